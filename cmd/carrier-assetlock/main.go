@@ -0,0 +1,70 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// carrier-assetlock runs as the init container before the asset container of GameServers
+// caching assets on host path. It exits once the pod holds the node-level lease of the assets,
+// which is released by the GameServer controller once the asset container terminates.
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+
+	"github.com/ocgi/carrier/pkg/assetlock"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func main() {
+	var leaseDuration, retryPeriod, timeout time.Duration
+	pflag.DurationVar(&leaseDuration, "lease-duration", 10*time.Minute,
+		"how long the lease is held if it is not released, e.g. the pod crashed while downloading.")
+	pflag.DurationVar(&retryPeriod, "retry-period", 2*time.Second, "interval of trying to acquire the lease.")
+	pflag.DurationVar(&timeout, "timeout", time.Hour,
+		"how long to wait for the lease before downloading without it, never gives up if 0.")
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
+	pflag.Parse()
+	defer klog.Flush()
+
+	name, holder, namespace := os.Getenv(util.AssetLeaseEnv), os.Getenv(util.PodNameEnv),
+		os.Getenv(util.PodNamespaceEnv)
+	if len(name) == 0 || len(holder) == 0 || len(namespace) == 0 {
+		klog.Fatalf("%v, %v and %v are required", util.AssetLeaseEnv, util.PodNameEnv, util.PodNamespaceEnv)
+	}
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		klog.Fatalf("Failed to build config: %v", err)
+	}
+	client := kubernetes.NewForConfigOrDie(config)
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	klog.Infof("Acquiring lease %v/%v as %v", namespace, name, holder)
+	if err = assetlock.Acquire(ctx, client.CoordinationV1(), namespace, name, holder, leaseDuration,
+		retryPeriod); err != nil {
+		// downloading without the lease is slower, but never blocks the GameServer.
+		klog.Warningf("Gave up acquiring lease %v/%v: %v", namespace, name, err)
+		return
+	}
+	klog.Infof("Acquired lease %v/%v", namespace, name)
+}
@@ -0,0 +1,244 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// carrier-loadtest creates synthetic GameServerSets against a real or simulated apiserver,
+// e.g. kwok, waits for their GameServers to be ready, and reports the time to N ready,
+// the reconcile throughput of controllers and the apiserver QPS during the test.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	carrierclient "github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+const (
+	// loadTestLabelKey labels the GameServerSets created by the load test.
+	loadTestLabelKey = "carrier.ocgi.dev/loadtest"
+	// kwokNodeTaintKey is the taint of nodes simulated by kwok.
+	kwokNodeTaintKey = "kwok.x-k8s.io/node"
+)
+
+// options are the options of a load test.
+type options struct {
+	kubeconfigPath       string
+	masterURL            string
+	namespace            string
+	sets                 int
+	replicas             int
+	image                string
+	kwok                 bool
+	timeout              time.Duration
+	interval             time.Duration
+	controllerMetricsURL string
+	cleanup              bool
+}
+
+// report is the result of a load test.
+type report struct {
+	GameServerSets int `json:"gameServerSets"`
+	Replicas       int `json:"replicas"`
+	// Ready is the number of ready GameServers when the test ends.
+	Ready int32 `json:"ready"`
+	// Duration is how long the test took.
+	Duration string `json:"duration"`
+	// TimeToReady is the time until the percentage of GameServers are ready, keyed by percentage.
+	TimeToReady map[string]string `json:"timeToReady"`
+	// SyncsPerSecond is the reconcile throughput of each controller, empty if the metrics
+	// of controller are not scraped.
+	SyncsPerSecond map[string]float64 `json:"syncsPerSecond,omitempty"`
+	// APIServerQPS is the requests per second served by the apiserver.
+	APIServerQPS float64 `json:"apiserverQPS"`
+}
+
+func main() {
+	o := &options{}
+	pflag.StringVar(&o.kubeconfigPath, "kubeconfig-path", "", "Absolute path to the kubeconfig file.")
+	pflag.StringVar(&o.masterURL, "master", "", "Master url.")
+	pflag.StringVar(&o.namespace, "namespace", "carrier-loadtest", "namespace the GameServerSets are created in.")
+	pflag.IntVar(&o.sets, "gameserversets", 10, "number of GameServerSets created.")
+	pflag.IntVar(&o.replicas, "replicas", 100, "replicas of each GameServerSet.")
+	pflag.StringVar(&o.image, "image", "k8s.gcr.io/pause:3.2", "image of game server container.")
+	pflag.BoolVar(&o.kwok, "kwok", false, "schedule GameServers onto nodes simulated by kwok.")
+	pflag.DurationVar(&o.timeout, "timeout", 30*time.Minute, "how long to wait for all GameServers to be ready.")
+	pflag.DurationVar(&o.interval, "interval", 5*time.Second, "period GameServerSets are polled.")
+	pflag.StringVar(&o.controllerMetricsURL, "controller-metrics-url", "",
+		"url of controller metrics, e.g. http://carrier-controller:8080/metrics, "+
+			"reconcile throughput is not reported if not set.")
+	pflag.BoolVar(&o.cleanup, "cleanup", true, "delete the GameServerSets after the test.")
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
+	pflag.Parse()
+	defer klog.Flush()
+
+	config, err := clientcmd.BuildConfigFromFlags(o.masterURL, o.kubeconfigPath)
+	if err != nil {
+		klog.Fatalf("Failed to build config: %v", err)
+	}
+	// the load test must not be throttled by client side rate limit.
+	config.QPS, config.Burst = 1000, 2000
+	kubeClient := kubernetes.NewForConfigOrDie(config)
+	carrierClient := carrierclient.NewForConfigOrDie(config)
+
+	_, err = kubeClient.CoreV1().Namespaces().Create(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: o.namespace},
+	})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		klog.Fatalf("Failed to create namespace %v: %v", o.namespace, err)
+	}
+
+	startRequests := scrapeAPIServer(kubeClient)
+	startSyncs := scrapeController(o.controllerMetricsURL)
+	start := time.Now()
+	for i := 0; i < o.sets; i++ {
+		gsSet := buildGameServerSet(o, fmt.Sprintf("loadtest-%v", i))
+		if _, err = carrierClient.CarrierV1alpha1().GameServerSets(o.namespace).Create(gsSet); err != nil {
+			klog.Fatalf("Failed to create GameServerSet %v: %v", gsSet.Name, err)
+		}
+	}
+	if o.cleanup {
+		defer func() {
+			err := carrierClient.CarrierV1alpha1().GameServerSets(o.namespace).DeleteCollection(
+				&metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: loadTestLabelKey})
+			if err != nil {
+				klog.Errorf("Failed to delete GameServerSets: %v", err)
+			}
+		}()
+	}
+
+	r := &report{
+		GameServerSets: o.sets,
+		Replicas:       o.replicas,
+		TimeToReady:    make(map[string]string),
+	}
+	desired := int32(o.sets * o.replicas)
+	milestones := []int32{50, 90, 99, 100}
+	deadline := start.Add(o.timeout)
+	for r.Ready < desired && time.Now().Before(deadline) {
+		time.Sleep(o.interval)
+		list, err := carrierClient.CarrierV1alpha1().GameServerSets(o.namespace).List(
+			metav1.ListOptions{LabelSelector: loadTestLabelKey})
+		if err != nil {
+			klog.Errorf("Failed to list GameServerSets: %v", err)
+			continue
+		}
+		r.Ready = 0
+		for _, gsSet := range list.Items {
+			r.Ready += gsSet.Status.ReadyReplicas
+		}
+		for _, percent := range milestones {
+			key := fmt.Sprintf("%v%%", percent)
+			if _, ok := r.TimeToReady[key]; !ok && r.Ready*100 >= desired*percent {
+				r.TimeToReady[key] = time.Since(start).String()
+			}
+		}
+		klog.Infof("%v/%v GameServers ready after %v", r.Ready, desired, time.Since(start))
+	}
+	elapsed := time.Since(start)
+	r.Duration = elapsed.String()
+	if requests := scrapeAPIServer(kubeClient); requests != nil && startRequests != nil {
+		r.APIServerQPS = (requests[""] - startRequests[""]) / elapsed.Seconds()
+	}
+	if syncs := scrapeController(o.controllerMetricsURL); syncs != nil && startSyncs != nil {
+		r.SyncsPerSecond = make(map[string]float64)
+		for controller, count := range syncs {
+			r.SyncsPerSecond[controller] = (count - startSyncs[controller]) / elapsed.Seconds()
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(r); err != nil {
+		klog.Fatal(err)
+	}
+	if r.Ready < desired {
+		klog.Errorf("Only %v/%v GameServers are ready before timeout", r.Ready, desired)
+	}
+}
+
+// buildGameServerSet builds a synthetic GameServerSet of the load test.
+func buildGameServerSet(o *options, name string) *carrierv1alpha1.GameServerSet {
+	labels := map[string]string{loadTestLabelKey: name}
+	podSpec := corev1.PodSpec{
+		Containers: []corev1.Container{{Name: util.GameServerContainerName, Image: o.image}},
+	}
+	if o.kwok {
+		podSpec.NodeSelector = map[string]string{"type": "kwok"}
+		podSpec.Tolerations = []corev1.Toleration{{
+			Key:      kwokNodeTaintKey,
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoSchedule,
+		}}
+	}
+	return &carrierv1alpha1.GameServerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: carrierv1alpha1.GameServerSetSpec{
+			Replicas: int32(o.replicas),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: carrierv1alpha1.GameServerTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: carrierv1alpha1.GameServerSpec{
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec:       podSpec,
+					},
+				},
+			},
+		},
+	}
+}
+
+// scrapeAPIServer returns the number of requests served by the apiserver, keyed by empty string.
+func scrapeAPIServer(client kubernetes.Interface) map[string]float64 {
+	data, err := client.CoreV1().RESTClient().Get().AbsPath("/metrics").DoRaw()
+	if err != nil {
+		klog.Warningf("Failed to scrape apiserver metrics: %v", err)
+		return nil
+	}
+	return sumMetric(data, "apiserver_request_total", "")
+}
+
+// scrapeController returns the number of objects synced by each controller.
+func scrapeController(url string) map[string]float64 {
+	if len(url) == 0 {
+		return nil
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		klog.Warningf("Failed to scrape controller metrics: %v", err)
+		return nil
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		klog.Warningf("Failed to scrape controller metrics: %v", err)
+		return nil
+	}
+	return sumMetric(data, "carrier_controller_syncs_total", "controller")
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// sumMetric sums the samples of metric in the prometheus text format, grouped by the value
+// of label. All samples are summed into the empty key if label is empty.
+func sumMetric(data []byte, metric, label string) map[string]float64 {
+	sums := make(map[string]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || !strings.HasPrefix(line, metric) {
+			continue
+		}
+		name, labels, value := splitSample(line)
+		if name != metric {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		key := ""
+		if len(label) != 0 {
+			key = labels[label]
+		}
+		sums[key] += v
+	}
+	return sums
+}
+
+// splitSample splits a sample line into the metric name, labels and value, timestamps are dropped.
+func splitSample(line string) (string, map[string]string, string) {
+	labels := make(map[string]string)
+	name, rest := line, ""
+	if i := strings.IndexByte(line, '{'); i >= 0 {
+		j := strings.LastIndexByte(line, '}')
+		if j < i {
+			return "", nil, ""
+		}
+		name, rest = line[:i], strings.TrimSpace(line[j+1:])
+		for _, pair := range strings.Split(line[i+1:j], ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) == 2 {
+				labels[strings.TrimSpace(kv[0])] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+			}
+		}
+	} else if i := strings.IndexByte(line, ' '); i >= 0 {
+		name, rest = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return name, labels, ""
+	}
+	return name, labels, fields[0]
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSample(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		metric string
+		labels map[string]string
+		value  string
+	}{
+		{
+			name:   "no labels",
+			line:   "carrier_gameservers_total 3",
+			metric: "carrier_gameservers_total",
+			labels: map[string]string{},
+			value:  "3",
+		},
+		{
+			name:   "labels",
+			line:   `carrier_gameservers_total{state="Ready", squad="game"} 2`,
+			metric: "carrier_gameservers_total",
+			labels: map[string]string{"state": "Ready", "squad": "game"},
+			value:  "2",
+		},
+		{
+			name:   "timestamp dropped",
+			line:   `carrier_gameservers_total{state="Ready"} 2 1622534400000`,
+			metric: "carrier_gameservers_total",
+			labels: map[string]string{"state": "Ready"},
+			value:  "2",
+		},
+		{
+			name:   "empty labels",
+			line:   "carrier_gameservers_total{} 1.5",
+			metric: "carrier_gameservers_total",
+			labels: map[string]string{},
+			value:  "1.5",
+		},
+		{
+			name:   "no value",
+			line:   "carrier_gameservers_total",
+			metric: "carrier_gameservers_total",
+			labels: map[string]string{},
+		},
+		{
+			name: "unclosed labels",
+			line: `carrier_gameservers_total{state="Ready" 2`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			metric, labels, value := splitSample(tc.line)
+			if metric != tc.metric || !reflect.DeepEqual(labels, tc.labels) || value != tc.value {
+				t.Errorf("desired %v, %v, %v, get: %v, %v, %v", tc.metric, tc.labels, tc.value, metric, labels, value)
+			}
+		})
+	}
+}
+
+func TestSumMetric(t *testing.T) {
+	data := []byte(`# HELP carrier_gameservers_total The GameServers per state.
+# TYPE carrier_gameservers_total gauge
+carrier_gameservers_total{state="Ready",squad="a"} 2
+carrier_gameservers_total{state="Ready",squad="b"} 3
+carrier_gameservers_total{state="Starting",squad="a"} 1
+
+carrier_gameservers_total_errors{state="Ready"} 7
+carrier_gameservers_total{state="Ready",squad="c"} invalid
+carrier_allocations_total 4
+`)
+	tests := []struct {
+		name   string
+		metric string
+		label  string
+		sums   map[string]float64
+	}{
+		{
+			name:   "grouped by label",
+			metric: "carrier_gameservers_total",
+			label:  "state",
+			sums:   map[string]float64{"Ready": 5, "Starting": 1},
+		},
+		{
+			name:   "summed without label",
+			metric: "carrier_gameservers_total",
+			sums:   map[string]float64{"": 6},
+		},
+		{
+			name:   "label missing",
+			metric: "carrier_allocations_total",
+			label:  "state",
+			sums:   map[string]float64{"": 4},
+		},
+		{
+			name:   "metric missing",
+			metric: "carrier_nodes_total",
+			sums:   map[string]float64{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sums := sumMetric(data, tc.metric, tc.label)
+			if !reflect.DeepEqual(sums, tc.sums) {
+				t.Errorf("desired %v, get: %v", tc.sums, sums)
+			}
+		})
+	}
+}
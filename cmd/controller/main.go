@@ -18,11 +18,13 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"sync"
 	"time"
 
 	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
 	apiv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	ext "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
@@ -37,21 +39,47 @@ import (
 
 	"github.com/ocgi/carrier/cmd/controller/app"
 	"github.com/ocgi/carrier/pkg/apis/carrier"
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/audit"
 	carrierclient "github.com/ocgi/carrier/pkg/client/clientset/versioned"
 	carrierinformer "github.com/ocgi/carrier/pkg/client/informers/externalversions"
 	"github.com/ocgi/carrier/pkg/controllers"
+	"github.com/ocgi/carrier/pkg/controllers/chaos"
+	"github.com/ocgi/carrier/pkg/controllers/consolidation"
+	"github.com/ocgi/carrier/pkg/controllers/cost"
+	"github.com/ocgi/carrier/pkg/controllers/defrag"
 	"github.com/ocgi/carrier/pkg/controllers/gameservers"
 	"github.com/ocgi/carrier/pkg/controllers/gameserversets"
+	"github.com/ocgi/carrier/pkg/controllers/gc"
+	"github.com/ocgi/carrier/pkg/controllers/headroom"
+	"github.com/ocgi/carrier/pkg/controllers/nodecapacity"
+	"github.com/ocgi/carrier/pkg/controllers/nodemaintenance"
+	"github.com/ocgi/carrier/pkg/controllers/preemption"
 	"github.com/ocgi/carrier/pkg/controllers/squad"
+	"github.com/ocgi/carrier/pkg/demand"
+	"github.com/ocgi/carrier/pkg/diagnostics"
+	"github.com/ocgi/carrier/pkg/eventbus"
+	"github.com/ocgi/carrier/pkg/externalscaler"
+	"github.com/ocgi/carrier/pkg/fleetapi"
+	"github.com/ocgi/carrier/pkg/metrics"
+	"github.com/ocgi/carrier/pkg/query"
+	"github.com/ocgi/carrier/pkg/tenancy"
+	"github.com/ocgi/carrier/pkg/util/kube"
 	"github.com/ocgi/carrier/pkg/version"
+	"github.com/ocgi/carrier/pkg/webhook"
 )
 
 const (
 	defaultLeaseDuration = 15 * time.Second
 	defaultRenewDeadline = 10 * time.Second
 	defaultRetryPeriod   = 2 * time.Second
+	defaultWorkers       = 10
 )
 
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;create;update
+// +kubebuilder:rbac:groups="",resources=endpoints;configmaps,verbs=get;create;update
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get
+
 func main() {
 	runConfig := app.NewServerRunOptions()
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
@@ -78,23 +106,341 @@ func main() {
 	carrierClient := carrierclient.NewForConfigOrDie(kubeconfig)
 	exClient := ext.NewForConfigOrDie(kubeconfig)
 
+	if len(runConfig.AuditSink) != 0 {
+		sink, err := audit.NewSink(runConfig.AuditSink, runConfig.AuditWebhookURL)
+		if err != nil {
+			klog.Fatalf("Create audit sink failed: %v", err)
+		}
+		audit.SetSink(sink)
+	}
+
+	if runConfig.EnableWebhook() {
+		// webhook server runs on every replica, no matter if it is the leader.
+		var resolver webhook.ImageResolver
+		if runConfig.PinImageDigests {
+			// registries are looked up within the timeout of admission webhooks.
+			resolver = webhook.NewRegistryResolver(&http.Client{Timeout: 5 * time.Second},
+				runConfig.RegistryTokenRealms)
+		}
+		policy := webhook.Policy{
+			TLSDNSSuffixes: runConfig.GameServerTLSDNSSuffixes,
+			AssetCacheRoot: runConfig.AssetCacheHostRoot,
+			PostMortemRoot: runConfig.PostMortemHostRoot,
+		}
+		server := webhook.NewServer(runConfig.WebhookPort, runConfig.TLSCertFile, runConfig.TLSKeyFile,
+			carrierClient.CarrierV1alpha1().FleetProfiles(), client.CoreV1(), resolver, policy)
+		go func() {
+			if err := server.Run(stop); err != nil {
+				klog.Fatalf("Start webhook server failed: %v", err)
+			}
+		}()
+	}
+
+	metrics.Register()
+	if runConfig.MetricsPort != 0 {
+		// metrics server runs on every replica, only the leader reports controller metrics.
+		server := metrics.NewServer(runConfig.MetricsPort)
+		go func() {
+			if err := server.Run(stop); err != nil {
+				klog.Fatalf("Start metrics server failed: %v", err)
+			}
+		}()
+	}
+
+	if len(runConfig.DiagnosticsBindAddress) != 0 {
+		// diagnostics server runs on every replica, so followers could be profiled as well.
+		server := diagnostics.NewServer(runConfig.DiagnosticsBindAddress)
+		go func() {
+			if err := server.Run(stop); err != nil {
+				klog.Fatalf("Start diagnostics server failed: %v", err)
+			}
+		}()
+	}
+	if runConfig.DumpGoroutinesOnSigquit {
+		diagnostics.DumpGoroutinesOnSignal(stop)
+	}
+
 	coreFactory := informers.NewSharedInformerFactory(client, runConfig.Resync)
+	coreFactory.InformerFor(&corev1.Pod{}, gameservers.NewPodInformer(runConfig.StripManagedFields))
 	carrierFactory := carrierinformer.NewSharedInformerFactory(carrierClient, runConfig.Resync)
+	if runConfig.StripManagedFields {
+		carrierFactory.InformerFor(&carrierv1alpha1.GameServer{},
+			controllers.NewGameServerInformer(kube.StripManagedFields))
+		carrierFactory.InformerFor(&carrierv1alpha1.GameServerSet{},
+			controllers.NewGameServerSetInformer(kube.StripManagedFields))
+	}
 
 	if !isCRDReady(exClient.ApiextensionsV1beta1().CustomResourceDefinitions()) {
 		klog.Fatalf("wait for crd ready timeout")
 	}
 
-	gscontroller := gameservers.NewController(client, coreFactory, carrierClient, carrierFactory,
-		runConfig.MinPort, runConfig.MaxPort)
-	gsscontroller := gameserversets.NewController(client, carrierClient, carrierFactory)
-	sqdcontroller := squad.NewController(client, carrierClient, carrierFactory)
+	sdkPorts := &gameservers.SDKPorts{
+		GRPCPort: int32(runConfig.SDKGRPCPort),
+		HTTPPort: int32(runConfig.SDKHTTPPort),
+		MinPort:  int32(runConfig.SDKMinPort),
+		MaxPort:  int32(runConfig.SDKMaxPort),
+
+		HostNetworkMinPort: int32(runConfig.SDKHostNetworkMinPort),
+		HostNetworkMaxPort: int32(runConfig.SDKHostNetworkMaxPort),
+	}
+	if err := sdkPorts.Validate(); err != nil {
+		klog.Fatalf("Invalid SDK ports: %v", err)
+	}
+	var stuckFinalizer *gameservers.StuckFinalizerPolicy
+	if runConfig.StuckFinalizerThreshold > 0 {
+		stuckFinalizer = &gameservers.StuckFinalizerPolicy{
+			Threshold:   runConfig.StuckFinalizerThreshold,
+			ForceRemove: runConfig.ForceRemoveStuckFinalizer,
+		}
+	}
+	var addressResolver gameservers.AddressResolver
+	if len(runConfig.AddressResolverURL) != 0 {
+		addressResolver = gameservers.NewWebhookResolver(runConfig.AddressResolverURL)
+	}
+	var ca *gameservers.CertificateAuthority
+	if len(runConfig.GameServerCACertFile) != 0 {
+		ca, err = gameservers.LoadCertificateAuthority(runConfig.GameServerCACertFile, runConfig.GameServerCAKeyFile,
+			runConfig.GameServerTLSDNSSuffixes)
+		if err != nil {
+			klog.Fatalf("Load GameServer CA failed: %v", err)
+		}
+	}
+	orphanPodPolicy := gameservers.OrphanPodPolicy(runConfig.OrphanPodPolicy)
+	if err := orphanPodPolicy.Validate(); err != nil {
+		klog.Fatalf("Invalid orphan pod policy: %v", err)
+	}
+	var warmNodes *gameservers.WarmNodePolicy
+	if runConfig.WarmNodeAffinityWeight > 0 {
+		warmNodes = &gameservers.WarmNodePolicy{
+			Weight:   int32(runConfig.WarmNodeAffinityWeight),
+			MaxNodes: runConfig.WarmNodeAffinityMaxNodes,
+		}
+		if err := warmNodes.Validate(); err != nil {
+			klog.Fatalf("Invalid warm node affinity: %v", err)
+		}
+	}
+	var assetCache *gameservers.AssetCachePolicy
+	if len(runConfig.AssetCacheHostRoot) != 0 {
+		assetCache = &gameservers.AssetCachePolicy{
+			HostRoot:  runConfig.AssetCacheHostRoot,
+			LockImage: runConfig.AssetLockImage,
+		}
+		if err := assetCache.Validate(); err != nil {
+			klog.Fatalf("Invalid asset cache policy: %v", err)
+		}
+	}
+	gscontroller, err := gameservers.NewController(client, coreFactory, carrierClient, carrierFactory,
+		runConfig.MinPort, runConfig.MaxPort, sdkPorts, stuckFinalizer, addressResolver, ca, orphanPodPolicy,
+		runConfig.SimulateKwokNodes, warmNodes, assetCache)
+	if err != nil {
+		klog.Fatalf("Failed to create GameServer controller: %v", err)
+	}
+	var idleReaper *gameserversets.IdleReaper
+	if len(runConfig.IdleReaperPressureConditions) != 0 {
+		idleReaper = &gameserversets.IdleReaper{}
+		for _, condition := range runConfig.IdleReaperPressureConditions {
+			idleReaper.PressureConditions = append(idleReaper.PressureConditions,
+				corev1.NodeConditionType(condition))
+		}
+	}
+	gsscontroller := gameserversets.NewController(client, coreFactory, carrierClient, carrierFactory,
+		&gameserversets.PriorityLane{
+			Workers:     runConfig.PriorityWorkers,
+			MaxReplicas: int32(runConfig.PriorityMaxReplicas),
+		}, idleReaper, runConfig.MigrationMode)
+	sqdcontroller := squad.NewController(client, coreFactory, carrierClient, carrierFactory)
+	gccontroller := gc.NewController(carrierClient, carrierFactory,
+		runConfig.GameServerTTLAfterFinished, runConfig.GameServerFinishedRetain)
+	allControllers := []controllers.Controller{gscontroller, gsscontroller, sqdcontroller, gccontroller}
+	workers := map[controllers.Controller]int{
+		gscontroller:  runConfig.GameServerWorkers,
+		gsscontroller: runConfig.GameServerSetWorkers,
+		sqdcontroller: runConfig.SquadWorkers,
+	}
+	if len(runConfig.ChaosNamespace) != 0 {
+		chaosConfig := chaos.Config{
+			Namespace:          runConfig.ChaosNamespace,
+			Interval:           runConfig.ChaosInterval,
+			KillRate:           runConfig.ChaosKillRate,
+			ReadinessDelayRate: runConfig.ChaosReadinessDelayRate,
+			ReadinessDelay:     runConfig.ChaosReadinessDelay,
+			DrainRate:          runConfig.ChaosDrainRate,
+		}
+		if err := chaosConfig.Validate(); err != nil {
+			klog.Fatalf("Invalid chaos config: %v", err)
+		}
+		allControllers = append(allControllers, chaos.NewController(client, carrierClient, carrierFactory, chaosConfig))
+	}
+	if len(runConfig.ConsolidationNodeSelector) != 0 {
+		consolidationConfig := consolidation.Config{
+			NodeSelector: runConfig.ConsolidationNodeSelector,
+			Interval:     runConfig.ConsolidationInterval,
+			MaxNodes:     runConfig.ConsolidationMaxNodes,
+		}
+		if err := consolidationConfig.Validate(); err != nil {
+			klog.Fatalf("Invalid consolidation config: %v", err)
+		}
+		allControllers = append(allControllers,
+			consolidation.NewController(carrierClient, coreFactory, carrierFactory, consolidationConfig))
+	}
+	if runConfig.DefragInterval > 0 {
+		allControllers = append(allControllers,
+			defrag.NewController(client, carrierClient, coreFactory, carrierFactory, runConfig.DefragInterval))
+	}
+	if runConfig.NodeMaintenanceInterval > 0 {
+		allControllers = append(allControllers, nodemaintenance.NewController(client, carrierClient,
+			coreFactory, carrierFactory, runConfig.NodeMaintenanceInterval))
+	}
+	if runConfig.NodeCapacityInterval > 0 {
+		nodeCapacityConfig := nodecapacity.Config{
+			NodeSelector: runConfig.NodeCapacitySelector,
+			Interval:     runConfig.NodeCapacityInterval,
+		}
+		if err := nodeCapacityConfig.Validate(); err != nil {
+			klog.Fatalf("Invalid node capacity config: %v", err)
+		}
+		allControllers = append(allControllers,
+			nodecapacity.NewController(client, coreFactory, carrierFactory, nodeCapacityConfig))
+	}
+	if runConfig.CostInterval > 0 {
+		costConfig := cost.Config{LabelKeys: runConfig.CostLabelKeys, Interval: runConfig.CostInterval}
+		if err := costConfig.Validate(); err != nil {
+			klog.Fatalf("Invalid cost config: %v", err)
+		}
+		allControllers = append(allControllers,
+			cost.NewController(client, carrierClient, coreFactory, carrierFactory, costConfig))
+	}
+	if runConfig.HeadroomInterval > 0 {
+		headroomConfig := headroom.Config{
+			Interval: runConfig.HeadroomInterval,
+			Window:   runConfig.HeadroomWindow,
+			Horizon:  runConfig.HeadroomHorizon,
+		}
+		if err := headroomConfig.Validate(); err != nil {
+			klog.Fatalf("Invalid headroom config: %v", err)
+		}
+		allControllers = append(allControllers,
+			headroom.NewController(client, carrierClient, carrierFactory, headroomConfig))
+	}
+	if runConfig.PreemptionInterval > 0 {
+		allControllers = append(allControllers, preemption.NewController(client, carrierClient,
+			coreFactory, carrierFactory, runConfig.PreemptionInterval))
+	}
+	var tenants *tenancy.Config
+	if len(runConfig.TenancyConfig) != 0 {
+		tenants, err = tenancy.Load(runConfig.TenancyConfig)
+		if err != nil {
+			klog.Fatalf("Load tenancy config failed: %v", err)
+		}
+	}
+	if len(runConfig.EventWebhookURL) != 0 || len(runConfig.EventKafkaRESTURL) != 0 ||
+		len(runConfig.EventNATSURL) != 0 || tenants != nil {
+		var publishers []eventbus.Publisher
+		if len(runConfig.EventWebhookURL) != 0 {
+			webhook, err := eventbus.NewWebhookPublisher(runConfig.EventWebhookURL, runConfig.EventEncoding)
+			if err != nil {
+				klog.Fatalf("Create event publisher failed: %v", err)
+			}
+			publishers = append(publishers, webhook)
+		}
+		if len(runConfig.EventKafkaRESTURL) != 0 {
+			kafka, err := eventbus.NewKafkaPublisher(runConfig.EventKafkaRESTURL, runConfig.EventKafkaTopic,
+				runConfig.EventEncoding)
+			if err != nil {
+				klog.Fatalf("Create event publisher failed: %v", err)
+			}
+			publishers = append(publishers, kafka)
+		}
+		if len(runConfig.EventNATSURL) != 0 {
+			nats, err := eventbus.NewNATSPublisher(runConfig.EventNATSURL, runConfig.EventNATSSubject,
+				runConfig.EventEncoding)
+			if err != nil {
+				klog.Fatalf("Create event publisher failed: %v", err)
+			}
+			publishers = append(publishers, nats)
+		}
+		var publisher eventbus.Publisher
+		if len(publishers) != 0 {
+			publisher = eventbus.NewMultiPublisher(publishers...)
+		}
+		if tenants != nil {
+			publisher, err = eventbus.NewTenantPublisher(tenants, publisher, runConfig.EventEncoding)
+			if err != nil {
+				klog.Fatalf("Create event publisher failed: %v", err)
+			}
+		}
+		allControllers = append(allControllers, eventbus.NewController(carrierClient, carrierFactory, publisher))
+	}
+	id, err := os.Hostname()
+	if err != nil {
+		klog.Fatalf("Unable to get hostname: %v", err)
+	}
+	// unfulfilled allocations are tracked by the query server of every replica, shared by the
+	// replicas in the Squads, and reported by the external scaler.
+	tracker := demand.NewTracker(runConfig.DemandWindow, id, carrierClient,
+		carrierFactory.Carrier().V1alpha1().Squads().Lister())
+	go tracker.Run(stop)
+	if runConfig.QueryPort != 0 {
+		// query server runs on every replica, answering from the informer cache.
+		queryConfig := query.Config{
+			GRPCPort:   runConfig.QueryGRPCPort,
+			MaxWaiting: runConfig.QueryMaxWaiting,
+			MaxWait:    runConfig.QueryMaxWait,
+			Hinter:     tracker,
+		}
+		if tenants != nil {
+			// tenants send their tokens in queries, which must not go in plaintext.
+			if !runConfig.EnableWebhook() {
+				klog.Fatal("Query server requires tls cert and key files with tenancy config")
+			}
+			queryConfig.CertFile = runConfig.TLSCertFile
+			queryConfig.KeyFile = runConfig.TLSKeyFile
+		}
+		server := query.NewServer(runConfig.QueryPort, carrierFactory, tenants, queryConfig)
+		go func() {
+			if err := server.Run(stop); err != nil {
+				klog.Fatalf("Start query server failed: %v", err)
+			}
+		}()
+	}
+	if runConfig.ExternalScalerPort != 0 {
+		if !runConfig.EnableWebhook() || len(runConfig.ExternalScalerClientCAFile) == 0 {
+			klog.Fatal("External scaler server requires tls cert, key and client CA files")
+		}
+		// external scaler server runs on every replica, answering from the informer cache.
+		server := externalscaler.NewServer(runConfig.ExternalScalerPort, runConfig.TLSCertFile,
+			runConfig.TLSKeyFile, runConfig.ExternalScalerClientCAFile, runConfig.ExternalScalerAllowedNames,
+			carrierFactory, tracker)
+		go func() {
+			if err := server.Run(stop); err != nil {
+				klog.Fatalf("Start external scaler server failed: %v", err)
+			}
+		}()
+	}
+	if runConfig.FleetAPIPort != 0 {
+		if len(runConfig.FleetAPICertFile) == 0 || len(runConfig.FleetAPIKeyFile) == 0 ||
+			len(runConfig.FleetAPIClientCAFile) == 0 {
+			klog.Fatal("Fleet API server requires tls cert, key and client CA files")
+		}
+		// fleet API server runs on every replica, answering from the informer cache.
+		server := fleetapi.NewServer(runConfig.FleetAPIPort, runConfig.FleetAPICertFile, runConfig.FleetAPIKeyFile,
+			runConfig.FleetAPIClientCAFile, runConfig.FleetAPIAllowedNames, client, coreFactory, carrierFactory)
+		go func() {
+			if err := server.Run(stop); err != nil {
+				klog.Fatalf("Start fleet API server failed: %v", err)
+			}
+		}()
+	}
 	coreFactory.Start(stop)
 	carrierFactory.Start(stop)
 	run := func(ctx context.Context) {
-		for _, c := range []controllers.Controller{gscontroller, gsscontroller, sqdcontroller} {
+		for _, c := range allControllers {
 			go func(c controllers.Controller) {
-				err := c.Run(10, ctx.Done())
+				n, ok := workers[c]
+				if !ok {
+					n = defaultWorkers
+				}
+				err := c.Run(n, ctx.Done())
 				if err != nil {
 					klog.Fatal("Start controller failed")
 				}
@@ -102,11 +448,6 @@ func main() {
 		}
 	}
 
-	id, err := os.Hostname()
-	if err != nil {
-		klog.Fatalf("Unable to get hostname: %v", err)
-	}
-
 	lock, err := resourcelock.New(
 		leaderElection.ResourceLock,
 		runConfig.ElectionNamespace,
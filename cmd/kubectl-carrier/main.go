@@ -0,0 +1,366 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// kubectl-carrier is a kubectl plugin resolving the pod and container backing a
+// GameServer, and attaching to it by the GameServer name, e.g.
+//
+//	kubectl carrier exec my-gs -it -- sh
+//	kubectl carrier port-forward my-gs 17777:default
+//
+// It also injects an ephemeral debug container sharing the process namespace of the
+// GameServer, so live matches are profiled without restarting them, e.g.
+//
+//	kubectl carrier debug my-gs --image=busybox -it
+//
+// It also updates the images of a Squad without applying the whole template, and
+// renders the template of a Squad resolved with its FleetProfile, e.g.
+//
+//	kubectl carrier set-image my-squad server=game:v2
+//	kubectl carrier explain-template my-squad
+//
+// It also repairs the stale control annotations of GameServerSets and their GameServers
+// left by reconciles failed halfway, e.g.
+//
+//	kubectl carrier repair-annotations --dry-run
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	carrierclient "github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/controllers/gameserversets"
+	"github.com/ocgi/carrier/pkg/controllers/squad"
+	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/webhook"
+)
+
+const usage = `Usage:
+  kubectl carrier exec NAME [-n NAMESPACE] [-c CONTAINER] [-i] [-t] -- COMMAND [ARGS...]
+  kubectl carrier port-forward NAME [-n NAMESPACE] [LOCAL_PORT:]PORT...
+  kubectl carrier debug NAME [-n NAMESPACE] [-c CONTAINER] [--image IMAGE] [-i] [-t] [-- COMMAND [ARGS...]]
+  kubectl carrier set-image SQUAD [-n NAMESPACE] CONTAINER=IMAGE...
+  kubectl carrier explain-template SQUAD [-n NAMESPACE]
+  kubectl carrier repair-annotations [-n NAMESPACE] [--dry-run]
+
+PORT could be a port number or the name of a port of the GameServer.
+The debug container shares the process namespace of CONTAINER, and is attached to if -i is set.
+`
+
+// debugTimeout is how long the ephemeral debug container could take to start.
+const debugTimeout = 2 * time.Minute
+
+// minArgs is the minimum number of arguments of each command.
+var minArgs = map[string]int{
+	"exec":               2,
+	"port-forward":       2,
+	"debug":              1,
+	"set-image":          2,
+	"explain-template":   1,
+	"repair-annotations": 0,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+	command := os.Args[1]
+	flags := pflag.NewFlagSet("kubectl-carrier", pflag.ExitOnError)
+	var kubeconfig, namespace, container, image string
+	var stdin, tty, dryRun bool
+	flags.StringVar(&kubeconfig, "kubeconfig", "", "path to the kubeconfig file.")
+	flags.StringVarP(&namespace, "namespace", "n", "", "namespace of the GameServer or Squad.")
+	flags.StringVarP(&container, "container", "c", "", "container name, default is the game server container.")
+	flags.StringVar(&image, "image", "busybox", "image of the ephemeral debug container.")
+	flags.BoolVarP(&stdin, "stdin", "i", false, "pass stdin to the container.")
+	flags.BoolVarP(&tty, "tty", "t", false, "stdin is a TTY.")
+	flags.BoolVar(&dryRun, "dry-run", false, "only report the stale annotations without repairing them.")
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	if err := flags.Parse(os.Args[2:]); err != nil {
+		fatalf("%v", err)
+	}
+	args := flags.Args()
+	if required, ok := minArgs[command]; !ok || len(args) < required {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+	if len(namespace) == 0 {
+		ns, _, err := clientConfig.Namespace()
+		if err != nil {
+			fatalf("Failed to get namespace: %v", err)
+		}
+		namespace = ns
+	}
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		fatalf("Failed to build config: %v", err)
+	}
+	switch command {
+	case "set-image":
+		setImage(config, namespace, args[0], args[1:])
+		return
+	case "explain-template":
+		explainTemplate(config, namespace, args[0])
+		return
+	case "repair-annotations":
+		repairAnnotations(config, namespace, dryRun)
+		return
+	}
+
+	name := args[0]
+	gs, err := carrierclient.NewForConfigOrDie(config).CarrierV1alpha1().GameServers(namespace).
+		Get(name, metav1.GetOptions{})
+	if err != nil {
+		fatalf("Failed to get GameServer %v/%v: %v", namespace, name, err)
+	}
+	pods := kubernetes.NewForConfigOrDie(config).CoreV1().Pods(namespace)
+	pod, err := pods.Get(gs.Name, metav1.GetOptions{})
+	if err != nil {
+		fatalf("Failed to get pod of GameServer %v/%v: %v", namespace, name, err)
+	}
+	target, err := gameservers.ResolveDebugTarget(gs, pod, container)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	kubectlArgs := []string{"--namespace", target.Namespace}
+	if len(kubeconfig) != 0 {
+		kubectlArgs = append(kubectlArgs, "--kubeconfig", kubeconfig)
+	}
+	switch command {
+	case "exec":
+		kubectlArgs = append(kubectlArgs, "exec", target.Pod, "--container", target.Container)
+		if stdin {
+			kubectlArgs = append(kubectlArgs, "--stdin")
+		}
+		if tty {
+			kubectlArgs = append(kubectlArgs, "--tty")
+		}
+		kubectlArgs = append(append(kubectlArgs, "--"), args[1:]...)
+	case "port-forward":
+		ports, err := gameservers.ResolveDebugPorts(gs, args[1:])
+		if err != nil {
+			fatalf("%v", err)
+		}
+		kubectlArgs = append(append(kubectlArgs, "port-forward", "pod/"+target.Pod), ports...)
+	case "debug":
+		name := injectDebugContainer(pods, pod, target, image, args[1:])
+		if !stdin {
+			fmt.Printf("Attach with: kubectl attach %v -n %v -c %v -it\n", target.Pod, target.Namespace, name)
+			return
+		}
+		kubectlArgs = append(kubectlArgs, "attach", target.Pod, "--container", name, "--stdin")
+		if tty {
+			kubectlArgs = append(kubectlArgs, "--tty")
+		}
+	}
+	cmd := exec.Command("kubectl", kubectlArgs...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		fatalf("Failed to run kubectl: %v", err)
+	}
+}
+
+// injectDebugContainer injects an ephemeral debug container into pod targeting the container of
+// target, and waits until it is running. Returns the name of the debug container.
+func injectDebugContainer(pods typedcorev1.PodInterface, pod *corev1.Pod, target *gameservers.DebugTarget,
+	image string, command []string) string {
+	container := gameservers.DebugContainer(pod, target, image, command)
+	ephemeral, err := pods.GetEphemeralContainers(pod.Name, metav1.GetOptions{})
+	if err != nil {
+		fatalf("Failed to get ephemeral containers of pod %v/%v: %v", pod.Namespace, pod.Name, err)
+	}
+	ephemeral.EphemeralContainers = append(ephemeral.EphemeralContainers, container)
+	if _, err = pods.UpdateEphemeralContainers(pod.Name, ephemeral); err != nil {
+		fatalf("Failed to inject ephemeral container into pod %v/%v: %v", pod.Namespace, pod.Name, err)
+	}
+	fmt.Printf("Ephemeral container %v injected into pod %v/%v, targeting container %v\n",
+		container.Name, pod.Namespace, pod.Name, target.Container)
+	err = wait.PollImmediate(time.Second, debugTimeout, func() (bool, error) {
+		current, err := pods.Get(pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, status := range current.Status.EphemeralContainerStatuses {
+			if status.Name != container.Name {
+				continue
+			}
+			if status.State.Terminated != nil {
+				return false, fmt.Errorf("ephemeral container %v terminated: %v", container.Name,
+					status.State.Terminated.Reason)
+			}
+			return status.State.Running != nil, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		fatalf("Failed to wait for ephemeral container %v to run: %v", container.Name, err)
+	}
+	return container.Name
+}
+
+// setImage patches the images of containers in the template of Squad.
+func setImage(config *rest.Config, namespace, name string, args []string) {
+	images := make(map[string]string, len(args))
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			fatalf("Invalid image %q, should be CONTAINER=IMAGE", arg)
+		}
+		images[parts[0]] = parts[1]
+	}
+	squads := carrierclient.NewForConfigOrDie(config).CarrierV1alpha1().Squads(namespace)
+	sqd, err := squads.Get(name, metav1.GetOptions{})
+	if err != nil {
+		fatalf("Failed to get Squad %v/%v: %v", namespace, name, err)
+	}
+	patch, err := squad.ImagePatch(sqd, images)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if _, err = squads.Patch(name, types.JSONPatchType, patch); err != nil {
+		fatalf("Failed to patch Squad %v/%v: %v", namespace, name, err)
+	}
+	fmt.Printf("squad.carrier.ocgi.dev/%v image updated\n", name)
+}
+
+// explainTemplate prints the template of Squad resolved with its FleetProfile, followed by
+// the source of each field merged from the FleetProfile.
+func explainTemplate(config *rest.Config, namespace, name string) {
+	client := carrierclient.NewForConfigOrDie(config).CarrierV1alpha1()
+	sqd, err := client.Squads(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		fatalf("Failed to get Squad %v/%v: %v", namespace, name, err)
+	}
+	var profile *carrierv1alpha1.FleetProfile
+	if sqd.Spec.ProfileRef != nil {
+		profile, err = client.FleetProfiles().Get(sqd.Spec.ProfileRef.Name, metav1.GetOptions{})
+		if err != nil {
+			fatalf("Failed to get FleetProfile %v: %v", sqd.Spec.ProfileRef.Name, err)
+		}
+	}
+	template, provenance := webhook.ResolveTemplate(sqd, profile)
+	data, err := json.MarshalIndent(template, "", "  ")
+	if err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Println(string(data))
+	if len(provenance) == 0 {
+		fmt.Println("\nAll fields are set by the Squad.")
+		return
+	}
+	fields := make([]string, 0, len(provenance))
+	for field := range provenance {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	fmt.Println("\nFields not listed are set by the Squad:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tSOURCE")
+	for _, field := range fields {
+		fmt.Fprintf(w, "%v\t%v\n", field, provenance[field])
+	}
+	w.Flush()
+}
+
+// repairAnnotations repairs the stale control annotations of GameServerSets in namespace and
+// their GameServers, and reports what was repaired.
+func repairAnnotations(config *rest.Config, namespace string, dryRun bool) {
+	client := carrierclient.NewForConfigOrDie(config).CarrierV1alpha1()
+	gsSets, err := client.GameServerSets(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		fatalf("Failed to list GameServerSets in %v: %v", namespace, err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tREPAIR")
+	repaired := 0
+	for i := range gsSets.Items {
+		gsSet := &gsSets.Items[i]
+		gsList, err := client.GameServers(namespace).List(metav1.ListOptions{
+			LabelSelector: util.GameServerSetLabelKey + "=" + gsSet.Name,
+		})
+		if err != nil {
+			fatalf("Failed to list GameServers of GameServerSet %v/%v: %v", namespace, gsSet.Name, err)
+		}
+		for j := range gsList.Items {
+			gs := &gsList.Items[j]
+			if !metav1.IsControlledBy(gs, gsSet) {
+				continue
+			}
+			repairs := gameserversets.StaleGameServerAnnotations(gsSet, gs)
+			if len(repairs) != 0 && !dryRun {
+				_, err = client.GameServers(namespace).Patch(gs.Name, types.MergePatchType,
+					gameserversets.GameServerRepairPatch(gs, repairs))
+				if err != nil {
+					fatalf("Failed to patch GameServer %v/%v: %v", namespace, gs.Name, err)
+				}
+			}
+			for _, repair := range repairs {
+				fmt.Fprintf(w, "GameServer\t%v\t%v\n", gs.Name, repair)
+			}
+			repaired += len(repairs)
+		}
+		repairs := gameserversets.StaleGameServerSetAnnotations(gsSet)
+		if len(repairs) != 0 && !dryRun {
+			_, err = client.GameServerSets(namespace).Patch(gsSet.Name, types.MergePatchType,
+				gameserversets.AnnotationRepairPatch(repairs))
+			if err != nil {
+				fatalf("Failed to patch GameServerSet %v/%v: %v", namespace, gsSet.Name, err)
+			}
+		}
+		for _, repair := range repairs {
+			fmt.Fprintf(w, "GameServerSet\t%v\t%v\n", gsSet.Name, repair)
+		}
+		repaired += len(repairs)
+	}
+	if repaired == 0 {
+		fmt.Println("No stale annotations found.")
+		return
+	}
+	w.Flush()
+	if dryRun {
+		fmt.Printf("%v stale annotations found, not repaired in dry run\n", repaired)
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// simulate prints what the GameServerSet controller would add or delete for a
+// GameServerSet in the next sync, without changing anything.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+
+	"github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	carrierclient "github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/controllers/gameserversets"
+)
+
+func main() {
+	var kubeconfigPath, masterURL, namespace, name string
+	pflag.StringVar(&kubeconfigPath, "kubeconfig-path", "", "Absolute path to the kubeconfig file.")
+	pflag.StringVar(&masterURL, "master", "", "Master url.")
+	pflag.StringVar(&namespace, "namespace", metav1.NamespaceDefault, "namespace of the GameServerSet.")
+	pflag.StringVar(&name, "name", "", "name of the GameServerSet.")
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
+	pflag.Parse()
+	defer klog.Flush()
+	if len(name) == 0 {
+		klog.Fatal("--name is required")
+	}
+	config, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfigPath)
+	if err != nil {
+		klog.Fatalf("Failed to build config: %v", err)
+	}
+	client := carrierclient.NewForConfigOrDie(config)
+	gsSet, err := client.CarrierV1alpha1().GameServerSets(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		klog.Fatalf("Failed to get GameServerSet %v/%v: %v", namespace, name, err)
+	}
+	// GameServers of all namespaces are counted on nodes, same as the controller.
+	gsList, err := client.CarrierV1alpha1().GameServers(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		klog.Fatalf("Failed to list GameServers: %v", err)
+	}
+	nodeGameServers := make(map[string]uint64)
+	var list []*v1alpha1.GameServer
+	for i := range gsList.Items {
+		gs := &gsList.Items[i]
+		if gs.DeletionTimestamp == nil && len(gs.Status.NodeName) != 0 {
+			nodeGameServers[gs.Status.NodeName]++
+		}
+		if metav1.IsControlledBy(gs, gsSet) {
+			list = append(list, gs)
+		}
+	}
+	simulation := gameserversets.Simulate(gsSet, list, nodeGameServers)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(simulation); err != nil {
+		klog.Fatal(err)
+	}
+}
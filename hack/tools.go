@@ -18,6 +18,8 @@
 package tools
 
 import (
+	// Import protoc-gen-go to use in build tools
+	_ "github.com/golang/protobuf/protoc-gen-go"
 	// Import code-generator to use in build tools
 	_ "k8s.io/code-generator"
 )
@@ -0,0 +1,36 @@
+#!/bin/bash
+
+# Copyright 2021 The OCGI Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+SCRIPT_ROOT=$(cd "$(dirname "${BASH_SOURCE}")/.." && pwd)
+# directories of the proto files, the go code is generated next to them.
+PROTO_DIRS=(
+  pkg/externalscaler
+  pkg/query/querypb
+)
+
+# build protoc-gen-go at the version of github.com/golang/protobuf in go.mod,
+# so the generated code matches the proto package it is compiled against.
+_bin=$(mktemp -d)
+trap "rm -rf ${_bin}" EXIT
+(cd "${SCRIPT_ROOT}" && go build -o "${_bin}/protoc-gen-go" github.com/golang/protobuf/protoc-gen-go)
+
+for dir in "${PROTO_DIRS[@]}"; do
+  (cd "${SCRIPT_ROOT}/${dir}" && PATH="${_bin}:${PATH}" protoc --go_out=plugins=grpc:. *.proto)
+done
@@ -0,0 +1,40 @@
+#!/bin/bash
+
+# Copyright 2021 The OCGI Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+SCRIPT_ROOT=$(dirname ${BASH_SOURCE})/..
+CONTROLLER_GEN=${CONTROLLER_GEN:-controller-gen}
+
+# generate one ClusterRole per controller from the +kubebuilder:rbac markers,
+# the roles are bound to the carrier ServiceAccount in manifeasts/deploy.yaml.
+cd ${SCRIPT_ROOT}
+for name in gameservers gameserversets squad gc consolidation defrag cost nodemaintenance nodecapacity headroom preemption chaos; do
+  ${CONTROLLER_GEN} rbac:roleName=carrier-${name}-controller \
+    paths=./pkg/controllers/${name}/... \
+    output:rbac:artifacts:config=manifeasts/rbac/${name}
+done
+${CONTROLLER_GEN} rbac:roleName=carrier-eventbus-controller \
+  paths=./pkg/eventbus/... \
+  output:rbac:artifacts:config=manifeasts/rbac/eventbus
+${CONTROLLER_GEN} rbac:roleName=carrier-demand-tracker \
+  paths=./pkg/demand/... \
+  output:rbac:artifacts:config=manifeasts/rbac/demand
+${CONTROLLER_GEN} rbac:roleName=carrier-leader-election \
+  paths=./cmd/controller \
+  output:rbac:artifacts:config=manifeasts/rbac/leaderelection
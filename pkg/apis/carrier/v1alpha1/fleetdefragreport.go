@@ -0,0 +1,104 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefragAction is the action taken on the nodes recommended to be emptied.
+type DefragAction string
+
+const (
+	// DefragRecommend only reports the nodes recommended to be emptied.
+	DefragRecommend DefragAction = "Recommend"
+	// DefragDrain cordons the nodes recommended to be emptied and marks their GameServers out of
+	// service, they are deleted as their drain policy allows, e.g. deletable gates and max drain
+	// seconds. The nodes are uncordoned once the report no longer drains, or once they stay
+	// cordoned longer than the drain timeout, e.g. cluster autoscaler never removes them.
+	DefragDrain DefragAction = "Drain"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FleetDefragReport is the data structure for a cluster scoped FleetDefragReport resource,
+// reporting how fragmented GameServers are on a node pool and which nodes could be emptied.
+type FleetDefragReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FleetDefragReportSpec   `json:"spec"`
+	Status FleetDefragReportStatus `json:"status"`
+}
+
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FleetDefragReportList is a list of FleetDefragReport resources
+type FleetDefragReportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []FleetDefragReport `json:"items"`
+}
+
+// FleetDefragReportSpec is the spec for a FleetDefragReport.
+type FleetDefragReportSpec struct {
+	// NodeSelector selects the nodes of the pool analyzed, all nodes if empty.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Capacity is the number of GameServers a node of the pool could run, defaults to the
+	// max number of GameServers running on any node of the pool.
+	Capacity int32 `json:"capacity,omitempty"`
+	// Action is the action taken on the nodes recommended, defaults to Recommend.
+	Action DefragAction `json:"action,omitempty"`
+	// MaxRecommendations is the max number of nodes recommended in each analysis, defaults to 1.
+	MaxRecommendations int32 `json:"maxRecommendations,omitempty"`
+	// DrainTimeoutSeconds is how long nodes drained stay cordoned waiting to be removed by
+	// cluster autoscaler, they are uncordoned afterwards to take GameServers again. Defaults to 3600.
+	DrainTimeoutSeconds *int32 `json:"drainTimeoutSeconds,omitempty"`
+}
+
+// FleetDefragReportStatus is the result of the latest analysis.
+type FleetDefragReportStatus struct {
+	// Nodes is the number of nodes in the pool.
+	Nodes int32 `json:"nodes"`
+	// EmptyNodes is the number of nodes in the pool running no GameServers.
+	EmptyNodes int32 `json:"emptyNodes"`
+	// GameServers is the number of GameServers in service on the pool.
+	GameServers int32 `json:"gameServers"`
+	// Capacity is the number of GameServers a node is regarded to run.
+	Capacity int32 `json:"capacity"`
+	// FragmentationPercent is the percentage of capacity unused on the nodes running GameServers.
+	FragmentationPercent int32 `json:"fragmentationPercent"`
+	// ReclaimableNodes is the number of nodes could be emptied by moving their GameServers
+	// to the unused capacity of other nodes.
+	ReclaimableNodes int32 `json:"reclaimableNodes"`
+	// Recommendations are the nodes recommended to be emptied, emptiest first.
+	Recommendations []DefragRecommendation `json:"recommendations,omitempty"`
+	// LastAnalysisTime is the time of the latest analysis.
+	LastAnalysisTime *metav1.Time `json:"lastAnalysisTime,omitempty"`
+}
+
+// DefragRecommendation is a node recommended to be emptied.
+type DefragRecommendation struct {
+	// NodeName is the name of node.
+	NodeName string `json:"nodeName"`
+	// GameServers is the number of GameServers in service on the node.
+	GameServers int32 `json:"gameServers"`
+	// Drained is true if the GameServers on the node are marked out of service.
+	Drained bool `json:"drained,omitempty"`
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FleetProfile is the data structure for a cluster scoped FleetProfile resource, holding
+// the defaults shared by Squads referencing it, so platform teams maintain them in one place.
+type FleetProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec FleetProfileSpec `json:"spec"`
+}
+
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FleetProfileList is a list of FleetProfile resources
+type FleetProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []FleetProfile `json:"items"`
+}
+
+// FleetProfileSpec is the spec for a FleetProfile. The defaults are merged into the template
+// of Squads on admission, values set by the Squad always take precedence.
+type FleetProfileSpec struct {
+	// Sidecars are added to the pod template unless it has a container of the same name.
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+	// Volumes are added to the pod template unless it has a volume of the same name,
+	// e.g. the config of sidecars.
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+	// NodeSelector is merged into the node selector of pod template, keys set by the
+	// Squad are kept.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations are added to the pod template unless it has the same toleration.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Affinity is used if the pod template has no affinity.
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// PriorityClassName is used if the pod template has no priority class.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// Scheduling is used if the GameServer template has no scheduling strategy.
+	Scheduling SchedulingStrategy `json:"scheduling,omitempty"`
+	// ReadinessGates are added to the readiness gates of GameServer template.
+	ReadinessGates []string `json:"readinessGates,omitempty"`
+	// DeletableGates are added to the deletable gates of GameServer template.
+	DeletableGates []string `json:"deletableGates,omitempty"`
+	// MaxDrainSeconds is used if the GameServer template has no drain deadline.
+	MaxDrainSeconds *int64 `json:"maxDrainSeconds,omitempty"`
+}
@@ -17,6 +17,7 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -56,6 +57,17 @@ type GameServerSpec struct {
 	// Scheduling strategy, including "LeastAllocated, MostAllocated, Default". Defaults to "MostAllocated".
 	Scheduling SchedulingStrategy `json:"scheduling,omitempty"`
 
+	// Colocation describes whether GameServers prefer or avoid sharing nodes with GameServers of other Squads.
+	Colocation []ColocationRule `json:"colocation,omitempty"`
+
+	// OS is the operating system of GameServer containers, "linux" or "windows". Defaults to "linux".
+	// Pods are scheduled to nodes of the OS only if it is set.
+	OS OperatingSystem `json:"os,omitempty"`
+
+	// Bandwidth limits the network bandwidth of GameServer pod, so noisy GameServers
+	// can not saturate the NIC of node. Requires the CNI bandwidth plugin.
+	Bandwidth *Bandwidth `json:"bandwidth,omitempty"`
+
 	// Template describes the Pod that will be created for the GameServer.
 	Template corev1.PodTemplateSpec `json:"template"`
 
@@ -77,6 +89,176 @@ type GameServerSpec struct {
 	// all conditions specified in the deletable gates have status equal to "True"
 	// +optional
 	DeletableGates []string `json:"deletableGates,omitempty"`
+
+	// MaxDrainSeconds is the maximum time a GameServer out of service waits for
+	// its deletable gates, counted from when it is first marked out of service, before
+	// being deleted or in place updated. GameServer exceeding it is deleted forcibly,
+	// even if players are still connected.
+	// +optional
+	MaxDrainSeconds *int64 `json:"maxDrainSeconds,omitempty"`
+
+	// GameVersion is the version of game content served by GameServer, e.g. the protocol
+	// version clients must speak. It is surfaced in status once the pod runs the spec.
+	// +optional
+	GameVersion string `json:"gameVersion,omitempty"`
+
+	// AssetCache describes the init container downloading assets of GameServer into a
+	// cache volume, which is shared by GameServers on the same node if on host path.
+	// +optional
+	AssetCache *AssetCache `json:"assetCache,omitempty"`
+
+	// TLS describes the certificate minted for GameServer by the internal CA, for games
+	// requiring TLS or DTLS to clients.
+	// +optional
+	TLS *GameServerTLS `json:"tls,omitempty"`
+
+	// LogShipping describes the sidecar shipping logs of GameServer, e.g. to ELK or Loki.
+	// +optional
+	LogShipping *LogShipping `json:"logShipping,omitempty"`
+
+	// PostMortem describes the Job collecting crash dumps and logs of GameServer once it fails.
+	// +optional
+	PostMortem *PostMortem `json:"postMortem,omitempty"`
+
+	// MaxIdleSeconds is how long the GameServer could stay ready without being allocated, i.e.
+	// without players reported. GameServers idle longer are scaled down first while nodes report
+	// resource pressure, if the idle reaper of controller is enabled.
+	// +optional
+	MaxIdleSeconds *int64 `json:"maxIdleSeconds,omitempty"`
+
+	// CompletionPolicy describes what happens once the GameServer exits successfully, "Replace"
+	// or "OneShot". Defaults to "Replace". OneShot GameServers, e.g. tournament matches, turn
+	// Completed and are not replaced, while crashed ones are still replaced.
+	// +optional
+	CompletionPolicy CompletionPolicy `json:"completionPolicy,omitempty"`
+
+	// MaxSessionSeconds is the max lifetime of a session on the GameServer, counted from when
+	// it was allocated, or from when it is running if it does not report players. GameServer
+	// exceeding it is marked out of service and drained, so a leaked session never ending could
+	// not hold the capacity forever.
+	// +optional
+	MaxSessionSeconds *int64 `json:"maxSessionSeconds,omitempty"`
+
+	// SecurityProfile is the Pod Security Standard pods of GameServer comply with, "Baseline" or
+	// "Restricted". Security context defaults of the profile are set on pods, and GameServers
+	// violating it, e.g. in host network or with host ports, are rejected at admission.
+	// Only linux GameServers support it.
+	// +optional
+	SecurityProfile SecurityProfile `json:"securityProfile,omitempty"`
+}
+
+// SecurityProfile is a level of the Pod Security Standards.
+type SecurityProfile string
+
+const (
+	// BaselineSecurityProfile prevents known privilege escalations, e.g. privileged containers,
+	// host namespaces, host ports and host path volumes.
+	BaselineSecurityProfile SecurityProfile = "Baseline"
+
+	// RestrictedSecurityProfile also requires containers run as non-root users, without
+	// privilege escalation and with all capabilities but NET_BIND_SERVICE dropped.
+	RestrictedSecurityProfile SecurityProfile = "Restricted"
+)
+
+// CompletionPolicy describes what happens once a GameServer exits successfully.
+type CompletionPolicy string
+
+const (
+	// ReplaceOnCompletion replaces GameServers exited successfully, same as crashed ones.
+	ReplaceOnCompletion CompletionPolicy = "Replace"
+
+	// OneShot keeps GameServers exited successfully as Completed, they are not replaced
+	// by GameServerSets, but scaled down first.
+	OneShot CompletionPolicy = "OneShot"
+)
+
+// PostMortem describes the crash dump collection of GameServer. Each GameServer writes crash
+// dumps and logs into its own directory on node, which survives the pod, and a Job is run on
+// the node once GameServer fails to upload the directory into object storage. The Job is owned
+// by GameServer, whose deletion waits for the Job to finish.
+type PostMortem struct {
+	// HostPath is the directory on node holding crash dumps, GameServers write into the
+	// subdirectory named by their namespace and name. It must be under the post mortem host
+	// root configured in controller.
+	HostPath string `json:"hostPath"`
+
+	// MountPath is the path the dump directory is mounted at in all containers and the Job.
+	MountPath string `json:"mountPath"`
+
+	// Container is the container of the Job, which uploads the dump directory in env
+	// CARRIER_DUMP_DIR to the URL in env CARRIER_ARTIFACT_URL, and should remove it after.
+	Container corev1.Container `json:"container"`
+
+	// ArtifactPrefix is the URL prefix in object storage, the artifact of GameServer is
+	// uploaded to prefix/namespace/name.
+	ArtifactPrefix string `json:"artifactPrefix"`
+
+	// TTLSecondsAfterFinished limits the lifetime of the Job after it finishes. Defaults to 3600.
+	// +optional
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+}
+
+// LogShipping describes the log shipper sidecar injected into GameServer pods. The log
+// directory is shared by all containers, and the log labels of GameServer are mounted
+// into the sidecar as files squad, revision and match-id, which are kept up to date.
+type LogShipping struct {
+	// Image is the image of the log shipper sidecar.
+	Image string `json:"image"`
+
+	// ConfigMap is the name of the ConfigMap holding the config of the log shipper,
+	// which is mounted at /etc/carrier/log-shipper in the sidecar.
+	// +optional
+	ConfigMap string `json:"configMap,omitempty"`
+
+	// LogPath is the directory GameServer writes logs into. Defaults to /var/log/gameserver.
+	// +optional
+	LogPath string `json:"logPath,omitempty"`
+
+	// Resources are the compute resources of the sidecar.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// AssetCache describes the assets downloaded before GameServer containers start.
+type AssetCache struct {
+	// Key identifies the content of assets, e.g. the version. Assets are downloaded
+	// into the directory named by key in the cache volume.
+	Key string `json:"key"`
+
+	// Container is the init container downloading assets into the directory in env
+	// CARRIER_ASSET_DIR, if they are not cached yet. For caches on host path, the controller
+	// may run the asset lock container before it, which holds the coordination Lease named in
+	// env CARRIER_ASSET_LEASE until the container terminates, so concurrent downloads on a
+	// node are deduped.
+	Container corev1.Container `json:"container"`
+
+	// MountPath is the path the cache volume is mounted at in all containers, read only
+	// except in the asset container.
+	MountPath string `json:"mountPath"`
+
+	// HostPath is the directory on node caching assets across GameServers, which must be
+	// under the host root allowed by the operator. An emptyDir volume is used if not specified,
+	// which means assets are downloaded by every GameServer.
+	// +optional
+	HostPath string `json:"hostPath,omitempty"`
+}
+
+// GameServerTLS describes the certificate of GameServer, whose SANs are the DNS names of
+// GameServer and its addresses once known. The certificate, key and CA are mounted as
+// tls.crt, tls.key and ca.crt, and rotated before expiry.
+type GameServerTLS struct {
+	// MountPath is the path the certificate is mounted at in all containers.
+	MountPath string `json:"mountPath"`
+
+	// DNSNames are the extra DNS names of the certificate, which must be under the domains
+	// allowed by the operator.
+	// +optional
+	DNSNames []string `json:"dnsNames,omitempty"`
+
+	// Duration is the validity of certificate, defaults to 24h. The certificate
+	// is rotated after two thirds of the duration.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
 }
 
 // SchedulingStrategy is the strategy that a Squad & GameServers will use
@@ -96,6 +278,54 @@ const (
 	Default SchedulingStrategy = "Default"
 )
 
+// Bandwidth is the network bandwidth limit of GameServer pod, in bits per second.
+type Bandwidth struct {
+	// Ingress is the ingress bandwidth limit, e.g. 10M.
+	Ingress *resource.Quantity `json:"ingress,omitempty"`
+
+	// Egress is the egress bandwidth limit, e.g. 10M.
+	Egress *resource.Quantity `json:"egress,omitempty"`
+}
+
+// OperatingSystem is the operating system of GameServer containers.
+type OperatingSystem string
+
+const (
+	// Linux is the operating system of linux containers.
+	Linux OperatingSystem = "linux"
+
+	// Windows is the operating system of windows containers.
+	Windows OperatingSystem = "windows"
+)
+
+// ColocationType is the type of a ColocationRule.
+type ColocationType string
+
+const (
+	// ColocationPrefer prefers placing GameServers on nodes with GameServers of the listed Squads.
+	ColocationPrefer ColocationType = "Prefer"
+
+	// ColocationAvoid prefers placing GameServers away from nodes with GameServers of the listed Squads.
+	ColocationAvoid ColocationType = "Avoid"
+)
+
+// ColocationRule describes whether GameServers prefer or avoid sharing nodes with
+// GameServers of other Squads in the same namespace. It is translated into preferred
+// pod affinity or anti-affinity terms.
+type ColocationRule struct {
+	// Type is Prefer or Avoid.
+	Type ColocationType `json:"type"`
+
+	// Squads are the names of Squads whose GameServers are preferred or avoided.
+	Squads []string `json:"squads"`
+
+	// Weight of the rule in range 1-100. Defaults to 50.
+	Weight int32 `json:"weight,omitempty"`
+
+	// TopologyKey is the domain GameServers are colocated in. Defaults to "kubernetes.io/hostname".
+	TopologyKey string `json:"topologyKey,omitempty"`
+}
+
 // PortPolicy is the port policy for the GameServer
 type PortPolicy string
 
@@ -139,12 +369,22 @@ type PortRange struct {
 // ConstraintType describes the constraint name
 type ConstraintType string
 
-// NotInService is one of the ConstraintTypes, which marks GameServer should close the connection.
-const NotInService ConstraintType = `NotInService`
+const (
+	// NotInService is one of the ConstraintTypes, which marks GameServer should close the connection.
+	NotInService ConstraintType = `NotInService`
+	// NoNewSessions is one of the ConstraintTypes, which marks GameServer should keep serving the
+	// players connected but not accept new sessions. GameServer is not allocated, and is scaled
+	// down before GameServers in service.
+	NoNewSessions ConstraintType = `NoNewSessions`
+	// Cordoned is one of the ConstraintTypes, which keeps GameServer as is, e.g. for investigation.
+	// GameServer is neither allocated, nor scaled down or updated.
+	Cordoned ConstraintType = `Cordoned`
+)
 
 // Constraint describes the constraint info of GameServer.
 type Constraint struct {
-	// Type is the ConstraintType name, e.g. NotInService.
+	// Type is the ConstraintType name, e.g. NotInService. Types not defined by carrier must be
+	// prefixed by a domain, e.g. example.com/Maintenance, and are ignored by carrier.
 	Type ConstraintType `json:"type"`
 	// Effective describes whether the constraint is effective.
 	Effective *bool `json:"effective,omitempty"`
@@ -152,6 +392,9 @@ type Constraint struct {
 	Message string `json:"message,omitempty"`
 	// TimeAdded describes when it is added.
 	TimeAdded *metav1.Time `json:"timeAdded,omitempty"`
+	// ExpiresAt describes when the constraint is no longer effective, the controller removes it once
+	// expired. Effective until removed if not set.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
 }
 
 // GameServerState is the state of a GameServer at the current time.
@@ -167,6 +410,9 @@ const (
 	GameServerExited GameServerState = "Exited"
 	// GameServerFailed means the pod phase of GameServer is Failed
 	GameServerFailed GameServerState = "Failed"
+	// GameServerCompleted means the OneShot GameServer has exited successfully, it is terminal
+	// and not replaced
+	GameServerCompleted GameServerState = "Completed"
 	// GameServerUnknown means the pod phase of GameServer is Unkown
 	GameServerUnknown GameServerState = "Unknown"
 )
@@ -183,11 +429,62 @@ type GameServerStatus struct {
 	NodeName string `json:"nodeName,omitempty"`
 	// LoadBalancerStatus is the load-balancer status
 	LoadBalancerStatus *LoadBalancerStatus `json:"loadBalancerStatus,omitempty"`
+	// LastScaleDownReason explains why the GameServer is selected when scaling down
+	LastScaleDownReason string `json:"lastScaleDownReason,omitempty"`
+	// FinishedTime is the time when the GameServer became Exited or Failed
+	FinishedTime *metav1.Time `json:"finishedTime,omitempty"`
+	// PodFailure is the last failure of the pod, e.g. ImagePullBackOff, OOMKilled or Evicted
+	PodFailure *PodFailure `json:"podFailure,omitempty"`
+	// GameVersion is the game version the pod of GameServer is running
+	GameVersion string `json:"gameVersion,omitempty"`
+	// Startup is the milestones of the first start of GameServer, in place updates are not recorded.
+	Startup *StartupMilestones `json:"startup,omitempty"`
+	// IdleSince is the time the GameServer became ready without being allocated, nil if it is not idle.
+	IdleSince *metav1.Time `json:"idleSince,omitempty"`
+	// SessionStartTime is when the running GameServer was allocated, nil if it has no players.
+	SessionStartTime *metav1.Time `json:"sessionStartTime,omitempty"`
+}
+
+// StartupMilestones is when a GameServer reached each milestone of its start, the
+// milestone before is the creation timestamp.
+type StartupMilestones struct {
+	// ScheduledTime is when the pod of GameServer was scheduled.
+	ScheduledTime *metav1.Time `json:"scheduledTime,omitempty"`
+	// PodRunningTime is when all containers of the pod of GameServer were running.
+	PodRunningTime *metav1.Time `json:"podRunningTime,omitempty"`
+	// ReadyTime is when the GameServer became Running and ready.
+	ReadyTime *metav1.Time `json:"readyTime,omitempty"`
+}
+
+// PodFailure describes a failure of the pod of GameServer.
+type PodFailure struct {
+	// Container is the name of failed container, empty if the pod failed, e.g. evicted.
+	Container string `json:"container,omitempty"`
+	// Reason is a brief reason of the failure, e.g. ImagePullBackOff, OOMKilled or Evicted.
+	Reason string `json:"reason"`
+	// Message is the human readable message of the failure.
+	Message string `json:"message,omitempty"`
+	// ExitCode is the exit code of the terminated container.
+	ExitCode int32 `json:"exitCode,omitempty"`
 }
 
 // GameServerConditionType is a valid value for GameServerCondition.Type
 type GameServerConditionType string
 
+const (
+	// GameServerPodFailed is True if the pod of GameServer is failing, see PodFailure of status for details.
+	GameServerPodFailed GameServerConditionType = "PodFailed"
+	// GameServerDraining is True if the GameServer is out of service and waiting for its deletable
+	// gates to pass, the message describes the players and since when it is out of service.
+	GameServerDraining GameServerConditionType = "Draining"
+	// GameServerAssetReady is True once the assets of GameServer are downloaded by the
+	// asset container, False while they are being downloaded.
+	GameServerAssetReady GameServerConditionType = "AssetReady"
+	// GameServerAddressResolved is True once the public endpoint of GameServer is resolved by
+	// the address resolver and stored in LoadBalancerStatus, False if resolving failed.
+	GameServerAddressResolved GameServerConditionType = "AddressResolved"
+)
+
 // ConditionStatus includes True or False
 type ConditionStatus string
 
@@ -17,7 +17,10 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // +genclient
@@ -50,6 +53,8 @@ type GameServerSetSpec struct {
 	Replicas int32 `json:"replicas"`
 	// Scheduling strategy. Defaults to "MostAllocated".
 	Scheduling SchedulingStrategy `json:"scheduling,omitempty"`
+	// Colocation describes whether GameServers prefer or avoid sharing nodes with GameServers of other Squads.
+	Colocation []ColocationRule `json:"colocation,omitempty"`
 	// Template the GameServer template to apply for this GameServerSet
 	Template GameServerTemplateSpec `json:"template"`
 	// Selector is a label query over pods that should match the replica count.
@@ -60,6 +65,118 @@ type GameServerSetSpec struct {
 	// ExcludeConstraints describes if we should exclude GameServer with constraints
 	// when computing replicas
 	ExcludeConstraints *bool `json:"excludeConstraints,omitempty"`
+	// TTLSecondsAfterFinished limits the lifetime of GameServers which are Exited or Failed.
+	// If set, finished GameServers are not deleted by the GameServerSet immediately, but
+	// garbage collected TTLSecondsAfterFinished seconds after they finished.
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+	// OOMPolicy describes how to react to repeated OOMKills of GameServers,
+	// nothing is done if not set.
+	OOMPolicy *OOMPolicy `json:"oomPolicy,omitempty"`
+	// DisruptionBudget describes the PodDisruptionBudget covering pods of the GameServerSet,
+	// no PodDisruptionBudget is created if not set.
+	DisruptionBudget *DisruptionBudget `json:"disruptionBudget,omitempty"`
+	// NetworkPolicy describes the NetworkPolicy isolating pods of the GameServerSet,
+	// no NetworkPolicy is created if not set.
+	NetworkPolicy *NetworkPolicy `json:"networkPolicy,omitempty"`
+	// LogShipping describes the log shipper sidecar injected into pods of GameServers,
+	// overriding the one of template if set.
+	LogShipping *LogShipping `json:"logShipping,omitempty"`
+	// UpdateStrategy describes how GameServers are updated once the template changes. It is
+	// honored only if the GameServerSet is not controlled by a Squad, which updates its
+	// GameServerSets by the strategy of Squad. Template changes only apply to new GameServers if not set.
+	// GameServers existing when it is enabled are kept if they are of the current template.
+	UpdateStrategy *GameServerSetUpdateStrategy `json:"updateStrategy,omitempty"`
+	// GameServerMetadata is the labels and annotations of GameServers, apart from the template.
+	// Changes are patched into existing GameServers instead of replacing them.
+	GameServerMetadata *GameServerMetadata `json:"gameServerMetadata,omitempty"`
+	// ImagePullSecrets are added to the pods of new GameServers apart from the template.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// GameServerMetadata is the metadata inherited by GameServers of a GameServerSet.
+type GameServerMetadata struct {
+	// Labels of GameServers.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations of GameServers.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// GameServerSetUpdateStrategyType is how GameServers of a GameServerSet are updated.
+type GameServerSetUpdateStrategyType string
+
+const (
+	// OnDeleteGameServerSetStrategyType only applies template changes to GameServers created after.
+	OnDeleteGameServerSetStrategyType GameServerSetUpdateStrategyType = "OnDelete"
+	// RecreateGameServerSetStrategyType replaces all old GameServers at once.
+	RecreateGameServerSetStrategyType GameServerSetUpdateStrategyType = "Recreate"
+	// RollingUpdateGameServerSetStrategyType replaces old GameServers, keeping at most
+	// MaxUnavailable GameServers unavailable.
+	RollingUpdateGameServerSetStrategyType GameServerSetUpdateStrategyType = "RollingUpdate"
+	// InplaceUpdateGameServerSetStrategyType updates the image and resources of at most
+	// Threshold old GameServers in place.
+	InplaceUpdateGameServerSetStrategyType GameServerSetUpdateStrategyType = "InplaceUpdate"
+)
+
+// GameServerSetUpdateStrategy describes how GameServers of a GameServerSet are updated.
+// Old GameServers with deletable gates are marked out of service, and replaced once deletable.
+type GameServerSetUpdateStrategy struct {
+	// Type of update. Can be "OnDelete", "Recreate", "RollingUpdate" or "InplaceUpdate". Default is OnDelete.
+	Type GameServerSetUpdateStrategyType `json:"type,omitempty"`
+	// MaxUnavailable is the max number of GameServers unavailable during the update, Value can
+	// be an absolute number(ex: 5) or a percentage of replicas (ex: 10%). Present only if type
+	// is RollingUpdate. Defaults to 25%.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	// Threshold is the number of GameServers updated in place, Value can be an absolute number
+	// (ex: 5) or a percentage of replicas (ex: 10%). Present only if type is InplaceUpdate.
+	// Defaults to 100%.
+	Threshold *intstr.IntOrString `json:"threshold,omitempty"`
+}
+
+// DisruptionBudget describes the PodDisruptionBudget created for a GameServerSet.
+type DisruptionBudget struct {
+	// MinAvailablePercent is the percentage of replicas that must stay available
+	// during voluntary disruptions, e.g. node draining. Rounded up.
+	MinAvailablePercent int32 `json:"minAvailablePercent"`
+}
+
+// NetworkPolicy describes the NetworkPolicy created for a GameServerSet. Pods of GameServers
+// are denied all traffic, apart from inbound traffic to the ports declared by GameServers and
+// outbound traffic to DNS and to the apiserver, which the SDK server sidecar talks to.
+type NetworkPolicy struct {
+	// From are the peers allowed to reach the ports of GameServers, all sources if empty.
+	From []networkingv1.NetworkPolicyPeer `json:"from,omitempty"`
+	// APIServerCIDRs are the CIDRs of the apiserver, e.g. the endpoints of the kubernetes
+	// Service in the default namespace. Required, outbound traffic to the apiserver is
+	// not allowed to any destination if empty.
+	APIServerCIDRs []string `json:"apiServerCIDRs"`
+	// APIServerPorts are the TCP ports of the apiserver, defaults to 443 and 6443.
+	APIServerPorts []int32 `json:"apiServerPorts,omitempty"`
+	// Egress are the outbound rules allowed apart from DNS and the apiserver,
+	// e.g. to the backends of the game.
+	Egress []networkingv1.NetworkPolicyEgressRule `json:"egress,omitempty"`
+}
+
+// OOMAction is the remediation of repeated OOMKills.
+type OOMAction string
+
+const (
+	// OOMActionBumpMemory increases the memory limit of GameServer container for new GameServers,
+	// the template is marked as faulty once MaxMemoryLimit is reached.
+	OOMActionBumpMemory OOMAction = "BumpMemory"
+	// OOMActionMarkFaulty marks the template as faulty and stops creating GameServers.
+	OOMActionMarkFaulty OOMAction = "MarkFaulty"
+)
+
+// OOMPolicy describes how a GameServerSet reacts to repeated OOMKills of its GameServers.
+type OOMPolicy struct {
+	// Action is the remediation, BumpMemory or MarkFaulty.
+	Action OOMAction `json:"action"`
+	// Threshold is the number of OOMKilled GameServers before remediating. Defaults to 3.
+	Threshold int32 `json:"threshold,omitempty"`
+	// MemoryBumpPercent is the percentage the memory limit is increased by each time. Defaults to 50.
+	MemoryBumpPercent int32 `json:"memoryBumpPercent,omitempty"`
+	// MaxMemoryLimit caps the memory limit bumped.
+	MaxMemoryLimit *resource.Quantity `json:"maxMemoryLimit,omitempty"`
 }
 
 // GameServerSetStatus is the status of a GameServerSet
@@ -68,12 +185,67 @@ type GameServerSetStatus struct {
 	Replicas int32 `json:"replicas"`
 	// ReadyReplicas is the number of Ready GameServer replicas
 	ReadyReplicas int32 `json:"readyReplicas"`
+	// UpdateBlockedReplicas is the number of GameServer replicas excluded from
+	// in place updates and scale down by the skip-update annotation
+	UpdateBlockedReplicas int32 `json:"updateBlockedReplicas,omitempty"`
+	// DrainingReplicas is the number of GameServer replicas out of service and
+	// waiting for their deletable gates to pass
+	DrainingReplicas int32 `json:"drainingReplicas,omitempty"`
 	// ObservedGeneration is the most recent generation observed by the controller.
 	ObservedGeneration int64 `json:"observedGeneration"`
 	// Represents the latest available observations of a GameServerSet's current state.
 	Conditions []GameServerSetCondition `json:"conditions,omitempty"`
 	// Selector is a string format, which is for scale
 	Selector string `json:"selector,omitempty"`
+	// InPlaceUpdateSkipped is the number of candidates skipped by the last in place
+	// update of each reason, nil if none is skipped.
+	InPlaceUpdateSkipped *InPlaceUpdateSkipped `json:"inPlaceUpdateSkipped,omitempty"`
+	// Checkpoint is the progress of the last reconciliation, nil if all its actions are observed.
+	Checkpoint *ReconcileCheckpoint `json:"checkpoint,omitempty"`
+	// IdleReplicas is the number of GameServer replicas idle longer than their max idle seconds,
+	// which are scaled down first while nodes report resource pressure.
+	IdleReplicas int32 `json:"idleReplicas,omitempty"`
+}
+
+// ReconcilePhase is a phase of the reconciliation of a GameServerSet.
+type ReconcilePhase string
+
+// These are the phases of the reconciliation of a GameServerSet.
+const (
+	// ReconcileObserve lists the GameServers and drops the actions of checkpoint already observed.
+	ReconcileObserve ReconcilePhase = "Observe"
+	// ReconcilePlan computes the GameServers to create and delete.
+	ReconcilePlan ReconcilePhase = "Plan"
+	// ReconcileApplyCreates creates GameServers.
+	ReconcileApplyCreates ReconcilePhase = "ApplyCreates"
+	// ReconcileApplyDeletes deletes GameServers or marks them out of service.
+	ReconcileApplyDeletes ReconcilePhase = "ApplyDeletes"
+	// ReconcileFinalizeStatus updates the status.
+	ReconcileFinalizeStatus ReconcilePhase = "FinalizeStatus"
+)
+
+// ReconcileCheckpoint records the actions applied by the reconciliation of a GameServerSet
+// but not observed in the cache yet, so a retry after a mid-phase error does not redo them.
+type ReconcileCheckpoint struct {
+	// Phase is the phase the last reconciliation stopped in.
+	Phase ReconcilePhase `json:"phase"`
+	// LastTransitionTime is the last time an action was applied, the checkpoint
+	// is dropped if the actions are not observed for a long time.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Created is the names of GameServers created but not observed yet.
+	Created []string `json:"created,omitempty"`
+	// Deleted is the names of GameServers deleted but not observed yet.
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+// InPlaceUpdateSkipped is the number of GameServers skipped by in place update of each reason.
+type InPlaceUpdateSkipped struct {
+	// BeingDeleted is the number of GameServers being deleted.
+	BeingDeleted int32 `json:"beingDeleted,omitempty"`
+	// BeforeReady is the number of GameServers selected before ready, but ready when updating.
+	BeforeReady int32 `json:"beforeReady,omitempty"`
+	// GatesNotTrue is the number of GameServers out of service, whose deletable gates are not all True.
+	GatesNotTrue int32 `json:"gatesNotTrue,omitempty"`
 }
 
 type GameServerSetConditionType string
@@ -88,6 +260,18 @@ const (
 	// GameServerSet, this condition should be set by squad controller and would be removed when GameServerSet
 	// finishes scaling.
 	GameServerSetScalingInProgress GameServerSetConditionType = "ScalingInProgress"
+	// GameServerSetTemplateFaulty is added in a GameServerSet when its GameServers are OOMKilled
+	// repeatedly and the OOMPolicy could not remediate, no GameServer is created until the template changes.
+	GameServerSetTemplateFaulty GameServerSetConditionType = "TemplateFaulty"
+	// GameServerSetReady follows kstatus conventions, it is True once all desired GameServers of the
+	// GameServerSet are ready, and False otherwise.
+	GameServerSetReady GameServerSetConditionType = "Ready"
+	// GameServerSetReconciling follows kstatus conventions, it is True while the GameServerSet is
+	// scaling, and removed once finished or stalled.
+	GameServerSetReconciling GameServerSetConditionType = "Reconciling"
+	// GameServerSetStalled follows kstatus conventions, it is True if the GameServerSet could not make
+	// progress without intervention, e.g. the template is faulty or GameServers fail to be created.
+	GameServerSetStalled GameServerSetConditionType = "Stalled"
 )
 
 // GameServerSetCondition describes the state of a GameServerSet at a certain point.
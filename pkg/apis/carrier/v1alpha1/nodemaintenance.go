@@ -0,0 +1,118 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeMaintenancePolicy is how GameServers on the nodes under maintenance are handled.
+type NodeMaintenancePolicy string
+
+const (
+	// MaintenanceDrain marks GameServers NotInService, they are deleted as their drain policy
+	// allows, e.g. deletable gates and max drain seconds.
+	MaintenanceDrain NodeMaintenancePolicy = "Drain"
+	// MaintenanceNoNewSessions marks GameServers NoNewSessions, the sessions in progress are
+	// finished on the nodes.
+	MaintenanceNoNewSessions NodeMaintenancePolicy = "NoNewSessions"
+)
+
+// NodeMaintenancePhase is the phase of a NodeMaintenance.
+type NodeMaintenancePhase string
+
+const (
+	// MaintenancePending means the maintenance window is not approaching yet.
+	MaintenancePending NodeMaintenancePhase = "Pending"
+	// MaintenancePreparing means GameServers on the nodes are being marked ahead of the window.
+	MaintenancePreparing NodeMaintenancePhase = "Preparing"
+	// MaintenanceInProgress means the maintenance window has started.
+	MaintenanceInProgress NodeMaintenancePhase = "InProgress"
+	// MaintenanceCompleted means the maintenance window has ended.
+	MaintenanceCompleted NodeMaintenancePhase = "Completed"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NodeMaintenance is the data structure for a cluster scoped NodeMaintenance resource,
+// announcing a maintenance window of nodes so the GameServers on them are taken out of
+// allocation ahead of it.
+type NodeMaintenance struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodeMaintenanceSpec   `json:"spec"`
+	Status NodeMaintenanceStatus `json:"status"`
+}
+
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NodeMaintenanceList is a list of NodeMaintenance resources
+type NodeMaintenanceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []NodeMaintenance `json:"items"`
+}
+
+// NodeMaintenanceSpec is the spec for a NodeMaintenance.
+type NodeMaintenanceSpec struct {
+	// NodeSelector selects the nodes under maintenance, it must not be empty. The nodes are
+	// cordoned from the lead time till the end of the window.
+	NodeSelector map[string]string `json:"nodeSelector"`
+	// Window is the maintenance window.
+	Window MaintenanceWindow `json:"window"`
+	// LeadSeconds is how long ahead of the window GameServers are marked, defaults to 3600.
+	LeadSeconds *int32 `json:"leadSeconds,omitempty"`
+	// Policy is how GameServers on the nodes are handled, defaults to Drain.
+	Policy NodeMaintenancePolicy `json:"policy,omitempty"`
+}
+
+// MaintenanceWindow is the time range of a maintenance.
+type MaintenanceWindow struct {
+	// Start is when the maintenance starts.
+	Start metav1.Time `json:"start"`
+	// DurationSeconds is how long the maintenance lasts. GameServers marked return to service
+	// once the window ends.
+	DurationSeconds int32 `json:"durationSeconds"`
+}
+
+// NodeMaintenanceStatus is the status of a NodeMaintenance.
+type NodeMaintenanceStatus struct {
+	// Phase is the phase of the maintenance.
+	Phase NodeMaintenancePhase `json:"phase,omitempty"`
+	// ReadyNodes is the number of nodes ready for maintenance.
+	ReadyNodes int32 `json:"readyNodes"`
+	// Nodes are the readiness for maintenance of each node selected.
+	Nodes []NodeMaintenanceNodeStatus `json:"nodes,omitempty"`
+	// LastUpdateTime is the last time the status changed.
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// NodeMaintenanceNodeStatus is the readiness for maintenance of a node.
+type NodeMaintenanceNodeStatus struct {
+	// NodeName is the name of node.
+	NodeName string `json:"nodeName"`
+	// GameServers is the number of GameServers still on the node.
+	GameServers int32 `json:"gameServers"`
+	// Players is the number of players still on the GameServers of the node.
+	Players int64 `json:"players"`
+	// Ready is true if the node is ready for maintenance: with the Drain policy no GameServers
+	// are left, and with the NoNewSessions policy no players are left.
+	Ready bool `json:"ready"`
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PriorityPolicy is the data structure for a cluster scoped PriorityPolicy resource, letting
+// high priority Squads preempt low priority Squads when the cluster is out of capacity.
+type PriorityPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PriorityPolicySpec   `json:"spec"`
+	Status PriorityPolicyStatus `json:"status"`
+}
+
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PriorityPolicyList is a list of PriorityPolicy resources
+type PriorityPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []PriorityPolicy `json:"items"`
+}
+
+// PriorityPolicySpec is the spec for a PriorityPolicy.
+type PriorityPolicySpec struct {
+	// HighPriority selects the Squads of all namespaces whose GameServers pending for capacity
+	// trigger preemption.
+	HighPriority *metav1.LabelSelector `json:"highPriority"`
+	// LowPriority selects the Squads of all namespaces scaled down to free capacity. Squads
+	// selected by both are regarded as high priority. Squads annotated as autoscaled are not
+	// scaled down, as their autoscalers would scale them back up.
+	LowPriority *metav1.LabelSelector `json:"lowPriority"`
+	// PendingSeconds is how long GameServers of high priority Squads are unschedulable before
+	// low priority Squads are preempted, defaults to 60.
+	PendingSeconds *int32 `json:"pendingSeconds,omitempty"`
+	// CooldownSeconds is the min interval between preemptions, so the capacity freed is taken
+	// before more is preempted, defaults to 60.
+	CooldownSeconds *int32 `json:"cooldownSeconds,omitempty"`
+	// MinReplicas is the replicas low priority Squads are never scaled down below.
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+}
+
+// PriorityPolicyStatus is the status of a PriorityPolicy.
+type PriorityPolicyStatus struct {
+	// PendingGameServers is the number of GameServers of high priority Squads pending for capacity
+	// longer than pendingSeconds.
+	PendingGameServers int32 `json:"pendingGameServers"`
+	// PreemptedReplicas is the total replicas low priority Squads are scaled down by the policy.
+	PreemptedReplicas int32 `json:"preemptedReplicas"`
+	// LastPreemptionTime is the last time low priority Squads are preempted.
+	LastPreemptionTime *metav1.Time `json:"lastPreemptionTime,omitempty"`
+}
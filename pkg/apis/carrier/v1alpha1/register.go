@@ -45,10 +45,18 @@ var (
 // Adds the list of known types to Scheme.
 func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
+		&FleetDefragReport{},
+		&FleetDefragReportList{},
+		&FleetProfile{},
+		&FleetProfileList{},
 		&GameServer{},
 		&GameServerList{},
 		&GameServerSet{},
 		&GameServerSetList{},
+		&NodeMaintenance{},
+		&NodeMaintenanceList{},
+		&PriorityPolicy{},
+		&PriorityPolicyList{},
 		&Squad{},
 		&SquadList{},
 	)
@@ -15,7 +15,9 @@
 package v1alpha1
 
 import (
+	admregv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -52,6 +54,9 @@ type SquadSpec struct {
 	Strategy SquadStrategy `json:"strategy,omitempty"`
 	// Scheduling strategy. Defaults to "MostAllocated".
 	Scheduling SchedulingStrategy `json:"scheduling,omitempty"`
+	// Colocation describes whether GameServers prefer or avoid sharing nodes with GameServers
+	// of other Squads, e.g. keeping lobby servers off nodes hosting match servers.
+	Colocation []ColocationRule `json:"colocation,omitempty"`
 	// Template the GameServer template to apply for this Squad
 	Template GameServerTemplateSpec `json:"template"`
 	// The number of old GameServerSets to retain to allow rollback.
@@ -70,6 +75,37 @@ type SquadSpec struct {
 	// ExcludeConstraints describes if we should exclude GameServer with constraints
 	// when computing replicas, default false.
 	ExcludeConstraints *bool `json:"excludeConstraints,omitempty"`
+	// ProfileRef references the cluster scoped FleetProfile whose defaults are merged
+	// into the template on admission.
+	ProfileRef *corev1.LocalObjectReference `json:"profileRef,omitempty"`
+	// ImagePullSecrets are the Secrets pulling the images of GameServers, added to the pods of
+	// GameServers apart from the template, so they could be changed without rolling out.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// Preflight runs a GameServer with the new template before rolling it out, the rollout
+	// starts only if the GameServer becomes ready. Rollouts are not checked if not set.
+	Preflight *SquadPreflight `json:"preflight,omitempty"`
+	// DependsOn are the Squads in the same namespace which must roll out before the rollout of
+	// this Squad starts, e.g. lobby servers are updated after match servers. A Squad has rolled
+	// out once its latest spec is observed and all its replicas are updated, ready or not.
+	// Scaling is not delayed.
+	DependsOn []corev1.LocalObjectReference `json:"dependsOn,omitempty"`
+	// MaxResources is the budget of resources, e.g. cpu and memory, requested by all GameServers
+	// of the Squad. Replicas are capped so the requests of the template times the replicas, plus
+	// the surge of rolling updates and the GameServers of old GameServerSets, stay within the
+	// budget, however many are desired by autoscalers. It is set on the Squad rather than the
+	// SquadAutoscaler, which is not part of carrier, so it caps any autoscaler and manual
+	// scaling alike. Not capped if not set.
+	MaxResources corev1.ResourceList `json:"maxResources,omitempty"`
+}
+
+// SquadPreflight describes the smoke GameServer run with a new template before rolling it out.
+type SquadPreflight struct {
+	// TimeoutSeconds is how long the preflight GameServer could take to become ready before
+	// the preflight fails. Defaults to 300.
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+	// SelfTestCondition is the condition the game server sets True through the SDK once its
+	// self-test passes, or False if it fails. Only readiness is checked if not set.
+	SelfTestCondition string `json:"selfTestCondition,omitempty"`
 }
 
 // RollbackConfig is the rollback config for a Squad
@@ -124,6 +160,39 @@ type CanaryUpdateSquad struct {
 	// Value can be an absolute number(ex: 5) or a percentage of total GameServers at
 	// the start of the update (ex: 10%)
 	Threshold *intstr.IntOrString `json:"threshold"`
+	// TrafficHook is called to shift new sessions to the new GameServerSet
+	// for games routed through a gateway.
+	TrafficHook *TrafficHook `json:"trafficHook,omitempty"`
+	// MetricGate is evaluated periodically during canary update, the rollout is
+	// aborted and the new GameServerSet is scaled to zero if it is breached.
+	// +optional
+	MetricGate *MetricGate `json:"metricGate,omitempty"`
+}
+
+// MetricGate is a PromQL query checked against a threshold, e.g. the error budget burn rate
+// of the new version.
+type MetricGate struct {
+	// Address is the url of prometheus server, e.g. http://prometheus.monitoring:9090
+	Address string `json:"address"`
+	// Query is the PromQL query, the first sample of the result is checked.
+	Query string `json:"query"`
+	// Threshold is the max value of the query allowed.
+	Threshold resource.Quantity `json:"threshold"`
+	// IntervalSeconds is the interval between two evaluations, default is 30
+	// +optional
+	IntervalSeconds *int32 `json:"intervalSeconds,omitempty"`
+	// TimeoutSeconds means http request timeout, default is 10
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// TrafficHook is a webhook called during canary update, the percentage of new sessions
+// routed to each GameServerSet is posted to it, e.g. to update the routes of a gateway.
+type TrafficHook struct {
+	// ClientConfig is the config for the webhook
+	ClientConfig admregv1.WebhookClientConfig `json:"clientConfig"`
+	// TimeoutSeconds means http request timeout, default is 10
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
 }
 
 // InplaceUpdateSquad to control the desired behavior of inplace update.
@@ -173,6 +242,19 @@ type SquadStatus struct {
 	Conditions []SquadCondition `json:"conditions,omitempty"`
 	// Selector is a string format, which is for scale
 	Selector string `json:"selector,omitempty"`
+	// GameVersions are the replicas of each game version run by the Squad, more than one
+	// version runs during updates, or intentionally with a canary update held at its threshold.
+	GameVersions []GameVersionStatus `json:"gameVersions,omitempty"`
+}
+
+// GameVersionStatus is the replicas of a game version.
+type GameVersionStatus struct {
+	// GameVersion is the game version of GameServers.
+	GameVersion string `json:"gameVersion"`
+	// Replicas is the number of GameServers of the game version.
+	Replicas int32 `json:"replicas"`
+	// ReadyReplicas is the number of Ready GameServers of the game version.
+	ReadyReplicas int32 `json:"readyReplicas"`
 }
 
 type SquadConditionType string
@@ -187,6 +269,29 @@ const (
 	// SquadReplicaFailure is added in a Squad when one of its GameServers fails to be created
 	// or deleted.
 	SquadReplicaFailure SquadConditionType = "ReplicaFailure"
+	// SquadReady follows kstatus conventions, it is True once all desired GameServers of the Squad
+	// are updated and ready, and False otherwise.
+	SquadReady SquadConditionType = "Ready"
+	// SquadReconciling follows kstatus conventions, it is True while the Squad is rolling out or
+	// scaling, and removed once finished or stalled.
+	SquadReconciling SquadConditionType = "Reconciling"
+	// SquadStalled follows kstatus conventions, it is True if the Squad could not make progress
+	// without intervention, e.g. GameServers fail to be created or the canary update is aborted.
+	SquadStalled SquadConditionType = "Stalled"
+	// SquadImagePullSecretMissing is True if some of the imagePullSecrets of the Squad do not
+	// exist, so GameServers would stall in ImagePullBackOff.
+	SquadImagePullSecretMissing SquadConditionType = "ImagePullSecretMissing"
+	// SquadPreflight is Unknown while the preflight GameServer of a new template is running,
+	// True once it passes and False if it fails, which aborts the rollout.
+	SquadPreflight SquadConditionType = "Preflight"
+	// SquadWaitingForDependencies is True while the rollout of the Squad waits for the Squads
+	// it depends on to roll out.
+	SquadWaitingForDependencies SquadConditionType = "WaitingForDependencies"
+	// SquadLowHeadroom is True if the ready GameServers not allocated yet are projected to be
+	// exhausted within the horizon at the recent allocation rate, and False otherwise.
+	SquadLowHeadroom SquadConditionType = "LowHeadroom"
+	// SquadBudgetCapped is True while the replicas of the Squad are capped by its maxResources.
+	SquadBudgetCapped SquadConditionType = "BudgetCapped"
 )
 
 // SquadCondition describes the state of a Squad at a certain point.
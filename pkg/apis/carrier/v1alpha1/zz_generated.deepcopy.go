@@ -19,11 +19,57 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	resource "k8s.io/apimachinery/pkg/api/resource"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AssetCache) DeepCopyInto(out *AssetCache) {
+	*out = *in
+	in.Container.DeepCopyInto(&out.Container)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AssetCache.
+func (in *AssetCache) DeepCopy() *AssetCache {
+	if in == nil {
+		return nil
+	}
+	out := new(AssetCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Bandwidth) DeepCopyInto(out *Bandwidth) {
+	*out = *in
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Egress != nil {
+		in, out := &in.Egress, &out.Egress
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Bandwidth.
+func (in *Bandwidth) DeepCopy() *Bandwidth {
+	if in == nil {
+		return nil
+	}
+	out := new(Bandwidth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CanaryUpdateSquad) DeepCopyInto(out *CanaryUpdateSquad) {
 	*out = *in
@@ -32,6 +78,16 @@ func (in *CanaryUpdateSquad) DeepCopyInto(out *CanaryUpdateSquad) {
 		*out = new(intstr.IntOrString)
 		**out = **in
 	}
+	if in.TrafficHook != nil {
+		in, out := &in.TrafficHook, &out.TrafficHook
+		*out = new(TrafficHook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MetricGate != nil {
+		in, out := &in.MetricGate, &out.MetricGate
+		*out = new(MetricGate)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -45,6 +101,27 @@ func (in *CanaryUpdateSquad) DeepCopy() *CanaryUpdateSquad {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ColocationRule) DeepCopyInto(out *ColocationRule) {
+	*out = *in
+	if in.Squads != nil {
+		in, out := &in.Squads, &out.Squads
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ColocationRule.
+func (in *ColocationRule) DeepCopy() *ColocationRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ColocationRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Configurations) DeepCopyInto(out *Configurations) {
 	*out = *in
@@ -94,6 +171,10 @@ func (in *Constraint) DeepCopyInto(out *Constraint) {
 		in, out := &in.TimeAdded, &out.TimeAdded
 		*out = (*in).DeepCopy()
 	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -108,7 +189,39 @@ func (in *Constraint) DeepCopy() *Constraint {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GameServer) DeepCopyInto(out *GameServer) {
+func (in *DefragRecommendation) DeepCopyInto(out *DefragRecommendation) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DefragRecommendation.
+func (in *DefragRecommendation) DeepCopy() *DefragRecommendation {
+	if in == nil {
+		return nil
+	}
+	out := new(DefragRecommendation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisruptionBudget) DeepCopyInto(out *DisruptionBudget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisruptionBudget.
+func (in *DisruptionBudget) DeepCopy() *DisruptionBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(DisruptionBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FleetDefragReport) DeepCopyInto(out *FleetDefragReport) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -117,18 +230,18 @@ func (in *GameServer) DeepCopyInto(out *GameServer) {
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServer.
-func (in *GameServer) DeepCopy() *GameServer {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetDefragReport.
+func (in *FleetDefragReport) DeepCopy() *FleetDefragReport {
 	if in == nil {
 		return nil
 	}
-	out := new(GameServer)
+	out := new(FleetDefragReport)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *GameServer) DeepCopyObject() runtime.Object {
+func (in *FleetDefragReport) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -136,31 +249,126 @@ func (in *GameServer) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GameServerCondition) DeepCopyInto(out *GameServerCondition) {
+func (in *FleetDefragReportList) DeepCopyInto(out *FleetDefragReportList) {
 	*out = *in
-	in.LastProbeTime.DeepCopyInto(&out.LastProbeTime)
-	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FleetDefragReport, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerCondition.
-func (in *GameServerCondition) DeepCopy() *GameServerCondition {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetDefragReportList.
+func (in *FleetDefragReportList) DeepCopy() *FleetDefragReportList {
 	if in == nil {
 		return nil
 	}
-	out := new(GameServerCondition)
+	out := new(FleetDefragReportList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FleetDefragReportList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FleetDefragReportSpec) DeepCopyInto(out *FleetDefragReportSpec) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DrainTimeoutSeconds != nil {
+		in, out := &in.DrainTimeoutSeconds, &out.DrainTimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetDefragReportSpec.
+func (in *FleetDefragReportSpec) DeepCopy() *FleetDefragReportSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FleetDefragReportSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GameServerList) DeepCopyInto(out *GameServerList) {
+func (in *FleetDefragReportStatus) DeepCopyInto(out *FleetDefragReportStatus) {
+	*out = *in
+	if in.Recommendations != nil {
+		in, out := &in.Recommendations, &out.Recommendations
+		*out = make([]DefragRecommendation, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastAnalysisTime != nil {
+		in, out := &in.LastAnalysisTime, &out.LastAnalysisTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetDefragReportStatus.
+func (in *FleetDefragReportStatus) DeepCopy() *FleetDefragReportStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FleetDefragReportStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FleetProfile) DeepCopyInto(out *FleetProfile) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetProfile.
+func (in *FleetProfile) DeepCopy() *FleetProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(FleetProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FleetProfile) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FleetProfileList) DeepCopyInto(out *FleetProfileList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]GameServer, len(*in))
+		*out = make([]FleetProfile, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -168,18 +376,18 @@ func (in *GameServerList) DeepCopyInto(out *GameServerList) {
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerList.
-func (in *GameServerList) DeepCopy() *GameServerList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetProfileList.
+func (in *FleetProfileList) DeepCopy() *FleetProfileList {
 	if in == nil {
 		return nil
 	}
-	out := new(GameServerList)
+	out := new(FleetProfileList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *GameServerList) DeepCopyObject() runtime.Object {
+func (in *FleetProfileList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -187,43 +395,71 @@ func (in *GameServerList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GameServerPort) DeepCopyInto(out *GameServerPort) {
+func (in *FleetProfileSpec) DeepCopyInto(out *FleetProfileSpec) {
 	*out = *in
-	if in.ContainerPort != nil {
-		in, out := &in.ContainerPort, &out.ContainerPort
-		*out = new(int32)
-		**out = **in
+	if in.Sidecars != nil {
+		in, out := &in.Sidecars, &out.Sidecars
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
-	if in.ContainerPortRange != nil {
-		in, out := &in.ContainerPortRange, &out.ContainerPortRange
-		*out = new(PortRange)
-		**out = **in
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]corev1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
-	if in.HostPort != nil {
-		in, out := &in.HostPort, &out.HostPort
-		*out = new(int32)
-		**out = **in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
-	if in.HostPortRange != nil {
-		in, out := &in.HostPortRange, &out.HostPortRange
-		*out = new(PortRange)
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadinessGates != nil {
+		in, out := &in.ReadinessGates, &out.ReadinessGates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeletableGates != nil {
+		in, out := &in.DeletableGates, &out.DeletableGates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxDrainSeconds != nil {
+		in, out := &in.MaxDrainSeconds, &out.MaxDrainSeconds
+		*out = new(int64)
 		**out = **in
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerPort.
-func (in *GameServerPort) DeepCopy() *GameServerPort {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetProfileSpec.
+func (in *FleetProfileSpec) DeepCopy() *FleetProfileSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(GameServerPort)
+	out := new(FleetProfileSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GameServerSet) DeepCopyInto(out *GameServerSet) {
+func (in *GameServer) DeepCopyInto(out *GameServer) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -232,18 +468,18 @@ func (in *GameServerSet) DeepCopyInto(out *GameServerSet) {
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSet.
-func (in *GameServerSet) DeepCopy() *GameServerSet {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServer.
+func (in *GameServer) DeepCopy() *GameServer {
 	if in == nil {
 		return nil
 	}
-	out := new(GameServerSet)
+	out := new(GameServer)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *GameServerSet) DeepCopyObject() runtime.Object {
+func (in *GameServer) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -251,30 +487,31 @@ func (in *GameServerSet) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GameServerSetCondition) DeepCopyInto(out *GameServerSetCondition) {
+func (in *GameServerCondition) DeepCopyInto(out *GameServerCondition) {
 	*out = *in
+	in.LastProbeTime.DeepCopyInto(&out.LastProbeTime)
 	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSetCondition.
-func (in *GameServerSetCondition) DeepCopy() *GameServerSetCondition {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerCondition.
+func (in *GameServerCondition) DeepCopy() *GameServerCondition {
 	if in == nil {
 		return nil
 	}
-	out := new(GameServerSetCondition)
+	out := new(GameServerCondition)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GameServerSetList) DeepCopyInto(out *GameServerSetList) {
+func (in *GameServerList) DeepCopyInto(out *GameServerList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]GameServerSet, len(*in))
+		*out = make([]GameServer, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -282,18 +519,774 @@ func (in *GameServerSetList) DeepCopyInto(out *GameServerSetList) {
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSetList.
-func (in *GameServerSetList) DeepCopy() *GameServerSetList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerList.
+func (in *GameServerList) DeepCopy() *GameServerList {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GameServerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerMetadata) DeepCopyInto(out *GameServerMetadata) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerMetadata.
+func (in *GameServerMetadata) DeepCopy() *GameServerMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerPort) DeepCopyInto(out *GameServerPort) {
+	*out = *in
+	if in.ContainerPort != nil {
+		in, out := &in.ContainerPort, &out.ContainerPort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ContainerPortRange != nil {
+		in, out := &in.ContainerPortRange, &out.ContainerPortRange
+		*out = new(PortRange)
+		**out = **in
+	}
+	if in.HostPort != nil {
+		in, out := &in.HostPort, &out.HostPort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.HostPortRange != nil {
+		in, out := &in.HostPortRange, &out.HostPortRange
+		*out = new(PortRange)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerPort.
+func (in *GameServerPort) DeepCopy() *GameServerPort {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerPort)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerSet) DeepCopyInto(out *GameServerSet) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSet.
+func (in *GameServerSet) DeepCopy() *GameServerSet {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GameServerSet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerSetCondition) DeepCopyInto(out *GameServerSetCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSetCondition.
+func (in *GameServerSetCondition) DeepCopy() *GameServerSetCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerSetCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerSetList) DeepCopyInto(out *GameServerSetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GameServerSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSetList.
+func (in *GameServerSetList) DeepCopy() *GameServerSetList {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerSetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GameServerSetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerSetSpec) DeepCopyInto(out *GameServerSetSpec) {
+	*out = *in
+	if in.Colocation != nil {
+		in, out := &in.Colocation, &out.Colocation
+		*out = make([]ColocationRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Template.DeepCopyInto(&out.Template)
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExcludeConstraints != nil {
+		in, out := &in.ExcludeConstraints, &out.ExcludeConstraints
+		*out = new(bool)
+		**out = **in
+	}
+	if in.TTLSecondsAfterFinished != nil {
+		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
+		*out = new(int32)
+		**out = **in
+	}
+	if in.OOMPolicy != nil {
+		in, out := &in.OOMPolicy, &out.OOMPolicy
+		*out = new(OOMPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DisruptionBudget != nil {
+		in, out := &in.DisruptionBudget, &out.DisruptionBudget
+		*out = new(DisruptionBudget)
+		**out = **in
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(NetworkPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LogShipping != nil {
+		in, out := &in.LogShipping, &out.LogShipping
+		*out = new(LogShipping)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpdateStrategy != nil {
+		in, out := &in.UpdateStrategy, &out.UpdateStrategy
+		*out = new(GameServerSetUpdateStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GameServerMetadata != nil {
+		in, out := &in.GameServerMetadata, &out.GameServerMetadata
+		*out = new(GameServerMetadata)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSetSpec.
+func (in *GameServerSetSpec) DeepCopy() *GameServerSetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerSetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerSetStatus) DeepCopyInto(out *GameServerSetStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]GameServerSetCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InPlaceUpdateSkipped != nil {
+		in, out := &in.InPlaceUpdateSkipped, &out.InPlaceUpdateSkipped
+		*out = new(InPlaceUpdateSkipped)
+		**out = **in
+	}
+	if in.Checkpoint != nil {
+		in, out := &in.Checkpoint, &out.Checkpoint
+		*out = new(ReconcileCheckpoint)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSetStatus.
+func (in *GameServerSetStatus) DeepCopy() *GameServerSetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerSetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerSetUpdateStrategy) DeepCopyInto(out *GameServerSetUpdateStrategy) {
+	*out = *in
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.Threshold != nil {
+		in, out := &in.Threshold, &out.Threshold
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSetUpdateStrategy.
+func (in *GameServerSetUpdateStrategy) DeepCopy() *GameServerSetUpdateStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerSetUpdateStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerSpec) DeepCopyInto(out *GameServerSpec) {
+	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]GameServerPort, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Colocation != nil {
+		in, out := &in.Colocation, &out.Colocation
+		*out = make([]ColocationRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Bandwidth != nil {
+		in, out := &in.Bandwidth, &out.Bandwidth
+		*out = new(Bandwidth)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Template.DeepCopyInto(&out.Template)
+	if in.Constraints != nil {
+		in, out := &in.Constraints, &out.Constraints
+		*out = make([]Constraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ReadinessGates != nil {
+		in, out := &in.ReadinessGates, &out.ReadinessGates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeletableGates != nil {
+		in, out := &in.DeletableGates, &out.DeletableGates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxDrainSeconds != nil {
+		in, out := &in.MaxDrainSeconds, &out.MaxDrainSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.AssetCache != nil {
+		in, out := &in.AssetCache, &out.AssetCache
+		*out = new(AssetCache)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(GameServerTLS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LogShipping != nil {
+		in, out := &in.LogShipping, &out.LogShipping
+		*out = new(LogShipping)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PostMortem != nil {
+		in, out := &in.PostMortem, &out.PostMortem
+		*out = new(PostMortem)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxIdleSeconds != nil {
+		in, out := &in.MaxIdleSeconds, &out.MaxIdleSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.MaxSessionSeconds != nil {
+		in, out := &in.MaxSessionSeconds, &out.MaxSessionSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSpec.
+func (in *GameServerSpec) DeepCopy() *GameServerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerStatus) DeepCopyInto(out *GameServerStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]GameServerCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LoadBalancerStatus != nil {
+		in, out := &in.LoadBalancerStatus, &out.LoadBalancerStatus
+		*out = new(LoadBalancerStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FinishedTime != nil {
+		in, out := &in.FinishedTime, &out.FinishedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PodFailure != nil {
+		in, out := &in.PodFailure, &out.PodFailure
+		*out = new(PodFailure)
+		**out = **in
+	}
+	if in.Startup != nil {
+		in, out := &in.Startup, &out.Startup
+		*out = new(StartupMilestones)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IdleSince != nil {
+		in, out := &in.IdleSince, &out.IdleSince
+		*out = (*in).DeepCopy()
+	}
+	if in.SessionStartTime != nil {
+		in, out := &in.SessionStartTime, &out.SessionStartTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerStatus.
+func (in *GameServerStatus) DeepCopy() *GameServerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerTLS) DeepCopyInto(out *GameServerTLS) {
+	*out = *in
+	if in.DNSNames != nil {
+		in, out := &in.DNSNames, &out.DNSNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerTLS.
+func (in *GameServerTLS) DeepCopy() *GameServerTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerTemplateSpec) DeepCopyInto(out *GameServerTemplateSpec) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerTemplateSpec.
+func (in *GameServerTemplateSpec) DeepCopy() *GameServerTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameVersionStatus) DeepCopyInto(out *GameVersionStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameVersionStatus.
+func (in *GameVersionStatus) DeepCopy() *GameVersionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GameVersionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InPlaceUpdateSkipped) DeepCopyInto(out *InPlaceUpdateSkipped) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InPlaceUpdateSkipped.
+func (in *InPlaceUpdateSkipped) DeepCopy() *InPlaceUpdateSkipped {
+	if in == nil {
+		return nil
+	}
+	out := new(InPlaceUpdateSkipped)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InplaceUpdateSquad) DeepCopyInto(out *InplaceUpdateSquad) {
+	*out = *in
+	if in.Threshold != nil {
+		in, out := &in.Threshold, &out.Threshold
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InplaceUpdateSquad.
+func (in *InplaceUpdateSquad) DeepCopy() *InplaceUpdateSquad {
+	if in == nil {
+		return nil
+	}
+	out := new(InplaceUpdateSquad)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerIngress) DeepCopyInto(out *LoadBalancerIngress) {
+	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]LoadBalancerPort, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerIngress.
+func (in *LoadBalancerIngress) DeepCopy() *LoadBalancerIngress {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerIngress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerPort) DeepCopyInto(out *LoadBalancerPort) {
+	*out = *in
+	if in.ContainerPort != nil {
+		in, out := &in.ContainerPort, &out.ContainerPort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ExternalPort != nil {
+		in, out := &in.ExternalPort, &out.ExternalPort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ContainerPortRange != nil {
+		in, out := &in.ContainerPortRange, &out.ContainerPortRange
+		*out = new(PortRange)
+		**out = **in
+	}
+	if in.ExternalPortRange != nil {
+		in, out := &in.ExternalPortRange, &out.ExternalPortRange
+		*out = new(PortRange)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerPort.
+func (in *LoadBalancerPort) DeepCopy() *LoadBalancerPort {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerPort)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerStatus) DeepCopyInto(out *LoadBalancerStatus) {
+	*out = *in
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = make([]LoadBalancerIngress, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerStatus.
+func (in *LoadBalancerStatus) DeepCopy() *LoadBalancerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LogShipping) DeepCopyInto(out *LogShipping) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LogShipping.
+func (in *LogShipping) DeepCopy() *LogShipping {
+	if in == nil {
+		return nil
+	}
+	out := new(LogShipping)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricGate) DeepCopyInto(out *MetricGate) {
+	*out = *in
+	out.Threshold = in.Threshold.DeepCopy()
+	if in.IntervalSeconds != nil {
+		in, out := &in.IntervalSeconds, &out.IntervalSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricGate.
+func (in *MetricGate) DeepCopy() *MetricGate {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricGate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicy) DeepCopyInto(out *NetworkPolicy) {
+	*out = *in
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]networkingv1.NetworkPolicyPeer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.APIServerCIDRs != nil {
+		in, out := &in.APIServerCIDRs, &out.APIServerCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.APIServerPorts != nil {
+		in, out := &in.APIServerPorts, &out.APIServerPorts
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	if in.Egress != nil {
+		in, out := &in.Egress, &out.Egress
+		*out = make([]networkingv1.NetworkPolicyEgressRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicy.
+func (in *NetworkPolicy) DeepCopy() *NetworkPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeMaintenance) DeepCopyInto(out *NodeMaintenance) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeMaintenance.
+func (in *NodeMaintenance) DeepCopy() *NodeMaintenance {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeMaintenance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NodeMaintenance) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeMaintenanceList) DeepCopyInto(out *NodeMaintenanceList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NodeMaintenance, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeMaintenanceList.
+func (in *NodeMaintenanceList) DeepCopy() *NodeMaintenanceList {
 	if in == nil {
 		return nil
 	}
-	out := new(GameServerSetList)
+	out := new(NodeMaintenanceList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *GameServerSetList) DeepCopyObject() runtime.Object {
+func (in *NodeMaintenanceList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -301,169 +1294,186 @@ func (in *GameServerSetList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GameServerSetSpec) DeepCopyInto(out *GameServerSetSpec) {
+func (in *NodeMaintenanceNodeStatus) DeepCopyInto(out *NodeMaintenanceNodeStatus) {
 	*out = *in
-	in.Template.DeepCopyInto(&out.Template)
-	if in.Selector != nil {
-		in, out := &in.Selector, &out.Selector
-		*out = new(v1.LabelSelector)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.ExcludeConstraints != nil {
-		in, out := &in.ExcludeConstraints, &out.ExcludeConstraints
-		*out = new(bool)
-		**out = **in
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSetSpec.
-func (in *GameServerSetSpec) DeepCopy() *GameServerSetSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeMaintenanceNodeStatus.
+func (in *NodeMaintenanceNodeStatus) DeepCopy() *NodeMaintenanceNodeStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(GameServerSetSpec)
+	out := new(NodeMaintenanceNodeStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GameServerSetStatus) DeepCopyInto(out *GameServerSetStatus) {
+func (in *NodeMaintenanceSpec) DeepCopyInto(out *NodeMaintenanceSpec) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]GameServerSetCondition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
 		}
 	}
+	in.Window.DeepCopyInto(&out.Window)
+	if in.LeadSeconds != nil {
+		in, out := &in.LeadSeconds, &out.LeadSeconds
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSetStatus.
-func (in *GameServerSetStatus) DeepCopy() *GameServerSetStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeMaintenanceSpec.
+func (in *NodeMaintenanceSpec) DeepCopy() *NodeMaintenanceSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(GameServerSetStatus)
+	out := new(NodeMaintenanceSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GameServerSpec) DeepCopyInto(out *GameServerSpec) {
+func (in *NodeMaintenanceStatus) DeepCopyInto(out *NodeMaintenanceStatus) {
 	*out = *in
-	if in.Ports != nil {
-		in, out := &in.Ports, &out.Ports
-		*out = make([]GameServerPort, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	in.Template.DeepCopyInto(&out.Template)
-	if in.Constraints != nil {
-		in, out := &in.Constraints, &out.Constraints
-		*out = make([]Constraint, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.ReadinessGates != nil {
-		in, out := &in.ReadinessGates, &out.ReadinessGates
-		*out = make([]string, len(*in))
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeMaintenanceNodeStatus, len(*in))
 		copy(*out, *in)
 	}
-	if in.DeletableGates != nil {
-		in, out := &in.DeletableGates, &out.DeletableGates
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSpec.
-func (in *GameServerSpec) DeepCopy() *GameServerSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeMaintenanceStatus.
+func (in *NodeMaintenanceStatus) DeepCopy() *NodeMaintenanceStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(GameServerSpec)
+	out := new(NodeMaintenanceStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GameServerStatus) DeepCopyInto(out *GameServerStatus) {
+func (in *OOMPolicy) DeepCopyInto(out *OOMPolicy) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]GameServerCondition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.MaxMemoryLimit != nil {
+		in, out := &in.MaxMemoryLimit, &out.MaxMemoryLimit
+		x := (*in).DeepCopy()
+		*out = &x
 	}
-	if in.LoadBalancerStatus != nil {
-		in, out := &in.LoadBalancerStatus, &out.LoadBalancerStatus
-		*out = new(LoadBalancerStatus)
-		(*in).DeepCopyInto(*out)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OOMPolicy.
+func (in *OOMPolicy) DeepCopy() *OOMPolicy {
+	if in == nil {
+		return nil
 	}
+	out := new(OOMPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodFailure) DeepCopyInto(out *PodFailure) {
+	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerStatus.
-func (in *GameServerStatus) DeepCopy() *GameServerStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodFailure.
+func (in *PodFailure) DeepCopy() *PodFailure {
 	if in == nil {
 		return nil
 	}
-	out := new(GameServerStatus)
+	out := new(PodFailure)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GameServerTemplateSpec) DeepCopyInto(out *GameServerTemplateSpec) {
+func (in *PortRange) DeepCopyInto(out *PortRange) {
 	*out = *in
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerTemplateSpec.
-func (in *GameServerTemplateSpec) DeepCopy() *GameServerTemplateSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PortRange.
+func (in *PortRange) DeepCopy() *PortRange {
 	if in == nil {
 		return nil
 	}
-	out := new(GameServerTemplateSpec)
+	out := new(PortRange)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *InplaceUpdateSquad) DeepCopyInto(out *InplaceUpdateSquad) {
+func (in *PostMortem) DeepCopyInto(out *PostMortem) {
 	*out = *in
-	if in.Threshold != nil {
-		in, out := &in.Threshold, &out.Threshold
-		*out = new(intstr.IntOrString)
+	in.Container.DeepCopyInto(&out.Container)
+	if in.TTLSecondsAfterFinished != nil {
+		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
+		*out = new(int32)
 		**out = **in
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InplaceUpdateSquad.
-func (in *InplaceUpdateSquad) DeepCopy() *InplaceUpdateSquad {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostMortem.
+func (in *PostMortem) DeepCopy() *PostMortem {
 	if in == nil {
 		return nil
 	}
-	out := new(InplaceUpdateSquad)
+	out := new(PostMortem)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LoadBalancerIngress) DeepCopyInto(out *LoadBalancerIngress) {
+func (in *PriorityPolicy) DeepCopyInto(out *PriorityPolicy) {
 	*out = *in
-	if in.Ports != nil {
-		in, out := &in.Ports, &out.Ports
-		*out = make([]LoadBalancerPort, len(*in))
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PriorityPolicy.
+func (in *PriorityPolicy) DeepCopy() *PriorityPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PriorityPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PriorityPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PriorityPolicyList) DeepCopyInto(out *PriorityPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PriorityPolicy, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -471,87 +1481,103 @@ func (in *LoadBalancerIngress) DeepCopyInto(out *LoadBalancerIngress) {
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerIngress.
-func (in *LoadBalancerIngress) DeepCopy() *LoadBalancerIngress {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PriorityPolicyList.
+func (in *PriorityPolicyList) DeepCopy() *PriorityPolicyList {
 	if in == nil {
 		return nil
 	}
-	out := new(LoadBalancerIngress)
+	out := new(PriorityPolicyList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PriorityPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LoadBalancerPort) DeepCopyInto(out *LoadBalancerPort) {
+func (in *PriorityPolicySpec) DeepCopyInto(out *PriorityPolicySpec) {
 	*out = *in
-	if in.ContainerPort != nil {
-		in, out := &in.ContainerPort, &out.ContainerPort
-		*out = new(int32)
-		**out = **in
+	if in.HighPriority != nil {
+		in, out := &in.HighPriority, &out.HighPriority
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.ExternalPort != nil {
-		in, out := &in.ExternalPort, &out.ExternalPort
-		*out = new(int32)
-		**out = **in
+	if in.LowPriority != nil {
+		in, out := &in.LowPriority, &out.LowPriority
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.ContainerPortRange != nil {
-		in, out := &in.ContainerPortRange, &out.ContainerPortRange
-		*out = new(PortRange)
+	if in.PendingSeconds != nil {
+		in, out := &in.PendingSeconds, &out.PendingSeconds
+		*out = new(int32)
 		**out = **in
 	}
-	if in.ExternalPortRange != nil {
-		in, out := &in.ExternalPortRange, &out.ExternalPortRange
-		*out = new(PortRange)
+	if in.CooldownSeconds != nil {
+		in, out := &in.CooldownSeconds, &out.CooldownSeconds
+		*out = new(int32)
 		**out = **in
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerPort.
-func (in *LoadBalancerPort) DeepCopy() *LoadBalancerPort {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PriorityPolicySpec.
+func (in *PriorityPolicySpec) DeepCopy() *PriorityPolicySpec {
 	if in == nil {
 		return nil
 	}
-	out := new(LoadBalancerPort)
+	out := new(PriorityPolicySpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LoadBalancerStatus) DeepCopyInto(out *LoadBalancerStatus) {
+func (in *PriorityPolicyStatus) DeepCopyInto(out *PriorityPolicyStatus) {
 	*out = *in
-	if in.Ingress != nil {
-		in, out := &in.Ingress, &out.Ingress
-		*out = make([]LoadBalancerIngress, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.LastPreemptionTime != nil {
+		in, out := &in.LastPreemptionTime, &out.LastPreemptionTime
+		*out = (*in).DeepCopy()
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerStatus.
-func (in *LoadBalancerStatus) DeepCopy() *LoadBalancerStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PriorityPolicyStatus.
+func (in *PriorityPolicyStatus) DeepCopy() *PriorityPolicyStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(LoadBalancerStatus)
+	out := new(PriorityPolicyStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PortRange) DeepCopyInto(out *PortRange) {
+func (in *ReconcileCheckpoint) DeepCopyInto(out *ReconcileCheckpoint) {
 	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	if in.Created != nil {
+		in, out := &in.Created, &out.Created
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Deleted != nil {
+		in, out := &in.Deleted, &out.Deleted
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PortRange.
-func (in *PortRange) DeepCopy() *PortRange {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReconcileCheckpoint.
+func (in *ReconcileCheckpoint) DeepCopy() *ReconcileCheckpoint {
 	if in == nil {
 		return nil
 	}
-	out := new(PortRange)
+	out := new(ReconcileCheckpoint)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -677,10 +1703,38 @@ func (in *SquadList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SquadPreflight) DeepCopyInto(out *SquadPreflight) {
+	*out = *in
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SquadPreflight.
+func (in *SquadPreflight) DeepCopy() *SquadPreflight {
+	if in == nil {
+		return nil
+	}
+	out := new(SquadPreflight)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SquadSpec) DeepCopyInto(out *SquadSpec) {
 	*out = *in
 	in.Strategy.DeepCopyInto(&out.Strategy)
+	if in.Colocation != nil {
+		in, out := &in.Colocation, &out.Colocation
+		*out = make([]ColocationRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	in.Template.DeepCopyInto(&out.Template)
 	if in.RevisionHistoryLimit != nil {
 		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
@@ -702,6 +1756,33 @@ func (in *SquadSpec) DeepCopyInto(out *SquadSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.ProfileRef != nil {
+		in, out := &in.ProfileRef, &out.ProfileRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Preflight != nil {
+		in, out := &in.Preflight, &out.Preflight
+		*out = new(SquadPreflight)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxResources != nil {
+		in, out := &in.MaxResources, &out.MaxResources
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
 	return
 }
 
@@ -725,6 +1806,11 @@ func (in *SquadStatus) DeepCopyInto(out *SquadStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.GameVersions != nil {
+		in, out := &in.GameVersions, &out.GameVersions
+		*out = make([]GameVersionStatus, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -769,6 +1855,56 @@ func (in *SquadStrategy) DeepCopy() *SquadStrategy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StartupMilestones) DeepCopyInto(out *StartupMilestones) {
+	*out = *in
+	if in.ScheduledTime != nil {
+		in, out := &in.ScheduledTime, &out.ScheduledTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PodRunningTime != nil {
+		in, out := &in.PodRunningTime, &out.PodRunningTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ReadyTime != nil {
+		in, out := &in.ReadyTime, &out.ReadyTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StartupMilestones.
+func (in *StartupMilestones) DeepCopy() *StartupMilestones {
+	if in == nil {
+		return nil
+	}
+	out := new(StartupMilestones)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficHook) DeepCopyInto(out *TrafficHook) {
+	*out = *in
+	in.ClientConfig.DeepCopyInto(&out.ClientConfig)
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficHook.
+func (in *TrafficHook) DeepCopy() *TrafficHook {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WebhookConfiguration) DeepCopyInto(out *WebhookConfiguration) {
 	*out = *in
@@ -0,0 +1,124 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assetlock
+
+import (
+	"context"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coordinationv1client "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/klog"
+)
+
+// Acquire blocks until the Lease name in namespace is held by holder, retrying every period,
+// or ctx is done. A Lease held by others is taken over once it is not renewed for its duration,
+// so a holder crashed while downloading does not block the node forever.
+func Acquire(ctx context.Context, client coordinationv1client.LeasesGetter, namespace, name, holder string,
+	duration, period time.Duration) error {
+	return wait.PollImmediateUntil(period, func() (bool, error) {
+		acquired, err := tryAcquire(client.Leases(namespace), name, holder, duration, time.Now())
+		if err != nil {
+			klog.Warningf("Failed to acquire lease %v/%v: %v", namespace, name, err)
+			return false, nil
+		}
+		return acquired, nil
+	}, ctx.Done())
+}
+
+// tryAcquire acquires the Lease for holder if it does not exist, is released, expired or
+// already held by holder, and reports whether holder holds the Lease.
+func tryAcquire(leases coordinationv1client.LeaseInterface, name, holder string, duration time.Duration,
+	now time.Time) (bool, error) {
+	seconds := int32(duration / time.Second)
+	renewTime := metav1.NewMicroTime(now)
+	lease, err := leases.Get(name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		_, err = leases.Create(&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &renewTime,
+				RenewTime:            &renewTime,
+			},
+		})
+		if k8serrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	if heldBy(lease) == holder {
+		return true, nil
+	}
+	if len(heldBy(lease)) != 0 && !expired(lease, now) {
+		return false, nil
+	}
+	lease = lease.DeepCopy()
+	lease.Spec.HolderIdentity = &holder
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.AcquireTime = &renewTime
+	lease.Spec.RenewTime = &renewTime
+	_, err = leases.Update(lease)
+	if k8serrors.IsConflict(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Release deletes the Lease name in namespace if it is held by holder, so the next pod waiting
+// for it goes on without waiting for the expiry.
+func Release(client coordinationv1client.LeasesGetter, namespace, name, holder string) error {
+	lease, err := client.Leases(namespace).Get(name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if heldBy(lease) != holder {
+		return nil
+	}
+	err = client.Leases(namespace).Delete(name, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &lease.UID},
+	})
+	if k8serrors.IsNotFound(err) || k8serrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// heldBy returns the holder of lease, empty if it is released.
+func heldBy(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// expired checks if lease is not renewed for its duration.
+func expired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	duration := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	return lease.Spec.RenewTime.Add(duration).Before(now)
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assetlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTryAcquire(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	leases := client.CoordinationV1().Leases("default")
+	now := time.Now()
+	acquired, err := tryAcquire(leases, "carrier-asset-node-1-v1", "pod-a", time.Minute, now)
+	if err != nil || !acquired {
+		t.Fatalf("desired lease created for pod-a, get: %v, %v", acquired, err)
+	}
+	acquired, err = tryAcquire(leases, "carrier-asset-node-1-v1", "pod-a", time.Minute, now)
+	if err != nil || !acquired {
+		t.Errorf("desired lease still held by pod-a, get: %v, %v", acquired, err)
+	}
+	acquired, err = tryAcquire(leases, "carrier-asset-node-1-v1", "pod-b", time.Minute, now.Add(30*time.Second))
+	if err != nil || acquired {
+		t.Errorf("desired pod-b waiting while pod-a holds the lease, get: %v, %v", acquired, err)
+	}
+	acquired, err = tryAcquire(leases, "carrier-asset-node-1-v1", "pod-b", time.Minute, now.Add(2*time.Minute))
+	if err != nil || !acquired {
+		t.Errorf("desired expired lease taken over by pod-b, get: %v, %v", acquired, err)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := Acquire(ctx, client.CoordinationV1(), "default", "lease", "pod-a", time.Minute,
+		10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := Release(client.CoordinationV1(), "default", "lease", "pod-b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoordinationV1().Leases("default").Get("lease", metav1.GetOptions{}); err != nil {
+		t.Errorf("desired lease held by others kept, get: %v", err)
+	}
+	if err := Release(client.CoordinationV1(), "default", "lease", "pod-a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoordinationV1().Leases("default").Get("lease", metav1.GetOptions{}); err == nil {
+		t.Errorf("desired lease released")
+	}
+	if err := Acquire(ctx, client.CoordinationV1(), "default", "lease", "pod-b", time.Minute,
+		10*time.Millisecond); err != nil {
+		t.Errorf("desired released lease acquired by pod-b, get: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// Operation is the fleet operation recorded.
+type Operation string
+
+const (
+	// OperationScaleUp records GameServers created for scaling up.
+	OperationScaleUp Operation = "ScaleUp"
+	// OperationScaleDown records GameServers marked out of service for scaling down.
+	OperationScaleDown Operation = "ScaleDown"
+	// OperationDelete records GameServers deleted.
+	OperationDelete Operation = "Delete"
+	// OperationInPlaceUpdate records a batch of GameServers updated in place.
+	OperationInPlaceUpdate Operation = "InPlaceUpdate"
+)
+
+const (
+	// SinkStdout writes records to stdout as JSON lines.
+	SinkStdout = "stdout"
+	// SinkWebhook posts records to a webhook.
+	SinkWebhook = "webhook"
+)
+
+// Record is an audit record of a fleet operation.
+type Record struct {
+	// Time when the operation happened
+	Time time.Time `json:"time"`
+	// Operation recorded
+	Operation Operation `json:"operation"`
+	// Kind of the object operating, e.g. GameServerSet
+	Kind string `json:"kind"`
+	// Namespace of the object
+	Namespace string `json:"namespace"`
+	// Name of the object
+	Name string `json:"name"`
+	// Reason explains why the operation happened
+	Reason string `json:"reason,omitempty"`
+	// Requested is the number of GameServers the operation was applied to
+	Requested int `json:"requested"`
+	// Count is the number of GameServers the operation succeeded on
+	Count int `json:"count"`
+	// GameServers are the names of GameServers the operation succeeded on
+	GameServers []string `json:"gameServers,omitempty"`
+	// Error is why the operation failed on some of the GameServers requested, empty if none failed
+	Error string `json:"error,omitempty"`
+}
+
+// Sink is where the audit records are written.
+type Sink interface {
+	Write(record *Record) error
+}
+
+var (
+	lock sync.RWMutex
+	sink Sink
+)
+
+// NewSink returns the sink of kind, webhookURL is required if kind is webhook.
+func NewSink(kind, webhookURL string) (Sink, error) {
+	switch kind {
+	case SinkStdout:
+		return NewStdoutSink(), nil
+	case SinkWebhook:
+		if len(webhookURL) == 0 {
+			return nil, errors.New("webhook url of audit sink is required")
+		}
+		return NewWebhookSink(webhookURL), nil
+	}
+	return nil, errors.Errorf("unknown audit sink %q", kind)
+}
+
+// SetSink sets the sink which records are written to, audit is disabled if s is nil.
+func SetSink(s Sink) {
+	lock.Lock()
+	defer lock.Unlock()
+	sink = s
+}
+
+// Log writes the record to the sink.
+func Log(record *Record) {
+	lock.RLock()
+	defer lock.RUnlock()
+	if sink == nil {
+		return
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	if err := sink.Write(record); err != nil {
+		klog.Errorf("Failed to write audit record %+v: %v", record, err)
+	}
+}
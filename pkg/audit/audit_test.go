@@ -0,0 +1,50 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetSink(newWriterSink(buf))
+	defer SetSink(nil)
+	record := &Record{
+		Operation:   OperationDelete,
+		Kind:        "GameServerSet",
+		Namespace:   "default",
+		Name:        "test",
+		Requested:   2,
+		Count:       1,
+		GameServers: []string{"test-xxx"},
+		Error:       "error deleting GameServer test-yyy",
+	}
+	Log(record)
+	if record.Time.IsZero() {
+		t.Errorf("desired time of record set")
+	}
+	written := &Record{}
+	if err := json.Unmarshal(buf.Bytes(), written); err != nil {
+		t.Fatal(err)
+	}
+	written.Time = record.Time
+	if !reflect.DeepEqual(record, written) {
+		t.Errorf("desired record %+v, get: %+v", record, written)
+	}
+}
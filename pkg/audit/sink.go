@@ -0,0 +1,108 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// stdoutSink writes records as JSON lines.
+type stdoutSink struct {
+	sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewStdoutSink returns a sink writing records to stdout.
+func NewStdoutSink() Sink {
+	return newWriterSink(os.Stdout)
+}
+
+func newWriterSink(w io.Writer) *stdoutSink {
+	return &stdoutSink{encoder: json.NewEncoder(w)}
+}
+
+// Write writes the record as a JSON line.
+func (s *stdoutSink) Write(record *Record) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.encoder.Encode(record)
+}
+
+const (
+	webhookQueueSize = 1024
+	webhookTimeout   = 10 * time.Second
+)
+
+// webhookSink posts records to a webhook asynchronously, so the
+// controllers are not blocked by a slow webhook.
+type webhookSink struct {
+	url    string
+	client *http.Client
+	queue  chan *Record
+}
+
+// NewWebhookSink returns a sink posting records to url.
+func NewWebhookSink(url string) Sink {
+	s := &webhookSink{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+		queue:  make(chan *Record, webhookQueueSize),
+	}
+	go s.run()
+	return s
+}
+
+// Write queues the record, the record is dropped if the queue is full.
+func (s *webhookSink) Write(record *Record) error {
+	select {
+	case s.queue <- record:
+		return nil
+	default:
+		return errors.New("audit queue is full, record dropped")
+	}
+}
+
+func (s *webhookSink) run() {
+	for record := range s.queue {
+		if err := s.post(record); err != nil {
+			klog.Errorf("Failed to post audit record %+v: %v", record, err)
+		}
+	}
+}
+
+func (s *webhookSink) post(record *Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf("audit webhook returned %v", resp.StatusCode)
+	}
+	return nil
+}
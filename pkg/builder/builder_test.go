@@ -0,0 +1,128 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestGameServerBuilder(t *testing.T) {
+	containerPort := int32(7777)
+	port := carrierv1alpha1.GameServerPort{Name: "game", ContainerPort: &containerPort}
+	gs, err := NewGameServer("default", "gs").
+		WithLabels(map[string]string{"app": "game"}).
+		WithContainer("server", "game:v1").
+		WithPorts(port).
+		WithGates([]string{"ready"}, []string{"no-player"}).
+		WithDeletionCost(10).
+		OutOfService("maintenance").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gs.Namespace != "default" || gs.Name != "gs" || gs.Labels["app"] != "game" {
+		t.Errorf("desired default/gs labeled app=game, get: %+v", gs.ObjectMeta)
+	}
+	if len(gs.Spec.Template.Spec.Containers) != 1 || gs.Spec.Template.Spec.Containers[0].Image != "game:v1" {
+		t.Errorf("desired container of game:v1, get: %+v", gs.Spec.Template.Spec.Containers)
+	}
+	if !reflect.DeepEqual(gs.Spec.Ports, []carrierv1alpha1.GameServerPort{port}) {
+		t.Errorf("desired ports %+v, get: %+v", port, gs.Spec.Ports)
+	}
+	if !reflect.DeepEqual(gs.Spec.ReadinessGates, []string{"ready"}) ||
+		!reflect.DeepEqual(gs.Spec.DeletableGates, []string{"no-player"}) {
+		t.Errorf("desired gates ready and no-player, get: %v, %v", gs.Spec.ReadinessGates, gs.Spec.DeletableGates)
+	}
+	if gs.Annotations[util.GameServerDeletionCost] != "10" {
+		t.Errorf("desired deletion cost 10, get: %v", gs.Annotations[util.GameServerDeletionCost])
+	}
+	if !IsOutOfService(gs) || gs.Spec.Constraints[0].Message != "maintenance" {
+		t.Errorf("desired out of service, get: %+v", gs.Spec.Constraints)
+	}
+
+	if _, err := NewGameServer("default", "gs").WithDeletionCost(math.MaxInt64).Build(); err == nil {
+		t.Errorf("desired error for reserved deletion cost, get: nil")
+	}
+}
+
+func TestMarkOutOfService(t *testing.T) {
+	notEffective := false
+	tests := []struct {
+		name        string
+		constraints []carrierv1alpha1.Constraint
+	}{
+		{
+			name: "no constraint",
+		},
+		{
+			name: "not effective constraint",
+			constraints: []carrierv1alpha1.Constraint{
+				{Type: carrierv1alpha1.NotInService, Effective: &notEffective},
+			},
+		},
+	}
+	for _, test := range tests {
+		gs := &carrierv1alpha1.GameServer{}
+		gs.Spec.Constraints = test.constraints
+		if IsOutOfService(gs) {
+			t.Errorf("%v: desired in service before marked", test.name)
+		}
+		MarkOutOfService(gs, "test")
+		if !IsOutOfService(gs) || len(gs.Spec.Constraints) != 1 {
+			t.Errorf("%v: desired one effective constraint, get: %+v", test.name, gs.Spec.Constraints)
+		}
+	}
+
+	gs := &carrierv1alpha1.GameServer{}
+	MarkOutOfService(gs, "test")
+	timeAdded := metav1.NewTime(time.Now().Add(-time.Hour))
+	gs.Spec.Constraints[0].TimeAdded = &timeAdded
+	MarkOutOfService(gs, "again")
+	if len(gs.Spec.Constraints) != 1 || !gs.Spec.Constraints[0].TimeAdded.Equal(&timeAdded) ||
+		gs.Spec.Constraints[0].Message != "again" {
+		t.Errorf("desired time added kept when marked again, get: %+v", gs.Spec.Constraints)
+	}
+}
+
+func TestSquadBuilder(t *testing.T) {
+	squad, err := NewSquad("default", "squad").
+		WithReplicas(3).
+		WithGameServer(NewGameServer("", "").WithLabels(map[string]string{"app": "game"}).
+			WithContainer("server", "game:v1")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if squad.Spec.Replicas != 3 {
+		t.Errorf("desired replicas 3, get: %v", squad.Spec.Replicas)
+	}
+	if squad.Spec.Selector.MatchLabels[util.SquadNameLabelKey] != "squad" ||
+		squad.Spec.Template.Labels[util.SquadNameLabelKey] != "squad" || squad.Spec.Template.Labels["app"] != "game" {
+		t.Errorf("desired selector matching template, get: %+v, %+v", squad.Spec.Selector, squad.Spec.Template.Labels)
+	}
+
+	_, err = NewSquad("default", "squad").WithGameServer(NewGameServer("", "").WithDeletionCost(math.MaxInt64)).Build()
+	if err == nil {
+		t.Errorf("desired error of GameServer template, get: nil")
+	}
+}
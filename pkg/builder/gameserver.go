@@ -0,0 +1,119 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+// GameServerBuilder builds a GameServer step by step, e.g.
+//
+//	gs, err := NewGameServer("default", "gs").
+//		WithContainer("server", "game:v1").
+//		WithPorts(port).
+//		WithGates([]string{"ready"}, []string{"no-player"}).
+//		Build()
+type GameServerBuilder struct {
+	gs  *carrierv1alpha1.GameServer
+	err error
+}
+
+// NewGameServer returns a builder of the GameServer namespace/name.
+func NewGameServer(namespace, name string) *GameServerBuilder {
+	return &GameServerBuilder{
+		gs: &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		},
+	}
+}
+
+// WithLabels adds labels to the GameServer.
+func (b *GameServerBuilder) WithLabels(labels map[string]string) *GameServerBuilder {
+	if b.gs.Labels == nil {
+		b.gs.Labels = map[string]string{}
+	}
+	for k, v := range labels {
+		b.gs.Labels[k] = v
+	}
+	return b
+}
+
+// WithAnnotations adds annotations to the GameServer.
+func (b *GameServerBuilder) WithAnnotations(annotations map[string]string) *GameServerBuilder {
+	if b.gs.Annotations == nil {
+		b.gs.Annotations = map[string]string{}
+	}
+	for k, v := range annotations {
+		b.gs.Annotations[k] = v
+	}
+	return b
+}
+
+// WithContainer adds a container running image to the pod template of GameServer.
+func (b *GameServerBuilder) WithContainer(name, image string) *GameServerBuilder {
+	b.gs.Spec.Template.Spec.Containers = append(b.gs.Spec.Template.Spec.Containers,
+		corev1.Container{Name: name, Image: image})
+	return b
+}
+
+// WithTemplate sets the pod template of GameServer.
+func (b *GameServerBuilder) WithTemplate(template corev1.PodTemplateSpec) *GameServerBuilder {
+	b.gs.Spec.Template = template
+	return b
+}
+
+// WithPorts adds ports to the GameServer.
+func (b *GameServerBuilder) WithPorts(ports ...carrierv1alpha1.GameServerPort) *GameServerBuilder {
+	b.gs.Spec.Ports = append(b.gs.Spec.Ports, ports...)
+	return b
+}
+
+// WithGates adds readiness gates and deletable gates to the GameServer.
+func (b *GameServerBuilder) WithGates(readiness, deletable []string) *GameServerBuilder {
+	b.gs.Spec.ReadinessGates = append(b.gs.Spec.ReadinessGates, readiness...)
+	b.gs.Spec.DeletableGates = append(b.gs.Spec.DeletableGates, deletable...)
+	return b
+}
+
+// WithScheduling sets the scheduling strategy of GameServer.
+func (b *GameServerBuilder) WithScheduling(scheduling carrierv1alpha1.SchedulingStrategy) *GameServerBuilder {
+	b.gs.Spec.Scheduling = scheduling
+	return b
+}
+
+// WithDeletionCost sets the deletion cost of GameServer.
+func (b *GameServerBuilder) WithDeletionCost(cost int64) *GameServerBuilder {
+	if err := SetDeletionCost(b.gs, cost); err != nil && b.err == nil {
+		b.err = err
+	}
+	return b
+}
+
+// OutOfService marks the GameServer out of service with message.
+func (b *GameServerBuilder) OutOfService(message string) *GameServerBuilder {
+	MarkOutOfService(b.gs, message)
+	return b
+}
+
+// Build returns the GameServer built, or the first error met while building.
+func (b *GameServerBuilder) Build() (*carrierv1alpha1.GameServer, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.gs.DeepCopy(), nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// MarkOutOfService adds an effective NotInService constraint to gs with message,
+// replacing the existing one if any, so gs would close connections and be drained.
+// The drain deadline counts from when gs is marked, so the time an effective constraint
+// was added is kept, and marking gs again does not push the deadline back.
+func MarkOutOfService(gs *carrierv1alpha1.GameServer, message string) {
+	effective := true
+	now := metav1.NewTime(time.Now())
+	constraint := carrierv1alpha1.Constraint{
+		Type:      carrierv1alpha1.NotInService,
+		Effective: &effective,
+		Message:   message,
+		TimeAdded: &now,
+	}
+	for i := range gs.Spec.Constraints {
+		if gs.Spec.Constraints[i].Type != carrierv1alpha1.NotInService {
+			continue
+		}
+		if IsConstraintEffective(&gs.Spec.Constraints[i], now.Time) && gs.Spec.Constraints[i].TimeAdded != nil {
+			constraint.TimeAdded = gs.Spec.Constraints[i].TimeAdded
+		}
+		gs.Spec.Constraints[i] = constraint
+		return
+	}
+	gs.Spec.Constraints = append(gs.Spec.Constraints, constraint)
+}
+
+// IsOutOfService returns true if gs has an effective NotInService constraint not expired yet.
+func IsOutOfService(gs *carrierv1alpha1.GameServer) bool {
+	now := time.Now()
+	for i := range gs.Spec.Constraints {
+		if gs.Spec.Constraints[i].Type == carrierv1alpha1.NotInService &&
+			IsConstraintEffective(&gs.Spec.Constraints[i], now) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsConstraintEffective checks if constraint is effective and not expired at now.
+func IsConstraintEffective(constraint *carrierv1alpha1.Constraint, now time.Time) bool {
+	if constraint.Effective == nil || !*constraint.Effective {
+		return false
+	}
+	return constraint.ExpiresAt == nil || now.Before(constraint.ExpiresAt.Time)
+}
+
+// SetDeletionCost sets the deletion cost annotation of gs. GameServers with lower
+// cost are preferred to be deleted when scaling down.
+func SetDeletionCost(gs *carrierv1alpha1.GameServer, cost int64) error {
+	value := strconv.FormatInt(cost, 10)
+	if err := util.ValidateDeletionCost(value); err != nil {
+		return err
+	}
+	if gs.Annotations == nil {
+		gs.Annotations = map[string]string{}
+	}
+	gs.Annotations[util.GameServerDeletionCost] = value
+	return nil
+}
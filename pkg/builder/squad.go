@@ -0,0 +1,90 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// SquadBuilder builds a Squad step by step, e.g.
+//
+//	squad, err := NewSquad("default", "squad").
+//		WithReplicas(3).
+//		WithGameServer(NewGameServer("", "").WithContainer("server", "game:v1")).
+//		Build()
+type SquadBuilder struct {
+	squad *carrierv1alpha1.Squad
+	err   error
+}
+
+// NewSquad returns a builder of the Squad namespace/name.
+func NewSquad(namespace, name string) *SquadBuilder {
+	return &SquadBuilder{
+		squad: &carrierv1alpha1.Squad{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		},
+	}
+}
+
+// WithReplicas sets the replicas of Squad.
+func (b *SquadBuilder) WithReplicas(replicas int32) *SquadBuilder {
+	b.squad.Spec.Replicas = replicas
+	return b
+}
+
+// WithStrategy sets the update strategy of Squad.
+func (b *SquadBuilder) WithStrategy(strategy carrierv1alpha1.SquadStrategy) *SquadBuilder {
+	b.squad.Spec.Strategy = strategy
+	return b
+}
+
+// WithGameServer sets the GameServer template of Squad to the GameServer built by gs.
+// The name and namespace of the GameServer are ignored.
+func (b *SquadBuilder) WithGameServer(gs *GameServerBuilder) *SquadBuilder {
+	built, err := gs.Build()
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return b
+	}
+	b.squad.Spec.Template = carrierv1alpha1.GameServerTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: built.Labels, Annotations: built.Annotations},
+		Spec:       built.Spec,
+	}
+	return b
+}
+
+// Build returns the Squad built, or the first error met while building. The selector
+// of Squad defaults to the squad name label, which is added to the template.
+func (b *SquadBuilder) Build() (*carrierv1alpha1.Squad, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	squad := b.squad.DeepCopy()
+	if squad.Spec.Selector == nil {
+		squad.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{util.SquadNameLabelKey: squad.Name},
+		}
+		if squad.Spec.Template.Labels == nil {
+			squad.Spec.Template.Labels = map[string]string{}
+		}
+		squad.Spec.Template.Labels[util.SquadNameLabelKey] = squad.Name
+	}
+	return squad, nil
+}
@@ -24,8 +24,12 @@ import (
 
 type CarrierV1alpha1Interface interface {
 	RESTClient() rest.Interface
+	FleetDefragReportsGetter
+	FleetProfilesGetter
 	GameServersGetter
 	GameServerSetsGetter
+	NodeMaintenancesGetter
+	PriorityPoliciesGetter
 	SquadsGetter
 	WebhookConfigurationsGetter
 }
@@ -35,6 +39,14 @@ type CarrierV1alpha1Client struct {
 	restClient rest.Interface
 }
 
+func (c *CarrierV1alpha1Client) FleetDefragReports() FleetDefragReportInterface {
+	return newFleetDefragReports(c)
+}
+
+func (c *CarrierV1alpha1Client) FleetProfiles() FleetProfileInterface {
+	return newFleetProfiles(c)
+}
+
 func (c *CarrierV1alpha1Client) GameServers(namespace string) GameServerInterface {
 	return newGameServers(c, namespace)
 }
@@ -43,6 +55,14 @@ func (c *CarrierV1alpha1Client) GameServerSets(namespace string) GameServerSetIn
 	return newGameServerSets(c, namespace)
 }
 
+func (c *CarrierV1alpha1Client) NodeMaintenances() NodeMaintenanceInterface {
+	return newNodeMaintenances(c)
+}
+
+func (c *CarrierV1alpha1Client) PriorityPolicies() PriorityPolicyInterface {
+	return newPriorityPolicies(c)
+}
+
 func (c *CarrierV1alpha1Client) Squads(namespace string) SquadInterface {
 	return newSquads(c, namespace)
 }
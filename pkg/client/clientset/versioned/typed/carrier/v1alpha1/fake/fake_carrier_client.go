@@ -26,6 +26,14 @@ type FakeCarrierV1alpha1 struct {
 	*testing.Fake
 }
 
+func (c *FakeCarrierV1alpha1) FleetDefragReports() v1alpha1.FleetDefragReportInterface {
+	return &FakeFleetDefragReports{c}
+}
+
+func (c *FakeCarrierV1alpha1) FleetProfiles() v1alpha1.FleetProfileInterface {
+	return &FakeFleetProfiles{c}
+}
+
 func (c *FakeCarrierV1alpha1) GameServers(namespace string) v1alpha1.GameServerInterface {
 	return &FakeGameServers{c, namespace}
 }
@@ -34,6 +42,14 @@ func (c *FakeCarrierV1alpha1) GameServerSets(namespace string) v1alpha1.GameServ
 	return &FakeGameServerSets{c, namespace}
 }
 
+func (c *FakeCarrierV1alpha1) NodeMaintenances() v1alpha1.NodeMaintenanceInterface {
+	return &FakeNodeMaintenances{c}
+}
+
+func (c *FakeCarrierV1alpha1) PriorityPolicies() v1alpha1.PriorityPolicyInterface {
+	return &FakePriorityPolicies{c}
+}
+
 func (c *FakeCarrierV1alpha1) Squads(namespace string) v1alpha1.SquadInterface {
 	return &FakeSquads{c, namespace}
 }
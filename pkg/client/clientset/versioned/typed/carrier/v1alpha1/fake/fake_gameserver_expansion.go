@@ -0,0 +1,56 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/builder"
+)
+
+// MarkOutOfService adds an effective NotInService constraint to the GameServer name.
+func (c *FakeGameServers) MarkOutOfService(name, message string) (*carrierv1alpha1.GameServer, error) {
+	var result *carrierv1alpha1.GameServer
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		gs, err := c.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		builder.MarkOutOfService(gs, message)
+		result, err = c.Update(gs)
+		return err
+	})
+	return result, err
+}
+
+// SetDeletionCost sets the deletion cost annotation of the GameServer name.
+func (c *FakeGameServers) SetDeletionCost(name string, cost int64) (*carrierv1alpha1.GameServer, error) {
+	gs := &carrierv1alpha1.GameServer{}
+	if err := builder.SetDeletionCost(gs, cost); err != nil {
+		return nil, err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": gs.Annotations},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.Patch(name, types.MergePatchType, patch)
+}
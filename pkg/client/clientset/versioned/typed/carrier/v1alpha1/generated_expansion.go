@@ -16,10 +16,16 @@
 
 package v1alpha1
 
-type GameServerExpansion interface{}
+type FleetDefragReportExpansion interface{}
+
+type FleetProfileExpansion interface{}
 
 type GameServerSetExpansion interface{}
 
+type NodeMaintenanceExpansion interface{}
+
+type PriorityPolicyExpansion interface{}
+
 type SquadExpansion interface{}
 
 type WebhookConfigurationExpansion interface{}
@@ -22,10 +22,18 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// FleetDefragReports returns a FleetDefragReportInformer.
+	FleetDefragReports() FleetDefragReportInformer
+	// FleetProfiles returns a FleetProfileInformer.
+	FleetProfiles() FleetProfileInformer
 	// GameServers returns a GameServerInformer.
 	GameServers() GameServerInformer
 	// GameServerSets returns a GameServerSetInformer.
 	GameServerSets() GameServerSetInformer
+	// NodeMaintenances returns a NodeMaintenanceInformer.
+	NodeMaintenances() NodeMaintenanceInformer
+	// PriorityPolicies returns a PriorityPolicyInformer.
+	PriorityPolicies() PriorityPolicyInformer
 	// Squads returns a SquadInformer.
 	Squads() SquadInformer
 	// WebhookConfigurations returns a WebhookConfigurationInformer.
@@ -43,6 +51,16 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// FleetDefragReports returns a FleetDefragReportInformer.
+func (v *version) FleetDefragReports() FleetDefragReportInformer {
+	return &fleetDefragReportInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// FleetProfiles returns a FleetProfileInformer.
+func (v *version) FleetProfiles() FleetProfileInformer {
+	return &fleetProfileInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
 // GameServers returns a GameServerInformer.
 func (v *version) GameServers() GameServerInformer {
 	return &gameServerInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
@@ -53,6 +71,16 @@ func (v *version) GameServerSets() GameServerSetInformer {
 	return &gameServerSetInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// NodeMaintenances returns a NodeMaintenanceInformer.
+func (v *version) NodeMaintenances() NodeMaintenanceInformer {
+	return &nodeMaintenanceInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// PriorityPolicies returns a PriorityPolicyInformer.
+func (v *version) PriorityPolicies() PriorityPolicyInformer {
+	return &priorityPolicyInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
 // Squads returns a SquadInformer.
 func (v *version) Squads() SquadInformer {
 	return &squadInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
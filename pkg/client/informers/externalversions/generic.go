@@ -51,10 +51,18 @@ func (f *genericInformer) Lister() cache.GenericLister {
 func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
 	switch resource {
 	// Group=carrier.ocgi.dev, Version=v1alpha1
+	case v1alpha1.SchemeGroupVersion.WithResource("fleetdefragreports"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Carrier().V1alpha1().FleetDefragReports().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("fleetprofiles"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Carrier().V1alpha1().FleetProfiles().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("gameservers"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Carrier().V1alpha1().GameServers().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("gameserversets"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Carrier().V1alpha1().GameServerSets().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("nodemaintenances"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Carrier().V1alpha1().NodeMaintenances().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("prioritypolicies"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Carrier().V1alpha1().PriorityPolicies().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("squads"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Carrier().V1alpha1().Squads().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("webhookconfigurations"):
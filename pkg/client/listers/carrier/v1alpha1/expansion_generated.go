@@ -16,6 +16,14 @@
 
 package v1alpha1
 
+// FleetDefragReportListerExpansion allows custom methods to be added to
+// FleetDefragReportLister.
+type FleetDefragReportListerExpansion interface{}
+
+// FleetProfileListerExpansion allows custom methods to be added to
+// FleetProfileLister.
+type FleetProfileListerExpansion interface{}
+
 // GameServerListerExpansion allows custom methods to be added to
 // GameServerLister.
 type GameServerListerExpansion interface{}
@@ -32,6 +40,14 @@ type GameServerSetListerExpansion interface{}
 // GameServerSetNamespaceLister.
 type GameServerSetNamespaceListerExpansion interface{}
 
+// NodeMaintenanceListerExpansion allows custom methods to be added to
+// NodeMaintenanceLister.
+type NodeMaintenanceListerExpansion interface{}
+
+// PriorityPolicyListerExpansion allows custom methods to be added to
+// PriorityPolicyLister.
+type PriorityPolicyListerExpansion interface{}
+
 // SquadListerExpansion allows custom methods to be added to
 // SquadLister.
 type SquadListerExpansion interface{}
@@ -0,0 +1,256 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=list;watch;update
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers/status,verbs=update
+// +kubebuilder:rbac:groups="",resources=pods,verbs=delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch
+
+// Config describes the faults injected and their rates.
+type Config struct {
+	// Namespace is the only namespace faults are injected into.
+	Namespace string
+	// Interval is the period faults are injected.
+	Interval time.Duration
+	// KillRate is the probability a running GameServer is killed in each interval.
+	KillRate float64
+	// ReadinessDelayRate is the probability a starting GameServer is held not ready in each interval.
+	ReadinessDelayRate float64
+	// ReadinessDelay is how long the readiness of GameServer is held.
+	ReadinessDelay time.Duration
+	// DrainRate is the probability a node running GameServers is drained in each interval.
+	DrainRate float64
+}
+
+// Validate checks if the config is valid.
+func (c *Config) Validate() error {
+	if len(c.Namespace) == 0 {
+		return errors.New("namespace is required")
+	}
+	if c.Interval <= 0 {
+		return errors.Errorf("interval %v must be positive", c.Interval)
+	}
+	for _, rate := range []float64{c.KillRate, c.ReadinessDelayRate, c.DrainRate} {
+		if rate < 0 || rate > 1 {
+			return errors.Errorf("rate %v is not in [0, 1]", rate)
+		}
+	}
+	return nil
+}
+
+// Controller randomly kills GameServers, delays their readiness and drains their nodes.
+// Node drains are simulated by marking all GameServers on the node out of service, as
+// done for nodes to be removed by cluster autoscaler, nodes themselves are untouched.
+type Controller struct {
+	kubeClient       kubernetes.Interface
+	carrierClient    versioned.Interface
+	gameServerLister listerv1.GameServerLister
+	gameServerSynced cache.InformerSynced
+	recorder         record.EventRecorder
+	config           Config
+	rand             *rand.Rand
+}
+
+// NewController returns a new chaos controller.
+func NewController(
+	kubeClient kubernetes.Interface,
+	carrierClient versioned.Interface,
+	carrierInformerFactory externalversions.SharedInformerFactory,
+	config Config) *Controller {
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	c := &Controller{
+		kubeClient:       kubeClient,
+		carrierClient:    carrierClient,
+		gameServerLister: gameServers.Lister(),
+		gameServerSynced: gameServers.Informer().HasSynced,
+		config:           config,
+		rand:             rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	c.recorder = eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "chaos-controller"})
+	return c
+}
+
+// Run injects faults periodically. Will block until stop is closed.
+func (c *Controller) Run(workers int, stop <-chan struct{}) error {
+	klog.Warningf("Chaos is enabled on namespace %v, GameServers will be killed, delayed and drained",
+		c.config.Namespace)
+	if !cache.WaitForCacheSync(stop, c.gameServerSynced) {
+		return errors.New("failed to wait for caches to sync")
+	}
+	wait.Until(c.inject, c.config.Interval, stop)
+	return nil
+}
+
+// inject injects faults into GameServers of the namespace once.
+func (c *Controller) inject() {
+	list, err := c.gameServerLister.GameServers(c.config.Namespace).List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing GameServers"))
+		return
+	}
+	now := time.Now()
+	nodes := make(map[string][]*carrierv1alpha1.GameServer)
+	for _, gs := range list {
+		if gs.DeletionTimestamp != nil || gameservers.IsStopped(gs) {
+			continue
+		}
+		if readinessReleased(gs, now) {
+			c.handleError(c.releaseReadiness(gs))
+			continue
+		}
+		switch gs.Status.State {
+		case carrierv1alpha1.GameServerRunning:
+			if c.roll(c.config.KillRate) {
+				c.handleError(c.kill(gs))
+				continue
+			}
+			nodes[gs.Status.NodeName] = append(nodes[gs.Status.NodeName], gs)
+		case carrierv1alpha1.GameServerStarting:
+			if !readinessDelayed(gs) && c.roll(c.config.ReadinessDelayRate) {
+				c.handleError(c.delayReadiness(gs, now))
+			}
+		}
+	}
+	for node, list := range nodes {
+		if len(node) == 0 || !c.roll(c.config.DrainRate) {
+			continue
+		}
+		klog.Warningf("Chaos drains node %v", node)
+		for _, gs := range list {
+			c.handleError(c.drain(gs, node))
+		}
+	}
+}
+
+// roll returns true with the probability rate.
+func (c *Controller) roll(rate float64) bool {
+	return rate > 0 && c.rand.Float64() < rate
+}
+
+func (c *Controller) handleError(err error) {
+	if err != nil {
+		utilruntime.HandleError(err)
+	}
+}
+
+// kill deletes the pod of GameServer, as if it crashed.
+func (c *Controller) kill(gs *carrierv1alpha1.GameServer) error {
+	err := c.kubeClient.CoreV1().Pods(gs.Namespace).Delete(gs.Name, &metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "error killing GameServer %v", gs.Name)
+	}
+	c.recorder.Event(gs, corev1.EventTypeWarning, "ChaosKill", "Killed pod of GameServer")
+	return nil
+}
+
+// delayReadiness adds the chaos readiness gate to GameServer, which is passed once the delay elapses.
+func (c *Controller) delayReadiness(gs *carrierv1alpha1.GameServer, now time.Time) error {
+	gsCopy := gs.DeepCopy()
+	gsCopy.Spec.ReadinessGates = append(gsCopy.Spec.ReadinessGates, util.ChaosReadinessGate)
+	if gsCopy.Annotations == nil {
+		gsCopy.Annotations = make(map[string]string)
+	}
+	releaseTime := now.Add(c.config.ReadinessDelay)
+	gsCopy.Annotations[util.ChaosReadinessReleaseAnnotation] = releaseTime.UTC().Format(time.RFC3339)
+	if _, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Update(gsCopy); err != nil {
+		return errors.Wrapf(err, "error delaying readiness of GameServer %v", gs.Name)
+	}
+	c.recorder.Eventf(gs, corev1.EventTypeWarning, "ChaosReadinessDelay",
+		"Readiness of GameServer is delayed for %v", c.config.ReadinessDelay)
+	return nil
+}
+
+// releaseReadiness passes the chaos readiness gate of GameServer.
+func (c *Controller) releaseReadiness(gs *carrierv1alpha1.GameServer) error {
+	gsCopy := gs.DeepCopy()
+	now := metav1.Now()
+	gsCopy.Status.Conditions = append(gsCopy.Status.Conditions, carrierv1alpha1.GameServerCondition{
+		Type:               util.ChaosReadinessGate,
+		Status:             carrierv1alpha1.ConditionTrue,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
+	if _, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).UpdateStatus(gsCopy); err != nil {
+		return errors.Wrapf(err, "error releasing readiness of GameServer %v", gs.Name)
+	}
+	return nil
+}
+
+// drain marks GameServer out of service, as if its node is being removed.
+func (c *Controller) drain(gs *carrierv1alpha1.GameServer, node string) error {
+	gsCopy := gs.DeepCopy()
+	gameservers.AddNotInServiceConstraint(gsCopy)
+	if _, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Update(gsCopy); err != nil {
+		return errors.Wrapf(err, "error draining GameServer %v", gs.Name)
+	}
+	c.recorder.Eventf(gs, corev1.EventTypeWarning, "ChaosDrain", "Node %v of GameServer is drained", node)
+	return nil
+}
+
+// readinessDelayed returns true if GameServer is ever held not ready by chaos.
+func readinessDelayed(gs *carrierv1alpha1.GameServer) bool {
+	_, ok := gs.Annotations[util.ChaosReadinessReleaseAnnotation]
+	return ok
+}
+
+// readinessReleased returns true if the readiness delay of GameServer elapses, and the
+// chaos readiness gate is not passed yet.
+func readinessReleased(gs *carrierv1alpha1.GameServer, now time.Time) bool {
+	value, ok := gs.Annotations[util.ChaosReadinessReleaseAnnotation]
+	if !ok {
+		return false
+	}
+	releaseTime, err := time.Parse(time.RFC3339, value)
+	if err != nil || now.Before(releaseTime) {
+		return false
+	}
+	for _, condition := range gs.Status.Conditions {
+		if condition.Type == util.ChaosReadinessGate {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	gsfake "github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{
+			name:   "valid",
+			config: Config{Namespace: "chaos", Interval: time.Minute, KillRate: 0.1},
+			valid:  true,
+		},
+		{
+			name:   "no namespace",
+			config: Config{Interval: time.Minute},
+		},
+		{
+			name:   "no interval",
+			config: Config{Namespace: "chaos"},
+		},
+		{
+			name:   "rate out of range",
+			config: Config{Namespace: "chaos", Interval: time.Minute, DrainRate: 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.config.Validate(); (err == nil) != test.valid {
+				t.Errorf("desired valid %v, get: %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func newTestController(config Config, list ...*carrierv1alpha1.GameServer) (*Controller, *fake.Clientset,
+	*gsfake.Clientset) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	kubeClient := fake.NewSimpleClientset()
+	carrierClient := gsfake.NewSimpleClientset()
+	for _, gs := range list {
+		indexer.Add(gs)
+		carrierClient.Tracker().Add(gs)
+		kubeClient.Tracker().Add(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: gs.Name, Namespace: gs.Namespace}})
+	}
+	return &Controller{
+		kubeClient:       kubeClient,
+		carrierClient:    carrierClient,
+		gameServerLister: listerv1.NewGameServerLister(indexer),
+		recorder:         record.NewFakeRecorder(10),
+		config:           config,
+		rand:             rand.New(rand.NewSource(1)),
+	}, kubeClient, carrierClient
+}
+
+func gameServer(name string, state carrierv1alpha1.GameServerState) *carrierv1alpha1.GameServer {
+	return &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "chaos"},
+		Status:     carrierv1alpha1.GameServerStatus{State: state, NodeName: "node1"},
+	}
+}
+
+func TestInjectKill(t *testing.T) {
+	c, kubeClient, _ := newTestController(Config{Namespace: "chaos", KillRate: 1},
+		gameServer("running", carrierv1alpha1.GameServerRunning),
+		gameServer("starting", carrierv1alpha1.GameServerStarting))
+	c.inject()
+	if _, err := kubeClient.CoreV1().Pods("chaos").Get("running", metav1.GetOptions{}); err == nil {
+		t.Errorf("desired pod of running GameServer killed")
+	}
+	if _, err := kubeClient.CoreV1().Pods("chaos").Get("starting", metav1.GetOptions{}); err != nil {
+		t.Errorf("desired pod of starting GameServer kept, get: %v", err)
+	}
+}
+
+func TestInjectDrain(t *testing.T) {
+	c, _, carrierClient := newTestController(Config{Namespace: "chaos", DrainRate: 1},
+		gameServer("gs1", carrierv1alpha1.GameServerRunning),
+		gameServer("gs2", carrierv1alpha1.GameServerRunning))
+	c.inject()
+	for _, name := range []string{"gs1", "gs2"} {
+		gs, _ := carrierClient.CarrierV1alpha1().GameServers("chaos").Get(name, metav1.GetOptions{})
+		if !gameservers.IsOutOfService(gs) {
+			t.Errorf("desired %v out of service", name)
+		}
+	}
+}
+
+func TestReadinessDelay(t *testing.T) {
+	delay := time.Minute
+	c, _, carrierClient := newTestController(Config{Namespace: "chaos", ReadinessDelayRate: 1, ReadinessDelay: delay},
+		gameServer("gs", carrierv1alpha1.GameServerStarting))
+	c.inject()
+	gs, _ := carrierClient.CarrierV1alpha1().GameServers("chaos").Get("gs", metav1.GetOptions{})
+	if !readinessDelayed(gs) || gameservers.IsReady(gs) {
+		t.Fatalf("desired readiness delayed, get: %+v", gs.Spec.ReadinessGates)
+	}
+	if readinessReleased(gs, time.Now()) {
+		t.Errorf("desired readiness not released before delay")
+	}
+	if !readinessReleased(gs, time.Now().Add(2*delay)) {
+		t.Fatalf("desired readiness released after delay")
+	}
+	if err := c.releaseReadiness(gs); err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	gs, _ = carrierClient.CarrierV1alpha1().GameServers("chaos").Get("gs", metav1.GetOptions{})
+	if !gameservers.IsReady(gs) || readinessReleased(gs, time.Now().Add(2*delay)) {
+		t.Errorf("desired chaos readiness gate passed, get: %+v", gs.Status.Conditions)
+	}
+	if gs.Annotations[util.ChaosReadinessReleaseAnnotation] == "" {
+		t.Errorf("desired release time kept")
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consolidation
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// DeletionCandidateTaint is the soft taint cluster autoscaler adds to nodes it plans to remove.
+const DeletionCandidateTaint = "DeletionCandidateOfClusterAutoscaler"
+
+// Config describes the node pool consolidated.
+type Config struct {
+	// NodeSelector selects the nodes of the pool shared by Squads.
+	NodeSelector string
+	// Interval is the period the nodes to empty are chosen.
+	Interval time.Duration
+	// MaxNodes is the max number of nodes emptied at the same time.
+	MaxNodes int
+}
+
+// Validate checks if the config is valid.
+func (c *Config) Validate() error {
+	if _, err := labels.Parse(c.NodeSelector); err != nil {
+		return errors.Wrapf(err, "invalid node selector %q", c.NodeSelector)
+	}
+	if c.Interval <= 0 {
+		return errors.Errorf("interval %v must be positive", c.Interval)
+	}
+	if c.MaxNodes <= 0 {
+		return errors.Errorf("max nodes %v must be positive", c.MaxNodes)
+	}
+	return nil
+}
+
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=list;watch;patch
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=list;watch
+
+// Controller chooses the nodes of a pool to empty first, and ranks the GameServers on them by
+// the drain rank annotation, which every GameServerSet prefers when scaling down. So Squads
+// sharing the pool scale down on the same nodes, nodes cluster autoscaler plans to remove first,
+// then the nodes running the fewest GameServers.
+type Controller struct {
+	carrierClient    versioned.Interface
+	nodeLister       corelisterv1.NodeLister
+	nodeSynced       cache.InformerSynced
+	gameServerLister listerv1.GameServerLister
+	gameServerSynced cache.InformerSynced
+	selector         labels.Selector
+	config           Config
+}
+
+// NewController returns a new consolidation controller, config must be validated.
+func NewController(
+	carrierClient versioned.Interface,
+	kubeInformerFactory informers.SharedInformerFactory,
+	carrierInformerFactory externalversions.SharedInformerFactory,
+	config Config) *Controller {
+	nodes := kubeInformerFactory.Core().V1().Nodes()
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	selector, _ := labels.Parse(config.NodeSelector)
+	return &Controller{
+		carrierClient:    carrierClient,
+		nodeLister:       nodes.Lister(),
+		nodeSynced:       nodes.Informer().HasSynced,
+		gameServerLister: gameServers.Lister(),
+		gameServerSynced: gameServers.Informer().HasSynced,
+		selector:         selector,
+		config:           config,
+	}
+}
+
+// Run ranks GameServers periodically. Will block until stop is closed.
+func (c *Controller) Run(workers int, stop <-chan struct{}) error {
+	if !cache.WaitForCacheSync(stop, c.nodeSynced, c.gameServerSynced) {
+		return errors.New("failed to wait for caches to sync")
+	}
+	wait.Until(c.consolidate, c.config.Interval, stop)
+	return nil
+}
+
+// consolidate chooses the nodes to empty once, and updates the drain rank of GameServers.
+func (c *Controller) consolidate() {
+	nodes, err := c.nodeLister.List(c.selector)
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing nodes"))
+		return
+	}
+	list, err := c.gameServerLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing GameServers"))
+		return
+	}
+	counts := make(map[string]int)
+	for _, gs := range list {
+		if isActive(gs) {
+			counts[gs.Status.NodeName]++
+		}
+	}
+	ranks := make(map[string]int)
+	for i, node := range rankNodes(nodes, counts, c.config.MaxNodes) {
+		ranks[node] = i
+	}
+	klog.V(4).Infof("Nodes to empty first: %v", ranks)
+	for _, gs := range list {
+		var desired *string
+		if rank, ok := ranks[gs.Status.NodeName]; ok && isActive(gs) {
+			value := strconv.Itoa(rank)
+			desired = &value
+		}
+		if err := c.setDrainRank(gs, desired); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}
+}
+
+// setDrainRank sets the drain rank annotation of gs to rank, or removes it if rank is nil.
+func (c *Controller) setDrainRank(gs *carrierv1alpha1.GameServer, rank *string) error {
+	current, ok := gs.Annotations[util.NodeDrainRankAnnotation]
+	if rank == nil && !ok || rank != nil && ok && *rank == current {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]*string{util.NodeDrainRankAnnotation: rank},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Patch(gs.Name, types.MergePatchType, patch)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "error setting drain rank of GameServer %v/%v", gs.Namespace, gs.Name)
+	}
+	return nil
+}
+
+// isActive returns true if gs is scheduled and not leaving yet.
+func isActive(gs *carrierv1alpha1.GameServer) bool {
+	return gs.DeletionTimestamp == nil && len(gs.Status.NodeName) != 0 && !gameservers.IsStopped(gs)
+}
+
+// rankNodes returns at most maxNodes names of nodes running GameServers to empty, in order:
+// nodes cluster autoscaler plans to remove, then nodes running fewer GameServers, counts is
+// the number of GameServers on each node.
+func rankNodes(nodes []*corev1.Node, counts map[string]int, maxNodes int) []string {
+	var candidates []*corev1.Node
+	for _, node := range nodes {
+		if counts[node.Name] != 0 {
+			candidates = append(candidates, node)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if candidateA, candidateB := isDeletionCandidate(a), isDeletionCandidate(b); candidateA != candidateB {
+			return candidateA
+		}
+		if counts[a.Name] != counts[b.Name] {
+			return counts[a.Name] < counts[b.Name]
+		}
+		return a.Name < b.Name
+	})
+	if len(candidates) > maxNodes {
+		candidates = candidates[:maxNodes]
+	}
+	names := make([]string, 0, len(candidates))
+	for _, node := range candidates {
+		names = append(names, node.Name)
+	}
+	return names
+}
+
+// isDeletionCandidate returns true if cluster autoscaler plans to remove node.
+func isDeletionCandidate(node *corev1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == DeletionCandidateTaint {
+			return true
+		}
+	}
+	return false
+}
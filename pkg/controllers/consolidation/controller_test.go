@@ -0,0 +1,151 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consolidation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	gsfake "github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{
+			name:   "valid",
+			config: Config{NodeSelector: "pool=game", Interval: time.Minute, MaxNodes: 1},
+			valid:  true,
+		},
+		{
+			name:   "invalid selector",
+			config: Config{NodeSelector: "pool in game", Interval: time.Minute, MaxNodes: 1},
+		},
+		{
+			name:   "no interval",
+			config: Config{NodeSelector: "pool=game", MaxNodes: 1},
+		},
+		{
+			name:   "no max nodes",
+			config: Config{NodeSelector: "pool=game", Interval: time.Minute},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.config.Validate(); (err == nil) != test.valid {
+				t.Errorf("desired valid %v, get: %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func newNode(name string, taints ...corev1.Taint) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"pool": "game"}},
+		Spec:       corev1.NodeSpec{Taints: taints},
+	}
+}
+
+func TestRankNodes(t *testing.T) {
+	candidate := corev1.Taint{Key: DeletionCandidateTaint, Effect: corev1.TaintEffectPreferNoSchedule}
+	nodes := []*corev1.Node{newNode("node1"), newNode("node2"), newNode("node3", candidate), newNode("node4"), newNode("node5")}
+	counts := map[string]int{"node1": 3, "node2": 1, "node3": 5, "node4": 1}
+	tests := []struct {
+		name     string
+		maxNodes int
+		desired  []string
+	}{
+		{
+			name:     "all nodes running GameServers",
+			maxNodes: 10,
+			desired:  []string{"node3", "node2", "node4", "node1"},
+		},
+		{
+			name:     "limited by max nodes",
+			maxNodes: 2,
+			desired:  []string{"node3", "node2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := rankNodes(nodes, counts, test.maxNodes); !reflect.DeepEqual(test.desired, actual) {
+				t.Errorf("desired: %v, get: %v", test.desired, actual)
+			}
+		})
+	}
+}
+
+func TestConsolidate(t *testing.T) {
+	newGS := func(name, node, rank string) *carrierv1alpha1.GameServer {
+		gs := &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: map[string]string{}},
+			Status:     carrierv1alpha1.GameServerStatus{NodeName: node, State: carrierv1alpha1.GameServerRunning},
+		}
+		if len(rank) != 0 {
+			gs.Annotations[util.NodeDrainRankAnnotation] = rank
+		}
+		return gs
+	}
+	list := []*carrierv1alpha1.GameServer{
+		newGS("gs1", "node1", ""),
+		newGS("gs2", "node1", "0"),
+		newGS("gs3", "node2", ""),
+		newGS("gs4", "node2", "0"),
+		newGS("gs5", "node3", "1"),
+	}
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range []*corev1.Node{newNode("node1"), newNode("node2"), newNode("node3")} {
+		nodeIndexer.Add(node)
+	}
+	// node3 is not in the pool.
+	nodeIndexer.Update(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node3"}})
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	carrierClient := gsfake.NewSimpleClientset()
+	for _, gs := range list {
+		indexer.Add(gs)
+		carrierClient.Tracker().Add(gs)
+	}
+	c := &Controller{
+		carrierClient:    carrierClient,
+		nodeLister:       corelisterv1.NewNodeLister(nodeIndexer),
+		gameServerLister: listerv1.NewGameServerLister(indexer),
+		selector:         labels.SelectorFromSet(labels.Set{"pool": "game"}),
+		config:           Config{MaxNodes: 1},
+	}
+	c.consolidate()
+	desired := map[string]string{"gs1": "", "gs2": "", "gs3": "0", "gs4": "0", "gs5": ""}
+	for name, rank := range desired {
+		gs, err := carrierClient.CarrierV1alpha1().GameServers("default").Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := gs.Annotations[util.NodeDrainRankAnnotation]; actual != rank {
+			t.Errorf("GameServer %v desired rank %q, get: %q", name, rank, actual)
+		}
+	}
+}
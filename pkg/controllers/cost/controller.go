@@ -0,0 +1,293 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cost
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/metrics"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=squads,verbs=list;watch
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=list;watch;patch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list;watch;patch
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=list;watch
+
+// Config describes the cost labels and the period costs are recorded.
+type Config struct {
+	// LabelKeys are the keys of Squad labels propagated to GameServers and pods.
+	LabelKeys []string
+	// Interval is the period labels are propagated and costs are recorded.
+	Interval time.Duration
+}
+
+// Validate checks if the config is valid.
+func (c *Config) Validate() error {
+	if c.Interval <= 0 {
+		return errors.Errorf("interval %v must be positive", c.Interval)
+	}
+	for _, key := range c.LabelKeys {
+		if len(key) == 0 {
+			return errors.New("label key must not be empty")
+		}
+	}
+	return nil
+}
+
+// squadKey is the namespace and name of a Squad.
+type squadKey struct {
+	namespace string
+	name      string
+}
+
+// Controller propagates the cost labels of Squads to their GameServers and pods periodically,
+// including GameServers created before the labels are changed, and records the costs of Squads.
+// The node time of a GameServer is attributed by the largest share of cpu and memory of its
+// node it requests.
+type Controller struct {
+	kubeClient       kubernetes.Interface
+	carrierClient    versioned.Interface
+	squadLister      listerv1.SquadLister
+	squadSynced      cache.InformerSynced
+	gameServerLister listerv1.GameServerLister
+	gameServerSynced cache.InformerSynced
+	podLister        corelisterv1.PodLister
+	podSynced        cache.InformerSynced
+	nodeLister       corelisterv1.NodeLister
+	nodeSynced       cache.InformerSynced
+	config           Config
+	// squads are the Squads node seconds are recorded for.
+	squads map[squadKey]bool
+}
+
+// NewController returns a new cost controller, config must be validated.
+func NewController(
+	kubeClient kubernetes.Interface,
+	carrierClient versioned.Interface,
+	kubeInformerFactory informers.SharedInformerFactory,
+	carrierInformerFactory externalversions.SharedInformerFactory,
+	config Config) *Controller {
+	squads := carrierInformerFactory.Carrier().V1alpha1().Squads()
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	pods := kubeInformerFactory.Core().V1().Pods()
+	nodes := kubeInformerFactory.Core().V1().Nodes()
+	return &Controller{
+		kubeClient:       kubeClient,
+		carrierClient:    carrierClient,
+		squadLister:      squads.Lister(),
+		squadSynced:      squads.Informer().HasSynced,
+		gameServerLister: gameServers.Lister(),
+		gameServerSynced: gameServers.Informer().HasSynced,
+		podLister:        pods.Lister(),
+		podSynced:        pods.Informer().HasSynced,
+		nodeLister:       nodes.Lister(),
+		nodeSynced:       nodes.Informer().HasSynced,
+		config:           config,
+		squads:           make(map[squadKey]bool),
+	}
+}
+
+// Run propagates labels and records costs periodically. Will block until stop is closed.
+func (c *Controller) Run(workers int, stop <-chan struct{}) error {
+	if !cache.WaitForCacheSync(stop, c.squadSynced, c.gameServerSynced, c.podSynced, c.nodeSynced) {
+		return errors.New("failed to wait for caches to sync")
+	}
+	wait.Until(c.attribute, c.config.Interval, stop)
+	return nil
+}
+
+// attribute propagates the cost labels and records the costs of all Squads once.
+func (c *Controller) attribute() {
+	squads, err := c.squadLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing Squads"))
+		return
+	}
+	list, err := c.gameServerLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing GameServers"))
+		return
+	}
+	costLabels := make(map[squadKey]map[string]string, len(squads))
+	for _, squad := range squads {
+		costLabels[squadKey{namespace: squad.Namespace, name: squad.Name}] = c.costLabels(squad)
+	}
+	requests := make(map[squadKey]corev1.ResourceList)
+	nodeSeconds := make(map[squadKey]float64)
+	for _, gs := range list {
+		key := squadKey{namespace: gs.Namespace, name: gs.Labels[util.SquadNameLabelKey]}
+		desired, ok := costLabels[key]
+		if !ok {
+			continue
+		}
+		if err := c.propagate(gs, desired); err != nil {
+			utilruntime.HandleError(err)
+		}
+		if !inService(gs) {
+			continue
+		}
+		request := podRequests(&gs.Spec.Template.Spec)
+		if requests[key] == nil {
+			requests[key] = corev1.ResourceList{}
+		}
+		addResources(requests[key], request)
+		node, err := c.nodeLister.Get(gs.Status.NodeName)
+		if err != nil {
+			klog.V(4).Infof("Node %v of GameServer %v/%v not found: %v", gs.Status.NodeName, gs.Namespace, gs.Name, err)
+			continue
+		}
+		nodeSeconds[key] += nodeShare(request, node.Status.Allocatable) * c.config.Interval.Seconds()
+	}
+	metrics.ResetSquadCosts()
+	current := make(map[squadKey]bool, len(costLabels))
+	for key, squadLabels := range costLabels {
+		current[key] = true
+		for label, value := range squadLabels {
+			metrics.RecordSquadCostLabel(key.namespace, key.name, label, value)
+		}
+		for name, quantity := range requests[key] {
+			metrics.RecordSquadResourceRequest(key.namespace, key.name, string(name), float64(quantity.MilliValue())/1000)
+		}
+		metrics.RecordSquadNodeSeconds(key.namespace, key.name, nodeSeconds[key])
+	}
+	for key := range c.squads {
+		if !current[key] {
+			metrics.DeleteSquadNodeSeconds(key.namespace, key.name)
+		}
+	}
+	c.squads = current
+}
+
+// costLabels returns the cost labels of squad.
+func (c *Controller) costLabels(squad *carrierv1alpha1.Squad) map[string]string {
+	result := make(map[string]string)
+	for _, key := range c.config.LabelKeys {
+		if value, ok := squad.Labels[key]; ok {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// propagate sets the cost labels of GameServer and its pod to desired, cost labels removed
+// from the Squad are removed too.
+func (c *Controller) propagate(gs *carrierv1alpha1.GameServer, desired map[string]string) error {
+	if gs.DeletionTimestamp != nil {
+		return nil
+	}
+	if patch := c.labelPatch(gs.Labels, desired); patch != nil {
+		_, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Patch(gs.Name, types.MergePatchType, patch)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return errors.Wrapf(err, "error propagating cost labels to GameServer %v/%v", gs.Namespace, gs.Name)
+		}
+	}
+	pod, err := c.podLister.Pods(gs.Namespace).Get(gs.Name)
+	if err != nil {
+		return nil
+	}
+	if patch := c.labelPatch(pod.Labels, desired); patch != nil {
+		_, err := c.kubeClient.CoreV1().Pods(pod.Namespace).Patch(pod.Name, types.MergePatchType, patch)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return errors.Wrapf(err, "error propagating cost labels to pod %v/%v", pod.Namespace, pod.Name)
+		}
+	}
+	return nil
+}
+
+// labelPatch returns the merge patch setting the cost labels in current to desired,
+// nil if they are the same.
+func (c *Controller) labelPatch(current, desired map[string]string) []byte {
+	changes := make(map[string]*string)
+	for _, key := range c.config.LabelKeys {
+		value, ok := desired[key]
+		existing, exists := current[key]
+		switch {
+		case ok && (!exists || existing != value):
+			changes[key] = &value
+		case !ok && exists:
+			changes[key] = nil
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	patch, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": changes},
+	})
+	return patch
+}
+
+// inService returns true if gs is scheduled and not deleted yet.
+func inService(gs *carrierv1alpha1.GameServer) bool {
+	return gs.DeletionTimestamp == nil && len(gs.Status.NodeName) != 0 && !gameservers.IsStopped(gs)
+}
+
+// podRequests returns the resources requested by the containers of podSpec.
+func podRequests(podSpec *corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range podSpec.Containers {
+		addResources(requests, container.Resources.Requests)
+	}
+	return requests
+}
+
+func addResources(total, list corev1.ResourceList) {
+	for name, quantity := range list {
+		if current, ok := total[name]; ok {
+			current.Add(quantity)
+			total[name] = current
+		} else {
+			total[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+// nodeShare returns the largest share of cpu and memory of allocatable requested, at most 1.
+func nodeShare(requests, allocatable corev1.ResourceList) float64 {
+	var share float64
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		request, allocated := requests[name], allocatable[name]
+		if allocated.IsZero() {
+			continue
+		}
+		if s := float64(request.MilliValue()) / float64(allocated.MilliValue()); s > share {
+			share = s
+		}
+	}
+	if share > 1 {
+		return 1
+	}
+	return share
+}
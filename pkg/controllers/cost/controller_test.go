@@ -0,0 +1,126 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cost
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	gsfake "github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestNodeShare(t *testing.T) {
+	allocatable := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("8"),
+		corev1.ResourceMemory: resource.MustParse("16Gi"),
+	}
+	tests := []struct {
+		name     string
+		requests corev1.ResourceList
+		desired  float64
+	}{
+		{
+			name:     "cpu bound",
+			requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+			desired:  0.25,
+		},
+		{
+			name:     "memory bound",
+			requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("8Gi")},
+			desired:  0.5,
+		},
+		{
+			name:     "no requests",
+			requests: corev1.ResourceList{},
+		},
+		{
+			name:     "larger than node",
+			requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("16")},
+			desired:  1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := nodeShare(test.requests, allocatable); actual != test.desired {
+				t.Errorf("desired: %v, get: %v", test.desired, actual)
+			}
+		})
+	}
+}
+
+func TestAttribute(t *testing.T) {
+	squad := &carrierv1alpha1.Squad{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "squad",
+			Namespace: "default",
+			Labels:    map[string]string{"team": "blue", "app": "game"},
+		},
+	}
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gs",
+			Namespace: "default",
+			Labels:    map[string]string{util.SquadNameLabelKey: "squad", "cost-center": "old"},
+		},
+		Status: carrierv1alpha1.GameServerStatus{NodeName: "node1"},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default"}}
+	squadIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	squadIndexer.Add(squad)
+	gsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	gsIndexer.Add(gs)
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	podIndexer.Add(pod)
+	kubeClient := fake.NewSimpleClientset(pod)
+	carrierClient := gsfake.NewSimpleClientset(gs)
+	c := &Controller{
+		kubeClient:       kubeClient,
+		carrierClient:    carrierClient,
+		squadLister:      listerv1.NewSquadLister(squadIndexer),
+		gameServerLister: listerv1.NewGameServerLister(gsIndexer),
+		podLister:        corelisterv1.NewPodLister(podIndexer),
+		nodeLister:       corelisterv1.NewNodeLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		config:           Config{LabelKeys: []string{"team", "cost-center"}, Interval: time.Minute},
+		squads:           make(map[squadKey]bool),
+	}
+	c.attribute()
+	desired := map[string]string{util.SquadNameLabelKey: "squad", "team": "blue"}
+	actualGS, err := carrierClient.CarrierV1alpha1().GameServers("default").Get("gs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(desired, actualGS.Labels) {
+		t.Errorf("desired GameServer labels: %v, get: %v", desired, actualGS.Labels)
+	}
+	actualPod, err := kubeClient.CoreV1().Pods("default").Get("gs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	desired = map[string]string{"team": "blue"}
+	if !reflect.DeepEqual(desired, actualPod.Labels) {
+		t.Errorf("desired pod labels: %v, get: %v", desired, actualPod.Labels)
+	}
+}
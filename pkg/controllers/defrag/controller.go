@@ -0,0 +1,323 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package defrag
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/metrics"
+	"github.com/ocgi/carrier/pkg/util/kube"
+)
+
+const (
+	// cordonOwnerPrefix prefixes the name of FleetDefragReport cordoning a node.
+	cordonOwnerPrefix = "FleetDefragReport/"
+	// defaultDrainTimeoutSeconds is how long drained nodes stay cordoned if not specified.
+	defaultDrainTimeoutSeconds = 3600
+)
+
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=fleetdefragreports,verbs=list;watch
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=fleetdefragreports/status,verbs=update
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=list;watch;update
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=list;watch;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch
+
+// Controller analyzes the node pool of every FleetDefragReport periodically, and writes
+// the result to its status. Nodes marked to be removed by cluster autoscaler are not
+// regarded as part of the pool, and GameServers out of service are not counted, so the
+// nodes drained by earlier analyses are reported as empty. Drained nodes are cordoned, so the
+// GameServers replacing the ones drained are not scheduled back onto them, till they are
+// removed or the drain timeout of the report expires.
+type Controller struct {
+	kubeClient       kubernetes.Interface
+	carrierClient    versioned.Interface
+	reportLister     listerv1.FleetDefragReportLister
+	reportSynced     cache.InformerSynced
+	nodeLister       corelisterv1.NodeLister
+	nodeSynced       cache.InformerSynced
+	gameServerLister listerv1.GameServerLister
+	gameServerSynced cache.InformerSynced
+	recorder         record.EventRecorder
+	interval         time.Duration
+	// reported are the names of reports metrics are recorded for.
+	reported sets.String
+}
+
+// NewController returns a new defrag controller analyzing reports every interval.
+func NewController(
+	kubeClient kubernetes.Interface,
+	carrierClient versioned.Interface,
+	kubeInformerFactory informers.SharedInformerFactory,
+	carrierInformerFactory externalversions.SharedInformerFactory,
+	interval time.Duration) *Controller {
+	reports := carrierInformerFactory.Carrier().V1alpha1().FleetDefragReports()
+	nodes := kubeInformerFactory.Core().V1().Nodes()
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	c := &Controller{
+		kubeClient:       kubeClient,
+		carrierClient:    carrierClient,
+		reportLister:     reports.Lister(),
+		reportSynced:     reports.Informer().HasSynced,
+		nodeLister:       nodes.Lister(),
+		nodeSynced:       nodes.Informer().HasSynced,
+		gameServerLister: gameServers.Lister(),
+		gameServerSynced: gameServers.Informer().HasSynced,
+		interval:         interval,
+		reported:         sets.NewString(),
+	}
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	c.recorder = eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "defrag-controller"})
+	return c
+}
+
+// Run analyzes reports periodically. Will block until stop is closed.
+func (c *Controller) Run(workers int, stop <-chan struct{}) error {
+	if !cache.WaitForCacheSync(stop, c.reportSynced, c.nodeSynced, c.gameServerSynced) {
+		return errors.New("failed to wait for caches to sync")
+	}
+	wait.Until(c.analyzeAll, c.interval, stop)
+	return nil
+}
+
+// analyzeAll analyzes all reports once.
+func (c *Controller) analyzeAll() {
+	reports, err := c.reportLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing FleetDefragReports"))
+		return
+	}
+	list, err := c.gameServerLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing GameServers"))
+		return
+	}
+	servers := make(map[string][]*carrierv1alpha1.GameServer)
+	for _, gs := range list {
+		if inService(gs) {
+			servers[gs.Status.NodeName] = append(servers[gs.Status.NodeName], gs)
+		}
+	}
+	current := sets.NewString()
+	draining := make(map[string]*carrierv1alpha1.FleetDefragReport)
+	for _, report := range reports {
+		current.Insert(report.Name)
+		if report.Spec.Action == carrierv1alpha1.DefragDrain {
+			draining[cordonOwnerPrefix+report.Name] = report
+		}
+		if err := c.sync(report, servers); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}
+	c.uncordon(draining, time.Now())
+	for _, name := range c.reported.Difference(current).List() {
+		metrics.DeleteDefragReport(name)
+	}
+	c.reported = current
+}
+
+// sync analyzes the pool of report, drains the nodes recommended if asked, and updates its status.
+func (c *Controller) sync(report *carrierv1alpha1.FleetDefragReport,
+	servers map[string][]*carrierv1alpha1.GameServer) error {
+	nodes, err := c.nodeLister.List(labels.SelectorFromSet(report.Spec.NodeSelector))
+	if err != nil {
+		return errors.Wrapf(err, "error listing nodes of FleetDefragReport %v", report.Name)
+	}
+	counts := make(map[string]int32)
+	nodesByName := make(map[string]*corev1.Node)
+	for _, node := range nodes {
+		counts[node.Name] = int32(len(servers[node.Name]))
+		nodesByName[node.Name] = node
+	}
+	status := analyze(&report.Spec, nodes, counts)
+	if report.Spec.Action == carrierv1alpha1.DefragDrain {
+		for i := range status.Recommendations {
+			node := nodesByName[status.Recommendations[i].NodeName]
+			status.Recommendations[i].Drained = c.drain(report, node, servers)
+		}
+	}
+	metrics.RecordDefragReport(report.Name, status.FragmentationPercent, status.ReclaimableNodes)
+	previous := report.Status.DeepCopy()
+	previous.LastAnalysisTime = nil
+	if apiequality.Semantic.DeepEqual(previous, status) {
+		return nil
+	}
+	now := metav1.Now()
+	status.LastAnalysisTime = &now
+	reportCopy := report.DeepCopy()
+	reportCopy.Status = *status
+	klog.V(4).Infof("FleetDefragReport %v: %+v", report.Name, status)
+	if _, err := c.carrierClient.CarrierV1alpha1().FleetDefragReports().UpdateStatus(reportCopy); err != nil {
+		return errors.Wrapf(err, "error updating status of FleetDefragReport %v", report.Name)
+	}
+	return nil
+}
+
+// drain cordons node and marks GameServers on it out of service, they are deleted as their
+// drain policy allows. GameServers are not marked if node fails to be cordoned, as their
+// replacements could be scheduled back onto it. Returns true if all of them are marked.
+func (c *Controller) drain(report *carrierv1alpha1.FleetDefragReport, node *corev1.Node,
+	servers map[string][]*carrierv1alpha1.GameServer) bool {
+	if err := kube.CordonNode(c.kubeClient, node, cordonOwnerPrefix+report.Name); err != nil {
+		utilruntime.HandleError(err)
+		return false
+	}
+	drained := true
+	for _, gs := range servers[node.Name] {
+		gsCopy := gs.DeepCopy()
+		gameservers.AddNotInServiceConstraint(gsCopy)
+		if _, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Update(gsCopy); err != nil {
+			utilruntime.HandleError(errors.Wrapf(err, "error draining GameServer %v/%v", gs.Namespace, gs.Name))
+			drained = false
+			continue
+		}
+		c.recorder.Eventf(gs, corev1.EventTypeNormal, "DefragDrain",
+			"Node %v of GameServer is drained to defragment the pool", node.Name)
+	}
+	return drained
+}
+
+// uncordon uncordons the nodes cordoned by FleetDefragReports which are not in draining,
+// because the reports are deleted or no longer drain, and the nodes cordoned longer than
+// the drain timeout of their reports at now. Nodes whose cordon time is not recorded are
+// regarded as timed out.
+func (c *Controller) uncordon(draining map[string]*carrierv1alpha1.FleetDefragReport, now time.Time) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing nodes"))
+		return
+	}
+	for _, node := range nodes {
+		owner := kube.CordonedBy(node)
+		if !strings.HasPrefix(owner, cordonOwnerPrefix) {
+			continue
+		}
+		report, ok := draining[owner]
+		if ok {
+			timeout := time.Duration(defaultDrainTimeoutSeconds) * time.Second
+			if report.Spec.DrainTimeoutSeconds != nil {
+				timeout = time.Duration(*report.Spec.DrainTimeoutSeconds) * time.Second
+			}
+			if cordonedAt, recorded := kube.CordonedAt(node); recorded && now.Before(cordonedAt.Add(timeout)) {
+				continue
+			}
+		}
+		klog.Infof("Uncordon node %v cordoned by %v", node.Name, owner)
+		if err := kube.UncordonNode(c.kubeClient, node, owner); err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		if ok {
+			c.recorder.Eventf(report, corev1.EventTypeWarning, "DrainTimeout",
+				"Uncordoned node %v drained but not removed within the drain timeout", node.Name)
+		}
+	}
+}
+
+// analyze returns the fragmentation of the pool of nodes, counts is the number of GameServers
+// in service on each node. Nodes are recommended emptiest first, as long as the unused
+// capacity of the other nodes running GameServers could hold a whole node.
+func analyze(spec *carrierv1alpha1.FleetDefragReportSpec, nodes []*corev1.Node,
+	counts map[string]int32) *carrierv1alpha1.FleetDefragReportStatus {
+	status := &carrierv1alpha1.FleetDefragReportStatus{Capacity: spec.Capacity}
+	var used []*corev1.Node
+	for _, node := range nodes {
+		if toBeDeleted(node) {
+			continue
+		}
+		status.Nodes++
+		count := counts[node.Name]
+		if count == 0 {
+			status.EmptyNodes++
+			continue
+		}
+		used = append(used, node)
+		status.GameServers += count
+		if spec.Capacity == 0 && count > status.Capacity {
+			status.Capacity = count
+		}
+	}
+	if len(used) == 0 || status.Capacity == 0 {
+		return status
+	}
+	total := int32(len(used)) * status.Capacity
+	unused := total - status.GameServers
+	if unused <= 0 {
+		return status
+	}
+	status.FragmentationPercent = unused * 100 / total
+	status.ReclaimableNodes = unused / status.Capacity
+	// emptying a node moves its GameServers to the unused capacity of the others, which
+	// reduces the unused capacity by exactly one node whichever node it is.
+	sort.Slice(used, func(i, j int) bool {
+		if counts[used[i].Name] != counts[used[j].Name] {
+			return counts[used[i].Name] < counts[used[j].Name]
+		}
+		return used[i].Name < used[j].Name
+	})
+	maxRecommendations := spec.MaxRecommendations
+	if maxRecommendations == 0 {
+		maxRecommendations = 1
+	}
+	for i := int32(0); i < status.ReclaimableNodes && i < maxRecommendations; i++ {
+		status.Recommendations = append(status.Recommendations, carrierv1alpha1.DefragRecommendation{
+			NodeName:    used[i].Name,
+			GameServers: counts[used[i].Name],
+		})
+	}
+	return status
+}
+
+// inService returns true if gs is scheduled and in service.
+func inService(gs *carrierv1alpha1.GameServer) bool {
+	return gs.DeletionTimestamp == nil && len(gs.Status.NodeName) != 0 && !gameservers.IsStopped(gs) &&
+		!gameservers.IsOutOfService(gs)
+}
+
+// toBeDeleted returns true if node is being removed by cluster autoscaler.
+func toBeDeleted(node *corev1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == gameservers.ToBeDeletedTaint {
+			return true
+		}
+	}
+	return false
+}
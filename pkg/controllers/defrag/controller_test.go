@@ -0,0 +1,194 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package defrag
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	gsfake "github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util/kube"
+)
+
+func newNode(name string, taints ...corev1.Taint) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"pool": "game"}},
+		Spec:       corev1.NodeSpec{Taints: taints},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	toBeDeleted := corev1.Taint{Key: gameservers.ToBeDeletedTaint, Effect: corev1.TaintEffectNoSchedule}
+	nodes := []*corev1.Node{newNode("node1"), newNode("node2"), newNode("node3"), newNode("node4"),
+		newNode("node5", toBeDeleted)}
+	counts := map[string]int32{"node1": 4, "node2": 1, "node3": 2, "node5": 3}
+	tests := []struct {
+		name    string
+		spec    carrierv1alpha1.FleetDefragReportSpec
+		desired carrierv1alpha1.FleetDefragReportStatus
+	}{
+		{
+			name: "capacity by the fullest node",
+			spec: carrierv1alpha1.FleetDefragReportSpec{},
+			desired: carrierv1alpha1.FleetDefragReportStatus{
+				Nodes:                4,
+				EmptyNodes:           1,
+				GameServers:          7,
+				Capacity:             4,
+				FragmentationPercent: 41,
+				ReclaimableNodes:     1,
+				Recommendations:      []carrierv1alpha1.DefragRecommendation{{NodeName: "node2", GameServers: 1}},
+			},
+		},
+		{
+			name: "capacity set and limited by max recommendations",
+			spec: carrierv1alpha1.FleetDefragReportSpec{Capacity: 10, MaxRecommendations: 1},
+			desired: carrierv1alpha1.FleetDefragReportStatus{
+				Nodes:                4,
+				EmptyNodes:           1,
+				GameServers:          7,
+				Capacity:             10,
+				FragmentationPercent: 76,
+				ReclaimableNodes:     2,
+				Recommendations:      []carrierv1alpha1.DefragRecommendation{{NodeName: "node2", GameServers: 1}},
+			},
+		},
+		{
+			name: "full",
+			spec: carrierv1alpha1.FleetDefragReportSpec{Capacity: 2, MaxRecommendations: 3},
+			desired: carrierv1alpha1.FleetDefragReportStatus{
+				Nodes:       4,
+				EmptyNodes:  1,
+				GameServers: 7,
+				Capacity:    2,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := analyze(&test.spec, nodes, counts)
+			if !reflect.DeepEqual(&test.desired, actual) {
+				t.Errorf("desired: %+v, get: %+v", test.desired, *actual)
+			}
+		})
+	}
+}
+
+func TestSyncDrain(t *testing.T) {
+	report := &carrierv1alpha1.FleetDefragReport{
+		ObjectMeta: metav1.ObjectMeta{Name: "pool"},
+		Spec: carrierv1alpha1.FleetDefragReportSpec{
+			NodeSelector: map[string]string{"pool": "game"},
+			Capacity:     4,
+			Action:       carrierv1alpha1.DefragDrain,
+		},
+	}
+	carrierClient := gsfake.NewSimpleClientset(report)
+	servers := make(map[string][]*carrierv1alpha1.GameServer)
+	for _, gs := range [][2]string{{"gs1", "node1"}, {"gs2", "node1"}, {"gs3", "node2"}} {
+		server := &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: gs[0], Namespace: "default"},
+			Status:     carrierv1alpha1.GameServerStatus{NodeName: gs[1], State: carrierv1alpha1.GameServerRunning},
+		}
+		carrierClient.Tracker().Add(server)
+		servers[gs[1]] = append(servers[gs[1]], server)
+	}
+	node1, node2 := newNode("node1"), newNode("node2")
+	kubeClient := kubefake.NewSimpleClientset(node1, node2)
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nodeIndexer.Add(node1)
+	nodeIndexer.Add(node2)
+	c := &Controller{
+		kubeClient:       kubeClient,
+		carrierClient:    carrierClient,
+		nodeLister:       corelisterv1.NewNodeLister(nodeIndexer),
+		gameServerLister: listerv1.NewGameServerLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		recorder:         record.NewFakeRecorder(10),
+		reported:         sets.NewString(),
+	}
+	if err := c.sync(report, servers); err != nil {
+		t.Fatal(err)
+	}
+	gs, err := carrierClient.CarrierV1alpha1().GameServers("default").Get("gs3", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gameservers.IsOutOfService(gs) {
+		t.Errorf("desired GameServer on the emptiest node drained")
+	}
+	gs, err = carrierClient.CarrierV1alpha1().GameServers("default").Get("gs1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gameservers.IsOutOfService(gs) {
+		t.Errorf("desired GameServer on other node in service")
+	}
+	actual, err := carrierClient.CarrierV1alpha1().FleetDefragReports().Get("pool", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	desired := []carrierv1alpha1.DefragRecommendation{{NodeName: "node2", GameServers: 1, Drained: true}}
+	if !reflect.DeepEqual(desired, actual.Status.Recommendations) {
+		t.Errorf("desired: %+v, get: %+v", desired, actual.Status.Recommendations)
+	}
+	cordoned, err := kubeClient.CoreV1().Nodes().Get("node2", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kube.CordonedBy(cordoned) != "FleetDefragReport/pool" {
+		t.Fatalf("desired drained node cordoned by the report, get: %+v", cordoned)
+	}
+	if n, _ := kubeClient.CoreV1().Nodes().Get("node1", metav1.GetOptions{}); n.Spec.Unschedulable {
+		t.Errorf("desired node not drained left schedulable")
+	}
+
+	// the report still drains within the drain timeout.
+	nodeIndexer.Update(cordoned)
+	draining := map[string]*carrierv1alpha1.FleetDefragReport{"FleetDefragReport/pool": report}
+	c.uncordon(draining, time.Now())
+	if n, _ := kubeClient.CoreV1().Nodes().Get("node2", metav1.GetOptions{}); !n.Spec.Unschedulable {
+		t.Errorf("desired drained node kept cordoned within the drain timeout, get: %+v", n)
+	}
+
+	// the node is not removed by cluster autoscaler within the drain timeout.
+	c.uncordon(draining, time.Now().Add(2*time.Hour))
+	if n, _ := kubeClient.CoreV1().Nodes().Get("node2", metav1.GetOptions{}); n.Spec.Unschedulable {
+		t.Errorf("desired node uncordoned after the drain timeout, get: %+v", n)
+	}
+
+	// the node drained again, while the report no longer drains.
+	uncordoned, _ := kubeClient.CoreV1().Nodes().Get("node2", metav1.GetOptions{})
+	if err := kube.CordonNode(kubeClient, uncordoned, "FleetDefragReport/pool"); err != nil {
+		t.Fatal(err)
+	}
+	cordoned, _ = kubeClient.CoreV1().Nodes().Get("node2", metav1.GetOptions{})
+	nodeIndexer.Update(cordoned)
+	c.uncordon(nil, time.Now())
+	if n, _ := kubeClient.CoreV1().Nodes().Get("node2", metav1.GetOptions{}); n.Spec.Unschedulable {
+		t.Errorf("desired node uncordoned once no report drains it, get: %+v", n)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/metrics"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+const (
+	// antiEntropyPeriod is the period GameServers and pods are cross checked.
+	antiEntropyPeriod = 10 * time.Minute
+	// antiEntropyGracePeriod is how long a pod or GameServer is ignored after created,
+	// the informers may not have observed the other side yet.
+	antiEntropyGracePeriod = time.Minute
+)
+
+// kinds of discrepancies between GameServers and pods.
+const (
+	// discrepancyOrphanPod is a pod whose controller GameServer is gone.
+	discrepancyOrphanPod = "OrphanPod"
+	// discrepancyUnownedPod is a game server pod without controller, e.g. the GameServer
+	// create was lost mid-flight or the pod survived its GameServer.
+	discrepancyUnownedPod = "UnownedPod"
+	// discrepancyMissingPod is a Running GameServer without pod.
+	discrepancyMissingPod = "MissingPod"
+	// discrepancyPortConflict is GameServers on the same node using the same host port.
+	discrepancyPortConflict = "PortConflict"
+)
+
+// discrepancy is a drift between GameServers and pods found by the anti-entropy audit.
+type discrepancy struct {
+	kind string
+	// gameServers are the GameServers involved, empty for orphan pods. For unowned
+	// pods it is the GameServer the pod could be adopted by, if any.
+	gameServers []*carrierv1alpha1.GameServer
+	// pod is the pod involved, nil for GameServers without pod.
+	pod     *corev1.Pod
+	message string
+}
+
+// auditGameServers cross checks GameServers against pods, which catches the drift after
+// apiserver hiccups or partial failures. Orphan and unowned pods are handled by the orphan
+// pod policy, GameServers without pod are synced again, port conflicts are reported.
+func (c *Controller) auditGameServers() {
+	gsList, err := c.gameServerLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing GameServers"))
+		return
+	}
+	requirement, err := labels.NewRequirement(util.GameServerPodLabelKey, selection.Exists, nil)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	pods, err := c.podLister.List(labels.NewSelector().Add(*requirement))
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing pods"))
+		return
+	}
+	found := findDiscrepancies(gsList, pods, time.Now())
+	klog.V(4).Infof("Anti-entropy audit checked %v GameServers and %v pods, found %v discrepancies",
+		len(gsList), len(pods), len(found))
+	for _, d := range found {
+		metrics.RecordGameServerDiscrepancy(d.kind, c.repair(d))
+	}
+}
+
+// repair repairs or reports the discrepancy, returns true if it is repaired.
+func (c *Controller) repair(d discrepancy) bool {
+	klog.Warningf("Anti-entropy audit found %v: %v", d.kind, d.message)
+	switch d.kind {
+	case discrepancyOrphanPod:
+		return c.handleOrphanPod(d.pod)
+	case discrepancyUnownedPod:
+		return c.handleUnownedPod(d)
+	case discrepancyMissingPod:
+		gs := d.gameServers[0]
+		c.recorder.Event(gs, corev1.EventTypeWarning, d.kind, d.message)
+		key, err := cache.MetaNamespaceKeyFunc(gs)
+		if err != nil {
+			return false
+		}
+		c.queue.Add(key)
+		return true
+	default:
+		for _, gs := range d.gameServers {
+			c.recorder.Event(gs, corev1.EventTypeWarning, d.kind, d.message)
+		}
+		return false
+	}
+}
+
+// findDiscrepancies returns the discrepancies between GameServers and their pods. Objects
+// created within antiEntropyGracePeriod are ignored.
+func findDiscrepancies(gsList []*carrierv1alpha1.GameServer, pods []*corev1.Pod,
+	now time.Time) []discrepancy {
+	var found []discrepancy
+	gameServers := make(map[string]*carrierv1alpha1.GameServer, len(gsList))
+	for _, gs := range gsList {
+		gameServers[gs.Namespace+"/"+gs.Name] = gs
+	}
+	podKeys := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		podKeys[pod.Namespace+"/"+pod.Name] = true
+		if pod.DeletionTimestamp != nil || now.Sub(pod.CreationTimestamp.Time) < antiEntropyGracePeriod {
+			continue
+		}
+		owner := metav1.GetControllerOf(pod)
+		if owner == nil {
+			d := discrepancy{
+				kind:    discrepancyUnownedPod,
+				pod:     pod,
+				message: fmt.Sprintf("pod %v/%v has no owner", pod.Namespace, pod.Name),
+			}
+			if gs := adoptionCandidate(pod, gameServers); gs != nil {
+				d.gameServers = []*carrierv1alpha1.GameServer{gs}
+			}
+			found = append(found, d)
+			continue
+		}
+		if owner.Kind != "GameServer" {
+			continue
+		}
+		if gs, ok := gameServers[pod.Namespace+"/"+owner.Name]; ok && gs.UID == owner.UID {
+			continue
+		}
+		found = append(found, discrepancy{
+			kind:    discrepancyOrphanPod,
+			pod:     pod,
+			message: fmt.Sprintf("pod %v/%v exists but its GameServer %v is gone", pod.Namespace, pod.Name, owner.Name),
+		})
+	}
+
+	// GameServers using host ports by node and port.
+	hostPorts := make(map[string][]*carrierv1alpha1.GameServer)
+	for _, gs := range gsList {
+		if IsBeingDeleted(gs) {
+			continue
+		}
+		if gs.Status.State == carrierv1alpha1.GameServerRunning && !podKeys[gs.Namespace+"/"+gs.Name] &&
+			now.Sub(gs.CreationTimestamp.Time) >= antiEntropyGracePeriod {
+			found = append(found, discrepancy{
+				kind:        discrepancyMissingPod,
+				gameServers: []*carrierv1alpha1.GameServer{gs},
+				message:     fmt.Sprintf("GameServer %v/%v is Running without pod", gs.Namespace, gs.Name),
+			})
+		}
+		if len(gs.Status.NodeName) == 0 {
+			continue
+		}
+		for _, port := range findPorts(gs) {
+			key := fmt.Sprintf("%v:%v", gs.Status.NodeName, port)
+			hostPorts[key] = append(hostPorts[key], gs)
+		}
+	}
+	var conflicts []string
+	for key, list := range hostPorts {
+		if len(list) > 1 {
+			conflicts = append(conflicts, key)
+		}
+	}
+	sort.Strings(conflicts)
+	for _, key := range conflicts {
+		list := hostPorts[key]
+		var names []string
+		for _, gs := range list {
+			names = append(names, gs.Namespace+"/"+gs.Name)
+		}
+		found = append(found, discrepancy{
+			kind:        discrepancyPortConflict,
+			gameServers: list,
+			message:     fmt.Sprintf("GameServers %v use the same host port %v", strings.Join(names, ", "), key),
+		})
+	}
+	return found
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestFindDiscrepancies(t *testing.T) {
+	now := time.Now()
+	old := metav1.NewTime(now.Add(-time.Hour))
+	newGameServer := func(name, node string, state carrierv1alpha1.GameServerState, port int32) *carrierv1alpha1.GameServer {
+		return &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name),
+				CreationTimestamp: old},
+			Spec: carrierv1alpha1.GameServerSpec{Ports: []carrierv1alpha1.GameServerPort{
+				{Name: "default", HostPort: &port}}},
+			Status: carrierv1alpha1.GameServerStatus{State: state, NodeName: node},
+		}
+	}
+	newPod := func(name string, owner types.UID, created metav1.Time) *corev1.Pod {
+		controller := true
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default",
+			CreationTimestamp: created, OwnerReferences: []metav1.OwnerReference{
+				{Kind: "GameServer", Name: name, UID: owner, Controller: &controller}}}}
+	}
+	for _, testCase := range []struct {
+		name        string
+		gameServers []*carrierv1alpha1.GameServer
+		pods        []*corev1.Pod
+		expect      []string
+	}{
+		{
+			name:        "consistent",
+			gameServers: []*carrierv1alpha1.GameServer{newGameServer("a", "n1", carrierv1alpha1.GameServerRunning, 7000)},
+			pods:        []*corev1.Pod{newPod("a", "a", old)},
+		},
+		{
+			name:   "orphan pod",
+			pods:   []*corev1.Pod{newPod("a", "a", old)},
+			expect: []string{discrepancyOrphanPod},
+		},
+		{
+			name:   "new pod in grace period",
+			pods:   []*corev1.Pod{newPod("a", "a", metav1.NewTime(now))},
+			expect: nil,
+		},
+		{
+			name:        "pod of recreated GameServer",
+			gameServers: []*carrierv1alpha1.GameServer{newGameServer("a", "n1", carrierv1alpha1.GameServerStarting, 7000)},
+			pods:        []*corev1.Pod{newPod("a", "old-uid", old)},
+			expect:      []string{discrepancyOrphanPod},
+		},
+		{
+			name: "unowned pod",
+			pods: []*corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default",
+				CreationTimestamp: old}}},
+			expect: []string{discrepancyUnownedPod},
+		},
+		{
+			name:        "running without pod",
+			gameServers: []*carrierv1alpha1.GameServer{newGameServer("a", "n1", carrierv1alpha1.GameServerRunning, 7000)},
+			expect:      []string{discrepancyMissingPod},
+		},
+		{
+			name: "port conflict",
+			gameServers: []*carrierv1alpha1.GameServer{
+				newGameServer("a", "n1", carrierv1alpha1.GameServerRunning, 7000),
+				newGameServer("b", "n1", carrierv1alpha1.GameServerRunning, 7000),
+				newGameServer("c", "n2", carrierv1alpha1.GameServerRunning, 7000),
+			},
+			pods:   []*corev1.Pod{newPod("a", "a", old), newPod("b", "b", old), newPod("c", "c", old)},
+			expect: []string{discrepancyPortConflict},
+		},
+	} {
+		found := findDiscrepancies(testCase.gameServers, testCase.pods, now)
+		var kinds []string
+		for _, d := range found {
+			kinds = append(kinds, d.kind)
+		}
+		if len(kinds) != len(testCase.expect) {
+			t.Errorf("%v: desired %v, get: %v", testCase.name, testCase.expect, kinds)
+			continue
+		}
+		for i := range kinds {
+			if kinds[i] != testCase.expect[i] {
+				t.Errorf("%v: desired %v, get: %v", testCase.name, testCase.expect, kinds)
+			}
+		}
+	}
+}
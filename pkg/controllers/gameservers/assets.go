@@ -0,0 +1,240 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/assetlock"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// AssetCachePolicy is the operator policy of asset caches on host path.
+type AssetCachePolicy struct {
+	// HostRoot is the host directory asset caches on host path must be under.
+	HostRoot string
+	// LockImage is the image of the asset lock container running /carrier-assetlock, which
+	// holds the node-level lease of assets before the asset container runs. Downloads on a
+	// node are not deduped if empty.
+	LockImage string
+}
+
+// Validate checks if the policy is valid.
+func (p *AssetCachePolicy) Validate() error {
+	if !path.IsAbs(p.HostRoot) || path.Clean(p.HostRoot) != p.HostRoot || p.HostRoot == "/" {
+		return errors.Errorf("host root %q must be a clean absolute path other than /", p.HostRoot)
+	}
+	return nil
+}
+
+// Allows checks if hostPath is under the host root.
+func (p *AssetCachePolicy) Allows(hostPath string) bool {
+	return util.IsSubPath(p.HostRoot, hostPath)
+}
+
+// injectAssetCache adds the asset cache volume and the asset container downloading into it
+// as the first init container. The volume is mounted in all containers, read only except in
+// the asset container.
+func injectAssetCache(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	cache := gs.Spec.AssetCache
+	if cache == nil {
+		return
+	}
+	volume := corev1.Volume{
+		Name:         util.AssetCacheVolumeName,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	}
+	if len(cache.HostPath) != 0 {
+		hostPathType := corev1.HostPathDirectoryOrCreate
+		volume.VolumeSource = corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{
+			Path: cache.HostPath,
+			Type: &hostPathType,
+		}}
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, volume)
+
+	dir := path.Join(cache.MountPath, cache.Key)
+	mount := corev1.VolumeMount{Name: util.AssetCacheVolumeName, MountPath: cache.MountPath, ReadOnly: true}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			containers[i].VolumeMounts = append(containers[i].VolumeMounts, mount)
+			containers[i].Env = append(containers[i].Env, corev1.EnvVar{Name: util.AssetDirEnv, Value: dir})
+		}
+	}
+
+	container := *cache.Container.DeepCopy()
+	container.Name = util.AssetContainerName
+	container.Env = append(container.Env, assetLeaseEnv(cache)...)
+	container.Env = append(container.Env, corev1.EnvVar{Name: util.AssetDirEnv, Value: dir})
+	mount.ReadOnly = false
+	container.VolumeMounts = append(container.VolumeMounts, mount)
+	pod.Spec.InitContainers = append([]corev1.Container{container}, pod.Spec.InitContainers...)
+}
+
+// injectAssetCachePolicy enforces the asset cache policy on pod. Caches on host path not under
+// the host root fall back to emptyDir, and the asset lock container is added before the asset
+// container of caches on host path, so the assets are downloaded once on a node.
+func (c *Controller) injectAssetCachePolicy(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	cache := gs.Spec.AssetCache
+	if cache == nil || len(cache.HostPath) == 0 {
+		return
+	}
+	if c.assetCache == nil || !c.assetCache.Allows(cache.HostPath) {
+		c.recorder.Eventf(gs, corev1.EventTypeWarning, string(gs.Status.State),
+			"Asset cache host path %v is not allowed, falling back to emptyDir", cache.HostPath)
+		for i := range pod.Spec.Volumes {
+			if pod.Spec.Volumes[i].Name == util.AssetCacheVolumeName {
+				pod.Spec.Volumes[i].VolumeSource = corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+			}
+		}
+		return
+	}
+	if len(c.assetCache.LockImage) == 0 {
+		return
+	}
+	lock := corev1.Container{
+		Name:    util.AssetLockContainerName,
+		Image:   c.assetCache.LockImage,
+		Command: []string{"/carrier-assetlock"},
+		Env: append(assetLeaseEnv(cache), corev1.EnvVar{Name: util.PodNamespaceEnv,
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"}}}),
+	}
+	pod.Spec.InitContainers = append([]corev1.Container{lock}, pod.Spec.InitContainers...)
+}
+
+// assetLeaseEnv returns the env exporting the node-level lease of assets and its holder.
+func assetLeaseEnv(cache *carrierv1alpha1.AssetCache) []corev1.EnvVar {
+	// CARRIER_NODE_NAME must be defined before it is referenced by CARRIER_ASSET_LEASE.
+	return []corev1.EnvVar{
+		{Name: util.NodeNameEnv, ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+		}},
+		{Name: util.PodNameEnv, ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+		}},
+		{Name: util.AssetLeaseEnv, Value: assetLeaseName(cache.Key)},
+	}
+}
+
+// assetLeaseName returns the name of lease deduping downloads of assets on a node, the
+// node name is expanded by kubelet.
+func assetLeaseName(key string) string {
+	return fmt.Sprintf("carrier-asset-$(%v)-%v", util.NodeNameEnv, key)
+}
+
+// releaseAssetLease releases the node-level lease of assets held by pod once its asset
+// container terminated, so the next pod on the node goes on without waiting for the expiry.
+func (c *Controller) releaseAssetLease(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	cache := gs.Spec.AssetCache
+	if cache == nil || len(pod.Spec.NodeName) == 0 || !hasInitContainer(pod, util.AssetLockContainerName) ||
+		!assetTerminated(pod) {
+		return
+	}
+	name := strings.Replace(assetLeaseName(cache.Key), fmt.Sprintf("$(%v)", util.NodeNameEnv), pod.Spec.NodeName, 1)
+	if err := assetlock.Release(c.kubeClient.CoordinationV1(), pod.Namespace, name, pod.Name); err != nil {
+		klog.Warningf("Failed to release asset lease %v/%v: %v", pod.Namespace, name, err)
+	}
+}
+
+// assetTerminated checks if the asset container of pod terminated, succeeded or not.
+func assetTerminated(pod *corev1.Pod) bool {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == util.AssetContainerName {
+			return status.State.Terminated != nil
+		}
+	}
+	return pod.Status.Phase == corev1.PodRunning || pod.Status.Phase == corev1.PodSucceeded
+}
+
+// hasInitContainer checks if pod has the init container name.
+func hasInitContainer(pod *corev1.Pod, name string) bool {
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileAssetReady sets the AssetReady condition of GameServer according to the
+// status of the asset container.
+func reconcileAssetReady(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	if gs.Spec.AssetCache == nil {
+		return
+	}
+	ready, message := assetReady(gs.Spec.AssetCache, pod)
+	status := carrierv1alpha1.ConditionFalse
+	if ready {
+		status = carrierv1alpha1.ConditionTrue
+	}
+	for _, condition := range gs.Status.Conditions {
+		if condition.Type == carrierv1alpha1.GameServerAssetReady {
+			setGameServerCondition(gs, carrierv1alpha1.GameServerAssetReady, status, message)
+			return
+		}
+	}
+	// setGameServerCondition does not add conditions which are False.
+	now := metav1.Now()
+	gs.Status.Conditions = append(gs.Status.Conditions, carrierv1alpha1.GameServerCondition{
+		Type:               carrierv1alpha1.GameServerAssetReady,
+		Status:             status,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+		Message:            message,
+	})
+}
+
+// assetReadyMessage returns the message of AssetReady condition of GameServer, which changes
+// with the status of the asset container.
+func assetReadyMessage(gs *carrierv1alpha1.GameServer) string {
+	for _, condition := range gs.Status.Conditions {
+		if condition.Type == carrierv1alpha1.GameServerAssetReady {
+			return condition.Message
+		}
+	}
+	return ""
+}
+
+// assetReady checks if the asset container has downloaded the assets, and returns the message
+// describing the status of the asset container.
+func assetReady(cache *carrierv1alpha1.AssetCache, pod *corev1.Pod) (bool, string) {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name != util.AssetContainerName {
+			continue
+		}
+		switch {
+		case status.State.Terminated != nil && status.State.Terminated.ExitCode == 0:
+			return true, fmt.Sprintf("Assets %v are ready", cache.Key)
+		case status.State.Running != nil:
+			return false, fmt.Sprintf("Downloading assets %v", cache.Key)
+		case status.State.Terminated != nil:
+			return false, fmt.Sprintf("Failed to download assets %v: %v", cache.Key, status.State.Terminated.Reason)
+		}
+		break
+	}
+	// init containers of a running pod must have succeeded, statuses may be missing.
+	if pod.Status.Phase == corev1.PodRunning || pod.Status.Phase == corev1.PodSucceeded {
+		return true, fmt.Sprintf("Assets %v are ready", cache.Key)
+	}
+	return false, fmt.Sprintf("Waiting to download assets %v", cache.Key)
+}
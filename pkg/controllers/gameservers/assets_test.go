@@ -0,0 +1,219 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestInjectAssetCache(t *testing.T) {
+	gs := &carrierv1alpha1.GameServer{
+		Spec: carrierv1alpha1.GameServerSpec{
+			AssetCache: &carrierv1alpha1.AssetCache{
+				Key:       "v2",
+				MountPath: "/assets",
+				HostPath:  "/var/cache/game",
+				Container: corev1.Container{Name: "download", Image: "downloader"},
+			},
+		},
+	}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init"}},
+		Containers:     []corev1.Container{{Name: util.GameServerContainerName}},
+	}}
+	injectAssetCache(gs, pod)
+	if len(pod.Spec.Volumes) != 1 || pod.Spec.Volumes[0].HostPath == nil ||
+		pod.Spec.Volumes[0].HostPath.Path != "/var/cache/game" {
+		t.Errorf("desired host path volume, get: %+v", pod.Spec.Volumes)
+	}
+	if len(pod.Spec.InitContainers) != 2 || pod.Spec.InitContainers[0].Name != util.AssetContainerName {
+		t.Fatalf("desired asset container first, get: %+v", pod.Spec.InitContainers)
+	}
+	env := make(map[string]corev1.EnvVar)
+	for _, e := range pod.Spec.InitContainers[0].Env {
+		env[e.Name] = e
+	}
+	if lease := env[util.AssetLeaseEnv].Value; lease != "carrier-asset-$(CARRIER_NODE_NAME)-v2" {
+		t.Errorf("desired lease name with node name, get: %v", lease)
+	}
+	if env[util.NodeNameEnv].ValueFrom == nil {
+		t.Errorf("desired node name from downward api")
+	}
+	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		if len(container.VolumeMounts) != 1 || container.VolumeMounts[0].MountPath != "/assets" {
+			t.Errorf("desired cache mounted in %v, get: %+v", container.Name, container.VolumeMounts)
+		}
+		if readOnly := container.Name != util.AssetContainerName; container.VolumeMounts[0].ReadOnly != readOnly {
+			t.Errorf("desired cache mounted read only %v in %v", readOnly, container.Name)
+		}
+		last := container.Env[len(container.Env)-1]
+		if last.Name != util.AssetDirEnv || last.Value != "/assets/v2" {
+			t.Errorf("desired asset dir in %v, get: %+v", container.Name, last)
+		}
+	}
+}
+
+func TestInjectAssetCachePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *AssetCachePolicy
+		hostPath bool
+		lock     bool
+	}{
+		{
+			name: "not allowed",
+		},
+		{
+			name:   "not under root",
+			policy: &AssetCachePolicy{HostRoot: "/var/cache/carrier", LockImage: "carrier"},
+		},
+		{
+			name:     "under root",
+			policy:   &AssetCachePolicy{HostRoot: "/var/cache"},
+			hostPath: true,
+		},
+		{
+			name:     "locked",
+			policy:   &AssetCachePolicy{HostRoot: "/var/cache", LockImage: "carrier"},
+			hostPath: true,
+			lock:     true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				Spec: carrierv1alpha1.GameServerSpec{
+					AssetCache: &carrierv1alpha1.AssetCache{
+						Key:       "v2",
+						MountPath: "/assets",
+						HostPath:  "/var/cache/game",
+						Container: corev1.Container{Image: "downloader"},
+					},
+				},
+			}
+			pod := &corev1.Pod{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: util.GameServerContainerName}},
+			}}
+			injectAssetCache(gs, pod)
+			c := &Controller{assetCache: tc.policy, recorder: record.NewFakeRecorder(10)}
+			c.injectAssetCachePolicy(gs, pod)
+			if hostPath := pod.Spec.Volumes[0].HostPath != nil; hostPath != tc.hostPath {
+				t.Errorf("desired host path %v, get: %+v", tc.hostPath, pod.Spec.Volumes[0])
+			}
+			if lock := hasInitContainer(pod, util.AssetLockContainerName); lock != tc.lock {
+				t.Errorf("desired asset lock container %v, get: %+v", tc.lock, pod.Spec.InitContainers)
+			}
+			if tc.lock && pod.Spec.InitContainers[1].Name != util.AssetContainerName {
+				t.Errorf("desired asset lock container before asset container, get: %+v", pod.Spec.InitContainers)
+			}
+		})
+	}
+}
+
+func TestReleaseAssetLease(t *testing.T) {
+	holder, seconds := "gs", int32(600)
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: "carrier-asset-node-1-v2", Namespace: "default"},
+		Spec:       coordinationv1.LeaseSpec{HolderIdentity: &holder, LeaseDurationSeconds: &seconds},
+	}
+	client := fake.NewSimpleClientset(lease)
+	c := &Controller{kubeClient: client}
+	gs := &carrierv1alpha1.GameServer{
+		Spec: carrierv1alpha1.GameServerSpec{AssetCache: &carrierv1alpha1.AssetCache{Key: "v2"}},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			NodeName:       "node-1",
+			InitContainers: []corev1.Container{{Name: util.AssetLockContainerName}, {Name: util.AssetContainerName}},
+		},
+		Status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{
+			{Name: util.AssetContainerName, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+		}},
+	}
+	c.releaseAssetLease(gs, pod)
+	if _, err := client.CoordinationV1().Leases("default").Get(lease.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("desired lease held while downloading, get: %v", err)
+	}
+	pod.Status.InitContainerStatuses[0].State = corev1.ContainerState{
+		Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"},
+	}
+	c.releaseAssetLease(gs, pod)
+	if _, err := client.CoordinationV1().Leases("default").Get(lease.Name, metav1.GetOptions{}); err == nil {
+		t.Errorf("desired lease released once downloaded")
+	}
+}
+
+func TestReconcileAssetReady(t *testing.T) {
+	tests := []struct {
+		name   string
+		state  corev1.ContainerState
+		phase  corev1.PodPhase
+		status carrierv1alpha1.ConditionStatus
+	}{
+		{
+			name:   "waiting",
+			state:  corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}},
+			phase:  corev1.PodPending,
+			status: carrierv1alpha1.ConditionFalse,
+		},
+		{
+			name:   "downloading",
+			state:  corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			phase:  corev1.PodPending,
+			status: carrierv1alpha1.ConditionFalse,
+		},
+		{
+			name:   "failed",
+			state:  corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}},
+			phase:  corev1.PodPending,
+			status: carrierv1alpha1.ConditionFalse,
+		},
+		{
+			name:   "downloaded",
+			state:  corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"}},
+			phase:  corev1.PodRunning,
+			status: carrierv1alpha1.ConditionTrue,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				Spec: carrierv1alpha1.GameServerSpec{
+					AssetCache: &carrierv1alpha1.AssetCache{Key: "v2"},
+				},
+			}
+			pod := &corev1.Pod{Status: corev1.PodStatus{
+				Phase: tc.phase,
+				InitContainerStatuses: []corev1.ContainerStatus{
+					{Name: util.AssetContainerName, State: tc.state},
+				},
+			}}
+			reconcileAssetReady(gs, pod)
+			if len(gs.Status.Conditions) != 1 || gs.Status.Conditions[0].Status != tc.status {
+				t.Errorf("desired AssetReady %v, get: %+v", tc.status, gs.Status.Conditions)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+const (
+	// defaultCertificateDuration is the validity of GameServer certificates if not specified.
+	defaultCertificateDuration = 24 * time.Hour
+	// certificateBackdate tolerates the clock skew between the controller and clients.
+	certificateBackdate = time.Minute
+	// tlsCAKey is the key of CA certificate in the Secret of GameServer certificate.
+	tlsCAKey = "ca.crt"
+)
+
+// CertificateAuthority mints the certificates of GameServers.
+type CertificateAuthority struct {
+	cert    *x509.Certificate
+	key     crypto.Signer
+	certPEM []byte
+	// dnsSuffixes are the domains extra DNS names of GameServers must be under.
+	dnsSuffixes []string
+}
+
+// NewCertificateAuthority returns a CertificateAuthority from the PEM encoded certificate and key,
+// extra DNS names of GameServers are only included if they are under one of dnsSuffixes.
+func NewCertificateAuthority(certPEM, keyPEM []byte, dnsSuffixes []string) (*CertificateAuthority, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid CA certificate or key")
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid CA certificate")
+	}
+	if !cert.IsCA {
+		return nil, errors.New("certificate is not a CA")
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("CA key can not sign")
+	}
+	return &CertificateAuthority{cert: cert, key: key, certPEM: certPEM, dnsSuffixes: dnsSuffixes}, nil
+}
+
+// LoadCertificateAuthority returns a CertificateAuthority from the PEM encoded certificate and key files.
+func LoadCertificateAuthority(certFile, keyFile string, dnsSuffixes []string) (*CertificateAuthority, error) {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewCertificateAuthority(certPEM, keyPEM, dnsSuffixes)
+}
+
+// mint returns the PEM encoded certificate and key for the SANs, valid for duration.
+func (ca *CertificateAuthority) mint(commonName string, dnsNames []string, ips []net.IP,
+	duration time.Duration) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+		NotBefore:    now.Add(-certificateBackdate),
+		NotAfter:     now.Add(duration),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// syncTLSSecret mints the certificate of GameServer into its Secret, and rotates it
+// before expiry, or once the SANs or CA change.
+func (c *Controller) syncTLSSecret(key string, gs *carrierv1alpha1.GameServer) error {
+	if gs.Spec.TLS == nil || gs.DeletionTimestamp != nil {
+		return nil
+	}
+	if c.ca == nil {
+		c.recorder.Event(gs, corev1.EventTypeWarning, string(gs.Status.State),
+			"TLS is required but no CA is configured for the controller")
+		return nil
+	}
+	dnsNames, ips := certificateSANs(gs, c.ca.dnsSuffixes)
+	name := tlsSecretName(gs)
+	secret, err := c.kubeClient.CoreV1().Secrets(gs.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "error retrieving TLS secret %s", name)
+	}
+	now := time.Now()
+	if err == nil {
+		if !metav1.IsControlledBy(secret, gs) {
+			// never overwrite a Secret not minted for this GameServer.
+			c.recorder.Eventf(gs, corev1.EventTypeWarning, "TLSSecretConflict",
+				"Secret %v exists and is not owned by GameServer, certificate is not issued", name)
+			return nil
+		}
+		if renewAt, ok := c.certificateRenewal(secret, dnsNames, ips); ok && renewAt.After(now) {
+			c.queue.AddAfter(key, renewAt.Sub(now))
+			return nil
+		}
+	}
+	duration := defaultCertificateDuration
+	if gs.Spec.TLS.Duration != nil {
+		duration = gs.Spec.TLS.Duration.Duration
+	}
+	certPEM, keyPEM, mintErr := c.ca.mint(gs.Name, dnsNames, ips, duration)
+	if mintErr != nil {
+		return errors.Wrapf(mintErr, "error minting certificate for GameServer %s", gs.Name)
+	}
+	data := map[string][]byte{
+		corev1.TLSCertKey:       certPEM,
+		corev1.TLSPrivateKeyKey: keyPEM,
+		tlsCAKey:                c.ca.certPEM,
+	}
+	if k8serrors.IsNotFound(err) {
+		_, err = c.kubeClient.CoreV1().Secrets(gs.Namespace).Create(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: gs.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(gs, carrierv1alpha1.SchemeGroupVersion.WithKind("GameServer")),
+				},
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: data,
+		})
+	} else {
+		secret = secret.DeepCopy()
+		secret.Data = data
+		_, err = c.kubeClient.CoreV1().Secrets(gs.Namespace).Update(secret)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error writing TLS secret %s", name)
+	}
+	c.recorder.Eventf(gs, corev1.EventTypeNormal, string(gs.Status.State),
+		"Issued certificate for %v %v, valid for %v", dnsNames, ips, duration)
+	c.queue.AddAfter(key, renewalAfter(duration))
+	return nil
+}
+
+// certificateRenewal returns when the certificate in secret should be renewed, ok is
+// false if it should be renewed now as it is invalid, or its SANs or CA differ.
+func (c *Controller) certificateRenewal(secret *corev1.Secret, dnsNames []string,
+	ips []net.IP) (time.Time, bool) {
+	if !bytes.Equal(secret.Data[tlsCAKey], c.ca.certPEM) {
+		return time.Time{}, false
+	}
+	block, _ := pem.Decode(secret.Data[corev1.TLSCertKey])
+	if block == nil {
+		return time.Time{}, false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if !reflect.DeepEqual(cert.DNSNames, dnsNames) || !equalIPs(cert.IPAddresses, ips) {
+		return time.Time{}, false
+	}
+	return cert.NotBefore.Add(renewalAfter(cert.NotAfter.Sub(cert.NotBefore))), true
+}
+
+// renewalAfter returns how long after issued a certificate valid for duration is renewed.
+func renewalAfter(duration time.Duration) time.Duration {
+	return duration * 2 / 3
+}
+
+// certificateSANs returns the DNS names and IPs of GameServer certificate, extra DNS names
+// not under dnsSuffixes are dropped. The IPs are the pod IP and ingress IPs of load balancer
+// once known.
+func certificateSANs(gs *carrierv1alpha1.GameServer, dnsSuffixes []string) ([]string, []net.IP) {
+	dnsNames := []string{gs.Name, gs.Name + "." + gs.Namespace}
+	for _, name := range gs.Spec.TLS.DNSNames {
+		if util.HasDNSSuffix(name, dnsSuffixes) {
+			dnsNames = append(dnsNames, name)
+		}
+	}
+	var candidates []string
+	if len(gs.Status.Address) != 0 {
+		candidates = append(candidates, gs.Status.Address)
+	}
+	if gs.Status.LoadBalancerStatus != nil {
+		for _, ingress := range gs.Status.LoadBalancerStatus.Ingress {
+			candidates = append(candidates, ingress.IP)
+		}
+	}
+	var ips []net.IP
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		ip := net.ParseIP(candidate)
+		if ip == nil || seen[ip.String()] {
+			continue
+		}
+		seen[ip.String()] = true
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		return bytes.Compare(ips[i].To16(), ips[j].To16()) < 0
+	})
+	return dnsNames, ips
+}
+
+// equalIPs checks if the IPs are the same in order.
+func equalIPs(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// tlsSecretName returns the name of Secret holding the certificate of GameServer.
+func tlsSecretName(gs *carrierv1alpha1.GameServer) string {
+	return gs.Name + "-tls"
+}
+
+// injectTLS mounts the Secret of GameServer certificate in all containers.
+func injectTLS(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	if gs.Spec.TLS == nil {
+		return
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name: util.TLSVolumeName,
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+			SecretName: tlsSecretName(gs),
+		}},
+	})
+	mount := corev1.VolumeMount{Name: util.TLSVolumeName, MountPath: gs.Spec.TLS.MountPath, ReadOnly: true}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			containers[i].VolumeMounts = append(containers[i].VolumeMounts, mount)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func newTestCA(t *testing.T) *CertificateAuthority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "carrier-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := NewCertificateAuthority(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), []string{"example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+func TestCertificateSANs(t *testing.T) {
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default"},
+		Spec: carrierv1alpha1.GameServerSpec{
+			TLS: &carrierv1alpha1.GameServerTLS{DNSNames: []string{"game.example.com", "game.example.org"}},
+		},
+		Status: carrierv1alpha1.GameServerStatus{
+			Address: "10.0.0.2",
+			LoadBalancerStatus: &carrierv1alpha1.LoadBalancerStatus{Ingress: []carrierv1alpha1.LoadBalancerIngress{
+				{IP: "node-1"}, {IP: "1.2.3.4"}, {IP: "10.0.0.2"},
+			}},
+		},
+	}
+	dnsNames, ips := certificateSANs(gs, []string{"example.com"})
+	expectNames := []string{"gs", "gs.default", "game.example.com"}
+	if len(dnsNames) != len(expectNames) {
+		t.Fatalf("desired dns names: %v, get: %v", expectNames, dnsNames)
+	}
+	for i := range dnsNames {
+		if dnsNames[i] != expectNames[i] {
+			t.Errorf("desired dns names: %v, get: %v", expectNames, dnsNames)
+		}
+	}
+	expectIPs := []net.IP{net.ParseIP("1.2.3.4"), net.ParseIP("10.0.0.2")}
+	if !equalIPs(ips, expectIPs) {
+		t.Errorf("desired ips: %v, get: %v", expectIPs, ips)
+	}
+}
+
+func TestSyncTLSSecret(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, _, _, c, fakeClient := fakeController(ctx)
+	c.queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer c.queue.ShutDown()
+	c.ca = newTestCA(t)
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default", UID: "123"},
+		Spec: carrierv1alpha1.GameServerSpec{
+			TLS: &carrierv1alpha1.GameServerTLS{MountPath: "/etc/tls"},
+		},
+	}
+	if err := c.syncTLSSecret("default/gs", gs); err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	secret, err := fakeClient.CoreV1().Secrets("default").Get(tlsSecretName(gs), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("desired secret created, get: %v", err)
+	}
+	block, _ := pem.Decode(secret.Data[corev1.TLSCertKey])
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(c.ca.cert)
+	if _, err = cert.Verify(x509.VerifyOptions{DNSName: "gs.default", Roots: roots}); err != nil {
+		t.Errorf("desired certificate verified, get: %v", err)
+	}
+	dnsNames, ips := certificateSANs(gs, c.ca.dnsSuffixes)
+	if _, ok := c.certificateRenewal(secret, dnsNames, ips); !ok {
+		t.Errorf("desired certificate not renewed")
+	}
+
+	// the certificate is reissued once the pod ip is known.
+	gs.Status.Address = "10.0.0.2"
+	if err = c.syncTLSSecret("default/gs", gs); err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	secret, _ = fakeClient.CoreV1().Secrets("default").Get(tlsSecretName(gs), metav1.GetOptions{})
+	block, _ = pem.Decode(secret.Data[corev1.TLSCertKey])
+	cert, _ = x509.ParseCertificate(block.Bytes)
+	if len(cert.IPAddresses) != 1 || !cert.IPAddresses[0].Equal(net.ParseIP("10.0.0.2")) {
+		t.Errorf("desired ip 10.0.0.2, get: %v", cert.IPAddresses)
+	}
+}
+
+func TestSyncTLSSecretNotOwned(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, _, _, c, fakeClient := fakeController(ctx)
+	c.ca = newTestCA(t)
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default", UID: "123"},
+		Spec: carrierv1alpha1.GameServerSpec{
+			TLS: &carrierv1alpha1.GameServerTLS{MountPath: "/etc/tls"},
+		},
+	}
+	existing := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: tlsSecretName(gs), Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}
+	if _, err := fakeClient.CoreV1().Secrets("default").Create(existing); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.syncTLSSecret("default/gs", gs); err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	secret, _ := fakeClient.CoreV1().Secrets("default").Get(tlsSecretName(gs), metav1.GetOptions{})
+	if !reflect.DeepEqual(secret.Data, existing.Data) {
+		t.Errorf("desired secret not owned untouched, get: %v", secret.Data)
+	}
+}
+
+func TestInjectTLS(t *testing.T) {
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "gs"},
+		Spec: carrierv1alpha1.GameServerSpec{
+			TLS: &carrierv1alpha1.GameServerTLS{MountPath: "/etc/tls"},
+		},
+	}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "server"}}}}
+	injectTLS(gs, pod)
+	if len(pod.Spec.Volumes) != 1 || pod.Spec.Volumes[0].Secret.SecretName != "gs-tls" {
+		t.Errorf("desired secret volume gs-tls, get: %+v", pod.Spec.Volumes)
+	}
+	mounts := pod.Spec.Containers[0].VolumeMounts
+	if len(mounts) != 1 || mounts[0].MountPath != "/etc/tls" || !mounts[0].ReadOnly {
+		t.Errorf("desired read only mount at /etc/tls, get: %+v", mounts)
+	}
+}
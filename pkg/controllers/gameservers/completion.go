@@ -0,0 +1,37 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+// applyCompletionPolicy restarts the containers of OneShot GameServer pods only on failure
+// if the restart policy is not specified, otherwise the game server exited successfully
+// would be restarted instead of completed.
+func applyCompletionPolicy(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	if IsOneShot(gs) && len(pod.Spec.RestartPolicy) == 0 {
+		pod.Spec.RestartPolicy = corev1.RestartPolicyOnFailure
+	}
+}
+
+// isCompletedContainer returns true if the game server container of OneShot GameServer
+// exited successfully and will not be restarted, while sidecars may still run.
+func isCompletedContainer(gs *carrierv1alpha1.GameServer, pod *corev1.Pod,
+	terminated *corev1.ContainerStateTerminated) bool {
+	return IsOneShot(gs) && terminated.ExitCode == 0 && pod.Spec.RestartPolicy != corev1.RestartPolicyAlways
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestApplyCompletionPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        carrierv1alpha1.CompletionPolicy
+		restartPolicy corev1.RestartPolicy
+		desired       corev1.RestartPolicy
+	}{
+		{name: "replace", policy: carrierv1alpha1.ReplaceOnCompletion, desired: ""},
+		{name: "one shot", policy: carrierv1alpha1.OneShot, desired: corev1.RestartPolicyOnFailure},
+		{name: "one shot never restart", policy: carrierv1alpha1.OneShot, restartPolicy: corev1.RestartPolicyNever,
+			desired: corev1.RestartPolicyNever},
+	}
+	for _, test := range tests {
+		gs := &carrierv1alpha1.GameServer{Spec: carrierv1alpha1.GameServerSpec{CompletionPolicy: test.policy}}
+		pod := &corev1.Pod{Spec: corev1.PodSpec{RestartPolicy: test.restartPolicy}}
+		applyCompletionPolicy(gs, pod)
+		if pod.Spec.RestartPolicy != test.desired {
+			t.Errorf("%v: desired restart policy %q, get: %q", test.name, test.desired, pod.Spec.RestartPolicy)
+		}
+	}
+}
+
+func TestReconcileGameServerStateCompleted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, _, _, c, _ := fakeController(ctx)
+	terminated := func(exitCode int32) []corev1.ContainerStatus {
+		return []corev1.ContainerStatus{{
+			Name:  util.GameServerContainerName,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}},
+		}}
+	}
+	tests := []struct {
+		name     string
+		policy   carrierv1alpha1.CompletionPolicy
+		phase    corev1.PodPhase
+		statuses []corev1.ContainerStatus
+		desired  carrierv1alpha1.GameServerState
+	}{
+		{name: "replace succeeded", phase: corev1.PodSucceeded, desired: carrierv1alpha1.GameServerExited},
+		{name: "one shot succeeded", policy: carrierv1alpha1.OneShot, phase: corev1.PodSucceeded,
+			desired: carrierv1alpha1.GameServerCompleted},
+		{name: "one shot failed", policy: carrierv1alpha1.OneShot, phase: corev1.PodFailed,
+			desired: carrierv1alpha1.GameServerFailed},
+		{name: "one shot exited with sidecars", policy: carrierv1alpha1.OneShot, phase: corev1.PodRunning,
+			statuses: terminated(0), desired: carrierv1alpha1.GameServerCompleted},
+		{name: "one shot crashed with sidecars", policy: carrierv1alpha1.OneShot, phase: corev1.PodRunning,
+			statuses: terminated(1), desired: carrierv1alpha1.GameServerRunning},
+	}
+	for _, test := range tests {
+		gs := &carrierv1alpha1.GameServer{Spec: carrierv1alpha1.GameServerSpec{CompletionPolicy: test.policy}}
+		pod := &corev1.Pod{
+			Spec:   corev1.PodSpec{RestartPolicy: corev1.RestartPolicyOnFailure},
+			Status: corev1.PodStatus{Phase: test.phase, ContainerStatuses: test.statuses},
+		}
+		c.reconcileGameServerState(gs, pod, &corev1.Node{})
+		if gs.Status.State != test.desired {
+			t.Errorf("%v: desired state %v, get: %v", test.name, test.desired, gs.Status.State)
+		}
+	}
+}
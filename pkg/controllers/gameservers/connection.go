@@ -0,0 +1,133 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"reflect"
+	"strconv"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+const (
+	// connectionAddressKey is the key of the address clients connect to in the connection Secret.
+	connectionAddressKey = "address"
+	// connectionTokenKey is the key of the token in the connection Secret, which is generated
+	// once for the Secret, and could be checked by the game server mounting the same Secret.
+	connectionTokenKey = "token"
+	// connectionPortKeyPrefix prefixes the keys of ports in the connection Secret, e.g. "port.game".
+	connectionPortKeyPrefix = "port."
+	// connectionSecretConflict is the reason of the event warning the connection Secret is not
+	// owned by GameServer.
+	connectionSecretConflict = "ConnectionSecretConflict"
+)
+
+// syncConnectionSecret publishes the connection info of a running GameServer into the Secret
+// named by its ConnectionSecretAnnotation, so legacy backends could consume it by mounting the
+// Secret. The Secret is owned by the GameServer and deleted with it, an existing Secret not
+// owned by the GameServer is never overwritten, which is warned once until resolved.
+func (c *Controller) syncConnectionSecret(gs *carrierv1alpha1.GameServer) error {
+	name := gs.Annotations[util.ConnectionSecretAnnotation]
+	if len(name) == 0 || gs.DeletionTimestamp != nil || gs.Status.State != carrierv1alpha1.GameServerRunning {
+		return nil
+	}
+	data := connectionData(gs)
+	if data == nil {
+		return nil
+	}
+	secret, err := c.secretLister.Secrets(gs.Namespace).Get(name)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "error retrieving connection secret %s", name)
+	}
+	if err == nil {
+		if !metav1.IsControlledBy(secret, gs) {
+			c.events.Eventf(c.recorder, gs, corev1.EventTypeWarning, connectionSecretConflict,
+				"Connection secret %v exists and is not owned by GameServer", name)
+			return nil
+		}
+		c.events.Resolve(gs.UID, connectionSecretConflict)
+		data[connectionTokenKey] = secret.Data[connectionTokenKey]
+		if reflect.DeepEqual(secret.Data, data) {
+			return nil
+		}
+		secret = secret.DeepCopy()
+		secret.Data = data
+		if _, err = c.kubeClient.CoreV1().Secrets(gs.Namespace).Update(secret); err != nil {
+			return errors.Wrapf(err, "error writing connection secret %s", name)
+		}
+		return nil
+	}
+	token := make([]byte, 16)
+	if _, err = rand.Read(token); err != nil {
+		return errors.Wrap(err, "error generating connection token")
+	}
+	data[connectionTokenKey] = []byte(hex.EncodeToString(token))
+	_, err = c.kubeClient.CoreV1().Secrets(gs.Namespace).Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: gs.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(gs, carrierv1alpha1.SchemeGroupVersion.WithKind("GameServer")),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error writing connection secret %s", name)
+	}
+	c.events.Resolve(gs.UID, connectionSecretConflict)
+	c.recorder.Eventf(gs, corev1.EventTypeNormal, string(gs.Status.State),
+		"Published connection info into secret %v", name)
+	return nil
+}
+
+// connectionData returns the address and ports clients connect to, the load balancer
+// ingress takes precedence over the GameServer address and host ports. Nil is returned
+// if GameServer has no address yet.
+func connectionData(gs *carrierv1alpha1.GameServer) map[string][]byte {
+	data := map[string][]byte{}
+	if lb := gs.Status.LoadBalancerStatus; lb != nil && len(lb.Ingress) != 0 {
+		ingress := lb.Ingress[0]
+		data[connectionAddressKey] = []byte(ingress.IP)
+		if len(lb.Domain) != 0 {
+			data[connectionAddressKey] = []byte(lb.Domain)
+		}
+		for _, port := range ingress.Ports {
+			if port.ExternalPort != nil {
+				data[connectionPortKeyPrefix+port.Name] = []byte(strconv.Itoa(int(*port.ExternalPort)))
+			}
+		}
+		return data
+	}
+	if len(gs.Status.Address) == 0 {
+		return nil
+	}
+	data[connectionAddressKey] = []byte(gs.Status.Address)
+	for _, port := range gs.Spec.Ports {
+		if port.HostPort != nil {
+			data[connectionPortKeyPrefix+port.Name] = []byte(strconv.Itoa(int(*port.HostPort)))
+		}
+	}
+	return data
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestConnectionData(t *testing.T) {
+	hostPort, externalPort := int32(30001), int32(40001)
+	tests := []struct {
+		name    string
+		status  carrierv1alpha1.GameServerStatus
+		desired map[string][]byte
+	}{
+		{
+			name:    "no address",
+			desired: nil,
+		},
+		{
+			name:    "host port",
+			status:  carrierv1alpha1.GameServerStatus{Address: "10.0.0.2"},
+			desired: map[string][]byte{"address": []byte("10.0.0.2"), "port.game": []byte("30001")},
+		},
+		{
+			name: "load balancer",
+			status: carrierv1alpha1.GameServerStatus{
+				Address: "10.0.0.2",
+				LoadBalancerStatus: &carrierv1alpha1.LoadBalancerStatus{Ingress: []carrierv1alpha1.LoadBalancerIngress{
+					{IP: "1.2.3.4", Ports: []carrierv1alpha1.LoadBalancerPort{{Name: "game", ExternalPort: &externalPort}}},
+				}},
+			},
+			desired: map[string][]byte{"address": []byte("1.2.3.4"), "port.game": []byte("40001")},
+		},
+	}
+	for _, test := range tests {
+		gs := &carrierv1alpha1.GameServer{
+			Spec:   carrierv1alpha1.GameServerSpec{Ports: []carrierv1alpha1.GameServerPort{{Name: "game", HostPort: &hostPort}}},
+			Status: test.status,
+		}
+		if data := connectionData(gs); !reflect.DeepEqual(data, test.desired) {
+			t.Errorf("%v: desired %v, get: %v", test.name, test.desired, data)
+		}
+	}
+}
+
+func TestSyncConnectionSecret(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, _, _, c, fakeClient := fakeController(ctx)
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default", UID: "123",
+			Annotations: map[string]string{util.ConnectionSecretAnnotation: "match-1"}},
+		Status: carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning, Address: "10.0.0.2"},
+	}
+	if err := c.syncConnectionSecret(gs); err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	secret, err := fakeClient.CoreV1().Secrets("default").Get("match-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("desired secret created, get: %v", err)
+	}
+	token := string(secret.Data[connectionTokenKey])
+	if string(secret.Data[connectionAddressKey]) != "10.0.0.2" || len(token) == 0 || !metav1.IsControlledBy(secret, gs) {
+		t.Errorf("desired secret of 10.0.0.2 with token owned by gs, get: %+v", secret)
+	}
+	waitForSecretSynced(t, c, fakeClient, "match-1")
+
+	// the token is kept when the address changes.
+	gs.Status.Address = "10.0.0.3"
+	if err = c.syncConnectionSecret(gs); err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	secret, _ = fakeClient.CoreV1().Secrets("default").Get("match-1", metav1.GetOptions{})
+	if string(secret.Data[connectionAddressKey]) != "10.0.0.3" || string(secret.Data[connectionTokenKey]) != token {
+		t.Errorf("desired address 10.0.0.3 and token %v, get: %v", token, secret.Data)
+	}
+
+	// secrets not owned by the GameServer are not overwritten.
+	foreign := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foreign", Namespace: "default"}}
+	if _, err = fakeClient.CoreV1().Secrets("default").Create(foreign); err != nil {
+		t.Fatal(err)
+	}
+	waitForSecretSynced(t, c, fakeClient, "foreign")
+	gs.Annotations[util.ConnectionSecretAnnotation] = "foreign"
+	if err = c.syncConnectionSecret(gs); err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	secret, _ = fakeClient.CoreV1().Secrets("default").Get("foreign", metav1.GetOptions{})
+	if len(secret.Data) != 0 {
+		t.Errorf("desired foreign secret not overwritten, get: %v", secret.Data)
+	}
+}
+
+// waitForSecretSynced waits until the secret lister of c observes the secret of name in client.
+func waitForSecretSynced(t *testing.T, c *Controller, client *fake.Clientset, name string) {
+	secret, err := client.CoreV1().Secrets("default").Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		cached, err := c.secretLister.Secrets("default").Get(name)
+		return err == nil && reflect.DeepEqual(cached.Data, secret.Data), nil
+	})
+	if err != nil {
+		t.Fatalf("secret %v not synced: %v", name, err)
+	}
+}
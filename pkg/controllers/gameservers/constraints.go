@@ -0,0 +1,79 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+// expireConstraints splits the constraints of gs into the ones kept and the ones expired at now.
+// next is when the earliest constraint kept expires, zero if none of them expires.
+func expireConstraints(gs *carrierv1alpha1.GameServer, now time.Time) (kept,
+	expired []carrierv1alpha1.Constraint, next time.Time) {
+	for _, constraint := range gs.Spec.Constraints {
+		if constraint.ExpiresAt == nil {
+			kept = append(kept, constraint)
+			continue
+		}
+		if !now.Before(constraint.ExpiresAt.Time) {
+			expired = append(expired, constraint)
+			continue
+		}
+		kept = append(kept, constraint)
+		if next.IsZero() || constraint.ExpiresAt.Time.Before(next) {
+			next = constraint.ExpiresAt.Time
+		}
+	}
+	return kept, expired, next
+}
+
+// syncConstraintExpiry removes the expired constraints of GameServer, so a GameServer marked
+// e.g. NotInService for a temporary maintenance returns to service without another patch.
+// GameServer with constraints to expire is requeued to be checked again when they expire.
+func (c *Controller) syncConstraintExpiry(key string, gs *carrierv1alpha1.GameServer) (*carrierv1alpha1.GameServer,
+	error) {
+	if gs.DeletionTimestamp != nil {
+		return gs, nil
+	}
+	now := time.Now()
+	kept, expired, next := expireConstraints(gs, now)
+	if !next.IsZero() {
+		c.queue.AddAfter(key, next.Sub(now))
+	}
+	if len(expired) == 0 {
+		return gs, nil
+	}
+	types := make([]string, 0, len(expired))
+	for _, constraint := range expired {
+		types = append(types, string(constraint.Type))
+	}
+	gsCopy := gs.DeepCopy()
+	gsCopy.Spec.Constraints = kept
+	updated, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Update(gsCopy)
+	if err != nil {
+		return gs, errors.Wrapf(err, "failed to remove expired constraints of GameServer %v", key)
+	}
+	klog.Infof("Removed expired constraints %v of GameServer %v", types, key)
+	c.recorder.Eventf(gs, corev1.EventTypeNormal, "ConstraintExpired", "Constraints %v expired and removed",
+		strings.Join(types, ", "))
+	return updated, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestExpireConstraints(t *testing.T) {
+	now := time.Now()
+	past := metav1.NewTime(now.Add(-time.Minute))
+	soon := metav1.NewTime(now.Add(time.Minute))
+	later := metav1.NewTime(now.Add(time.Hour))
+	gs := &carrierv1alpha1.GameServer{Spec: carrierv1alpha1.GameServerSpec{Constraints: []carrierv1alpha1.Constraint{
+		{Type: carrierv1alpha1.NotInService, ExpiresAt: &past},
+		{Type: carrierv1alpha1.Cordoned, ExpiresAt: &later},
+		{Type: carrierv1alpha1.NoNewSessions, ExpiresAt: &soon},
+		{Type: "example.com/Maintenance"},
+	}}}
+	kept, expired, next := expireConstraints(gs, now)
+	if len(expired) != 1 || expired[0].Type != carrierv1alpha1.NotInService {
+		t.Errorf("desired NotInService expired, get: %v", expired)
+	}
+	if len(kept) != 3 {
+		t.Errorf("desired 3 constraints kept, get: %v", kept)
+	}
+	if !next.Equal(soon.Time) {
+		t.Errorf("desired next expiry %v, get: %v", soon, next)
+	}
+}
+
+func TestSyncConstraintExpiry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, _, _, c, _ := fakeController(ctx)
+	c.queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer c.queue.ShutDown()
+	gs, err := c.carrierClient.CarrierV1alpha1().GameServers("default").Get("test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	effective := true
+	expired := metav1.NewTime(time.Now().Add(-time.Second))
+	gs.Spec.Constraints = []carrierv1alpha1.Constraint{
+		{Type: carrierv1alpha1.NotInService, Effective: &effective, ExpiresAt: &expired},
+	}
+	if gs, err = c.syncConstraintExpiry("default/test", gs); err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	if IsOutOfService(gs) || len(gs.Spec.Constraints) != 0 {
+		t.Errorf("desired expired constraint removed, get: %+v", gs.Spec.Constraints)
+	}
+}
@@ -40,12 +40,24 @@ import (
 
 	"github.com/ocgi/carrier/pkg/apis/carrier"
 	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/builder"
 	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
 	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
 	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/metrics"
 	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/util/kube"
 )
 
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers/status,verbs=update
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;create;delete
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;delete
+
 // Controller is a the main GameServer crd controller
 type Controller struct {
 	podLister          corelisterv1.PodLister
@@ -54,26 +66,60 @@ type Controller struct {
 	gameServerSynced   cache.InformerSynced
 	nodeLister         corelisterv1.NodeLister
 	nodeSynced         cache.InformerSynced
+	secretLister       corelisterv1.SecretLister
+	secretSynced       cache.InformerSynced
 	queue              workqueue.RateLimitingInterface
 	nodeTaintWorkQueue workqueue.RateLimitingInterface // handles node autoscaler taint only
 	kubeClient         kubernetes.Interface
 	carrierClient      versioned.Interface
 	recorder           record.EventRecorder
 	portAllocator      Allocator
+	sdkPorts           *SDKPorts
+	// sdkPortAllocator allocates SDK ports for GameServers in hostNetwork mode.
+	sdkPortAllocator *sdkPortAllocator
+	// stuckFinalizer is the policy of GameServers with stuck finalizer, disabled if nil.
+	stuckFinalizer *StuckFinalizerPolicy
+	// addressResolver resolves the public endpoint of GameServers in background, disabled if nil.
+	addressResolver *asyncResolver
+	// ca mints the certificates of GameServers requiring TLS, disabled if nil.
+	ca *CertificateAuthority
+	// orphanPodPolicy is how game server pods without owner are handled.
+	orphanPodPolicy OrphanPodPolicy
+	// simulateKwokNodes passes the gates of GameServers on nodes simulated by kwok, as
+	// no SDK server runs there, so control plane could be tested at scale without real nodes.
+	simulateKwokNodes bool
+	// warmNodes weights scheduling toward nodes with the image or assets, disabled if nil.
+	warmNodes *WarmNodePolicy
+	// assetCache is the policy of asset caches on host path, which are not allowed if nil.
+	assetCache *AssetCachePolicy
+	// gameServerIndexer is set if warmNodes is enabled, to find nodes with assets cached.
+	gameServerIndexer cache.Indexer
+	// events dedupes the warnings persisting across syncs of GameServers.
+	events *kube.EventDeduper
 }
 
-// NewController returns a new GameServer crd controller
+// NewController returns a new GameServer crd controller, or an error if the indexers of
+// GameServers could not be added.
 func NewController(
 	kubeClient kubernetes.Interface,
 	kubeInformerFactory informers.SharedInformerFactory,
 	carrierClient versioned.Interface,
 	carrierInformerFactory externalversions.SharedInformerFactory,
-	minPort, maxPort int) *Controller {
+	minPort, maxPort int,
+	sdkPorts *SDKPorts,
+	stuckFinalizer *StuckFinalizerPolicy,
+	addressResolver AddressResolver,
+	ca *CertificateAuthority,
+	orphanPodPolicy OrphanPodPolicy,
+	simulateKwokNodes bool,
+	warmNodes *WarmNodePolicy,
+	assetCache *AssetCachePolicy) (*Controller, error) {
 
 	pods := kubeInformerFactory.Core().V1().Pods()
 	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
 	gsInformer := gameServers.Informer()
 	nodeInformer := kubeInformerFactory.Core().V1().Nodes()
+	secrets := kubeInformerFactory.Core().V1().Secrets()
 
 	c := &Controller{
 		podLister:        pods.Lister(),
@@ -82,9 +128,34 @@ func NewController(
 		gameServerSynced: gsInformer.HasSynced,
 		nodeLister:       nodeInformer.Lister(),
 		nodeSynced:       nodeInformer.Informer().HasSynced,
+		secretLister:     secrets.Lister(),
+		secretSynced:     secrets.Informer().HasSynced,
 		kubeClient:       kubeClient,
 		carrierClient:    carrierClient,
 		portAllocator:    NewMinMaxAllocator(minPort, maxPort),
+		sdkPorts:         sdkPorts,
+		stuckFinalizer:   stuckFinalizer,
+		ca:               ca,
+		orphanPodPolicy:  orphanPodPolicy,
+
+		simulateKwokNodes: simulateKwokNodes,
+		warmNodes:         warmNodes,
+		assetCache:        assetCache,
+		events:            kube.NewEventDeduper(),
+	}
+	if warmNodes != nil {
+		if err := AddGameServerIndexers(gsInformer); err != nil {
+			return nil, errors.Wrap(err, "failed to add GameServer indexers")
+		}
+		c.gameServerIndexer = gsInformer.GetIndexer()
+	}
+	if addressResolver != nil {
+		c.addressResolver = newAsyncResolver(addressResolver, func(key string, after time.Duration) {
+			c.queue.AddAfter(key, after)
+		})
+	}
+	if sdkPorts != nil {
+		c.sdkPortAllocator = newSDKPortAllocator(int(sdkPorts.HostNetworkMinPort), int(sdkPorts.HostNetworkMaxPort))
 	}
 
 	s := scheme.Scheme
@@ -138,7 +209,7 @@ func NewController(
 		DeleteFunc: c.deleteNode,
 	})
 
-	return c
+	return c, nil
 }
 
 // syncNodeTaint adds constraint to GameServers if a node will
@@ -201,6 +272,16 @@ func (c *Controller) deleteGamServer(obj interface{}) {
 		return
 	}
 	c.queue.Forget(key)
+	if c.addressResolver != nil {
+		c.addressResolver.forget(key)
+	}
+	if gs, ok := obj.(*carrierv1alpha1.GameServer); ok {
+		c.events.Forget(gs.UID)
+	} else if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		if gs, ok := tombstone.Obj.(*carrierv1alpha1.GameServer); ok {
+			c.events.Forget(gs.UID)
+		}
+	}
 }
 
 func (c *Controller) addNode(obj interface{}) {
@@ -277,19 +358,30 @@ func (c *Controller) processNextWorkItem(queue workqueue.RateLimitingInterface,
 // Runs threadiness number workers to process the rate limited queue
 func (c *Controller) Run(workers int, stop <-chan struct{}) error {
 	klog.V(4).Info("Wait for cache sync")
-	if !cache.WaitForCacheSync(stop, c.gameServerSynced, c.podSynced, c.nodeSynced) {
+	if !cache.WaitForCacheSync(stop, c.gameServerSynced, c.podSynced, c.nodeSynced, c.secretSynced) {
 		return errors.New("failed to wait for caches to sync")
 	}
 
 	c.syncPortAllocated()
+	metrics.RecordControllerReady("gameserver")
 	for i := 0; i < workers; i++ {
 		go wait.Until(c.gsWorker, time.Second, stop)
 		go wait.Until(c.nodeWorker, time.Second, stop)
 	}
+	go wait.Until(c.checkStuckFinalizers, stuckFinalizerCheckPeriod, stop)
+	go wait.Until(c.auditGameServers, antiEntropyPeriod, stop)
 	<-stop
 	return nil
 }
 
+// releasePorts releases the host ports and SDK ports allocated to gs.
+func (c *Controller) releasePorts(gs *carrierv1alpha1.GameServer) {
+	c.portAllocator.Release(getOwner(gs), string(gs.UID), findPorts(gs))
+	if c.sdkPortAllocator != nil {
+		c.sdkPortAllocator.Release(string(gs.UID))
+	}
+}
+
 // syncPortAllocated will lister GameServer and Ports that have allocated.
 // this should run before we start sync works
 func (c *Controller) syncPortAllocated() {
@@ -304,12 +396,16 @@ func (c *Controller) syncPortAllocated() {
 		gsOwnerId := getOwner(gs)
 		ports := findPorts(gs)
 		c.portAllocator.SetUsed(gsOwnerId, string(gs.UID), ports)
+		if sdkPorts := getAllocatedSDKPorts(gs); len(sdkPorts) != 0 && c.sdkPortAllocator != nil {
+			c.sdkPortAllocator.SetUsed(string(gs.UID), sdkPorts)
+		}
 	}
 }
 
 // syncGameServer reconciles GameServer status base on pod and node status.
 func (c *Controller) syncGameServer(key string) error {
 	klog.V(4).Infof("Sync GameServer %v", key)
+	metrics.RecordControllerSync("gameserver")
 	namespace, name, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
 		runtime.HandleError(err)
@@ -326,7 +422,7 @@ func (c *Controller) syncGameServer(key string) error {
 	}
 
 	if gs.DeletionTimestamp != nil {
-		c.portAllocator.Release(getOwner(gs), string(gs.UID), findPorts(gs))
+		c.releasePorts(gs)
 	}
 	gsCopy := gs.DeepCopy()
 	if gs, err = c.syncGameServerDeletionTimestamp(gsCopy); err != nil {
@@ -335,6 +431,9 @@ func (c *Controller) syncGameServer(key string) error {
 		}
 		return err
 	}
+	if err = c.syncTLSSecret(key, gs); err != nil {
+		return err
+	}
 	if gs, err = c.syncGameServerStartingState(gs); err != nil {
 		if klog.V(5) {
 			klog.Errorf("Failed sync GameServer: %v starting state, error: %v", key, err)
@@ -347,7 +446,22 @@ func (c *Controller) syncGameServer(key string) error {
 		}
 		return err
 	}
-	return nil
+	// the connection secret is best effort, failing to publish it must not block the steps
+	// below, its error is returned after them so it is retried.
+	connectionErr := c.syncConnectionSecret(gs)
+	if err = c.syncPostMortem(gs); err != nil {
+		return err
+	}
+	if gs, err = c.syncConstraintExpiry(key, gs); err != nil {
+		return err
+	}
+	if err = c.syncSessionDeadline(key, gs); err != nil {
+		return err
+	}
+	if err = c.syncDrainDeadline(key, gs); err != nil {
+		return err
+	}
+	return connectionErr
 }
 
 // syncGameServerDeletionTimestamp if the deletion timestamp is non-zero
@@ -372,6 +486,20 @@ func (c *Controller) syncGameServerDeletionTimestamp(gs *carrierv1alpha1.GameSer
 			fmt.Sprintf("Deleting Pod %s", pod.Name))
 	}
 
+	// crash dumps must be uploaded before the post-mortem Job is garbage collected with GameServer.
+	if err = c.syncPostMortem(gs); err != nil {
+		return gs, err
+	}
+	running, err := c.postMortemRunning(gs)
+	if err != nil {
+		return gs, err
+	}
+	if running {
+		klog.V(4).Infof("Waiting for post-mortem job of GameServer %v before removing finalizer", gs.Name)
+		c.queue.AddAfter(gs.Namespace+"/"+gs.Name, postMortemPollPeriod)
+		return gs, nil
+	}
+
 	var fin []string
 	for _, f := range gs.Finalizers {
 		if f != carrier.GroupName {
@@ -435,11 +563,36 @@ func (c *Controller) tryAllocatePorts(gs *carrierv1alpha1.GameServer) (*carrierv
 	return gs, err
 }
 
+// tryAllocateSDKPorts try to allocate SDK ports for GameServer in hostNetwork mode, distinct
+// from the GameServers on the same node, so that more than one GameServer can run on a node.
+func (c *Controller) tryAllocateSDKPorts(gs *carrierv1alpha1.GameServer) (*carrierv1alpha1.GameServer, error) {
+	if c.sdkPortAllocator == nil || !isHostPortNetwork(&gs.Spec) {
+		return gs, nil
+	}
+	if _, ok := gs.Annotations[util.GameServerSDKPortsAnnotation]; ok {
+		return gs, nil
+	}
+	ports, err := c.sdkPortAllocator.Allocate(string(gs.UID))
+	if err != nil {
+		klog.Errorf("Failed to allocate SDK ports: %v", err)
+		return gs, err
+	}
+	gsCopy := gs.DeepCopy()
+	setAllocatedSDKPorts(gsCopy, ports)
+	gs, err = c.carrierClient.CarrierV1alpha1().GameServers(gsCopy.Namespace).Update(gsCopy)
+	if err == nil {
+		return gs, nil
+	}
+	c.sdkPortAllocator.Release(string(gsCopy.UID))
+	klog.Errorf("Write back SDK ports to api failed: %v", err)
+	return gs, err
+}
+
 // syncGameServerStartingState checks if the GameServer is in the Creating state, and if so
 // creates a Pod for the GameServer and moves the state to Starting
 func (c *Controller) syncGameServerStartingState(gs *carrierv1alpha1.GameServer) (*carrierv1alpha1.GameServer, error) {
 	klog.V(4).Infof("Start sync start state for: %v", gs.Name)
-	if IsBeingDeleted(gs) {
+	if IsBeingDeleted(gs) || IsCompleted(gs) {
 		return gs, nil
 	}
 	var err error
@@ -447,6 +600,10 @@ func (c *Controller) syncGameServerStartingState(gs *carrierv1alpha1.GameServer)
 	if err != nil {
 		return gs, err
 	}
+	gs, err = c.tryAllocateSDKPorts(gs)
+	if err != nil {
+		return gs, err
+	}
 	if !IsDynamicPortAllocated(gs) && findDynamicPortNumber(gs) > 0 {
 		return gs, nil
 	}
@@ -460,6 +617,7 @@ func (c *Controller) syncGameServerStartingState(gs *carrierv1alpha1.GameServer)
 				LastProbeTime: metav1.NewTime(time.Now()),
 				Message:       "Pod deleted",
 			})
+			setFinishedTime(gs)
 			return c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).UpdateStatus(gs)
 		}
 		klog.V(4).Infof("Start creating pod for GameServer:%v", gs.Name)
@@ -502,7 +660,7 @@ func (c *Controller) syncGameServerStartingState(gs *carrierv1alpha1.GameServer)
 // 3. Check pod status
 func (c *Controller) syncGameServerRunningState(gs *carrierv1alpha1.GameServer) (*carrierv1alpha1.GameServer, error) {
 	klog.V(4).Infof("Start sync running state for: %v", gs.Name)
-	if IsBeingDeleted(gs) {
+	if IsBeingDeleted(gs) || IsCompleted(gs) {
 		return gs, nil
 	}
 	pod, err := c.getGameServerPod(gs)
@@ -524,6 +682,12 @@ func (c *Controller) syncGameServerRunningState(gs *carrierv1alpha1.GameServer)
 			}
 		}
 	}
+	if podCopy := pod.DeepCopy(); syncLogLabels(gs, podCopy) {
+		pod, err = c.kubeClient.CoreV1().Pods(podCopy.Namespace).Update(podCopy)
+		if err != nil {
+			return gs, err
+		}
+	}
 
 	switch gs.Status.State {
 	case carrierv1alpha1.GameServerUnknown:
@@ -549,6 +713,9 @@ func (c *Controller) syncGameServerRunningState(gs *carrierv1alpha1.GameServer)
 			return gs, errors.Wrapf(err, "error retrieving node %s for Pod %s", nodeName, pod.Name)
 		}
 	}
+	if gs, err = c.syncTopologyLabels(gs, node); err != nil {
+		return gs, err
+	}
 	// check node exist.
 	// if not NotFound Err, return
 	// if NotFound or err is nil, go to reconcileGameServerState
@@ -557,14 +724,38 @@ func (c *Controller) syncGameServerRunningState(gs *carrierv1alpha1.GameServer)
 	klog.V(5).Infof("Old GameServer %v state: %v, address: %v, node name: %v",
 		gs.Name, gs.Status.State, gs.Status.Address, gs.Status.NodeName)
 	gsStatusCopy := gs.Status.DeepCopy()
+	if c.simulateKwokNodes && isSimulatedNode(node) {
+		simulateGates(gs, pod)
+	}
 	// reconcile GameServer State
 	c.reconcileGameServerState(gs, pod, node)
+	reconcilePodFailure(gs, pod)
+	assetMessage := assetReadyMessage(gs)
+	reconcileAssetReady(gs, pod)
+	if assetMessage != assetReadyMessage(gs) {
+		c.releaseAssetLease(gs, pod)
+	}
+	reconcileGameVersion(gs, pod)
+	reconcileDraining(gs)
+	setFinishedTime(gs)
+	reconcileStartupMilestones(gs, pod, time.Now())
+	reconcileIdleSince(gs, time.Now())
+	reconcileSessionStartTime(gs, time.Now())
 	// reconcile GameServer Address
 	updated := c.reconcileGameServerAddress(gs, pod)
+	resolveErr := c.resolveGameServerAddress(gs, node)
+	if resolveErr != nil {
+		c.recorder.Eventf(gs, corev1.EventTypeWarning, string(gs.Status.State),
+			"Failed to resolve address: %v", resolveErr)
+	}
+	if err := reconcileExternalEndpoint(gs); err != nil {
+		c.recorder.Eventf(gs, corev1.EventTypeWarning, string(gs.Status.State),
+			"Ignored external endpoint reported: %v", err)
+	}
 	klog.V(5).Infof("New GameServer %v state: %v, address: %v, node name: %v",
 		gs.Name, gs.Status.State, gs.Status.Address, gs.Status.NodeName)
 	if reflect.DeepEqual(gsStatusCopy, gs.Status) {
-		return gs, nil
+		return gs, resolveErr
 	}
 	gs, err = c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).UpdateStatus(gs)
 	if err != nil {
@@ -580,7 +771,7 @@ func (c *Controller) syncGameServerRunningState(gs *carrierv1alpha1.GameServer)
 		c.recorder.Event(gs, corev1.EventTypeNormal, string(gs.Status.State),
 			"Waiting for receiving readiness message")
 	}
-	return gs, nil
+	return gs, resolveErr
 }
 
 // removeConstraintsFromGameServer removes constraints from GameServer migrated.
@@ -609,6 +800,13 @@ func (c *Controller) createGameServerPod(gs *carrierv1alpha1.GameServer) (*carri
 			"build Pod for GameServer %s", gs.Name)
 		return gs, errors.Wrapf(err, "error building Pod for GameServer %s", gs.Name)
 	}
+	if err = injectSDKPorts(gs, pod, c.sdkPorts); err != nil {
+		c.recorder.Eventf(gs, corev1.EventTypeWarning, string(gs.Status.State),
+			"select SDK ports for GameServer %s: %v", gs.Name, err)
+		return gs, errors.Wrapf(err, "error selecting SDK ports for GameServer %s", gs.Name)
+	}
+	c.injectWarmNodeAffinity(gs, pod)
+	c.injectAssetCachePolicy(gs, pod)
 
 	klog.V(4).Infof("Creating pod: %v for GameServer", pod.Name)
 	pod, err = c.kubeClient.CoreV1().Pods(gs.Namespace).Create(pod)
@@ -685,6 +883,10 @@ func (c *Controller) reconcileGameServerState(gs *carrierv1alpha1.GameServer, po
 			c.recorder.Eventf(gs, corev1.EventTypeWarning, string(gs.Status.State),
 				"Container terminated, reason: %v, exit code: %v",
 				cs.State.Terminated.Reason, cs.State.Terminated.ExitCode)
+			if isCompletedContainer(gs, pod, cs.State.Terminated) {
+				gs.Status.State = carrierv1alpha1.GameServerCompleted
+				return
+			}
 			if pod.Spec.RestartPolicy == corev1.RestartPolicyNever {
 				gs.Status.State = carrierv1alpha1.GameServerExited
 				return
@@ -697,6 +899,9 @@ func (c *Controller) reconcileGameServerState(gs *carrierv1alpha1.GameServer, po
 		gs.Status.State = carrierv1alpha1.GameServerFailed
 	case corev1.PodSucceeded:
 		gs.Status.State = carrierv1alpha1.GameServerExited
+		if IsOneShot(gs) {
+			gs.Status.State = carrierv1alpha1.GameServerCompleted
+		}
 	default:
 		gs.Status.State = carrierv1alpha1.GameServerUnknown
 	}
@@ -706,19 +911,5 @@ func (c *Controller) reconcileGameServerState(gs *carrierv1alpha1.GameServer, po
 // AddNotInServiceConstraint will add `NotInService` constraint
 // to GameServer Spec.
 func AddNotInServiceConstraint(gs *carrierv1alpha1.GameServer) {
-	constraints := gs.Spec.Constraints
-	notInService := NotInServiceConstraint()
-	found := false
-	for idx, constraint := range constraints {
-		if constraint.Type != carrierv1alpha1.NotInService {
-			continue
-		}
-		found = true
-		constraints[idx] = notInService
-		break
-	}
-	if !found {
-		constraints = append(constraints, notInService)
-	}
-	gs.Spec.Constraints = constraints
+	builder.MarkOutOfService(gs, notInServiceMessage)
 }
@@ -23,6 +23,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	informerv1 "k8s.io/client-go/informers/core/v1"
@@ -38,6 +39,7 @@ import (
 	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
 	v1alpha12 "github.com/ocgi/carrier/pkg/client/informers/externalversions/carrier/v1alpha1"
 	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/util/kube"
 )
 
 func TestNewControllerNodeTaint(t *testing.T) {
@@ -103,6 +105,55 @@ func TestNewControllerSyncDeleteTimeStamp(t *testing.T) {
 	}
 }
 
+func TestCheckStuckFinalizers(t *testing.T) {
+	ctx := context.Background()
+	_, _, gsInformer, c, _ := fakeController(ctx)
+	c.stuckFinalizer = &StuckFinalizerPolicy{Threshold: time.Minute, ForceRemove: true}
+	stuckTime := v1.NewTime(time.Now().Add(-time.Hour))
+	for _, testCase := range []struct {
+		name      string
+		deleting  v1.Time
+		hostPort  int32
+		finalizer bool
+	}{
+		{
+			name:      "stuck",
+			deleting:  stuckTime,
+			hostPort:  1001,
+			finalizer: false,
+		},
+		{
+			name:      "deleting",
+			deleting:  v1.Now(),
+			hostPort:  1002,
+			finalizer: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			gs := &v1alpha1.GameServer{
+				ObjectMeta: v1.ObjectMeta{Name: testCase.name, Namespace: "default", UID: types.UID(testCase.name),
+					DeletionTimestamp: &testCase.deleting, Finalizers: []string{carrier.GroupName}},
+				Spec: v1alpha1.GameServerSpec{Ports: []v1alpha1.GameServerPort{{HostPort: &testCase.hostPort}}}}
+			c.portAllocator.SetUsed(getOwner(gs), string(gs.UID), findPorts(gs))
+			if _, err := c.carrierClient.CarrierV1alpha1().GameServers("default").Create(gs); err != nil {
+				t.Fatal(err)
+			}
+			gsInformer.Informer().GetIndexer().Add(gs)
+			c.checkStuckFinalizers()
+			gs, err := c.carrierClient.CarrierV1alpha1().GameServers("default").Get(testCase.name, v1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hasFinalizer(gs) != testCase.finalizer {
+				t.Errorf("desired finalizer: %v, get: %v", testCase.finalizer, gs.Finalizers)
+			}
+			if used := c.portAllocator.(*MinMaxAllocator).has(int(testCase.hostPort)); used != testCase.finalizer {
+				t.Errorf("desired port %v used: %v, get: %v", testCase.hostPort, testCase.finalizer, used)
+			}
+		})
+	}
+}
+
 func TestNewControllerSyncStarting(t *testing.T) {
 	for _, testCase := range []struct {
 		name         string
@@ -278,6 +329,7 @@ func fakeController(ctx context.Context) (informerv1.PodInformer, informerv1.Nod
 	factory := informers.NewSharedInformerFactory(fakeClient, 0)
 	podInformer := factory.Core().V1().Pods()
 	nodeInformer := factory.Core().V1().Nodes()
+	secretInformer := factory.Core().V1().Secrets()
 	carrierFactory := externalversions.NewSharedInformerFactory(fakeGSClient, 0)
 	gsInformer := carrierFactory.Carrier().V1alpha1().GameServers()
 
@@ -293,15 +345,19 @@ func fakeController(ctx context.Context) (informerv1.PodInformer, informerv1.Nod
 		podSynced:        podInformer.Informer().HasSynced,
 		nodeLister:       nodeInformer.Lister(),
 		nodeSynced:       nodeInformer.Informer().HasSynced,
+		secretLister:     secretInformer.Lister(),
+		secretSynced:     secretInformer.Informer().HasSynced,
 		gameServerLister: gsInformer.Lister(),
 		gameServerSynced: gsInformer.Informer().HasSynced,
 		carrierClient:    fakeGSClient,
 		kubeClient:       fakeClient,
 		recorder:         eventBroadcaster.NewRecorder(s, corev1.EventSource{Component: "gameserver-controller"}),
+		events:           kube.NewEventDeduper(),
+		portAllocator:    NewMinMaxAllocator(1000, 2000),
 	}
 	factory.Start(ctx.Done())
 	carrierFactory.Start(ctx.Done())
-	cache.WaitForCacheSync(ctx.Done(), c.podSynced, c.gameServerSynced, c.nodeSynced)
+	cache.WaitForCacheSync(ctx.Done(), c.podSynced, c.gameServerSynced, c.nodeSynced, c.secretSynced)
 	return podInformer, nodeInformer, gsInformer, c, fakeClient
 }
 
@@ -0,0 +1,133 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// DebugTarget is the pod and container backing a GameServer, which exec and
+// port-forward sessions are established to.
+type DebugTarget struct {
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// ResolveDebugTarget returns the debug target of gs, the container of game server is
+// selected if container is empty.
+func ResolveDebugTarget(gs *carrierv1alpha1.GameServer, pod *corev1.Pod, container string) (*DebugTarget, error) {
+	if !metav1.IsControlledBy(pod, gs) {
+		return nil, fmt.Errorf("pod %v/%v is not controlled by GameServer %v", pod.Namespace, pod.Name, gs.Name)
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return nil, fmt.Errorf("pod %v/%v of GameServer %v is %v, not running",
+			pod.Namespace, pod.Name, gs.Name, pod.Status.Phase)
+	}
+	if len(container) == 0 {
+		container = util.GameServerContainerName
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name == container {
+			return &DebugTarget{Namespace: pod.Namespace, Pod: pod.Name, Container: container}, nil
+		}
+	}
+	return nil, fmt.Errorf("container %v not found in pod %v/%v", container, pod.Namespace, pod.Name)
+}
+
+// debugContainerPrefix is the name prefix of the ephemeral debug containers.
+const debugContainerPrefix = "debugger-"
+
+// DebugContainer returns the ephemeral container injected into pod to debug the container of
+// target with image. It shares the process namespace of the target container, runs as the same
+// user and is allowed to trace its processes, so profilers attach to live matches without
+// restarting them. The command of image is run if command is empty.
+func DebugContainer(pod *corev1.Pod, target *DebugTarget, image string, command []string) corev1.EphemeralContainer {
+	names := make(map[string]bool, len(pod.Spec.EphemeralContainers))
+	for _, c := range pod.Spec.EphemeralContainers {
+		names[c.Name] = true
+	}
+	var name string
+	for i := len(pod.Spec.EphemeralContainers); ; i++ {
+		if name = debugContainerPrefix + strconv.Itoa(i); !names[name] {
+			break
+		}
+	}
+	securityContext := &corev1.SecurityContext{
+		Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"SYS_PTRACE"}},
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name == target.Container && c.SecurityContext != nil {
+			securityContext.RunAsUser = c.SecurityContext.RunAsUser
+			securityContext.RunAsGroup = c.SecurityContext.RunAsGroup
+		}
+	}
+	return corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:                     name,
+			Image:                    image,
+			Command:                  command,
+			Stdin:                    true,
+			TTY:                      true,
+			TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+			ImagePullPolicy:          corev1.PullIfNotPresent,
+			SecurityContext:          securityContext,
+		},
+		TargetContainerName: target.Container,
+	}
+}
+
+// ResolveDebugPorts translates the ports of port-forward, which could be port numbers,
+// LOCAL:REMOTE pairs, or the names of the ports of gs forwarded to their container port.
+func ResolveDebugPorts(gs *carrierv1alpha1.GameServer, ports []string) ([]string, error) {
+	var result []string
+	for _, port := range ports {
+		local, remote := "", port
+		if i := strings.LastIndex(port, ":"); i >= 0 {
+			local, remote = port[:i+1], port[i+1:]
+		}
+		if _, err := strconv.Atoi(remote); err == nil {
+			result = append(result, port)
+			continue
+		}
+		containerPort, err := containerPortByName(gs, remote)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, local+strconv.Itoa(int(containerPort)))
+	}
+	return result, nil
+}
+
+func containerPortByName(gs *carrierv1alpha1.GameServer, name string) (int32, error) {
+	for _, port := range gs.Spec.Ports {
+		if port.Name != name {
+			continue
+		}
+		if port.ContainerPort == nil {
+			return 0, fmt.Errorf("port %v of GameServer %v has no container port", name, gs.Name)
+		}
+		return *port.ContainerPort, nil
+	}
+	return 0, fmt.Errorf("port %v not found in GameServer %v", name, gs.Name)
+}
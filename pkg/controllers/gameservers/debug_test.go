@@ -0,0 +1,123 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestResolveDebugTarget(t *testing.T) {
+	gs := &carrierv1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default", UID: "123"}}
+	controller := true
+	newPod := func(phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+				{Name: "gs", UID: "123", Controller: &controller}}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: util.GameServerContainerName}, {Name: "sidecar"}}},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+	notOwned := newPod(corev1.PodRunning)
+	notOwned.OwnerReferences[0].UID = "456"
+	for _, testCase := range []struct {
+		name      string
+		pod       *corev1.Pod
+		container string
+		expect    *DebugTarget
+	}{
+		{
+			name:   "default container",
+			pod:    newPod(corev1.PodRunning),
+			expect: &DebugTarget{Namespace: "default", Pod: "gs", Container: util.GameServerContainerName},
+		},
+		{
+			name:      "sidecar",
+			pod:       newPod(corev1.PodRunning),
+			container: "sidecar",
+			expect:    &DebugTarget{Namespace: "default", Pod: "gs", Container: "sidecar"},
+		},
+		{
+			name:      "container not found",
+			pod:       newPod(corev1.PodRunning),
+			container: "unknown",
+		},
+		{
+			name: "pod pending",
+			pod:  newPod(corev1.PodPending),
+		},
+		{
+			name: "pod of recreated GameServer",
+			pod:  notOwned,
+		},
+	} {
+		target, err := ResolveDebugTarget(gs, testCase.pod, testCase.container)
+		if (err != nil) != (testCase.expect == nil) || !reflect.DeepEqual(target, testCase.expect) {
+			t.Errorf("%v: desired %+v, get: %+v, %v", testCase.name, testCase.expect, target, err)
+		}
+	}
+}
+
+func TestResolveDebugPorts(t *testing.T) {
+	containerPort := int32(7777)
+	gs := &carrierv1alpha1.GameServer{Spec: carrierv1alpha1.GameServerSpec{Ports: []carrierv1alpha1.GameServerPort{
+		{Name: "default", ContainerPort: &containerPort}, {Name: "range"}}}}
+	for _, testCase := range []struct {
+		ports     []string
+		expect    []string
+		expectErr bool
+	}{
+		{ports: []string{"8080", "9000:9090"}, expect: []string{"8080", "9000:9090"}},
+		{ports: []string{"default", "17777:default", ":default"}, expect: []string{"7777", "17777:7777", ":7777"}},
+		{ports: []string{"range"}, expectErr: true},
+		{ports: []string{"unknown"}, expectErr: true},
+	} {
+		ports, err := ResolveDebugPorts(gs, testCase.ports)
+		if (err != nil) != testCase.expectErr || !reflect.DeepEqual(ports, testCase.expect) {
+			t.Errorf("%v: desired %v, get: %v, %v", testCase.ports, testCase.expect, ports, err)
+		}
+	}
+}
+
+func TestDebugContainer(t *testing.T) {
+	user := int64(1000)
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{{Name: util.GameServerContainerName,
+			SecurityContext: &corev1.SecurityContext{RunAsUser: &user}}},
+		EphemeralContainers: []corev1.EphemeralContainer{
+			{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debugger-1"}}},
+	}}
+	target := &DebugTarget{Namespace: "default", Pod: "gs", Container: util.GameServerContainerName}
+	c := DebugContainer(pod, target, "busybox", []string{"sh"})
+	if c.Name != "debugger-2" {
+		t.Errorf("desired an unused name debugger-2, get %v", c.Name)
+	}
+	if c.TargetContainerName != util.GameServerContainerName || c.Image != "busybox" ||
+		!reflect.DeepEqual(c.Command, []string{"sh"}) {
+		t.Errorf("desired targeting the game server container, get %+v", c)
+	}
+	sc := c.SecurityContext
+	if sc == nil || sc.RunAsUser == nil || *sc.RunAsUser != user ||
+		!reflect.DeepEqual(sc.Capabilities.Add, []corev1.Capability{"SYS_PTRACE"}) {
+		t.Errorf("desired running as the target user with SYS_PTRACE, get %+v", sc)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// reconcileDraining sets the Draining condition of GameServer, so the progress
+// of scaling down and updates waiting for deletable gates is visible.
+func reconcileDraining(gs *carrierv1alpha1.GameServer) {
+	if !IsDraining(gs) {
+		setGameServerCondition(gs, carrierv1alpha1.GameServerDraining, carrierv1alpha1.ConditionFalse, "")
+		return
+	}
+	message := fmt.Sprintf("players: %v", getPlayers(gs))
+	if since := outOfServiceSince(gs); since != nil {
+		message = fmt.Sprintf("%v, out of service since %v", message, since.UTC().Format(time.RFC3339))
+	}
+	setGameServerCondition(gs, carrierv1alpha1.GameServerDraining, carrierv1alpha1.ConditionTrue, message)
+}
+
+// syncDrainDeadline deletes the GameServer waiting for its deletable gates longer than
+// spec.maxDrainSeconds, either draining or in place updating, so a stuck match can not block
+// updates or node drains forever. GameServer still within the deadline is requeued to be
+// checked again when the deadline is reached.
+func (c *Controller) syncDrainDeadline(key string, gs *carrierv1alpha1.GameServer) error {
+	remaining, ok := drainDeadlineRemaining(gs, time.Now())
+	if !ok {
+		return nil
+	}
+	if remaining > 0 {
+		c.queue.AddAfter(key, remaining)
+		return nil
+	}
+	klog.Warningf("GameServer %v exceeds drain deadline %vs, force deleting", key, *gs.Spec.MaxDrainSeconds)
+	c.recorder.Eventf(gs, corev1.EventTypeWarning, "DrainDeadlineExceeded",
+		"Out of service for more than %vs, force deleting GameServer, players dropped: %v",
+		*gs.Spec.MaxDrainSeconds, getPlayers(gs))
+	err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Delete(gs.Name, &metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete GameServer %v exceeding drain deadline", key)
+	}
+	return nil
+}
+
+// drainDeadlineRemaining returns the time left before the drain deadline of GameServer, which
+// counts from when it is marked out of service. ok is false if GameServer is not waiting for its
+// deletable gates or has no deadline.
+func drainDeadlineRemaining(gs *carrierv1alpha1.GameServer, now time.Time) (remaining time.Duration, ok bool) {
+	if gs.Spec.MaxDrainSeconds == nil || gs.DeletionTimestamp != nil || !waitingForGates(gs) {
+		return 0, false
+	}
+	since := outOfServiceSince(gs)
+	if since == nil {
+		return 0, false
+	}
+	deadline := since.Add(time.Duration(*gs.Spec.MaxDrainSeconds) * time.Second)
+	return deadline.Sub(now), true
+}
+
+// waitingForGates returns true if GameServer out of service is waiting for its deletable gates
+// to pass before being deleted or in place updated.
+func waitingForGates(gs *carrierv1alpha1.GameServer) bool {
+	return gs.Status.State == carrierv1alpha1.GameServerRunning && IsOutOfService(gs) &&
+		IsDeletableExist(gs) && !deleteReady(gs)
+}
+
+// getPlayers returns the players reported by GameServer, "unknown" if not reported.
+func getPlayers(gs *carrierv1alpha1.GameServer) string {
+	if value, ok := gs.Annotations[util.GameServerPlayersAnnotation]; ok {
+		return value
+	}
+	return "unknown"
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func newDrainingGameServer(since time.Time) *carrierv1alpha1.GameServer {
+	effective := true
+	timeAdded := metav1.NewTime(since)
+	return &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{util.GameServerPlayersAnnotation: "3"},
+		},
+		Spec: carrierv1alpha1.GameServerSpec{
+			DeletableGates: []string{"carrier.ocgi.dev/has-no-player"},
+			Constraints: []carrierv1alpha1.Constraint{
+				{
+					Type:      carrierv1alpha1.NotInService,
+					Effective: &effective,
+					TimeAdded: &timeAdded,
+				},
+			},
+		},
+		Status: carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning},
+	}
+}
+
+func TestReconcileDraining(t *testing.T) {
+	since := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	gs := newDrainingGameServer(since)
+	reconcileDraining(gs)
+	if len(gs.Status.Conditions) != 1 || gs.Status.Conditions[0].Status != carrierv1alpha1.ConditionTrue {
+		t.Fatalf("desired Draining condition, get: %+v", gs.Status.Conditions)
+	}
+	desired := "players: 3, out of service since 2021-06-01T08:00:00Z"
+	if gs.Status.Conditions[0].Message != desired {
+		t.Errorf("desired message %q, get: %q", desired, gs.Status.Conditions[0].Message)
+	}
+
+	gs.Status.Conditions = append(gs.Status.Conditions, carrierv1alpha1.GameServerCondition{
+		Type:   "carrier.ocgi.dev/has-no-player",
+		Status: carrierv1alpha1.ConditionTrue,
+	})
+	reconcileDraining(gs)
+	if gs.Status.Conditions[0].Status != carrierv1alpha1.ConditionFalse {
+		t.Errorf("desired Draining condition False after gates passed, get: %+v", gs.Status.Conditions[0])
+	}
+
+	gs = newDrainingGameServer(since)
+	gs.Spec.Constraints = nil
+	reconcileDraining(gs)
+	if len(gs.Status.Conditions) != 0 {
+		t.Errorf("desired no condition for GameServer in service, get: %+v", gs.Status.Conditions)
+	}
+}
+
+func TestDrainDeadlineRemaining(t *testing.T) {
+	since := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	maxDrainSeconds := int64(600)
+	tests := []struct {
+		name      string
+		mutate    func(gs *carrierv1alpha1.GameServer)
+		now       time.Time
+		remaining time.Duration
+		ok        bool
+	}{
+		{
+			name: "no deadline",
+			now:  since.Add(time.Hour),
+		},
+		{
+			name: "within deadline",
+			mutate: func(gs *carrierv1alpha1.GameServer) {
+				gs.Spec.MaxDrainSeconds = &maxDrainSeconds
+			},
+			now:       since.Add(4 * time.Minute),
+			remaining: 6 * time.Minute,
+			ok:        true,
+		},
+		{
+			name: "deadline exceeded",
+			mutate: func(gs *carrierv1alpha1.GameServer) {
+				gs.Spec.MaxDrainSeconds = &maxDrainSeconds
+			},
+			now:       since.Add(15 * time.Minute),
+			remaining: -5 * time.Minute,
+			ok:        true,
+		},
+		{
+			name: "gates passed",
+			mutate: func(gs *carrierv1alpha1.GameServer) {
+				gs.Spec.MaxDrainSeconds = &maxDrainSeconds
+				gs.Status.Conditions = append(gs.Status.Conditions, carrierv1alpha1.GameServerCondition{
+					Type:   "carrier.ocgi.dev/has-no-player",
+					Status: carrierv1alpha1.ConditionTrue,
+				})
+			},
+			now: since.Add(15 * time.Minute),
+		},
+		{
+			name: "in place updating",
+			mutate: func(gs *carrierv1alpha1.GameServer) {
+				gs.Spec.MaxDrainSeconds = &maxDrainSeconds
+				gs.Annotations[util.GameServerInPlaceUpdatingAnnotation] = "true"
+			},
+			now:       since.Add(15 * time.Minute),
+			remaining: -5 * time.Minute,
+			ok:        true,
+		},
+		{
+			name: "being deleted",
+			mutate: func(gs *carrierv1alpha1.GameServer) {
+				gs.Spec.MaxDrainSeconds = &maxDrainSeconds
+				deletionTimestamp := metav1.NewTime(since)
+				gs.DeletionTimestamp = &deletionTimestamp
+			},
+			now: since.Add(15 * time.Minute),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := newDrainingGameServer(since)
+			if tc.mutate != nil {
+				tc.mutate(gs)
+			}
+			remaining, ok := drainDeadlineRemaining(gs, tc.now)
+			if ok != tc.ok || remaining != tc.remaining {
+				t.Errorf("desired %v, %v, get: %v, %v", tc.remaining, tc.ok, remaining, ok)
+			}
+		})
+	}
+}
+
+func TestAddNotInServiceConstraintKeepsTimeAdded(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	gs := newDrainingGameServer(since)
+	AddNotInServiceConstraint(gs)
+	if got := outOfServiceSince(gs); got == nil || !got.Time.Equal(since) {
+		t.Errorf("desired out of service since %v, get: %v", since, got)
+	}
+
+	gs.Spec.Constraints = nil
+	AddNotInServiceConstraint(gs)
+	if got := outOfServiceSince(gs); got == nil || got.Time.Before(since.Add(time.Minute)) {
+		t.Errorf("desired out of service from now, get: %v", got)
+	}
+}
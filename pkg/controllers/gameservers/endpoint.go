@@ -0,0 +1,74 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// reconcileExternalEndpoint publishes the endpoint self reported by the game server in the
+// load balancer status, which takes precedence over the address resolver. An invalid
+// endpoint is not published and returned as error.
+func reconcileExternalEndpoint(gs *carrierv1alpha1.GameServer) error {
+	endpoint, ok := gs.Annotations[util.GameServerExternalEndpointAnnotation]
+	if !ok {
+		return nil
+	}
+	ip, port, err := parseExternalEndpoint(endpoint)
+	if err != nil {
+		setGameServerCondition(gs, carrierv1alpha1.GameServerAddressResolved, carrierv1alpha1.ConditionFalse,
+			err.Error())
+		return err
+	}
+	lbPort := carrierv1alpha1.LoadBalancerPort{ExternalPort: &port}
+	if len(gs.Spec.Ports) != 0 {
+		lbPort.Name = gs.Spec.Ports[0].Name
+		lbPort.ContainerPort = gs.Spec.Ports[0].ContainerPort
+		lbPort.Protocol = gs.Spec.Ports[0].Protocol
+	}
+	gs.Status.LoadBalancerStatus = &carrierv1alpha1.LoadBalancerStatus{
+		Ingress: []carrierv1alpha1.LoadBalancerIngress{
+			{IP: ip, Ports: []carrierv1alpha1.LoadBalancerPort{lbPort}},
+		},
+	}
+	setGameServerCondition(gs, carrierv1alpha1.GameServerAddressResolved, carrierv1alpha1.ConditionTrue,
+		fmt.Sprintf("Reported by game server: %v", endpoint))
+	return nil
+}
+
+// parseExternalEndpoint parses the endpoint in "ip:port" format, the ip must be a
+// global unicast address.
+func parseExternalEndpoint(endpoint string) (string, int32, error) {
+	host, portStr, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "invalid external endpoint %q", endpoint)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsGlobalUnicast() {
+		return "", 0, errors.Errorf("invalid external endpoint %q: %q is not a unicast IP", endpoint, host)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || port == 0 {
+		return "", 0, errors.Errorf("invalid external endpoint %q: invalid port %q", endpoint, portStr)
+	}
+	return ip.String(), int32(port), nil
+}
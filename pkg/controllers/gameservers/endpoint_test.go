@@ -0,0 +1,85 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestParseExternalEndpoint(t *testing.T) {
+	for _, testCase := range []struct {
+		endpoint   string
+		expectIP   string
+		expectPort int32
+		expectErr  bool
+	}{
+		{endpoint: "1.2.3.4:7777", expectIP: "1.2.3.4", expectPort: 7777},
+		{endpoint: "[2001:db8::1]:7777", expectIP: "2001:db8::1", expectPort: 7777},
+		{endpoint: "1.2.3.4", expectErr: true},
+		{endpoint: "127.0.0.1:7777", expectErr: true},
+		{endpoint: "example.com:7777", expectErr: true},
+		{endpoint: "1.2.3.4:0", expectErr: true},
+		{endpoint: "1.2.3.4:70000", expectErr: true},
+	} {
+		ip, port, err := parseExternalEndpoint(testCase.endpoint)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("%v: desired error: %v, get: %v", testCase.endpoint, testCase.expectErr, err)
+			continue
+		}
+		if ip != testCase.expectIP || port != testCase.expectPort {
+			t.Errorf("%v: desired %v:%v, get: %v:%v", testCase.endpoint,
+				testCase.expectIP, testCase.expectPort, ip, port)
+		}
+	}
+}
+
+func TestReconcileExternalEndpoint(t *testing.T) {
+	containerPort := int32(7000)
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{util.GameServerExternalEndpointAnnotation: "1.2.3.4:7777"},
+		},
+		Spec: carrierv1alpha1.GameServerSpec{
+			Ports: []carrierv1alpha1.GameServerPort{{Name: "game", ContainerPort: &containerPort}},
+		},
+	}
+	if err := reconcileExternalEndpoint(gs); err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	ingress := gs.Status.LoadBalancerStatus.Ingress
+	if len(ingress) != 1 || ingress[0].IP != "1.2.3.4" || *ingress[0].Ports[0].ExternalPort != 7777 ||
+		*ingress[0].Ports[0].ContainerPort != containerPort {
+		t.Errorf("desired ingress 1.2.3.4:7777 of port game, get: %+v", ingress)
+	}
+	if !addressResolved(gs) {
+		t.Errorf("desired address resolved")
+	}
+
+	gs.Annotations[util.GameServerExternalEndpointAnnotation] = "invalid"
+	if err := reconcileExternalEndpoint(gs); err == nil {
+		t.Errorf("desired error, get nil")
+	}
+	if addressResolved(gs) {
+		t.Errorf("desired address not resolved")
+	}
+	if gs.Status.LoadBalancerStatus.Ingress[0].IP != "1.2.3.4" {
+		t.Errorf("desired last valid endpoint kept, get: %+v", gs.Status.LoadBalancerStatus)
+	}
+}
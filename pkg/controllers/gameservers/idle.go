@@ -0,0 +1,51 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+// reconcileIdleSince records when gs became ready without being allocated, and clears it
+// once gs is allocated or is not ready. GameServers not reporting players are never
+// allocated, so they are idle since they became ready.
+func reconcileIdleSince(gs *carrierv1alpha1.GameServer, now time.Time) {
+	if !isIdle(gs) {
+		gs.Status.IdleSince = nil
+		return
+	}
+	if gs.Status.IdleSince == nil {
+		idleSince := metav1.NewTime(now)
+		gs.Status.IdleSince = &idleSince
+	}
+}
+
+// isIdle returns true if gs is running, ready and not allocated.
+func isIdle(gs *carrierv1alpha1.GameServer) bool {
+	return gs.Status.State == carrierv1alpha1.GameServerRunning && IsReady(gs) && !IsAllocated(gs)
+}
+
+// IsIdleExpired returns true if gs has been idle longer than its max idle seconds at now.
+func IsIdleExpired(gs *carrierv1alpha1.GameServer, now time.Time) bool {
+	if gs.Spec.MaxIdleSeconds == nil || gs.Status.IdleSince == nil {
+		return false
+	}
+	maxIdle := time.Duration(*gs.Spec.MaxIdleSeconds) * time.Second
+	return now.Sub(gs.Status.IdleSince.Time) > maxIdle
+}
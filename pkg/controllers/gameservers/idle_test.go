@@ -0,0 +1,72 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestReconcileIdleSince(t *testing.T) {
+	now := time.Now()
+	maxIdleSeconds := int64(60)
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{util.GameServerPlayersAnnotation: "0"},
+		},
+		Spec:   carrierv1alpha1.GameServerSpec{MaxIdleSeconds: &maxIdleSeconds},
+		Status: carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning},
+	}
+	reconcileIdleSince(gs, now)
+	if gs.Status.IdleSince == nil || !gs.Status.IdleSince.Time.Equal(now) {
+		t.Fatalf("desired idle since %v, get: %v", now, gs.Status.IdleSince)
+	}
+	reconcileIdleSince(gs, now.Add(time.Minute))
+	if !gs.Status.IdleSince.Time.Equal(now) {
+		t.Errorf("desired idle since kept, get: %v", gs.Status.IdleSince)
+	}
+	if IsIdleExpired(gs, now.Add(30*time.Second)) {
+		t.Errorf("desired not expired before max idle seconds")
+	}
+	if !IsIdleExpired(gs, now.Add(2*time.Minute)) {
+		t.Errorf("desired expired after max idle seconds")
+	}
+
+	gs.Annotations[util.GameServerPlayersAnnotation] = "3"
+	reconcileIdleSince(gs, now.Add(2*time.Minute))
+	if gs.Status.IdleSince != nil || IsIdleExpired(gs, now.Add(2*time.Minute)) {
+		t.Errorf("desired not idle with players, get: %v", gs.Status.IdleSince)
+	}
+
+	delete(gs.Annotations, util.GameServerPlayersAnnotation)
+	reconcileIdleSince(gs, now.Add(3*time.Minute))
+	if gs.Status.IdleSince == nil || !gs.Status.IdleSince.Time.Equal(now.Add(3*time.Minute)) {
+		t.Fatalf("desired idle without players reported, get: %v", gs.Status.IdleSince)
+	}
+	if !IsIdleExpired(gs, now.Add(5*time.Minute)) {
+		t.Errorf("desired expired without players reported after max idle seconds")
+	}
+
+	gs.Status.State = carrierv1alpha1.GameServerStarting
+	reconcileIdleSince(gs, now)
+	if gs.Status.IdleSince != nil {
+		t.Errorf("desired not idle before running, get: %v", gs.Status.IdleSince)
+	}
+}
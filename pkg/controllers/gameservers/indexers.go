@@ -0,0 +1,124 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// indexes of the GameServer informer.
+const (
+	// GameServerOwnerIndex indexes GameServers by the uid of their controller.
+	GameServerOwnerIndex = "owner"
+	// GameServerHashIndex indexes GameServers by the uid of their controller and the template hash.
+	GameServerHashIndex = "hash"
+	// GameServerAssetIndex indexes GameServers whose assets are ready in the host path cache
+	// of their node by the cache key, see AssetIndexKey.
+	GameServerAssetIndex = "asset"
+)
+
+// AddGameServerIndexers adds the indexers of GameServers to informer, indexers already added
+// by other controllers sharing the informer are skipped. It must be called before the
+// informer is started.
+func AddGameServerIndexers(informer cache.SharedIndexInformer) error {
+	indexers := cache.Indexers{}
+	existing := informer.GetIndexer().GetIndexers()
+	for name, f := range map[string]cache.IndexFunc{
+		GameServerOwnerIndex: indexByOwner,
+		GameServerHashIndex:  indexByHash,
+		GameServerAssetIndex: indexByAsset,
+	} {
+		if _, ok := existing[name]; !ok {
+			indexers[name] = f
+		}
+	}
+	if len(indexers) == 0 {
+		return nil
+	}
+	return informer.AddIndexers(indexers)
+}
+
+// HashIndexKey returns the key of GameServerHashIndex.
+func HashIndexKey(owner types.UID, hash string) string {
+	return string(owner) + "/" + hash
+}
+
+// AssetIndexKey returns the key of GameServerAssetIndex.
+func AssetIndexKey(cache *carrierv1alpha1.AssetCache) string {
+	return cache.HostPath + "/" + cache.Key
+}
+
+// ListGameServersByIndex returns the GameServers whose index is value.
+func ListGameServersByIndex(indexer cache.Indexer, index, value string) ([]*carrierv1alpha1.GameServer, error) {
+	objs, err := indexer.ByIndex(index, value)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*carrierv1alpha1.GameServer, 0, len(objs))
+	for _, obj := range objs {
+		if gs, ok := obj.(*carrierv1alpha1.GameServer); ok {
+			list = append(list, gs)
+		}
+	}
+	return list, nil
+}
+
+func indexByOwner(obj interface{}) ([]string, error) {
+	gs, ok := obj.(*carrierv1alpha1.GameServer)
+	if !ok {
+		return nil, nil
+	}
+	ref := metav1.GetControllerOf(gs)
+	if ref == nil {
+		return nil, nil
+	}
+	return []string{string(ref.UID)}, nil
+}
+
+func indexByHash(obj interface{}) ([]string, error) {
+	gs, ok := obj.(*carrierv1alpha1.GameServer)
+	if !ok {
+		return nil, nil
+	}
+	ref := metav1.GetControllerOf(gs)
+	hash, exist := gs.Labels[util.GameServerHash]
+	if ref == nil || !exist {
+		return nil, nil
+	}
+	return []string{HashIndexKey(ref.UID, hash)}, nil
+}
+
+func indexByAsset(obj interface{}) ([]string, error) {
+	gs, ok := obj.(*carrierv1alpha1.GameServer)
+	if !ok || len(gs.Status.NodeName) == 0 {
+		return nil, nil
+	}
+	cache := gs.Spec.AssetCache
+	if cache == nil || len(cache.HostPath) == 0 {
+		return nil, nil
+	}
+	for _, condition := range gs.Status.Conditions {
+		if condition.Type == carrierv1alpha1.GameServerAssetReady &&
+			condition.Status == carrierv1alpha1.ConditionTrue {
+			return []string{AssetIndexKey(cache)}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestGameServerIndexers(t *testing.T) {
+	controller := true
+	newGameServer := func(name, owner, node, hash string) *carrierv1alpha1.GameServer {
+		gs := &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default",
+				Labels: map[string]string{util.GameServerHash: hash}},
+			Status: carrierv1alpha1.GameServerStatus{NodeName: node},
+		}
+		if len(owner) != 0 {
+			gs.OwnerReferences = []metav1.OwnerReference{{Name: owner, UID: types.UID("uid-" + owner), Controller: &controller}}
+		}
+		return gs
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		GameServerOwnerIndex: indexByOwner,
+		GameServerHashIndex:  indexByHash,
+		GameServerAssetIndex: indexByAsset,
+	})
+	for _, gs := range []*carrierv1alpha1.GameServer{
+		newGameServer("a", "set1", "node1", "v1"),
+		newGameServer("b", "set1", "node2", "v2"),
+		newGameServer("c", "set2", "node1", "v1"),
+		newGameServer("d", "", "", "v1"),
+	} {
+		gs.Spec.AssetCache = &carrierv1alpha1.AssetCache{Key: gs.Labels[util.GameServerHash], HostPath: "/cache"}
+		gs.Status.Conditions = []carrierv1alpha1.GameServerCondition{
+			{Type: carrierv1alpha1.GameServerAssetReady, Status: carrierv1alpha1.ConditionTrue},
+		}
+		indexer.Add(gs)
+	}
+	for _, testCase := range []struct {
+		index  string
+		value  string
+		expect int
+	}{
+		{index: GameServerOwnerIndex, value: "uid-set1", expect: 2},
+		{index: GameServerOwnerIndex, value: "uid-set3", expect: 0},
+		{index: GameServerHashIndex, value: HashIndexKey("uid-set1", "v1"), expect: 1},
+		{index: GameServerHashIndex, value: HashIndexKey("uid-set2", "v2"), expect: 0},
+		{index: GameServerAssetIndex, value: AssetIndexKey(&carrierv1alpha1.AssetCache{Key: "v1", HostPath: "/cache"}), expect: 2},
+	} {
+		list, err := ListGameServersByIndex(indexer, testCase.index, testCase.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != testCase.expect {
+			t.Errorf("%v=%v: desired %v GameServers, get: %v", testCase.index, testCase.value, testCase.expect, len(list))
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/util/kube"
+)
+
+// NewPodInformer returns a function building the pod informer of kube informer factory,
+// which only caches the pods of GameServers instead of all pods of the cluster.
+// The managedFields of pods are dropped before caching if stripManagedFields is true.
+func NewPodInformer(stripManagedFields bool) func(kubernetes.Interface, time.Duration) cache.SharedIndexInformer {
+	return func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		var lw cache.ListerWatcher = &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = util.GameServerPodLabelKey
+				return client.CoreV1().Pods(metav1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = util.GameServerPodLabelKey
+				return client.CoreV1().Pods(metav1.NamespaceAll).Watch(options)
+			},
+		}
+		if stripManagedFields {
+			lw = kube.NewTransformingListWatch(lw, kube.StripManagedFields)
+		}
+		return cache.NewSharedIndexInformer(lw, &corev1.Pod{}, resync,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	}
+}
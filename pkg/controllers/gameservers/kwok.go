@@ -0,0 +1,52 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+const (
+	// kwokNodeAnnotation is the annotation of nodes simulated by kwok.
+	kwokNodeAnnotation = "kwok.x-k8s.io/node"
+	// simulatedGateMessage is the message of gates passed by the controller for simulated nodes.
+	simulatedGateMessage = "Simulated, pod runs on a kwok node"
+)
+
+// isSimulatedNode returns true if node is simulated by kwok, no container of pods on it
+// really runs.
+func isSimulatedNode(node *corev1.Node) bool {
+	return node != nil && node.Annotations[kwokNodeAnnotation] == "fake"
+}
+
+// simulateGates passes the gates of GameServer, which are reported by the SDK server
+// in the pod otherwise. Readiness gates are passed once pod runs, and deletable gates
+// are passed once GameServer is out of service.
+func simulateGates(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	if pod.Status.Phase == corev1.PodRunning {
+		for _, gate := range gs.Spec.ReadinessGates {
+			setGameServerCondition(gs, carrierv1alpha1.GameServerConditionType(gate),
+				carrierv1alpha1.ConditionTrue, simulatedGateMessage)
+		}
+	}
+	if IsOutOfService(gs) {
+		for _, gate := range gs.Spec.DeletableGates {
+			setGameServerCondition(gs, carrierv1alpha1.GameServerConditionType(gate),
+				carrierv1alpha1.ConditionTrue, simulatedGateMessage)
+		}
+	}
+}
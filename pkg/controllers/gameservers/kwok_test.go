@@ -0,0 +1,79 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestIsSimulatedNode(t *testing.T) {
+	tests := []struct {
+		name      string
+		node      *corev1.Node
+		simulated bool
+	}{
+		{
+			name: "no node",
+		},
+		{
+			name: "real node",
+			node: &corev1.Node{},
+		},
+		{
+			name: "kwok node",
+			node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{kwokNodeAnnotation: "fake"},
+			}},
+			simulated: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if simulated := isSimulatedNode(test.node); simulated != test.simulated {
+				t.Errorf("desired simulated %v, get: %v", test.simulated, simulated)
+			}
+		})
+	}
+}
+
+func TestSimulateGates(t *testing.T) {
+	effective := true
+	gs := &carrierv1alpha1.GameServer{
+		Spec: carrierv1alpha1.GameServerSpec{
+			ReadinessGates: []string{"ready"},
+			DeletableGates: []string{"no-players"},
+		},
+	}
+	pod := &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending}}
+	simulateGates(gs, pod)
+	if IsReady(gs) {
+		t.Errorf("desired not ready before pod runs")
+	}
+	pod.Status.Phase = corev1.PodRunning
+	simulateGates(gs, pod)
+	if !IsReady(gs) || deleteReady(gs) {
+		t.Errorf("desired ready but not deletable, get: %+v", gs.Status.Conditions)
+	}
+	gs.Spec.Constraints = []carrierv1alpha1.Constraint{{Type: carrierv1alpha1.NotInService, Effective: &effective}}
+	simulateGates(gs, pod)
+	if !deleteReady(gs) {
+		t.Errorf("desired deletable once out of service, get: %+v", gs.Status.Conditions)
+	}
+}
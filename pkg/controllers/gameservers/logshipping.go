@@ -0,0 +1,128 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+const (
+	// defaultLogPath is the directory of GameServer logs if not specified.
+	defaultLogPath = "/var/log/gameserver"
+	// logLabelsMountPath is the path log labels are mounted at in the log shipper.
+	logLabelsMountPath = "/etc/carrier/log-labels"
+	// logShipperConfigMountPath is the path the log shipper config is mounted at.
+	logShipperConfigMountPath = "/etc/carrier/log-shipper"
+)
+
+// injectLogShipper adds the log volume mounted in all containers, and the log shipper
+// sidecar, which reads the log labels of GameServer from files kept up to date by kubelet.
+func injectLogShipper(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	shipping := gs.Spec.LogShipping
+	if shipping == nil {
+		return
+	}
+	logPath := shipping.LogPath
+	if len(logPath) == 0 {
+		logPath = defaultLogPath
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes,
+		corev1.Volume{
+			Name:         util.LogVolumeName,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		},
+		corev1.Volume{
+			Name: util.LogLabelsVolumeName,
+			VolumeSource: corev1.VolumeSource{DownwardAPI: &corev1.DownwardAPIVolumeSource{
+				Items: []corev1.DownwardAPIVolumeFile{
+					logLabelFile("squad", fmt.Sprintf("metadata.labels['%v']", util.SquadNameLabelKey)),
+					logLabelFile("revision", fmt.Sprintf("metadata.labels['%v']", util.GameServerHash)),
+					logLabelFile("match-id", fmt.Sprintf("metadata.annotations['%v']", util.MatchIDAnnotation)),
+				},
+			}},
+		},
+	)
+	mount := corev1.VolumeMount{Name: util.LogVolumeName, MountPath: logPath}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			containers[i].VolumeMounts = append(containers[i].VolumeMounts, mount)
+			containers[i].Env = append(containers[i].Env, corev1.EnvVar{Name: util.LogDirEnv, Value: logPath})
+		}
+	}
+
+	sidecar := corev1.Container{
+		Name:      util.LogShipperContainerName,
+		Image:     shipping.Image,
+		Resources: *shipping.Resources.DeepCopy(),
+		Env: []corev1.EnvVar{
+			{Name: util.LogDirEnv, Value: logPath},
+			{Name: util.PodNameEnv, ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			}},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: util.LogVolumeName, MountPath: logPath, ReadOnly: true},
+			{Name: util.LogLabelsVolumeName, MountPath: logLabelsMountPath, ReadOnly: true},
+		},
+	}
+	if len(shipping.ConfigMap) != 0 {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: util.LogShipperConfigVolumeName,
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: shipping.ConfigMap},
+			}},
+		})
+		sidecar.VolumeMounts = append(sidecar.VolumeMounts, corev1.VolumeMount{
+			Name:      util.LogShipperConfigVolumeName,
+			MountPath: logShipperConfigMountPath,
+			ReadOnly:  true,
+		})
+	}
+	pod.Spec.Containers = append(pod.Spec.Containers, sidecar)
+}
+
+// logLabelFile returns the downward API file of a log label.
+func logLabelFile(name, fieldPath string) corev1.DownwardAPIVolumeFile {
+	return corev1.DownwardAPIVolumeFile{
+		Path:     name,
+		FieldRef: &corev1.ObjectFieldSelector{FieldPath: fieldPath},
+	}
+}
+
+// syncLogLabels copies the match id of GameServer to pod, as log labels are read from the
+// pod. It returns true if pod is changed.
+func syncLogLabels(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) bool {
+	if gs.Spec.LogShipping == nil {
+		return false
+	}
+	matchID, ok := gs.Annotations[util.MatchIDAnnotation]
+	if current, exist := pod.Annotations[util.MatchIDAnnotation]; current == matchID && exist == ok {
+		return false
+	}
+	if !ok {
+		delete(pod.Annotations, util.MatchIDAnnotation)
+		return true
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[util.MatchIDAnnotation] = matchID
+	return true
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestInjectLogShipper(t *testing.T) {
+	gs := &carrierv1alpha1.GameServer{
+		Spec: carrierv1alpha1.GameServerSpec{
+			LogShipping: &carrierv1alpha1.LogShipping{Image: "fluent-bit", ConfigMap: "fluent-bit-config"},
+		},
+	}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{{Name: util.GameServerContainerName}},
+	}}
+	injectLogShipper(gs, pod)
+	if len(pod.Spec.Volumes) != 3 {
+		t.Errorf("desired log, labels and config volumes, get: %+v", pod.Spec.Volumes)
+	}
+	if len(pod.Spec.Containers) != 2 || pod.Spec.Containers[1].Name != util.LogShipperContainerName {
+		t.Fatalf("desired log shipper sidecar last, get: %+v", pod.Spec.Containers)
+	}
+	server := pod.Spec.Containers[0]
+	if len(server.VolumeMounts) != 1 || server.VolumeMounts[0].MountPath != defaultLogPath {
+		t.Errorf("desired logs mounted at %v, get: %+v", defaultLogPath, server.VolumeMounts)
+	}
+	sidecar := pod.Spec.Containers[1]
+	if sidecar.Image != "fluent-bit" || len(sidecar.VolumeMounts) != 3 {
+		t.Errorf("desired sidecar mounting logs, labels and config, get: %+v", sidecar)
+	}
+}
+
+func TestSyncLogLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		gs      map[string]string
+		pod     map[string]string
+		changed bool
+	}{
+		{
+			name:    "match id added",
+			gs:      map[string]string{util.MatchIDAnnotation: "m1"},
+			changed: true,
+		},
+		{
+			name:    "match id unchanged",
+			gs:      map[string]string{util.MatchIDAnnotation: "m1"},
+			pod:     map[string]string{util.MatchIDAnnotation: "m1"},
+			changed: false,
+		},
+		{
+			name:    "match id removed",
+			pod:     map[string]string{util.MatchIDAnnotation: "m1"},
+			changed: true,
+		},
+		{
+			name:    "no match id",
+			changed: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				ObjectMeta: metav1.ObjectMeta{Annotations: test.gs},
+				Spec:       carrierv1alpha1.GameServerSpec{LogShipping: &carrierv1alpha1.LogShipping{}},
+			}
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: test.pod}}
+			if changed := syncLogLabels(gs, pod); changed != test.changed {
+				t.Errorf("desired changed %v, get: %v", test.changed, changed)
+			}
+			if pod.Annotations[util.MatchIDAnnotation] != test.gs[util.MatchIDAnnotation] {
+				t.Errorf("desired match id %q, get: %q", test.gs[util.MatchIDAnnotation],
+					pod.Annotations[util.MatchIDAnnotation])
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// OrphanPodPolicy describes how game server pods without owner, or whose GameServer is gone,
+// are handled.
+type OrphanPodPolicy string
+
+const (
+	// OrphanPodAdopt adopts the pod if the GameServer it is created for exists, otherwise deletes it.
+	OrphanPodAdopt OrphanPodPolicy = "Adopt"
+	// OrphanPodDelete deletes the pod.
+	OrphanPodDelete OrphanPodPolicy = "Delete"
+	// OrphanPodIgnore only reports the pod.
+	OrphanPodIgnore OrphanPodPolicy = "Ignore"
+)
+
+// Validate validates the orphan pod policy.
+func (p OrphanPodPolicy) Validate() error {
+	switch p {
+	case OrphanPodAdopt, OrphanPodDelete, OrphanPodIgnore:
+		return nil
+	}
+	return fmt.Errorf("unknown orphan pod policy %q", p)
+}
+
+// adoptionCandidate returns the GameServer the unowned pod could be adopted by, nil if
+// the GameServer is gone, being deleted, or recreated with the same name. The pod must
+// be created for the GameServer with the same UID, as recorded in its UID label or in
+// an owner reference which is not the controller.
+func adoptionCandidate(pod *corev1.Pod,
+	gameServers map[string]*carrierv1alpha1.GameServer) *carrierv1alpha1.GameServer {
+	name := pod.Labels[util.GameServerPodLabelKey]
+	if name != pod.Name {
+		return nil
+	}
+	gs, ok := gameServers[pod.Namespace+"/"+name]
+	if !ok || IsBeingDeleted(gs) {
+		return nil
+	}
+	if pod.Labels[util.GameServerUIDLabelKey] == string(gs.UID) || gameServerOwnerRef(pod, gs) != nil {
+		return gs
+	}
+	return nil
+}
+
+// gameServerOwnerRef returns the owner reference of pod to gs, nil if not found.
+func gameServerOwnerRef(pod *corev1.Pod, gs *carrierv1alpha1.GameServer) *metav1.OwnerReference {
+	for i := range pod.OwnerReferences {
+		ref := &pod.OwnerReferences[i]
+		if ref.Kind == "GameServer" && ref.Name == gs.Name && ref.UID == gs.UID {
+			return ref
+		}
+	}
+	return nil
+}
+
+// handleUnownedPod adopts or deletes the game server pod without owner according to
+// the orphan pod policy, returns true if the pod is adopted or deleted.
+func (c *Controller) handleUnownedPod(d discrepancy) bool {
+	pod := d.pod
+	switch c.orphanPodPolicy {
+	case OrphanPodIgnore, "":
+		return false
+	case OrphanPodAdopt:
+		if len(d.gameServers) != 0 {
+			gs := d.gameServers[0]
+			if err := c.adoptPod(gs, pod); err != nil {
+				klog.Errorf("Failed to adopt pod %v/%v: %v", pod.Namespace, pod.Name, err)
+				return false
+			}
+			c.recorder.Eventf(gs, corev1.EventTypeNormal, "PodAdopted", "Adopted orphan pod %v", pod.Name)
+			return true
+		}
+	}
+	return c.deleteOrphanPod(pod)
+}
+
+// handleOrphanPod deletes the game server pod whose controller GameServer is gone, unless
+// the orphan pod policy is Ignore, returns true if the pod is deleted.
+func (c *Controller) handleOrphanPod(pod *corev1.Pod) bool {
+	if c.orphanPodPolicy == OrphanPodIgnore || c.orphanPodPolicy == "" {
+		return false
+	}
+	return c.deleteOrphanPod(pod)
+}
+
+// deleteOrphanPod deletes pod, returns true if it is deleted.
+func (c *Controller) deleteOrphanPod(pod *corev1.Pod) bool {
+	err := c.kubeClient.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &pod.UID},
+	})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		klog.Errorf("Failed to delete orphan pod %v/%v: %v", pod.Namespace, pod.Name, err)
+		return false
+	}
+	klog.Infof("Deleted orphan pod %v/%v", pod.Namespace, pod.Name)
+	return true
+}
+
+// adoptPod sets gs as the controller of pod, turning the owner reference to gs into the
+// controller reference if the pod has one.
+func (c *Controller) adoptPod(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) error {
+	podCopy := pod.DeepCopy()
+	if ref := gameServerOwnerRef(podCopy, gs); ref != nil {
+		controller := true
+		ref.Controller = &controller
+	} else {
+		ref := metav1.NewControllerRef(gs, carrierv1alpha1.SchemeGroupVersion.WithKind("GameServer"))
+		podCopy.OwnerReferences = append(podCopy.OwnerReferences, *ref)
+	}
+	_, err := c.kubeClient.CoreV1().Pods(podCopy.Namespace).Update(podCopy)
+	return errors.Wrapf(err, "error setting owner of pod %v/%v", pod.Namespace, pod.Name)
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestAdoptionCandidate(t *testing.T) {
+	now := metav1.Now()
+	gameServers := map[string]*carrierv1alpha1.GameServer{
+		"default/a": {ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default", UID: "a-uid"}},
+		"default/b": {ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "default", UID: "b-uid",
+			DeletionTimestamp: &now}},
+	}
+	newPod := func(name, label string, uid types.UID) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default",
+			Labels: map[string]string{util.GameServerPodLabelKey: label, util.GameServerUIDLabelKey: string(uid)}}}
+	}
+	ownedPod := newPod("a", "a", "")
+	ownedPod.OwnerReferences = []metav1.OwnerReference{{Kind: "GameServer", Name: "a", UID: "a-uid"}}
+	for _, testCase := range []struct {
+		name   string
+		pod    *corev1.Pod
+		expect string
+	}{
+		{name: "GameServer exists", pod: newPod("a", "a", "a-uid"), expect: "a"},
+		{name: "owner reference not controller", pod: ownedPod, expect: "a"},
+		{name: "GameServer recreated", pod: newPod("a", "a", "old-uid")},
+		{name: "uid not recorded", pod: newPod("a", "a", "")},
+		{name: "GameServer being deleted", pod: newPod("b", "b", "b-uid")},
+		{name: "GameServer gone", pod: newPod("c", "c", "c-uid")},
+		{name: "name not match label", pod: newPod("x", "a", "a-uid")},
+	} {
+		gs := adoptionCandidate(testCase.pod, gameServers)
+		get := ""
+		if gs != nil {
+			get = gs.Name
+		}
+		if get != testCase.expect {
+			t.Errorf("%v: desired %q, get: %q", testCase.name, testCase.expect, get)
+		}
+	}
+}
+
+func TestHandleOrphanPod(t *testing.T) {
+	for _, testCase := range []struct {
+		policy  OrphanPodPolicy
+		deleted bool
+	}{
+		{policy: OrphanPodIgnore},
+		{policy: OrphanPodAdopt, deleted: true},
+		{policy: OrphanPodDelete, deleted: true},
+	} {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default"}}
+		kubeClient := fake.NewSimpleClientset(pod)
+		c := &Controller{kubeClient: kubeClient, orphanPodPolicy: testCase.policy}
+		if deleted := c.handleOrphanPod(pod); deleted != testCase.deleted {
+			t.Errorf("%v: desired deleted %v, get: %v", testCase.policy, testCase.deleted, deleted)
+		}
+		_, err := kubeClient.CoreV1().Pods("default").Get("a", metav1.GetOptions{})
+		if exists := err == nil; exists == testCase.deleted {
+			t.Errorf("%v: desired pod deleted %v, get exists: %v", testCase.policy, testCase.deleted, exists)
+		}
+	}
+}
+
+func TestOrphanPodPolicyValidate(t *testing.T) {
+	for _, policy := range []OrphanPodPolicy{OrphanPodAdopt, OrphanPodDelete, OrphanPodIgnore} {
+		if err := policy.Validate(); err != nil {
+			t.Errorf("desired %v valid, get: %v", policy, err)
+		}
+	}
+	if err := OrphanPodPolicy("Keep").Validate(); err == nil {
+		t.Errorf("desired Keep invalid, get valid")
+	}
+}
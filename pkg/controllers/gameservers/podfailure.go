@@ -0,0 +1,138 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// failedWaitingReasons are the reasons of waiting containers regarded as failures.
+var failedWaitingReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+// reconcilePodFailure copies the failure of pod into GameServer status and
+// sets the PodFailed condition, so failures are diagnosable from GameServer.
+func reconcilePodFailure(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	failure := getPodFailure(pod)
+	if failure == nil {
+		setGameServerCondition(gs, carrierv1alpha1.GameServerPodFailed, carrierv1alpha1.ConditionFalse, "")
+		return
+	}
+	gs.Status.PodFailure = failure
+	message := fmt.Sprintf("%v: %v", failure.Reason, failure.Message)
+	if len(failure.Container) != 0 {
+		message = fmt.Sprintf("container %v %v, exit code: %v", failure.Container, message, failure.ExitCode)
+	}
+	setGameServerCondition(gs, carrierv1alpha1.GameServerPodFailed, carrierv1alpha1.ConditionTrue, message)
+}
+
+// getPodFailure returns the failure of pod, failures of GameServer container
+// are preferred. Returns nil if pod is not failing.
+func getPodFailure(pod *corev1.Pod) *carrierv1alpha1.PodFailure {
+	if pod.Status.Phase == corev1.PodFailed && len(pod.Status.Reason) != 0 {
+		return &carrierv1alpha1.PodFailure{
+			Reason:  pod.Status.Reason,
+			Message: pod.Status.Message,
+		}
+	}
+	var failure *carrierv1alpha1.PodFailure
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...),
+		pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		containerFailure := getContainerFailure(&status)
+		if containerFailure == nil {
+			continue
+		}
+		if status.Name == util.GameServerContainerName {
+			return containerFailure
+		}
+		if failure == nil {
+			failure = containerFailure
+		}
+	}
+	return failure
+}
+
+// getContainerFailure returns the failure of container, the last termination is
+// used if the container is waiting to restart, e.g. CrashLoopBackOff after OOMKilled.
+func getContainerFailure(status *corev1.ContainerStatus) *carrierv1alpha1.PodFailure {
+	if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+		return &carrierv1alpha1.PodFailure{
+			Container: status.Name,
+			Reason:    terminated.Reason,
+			Message:   terminated.Message,
+			ExitCode:  terminated.ExitCode,
+		}
+	}
+	waiting := status.State.Waiting
+	if waiting == nil || !failedWaitingReasons[waiting.Reason] {
+		return nil
+	}
+	failure := &carrierv1alpha1.PodFailure{
+		Container: status.Name,
+		Reason:    waiting.Reason,
+		Message:   waiting.Message,
+	}
+	if last := status.LastTerminationState.Terminated; last != nil {
+		failure.Reason = fmt.Sprintf("%v(%v)", waiting.Reason, last.Reason)
+		failure.ExitCode = last.ExitCode
+	}
+	return failure
+}
+
+// setGameServerCondition sets the condition of GameServer, the condition is
+// not added if status is False and the condition does not exist.
+func setGameServerCondition(gs *carrierv1alpha1.GameServer, conditionType carrierv1alpha1.GameServerConditionType,
+	status carrierv1alpha1.ConditionStatus, message string) {
+	now := metav1.Now()
+	for i := range gs.Status.Conditions {
+		condition := &gs.Status.Conditions[i]
+		if condition.Type != conditionType {
+			continue
+		}
+		if condition.Status != status {
+			condition.Status = status
+			condition.LastTransitionTime = now
+		}
+		if condition.Message != message {
+			condition.Message = message
+			condition.LastProbeTime = now
+		}
+		return
+	}
+	if status == carrierv1alpha1.ConditionFalse {
+		return
+	}
+	gs.Status.Conditions = append(gs.Status.Conditions, carrierv1alpha1.GameServerCondition{
+		Type:               conditionType,
+		Status:             status,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+		Message:            message,
+	})
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestReconcilePodFailure(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		status    corev1.PodStatus
+		failure   *carrierv1alpha1.PodFailure
+		condition carrierv1alpha1.ConditionStatus
+	}{
+		{
+			name: "running",
+			status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name:  util.GameServerContainerName,
+						State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+					},
+				},
+			},
+		},
+		{
+			name: "evicted",
+			status: corev1.PodStatus{
+				Phase:   corev1.PodFailed,
+				Reason:  "Evicted",
+				Message: "The node was low on resource: memory.",
+			},
+			failure: &carrierv1alpha1.PodFailure{
+				Reason:  "Evicted",
+				Message: "The node was low on resource: memory.",
+			},
+			condition: carrierv1alpha1.ConditionTrue,
+		},
+		{
+			name: "image pull back off of sidecar",
+			status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: "sidecar",
+						State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+							Reason:  "ImagePullBackOff",
+							Message: "Back-off pulling image",
+						}},
+					},
+					{
+						Name:  util.GameServerContainerName,
+						State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+					},
+				},
+			},
+			failure: &carrierv1alpha1.PodFailure{
+				Container: "sidecar",
+				Reason:    "ImagePullBackOff",
+				Message:   "Back-off pulling image",
+			},
+			condition: carrierv1alpha1.ConditionTrue,
+		},
+		{
+			name: "crash loop back off after OOMKilled",
+			status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name:  util.GameServerContainerName,
+						State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+						LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+							Reason:   "OOMKilled",
+							ExitCode: 137,
+						}},
+					},
+				},
+			},
+			failure: &carrierv1alpha1.PodFailure{
+				Container: util.GameServerContainerName,
+				Reason:    "CrashLoopBackOff(OOMKilled)",
+				ExitCode:  137,
+			},
+			condition: carrierv1alpha1.ConditionTrue,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{}
+			reconcilePodFailure(gs, &corev1.Pod{Status: testCase.status})
+			if !reflect.DeepEqual(gs.Status.PodFailure, testCase.failure) {
+				t.Errorf("desired failure: %+v, get: %+v", testCase.failure, gs.Status.PodFailure)
+			}
+			var condition carrierv1alpha1.ConditionStatus
+			for _, c := range gs.Status.Conditions {
+				if c.Type == carrierv1alpha1.GameServerPodFailed {
+					condition = c.Status
+				}
+			}
+			if condition != testCase.condition {
+				t.Errorf("desired condition: %v, get: %v", testCase.condition, condition)
+			}
+		})
+	}
+}
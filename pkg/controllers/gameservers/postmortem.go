@@ -0,0 +1,227 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+const (
+	// defaultPostMortemTTL is the lifetime of the post-mortem Job after it finishes if not specified.
+	defaultPostMortemTTL int32 = 3600
+	// postMortemBackoffLimit is the number of retries of the post-mortem Job.
+	postMortemBackoffLimit int32 = 2
+	// postMortemActiveDeadline bounds how long the deletion of GameServer waits for its post-mortem Job.
+	postMortemActiveDeadline int64 = 600
+	// postMortemPollPeriod is how often the post-mortem Job is checked while GameServer is being deleted.
+	postMortemPollPeriod = 10 * time.Second
+)
+
+// injectDumpVolume mounts the dump directory of GameServer on node in all containers.
+func injectDumpVolume(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	postMortem := gs.Spec.PostMortem
+	if postMortem == nil {
+		return
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, dumpVolume(gs))
+	mount := corev1.VolumeMount{Name: util.DumpVolumeName, MountPath: postMortem.MountPath}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			containers[i].VolumeMounts = append(containers[i].VolumeMounts, mount)
+			containers[i].Env = append(containers[i].Env,
+				corev1.EnvVar{Name: util.DumpDirEnv, Value: postMortem.MountPath})
+		}
+	}
+}
+
+// dumpVolume returns the host path volume of the dump directory of GameServer.
+func dumpVolume(gs *carrierv1alpha1.GameServer) corev1.Volume {
+	hostPathType := corev1.HostPathDirectoryOrCreate
+	return corev1.Volume{
+		Name: util.DumpVolumeName,
+		VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{
+			Path: path.Join(gs.Spec.PostMortem.HostPath, gs.Namespace, gs.Name),
+			Type: &hostPathType,
+		}},
+	}
+}
+
+// artifactURL returns the URL the crash dumps of GameServer are uploaded to.
+func artifactURL(gs *carrierv1alpha1.GameServer) string {
+	return strings.TrimSuffix(gs.Spec.PostMortem.ArtifactPrefix, "/") + "/" + gs.Namespace + "/" + gs.Name
+}
+
+// postMortemJobName returns the name of the post-mortem Job of GameServer.
+func postMortemJobName(gs *carrierv1alpha1.GameServer) string {
+	return gs.Name + "-post-mortem"
+}
+
+// postMortemTolerations returns the tolerations of the post-mortem Job, which are those of
+// GameServer and the ones letting it run on a failing or cordoned node.
+func postMortemTolerations(gs *carrierv1alpha1.GameServer) []corev1.Toleration {
+	tolerations := append([]corev1.Toleration{}, gs.Spec.Template.Spec.Tolerations...)
+	return append(tolerations,
+		corev1.Toleration{
+			Key:      corev1.TaintNodeNotReady,
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoExecute,
+		},
+		corev1.Toleration{
+			Key:      corev1.TaintNodeUnreachable,
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoExecute,
+		},
+		corev1.Toleration{
+			Key:      corev1.TaintNodeUnschedulable,
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoSchedule,
+		},
+		corev1.Toleration{
+			Key:      ToBeDeletedTaint,
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoSchedule,
+		},
+	)
+}
+
+// buildPostMortemJob builds the Job uploading the crash dumps of GameServer, which runs on
+// the node of GameServer. It is owned by GameServer, whose deletion waits for the Job, so
+// that it is not garbage collected before uploading.
+func buildPostMortemJob(gs *carrierv1alpha1.GameServer) *batchv1.Job {
+	postMortem := gs.Spec.PostMortem
+	ttl := defaultPostMortemTTL
+	if postMortem.TTLSecondsAfterFinished != nil {
+		ttl = *postMortem.TTLSecondsAfterFinished
+	}
+	backoffLimit := postMortemBackoffLimit
+	container := *postMortem.Container.DeepCopy()
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: util.DumpDirEnv, Value: postMortem.MountPath},
+		corev1.EnvVar{Name: util.ArtifactURLEnv, Value: artifactURL(gs)},
+	)
+	container.VolumeMounts = append(container.VolumeMounts,
+		corev1.VolumeMount{Name: util.DumpVolumeName, MountPath: postMortem.MountPath})
+	labels := map[string]string{util.GameServerPodLabelKey: gs.Name}
+	if squad, ok := gs.Labels[util.SquadNameLabelKey]; ok {
+		labels[util.SquadNameLabelKey] = squad
+	}
+	activeDeadline := postMortemActiveDeadline
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      postMortemJobName(gs),
+			Namespace: gs.Namespace,
+			Labels:    labels,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(gs, carrierv1alpha1.SchemeGroupVersion.WithKind("GameServer")),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &activeDeadline,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					NodeName:      gs.Status.NodeName,
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{container},
+					Volumes:       []corev1.Volume{dumpVolume(gs)},
+					Tolerations:   postMortemTolerations(gs),
+				},
+			},
+		},
+	}
+}
+
+// syncPostMortem runs the post-mortem Job once GameServer fails, and links the artifact in
+// an event of GameServer.
+func (c *Controller) syncPostMortem(gs *carrierv1alpha1.GameServer) error {
+	if gs.Spec.PostMortem == nil || gs.Status.State != carrierv1alpha1.GameServerFailed {
+		return nil
+	}
+	if len(gs.Status.NodeName) == 0 {
+		// never scheduled, so nothing is dumped.
+		return nil
+	}
+	job := buildPostMortemJob(gs)
+	_, err := c.kubeClient.BatchV1().Jobs(gs.Namespace).Create(job)
+	if k8serrors.IsAlreadyExists(err) {
+		return c.replaceStalePostMortemJob(gs)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error creating post-mortem job for GameServer %s", gs.Name)
+	}
+	c.recorder.Eventf(gs, corev1.EventTypeWarning, string(gs.Status.State),
+		"Collecting crash dumps by Job %v into %v", job.Name, artifactURL(gs))
+	return nil
+}
+
+// replaceStalePostMortemJob deletes the existing post-mortem Job if it is not owned by GameServer,
+// e.g. left by a deleted GameServer of the same name, and returns an error so GameServer is
+// requeued to create its own.
+func (c *Controller) replaceStalePostMortemJob(gs *carrierv1alpha1.GameServer) error {
+	name := postMortemJobName(gs)
+	job, err := c.kubeClient.BatchV1().Jobs(gs.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "error getting post-mortem job for GameServer %s", gs.Name)
+	}
+	if metav1.IsControlledBy(job, gs) {
+		return nil
+	}
+	propagation := metav1.DeletePropagationBackground
+	err = c.kubeClient.BatchV1().Jobs(gs.Namespace).Delete(name, &metav1.DeleteOptions{
+		Preconditions:     metav1.NewUIDPreconditions(string(job.UID)),
+		PropagationPolicy: &propagation,
+	})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "error deleting stale post-mortem job for GameServer %s", gs.Name)
+	}
+	return errors.Errorf("stale post-mortem job %s of GameServer %s is being replaced", name, gs.Name)
+}
+
+// postMortemRunning returns if the post-mortem Job of GameServer has not finished yet.
+func (c *Controller) postMortemRunning(gs *carrierv1alpha1.GameServer) (bool, error) {
+	if gs.Spec.PostMortem == nil || gs.Status.State != carrierv1alpha1.GameServerFailed {
+		return false, nil
+	}
+	job, err := c.kubeClient.BatchV1().Jobs(gs.Namespace).Get(postMortemJobName(gs), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "error getting post-mortem job for GameServer %s", gs.Name)
+	}
+	if !metav1.IsControlledBy(job, gs) {
+		return false, nil
+	}
+	for _, cond := range job.Status.Conditions {
+		if (cond.Type == batchv1.JobComplete || cond.Type == batchv1.JobFailed) &&
+			cond.Status == corev1.ConditionTrue {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestSyncPostMortem(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, _, _, c, fakeClient := fakeController(ctx)
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default", UID: "uid1"},
+		Spec: carrierv1alpha1.GameServerSpec{
+			PostMortem: &carrierv1alpha1.PostMortem{
+				HostPath:       "/var/dumps",
+				MountPath:      "/dumps",
+				Container:      corev1.Container{Name: "upload", Image: "uploader"},
+				ArtifactPrefix: "s3://dumps/",
+			},
+		},
+		Status: carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning, NodeName: "node1"},
+	}
+	if err := c.syncPostMortem(gs); err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	if _, err := fakeClient.BatchV1().Jobs("default").Get(postMortemJobName(gs), metav1.GetOptions{}); err == nil {
+		t.Errorf("desired no job for running GameServer")
+	}
+
+	gs.Status.State = carrierv1alpha1.GameServerFailed
+	for i := 0; i < 2; i++ {
+		if err := c.syncPostMortem(gs); err != nil {
+			t.Fatalf("desired no error, get: %v", err)
+		}
+	}
+	job, err := fakeClient.BatchV1().Jobs("default").Get(postMortemJobName(gs), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("desired job created, get: %v", err)
+	}
+	if !metav1.IsControlledBy(job, gs) {
+		t.Errorf("desired job owned by GameServer, get: %v", job.OwnerReferences)
+	}
+	podSpec := job.Spec.Template.Spec
+	if podSpec.NodeName != "node1" {
+		t.Errorf("desired job on node1, get: %v", podSpec.NodeName)
+	}
+	if path := podSpec.Volumes[0].HostPath.Path; path != "/var/dumps/default/gs" {
+		t.Errorf("desired dump directory of GameServer, get: %v", path)
+	}
+	env := make(map[string]string)
+	for _, e := range podSpec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if env[util.ArtifactURLEnv] != "s3://dumps/default/gs" || env[util.DumpDirEnv] != "/dumps" {
+		t.Errorf("desired artifact url and dump dir, get: %v", env)
+	}
+}
+
+func TestSyncPostMortemStaleJob(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, _, _, c, fakeClient := fakeController(ctx)
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default", UID: "uid1"},
+		Spec: carrierv1alpha1.GameServerSpec{
+			PostMortem: &carrierv1alpha1.PostMortem{
+				HostPath:       "/var/dumps",
+				MountPath:      "/dumps",
+				Container:      corev1.Container{Name: "upload", Image: "uploader"},
+				ArtifactPrefix: "s3://dumps/",
+			},
+		},
+		Status: carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerFailed, NodeName: "node1"},
+	}
+	previous := gs.DeepCopy()
+	previous.UID = "uid0"
+	if _, err := fakeClient.BatchV1().Jobs("default").Create(buildPostMortemJob(previous)); err != nil {
+		t.Fatal(err)
+	}
+	if running, err := c.postMortemRunning(gs); err != nil || running {
+		t.Errorf("desired job of previous GameServer not waited for, get: %v, %v", running, err)
+	}
+	if err := c.syncPostMortem(gs); err == nil {
+		t.Errorf("desired requeue while replacing stale job")
+	}
+	if err := c.syncPostMortem(gs); err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	job, err := fakeClient.BatchV1().Jobs("default").Get(postMortemJobName(gs), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("desired job created, get: %v", err)
+	}
+	if !metav1.IsControlledBy(job, gs) {
+		t.Errorf("desired job owned by GameServer, get: %v", job.OwnerReferences)
+	}
+	if running, err := c.postMortemRunning(gs); err != nil || !running {
+		t.Errorf("desired unfinished job waited for, get: %v, %v", running, err)
+	}
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	if _, err = fakeClient.BatchV1().Jobs("default").UpdateStatus(job); err != nil {
+		t.Fatal(err)
+	}
+	if running, err := c.postMortemRunning(gs); err != nil || running {
+		t.Errorf("desired finished job not waited for, get: %v, %v", running, err)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+const (
+	resolverWebhookTimeout = 10 * time.Second
+	// resolverRetryInterval is how long a failed resolution is kept before it is retried.
+	resolverRetryInterval = 30 * time.Second
+)
+
+// AddressRequest is the private endpoint of a GameServer to be resolved.
+type AddressRequest struct {
+	// Namespace is the namespace of GameServer.
+	Namespace string `json:"namespace"`
+	// Name is the name of GameServer.
+	Name string `json:"name"`
+	// NodeName is the name of node the GameServer runs on.
+	NodeName string `json:"nodeName"`
+	// NodeIP is the internal IP of node.
+	NodeIP string `json:"nodeIP"`
+	// Ports are the ports of GameServer, with host ports allocated.
+	Ports []carrierv1alpha1.GameServerPort `json:"ports,omitempty"`
+}
+
+// AddressResolver translates the node private IP and host ports of a GameServer into
+// the public endpoint, e.g. the NAT or EIP of node.
+type AddressResolver interface {
+	// Resolve returns the public endpoint as load balancer status.
+	Resolve(request *AddressRequest) (*carrierv1alpha1.LoadBalancerStatus, error)
+}
+
+// webhookResolver posts the request to a webhook, which responds the load balancer status.
+type webhookResolver struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookResolver returns an AddressResolver calling the webhook at url.
+func NewWebhookResolver(url string) AddressResolver {
+	return &webhookResolver{
+		url:    url,
+		client: &http.Client{Timeout: resolverWebhookTimeout},
+	}
+}
+
+// Resolve posts the request as JSON, any non 2xx response is an error.
+func (r *webhookResolver) Resolve(request *AddressRequest) (*carrierv1alpha1.LoadBalancerStatus, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Post(r.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "error calling address resolver")
+	}
+	defer resp.Body.Close()
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, errors.Errorf("address resolver returned %v: %s", resp.StatusCode, body)
+	}
+	status := &carrierv1alpha1.LoadBalancerStatus{}
+	if err = json.Unmarshal(body, status); err != nil {
+		return nil, errors.Wrap(err, "could not decode address resolver response")
+	}
+	if len(status.Ingress) == 0 && len(status.Domain) == 0 {
+		return nil, errors.New("address resolver returned empty endpoint")
+	}
+	return status, nil
+}
+
+// asyncResolver calls the AddressResolver in background, so a slow resolver does not block
+// the workers syncing GameServers. The GameServer is enqueued once its resolution is done,
+// and failed resolutions are retried after resolverRetryInterval.
+type asyncResolver struct {
+	resolver     AddressResolver
+	enqueueAfter func(key string, after time.Duration)
+
+	lock        sync.Mutex
+	resolutions map[string]*addressResolution
+}
+
+// addressResolution is the resolution of a GameServer in progress or done.
+type addressResolution struct {
+	request *AddressRequest
+	done    bool
+	status  *carrierv1alpha1.LoadBalancerStatus
+	err     error
+	// retryAt is set once the error is reported, the resolution is retried after it.
+	retryAt time.Time
+}
+
+// newAsyncResolver returns an asyncResolver calling resolver, and enqueueAfter with the key
+// of GameServers to be synced again.
+func newAsyncResolver(resolver AddressResolver, enqueueAfter func(key string, after time.Duration)) *asyncResolver {
+	return &asyncResolver{
+		resolver:     resolver,
+		enqueueAfter: enqueueAfter,
+		resolutions:  make(map[string]*addressResolution),
+	}
+}
+
+// resolve returns the result of resolving request for the GameServer of key, done is false
+// while it is in progress. The resolution is started if none matching request is done or
+// in progress. The error of a failed resolution is returned once, later calls are not done
+// until it is retried.
+func (r *asyncResolver) resolve(key string, request *AddressRequest,
+	now time.Time) (status *carrierv1alpha1.LoadBalancerStatus, done bool, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	resolution, ok := r.resolutions[key]
+	switch {
+	case ok && !resolution.done:
+		return nil, false, nil
+	case ok && !reflect.DeepEqual(resolution.request, request):
+		// the GameServer is changed since resolved, e.g. its ports, so it is resolved again.
+	case ok && resolution.err == nil:
+		delete(r.resolutions, key)
+		return resolution.status, true, nil
+	case ok && resolution.retryAt.IsZero():
+		resolution.retryAt = now.Add(resolverRetryInterval)
+		r.enqueueAfter(key, resolverRetryInterval)
+		return nil, true, resolution.err
+	case ok && now.Before(resolution.retryAt):
+		return nil, false, nil
+	}
+	resolution = &addressResolution{request: request}
+	r.resolutions[key] = resolution
+	go func() {
+		status, err := r.resolver.Resolve(request)
+		r.lock.Lock()
+		resolution.status, resolution.err, resolution.done = status, err, true
+		r.lock.Unlock()
+		r.enqueueAfter(key, 0)
+	}()
+	return nil, false, nil
+}
+
+// forget drops the resolution of the GameServer of key, it should be called once the
+// GameServer is deleted.
+func (r *asyncResolver) forget(key string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.resolutions, key)
+}
+
+// resolveGameServerAddress resolves the public endpoint of GameServer once its pod is
+// scheduled, and stores it in the load balancer status. The AddressResolved condition
+// is True once resolved. GameServers reporting their endpoint are not resolved. The
+// resolution runs in background, GameServer is left unchanged while it is in progress.
+func (c *Controller) resolveGameServerAddress(gs *carrierv1alpha1.GameServer, node *corev1.Node) error {
+	if c.addressResolver == nil || node == nil || len(gs.Status.NodeName) == 0 ||
+		IsBeingDeleted(gs) || addressResolved(gs) {
+		return nil
+	}
+	if _, ok := gs.Annotations[util.GameServerExternalEndpointAnnotation]; ok {
+		return nil
+	}
+	status, done, err := c.addressResolver.resolve(gs.Namespace+"/"+gs.Name, &AddressRequest{
+		Namespace: gs.Namespace,
+		Name:      gs.Name,
+		NodeName:  node.Name,
+		NodeIP:    nodeInternalIP(node),
+		Ports:     gs.Spec.Ports,
+	}, time.Now())
+	if !done {
+		return nil
+	}
+	if err != nil {
+		setGameServerCondition(gs, carrierv1alpha1.GameServerAddressResolved, carrierv1alpha1.ConditionFalse,
+			err.Error())
+		return errors.Wrapf(err, "error resolving address of GameServer %v", gs.Name)
+	}
+	gs.Status.LoadBalancerStatus = status
+	setGameServerCondition(gs, carrierv1alpha1.GameServerAddressResolved, carrierv1alpha1.ConditionTrue, "")
+	return nil
+}
+
+// addressResolved checks if the public endpoint of GameServer is resolved.
+func addressResolved(gs *carrierv1alpha1.GameServer) bool {
+	for _, condition := range gs.Status.Conditions {
+		if condition.Type == carrierv1alpha1.GameServerAddressResolved {
+			return condition.Status == carrierv1alpha1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// nodeInternalIP returns the first internal IP of node.
+func nodeInternalIP(node *corev1.Node) string {
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeInternalIP {
+			return address.Address
+		}
+	}
+	return ""
+}
@@ -0,0 +1,178 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+type fakeResolver struct {
+	status *carrierv1alpha1.LoadBalancerStatus
+	err    error
+}
+
+func (r *fakeResolver) Resolve(request *AddressRequest) (*carrierv1alpha1.LoadBalancerStatus, error) {
+	return r.status, r.err
+}
+
+func TestWebhookResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &AddressRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if request.NodeIP != "10.0.0.1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(&carrierv1alpha1.LoadBalancerStatus{
+			Ingress: []carrierv1alpha1.LoadBalancerIngress{{IP: "1.2.3.4"}},
+		})
+	}))
+	defer server.Close()
+	resolver := NewWebhookResolver(server.URL)
+	status, err := resolver.Resolve(&AddressRequest{Name: "gs", NodeIP: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	if status.Ingress[0].IP != "1.2.3.4" {
+		t.Errorf("desired ip: 1.2.3.4, get: %v", status.Ingress[0].IP)
+	}
+	if _, err = resolver.Resolve(&AddressRequest{Name: "gs", NodeIP: "10.0.0.2"}); err == nil {
+		t.Errorf("desired error, get nil")
+	}
+}
+
+func TestResolveGameServerAddress(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+			{Type: corev1.NodeHostName, Address: "node"},
+			{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+		}},
+	}
+	resolved := &carrierv1alpha1.LoadBalancerStatus{
+		Ingress: []carrierv1alpha1.LoadBalancerIngress{{IP: "1.2.3.4"}},
+	}
+	for _, testCase := range []struct {
+		name         string
+		resolver     AddressResolver
+		nodeName     string
+		expectErr    bool
+		expectStatus *carrierv1alpha1.LoadBalancerStatus
+	}{
+		{
+			name:     "disabled",
+			nodeName: "node",
+		},
+		{
+			name:     "not scheduled",
+			resolver: &fakeResolver{status: resolved},
+		},
+		{
+			name:         "resolved",
+			resolver:     &fakeResolver{status: resolved},
+			nodeName:     "node",
+			expectStatus: resolved,
+		},
+		{
+			name:      "failed",
+			resolver:  &fakeResolver{err: errors.New("timeout")},
+			nodeName:  "node",
+			expectErr: true,
+		},
+	} {
+		c := &Controller{}
+		enqueued := make(chan string, 1)
+		if testCase.resolver != nil {
+			c.addressResolver = newAsyncResolver(testCase.resolver, func(key string, after time.Duration) {
+				if after == 0 {
+					enqueued <- key
+				}
+			})
+		}
+		gs := &carrierv1alpha1.GameServer{Status: carrierv1alpha1.GameServerStatus{NodeName: testCase.nodeName}}
+		err := c.resolveGameServerAddress(gs, node)
+		if err != nil || gs.Status.LoadBalancerStatus != nil {
+			t.Errorf("%v: desired nothing before resolved, get: %v, %+v", testCase.name, err, gs.Status.LoadBalancerStatus)
+		}
+		if testCase.resolver != nil && len(testCase.nodeName) != 0 {
+			select {
+			case <-enqueued:
+			case <-time.After(wait.ForeverTestTimeout):
+				t.Fatalf("%v: desired GameServer enqueued once resolved", testCase.name)
+			}
+			err = c.resolveGameServerAddress(gs, node)
+		}
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("%v: desired error: %v, get: %v", testCase.name, testCase.expectErr, err)
+		}
+		if gs.Status.LoadBalancerStatus != testCase.expectStatus {
+			t.Errorf("%v: desired status: %+v, get: %+v", testCase.name,
+				testCase.expectStatus, gs.Status.LoadBalancerStatus)
+		}
+		if addressResolved(gs) != (testCase.expectStatus != nil) {
+			t.Errorf("%v: desired resolved: %v", testCase.name, testCase.expectStatus != nil)
+		}
+	}
+	if ip := nodeInternalIP(node); ip != "10.0.0.1" {
+		t.Errorf("desired node ip: 10.0.0.1, get: %v", ip)
+	}
+}
+
+func TestAsyncResolverRetry(t *testing.T) {
+	now := time.Now()
+	enqueued := make(chan time.Duration, 2)
+	r := newAsyncResolver(&fakeResolver{err: errors.New("timeout")}, func(key string, after time.Duration) {
+		enqueued <- after
+	})
+	request := &AddressRequest{Name: "gs", NodeIP: "10.0.0.1"}
+	if _, done, _ := r.resolve("default/gs", request, now); done {
+		t.Fatalf("desired not done while resolving")
+	}
+	<-enqueued
+	if _, done, err := r.resolve("default/gs", request, now); !done || err == nil {
+		t.Fatalf("desired error once resolved, get: %v, %v", done, err)
+	}
+	if after := <-enqueued; after != resolverRetryInterval {
+		t.Errorf("desired enqueued after %v, get: %v", resolverRetryInterval, after)
+	}
+	if _, done, _ := r.resolve("default/gs", request, now.Add(time.Second)); done {
+		t.Errorf("desired error reported once before retried")
+	}
+	if len(enqueued) != 0 {
+		t.Errorf("desired not resolved again before retry interval")
+	}
+	if _, done, _ := r.resolve("default/gs", request, now.Add(resolverRetryInterval)); done {
+		t.Errorf("desired resolved again after retry interval")
+	}
+	<-enqueued
+	r.forget("default/gs")
+	if len(r.resolutions) != 0 {
+		t.Errorf("desired resolutions forgotten, get: %v", r.resolutions)
+	}
+}
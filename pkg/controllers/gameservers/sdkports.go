@@ -0,0 +1,262 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// SDKPorts is the port configuration of the SDK server sidecar.
+type SDKPorts struct {
+	// GRPCPort is the default gRPC port of SDK server.
+	GRPCPort int32
+	// HTTPPort is the default HTTP port of SDK server.
+	HTTPPort int32
+	// MinPort and MaxPort is the range alternative ports are selected from,
+	// if the default ports conflict with ports of the GameServer container.
+	MinPort int32
+	MaxPort int32
+	// HostNetworkMinPort and HostNetworkMaxPort is the range SDK port pairs are allocated
+	// from for GameServers in hostNetwork mode, so that each GameServer on a node
+	// gets distinct SDK ports. Pairs are shared by GameServers on different nodes.
+	HostNetworkMinPort int32
+	HostNetworkMaxPort int32
+}
+
+// Validate checks the SDK ports configuration.
+func (p *SDKPorts) Validate() error {
+	if p.MinPort <= 0 || p.MinPort > p.MaxPort || p.MaxPort > 65535 {
+		return errors.Errorf("invalid SDK port range %v-%v", p.MinPort, p.MaxPort)
+	}
+	if p.GRPCPort <= 0 || p.HTTPPort <= 0 || p.GRPCPort == p.HTTPPort {
+		return errors.Errorf("invalid SDK ports, gRPC: %v, HTTP: %v", p.GRPCPort, p.HTTPPort)
+	}
+	if p.HostNetworkMinPort <= 0 || p.HostNetworkMinPort >= p.HostNetworkMaxPort || p.HostNetworkMaxPort > 65535 {
+		return errors.Errorf("invalid hostNetwork SDK port range %v-%v", p.HostNetworkMinPort, p.HostNetworkMaxPort)
+	}
+	return nil
+}
+
+// injectSDKPorts selects the SDK ports not conflicting with the ports of GameServer
+// container and exports them to all containers of pod by env. Ports allocated for
+// GameServer in hostNetwork mode are used directly, and declared as host ports of the
+// GameServer container, so the scheduler never places GameServers sharing the ports onto
+// one node. Ports set by env explicitly are kept.
+func injectSDKPorts(gs *carrierv1alpha1.GameServer, pod *corev1.Pod, sdkPorts *SDKPorts) error {
+	if sdkPorts == nil {
+		return nil
+	}
+	if allocated := getAllocatedSDKPorts(gs); len(allocated) == 2 {
+		for i := range pod.Spec.Containers {
+			setEnvIfAbsent(&pod.Spec.Containers[i], util.SDKGRPCPortEnv, int32(allocated[0]))
+			setEnvIfAbsent(&pod.Spec.Containers[i], util.SDKHTTPPortEnv, int32(allocated[1]))
+		}
+		declareSDKHostPorts(pod, int32(allocated[0]), int32(allocated[1]))
+		return nil
+	}
+	used := gameServerContainerPorts(pod)
+	grpcPort, err := selectSDKPort(sdkPorts.GRPCPort, sdkPorts, used)
+	if err != nil {
+		return err
+	}
+	used[grpcPort] = true
+	httpPort, err := selectSDKPort(sdkPorts.HTTPPort, sdkPorts, used)
+	if err != nil {
+		return err
+	}
+	for i := range pod.Spec.Containers {
+		setEnvIfAbsent(&pod.Spec.Containers[i], util.SDKGRPCPortEnv, grpcPort)
+		setEnvIfAbsent(&pod.Spec.Containers[i], util.SDKHTTPPortEnv, httpPort)
+	}
+	return nil
+}
+
+// gameServerContainerPorts returns the container ports and host ports of GameServer container.
+func gameServerContainerPorts(pod *corev1.Pod) map[int32]bool {
+	used := make(map[int32]bool)
+	for _, container := range pod.Spec.Containers {
+		if container.Name != util.GameServerContainerName {
+			continue
+		}
+		for _, port := range container.Ports {
+			used[port.ContainerPort] = true
+			if port.HostPort != 0 {
+				used[port.HostPort] = true
+			}
+		}
+	}
+	return used
+}
+
+// declareSDKHostPorts adds the SDK ports as host ports of the GameServer container of pod,
+// unless they are declared already.
+func declareSDKHostPorts(pod *corev1.Pod, grpcPort, httpPort int32) {
+	used := gameServerContainerPorts(pod)
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		if container.Name != util.GameServerContainerName {
+			continue
+		}
+		for _, port := range []corev1.ContainerPort{
+			{Name: "sdk-grpc", ContainerPort: grpcPort, HostPort: grpcPort, Protocol: corev1.ProtocolTCP},
+			{Name: "sdk-http", ContainerPort: httpPort, HostPort: httpPort, Protocol: corev1.ProtocolTCP},
+		} {
+			if !used[port.ContainerPort] {
+				container.Ports = append(container.Ports, port)
+			}
+		}
+		return
+	}
+}
+
+// selectSDKPort returns port if it is not used, otherwise returns the first free port in the range.
+func selectSDKPort(port int32, sdkPorts *SDKPorts, used map[int32]bool) (int32, error) {
+	if !used[port] {
+		return port, nil
+	}
+	for candidate := sdkPorts.MinPort; candidate <= sdkPorts.MaxPort; candidate++ {
+		if candidate != sdkPorts.GRPCPort && candidate != sdkPorts.HTTPPort && !used[candidate] {
+			return candidate, nil
+		}
+	}
+	return 0, errors.Errorf("no free SDK port in range %v-%v", sdkPorts.MinPort, sdkPorts.MaxPort)
+}
+
+// setEnvIfAbsent sets env name of container to port if not set.
+func setEnvIfAbsent(container *corev1.Container, name string, port int32) {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return
+		}
+	}
+	container.Env = append(container.Env, corev1.EnvVar{Name: name, Value: strconv.Itoa(int(port))})
+}
+
+// getAllocatedSDKPorts returns the SDK ports allocated for GameServer in hostNetwork mode.
+func getAllocatedSDKPorts(gs *carrierv1alpha1.GameServer) []int {
+	value, ok := gs.Annotations[util.GameServerSDKPortsAnnotation]
+	if !ok {
+		return nil
+	}
+	var ports []int
+	for _, item := range strings.Split(value, ",") {
+		port, err := strconv.Atoi(item)
+		if err != nil {
+			klog.Warningf("Invalid SDK ports %q of GameServer %v/%v", value, gs.Namespace, gs.Name)
+			return nil
+		}
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// setAllocatedSDKPorts records the SDK ports allocated for GameServer.
+func setAllocatedSDKPorts(gs *carrierv1alpha1.GameServer, ports []int) {
+	items := make([]string, 0, len(ports))
+	for _, port := range ports {
+		items = append(items, strconv.Itoa(port))
+	}
+	if gs.Annotations == nil {
+		gs.Annotations = map[string]string{}
+	}
+	gs.Annotations[util.GameServerSDKPortsAnnotation] = strings.Join(items, ",")
+}
+
+// sdkPortAllocator allocates SDK port pairs to GameServers in hostNetwork mode. A pair is
+// shared by GameServers on different nodes, as its ports are declared as host ports and the
+// scheduler never places two GameServers sharing a pair onto one node. The pair shared by the
+// fewest GameServers is allocated, so the range caps the GameServers of a node rather than of
+// the cluster.
+type sdkPortAllocator struct {
+	sync.Mutex
+	min int
+	// users is the number of GameServers sharing each pair.
+	users []int
+	// pairs is the pair allocated by the uid of GameServer.
+	pairs map[string]int
+}
+
+// newSDKPortAllocator returns an allocator of the port pairs from min to max.
+func newSDKPortAllocator(min, max int) *sdkPortAllocator {
+	return &sdkPortAllocator{
+		min:   min,
+		users: make([]int, (max-min+1)/2),
+		pairs: make(map[string]int),
+	}
+}
+
+// Allocate returns the gRPC and HTTP ports of the pair allocated to the GameServer of uid.
+func (a *sdkPortAllocator) Allocate(uid string) ([]int, error) {
+	a.Lock()
+	defer a.Unlock()
+	if pair, ok := a.pairs[uid]; ok {
+		return a.ports(pair), nil
+	}
+	if len(a.users) == 0 {
+		return nil, ErrRangeFull
+	}
+	pair := 0
+	for i, users := range a.users {
+		if users < a.users[pair] {
+			pair = i
+		}
+	}
+	a.users[pair]++
+	a.pairs[uid] = pair
+	return a.ports(pair), nil
+}
+
+// SetUsed records ports allocated to the GameServer of uid before, ports not of a pair in
+// the range are ignored.
+func (a *sdkPortAllocator) SetUsed(uid string, ports []int) {
+	a.Lock()
+	defer a.Unlock()
+	if _, ok := a.pairs[uid]; ok || len(ports) != 2 || ports[1] != ports[0]+1 || (ports[0]-a.min)%2 != 0 {
+		return
+	}
+	pair := (ports[0] - a.min) / 2
+	if pair < 0 || pair >= len(a.users) {
+		return
+	}
+	a.users[pair]++
+	a.pairs[uid] = pair
+}
+
+// Release releases the pair allocated to the GameServer of uid.
+func (a *sdkPortAllocator) Release(uid string) {
+	a.Lock()
+	defer a.Unlock()
+	pair, ok := a.pairs[uid]
+	if !ok {
+		return
+	}
+	a.users[pair]--
+	delete(a.pairs, uid)
+}
+
+// ports returns the gRPC and HTTP ports of pair.
+func (a *sdkPortAllocator) ports(pair int) []int {
+	port := a.min + 2*pair
+	return []int{port, port + 1}
+}
@@ -0,0 +1,146 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestInjectSDKPorts(t *testing.T) {
+	sdkPorts := &SDKPorts{GRPCPort: 9020, HTTPPort: 9021, MinPort: 9020, MaxPort: 9030}
+	tests := []struct {
+		name     string
+		allocate string
+		ports    []corev1.ContainerPort
+		env      []corev1.EnvVar
+		want     []corev1.EnvVar
+	}{
+		{
+			name: "default ports",
+			want: []corev1.EnvVar{
+				{Name: util.SDKGRPCPortEnv, Value: "9020"},
+				{Name: util.SDKHTTPPortEnv, Value: "9021"},
+			},
+		},
+		{
+			name:  "conflict with container port",
+			ports: []corev1.ContainerPort{{ContainerPort: 9020}, {ContainerPort: 9022}},
+			want: []corev1.EnvVar{
+				{Name: util.SDKGRPCPortEnv, Value: "9023"},
+				{Name: util.SDKHTTPPortEnv, Value: "9021"},
+			},
+		},
+		{
+			name:  "conflict with host port",
+			ports: []corev1.ContainerPort{{ContainerPort: 7777, HostPort: 9021}},
+			want: []corev1.EnvVar{
+				{Name: util.SDKGRPCPortEnv, Value: "9020"},
+				{Name: util.SDKHTTPPortEnv, Value: "9022"},
+			},
+		},
+		{
+			name: "set explicitly",
+			env:  []corev1.EnvVar{{Name: util.SDKGRPCPortEnv, Value: "8000"}},
+			want: []corev1.EnvVar{
+				{Name: util.SDKGRPCPortEnv, Value: "8000"},
+				{Name: util.SDKHTTPPortEnv, Value: "9021"},
+			},
+		},
+		{
+			name:     "allocated in hostNetwork mode",
+			allocate: "9200,9201",
+			ports:    []corev1.ContainerPort{{ContainerPort: 9200}},
+			want: []corev1.EnvVar{
+				{Name: util.SDKGRPCPortEnv, Value: "9200"},
+				{Name: util.SDKHTTPPortEnv, Value: "9201"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: util.GameServerContainerName, Ports: tc.ports, Env: tc.env},
+					},
+				},
+			}
+			gs := &carrierv1alpha1.GameServer{}
+			if len(tc.allocate) != 0 {
+				gs.Annotations = map[string]string{util.GameServerSDKPortsAnnotation: tc.allocate}
+			}
+			if err := injectSDKPorts(gs, pod, sdkPorts); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pod.Spec.Containers[0].Env, tc.want) {
+				t.Errorf("desired env: %v, get: %v", tc.want, pod.Spec.Containers[0].Env)
+			}
+		})
+	}
+}
+
+func TestDeclareSDKHostPorts(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: util.GameServerContainerName, Ports: []corev1.ContainerPort{{ContainerPort: 9200}}},
+			},
+		},
+	}
+	gs := &carrierv1alpha1.GameServer{}
+	gs.Annotations = map[string]string{util.GameServerSDKPortsAnnotation: "9200,9201"}
+	sdkPorts := &SDKPorts{GRPCPort: 9020, HTTPPort: 9021, MinPort: 9020, MaxPort: 9030}
+	if err := injectSDKPorts(gs, pod, sdkPorts); err != nil {
+		t.Fatal(err)
+	}
+	want := []corev1.ContainerPort{
+		{ContainerPort: 9200},
+		{Name: "sdk-http", ContainerPort: 9201, HostPort: 9201, Protocol: corev1.ProtocolTCP},
+	}
+	if !reflect.DeepEqual(pod.Spec.Containers[0].Ports, want) {
+		t.Errorf("desired ports: %v, get: %v", want, pod.Spec.Containers[0].Ports)
+	}
+}
+
+func TestSDKPortAllocator(t *testing.T) {
+	a := newSDKPortAllocator(9200, 9205)
+	a.SetUsed("old", []int{9202, 9203})
+	var allocated [][]int
+	for _, uid := range []string{"a", "b", "c", "d"} {
+		ports, err := a.Allocate(uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		allocated = append(allocated, ports)
+	}
+	// pairs are shared once all of them are used, the least shared first.
+	want := [][]int{{9200, 9201}, {9204, 9205}, {9200, 9201}, {9202, 9203}}
+	if !reflect.DeepEqual(allocated, want) {
+		t.Errorf("desired pairs: %v, get: %v", want, allocated)
+	}
+	if ports, _ := a.Allocate("a"); !reflect.DeepEqual(ports, want[0]) {
+		t.Errorf("desired the pair allocated before, get: %v", ports)
+	}
+	a.Release("b")
+	if ports, _ := a.Allocate("e"); !reflect.DeepEqual(ports, []int{9204, 9205}) {
+		t.Errorf("desired the pair released, get: %v", ports)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+// dropAllCapabilities drops all capabilities of a container.
+const dropAllCapabilities corev1.Capability = "ALL"
+
+// applySecurityProfile sets the security context defaults of the security profile of GameServer
+// on pod, including the containers injected by carrier. Fields set by the template are kept,
+// the ones violating the profile are rejected at admission instead.
+func applySecurityProfile(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	profile := gs.Spec.SecurityProfile
+	if len(profile) == 0 || GetGameServerOS(gs) == carrierv1alpha1.Windows {
+		return
+	}
+	// seccomp fields of security context are not available yet, so the annotation is used.
+	if _, ok := pod.Annotations[corev1.SeccompPodAnnotationKey]; !ok {
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[corev1.SeccompPodAnnotationKey] = corev1.SeccompProfileRuntimeDefault
+	}
+	if profile == carrierv1alpha1.RestrictedSecurityProfile {
+		if pod.Spec.SecurityContext == nil {
+			pod.Spec.SecurityContext = &corev1.PodSecurityContext{}
+		}
+		if pod.Spec.SecurityContext.RunAsNonRoot == nil {
+			runAsNonRoot := true
+			pod.Spec.SecurityContext.RunAsNonRoot = &runAsNonRoot
+		}
+	}
+	for i := range pod.Spec.InitContainers {
+		applyContainerSecurityProfile(&pod.Spec.InitContainers[i], profile)
+	}
+	for i := range pod.Spec.Containers {
+		applyContainerSecurityProfile(&pod.Spec.Containers[i], profile)
+	}
+}
+
+// applyContainerSecurityProfile sets the security context defaults of profile on container.
+// Restricted containers drop all capabilities, apart from the ones added explicitly.
+func applyContainerSecurityProfile(container *corev1.Container, profile carrierv1alpha1.SecurityProfile) {
+	if container.SecurityContext == nil {
+		container.SecurityContext = &corev1.SecurityContext{}
+	}
+	sc := container.SecurityContext
+	if sc.Privileged == nil {
+		privileged := false
+		sc.Privileged = &privileged
+	}
+	if profile != carrierv1alpha1.RestrictedSecurityProfile {
+		return
+	}
+	if sc.AllowPrivilegeEscalation == nil {
+		allowPrivilegeEscalation := false
+		sc.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+	}
+	if sc.Capabilities == nil {
+		sc.Capabilities = &corev1.Capabilities{}
+	}
+	for _, capability := range sc.Capabilities.Drop {
+		if capability == dropAllCapabilities {
+			return
+		}
+	}
+	sc.Capabilities.Drop = append(sc.Capabilities.Drop, dropAllCapabilities)
+}
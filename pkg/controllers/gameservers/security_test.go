@@ -0,0 +1,86 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestApplySecurityProfile(t *testing.T) {
+	newPod := func() *corev1.Pod {
+		privileged := true
+		return &corev1.Pod{Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init"}},
+			Containers: []corev1.Container{
+				{Name: "server"},
+				{Name: "sidecar", SecurityContext: &corev1.SecurityContext{
+					Privileged:   &privileged,
+					Capabilities: &corev1.Capabilities{Drop: []corev1.Capability{dropAllCapabilities}},
+				}},
+			},
+		}}
+	}
+	newGameServer := func(profile carrierv1alpha1.SecurityProfile) *carrierv1alpha1.GameServer {
+		return &carrierv1alpha1.GameServer{Spec: carrierv1alpha1.GameServerSpec{SecurityProfile: profile}}
+	}
+
+	pod := newPod()
+	applySecurityProfile(newGameServer(""), pod)
+	if len(pod.Annotations) != 0 || pod.Spec.Containers[0].SecurityContext != nil {
+		t.Fatalf("desired pod unchanged without profile, get: %+v", pod)
+	}
+
+	pod = newPod()
+	applySecurityProfile(newGameServer(carrierv1alpha1.BaselineSecurityProfile), pod)
+	if pod.Annotations[corev1.SeccompPodAnnotationKey] != corev1.SeccompProfileRuntimeDefault {
+		t.Errorf("desired runtime default seccomp profile, get: %v", pod.Annotations)
+	}
+	sc := pod.Spec.InitContainers[0].SecurityContext
+	if sc == nil || sc.Privileged == nil || *sc.Privileged || sc.Capabilities != nil {
+		t.Errorf("desired baseline containers unprivileged only, get: %+v", sc)
+	}
+	if pod.Spec.SecurityContext != nil {
+		t.Errorf("desired baseline pods may run as root, get: %+v", pod.Spec.SecurityContext)
+	}
+	if !*pod.Spec.Containers[1].SecurityContext.Privileged {
+		t.Errorf("desired privileged of template kept")
+	}
+
+	pod = newPod()
+	applySecurityProfile(newGameServer(carrierv1alpha1.RestrictedSecurityProfile), pod)
+	if pod.Spec.SecurityContext == nil || !*pod.Spec.SecurityContext.RunAsNonRoot {
+		t.Errorf("desired restricted pods run as non-root, get: %+v", pod.Spec.SecurityContext)
+	}
+	sc = pod.Spec.Containers[0].SecurityContext
+	if *sc.AllowPrivilegeEscalation || len(sc.Capabilities.Drop) != 1 ||
+		sc.Capabilities.Drop[0] != dropAllCapabilities {
+		t.Errorf("desired restricted containers drop all capabilities, get: %+v", sc)
+	}
+	if drop := pod.Spec.Containers[1].SecurityContext.Capabilities.Drop; len(drop) != 1 {
+		t.Errorf("desired ALL dropped once, get: %v", drop)
+	}
+
+	gs := newGameServer(carrierv1alpha1.RestrictedSecurityProfile)
+	gs.Spec.OS = carrierv1alpha1.Windows
+	pod = newPod()
+	applySecurityProfile(gs, pod)
+	if len(pod.Annotations) != 0 || pod.Spec.SecurityContext != nil {
+		t.Errorf("desired windows pods unchanged, get: %+v", pod)
+	}
+}
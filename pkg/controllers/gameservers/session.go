@@ -0,0 +1,87 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+// reconcileSessionStartTime records when gs was allocated, and clears it once gs reports
+// no players or is not running. GameServers not reporting players may host sessions unknown
+// to carrier, so their session starts once they are running, and max session seconds bounds
+// their lifetime.
+func reconcileSessionStartTime(gs *carrierv1alpha1.GameServer, now time.Time) {
+	if !inSession(gs) {
+		gs.Status.SessionStartTime = nil
+		return
+	}
+	if gs.Status.SessionStartTime == nil {
+		start := metav1.NewTime(now)
+		gs.Status.SessionStartTime = &start
+	}
+}
+
+// inSession returns true if gs is running, and allocated or not reporting players.
+func inSession(gs *carrierv1alpha1.GameServer) bool {
+	return gs.Status.State == carrierv1alpha1.GameServerRunning && (IsAllocated(gs) || ReportedPlayers(gs) < 0)
+}
+
+// syncSessionDeadline marks the GameServer whose session exceeds spec.maxSessionSeconds
+// out of service, so it is drained and replaced. GameServer still within the deadline is
+// requeued to be checked again when the deadline is reached.
+func (c *Controller) syncSessionDeadline(key string, gs *carrierv1alpha1.GameServer) error {
+	remaining, ok := sessionDeadlineRemaining(gs, time.Now())
+	if !ok {
+		return nil
+	}
+	if remaining > 0 {
+		c.queue.AddAfter(key, remaining)
+		return nil
+	}
+	klog.Warningf("GameServer %v exceeds session deadline %vs, marking out of service", key, *gs.Spec.MaxSessionSeconds)
+	gsCopy := gs.DeepCopy()
+	AddNotInServiceConstraint(gsCopy)
+	for i := range gsCopy.Spec.Constraints {
+		if gsCopy.Spec.Constraints[i].Type == carrierv1alpha1.NotInService {
+			gsCopy.Spec.Constraints[i].Message = fmt.Sprintf("Session exceeds %vs", *gs.Spec.MaxSessionSeconds)
+		}
+	}
+	if _, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Update(gsCopy); err != nil {
+		return errors.Wrapf(err, "failed to mark GameServer %v exceeding session deadline out of service", key)
+	}
+	c.recorder.Eventf(gs, corev1.EventTypeWarning, "SessionDeadlineExceeded",
+		"Session started at %v exceeds %vs, marked out of service, players: %v",
+		gs.Status.SessionStartTime.UTC().Format(time.RFC3339), *gs.Spec.MaxSessionSeconds, getPlayers(gs))
+	return nil
+}
+
+// sessionDeadlineRemaining returns the time left before the session deadline of GameServer.
+// ok is false if GameServer has no session, no deadline or is already out of service.
+func sessionDeadlineRemaining(gs *carrierv1alpha1.GameServer, now time.Time) (remaining time.Duration, ok bool) {
+	if gs.Spec.MaxSessionSeconds == nil || gs.Status.SessionStartTime == nil || gs.DeletionTimestamp != nil ||
+		IsOutOfService(gs) {
+		return 0, false
+	}
+	deadline := gs.Status.SessionStartTime.Add(time.Duration(*gs.Spec.MaxSessionSeconds) * time.Second)
+	return deadline.Sub(now), true
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestReconcileSessionStartTime(t *testing.T) {
+	now := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{util.GameServerPlayersAnnotation: "0"}},
+		Status:     carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning},
+	}
+	reconcileSessionStartTime(gs, now)
+	if gs.Status.SessionStartTime != nil {
+		t.Errorf("desired no session without players, get: %v", gs.Status.SessionStartTime)
+	}
+	gs.Annotations[util.GameServerPlayersAnnotation] = "4"
+	reconcileSessionStartTime(gs, now)
+	reconcileSessionStartTime(gs, now.Add(time.Minute))
+	if gs.Status.SessionStartTime == nil || !gs.Status.SessionStartTime.Time.Equal(now) {
+		t.Errorf("desired session started at %v, get: %v", now, gs.Status.SessionStartTime)
+	}
+	gs.Annotations[util.GameServerPlayersAnnotation] = "0"
+	reconcileSessionStartTime(gs, now.Add(2*time.Minute))
+	if gs.Status.SessionStartTime != nil {
+		t.Errorf("desired session ended once players left, get: %v", gs.Status.SessionStartTime)
+	}
+	delete(gs.Annotations, util.GameServerPlayersAnnotation)
+	reconcileSessionStartTime(gs, now.Add(3*time.Minute))
+	if gs.Status.SessionStartTime == nil || !gs.Status.SessionStartTime.Time.Equal(now.Add(3*time.Minute)) {
+		t.Errorf("desired session started without players reported, get: %v", gs.Status.SessionStartTime)
+	}
+	gs.Status.State = carrierv1alpha1.GameServerExited
+	reconcileSessionStartTime(gs, now.Add(4*time.Minute))
+	if gs.Status.SessionStartTime != nil {
+		t.Errorf("desired session ended once not running, get: %v", gs.Status.SessionStartTime)
+	}
+}
+
+func TestSessionDeadlineRemaining(t *testing.T) {
+	start := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	maxSessionSeconds := int64(3600)
+	tests := []struct {
+		name      string
+		mutate    func(gs *carrierv1alpha1.GameServer)
+		remaining time.Duration
+		ok        bool
+	}{
+		{
+			name: "no deadline",
+		},
+		{
+			name: "within deadline",
+			mutate: func(gs *carrierv1alpha1.GameServer) {
+				gs.Spec.MaxSessionSeconds = &maxSessionSeconds
+			},
+			remaining: 30 * time.Minute,
+			ok:        true,
+		},
+		{
+			name: "out of service",
+			mutate: func(gs *carrierv1alpha1.GameServer) {
+				gs.Spec.MaxSessionSeconds = &maxSessionSeconds
+				AddNotInServiceConstraint(gs)
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sessionStart := metav1.NewTime(start)
+			gs := &carrierv1alpha1.GameServer{
+				Status: carrierv1alpha1.GameServerStatus{SessionStartTime: &sessionStart},
+			}
+			if tc.mutate != nil {
+				tc.mutate(gs)
+			}
+			remaining, ok := sessionDeadlineRemaining(gs, start.Add(30*time.Minute))
+			if ok != tc.ok || remaining != tc.remaining {
+				t.Errorf("desired %v, %v, get: %v, %v", tc.remaining, tc.ok, remaining, ok)
+			}
+		})
+	}
+}
+
+func TestSyncSessionDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, _, _, c, _ := fakeController(ctx)
+	c.queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer c.queue.ShutDown()
+	gs, err := c.carrierClient.CarrierV1alpha1().GameServers("default").Get("test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxSessionSeconds := int64(60)
+	sessionStart := metav1.NewTime(time.Now().Add(-time.Hour))
+	gs.Spec.MaxSessionSeconds = &maxSessionSeconds
+	gs.Status.SessionStartTime = &sessionStart
+	if err = c.syncSessionDeadline("default/test", gs); err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	gs, _ = c.carrierClient.CarrierV1alpha1().GameServers("default").Get("test", metav1.GetOptions{})
+	if !IsOutOfService(gs) {
+		t.Errorf("desired out of service after session deadline, get: %+v", gs.Spec.Constraints)
+	}
+}
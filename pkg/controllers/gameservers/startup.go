@@ -0,0 +1,79 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+// reconcileStartupMilestones records the startup milestones of gs reached, from the pod
+// if possible, otherwise now. Milestones are recorded only once, until gs is ready.
+func reconcileStartupMilestones(gs *carrierv1alpha1.GameServer, pod *corev1.Pod, now time.Time) {
+	if gs.Status.Startup != nil && gs.Status.Startup.ReadyTime != nil {
+		return
+	}
+	milestones := gs.Status.Startup.DeepCopy()
+	if milestones == nil {
+		milestones = &carrierv1alpha1.StartupMilestones{}
+	}
+	if milestones.ScheduledTime == nil && len(pod.Spec.NodeName) != 0 {
+		milestones.ScheduledTime = podConditionTime(pod, corev1.PodScheduled, now)
+	}
+	if milestones.PodRunningTime == nil && pod.Status.Phase == corev1.PodRunning {
+		milestones.PodRunningTime = containersStartedTime(pod, now)
+	}
+	if milestones.ReadyTime == nil && gs.Status.State == carrierv1alpha1.GameServerRunning && IsReady(gs) {
+		readyTime := metav1.NewTime(now)
+		milestones.ReadyTime = &readyTime
+	}
+	if *milestones != (carrierv1alpha1.StartupMilestones{}) {
+		gs.Status.Startup = milestones
+	}
+}
+
+// podConditionTime returns the last transition time of the condition of pod if it is True, otherwise now.
+func podConditionTime(pod *corev1.Pod, conditionType corev1.PodConditionType, now time.Time) *metav1.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == corev1.ConditionTrue &&
+			!condition.LastTransitionTime.IsZero() {
+			t := condition.LastTransitionTime
+			return &t
+		}
+	}
+	t := metav1.NewTime(now)
+	return &t
+}
+
+// containersStartedTime returns when the last container of pod started running, now if unknown.
+func containersStartedTime(pod *corev1.Pod, now time.Time) *metav1.Time {
+	var started metav1.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running == nil {
+			continue
+		}
+		if status.State.Running.StartedAt.After(started.Time) {
+			started = status.State.Running.StartedAt
+		}
+	}
+	if started.IsZero() {
+		started = metav1.NewTime(now)
+	}
+	return &started
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestReconcileStartupMilestones(t *testing.T) {
+	now := time.Now()
+	scheduled := metav1.NewTime(now.Add(-time.Minute))
+	started := metav1.NewTime(now.Add(-30 * time.Second))
+	gs := &carrierv1alpha1.GameServer{Status: carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerStarting}}
+	pod := &corev1.Pod{}
+
+	reconcileStartupMilestones(gs, pod, now)
+	if gs.Status.Startup != nil {
+		t.Errorf("desired no milestone before scheduled, get: %+v", gs.Status.Startup)
+	}
+
+	pod.Spec.NodeName = "node1"
+	pod.Status.Conditions = []corev1.PodCondition{
+		{Type: corev1.PodScheduled, Status: corev1.ConditionTrue, LastTransitionTime: scheduled}}
+	reconcileStartupMilestones(gs, pod, now)
+	if gs.Status.Startup == nil || !gs.Status.Startup.ScheduledTime.Equal(&scheduled) ||
+		gs.Status.Startup.PodRunningTime != nil {
+		t.Errorf("desired scheduled at %v, get: %+v", scheduled, gs.Status.Startup)
+	}
+
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: scheduled}}},
+		{State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: started}}},
+	}
+	gs.Status.State = carrierv1alpha1.GameServerRunning
+	reconcileStartupMilestones(gs, pod, now)
+	milestones := gs.Status.Startup
+	if !milestones.PodRunningTime.Equal(&started) || milestones.ReadyTime == nil {
+		t.Errorf("desired pod running at %v and ready, get: %+v", started, milestones)
+	}
+
+	// milestones are not changed after ready, e.g. in place updated.
+	readyTime := *milestones.ReadyTime
+	gs.Status.State = carrierv1alpha1.GameServerStarting
+	reconcileStartupMilestones(gs, pod, now.Add(time.Hour))
+	gs.Status.State = carrierv1alpha1.GameServerRunning
+	reconcileStartupMilestones(gs, pod, now.Add(time.Hour))
+	if !gs.Status.Startup.ReadyTime.Equal(&readyTime) {
+		t.Errorf("desired ready time %v kept, get: %v", readyTime, gs.Status.Startup.ReadyTime)
+	}
+}
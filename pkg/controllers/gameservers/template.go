@@ -0,0 +1,84 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+const (
+	// nameToken is replaced with the name of GameServer.
+	nameToken = "$(GS_NAME)"
+	// namespaceToken is replaced with the namespace of GameServer.
+	namespaceToken = "$(GS_NAMESPACE)"
+	// indexToken is replaced with the index of GameServer in its GameServerSet.
+	indexToken = "$(GS_INDEX)"
+	// hostPortTokenPrefix followed by the port name and ")" is replaced with the host port,
+	// or the first port of host port range, e.g. $(HOST_PORT_game).
+	hostPortTokenPrefix = "$(HOST_PORT_"
+)
+
+// expandPodTemplate replaces the tokens in command, args and env of containers with the
+// identity and the host ports of GameServer, so that dedicated servers can reference
+// them in command-line flags. Tokens not resolved are kept.
+func expandPodTemplate(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	replacer := strings.NewReplacer(templateTokens(gs)...)
+	expand := func(containers []corev1.Container) {
+		for i := range containers {
+			container := &containers[i]
+			for j := range container.Command {
+				container.Command[j] = replacer.Replace(container.Command[j])
+			}
+			for j := range container.Args {
+				container.Args[j] = replacer.Replace(container.Args[j])
+			}
+			for j := range container.Env {
+				container.Env[j].Value = replacer.Replace(container.Env[j].Value)
+			}
+		}
+	}
+	expand(pod.Spec.InitContainers)
+	expand(pod.Spec.Containers)
+}
+
+// templateTokens returns the tokens and their values of GameServer in pairs.
+func templateTokens(gs *carrierv1alpha1.GameServer) []string {
+	tokens := []string{nameToken, gs.Name, namespaceToken, gs.Namespace}
+	if index, ok := gs.Annotations[util.GameServerIndexAnnotation]; ok {
+		tokens = append(tokens, indexToken, index)
+	}
+	for _, port := range gs.Spec.Ports {
+		if len(port.Name) == 0 {
+			continue
+		}
+		var hostPort int32
+		switch {
+		case port.HostPort != nil:
+			hostPort = *port.HostPort
+		case port.HostPortRange != nil:
+			hostPort = port.HostPortRange.MinPort
+		default:
+			continue
+		}
+		tokens = append(tokens, hostPortTokenPrefix+port.Name+")", strconv.Itoa(int(hostPort)))
+	}
+	return tokens
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestExpandPodTemplate(t *testing.T) {
+	hostPort := int32(7001)
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "gs-abc",
+			Namespace:   "game",
+			Annotations: map[string]string{util.GameServerIndexAnnotation: "3"},
+		},
+		Spec: carrierv1alpha1.GameServerSpec{
+			Ports: []carrierv1alpha1.GameServerPort{
+				{Name: "game", HostPort: &hostPort},
+				{Name: "query", HostPortRange: &carrierv1alpha1.PortRange{MinPort: 8000, MaxPort: 8010}},
+				{Name: "lb"},
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Args: []string{"--dir=/data/$(GS_NAME)"}}},
+			Containers: []corev1.Container{
+				{
+					Command: []string{"server", "-port=$(HOST_PORT_game)", "-query=$(HOST_PORT_query)"},
+					Args:    []string{"-id=$(GS_NAMESPACE)/$(GS_NAME)-$(GS_INDEX)", "-lb=$(HOST_PORT_lb)"},
+					Env:     []corev1.EnvVar{{Name: "INDEX", Value: "$(GS_INDEX)"}, {Name: "HOME", Value: "$(HOME)"}},
+				},
+			},
+		},
+	}
+	expandPodTemplate(gs, pod)
+	expect := corev1.PodSpec{
+		InitContainers: []corev1.Container{{Args: []string{"--dir=/data/gs-abc"}}},
+		Containers: []corev1.Container{
+			{
+				Command: []string{"server", "-port=7001", "-query=8000"},
+				Args:    []string{"-id=game/gs-abc-3", "-lb=$(HOST_PORT_lb)"},
+				Env:     []corev1.EnvVar{{Name: "INDEX", Value: "3"}, {Name: "HOME", Value: "$(HOME)"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(pod.Spec, expect) {
+		t.Errorf("desired pod spec: %+v, get: %+v", expect, pod.Spec)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// topologyLabels maps labels of GameServer to the node topology labels they are copied from,
+// the stable labels are preferred to the deprecated beta ones.
+var topologyLabels = map[string][]string{
+	util.GameServerRegionLabelKey: {corev1.LabelZoneRegionStable, corev1.LabelZoneRegion},
+	util.GameServerZoneLabelKey:   {corev1.LabelZoneFailureDomainStable, corev1.LabelZoneFailureDomain},
+}
+
+// syncTopologyLabels copies the region and zone of node into labels of GameServer,
+// so that GameServers can be selected by where they run.
+func (c *Controller) syncTopologyLabels(gs *carrierv1alpha1.GameServer,
+	node *corev1.Node) (*carrierv1alpha1.GameServer, error) {
+	if node == nil {
+		return gs, nil
+	}
+	labels := getTopologyLabels(node)
+	changed := false
+	for key, value := range labels {
+		if gs.Labels[key] != value {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return gs, nil
+	}
+	gsCopy := gs.DeepCopy()
+	gsCopy.Labels = util.Merge(gsCopy.Labels, labels)
+	updated, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Update(gsCopy)
+	if err != nil {
+		return gs, errors.Wrapf(err, "error setting topology labels of GameServer %s", gs.Name)
+	}
+	return updated, nil
+}
+
+// getTopologyLabels returns the GameServer topology labels of node.
+func getTopologyLabels(node *corev1.Node) map[string]string {
+	labels := make(map[string]string)
+	for key, nodeLabels := range topologyLabels {
+		for _, nodeLabel := range nodeLabels {
+			if value, ok := node.Labels[nodeLabel]; ok && len(value) != 0 {
+				labels[key] = value
+				break
+			}
+		}
+	}
+	return labels
+}
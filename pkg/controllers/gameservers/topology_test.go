@@ -0,0 +1,62 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestGetTopologyLabels(t *testing.T) {
+	for _, testCase := range []struct {
+		name       string
+		nodeLabels map[string]string
+		desired    map[string]string
+	}{
+		{
+			name:    "no topology labels",
+			desired: map[string]string{},
+		},
+		{
+			name: "stable labels preferred",
+			nodeLabels: map[string]string{
+				corev1.LabelZoneRegionStable:        "ap-shanghai",
+				corev1.LabelZoneRegion:              "shanghai",
+				corev1.LabelZoneFailureDomainStable: "ap-shanghai-2",
+			},
+			desired: map[string]string{
+				util.GameServerRegionLabelKey: "ap-shanghai",
+				util.GameServerZoneLabelKey:   "ap-shanghai-2",
+			},
+		},
+		{
+			name:       "beta labels",
+			nodeLabels: map[string]string{corev1.LabelZoneRegion: "shanghai"},
+			desired:    map[string]string{util.GameServerRegionLabelKey: "shanghai"},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Labels: testCase.nodeLabels}}
+			if labels := getTopologyLabels(node); !reflect.DeepEqual(labels, testCase.desired) {
+				t.Errorf("desired labels %v, get: %v", testCase.desired, labels)
+			}
+		})
+	}
+}
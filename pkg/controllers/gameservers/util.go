@@ -15,6 +15,8 @@
 package gameservers
 
 import (
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -24,12 +26,17 @@ import (
 
 	"github.com/ocgi/carrier/pkg/apis/carrier"
 	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/builder"
 	"github.com/ocgi/carrier/pkg/util"
 )
 
 const (
 	// ToBeDeletedTaint is a taint used to make the node unschedulable.
 	ToBeDeletedTaint = "ToBeDeletedByClusterAutoscaler"
+	// defaultColocationWeight is the weight of colocation rules without a valid weight.
+	defaultColocationWeight = 50
+	// notInServiceMessage is the message of NotInService constraints added by controller.
+	notInServiceMessage = "Carrier controller mark this game server as not in service"
 )
 
 // ApplyDefaults applies default values to the GameServer if they are not already populated
@@ -120,6 +127,24 @@ func IsStopped(gs *carrierv1alpha1.GameServer) bool {
 		gs.Status.State == carrierv1alpha1.GameServerExited
 }
 
+// IsCompleted returns true if the OneShot GameServer has exited successfully.
+func IsCompleted(gs *carrierv1alpha1.GameServer) bool {
+	return gs.Status.State == carrierv1alpha1.GameServerCompleted
+}
+
+// IsOneShot returns true if the GameServer is not replaced once it exits successfully.
+func IsOneShot(gs *carrierv1alpha1.GameServer) bool {
+	return gs.Spec.CompletionPolicy == carrierv1alpha1.OneShot
+}
+
+// setFinishedTime records the time when GameServer became Exited, Failed or Completed.
+func setFinishedTime(gs *carrierv1alpha1.GameServer) {
+	if (IsStopped(gs) || IsCompleted(gs)) && gs.Status.FinishedTime == nil {
+		now := metav1.Now()
+		gs.Status.FinishedTime = &now
+	}
+}
+
 // IsBeforeRunning returns if GameServer is not running.
 func IsBeforeRunning(gs *carrierv1alpha1.GameServer) bool {
 	if gs.Status.State == "" || gs.Status.State == carrierv1alpha1.GameServerUnknown ||
@@ -143,19 +168,67 @@ func IsReady(gs *carrierv1alpha1.GameServer) bool {
 	return true
 }
 
-// IsOutOfService checks if a GameServer is marked out of service, and a delete candidate
-func IsOutOfService(gs *carrierv1alpha1.GameServer) bool {
-	for _, constraint := range gs.Spec.Constraints {
-		if constraint.Type != carrierv1alpha1.NotInService {
-			continue
-		}
-		if *constraint.Effective == true {
+// HasEffectiveConstraint checks if a GameServer has an effective constraint of constraintType.
+func HasEffectiveConstraint(gs *carrierv1alpha1.GameServer, constraintType carrierv1alpha1.ConstraintType) bool {
+	now := time.Now()
+	for i := range gs.Spec.Constraints {
+		if gs.Spec.Constraints[i].Type == constraintType && builder.IsConstraintEffective(&gs.Spec.Constraints[i], now) {
 			return true
 		}
 	}
 	return false
 }
 
+// IsOutOfService checks if a GameServer is marked out of service, and a delete candidate
+func IsOutOfService(gs *carrierv1alpha1.GameServer) bool {
+	return builder.IsOutOfService(gs)
+}
+
+// IsCordoned checks if a GameServer is cordoned, which is neither allocated, scaled down nor updated.
+func IsCordoned(gs *carrierv1alpha1.GameServer) bool {
+	return HasEffectiveConstraint(gs, carrierv1alpha1.Cordoned)
+}
+
+// AcceptsNewSessions checks if a GameServer could be allocated to new sessions, as far as
+// its constraints are concerned.
+func AcceptsNewSessions(gs *carrierv1alpha1.GameServer) bool {
+	return !IsOutOfService(gs) && !IsCordoned(gs) && !HasEffectiveConstraint(gs, carrierv1alpha1.NoNewSessions)
+}
+
+// IsAllocated checks if a GameServer not being deleted hosts players, as reported in the
+// players annotation. GameServers not reporting players are never allocated.
+func IsAllocated(gs *carrierv1alpha1.GameServer) bool {
+	return !IsBeingDeleted(gs) && ReportedPlayers(gs) > 0
+}
+
+// ReportedPlayers returns the players reported in the players annotation of GameServer, -1 if not
+// reported or invalid.
+func ReportedPlayers(gs *carrierv1alpha1.GameServer) int64 {
+	players, err := strconv.ParseInt(gs.Annotations[util.GameServerPlayersAnnotation], 10, 64)
+	if err != nil || players < 0 {
+		return -1
+	}
+	return players
+}
+
+// IsDraining checks if a running GameServer is out of service and waiting for its deletable gates.
+func IsDraining(gs *carrierv1alpha1.GameServer) bool {
+	return gs.Status.State == carrierv1alpha1.GameServerRunning && IsOutOfService(gs) &&
+		IsDeletableExist(gs) && !IsInPlaceUpdating(gs) && !deleteReady(gs)
+}
+
+// outOfServiceSince returns when the GameServer is marked out of service, nil if unknown.
+func outOfServiceSince(gs *carrierv1alpha1.GameServer) *metav1.Time {
+	now := time.Now()
+	for i := range gs.Spec.Constraints {
+		if gs.Spec.Constraints[i].Type == carrierv1alpha1.NotInService &&
+			builder.IsConstraintEffective(&gs.Spec.Constraints[i], now) {
+			return gs.Spec.Constraints[i].TimeAdded
+		}
+	}
+	return nil
+}
+
 // IsInPlaceUpdating checks if a GameServer is inplace updating
 func IsInPlaceUpdating(gs *carrierv1alpha1.GameServer) bool {
 	if len(gs.Annotations) == 0 {
@@ -164,6 +237,18 @@ func IsInPlaceUpdating(gs *carrierv1alpha1.GameServer) bool {
 	return gs.Annotations[util.GameServerInPlaceUpdatingAnnotation] == "true"
 }
 
+// IsUpdateSkipped checks if a GameServer opts out of in place updates and scale down,
+// either by annotation or being cordoned
+func IsUpdateSkipped(gs *carrierv1alpha1.GameServer) bool {
+	if IsCordoned(gs) {
+		return true
+	}
+	if len(gs.Annotations) == 0 {
+		return false
+	}
+	return gs.Annotations[util.GameServerSkipUpdateAnnotation] == "true"
+}
+
 // IsDynamicPortAllocated checks if ports allocated
 func IsDynamicPortAllocated(gs *carrierv1alpha1.GameServer) bool {
 	if len(gs.Annotations) == 0 {
@@ -174,7 +259,7 @@ func IsDynamicPortAllocated(gs *carrierv1alpha1.GameServer) bool {
 
 // CanInPlaceUpdating checks if a GameServer can inplace updating
 func CanInPlaceUpdating(gs *carrierv1alpha1.GameServer) bool {
-	if IsBeingDeleted(gs) {
+	if IsBeingDeleted(gs) || IsUpdateSkipped(gs) {
 		return false
 	}
 	if IsBeforeRunning(gs) {
@@ -191,6 +276,27 @@ func SetInPlaceUpdatingStatus(gs *carrierv1alpha1.GameServer, status string) {
 	gs.Annotations[util.GameServerInPlaceUpdatingAnnotation] = status
 }
 
+// SetGameVersion sets the game version of GameServer, and the label selecting by it.
+func SetGameVersion(gs *carrierv1alpha1.GameServer, version string) {
+	gs.Spec.GameVersion = version
+	if len(version) == 0 {
+		delete(gs.Labels, util.GameVersionLabelKey)
+		return
+	}
+	if gs.Labels == nil {
+		gs.Labels = make(map[string]string)
+	}
+	gs.Labels[util.GameVersionLabelKey] = version
+}
+
+// reconcileGameVersion surfaces the game version in status once pod runs the spec of GameServer.
+func reconcileGameVersion(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	if pod.Labels[util.GameServerHash] != gs.Labels[util.GameServerHash] {
+		return
+	}
+	gs.Status.GameVersion = gs.Spec.GameVersion
+}
+
 // buildPod build pod according to GameServerSpec
 func buildPod(gs *carrierv1alpha1.GameServer) (*corev1.Pod, error) {
 	pod := &corev1.Pod{
@@ -248,6 +354,11 @@ func buildPod(gs *carrierv1alpha1.GameServer) (*corev1.Pod, error) {
 		pod.Spec.Containers[i] = gsContainer
 	}
 
+	injectAssetCache(gs, pod)
+	injectTLS(gs, pod)
+	injectLogShipper(gs, pod)
+	injectDumpVolume(gs, pod)
+	expandPodTemplate(gs, pod)
 	if pod.Annotations == nil {
 		pod.Annotations = map[string]string{}
 	}
@@ -255,7 +366,14 @@ func buildPod(gs *carrierv1alpha1.GameServer) (*corev1.Pod, error) {
 		pod.Labels = map[string]string{}
 	}
 	injectPodScheduling(gs, pod)
+	injectPodColocation(gs, pod)
+	injectGPUModelAffinity(gs, pod)
+	injectPodOS(gs, pod)
+	applyPodDNSDefaults(pod)
+	injectPodBandwidth(gs, pod)
 	injectPodTolerations(pod)
+	applyCompletionPolicy(gs, pod)
+	applySecurityProfile(gs, pod)
 	return pod, nil
 }
 
@@ -265,6 +383,7 @@ func podObjectMeta(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
 	pod.Annotations = util.Merge(gs.Annotations, pod.Annotations)
 	pod.Labels[util.RoleLabelKey] = util.GameServerLabelRoleValue
 	pod.Labels[util.GameServerPodLabelKey] = gs.Name
+	pod.Labels[util.GameServerUIDLabelKey] = string(gs.UID)
 	ref := metav1.NewControllerRef(gs, carrierv1alpha1.SchemeGroupVersion.WithKind("GameServer"))
 	pod.OwnerReferences = append(pod.OwnerReferences, *ref)
 	// Add Carrier version into Pod Annotations
@@ -322,6 +441,153 @@ func injectPodScheduling(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
 	}
 }
 
+// injectPodColocation translates the colocation rules of GameServer into preferred
+// podAffinity/PodAntiAffinity terms selecting GameServer pods of the listed Squads.
+func injectPodColocation(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	for _, rule := range gs.Spec.Colocation {
+		if len(rule.Squads) == 0 {
+			continue
+		}
+		weight := rule.Weight
+		if weight <= 0 || weight > 100 {
+			weight = defaultColocationWeight
+		}
+		topologyKey := rule.TopologyKey
+		if len(topologyKey) == 0 {
+			topologyKey = corev1.LabelHostname
+		}
+		term := corev1.WeightedPodAffinityTerm{
+			Weight: weight,
+			PodAffinityTerm: corev1.PodAffinityTerm{
+				TopologyKey: topologyKey,
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{util.RoleLabelKey: util.GameServerLabelRoleValue},
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{
+							Key:      util.SquadNameLabelKey,
+							Operator: metav1.LabelSelectorOpIn,
+							Values:   rule.Squads,
+						},
+					},
+				},
+			},
+		}
+		if pod.Spec.Affinity == nil {
+			pod.Spec.Affinity = &corev1.Affinity{}
+		}
+		switch rule.Type {
+		case carrierv1alpha1.ColocationPrefer:
+			if pod.Spec.Affinity.PodAffinity == nil {
+				pod.Spec.Affinity.PodAffinity = &corev1.PodAffinity{}
+			}
+			affinity := pod.Spec.Affinity.PodAffinity
+			affinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+				affinity.PreferredDuringSchedulingIgnoredDuringExecution, term)
+		case carrierv1alpha1.ColocationAvoid:
+			if pod.Spec.Affinity.PodAntiAffinity == nil {
+				pod.Spec.Affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
+			}
+			antiAffinity := pod.Spec.Affinity.PodAntiAffinity
+			antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+				antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, term)
+		default:
+			klog.Warningf("Unknown colocation type %q of GameServer %v/%v", rule.Type, gs.Namespace, gs.Name)
+		}
+	}
+}
+
+// injectGPUModelAffinity restricts pod to nodes with the GPU models listed in annotation
+// of GameServer. The requirement is added to every node selector term, as terms are ORed.
+func injectGPUModelAffinity(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	var models []string
+	for _, model := range strings.Split(gs.Annotations[util.GameServerGPUModelsAnnotation], ",") {
+		if model = strings.TrimSpace(model); len(model) != 0 {
+			models = append(models, model)
+		}
+	}
+	if len(models) == 0 {
+		return
+	}
+	requirement := corev1.NodeSelectorRequirement{
+		Key:      util.GPUModelNodeLabel,
+		Operator: corev1.NodeSelectorOpIn,
+		Values:   models,
+	}
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := pod.Spec.Affinity.NodeAffinity
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+	selector := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(selector.NodeSelectorTerms) == 0 {
+		selector.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+	}
+	for i := range selector.NodeSelectorTerms {
+		term := &selector.NodeSelectorTerms[i]
+		term.MatchExpressions = append(term.MatchExpressions, requirement)
+	}
+}
+
+// injectPodOS schedules pod to nodes of the OS of GameServer if it is set, windows pods
+// also tolerate the taint keeping linux pods off windows nodes. Pods of GameServers
+// without OS are left to their own node selector.
+func injectPodOS(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	if len(gs.Spec.OS) == 0 {
+		return
+	}
+	os := gs.Spec.OS
+	if pod.Spec.NodeSelector == nil {
+		pod.Spec.NodeSelector = make(map[string]string)
+	}
+	if _, ok := pod.Spec.NodeSelector[corev1.LabelOSStable]; !ok {
+		pod.Spec.NodeSelector[corev1.LabelOSStable] = string(os)
+	}
+	if os != carrierv1alpha1.Windows {
+		return
+	}
+	pod.Spec.Tolerations = append(pod.Spec.Tolerations, corev1.Toleration{
+		Key:      util.WindowsTaintKey,
+		Operator: corev1.TolerationOpEqual,
+		Value:    string(carrierv1alpha1.Windows),
+		Effect:   corev1.TaintEffectNoSchedule,
+	})
+}
+
+// GetGameServerOS returns the OS of GameServer, defaults to linux.
+func GetGameServerOS(gs *carrierv1alpha1.GameServer) carrierv1alpha1.OperatingSystem {
+	if len(gs.Spec.OS) == 0 {
+		return carrierv1alpha1.Linux
+	}
+	return gs.Spec.OS
+}
+
+// applyPodDNSDefaults uses ClusterFirstWithHostNet for pods in host network without
+// DNS policy, otherwise they would fall back to the DNS of node and lose cluster DNS.
+func applyPodDNSDefaults(pod *corev1.Pod) {
+	if pod.Spec.HostNetwork && len(pod.Spec.DNSPolicy) == 0 {
+		pod.Spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet
+	}
+}
+
+// injectPodBandwidth translates the bandwidth limit of GameServer into pod annotations.
+func injectPodBandwidth(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	bandwidth := gs.Spec.Bandwidth
+	if bandwidth == nil {
+		return
+	}
+	if bandwidth.Ingress != nil {
+		pod.Annotations[util.IngressBandwidthAnnotation] = bandwidth.Ingress.String()
+	}
+	if bandwidth.Egress != nil {
+		pod.Annotations[util.EgressBandwidthAnnotation] = bandwidth.Egress.String()
+	}
+}
+
 // injectPodTolerations helps add tolerations to pod.
 // tolerate: NotReady、Unreachable
 func injectPodTolerations(pod *corev1.Pod) {
@@ -409,7 +675,7 @@ func NotInServiceConstraint() carrierv1alpha1.Constraint {
 	return carrierv1alpha1.Constraint{
 		Type:      carrierv1alpha1.NotInService,
 		Effective: &effective,
-		Message:   "Carrier controller mark this game server as not in service",
+		Message:   notInServiceMessage,
 		TimeAdded: &now,
 	}
 }
@@ -431,6 +697,11 @@ func updatePodSpec(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
 		pod.Labels = make(map[string]string)
 	}
 	pod.Labels[util.GameServerHash] = gs.Labels[util.GameServerHash]
+	if version, ok := gs.Labels[util.GameVersionLabelKey]; ok {
+		pod.Labels[util.GameVersionLabelKey] = version
+	} else {
+		delete(pod.Labels, util.GameVersionLabelKey)
+	}
 	for _, container := range gs.Spec.Template.Spec.Containers {
 		if container.Name != util.GameServerContainerName {
 			continue
@@ -0,0 +1,222 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestInjectPodColocation(t *testing.T) {
+	gs := &carrierv1alpha1.GameServer{
+		Spec: carrierv1alpha1.GameServerSpec{
+			Scheduling: carrierv1alpha1.MostAllocated,
+			Colocation: []carrierv1alpha1.ColocationRule{
+				{
+					Type:   carrierv1alpha1.ColocationAvoid,
+					Squads: []string{"match"},
+				},
+				{
+					Type:        carrierv1alpha1.ColocationPrefer,
+					Squads:      []string{"lobby", "chat"},
+					Weight:      80,
+					TopologyKey: corev1.LabelZoneFailureDomain,
+				},
+				{
+					Type: carrierv1alpha1.ColocationAvoid,
+				},
+			},
+		},
+	}
+	pod := &corev1.Pod{}
+	injectPodScheduling(gs, pod)
+	injectPodColocation(gs, pod)
+
+	antiAffinity := pod.Spec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+	if len(antiAffinity) != 1 {
+		t.Fatalf("desired 1 anti-affinity term, get: %+v", antiAffinity)
+	}
+	if antiAffinity[0].Weight != defaultColocationWeight ||
+		antiAffinity[0].PodAffinityTerm.TopologyKey != corev1.LabelHostname {
+		t.Errorf("desired default weight and topology key, get: %+v", antiAffinity[0])
+	}
+	affinity := pod.Spec.Affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+	if len(affinity) != 2 {
+		t.Fatalf("desired affinity terms of scheduling and colocation, get: %+v", affinity)
+	}
+	term := affinity[1]
+	if term.Weight != 80 || term.PodAffinityTerm.TopologyKey != corev1.LabelZoneFailureDomain {
+		t.Errorf("desired weight 80 and zone topology key, get: %+v", term)
+	}
+	expressions := term.PodAffinityTerm.LabelSelector.MatchExpressions
+	if len(expressions) != 1 || expressions[0].Key != util.SquadNameLabelKey || len(expressions[0].Values) != 2 {
+		t.Errorf("desired selector of Squads lobby and chat, get: %+v", expressions)
+	}
+}
+
+func TestInjectGPUModelAffinity(t *testing.T) {
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{util.GameServerGPUModelsAnnotation: "Tesla-T4, A100-SXM4-40GB,"},
+		},
+	}
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Affinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{
+							{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "zone"}}},
+							{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "region"}}},
+						},
+					},
+				},
+			},
+		},
+	}
+	injectGPUModelAffinity(gs, pod)
+	for _, term := range pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if len(term.MatchExpressions) != 2 {
+			t.Fatalf("desired GPU model requirement in each term, get: %+v", term)
+		}
+		requirement := term.MatchExpressions[1]
+		if requirement.Key != util.GPUModelNodeLabel || len(requirement.Values) != 2 ||
+			requirement.Values[1] != "A100-SXM4-40GB" {
+			t.Errorf("desired GPU models Tesla-T4 and A100-SXM4-40GB, get: %+v", requirement)
+		}
+	}
+
+	pod = &corev1.Pod{}
+	injectGPUModelAffinity(&carrierv1alpha1.GameServer{}, pod)
+	if pod.Spec.Affinity != nil {
+		t.Errorf("desired no affinity without GPU models, get: %+v", pod.Spec.Affinity)
+	}
+}
+
+func TestInjectPodOS(t *testing.T) {
+	pod := &corev1.Pod{}
+	injectPodOS(&carrierv1alpha1.GameServer{}, pod)
+	if pod.Spec.NodeSelector != nil || len(pod.Spec.Tolerations) != 0 {
+		t.Errorf("desired no node selector without OS, get: %+v", pod.Spec)
+	}
+
+	pod = &corev1.Pod{}
+	injectPodOS(&carrierv1alpha1.GameServer{
+		Spec: carrierv1alpha1.GameServerSpec{OS: carrierv1alpha1.Linux},
+	}, pod)
+	if pod.Spec.NodeSelector[corev1.LabelOSStable] != "linux" || len(pod.Spec.Tolerations) != 0 {
+		t.Errorf("desired linux node selector without toleration, get: %+v", pod.Spec)
+	}
+
+	pod = &corev1.Pod{}
+	injectPodOS(&carrierv1alpha1.GameServer{
+		Spec: carrierv1alpha1.GameServerSpec{OS: carrierv1alpha1.Windows},
+	}, pod)
+	if pod.Spec.NodeSelector[corev1.LabelOSStable] != "windows" {
+		t.Errorf("desired windows node selector, get: %v", pod.Spec.NodeSelector)
+	}
+	if len(pod.Spec.Tolerations) != 1 || pod.Spec.Tolerations[0].Key != util.WindowsTaintKey {
+		t.Errorf("desired toleration of windows taint, get: %+v", pod.Spec.Tolerations)
+	}
+}
+
+func TestApplyPodDNSDefaults(t *testing.T) {
+	for _, tc := range []struct {
+		hostNetwork bool
+		policy      corev1.DNSPolicy
+		desired     corev1.DNSPolicy
+	}{
+		{hostNetwork: true, desired: corev1.DNSClusterFirstWithHostNet},
+		{hostNetwork: true, policy: corev1.DNSDefault, desired: corev1.DNSDefault},
+		{hostNetwork: false, desired: ""},
+	} {
+		pod := &corev1.Pod{Spec: corev1.PodSpec{HostNetwork: tc.hostNetwork, DNSPolicy: tc.policy}}
+		applyPodDNSDefaults(pod)
+		if pod.Spec.DNSPolicy != tc.desired {
+			t.Errorf("host network %v, policy %q, desired %q, get: %q",
+				tc.hostNetwork, tc.policy, tc.desired, pod.Spec.DNSPolicy)
+		}
+	}
+}
+
+func TestInjectPodBandwidth(t *testing.T) {
+	ingress := resource.MustParse("10M")
+	gs := &carrierv1alpha1.GameServer{
+		Spec: carrierv1alpha1.GameServerSpec{
+			Bandwidth: &carrierv1alpha1.Bandwidth{Ingress: &ingress},
+		},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}
+	injectPodBandwidth(gs, pod)
+	if pod.Annotations[util.IngressBandwidthAnnotation] != "10M" {
+		t.Errorf("desired ingress bandwidth 10M, get: %v", pod.Annotations)
+	}
+	if _, ok := pod.Annotations[util.EgressBandwidthAnnotation]; ok {
+		t.Errorf("desired no egress bandwidth, get: %v", pod.Annotations)
+	}
+}
+
+func TestConstraintTypes(t *testing.T) {
+	effective, ineffective := true, false
+	expired := metav1.NewTime(time.Now().Add(-time.Minute))
+	tests := []struct {
+		name         string
+		constraint   carrierv1alpha1.Constraint
+		outOfService bool
+		cordoned     bool
+		accepts      bool
+	}{
+		{name: "not in service", constraint: carrierv1alpha1.Constraint{Type: carrierv1alpha1.NotInService,
+			Effective: &effective}, outOfService: true},
+		{name: "not in service expired", constraint: carrierv1alpha1.Constraint{Type: carrierv1alpha1.NotInService,
+			Effective: &effective, ExpiresAt: &expired}, accepts: true},
+		{name: "no new sessions", constraint: carrierv1alpha1.Constraint{Type: carrierv1alpha1.NoNewSessions,
+			Effective: &effective}},
+		{name: "cordoned", constraint: carrierv1alpha1.Constraint{Type: carrierv1alpha1.Cordoned,
+			Effective: &effective}, cordoned: true},
+		{name: "cordoned ineffective", constraint: carrierv1alpha1.Constraint{Type: carrierv1alpha1.Cordoned,
+			Effective: &ineffective}, accepts: true},
+		{name: "effective unset", constraint: carrierv1alpha1.Constraint{Type: carrierv1alpha1.NotInService},
+			accepts: true},
+		{name: "user defined", constraint: carrierv1alpha1.Constraint{Type: "example.com/Maintenance",
+			Effective: &effective}, accepts: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				Spec: carrierv1alpha1.GameServerSpec{Constraints: []carrierv1alpha1.Constraint{test.constraint}},
+			}
+			if get := IsOutOfService(gs); get != test.outOfService {
+				t.Errorf("desired out of service %v, get: %v", test.outOfService, get)
+			}
+			if get := IsCordoned(gs); get != test.cordoned {
+				t.Errorf("desired cordoned %v, get: %v", test.cordoned, get)
+			}
+			if get := IsUpdateSkipped(gs); get != test.cordoned {
+				t.Errorf("desired update skipped %v, get: %v", test.cordoned, get)
+			}
+			if get := AcceptsNewSessions(gs); get != test.accepts {
+				t.Errorf("desired accepts new sessions %v, get: %v", test.accepts, get)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// WarmNodePolicy weights the scheduling of GameServer pods toward nodes which already pulled
+// the game server image, or already cached the assets in the host path, so new GameServers
+// skip the downloads and become ready sooner, especially during large scale-ups.
+type WarmNodePolicy struct {
+	// Weight is the weight of the preferred node affinity term, in the range 1-100.
+	Weight int32
+	// MaxNodes is the max number of nodes listed in a node affinity term.
+	MaxNodes int
+}
+
+// Validate checks if the policy is valid.
+func (p *WarmNodePolicy) Validate() error {
+	if p.Weight < 1 || p.Weight > 100 {
+		return errors.Errorf("weight %v is not in the range 1-100", p.Weight)
+	}
+	if p.MaxNodes <= 0 {
+		return errors.Errorf("max nodes %v must be positive", p.MaxNodes)
+	}
+	return nil
+}
+
+// injectWarmNodeAffinity adds a preferred node affinity term for the nodes with the game
+// server image, and another for the nodes with the assets cached, so nodes with both are
+// preferred most. Failures only lose the preference, so they are logged but not returned.
+func (c *Controller) injectWarmNodeAffinity(gs *carrierv1alpha1.GameServer, pod *corev1.Pod) {
+	if c.warmNodes == nil {
+		return
+	}
+	var terms []corev1.PreferredSchedulingTerm
+	imageNodes, err := c.imageWarmNodes(pod)
+	if err != nil {
+		klog.Warningf("List nodes with image of GameServer %s/%s failed: %v", gs.Namespace, gs.Name, err)
+	}
+	if term := c.warmNodeTerm(imageNodes); term != nil {
+		terms = append(terms, *term)
+	}
+	assetNodes, err := c.assetWarmNodes(gs)
+	if err != nil {
+		klog.Warningf("List nodes with assets of GameServer %s/%s failed: %v", gs.Namespace, gs.Name, err)
+	}
+	if term := c.warmNodeTerm(assetNodes); term != nil {
+		terms = append(terms, *term)
+	}
+	if len(terms) == 0 {
+		return
+	}
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := pod.Spec.Affinity.NodeAffinity
+	nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+		nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution, terms...)
+}
+
+// warmNodeTerm returns the preferred node affinity term matching nodes, nil if nodes is empty.
+func (c *Controller) warmNodeTerm(nodes []string) *corev1.PreferredSchedulingTerm {
+	if len(nodes) == 0 {
+		return nil
+	}
+	sort.Strings(nodes)
+	if len(nodes) > c.warmNodes.MaxNodes {
+		nodes = nodes[:c.warmNodes.MaxNodes]
+	}
+	return &corev1.PreferredSchedulingTerm{
+		Weight: c.warmNodes.Weight,
+		Preference: corev1.NodeSelectorTerm{
+			MatchFields: []corev1.NodeSelectorRequirement{
+				{
+					Key:      "metadata.name",
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   nodes,
+				},
+			},
+		},
+	}
+}
+
+// imageWarmNodes returns the schedulable nodes reporting the image of the game server container.
+func (c *Controller) imageWarmNodes(pod *corev1.Pod) ([]string, error) {
+	var image string
+	for _, container := range pod.Spec.Containers {
+		if container.Name == util.GameServerContainerName {
+			image = container.Image
+			break
+		}
+	}
+	if len(image) == 0 {
+		return nil, nil
+	}
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, node := range nodes {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		if nodeHasImage(node, image) {
+			names = append(names, node.Name)
+		}
+	}
+	return names, nil
+}
+
+// assetWarmNodes returns the nodes where GameServers sharing the host path asset cache of gs
+// have downloaded the assets.
+func (c *Controller) assetWarmNodes(gs *carrierv1alpha1.GameServer) ([]string, error) {
+	cache := gs.Spec.AssetCache
+	if cache == nil || len(cache.HostPath) == 0 || c.gameServerIndexer == nil {
+		return nil, nil
+	}
+	list, err := ListGameServersByIndex(c.gameServerIndexer, GameServerAssetIndex, AssetIndexKey(cache))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(list))
+	var names []string
+	for _, warm := range list {
+		if seen[warm.Status.NodeName] {
+			continue
+		}
+		seen[warm.Status.NodeName] = true
+		names = append(names, warm.Status.NodeName)
+	}
+	return names, nil
+}
+
+// nodeHasImage checks if node reports image in its status. Names reported by kubelet are fully
+// qualified, e.g. docker.io/library/nginx:1.19, so image is also matched as a suffix.
+func nodeHasImage(node *corev1.Node, image string) bool {
+	for _, nodeImage := range node.Status.Images {
+		for _, name := range nodeImage.Names {
+			if name == image || strings.HasSuffix(name, "/"+image) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestInjectWarmNodeAffinity(t *testing.T) {
+	newNode := func(name string, unschedulable bool, images ...string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.NodeSpec{Unschedulable: unschedulable},
+			Status:     corev1.NodeStatus{Images: []corev1.ContainerImage{{Names: images}}},
+		}
+	}
+	newGameServer := func(name, node, key string, ready bool) *carrierv1alpha1.GameServer {
+		status := carrierv1alpha1.ConditionFalse
+		if ready {
+			status = carrierv1alpha1.ConditionTrue
+		}
+		return &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: carrierv1alpha1.GameServerSpec{
+				AssetCache: &carrierv1alpha1.AssetCache{Key: key, HostPath: "/cache"},
+			},
+			Status: carrierv1alpha1.GameServerStatus{
+				NodeName: node,
+				Conditions: []carrierv1alpha1.GameServerCondition{
+					{Type: carrierv1alpha1.GameServerAssetReady, Status: status},
+				},
+			},
+		}
+	}
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range []*corev1.Node{
+		newNode("node3", false, "docker.io/library/game:v1"),
+		newNode("node1", false, "game:v1"),
+		newNode("node2", false, "game:v0"),
+		newNode("node4", true, "game:v1"),
+	} {
+		nodeIndexer.Add(node)
+	}
+	gsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{GameServerAssetIndex: indexByAsset})
+	for _, gs := range []*carrierv1alpha1.GameServer{
+		newGameServer("a", "node2", "v1", true),
+		newGameServer("b", "node2", "v1", true),
+		newGameServer("c", "node3", "v1", false),
+		newGameServer("d", "node1", "v0", true),
+	} {
+		gsIndexer.Add(gs)
+	}
+	term := func(nodes ...string) corev1.PreferredSchedulingTerm {
+		return corev1.PreferredSchedulingTerm{
+			Weight: 50,
+			Preference: corev1.NodeSelectorTerm{
+				MatchFields: []corev1.NodeSelectorRequirement{
+					{Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: nodes},
+				},
+			},
+		}
+	}
+
+	for _, testCase := range []struct {
+		name     string
+		policy   *WarmNodePolicy
+		image    string
+		key      string
+		expected []corev1.PreferredSchedulingTerm
+	}{
+		{
+			name:   "disabled",
+			image:  "game:v1",
+			key:    "v1",
+			policy: nil,
+		},
+		{
+			name:     "image and assets",
+			image:    "game:v1",
+			key:      "v1",
+			policy:   &WarmNodePolicy{Weight: 50, MaxNodes: 10},
+			expected: []corev1.PreferredSchedulingTerm{term("node1", "node3"), term("node2")},
+		},
+		{
+			name:     "max nodes",
+			image:    "game:v1",
+			key:      "v2",
+			policy:   &WarmNodePolicy{Weight: 50, MaxNodes: 1},
+			expected: []corev1.PreferredSchedulingTerm{term("node1")},
+		},
+		{
+			name:   "cold",
+			image:  "game:v2",
+			key:    "v2",
+			policy: &WarmNodePolicy{Weight: 50, MaxNodes: 10},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := &Controller{
+				nodeLister:        corelisterv1.NewNodeLister(nodeIndexer),
+				gameServerIndexer: gsIndexer,
+				warmNodes:         testCase.policy,
+			}
+			gs := newGameServer("new", "", testCase.key, false)
+			pod := &corev1.Pod{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: util.GameServerContainerName, Image: testCase.image}},
+			}}
+			c.injectWarmNodeAffinity(gs, pod)
+			var terms []corev1.PreferredSchedulingTerm
+			if pod.Spec.Affinity != nil && pod.Spec.Affinity.NodeAffinity != nil {
+				terms = pod.Spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+			}
+			if !reflect.DeepEqual(terms, testCase.expected) {
+				t.Errorf("desired terms: %+v, get: %+v", testCase.expected, terms)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"github.com/ocgi/carrier/pkg/apis/carrier"
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+// stuckFinalizerCheckPeriod is the period GameServers with stuck finalizer are checked.
+const stuckFinalizerCheckPeriod = time.Minute
+
+// StuckFinalizerPolicy describes how GameServers being deleted for a long time,
+// whose pod is already gone but carrier finalizer is never removed, are handled.
+type StuckFinalizerPolicy struct {
+	// Threshold is how long a GameServer could be deleting before it is regarded as stuck.
+	Threshold time.Duration
+	// ForceRemove removes the carrier finalizer of stuck GameServers if true,
+	// otherwise a warning event is recorded and the GameServer is synced again.
+	ForceRemove bool
+}
+
+// checkStuckFinalizers detects GameServers with stuck finalizer and remediates them.
+func (c *Controller) checkStuckFinalizers() {
+	if c.stuckFinalizer == nil {
+		return
+	}
+	list, err := c.gameServerLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing GameServers"))
+		return
+	}
+	now := time.Now()
+	for _, gs := range list {
+		if !c.isFinalizerStuck(gs, now) {
+			continue
+		}
+		c.recorder.Eventf(gs, corev1.EventTypeWarning, "StuckFinalizer",
+			"GameServer has been deleting since %v but finalizer %v is not removed",
+			gs.DeletionTimestamp, carrier.GroupName)
+		if !c.stuckFinalizer.ForceRemove {
+			if key, err := cache.MetaNamespaceKeyFunc(gs); err == nil {
+				c.queue.Add(key)
+			}
+			continue
+		}
+		if err := c.removeFinalizer(gs.DeepCopy()); err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		klog.Warningf("Force removed finalizer %v of GameServer %v/%v", carrier.GroupName, gs.Namespace, gs.Name)
+	}
+}
+
+// isFinalizerStuck returns true if gs has been deleting longer than the threshold,
+// with the carrier finalizer but without pod.
+func (c *Controller) isFinalizerStuck(gs *carrierv1alpha1.GameServer, now time.Time) bool {
+	if gs.DeletionTimestamp == nil || now.Sub(gs.DeletionTimestamp.Time) < c.stuckFinalizer.Threshold {
+		return false
+	}
+	if !hasFinalizer(gs) {
+		return false
+	}
+	_, err := c.getGameServerPod(gs)
+	return k8serrors.IsNotFound(err)
+}
+
+// removeFinalizer removes the carrier finalizer of gs. The ports of gs are released first,
+// as done by syncGameServer for GameServers being deleted, so they are not leaked once gs is gone.
+func (c *Controller) removeFinalizer(gs *carrierv1alpha1.GameServer) error {
+	c.releasePorts(gs)
+	var fin []string
+	for _, f := range gs.Finalizers {
+		if f != carrier.GroupName {
+			fin = append(fin, f)
+		}
+	}
+	gs.Finalizers = fin
+	_, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Update(gs)
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	return errors.Wrapf(err, "error removing finalizer of GameServer %v/%v", gs.Namespace, gs.Name)
+}
+
+// hasFinalizer returns true if gs has the carrier finalizer.
+func hasFinalizer(gs *carrierv1alpha1.GameServer) bool {
+	for _, f := range gs.Finalizers {
+		if f == carrier.GroupName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog"
+
+	"github.com/ocgi/carrier/pkg/metrics"
+)
+
+const (
+	// minBatchSize is the minimum number of apiserver requests sent in parallel.
+	minBatchSize = 1
+	// initialBatchSize is the batch size before any latency observed.
+	initialBatchSize = 16
+	// batchLatencyTarget is the apiserver latency tolerated, the batch size
+	// is decreased if a request is slower.
+	batchLatencyTarget = 500 * time.Millisecond
+	// defaultThrottleDelay is the pause of all batches after a request throttled
+	// by the apiserver without suggesting a delay.
+	defaultThrottleDelay = time.Second
+)
+
+// operations of GameServers sent in batches.
+const (
+	operationCreate = "create"
+	operationDelete = "delete"
+	operationUpdate = "update"
+	operationMark   = "mark"
+)
+
+// batchSizer sizes the batches creating or deleting GameServers adaptively, in AIMD style.
+// The size increases by one after a batch whose requests are fast and successful, and
+// halves after a batch with a request slower than the target or failed by overload,
+// bounded by minBatchSize and BurstReplicas.
+// A request throttled by the apiserver priority and fairness backs off all the batches
+// of the controller, the size drops to minBatchSize, and no batch starts until the
+// delay suggested by the apiserver passed.
+type batchSizer struct {
+	sync.Mutex
+	size   int
+	target time.Duration
+	// throttledUntil is the time batches are paused until.
+	throttledUntil time.Time
+}
+
+// newBatchSizer returns a batchSizer with the initial batch size.
+func newBatchSizer() *batchSizer {
+	return &batchSizer{size: initialBatchSize, target: batchLatencyTarget}
+}
+
+// get returns the current batch size.
+func (b *batchSizer) get() int {
+	b.Lock()
+	defer b.Unlock()
+	return b.size
+}
+
+// observe adjusts the batch size according to the slowest latency of a batch, and
+// whether any request of the batch is failed by overload.
+func (b *batchSizer) observe(latency time.Duration, overloaded bool) {
+	b.Lock()
+	defer b.Unlock()
+	size := b.size
+	if overloaded || latency > b.target {
+		size = size / 2
+	} else {
+		size++
+	}
+	if size < minBatchSize {
+		size = minBatchSize
+	}
+	if size > BurstReplicas {
+		size = BurstReplicas
+	}
+	if size != b.size {
+		klog.V(4).Infof("Batch size changed from %v to %v, latency: %v, overloaded: %v",
+			b.size, size, latency, overloaded)
+	}
+	b.size = size
+}
+
+// throttle backs off all the batches after a request throttled by the apiserver.
+func (b *batchSizer) throttle(delay time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+	b.size = minBatchSize
+	if until := time.Now().Add(delay); until.After(b.throttledUntil) {
+		b.throttledUntil = until
+	}
+}
+
+// wait blocks until the batches are not throttled.
+func (b *batchSizer) wait() {
+	b.Lock()
+	delay := time.Until(b.throttledUntil)
+	b.Unlock()
+	if delay > 0 {
+		klog.V(4).Infof("Throttled by apiserver, wait %v", delay)
+		time.Sleep(delay)
+	}
+}
+
+// run calls fn for pieces in [0, count) in batches, pieces of a batch are called in
+// parallel. The batch size is adjusted after each batch, errors of all pieces are
+// aggregated. operation is the label of metrics recording throttled requests.
+func (b *batchSizer) run(operation string, count int, fn func(piece int) error) error {
+	var errs []error
+	for start := 0; start < count; {
+		b.wait()
+		end := start + b.get()
+		if end > count {
+			end = count
+		}
+		var (
+			wg            sync.WaitGroup
+			mu            sync.Mutex
+			slowest       time.Duration
+			overloaded    bool
+			throttled     bool
+			throttleDelay time.Duration
+		)
+		for piece := start; piece < end; piece++ {
+			wg.Add(1)
+			go func(piece int) {
+				defer wg.Done()
+				begin := time.Now()
+				err := fn(piece)
+				latency := time.Since(begin)
+				mu.Lock()
+				defer mu.Unlock()
+				if latency > slowest {
+					slowest = latency
+				}
+				if err != nil {
+					errs = append(errs, err)
+					overloaded = overloaded || isOverloaded(err)
+				}
+				if delay, ok := isThrottled(err); ok {
+					metrics.RecordAPIServerThrottled(operation)
+					throttled = true
+					if delay > throttleDelay {
+						throttleDelay = delay
+					}
+				}
+			}(piece)
+		}
+		wg.Wait()
+		if throttled {
+			b.throttle(throttleDelay)
+		} else {
+			b.observe(slowest, overloaded)
+		}
+		start = end
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// isThrottled checks if the error is a request throttled by the apiserver, and returns
+// the delay suggested.
+func isThrottled(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	err = errors.Cause(err)
+	if !k8serrors.IsTooManyRequests(err) {
+		return 0, false
+	}
+	if seconds, ok := k8serrors.SuggestsClientDelay(err); ok && seconds > 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	return defaultThrottleDelay, true
+}
+
+// isOverloaded checks if the error shows the apiserver is overloaded.
+func isOverloaded(err error) bool {
+	err = errors.Cause(err)
+	return k8serrors.IsTooManyRequests(err) || k8serrors.IsServerTimeout(err) ||
+		k8serrors.IsTimeout(err) || k8serrors.IsServiceUnavailable(err) || k8serrors.IsInternalError(err)
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestBatchSizerObserve(t *testing.T) {
+	for _, testCase := range []struct {
+		name       string
+		size       int
+		latency    time.Duration
+		overloaded bool
+		expect     int
+	}{
+		{name: "fast", size: 16, latency: time.Millisecond, expect: 17},
+		{name: "slow", size: 16, latency: time.Second, expect: 8},
+		{name: "overloaded", size: 16, latency: time.Millisecond, overloaded: true, expect: 8},
+		{name: "max", size: BurstReplicas, latency: time.Millisecond, expect: BurstReplicas},
+		{name: "min", size: minBatchSize, latency: time.Second, expect: minBatchSize},
+	} {
+		b := newBatchSizer()
+		b.size = testCase.size
+		b.observe(testCase.latency, testCase.overloaded)
+		if b.get() != testCase.expect {
+			t.Errorf("%v: desired size: %v, get: %v", testCase.name, testCase.expect, b.get())
+		}
+	}
+}
+
+func TestBatchSizerRun(t *testing.T) {
+	b := newBatchSizer()
+	var called int32
+	err := b.run(operationCreate, 40, func(piece int) error {
+		atomic.AddInt32(&called, 1)
+		if piece == 0 {
+			return k8serrors.NewServiceUnavailable("unavailable")
+		}
+		return nil
+	})
+	if called != 40 {
+		t.Errorf("desired called: 40, get: %v", called)
+	}
+	if err == nil {
+		t.Errorf("desired error, get nil")
+	}
+	// batches of 16, 8, 9 and 7 GameServers, halved after the first one overloaded.
+	if b.get() != 11 {
+		t.Errorf("desired size: 11, get: %v", b.get())
+	}
+}
+
+func TestIsOverloaded(t *testing.T) {
+	for _, testCase := range []struct {
+		err    error
+		expect bool
+	}{
+		{err: k8serrors.NewTooManyRequests("throttled", 1), expect: true},
+		{err: errors.Wrap(k8serrors.NewServiceUnavailable("unavailable"), "error creating"), expect: true},
+		{err: k8serrors.NewAlreadyExists(schema.GroupResource{}, "gs"), expect: false},
+		{err: fmt.Errorf("unknown"), expect: false},
+	} {
+		if overloaded := isOverloaded(testCase.err); overloaded != testCase.expect {
+			t.Errorf("error %v: desired overloaded: %v, get: %v", testCase.err, testCase.expect, overloaded)
+		}
+	}
+}
+
+func TestBatchSizerThrottle(t *testing.T) {
+	b := newBatchSizer()
+	b.throttle(time.Minute)
+	b.throttle(time.Second)
+	if b.get() != minBatchSize {
+		t.Errorf("desired size: %v, get: %v", minBatchSize, b.get())
+	}
+	if wait := time.Until(b.throttledUntil); wait <= time.Second {
+		t.Errorf("desired throttled for about a minute, get: %v", wait)
+	}
+}
+
+func TestIsThrottled(t *testing.T) {
+	for _, testCase := range []struct {
+		err         error
+		expect      bool
+		expectDelay time.Duration
+	}{
+		{err: nil, expect: false},
+		{err: k8serrors.NewTooManyRequests("throttled", 3), expect: true, expectDelay: 3 * time.Second},
+		{err: errors.Wrap(k8serrors.NewTooManyRequests("throttled", 0), "error creating"), expect: true,
+			expectDelay: defaultThrottleDelay},
+		{err: k8serrors.NewServiceUnavailable("unavailable"), expect: false},
+	} {
+		delay, throttled := isThrottled(testCase.err)
+		if throttled != testCase.expect || delay != testCase.expectDelay {
+			t.Errorf("error %v: desired throttled: %v, delay: %v, get: %v, %v", testCase.err,
+				testCase.expect, testCase.expectDelay, throttled, delay)
+		}
+	}
+}
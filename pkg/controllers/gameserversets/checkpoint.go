@@ -0,0 +1,118 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+// checkpointTimeout is how long the actions of a checkpoint could be unobserved, the
+// checkpoint is dropped after that, e.g. a created GameServer was deleted by others.
+const checkpointTimeout = 5 * time.Minute
+
+// appliedNames collects the names of GameServers applied by parallel pieces.
+type appliedNames struct {
+	sync.Mutex
+	names []string
+}
+
+func (a *appliedNames) add(name string) {
+	a.Lock()
+	defer a.Unlock()
+	a.names = append(a.names, name)
+}
+
+// observeCheckpoint drops the actions of checkpoint already observed in list, returns
+// nil if all actions are observed or the checkpoint is timeout.
+func observeCheckpoint(checkpoint *carrierv1alpha1.ReconcileCheckpoint,
+	list []*carrierv1alpha1.GameServer, now time.Time) *carrierv1alpha1.ReconcileCheckpoint {
+	if checkpoint == nil || now.Sub(checkpoint.LastTransitionTime.Time) > checkpointTimeout {
+		return nil
+	}
+	observed := make(map[string]*carrierv1alpha1.GameServer, len(list))
+	for _, gs := range list {
+		observed[gs.Name] = gs
+	}
+	result := &carrierv1alpha1.ReconcileCheckpoint{
+		Phase:              carrierv1alpha1.ReconcileObserve,
+		LastTransitionTime: checkpoint.LastTransitionTime,
+	}
+	for _, name := range checkpoint.Created {
+		if _, ok := observed[name]; !ok {
+			result.Created = append(result.Created, name)
+		}
+	}
+	for _, name := range checkpoint.Deleted {
+		if gs, ok := observed[name]; ok && gs.DeletionTimestamp == nil {
+			result.Deleted = append(result.Deleted, name)
+		}
+	}
+	if len(result.Created) == 0 && len(result.Deleted) == 0 {
+		return nil
+	}
+	return result
+}
+
+// excludeDeleted returns list without the GameServers deleted by checkpoint, which
+// are not observed as deleting yet.
+func excludeDeleted(list []*carrierv1alpha1.GameServer,
+	checkpoint *carrierv1alpha1.ReconcileCheckpoint) []*carrierv1alpha1.GameServer {
+	if checkpoint == nil || len(checkpoint.Deleted) == 0 {
+		return list
+	}
+	deleted := make(map[string]bool, len(checkpoint.Deleted))
+	for _, name := range checkpoint.Deleted {
+		deleted[name] = true
+	}
+	var result []*carrierv1alpha1.GameServer
+	for _, gs := range list {
+		if !deleted[gs.Name] {
+			result = append(result, gs)
+		}
+	}
+	return result
+}
+
+// pendingCreates returns the number of GameServers created by checkpoint but not observed yet.
+func pendingCreates(checkpoint *carrierv1alpha1.ReconcileCheckpoint) int {
+	if checkpoint == nil {
+		return 0
+	}
+	return len(checkpoint.Created)
+}
+
+// advanceCheckpoint moves the checkpoint of status to phase and records the actions applied.
+// The checkpoint is only kept if some actions are not observed yet.
+func advanceCheckpoint(status *statusWriter, phase carrierv1alpha1.ReconcilePhase, created, deleted []string) {
+	status.mutate(func(s *carrierv1alpha1.GameServerSetStatus) {
+		if s.Checkpoint == nil {
+			if len(created) == 0 && len(deleted) == 0 {
+				return
+			}
+			s.Checkpoint = &carrierv1alpha1.ReconcileCheckpoint{}
+		}
+		s.Checkpoint.Phase = phase
+		if len(created) != 0 || len(deleted) != 0 {
+			s.Checkpoint.LastTransitionTime = metav1.Now()
+			s.Checkpoint.Created = append(s.Checkpoint.Created, created...)
+			s.Checkpoint.Deleted = append(s.Checkpoint.Deleted, deleted...)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestObserveCheckpoint(t *testing.T) {
+	now := time.Now()
+	deleting := metav1.NewTime(now)
+	list := []*carrierv1alpha1.GameServer{
+		{ObjectMeta: metav1.ObjectMeta{Name: "created"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "deleting", DeletionTimestamp: &deleting}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "lagging"}},
+	}
+	for _, testCase := range []struct {
+		name       string
+		checkpoint *carrierv1alpha1.ReconcileCheckpoint
+		expect     *carrierv1alpha1.ReconcileCheckpoint
+	}{
+		{
+			name: "no checkpoint",
+		},
+		{
+			name: "all observed",
+			checkpoint: &carrierv1alpha1.ReconcileCheckpoint{LastTransitionTime: metav1.NewTime(now),
+				Created: []string{"created"}, Deleted: []string{"deleting", "gone"}},
+		},
+		{
+			name: "partially observed",
+			checkpoint: &carrierv1alpha1.ReconcileCheckpoint{LastTransitionTime: metav1.NewTime(now),
+				Created: []string{"created", "unobserved"}, Deleted: []string{"deleting", "lagging"}},
+			expect: &carrierv1alpha1.ReconcileCheckpoint{Phase: carrierv1alpha1.ReconcileObserve,
+				LastTransitionTime: metav1.NewTime(now), Created: []string{"unobserved"}, Deleted: []string{"lagging"}},
+		},
+		{
+			name: "timeout",
+			checkpoint: &carrierv1alpha1.ReconcileCheckpoint{LastTransitionTime: metav1.NewTime(now.Add(-time.Hour)),
+				Created: []string{"unobserved"}},
+		},
+	} {
+		get := observeCheckpoint(testCase.checkpoint, list, now)
+		if !reflect.DeepEqual(get, testCase.expect) {
+			t.Errorf("%v: desired %+v, get: %+v", testCase.name, testCase.expect, get)
+		}
+	}
+}
+
+func TestExcludeDeleted(t *testing.T) {
+	list := []*carrierv1alpha1.GameServer{
+		{ObjectMeta: metav1.ObjectMeta{Name: "a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+	}
+	get := excludeDeleted(list, &carrierv1alpha1.ReconcileCheckpoint{Deleted: []string{"a"}})
+	if len(get) != 1 || get[0].Name != "b" {
+		t.Errorf("desired [b], get: %v", get)
+	}
+	if get := excludeDeleted(list, nil); len(get) != 2 {
+		t.Errorf("desired 2 GameServers without checkpoint, get: %v", len(get))
+	}
+}
+
+func TestAdvanceCheckpoint(t *testing.T) {
+	status := &statusWriter{}
+	advanceCheckpoint(status, carrierv1alpha1.ReconcileFinalizeStatus, nil, nil)
+	if status.pending.Checkpoint != nil {
+		t.Errorf("desired no checkpoint without actions, get: %+v", status.pending.Checkpoint)
+	}
+	advanceCheckpoint(status, carrierv1alpha1.ReconcileApplyCreates, []string{"a"}, nil)
+	advanceCheckpoint(status, carrierv1alpha1.ReconcileApplyDeletes, nil, []string{"b"})
+	checkpoint := status.pending.Checkpoint
+	if checkpoint == nil || checkpoint.Phase != carrierv1alpha1.ReconcileApplyDeletes ||
+		!reflect.DeepEqual(checkpoint.Created, []string{"a"}) || !reflect.DeepEqual(checkpoint.Deleted, []string{"b"}) {
+		t.Errorf("desired created [a], deleted [b] in ApplyDeletes, get: %+v", checkpoint)
+	}
+	if pendingCreates(checkpoint) != 1 {
+		t.Errorf("desired 1 pending create, get: %v", pendingCreates(checkpoint))
+	}
+}
@@ -15,38 +15,42 @@
 package gameserversets
 
 import (
-	"context"
 	"fmt"
-	"math"
 	"reflect"
 	"strconv"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
-	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	networkinglisterv1 "k8s.io/client-go/listers/networking/v1"
+	policylisterv1beta1 "k8s.io/client-go/listers/policy/v1beta1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 
 	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/audit"
 	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
 	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
 	listerv1alpha1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
 	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/metrics"
 	"github.com/ocgi/carrier/pkg/util"
 	"github.com/ocgi/carrier/pkg/util/kube"
 )
@@ -56,28 +60,42 @@ var (
 	BurstReplicas = 64
 )
 
+// invalidDeletionCost is the reason of events on GameServers whose deletion cost is invalid.
+const invalidDeletionCost = "InvalidDeletionCost"
+
 // Counter caches the node GameServer location
 type Counter struct {
 	nodeGameServer map[string]uint64
+	// nodeResources is the extended resources requested by GameServers on each node.
+	nodeResources map[string]corev1.ResourceList
+	// warm is true once the counts are recovered from the lister, GameServer
+	// events are ignored before that.
+	warm bool
 	sync.RWMutex
 }
 
 func (c *Counter) count(node string) (uint64, bool) {
 	c.RLock()
-	c.RUnlock()
+	defer c.RUnlock()
 	count, ok := c.nodeGameServer[node]
 	return count, ok
 }
 
 func (c *Counter) inc(node string) {
 	c.Lock()
+	defer c.Unlock()
+	if !c.warm {
+		return
+	}
 	c.nodeGameServer[node] += 1
-	c.Unlock()
 }
 
 func (c *Counter) dec(node string) {
 	c.Lock()
 	defer c.Unlock()
+	if !c.warm {
+		return
+	}
 	count, ok := c.nodeGameServer[node]
 	if !ok {
 		return
@@ -88,43 +106,167 @@ func (c *Counter) dec(node string) {
 	}
 }
 
+// extendedResource returns the amount of extended resource name requested by GameServers on node.
+func (c *Counter) extendedResource(node string, name corev1.ResourceName) resource.Quantity {
+	c.RLock()
+	defer c.RUnlock()
+	return c.nodeResources[node][name].DeepCopy()
+}
+
+// addResources adds the extended resources requested by a GameServer on node.
+func (c *Counter) addResources(node string, requests corev1.ResourceList) {
+	if len(requests) == 0 {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if !c.warm {
+		return
+	}
+	if c.nodeResources == nil {
+		c.nodeResources = make(map[string]corev1.ResourceList)
+	}
+	list, ok := c.nodeResources[node]
+	if !ok {
+		list = corev1.ResourceList{}
+		c.nodeResources[node] = list
+	}
+	for name, quantity := range requests {
+		current := list[name]
+		current.Add(quantity)
+		list[name] = current
+	}
+}
+
+// subResources subtracts the extended resources requested by a GameServer on node.
+func (c *Counter) subResources(node string, requests corev1.ResourceList) {
+	if len(requests) == 0 {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if !c.warm {
+		return
+	}
+	list, ok := c.nodeResources[node]
+	if !ok {
+		return
+	}
+	for name, quantity := range requests {
+		current, ok := list[name]
+		if !ok {
+			continue
+		}
+		current.Sub(quantity)
+		if current.Sign() <= 0 {
+			delete(list, name)
+			continue
+		}
+		list[name] = current
+	}
+	if len(list) == 0 {
+		delete(c.nodeResources, node)
+	}
+}
+
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers/status,verbs=update
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameserversets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameserversets/status,verbs=update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=list;watch
+
 // Controller is a the GameServerSet controller
 type Controller struct {
 	counter             *Counter
+	replicas            *replicaCounter
+	batch               *batchSizer
+	kubeClient          kubernetes.Interface
 	carrierClient       versioned.Interface
 	gameServerLister    listerv1alpha1.GameServerLister
+	gameServerIndexer   cache.Indexer
 	gameServerSynced    cache.InformerSynced
 	gameServerSetLister listerv1alpha1.GameServerSetLister
 	gameServerSetSynced cache.InformerSynced
+	pdbLister           policylisterv1beta1.PodDisruptionBudgetLister
+	pdbSynced           cache.InformerSynced
+	networkPolicyLister networkinglisterv1.NetworkPolicyLister
+	networkPolicySynced cache.InformerSynced
 	workerQueue         workqueue.RateLimitingInterface
 	stop                <-chan struct{}
 	recorder            record.EventRecorder
+	// events dedupes the warnings persisting across syncs.
+	events *kube.EventDeduper
+	// priorityQueue is the queue of small GameServerSets, nil if the priority lane is disabled.
+	priorityQueue workqueue.RateLimitingInterface
+	priorityLane  *PriorityLane
+	// syncing is the keys being synced by either lane.
+	syncing keySet
+	// idleReaper is nil if idle GameServers are not scaled down first on resource pressure.
+	idleReaper *IdleReaper
+	nodeLister corelisterv1.NodeLister
+	nodeSynced cache.InformerSynced
+	// migrationMode rewrites the stale template hash of GameServers lazily instead of replacing them.
+	migrationMode bool
 }
 
 // NewController returns a new GameServerSet crd controller
 func NewController(
 	kubeClient kubernetes.Interface,
+	kubeInformerFactory informers.SharedInformerFactory,
 	carrierClient versioned.Interface,
-	carrierInformerFactory externalversions.SharedInformerFactory) *Controller {
+	carrierInformerFactory externalversions.SharedInformerFactory,
+	priorityLane *PriorityLane,
+	idleReaper *IdleReaper,
+	migrationMode bool) *Controller {
 
 	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
 	gsInformer := gameServers.Informer()
 	gameServerSets := carrierInformerFactory.Carrier().V1alpha1().GameServerSets()
 	gsSetInformer := gameServerSets.Informer()
+	pdbs := kubeInformerFactory.Policy().V1beta1().PodDisruptionBudgets()
+	networkPolicies := kubeInformerFactory.Networking().V1().NetworkPolicies()
 
 	c := &Controller{
 		counter:             &Counter{nodeGameServer: map[string]uint64{}},
+		replicas:            &replicaCounter{},
+		batch:               newBatchSizer(),
 		gameServerLister:    gameServers.Lister(),
+		gameServerIndexer:   gsInformer.GetIndexer(),
 		gameServerSynced:    gsInformer.HasSynced,
 		gameServerSetLister: gameServerSets.Lister(),
 		gameServerSetSynced: gsSetInformer.HasSynced,
+		pdbLister:           pdbs.Lister(),
+		pdbSynced:           pdbs.Informer().HasSynced,
+		networkPolicyLister: networkPolicies.Lister(),
+		networkPolicySynced: networkPolicies.Informer().HasSynced,
+		kubeClient:          kubeClient,
 		carrierClient:       carrierClient,
+		events:              kube.NewEventDeduper(),
+		migrationMode:       migrationMode,
+	}
+	if err := gameservers.AddGameServerIndexers(gsInformer); err != nil {
+		klog.Fatalf("Failed to add GameServer indexers: %v", err)
 	}
 	c.workerQueue = workqueue.NewRateLimitingQueue(
 		workqueue.NewItemFastSlowRateLimiter(20*time.Millisecond, 500*time.Millisecond, 5))
+	if priorityLane != nil && priorityLane.Workers > 0 {
+		c.priorityLane = priorityLane
+		c.priorityQueue = workqueue.NewRateLimitingQueue(
+			workqueue.NewItemFastSlowRateLimiter(20*time.Millisecond, 500*time.Millisecond, 5))
+	}
+	if idleReaper != nil && len(idleReaper.PressureConditions) != 0 {
+		nodes := kubeInformerFactory.Core().V1().Nodes()
+		c.idleReaper = idleReaper
+		c.nodeLister = nodes.Lister()
+		c.nodeSynced = nodes.Informer().HasSynced
+	}
 	s := scheme.Scheme
 	// Register operator types with the runtime scheme.
-	s.AddKnownTypes(carrierv1alpha1.SchemeGroupVersion, &carrierv1alpha1.GameServerSet{})
+	s.AddKnownTypes(carrierv1alpha1.SchemeGroupVersion, &carrierv1alpha1.GameServerSet{},
+		&carrierv1alpha1.GameServer{})
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartLogging(klog.Infof)
 	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
@@ -141,31 +283,64 @@ func NewController(
 			gs := obj.(*carrierv1alpha1.GameServer)
 			if gs.DeletionTimestamp == nil && len(gs.Status.NodeName) != 0 {
 				c.counter.inc(gs.Status.NodeName)
+				c.counter.addResources(gs.Status.NodeName, extendedResourceRequests(gs))
 			}
+			c.replicas.update(nil, gs)
 			c.gameServerEventHandler(gs)
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			gsOld := oldObj.(*carrierv1alpha1.GameServer)
 			gs := newObj.(*carrierv1alpha1.GameServer)
+			c.replicas.update(gsOld, gs)
 			// ignore if already being deleted
 			if gs.DeletionTimestamp == nil {
 				c.gameServerEventHandler(gs)
+				c.recordTimeToReady(gsOld, gs)
+				c.recordStartupMilestones(gsOld, gs)
 			}
 			if len(gsOld.Status.NodeName) == 0 && len(gs.Status.NodeName) != 0 {
 				c.counter.inc(gs.Status.NodeName)
+				c.counter.addResources(gs.Status.NodeName, extendedResourceRequests(gs))
+			} else if len(gs.Status.NodeName) != 0 {
+				// resources may be changed by inplace update.
+				oldRequests, requests := extendedResourceRequests(gsOld), extendedResourceRequests(gs)
+				if !apiequality.Semantic.DeepEqual(oldRequests, requests) {
+					c.counter.subResources(gs.Status.NodeName, oldRequests)
+					c.counter.addResources(gs.Status.NodeName, requests)
+				}
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
 			gs, ok := obj.(*carrierv1alpha1.GameServer)
 			if !ok {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					if gs, ok := tombstone.Obj.(*carrierv1alpha1.GameServer); ok {
+						c.events.Forget(gs.UID)
+					}
+				}
 				return
 			}
+			c.events.Forget(gs.UID)
 			if len(gs.Status.NodeName) != 0 {
 				c.counter.dec(gs.Status.NodeName)
+				c.counter.subResources(gs.Status.NodeName, extendedResourceRequests(gs))
 			}
+			c.replicas.update(gs, nil)
 			c.gameServerEventHandler(obj)
 		},
 	})
+	pdbs.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.handlePodDisruptionBudget(newObj)
+		},
+		DeleteFunc: c.handlePodDisruptionBudget,
+	})
+	networkPolicies.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.handleNetworkPolicy(newObj)
+		},
+		DeleteFunc: c.handleNetworkPolicy,
+	})
 	return c
 }
 
@@ -173,12 +348,25 @@ func NewController(
 // Runs threadiness number workers to process the rate limited queue
 func (c *Controller) Run(workers int, stop <-chan struct{}) error {
 	klog.Info("Wait for cache sync")
-	if !cache.WaitForCacheSync(stop, c.gameServerSynced, c.gameServerSetSynced) {
+	synced := []cache.InformerSynced{c.gameServerSynced, c.gameServerSetSynced, c.pdbSynced,
+		c.networkPolicySynced}
+	if c.nodeSynced != nil {
+		synced = append(synced, c.nodeSynced)
+	}
+	if !cache.WaitForCacheSync(stop, synced...) {
 		return errors.New("failed to wait for caches to sync")
 	}
+	if err := c.warmUp(); err != nil {
+		return err
+	}
 	for i := 0; i < workers; i++ {
 		go wait.Until(c.worker, time.Second, stop)
 	}
+	if c.priorityQueue != nil {
+		for i := 0; i < c.priorityLane.Workers; i++ {
+			go wait.Until(c.priorityWorker, time.Second, stop)
+		}
+	}
 	<-stop
 	return nil
 }
@@ -193,7 +381,10 @@ func (c *Controller) enqueueGameServerSet(obj interface{}) {
 		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
 		return
 	}
-
+	if gsSet, ok := obj.(*carrierv1alpha1.GameServerSet); ok {
+		c.queueFor(gsSet).AddRateLimited(key)
+		return
+	}
 	c.workerQueue.AddRateLimited(key)
 }
 
@@ -205,31 +396,24 @@ func (c *Controller) deleteGamServerSet(obj interface{}) {
 	}
 
 	c.workerQueue.Forget(key)
+	if c.priorityQueue != nil {
+		c.priorityQueue.Forget(key)
+	}
+	if gsSet, ok := obj.(*carrierv1alpha1.GameServerSet); ok {
+		c.events.Forget(gsSet.UID)
+	} else if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		if gsSet, ok := tombstone.Obj.(*carrierv1alpha1.GameServerSet); ok {
+			c.events.Forget(gsSet.UID)
+		}
+	}
 }
 
 func (c *Controller) worker() {
-	for c.processNextWorkItem() {
+	for c.processNextWorkItem(c.workerQueue) {
 	}
 	klog.Infof("GameServerSet controller worker shutting down")
 }
 
-func (c *Controller) processNextWorkItem() bool {
-	key, quit := c.workerQueue.Get()
-	if quit {
-		return false
-	}
-	defer c.workerQueue.Done(key)
-
-	err := c.syncGameServerSet(key.(string))
-	if err != nil {
-		c.workerQueue.AddRateLimited(key)
-		utilruntime.HandleError(err)
-		return true
-	}
-	c.workerQueue.Forget(key)
-	return true
-}
-
 // gameServerEventHandler handle GameServerSet changes
 func (c *Controller) gameServerEventHandler(obj interface{}) {
 	gs, ok := obj.(*carrierv1alpha1.GameServer)
@@ -262,6 +446,7 @@ func (c *Controller) syncGameServerSet(key string) error {
 		return nil
 	}
 	klog.V(2).Infof("Sync gameServerSet %v", key)
+	metrics.RecordControllerSync("gameserverset")
 	gsSetInCache, err := c.gameServerSetLister.GameServerSets(namespace).Get(name)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
@@ -271,20 +456,54 @@ func (c *Controller) syncGameServerSet(key string) error {
 		return errors.Wrapf(err, "error retrieving GameServerSet %s from namespace %s", name, namespace)
 	}
 	gsSet := gsSetInCache.DeepCopy()
-	list, err := ListGameServersByGameServerSetOwner(c.gameServerLister, gsSet)
+	list, err := ListGameServersByGameServerSetOwner(c.gameServerIndexer, gsSet)
 	if err != nil {
 		return err
 	}
-	err = c.manageReplicas(key, list, gsSet)
+	c.checkDeletionCost(list)
+	// status mutations during the sync are written once at the end.
+	status := newStatusWriter(c.carrierClient, gsSet)
+	gsSet, err = c.remediateOOMKills(gsSet, list, status)
 	if err != nil {
 		return err
 	}
-	gsSet, err = c.syncGameServerSetStatus(gsSet, list)
-	if err != nil {
-		klog.Error(err)
+	if err = c.syncPodDisruptionBudget(gsSet); err != nil {
 		return err
 	}
-	return nil
+	if err = c.syncNetworkPolicy(gsSet); err != nil {
+		return err
+	}
+	if err = c.syncGameServerMetadata(gsSet, list); err != nil {
+		return err
+	}
+	if gsSet, err = c.repairStaleAnnotations(gsSet, list); err != nil {
+		return err
+	}
+	if gsSet, err = c.syncUpdateStrategy(gsSet, list); err != nil {
+		return err
+	}
+	err = c.manageReplicas(key, list, gsSet, status)
+	if _, statusErr := c.syncGameServerSetStatus(gsSet, list, status); statusErr != nil {
+		klog.Error(statusErr)
+		if err == nil {
+			err = statusErr
+		}
+	}
+	return err
+}
+
+// checkDeletionCost records events for GameServers with invalid deletion cost,
+// which are regarded as the default deletion cost when scaling down. The event is
+// recorded once the deletion cost becomes invalid, not on every sync.
+func (c *Controller) checkDeletionCost(list []*carrierv1alpha1.GameServer) {
+	for _, gs := range list {
+		if _, err := GetDeletionCostFromGameServerAnnotations(gs.Annotations); err != nil {
+			c.events.Eventf(c.recorder, gs, corev1.EventTypeWarning, invalidDeletionCost,
+				"%v, regarded as %v when scaling down", err, util.DefaultDeletionCost)
+		} else {
+			c.events.Resolve(gs.UID, invalidDeletionCost)
+		}
+	}
 }
 
 // manageReplicas manages replicas for GameServerSet: 1. scale up/down. 2. inplace updating.
@@ -292,61 +511,97 @@ func (c *Controller) syncGameServerSet(key string) error {
 // if inplace updating, then scaling down. scale down the older version(for Running GameServer),
 // do not scale down the updating one.
 // if scaling down, then inpalce updating. constraint is added, add inplace annotation directly, and go on.
+// Scaling runs in phases: observe, plan, apply creates, apply deletes, the actions applied are
+// recorded in the checkpoint of status, so a retry after a mid-phase error does not redo them.
 func (c *Controller) manageReplicas(key string, list []*carrierv1alpha1.GameServer,
-	gsSet *carrierv1alpha1.GameServerSet) error {
+	gsSet *carrierv1alpha1.GameServerSet, status *statusWriter) error {
 	klog.Infof("Current GameServer number of GameServerSet %v: %v", key, len(list))
-	gameServersToAdd, toDeleteList, exceedBurst := computeExpectation(gsSet, list, c.counter)
-	status := computeStatus(list, gsSet)
-	klog.V(5).Infof("Reconciling GameServerSet name: %v, spec: %v, status: %v", key, gsSet.Spec, status)
+	// observe
+	checkpoint := observeCheckpoint(status.pending.Checkpoint, list, time.Now())
+	status.mutate(func(s *carrierv1alpha1.GameServerSetStatus) {
+		s.Checkpoint = checkpoint
+	})
+	list = excludeDeleted(list, checkpoint)
+	pending := pendingCreates(checkpoint)
+
+	// plan
+	gameServersToAdd, toDeleteList, reasons, exceedBurst := computeExpectation(gsSet, list, c.counter,
+		c.shouldReapIdle(gsSet))
+	if gameServersToAdd -= pending; gameServersToAdd < 0 {
+		gameServersToAdd = 0
+	}
+	faulty := isTemplateFaulty(gsSet)
+	if gameServersToAdd > 0 && faulty {
+		c.recorder.Eventf(gsSet, corev1.EventTypeWarning, "TemplateFaulty",
+			"Template is faulty, skip creating %v GameServers", gameServersToAdd)
+		gameServersToAdd = 0
+	}
+	current := computeStatus(list, gsSet)
+	klog.V(5).Infof("Reconciling GameServerSet name: %v, spec: %v, status: %v", key, gsSet.Spec, current)
 	if exceedBurst {
-		defer c.workerQueue.Add(key)
+		defer c.queueFor(gsSet).Add(key)
 	}
-	klog.V(2).Infof("GameSeverSet: %v toAdd: %v, toDelete: %v, list: %+v",
-		key, gameServersToAdd, len(toDeleteList), toDeleteList)
+	klog.V(2).Infof("GameSeverSet: %v toAdd: %v, pending: %v, toDelete: %v, list: %+v",
+		key, gameServersToAdd, pending, len(toDeleteList), toDeleteList)
+
+	// apply creates
 	if gameServersToAdd > 0 {
-		if err := c.createGameServers(gsSet, gameServersToAdd); err != nil {
+		created, err := c.createGameServers(gsSet, list, gameServersToAdd)
+		advanceCheckpoint(status, carrierv1alpha1.ReconcileApplyCreates, created, nil)
+		if err != nil {
 			klog.Errorf("error adding game servers: %v", err)
 		}
+		auditGameServers(audit.OperationScaleUp, gsSet, gameServersToAdd, created,
+			fmt.Sprintf("desired replicas %v, current replicas %v", gsSet.Spec.Replicas, current.Replicas), err)
 	}
+
+	// apply deletes
 	var toDeletes, candidates, runnings []*carrierv1alpha1.GameServer
 	if len(toDeleteList) > 0 {
+		advanceCheckpoint(status, carrierv1alpha1.ReconcileApplyDeletes, nil, nil)
 		toDeletes, candidates, runnings = classifyGameServers(toDeleteList, false)
 		// GameServers can be deleted directly.
 		c.recorder.Eventf(gsSet, corev1.EventTypeNormal, "ToDelete",
 			"Created GameServer: %+v, can delete: %v", len(list), len(toDeleteList))
 		klog.Infof("toDeleteList toDeletes %v, candidates %v, runnings %v",
 			len(toDeletes), len(candidates), len(runnings))
-		if err := c.deleteGameServers(gsSet, toDeletes); err != nil {
+		deleted, err := c.deleteGameServers(gsSet, toDeletes)
+		advanceCheckpoint(status, carrierv1alpha1.ReconcileApplyDeletes, nil, deleted)
+		auditGameServers(audit.OperationDelete, gsSet, len(toDeletes), deleted,
+			"GameServers stopped, deletable or not running", err)
+		if err != nil {
 			klog.Errorf("error deleting game servers: %v", err)
 			return err
 		}
-		if err := c.markGameServersOutOfService(gsSet, runnings); err != nil {
+		if gsSet, err = c.recordOOMKills(gsSet, toDeletes); err != nil {
+			return err
+		}
+		marked, err := c.markGameServersOutOfService(gsSet, runnings, reasons)
+		auditGameServers(audit.OperationScaleDown, gsSet, len(runnings), marked,
+			fmt.Sprintf("desired replicas %v", gsSet.Spec.Replicas), err)
+		if err != nil {
 			return err
 		}
 	}
+	advanceCheckpoint(status, carrierv1alpha1.ReconcileFinalizeStatus, nil, nil)
 
-	var err error
-	gsSet, err = c.syncGameServerSetStatus(gsSet, list)
-	if err != nil {
-		klog.Error(err)
-		return err
+	if !faulty && current.Replicas+int32(pending)-int32(len(toDeleteList))+int32(gameServersToAdd) != gsSet.Spec.Replicas {
+		return fmt.Errorf("GameServerSet %v actual replicas: %v, desired: %v, to delete %v, to add: %v, pending: %v",
+			key, current.Replicas, gsSet.Spec.Replicas, len(toDeleteList), gameServersToAdd, pending)
 	}
-	if status.Replicas-int32(len(toDeleteList))+int32(gameServersToAdd) != gsSet.Spec.Replicas {
-		return fmt.Errorf("GameServerSet %v actual replicas: %v, desired: %v, to delete %v, to add: %v", key,
-			gsSet.Status.Replicas, gsSet.Spec.Replicas, len(toDeleteList), gameServersToAdd)
-	}
-	return c.doInPlaceUpdate(gsSet)
+	return c.doInPlaceUpdate(gsSet, status)
 }
 
-// doInPlaceUpdate  try to do inplace update.
+// doInPlaceUpdate  try to do inplace update, the candidates skipped are recorded in status by reason.
 // tree 3 steps:
 // 1. update GameServer to `out of service`, add `in progress`
 // 2. update GameServer image, remove `in progress`
 // 3. update GameServerSet updated replicas. This step must
 //    ensure success or failed but cache synced.
-func (c *Controller) doInPlaceUpdate(gsSet *carrierv1alpha1.GameServerSet) error {
+func (c *Controller) doInPlaceUpdate(gsSet *carrierv1alpha1.GameServerSet, status *statusWriter) error {
 	inPlaceUpdating, desired := IsGameServerSetInPlaceUpdating(gsSet)
 	if !inPlaceUpdating {
+		setInPlaceUpdateSkipped(status, nil)
 		return nil
 	}
 	klog.V(4).Infof("desired threshold : %v", gsSet.Annotations[util.GameServerInPlaceUpdateAnnotation])
@@ -362,6 +617,7 @@ func (c *Controller) doInPlaceUpdate(gsSet *carrierv1alpha1.GameServerSet) error
 	klog.V(4).Infof("desired replicas satisfied, desired: %v, "+
 		"diff: %v, new version: %v, updated according to ann: %v", desired, diff, len(newGameServers), updatedCount)
 	if diff <= 0 || updatedCount >= int32(desired) {
+		setInPlaceUpdateSkipped(status, nil)
 		// scale up when inplace updating
 		if len(newGameServers) > int(updatedCount) {
 			gsSet.Annotations[util.GameServerInPlaceUpdatedReplicasAnnotation] = strconv.Itoa(len(newGameServers))
@@ -377,26 +633,38 @@ func (c *Controller) doInPlaceUpdate(gsSet *carrierv1alpha1.GameServerSet) error
 	// update game servers
 	canUpdates, waitings, runnings := classifyGameServers(oldGameServers, true)
 	var candidates []*carrierv1alpha1.GameServer
-	candidates = append(candidates, sortGameServersByCreationTime(canUpdates)...)
-	candidates = append(candidates, sortGameServersByCreationTime(waitings)...)
-	candidates = append(candidates, sortGameServersByCreationTime(runnings)...)
+	candidates = append(candidates, spreadGameServersByNode(sortGameServersByCreationTime(canUpdates))...)
+	candidates = append(candidates, spreadGameServersByNode(sortGameServersByCreationTime(waitings))...)
+	candidates = append(candidates, spreadGameServersByNode(sortGameServersByCreationTime(runnings))...)
+	// avoid updating too many GameServers on the same node, the rest will be updated in later syncs.
+	candidates = limitGameServersPerNode(candidates, countInPlaceUpdatingByNode(newGameServers),
+		GetInPlaceUpdateMaxPerNode(gsSet))
 	if diff > len(candidates) {
 		diff = len(candidates)
 	}
 	candidates = candidates[0:diff]
 
-	if err = c.markGameServersOutOfService(gsSet, candidates, func(gs *carrierv1alpha1.GameServer) {
+	if _, err = c.markGameServersOutOfService(gsSet, candidates, nil, func(gs *carrierv1alpha1.GameServer) {
 		gameservers.SetInPlaceUpdatingStatus(gs, "true")
 	}); err != nil {
 		return err
 	}
 
-	updated, inPlaceErr := c.inplaceUpdateGameServers(gsSet, candidates)
+	updated, skipped, inPlaceErr := c.inplaceUpdateGameServers(gsSet, candidates)
+	if totalSkipped(skipped) > 0 {
+		c.recorder.Event(gsSet, corev1.EventTypeWarning, "InPlaceUpdateSkipped", describeSkipped(skipped))
+	} else {
+		skipped = nil
+	}
+	setInPlaceUpdateSkipped(status, skipped)
+	updatedCount += int32(len(updated))
+	auditGameServers(audit.OperationInPlaceUpdate, gsSet, len(candidates), updated,
+		fmt.Sprintf("threshold %v, updated %v", desired, updatedCount), inPlaceErr)
 	// updated is from api(source of truth).
 	// make sure update GameServerSet success or failed after retry.
 	// if retry failed, make sure the cache has synced.
 	err = wait.PollImmediate(50*time.Millisecond, 1*time.Second, func() (done bool, err error) {
-		gsSet.Annotations[util.GameServerInPlaceUpdatedReplicasAnnotation] = strconv.Itoa(int(updated + updatedCount))
+		gsSet.Annotations[util.GameServerInPlaceUpdatedReplicasAnnotation] = strconv.Itoa(int(updatedCount))
 		_, err = c.carrierClient.CarrierV1alpha1().GameServerSets(gsSet.Namespace).Update(gsSet)
 		if err == nil {
 			return true, nil
@@ -420,23 +688,28 @@ func (c *Controller) doInPlaceUpdate(gsSet *carrierv1alpha1.GameServerSet) error
 
 func (c *Controller) getOldAndNewReplicas(gsSet *carrierv1alpha1.GameServerSet) ([]*carrierv1alpha1.GameServer,
 	[]*carrierv1alpha1.GameServer, error) {
-	newGameServers, err := c.gameServerLister.List(labels.SelectorFromSet(
-		labels.Set{
-			util.GameServerHash:        gsSet.Labels[util.GameServerHash],
-			util.GameServerSetLabelKey: gsSet.Name,
-		}),
-	)
+	hash := gsSet.Labels[util.GameServerHash]
+	newGameServers, err := gameservers.ListGameServersByIndex(c.gameServerIndexer, gameservers.GameServerHashIndex,
+		gameservers.HashIndexKey(gsSet.UID, hash))
 	if err != nil {
 		return nil, nil, err
 	}
-	selector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", util.GameServerHash,
-		gsSet.Labels[util.GameServerHash], util.GameServerSetLabelKey, gsSet.Name))
+	owned, err := gameservers.ListGameServersByIndex(c.gameServerIndexer, gameservers.GameServerOwnerIndex,
+		string(gsSet.UID))
 	if err != nil {
 		return nil, nil, err
 	}
-	oldGameServers, err := c.gameServerLister.List(selector)
-	if err != nil {
-		return nil, nil, err
+	var oldGameServers []*carrierv1alpha1.GameServer
+	specHash := c.templateSpecHash(gsSet)
+	for _, gs := range owned {
+		if gs.Labels[util.GameServerHash] == hash {
+			continue
+		}
+		if isOfTemplate(gsSet, gs, specHash) {
+			newGameServers = append(newGameServers, gs)
+		} else {
+			oldGameServers = append(oldGameServers, gs)
+		}
 	}
 	return oldGameServers, newGameServers, nil
 }
@@ -446,10 +719,13 @@ func (c *Controller) getOldAndNewReplicas(gsSet *carrierv1alpha1.GameServerSet)
 // there is chance that toAdd > 0 and len(toDeleteGameServers).
 // This will happen when some `GameServers` stopped and have not been deleted. When these GameServers deleted,
 // we will reconcile and add more `GameServers`, which will not affect the final results.
+// The reasons why GameServers are selected by scaleDownOrdering are returned by name.
 func computeExpectation(gsSet *carrierv1alpha1.GameServerSet,
-	list []*carrierv1alpha1.GameServer, counts *Counter) (int, []*carrierv1alpha1.GameServer, bool) {
+	list []*carrierv1alpha1.GameServer, counts *Counter, reapIdle bool) (int, []*carrierv1alpha1.GameServer,
+	map[string]string, bool) {
 	excludeConstraintGS := excludeConstraints(gsSet)
 	var upCount int
+	reasons := make(map[string]string)
 
 	var potentialDeletions, toDeleteGameServers []*carrierv1alpha1.GameServer
 	for _, gs := range list {
@@ -460,6 +736,9 @@ func computeExpectation(gsSet *carrierv1alpha1.GameServerSet,
 		switch gs.Status.State {
 		case "", carrierv1alpha1.GameServerUnknown, carrierv1alpha1.GameServerStarting:
 			upCount++
+		case carrierv1alpha1.GameServerCompleted:
+			// completed OneShot GameServers are not replaced, but scaled down first.
+			upCount++
 		case carrierv1alpha1.GameServerRunning:
 			// GameServer has constraint but may still have player.
 			// if excludeConstraintGS is true, we exclude this, otherwise, include.
@@ -479,6 +758,10 @@ func computeExpectation(gsSet *carrierv1alpha1.GameServerSet,
 				upCount++
 			}
 		default:
+			// finished GameServers are garbage collected after TTL if set.
+			if gsSet.Spec.TTLSecondsAfterFinished != nil && gameservers.IsStopped(gs) {
+				continue
+			}
 			toDeleteGameServers = append(toDeleteGameServers, gs)
 			klog.Infof("GS state: %v", gs.Status.State)
 			continue
@@ -496,117 +779,136 @@ func computeExpectation(gsSet *carrierv1alpha1.GameServerSet,
 			exceedBurst = true
 		}
 	} else if diff < 0 {
-		// 1. delete not ready
-		// 2. delete deletable
-		// 3. try delete running
+		// candidates are ordered by scaleDownOrdering, the number of
+		// running GameServers scaled down at once is limited by BurstReplicas.
 		toDelete := -diff
 		candidates := make([]*carrierv1alpha1.GameServer, len(potentialDeletions))
 		copy(candidates, potentialDeletions)
 		deletables, deleteCandidates, runnings := classifyGameServers(candidates, false)
-		// sort running gs
-		runnings = sortGameServers(runnings, gsSet.Spec.Scheduling, counts)
-		// sort Running GameServers for inpalce updating.
-		inPlaceUpdating, _ := IsGameServerSetInPlaceUpdating(gsSet)
-		if inPlaceUpdating {
-			runnings = sortGameServersByHash(runnings, gsSet)
-		}
-		potentialDeletions = append(deletables, deleteCandidates...)
-		currentCandidateCount := len(potentialDeletions)
-		potentialDeletions = append(potentialDeletions, runnings...)
-		sumCandidateCount := len(potentialDeletions)
 		klog.Infof("deletables:%v, deleteCandidates:%v, runnings:%v",
 			len(deletables), len(deleteCandidates), len(runnings))
+		candidates = append(append(deletables, deleteCandidates...), runnings...)
+		ordering := newScaleDownOrdering(gsSet, counts)
+		if reapIdle {
+			ordering.reapIdleAt = time.Now()
+		}
+		candidates = ordering.sort(candidates)
 
-		if sumCandidateCount < toDelete {
-			toDelete = sumCandidateCount
+		var selected, kept []*carrierv1alpha1.GameServer
+		runningCount := 0
+		for _, gs := range candidates {
+			if len(selected) >= toDelete {
+				kept = append(kept, gs)
+				continue
+			}
+			if ordering.isRunning(gs) {
+				if runningCount >= BurstReplicas {
+					exceedBurst = true
+					kept = append(kept, gs)
+					continue
+				}
+				runningCount++
+			}
+			selected = append(selected, gs)
 		}
-		if toDelete-currentCandidateCount > BurstReplicas {
-			toDelete = BurstReplicas + currentCandidateCount
-			exceedBurst = true
+		var survivor *carrierv1alpha1.GameServer
+		if len(kept) != 0 {
+			survivor = kept[0]
 		}
-
-		toDeleteGameServers = append(toDeleteGameServers, potentialDeletions[0:toDelete]...)
+		for _, gs := range selected {
+			reasons[gs.Name] = ordering.reason(gs, survivor)
+		}
+		toDeleteGameServers = append(toDeleteGameServers, selected...)
 	}
-	return toAdd, toDeleteGameServers, exceedBurst
+	return toAdd, toDeleteGameServers, reasons, exceedBurst
 }
 
-// inplaceUpdateGameServers update GameServer spec to api server
+// inplaceUpdateGameServers update GameServer spec to api server, and returns
+// the names of GameServers updated and the number skipped by reason.
 func (c *Controller) inplaceUpdateGameServers(gsSet *carrierv1alpha1.GameServerSet,
-	toUpdate []*carrierv1alpha1.GameServer) (int32, error) {
+	toUpdate []*carrierv1alpha1.GameServer) ([]string, *carrierv1alpha1.InPlaceUpdateSkipped, error) {
 	klog.Infof("Updating GameServers: %v, to update %v", gsSet.Name, len(toUpdate))
 	if klog.V(5) {
 		printGameServerName(toUpdate, "GameServer to in place update:")
 	}
-	var errs []error
-	var count int32 = 0
-	workqueue.ParallelizeUntil(context.Background(), BurstReplicas, len(toUpdate), func(piece int) {
+	var updated appliedNames
+	skipped := &carrierv1alpha1.InPlaceUpdateSkipped{}
+	err := c.batch.run(operationUpdate, len(toUpdate), func(piece int) error {
 		gs := toUpdate[piece]
 		gsCopy := gs.DeepCopy()
 		var err error
 		if !gameservers.CanInPlaceUpdating(gsCopy) {
-			return
+			countSkipped(skipped, inPlaceUpdateSkipReason(gsCopy))
+			return nil
 		}
 		// Double check GameServer status, same as `deleteGameServers`。
 		if gameservers.IsBeforeRunning(gsCopy) {
 			newGS, err := c.carrierClient.CarrierV1alpha1().
 				GameServers(gsCopy.Namespace).Get(gs.Name, metav1.GetOptions{})
 			if err != nil {
-				errs = append(errs, errors.Wrapf(err, "error checking GameServer %s status", gs.Name))
-				return
+				return errors.Wrapf(err, "error checking GameServer %s status", gs.Name)
 			}
 			if gameservers.IsReady(newGS) && gameservers.IsReadinessExist(newGS) {
 				klog.Infof("GameServer %v is not before ready now, will not update", gs.Name)
-				return
+				countSkipped(skipped, skipReasonBeforeReady)
+				return nil
 			}
 		}
 		gsCopy.Status.Conditions = nil
 		gsCopy, err = c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).UpdateStatus(gsCopy)
 		if err != nil {
-			errs = append(errs, errors.Wrapf(err, "error updating GameServer %v status for condition", gs.Name))
-			return
+			return errors.Wrapf(err, "error updating GameServer %v status for condition", gs.Name)
 		}
-		updateGameServerSpec(gsSet, gsCopy)
+		diff := updateGameServerSpec(gsSet, gsCopy)
 		gs, err = c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Update(gsCopy)
 		if err != nil {
-			errs = append(errs, errors.Wrapf(err, "error inpalce updating GameServer: %v", gsCopy.Name))
-			return
+			return errors.Wrapf(err, "error inpalce updating GameServer: %v", gsCopy.Name)
 		}
-		atomic.AddInt32(&count, 1)
+		updated.add(gs.Name)
 		c.recorder.Eventf(gsSet, corev1.EventTypeNormal,
 			"SuccessfulUpdate", "Update GameServer in place success: %v", gs.Name)
-
+		if len(diff) != 0 {
+			c.recorder.Eventf(gs, corev1.EventTypeNormal, "InPlaceUpdate", "Update in place: %v", diff)
+		}
+		return nil
 	})
-	return count, utilerrors.NewAggregate(errs)
+	return updated.names, skipped, err
 }
 
 // createGameServer will add more servers according to diff
-func (c *Controller) createGameServers(gsSet *carrierv1alpha1.GameServerSet, count int) error {
+func (c *Controller) createGameServers(gsSet *carrierv1alpha1.GameServerSet,
+	list []*carrierv1alpha1.GameServer, count int) ([]string, error) {
 	klog.Infof("Adding more GameServers: %v, count: %v", gsSet.Name, count)
-	var errs []error
 	gs := BuildGameServer(gsSet)
 	gameservers.ApplyDefaults(gs)
-	workqueue.ParallelizeUntil(context.Background(), BurstReplicas, count, func(piece int) {
-		newGS, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Create(gs)
+	applyMemoryLimit(gsSet, gs)
+	indices := freeIndices(list, count)
+	var created appliedNames
+	err := c.batch.run(operationCreate, count, func(piece int) error {
+		gsCopy := gs.DeepCopy()
+		gsCopy.Annotations[util.GameServerIndexAnnotation] = strconv.Itoa(indices[piece])
+		newGS, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Create(gsCopy)
 		if err != nil {
-			errs = append(errs, errors.Wrapf(err, "error creating GameServer for GameServerSet %s", gsSet.Name))
-			return
+			return errors.Wrapf(err, "error creating GameServer for GameServerSet %s", gsSet.Name)
 		}
+		created.add(newGS.Name)
 		c.recorder.Eventf(gsSet, corev1.EventTypeNormal,
 			"SuccessfulCreate", "Created GameServer : %s", newGS.Name)
+		return nil
 	})
-	return utilerrors.NewAggregate(errs)
+	return created.names, err
 }
 
 // deleteGameServers delete GameServers. This will double check status before
 // we delete the GameServers.
 func (c *Controller) deleteGameServers(gsSet *carrierv1alpha1.GameServerSet,
-	toDelete []*carrierv1alpha1.GameServer) error {
+	toDelete []*carrierv1alpha1.GameServer) ([]string, error) {
 	klog.Infof("Deleting GameServers: %v, to delete %v", gsSet.Name, len(toDelete))
 	if klog.V(5) {
 		printGameServerName(toDelete, "GameServer to delete:")
 	}
-	var errs []error
-	workqueue.ParallelizeUntil(context.Background(), BurstReplicas, len(toDelete), func(piece int) {
+	var deleted appliedNames
+	err := c.batch.run(operationDelete, len(toDelete), func(piece int) error {
 		gs := toDelete[piece]
 		gsCopy := gs.DeepCopy()
 		// Double check GameServer status to avoid cache not synced.
@@ -619,38 +921,40 @@ func (c *Controller) deleteGameServers(gsSet *carrierv1alpha1.GameServerSet,
 			newGS, err := c.carrierClient.CarrierV1alpha1().
 				GameServers(gsCopy.Namespace).Get(gs.Name, metav1.GetOptions{})
 			if err != nil {
-				errs = append(errs, errors.Wrapf(err, "error checking GameServer %s status", gs.Name))
-				return
+				return errors.Wrapf(err, "error checking GameServer %s status", gs.Name)
 			}
 			if gameservers.IsReady(newGS) && gameservers.IsReadinessExist(newGS) {
 				klog.Infof("GameServer %v is not before ready now, will not delete", gs.Name)
-				return
+				return nil
 			}
 		}
 		p := metav1.DeletePropagationBackground
 		err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Delete(gs.Name,
 			&metav1.DeleteOptions{PropagationPolicy: &p})
 		if err != nil && !k8serrors.IsNotFound(err) {
-			return
+			return errors.Wrapf(err, "error deleting GameServer %v", gs.Name)
 		}
+		deleted.add(gs.Name)
 		c.recorder.Eventf(gsSet, corev1.EventTypeNormal, "SuccessfulDelete",
 			"Deleted delatable GameServer in state %s : %v", gs.Status.State, gs.Name)
+		return nil
 	})
-	return utilerrors.NewAggregate(errs)
+	return deleted.names, err
 }
 
 type opt func(g *carrierv1alpha1.GameServer)
 
-// markGameServersOutOfService marks GameServers not in Service.
+// markGameServersOutOfService marks GameServers not in Service, and returns the names of
+// those marked. Reasons of scaling down are recorded in the status of GameServers by name.
 func (c *Controller) markGameServersOutOfService(gsSet *carrierv1alpha1.GameServerSet,
-	toMark []*carrierv1alpha1.GameServer, opts ...opt) error {
+	toMark []*carrierv1alpha1.GameServer, reasons map[string]string, opts ...opt) ([]string, error) {
 	klog.Infof("Marking GameServers not in service: %v, to mark out of service %v", gsSet.Name, toMark)
-	var errs []error
 	if klog.V(5) {
 		printGameServerName(toMark, "GameServer to mark out of service:")
 	}
 	klog.Infof("gss %v mark %v", gsSet.Name, len(toMark))
-	workqueue.ParallelizeUntil(context.Background(), BurstReplicas, len(toMark), func(piece int) {
+	var marked appliedNames
+	err := c.batch.run(operationMark, len(toMark), func(piece int) error {
 		gs := toMark[piece]
 		gsCopy := gs.DeepCopy()
 		// 1. before running, we delete directly
@@ -658,7 +962,7 @@ func (c *Controller) markGameServersOutOfService(gsSet *carrierv1alpha1.GameServ
 		// 3. gs deleting, ignore.
 		if gameservers.IsBeforeRunning(gsCopy) ||
 			gameservers.IsInPlaceUpdating(gsCopy) || gameservers.IsBeingDeleted(gsCopy) {
-			return
+			return nil
 		}
 		for _, opt := range opts {
 			opt(gsCopy)
@@ -669,40 +973,45 @@ func (c *Controller) markGameServersOutOfService(gsSet *carrierv1alpha1.GameServ
 		}
 		gsCopy, err := c.carrierClient.CarrierV1alpha1().GameServers(gsCopy.Namespace).Update(gsCopy)
 		if err != nil {
-			errs = append(errs, errors.Wrapf(err, "error updating GameServer %s to not in service", gs.Name))
-			return
+			return errors.Wrapf(err, "error updating GameServer %s to not in service", gs.Name)
 		}
+		marked.add(gs.Name)
 		c.recorder.Eventf(gsSet, corev1.EventTypeNormal,
 			"Successful Mark ", "Mark GameServer not in service: %v", gs.Name)
+		reason, ok := reasons[gs.Name]
+		if !ok || gsCopy.Status.LastScaleDownReason == reason {
+			return nil
+		}
+		gsCopy.Status.LastScaleDownReason = reason
+		if _, err = c.carrierClient.CarrierV1alpha1().GameServers(gsCopy.Namespace).UpdateStatus(gsCopy); err != nil {
+			return errors.Wrapf(err, "error updating GameServer %s scale down reason", gs.Name)
+		}
+		return nil
 	})
-	return utilerrors.NewAggregate(errs)
+	return marked.names, err
 }
 
-// syncGameServerSetStatus synchronises the GameServerSet State with active GameServer counts
+// syncGameServerSetStatus synchronises the GameServerSet State with active GameServer counts,
+// and writes the status mutations coalesced by status.
 func (c *Controller) syncGameServerSetStatus(gsSet *carrierv1alpha1.GameServerSet,
-	list []*carrierv1alpha1.GameServer) (*carrierv1alpha1.GameServerSet, error) {
-	status := computeStatus(list, gsSet)
-	status.Conditions = gsSet.Status.Conditions
-	return c.updateStatusIfChanged(gsSet, status)
-}
-
-// updateStatusIfChanged updates GameServerSet status if it's different than provided.
-func (c *Controller) updateStatusIfChanged(gsSet *carrierv1alpha1.GameServerSet,
-	status carrierv1alpha1.GameServerSetStatus) (*carrierv1alpha1.GameServerSet, error) {
-	status.ObservedGeneration = gsSet.Generation
-	if gsSet.Spec.Selector != nil && gsSet.Spec.Selector.MatchLabels != nil {
-		status.Selector = labels.Set(gsSet.Spec.Selector.MatchLabels).String()
+	list []*carrierv1alpha1.GameServer, status *statusWriter) (*carrierv1alpha1.GameServerSet, error) {
+	computed, ok := c.replicas.status(gsSet)
+	if !ok {
+		computed = computeStatus(list, gsSet)
 	}
-	var err error
-	if !reflect.DeepEqual(gsSet.Status, status) {
-		gsSet.Status = status
-		gsSet, err = c.carrierClient.CarrierV1alpha1().GameServerSets(gsSet.Namespace).UpdateStatus(gsSet)
-		if err != nil {
-			return nil, errors.Wrap(err, "error updating status on GameServerSet")
-		}
-		return gsSet, nil
+	computed.ObservedGeneration = gsSet.Generation
+	computed.IdleReplicas = countIdleExpired(list, time.Now())
+	if gsSet.Spec.Selector != nil && gsSet.Spec.Selector.MatchLabels != nil {
+		computed.Selector = labels.Set(gsSet.Spec.Selector.MatchLabels).String()
 	}
-	return gsSet, nil
+	status.mutate(func(s *carrierv1alpha1.GameServerSetStatus) {
+		computed.Conditions = s.Conditions
+		computed.InPlaceUpdateSkipped = s.InPlaceUpdateSkipped
+		computed.Checkpoint = s.Checkpoint
+		*s = computed
+		setKStatusConditions(gsSet, s)
+	})
+	return status.flush()
 }
 
 // patchGameServerIfChanged  patch GameServerSet if it's different than provided.
@@ -726,9 +1035,11 @@ func (c *Controller) patchGameServerIfChanged(gsSet *carrierv1alpha1.GameServerS
 }
 
 // updateGameServerSpec update GameServer spec, include, image and resource.
-func updateGameServerSpec(gsSet *carrierv1alpha1.GameServerSet, gs *carrierv1alpha1.GameServer) {
+// It returns the summary of changes, which is also recorded in the annotation of gs.
+func updateGameServerSpec(gsSet *carrierv1alpha1.GameServerSet, gs *carrierv1alpha1.GameServer) string {
 	var image string
 	var resources corev1.ResourceRequirements
+	var diff string
 	gs.Labels[util.GameServerHash] = gsSet.Labels[util.GameServerHash]
 	for _, container := range gsSet.Spec.Template.Spec.Template.Spec.Containers {
 		if container.Name != util.GameServerContainerName {
@@ -741,39 +1052,30 @@ func updateGameServerSpec(gsSet *carrierv1alpha1.GameServerSet, gs *carrierv1alp
 		if container.Name != util.GameServerContainerName {
 			continue
 		}
+		diff = containerDiff(container, image, resources)
 		gs.Spec.Template.Spec.Containers[i].Image = image
 		gs.Spec.Template.Spec.Containers[i].Resources = resources
 	}
+	if len(diff) != 0 {
+		if gs.Annotations == nil {
+			gs.Annotations = make(map[string]string)
+		}
+		gs.Annotations[util.GameServerInPlaceUpdateDiffAnnotation] = diff
+	}
+	gameservers.SetGameVersion(gs, gsSet.Spec.Template.Spec.GameVersion)
 	gs.Spec.Constraints = nil
 	gameservers.SetInPlaceUpdatingStatus(gs, "false")
+	return diff
 }
 
 // computeStatus computes the status of the GameServerSet.
 func computeStatus(list []*carrierv1alpha1.GameServer,
 	gsSet *carrierv1alpha1.GameServerSet) carrierv1alpha1.GameServerSetStatus {
-	var status carrierv1alpha1.GameServerSetStatus
+	var counts replicaCounts
 	for _, gs := range list {
-		if gameservers.IsBeingDeleted(gs) {
-			// don't count GS that are being deleted
-			continue
-		}
-		status.Replicas++
-		if gs.Status.State != carrierv1alpha1.GameServerRunning {
-			continue
-		}
-		if gameservers.IsDeletableWithGates(gs) {
-			continue
-		}
-		if gameservers.IsOutOfService(gs) &&
-			excludeConstraints(gsSet) {
-			continue
-		}
-		if gameservers.IsReady(gs) {
-			// do not count GS will be deleted, this GS are not online
-			status.ReadyReplicas++
-		}
+		counts.add(countGameServer(gs), 1)
 	}
-	return status
+	return counts.status(gsSet)
 }
 
 // excludeConstraints return if exclude GameServers with constraint for the GameServerSet
@@ -790,9 +1092,12 @@ func classifyGameServers(toDelete []*carrierv1alpha1.GameServer, updating bool)
 	var inPlaceUpdatings, notReadys []*carrierv1alpha1.GameServer
 	for _, gs := range toDelete {
 		switch {
-		// GameServer Exit or Failed should delete.
-		case gameservers.IsStopped(gs):
+		// GameServer Exit, Failed or Completed should delete.
+		case gameservers.IsStopped(gs), gameservers.IsCompleted(gs):
 			deletables = append(deletables, gs)
+		// GameServer opts out of update and scale down.
+		case gameservers.IsUpdateSkipped(gs):
+			continue
 		case gameservers.IsInPlaceUpdating(gs):
 			if updating {
 				inPlaceUpdatings = append(inPlaceUpdatings, gs)
@@ -813,24 +1118,27 @@ func classifyGameServers(toDelete []*carrierv1alpha1.GameServer, updating bool)
 	return
 }
 
-// sortGameServers will sort GameServers.
-// 1. Sort according to cost.
-// 2. Sort by policy.
-func sortGameServers(potentialDeletions []*carrierv1alpha1.GameServer,
-	strategy carrierv1alpha1.SchedulingStrategy, counter *Counter) []*carrierv1alpha1.GameServer {
-	if len(potentialDeletions) == 0 {
-		return potentialDeletions
+// auditGameServers records the operation on GameServers of the GameServerSet once it is done,
+// with the number of GameServers requested, the names of those succeeded and the error if any.
+func auditGameServers(op audit.Operation, gsSet *carrierv1alpha1.GameServerSet,
+	requested int, succeeded []string, reason string, err error) {
+	if requested == 0 {
+		return
 	}
-	potentialDeletions = sortGameServersByCost(potentialDeletions)
-	ann := potentialDeletions[0].Annotations
-	if cost, _ := GetDeletionCostFromGameServerAnnotations(ann); cost == int64(math.MaxInt64) {
-		if strategy == carrierv1alpha1.MostAllocated {
-			potentialDeletions = sortGameServersByPodNum(potentialDeletions, counter)
-		} else {
-			potentialDeletions = sortGameServersByCreationTime(potentialDeletions)
-		}
+	record := &audit.Record{
+		Operation:   op,
+		Kind:        "GameServerSet",
+		Namespace:   gsSet.Namespace,
+		Name:        gsSet.Name,
+		Reason:      reason,
+		Requested:   requested,
+		Count:       len(succeeded),
+		GameServers: succeeded,
+	}
+	if err != nil {
+		record.Error = err.Error()
 	}
-	return potentialDeletions
+	audit.Log(record)
 }
 
 func printGameServerName(list []*carrierv1alpha1.GameServer, prefix string) {
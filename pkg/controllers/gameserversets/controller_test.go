@@ -21,9 +21,11 @@ import (
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -34,7 +36,9 @@ import (
 	gsfake "github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
 	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
 	v1alpha12 "github.com/ocgi/carrier/pkg/client/informers/externalversions/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
 	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/util/kube"
 )
 
 var selectMap = map[string]string{util.GameServerSetLabelKey: "test"}
@@ -138,6 +142,20 @@ func TestComputeExpectation(t *testing.T) {
 			toAdd:    0,
 			toDelete: []*v1alpha1.GameServer{gsOwnered2Running1Exit()[2]},
 		},
+		{
+			name:     "gsSet spec replicas, 0 to add, completed not replaced",
+			gsLister: gsOwnered1Running1Completed(),
+			gsSet:    withReplicas(2, gss()),
+			toAdd:    0,
+			toDelete: nil,
+		},
+		{
+			name:     "gsSet spec replicas, completed scaled down first",
+			gsLister: gsOwnered1Running1Completed(),
+			gsSet:    withReplicas(1, gss()),
+			toAdd:    0,
+			toDelete: []*v1alpha1.GameServer{gsOwnered1Running1Completed()[1]},
+		},
 		{
 			name:     "gsSet spec replicas, 0 to add, 1 to delete(deletablable)",
 			gsLister: gsOwnered2Running1Deletable(),
@@ -189,6 +207,13 @@ func TestComputeExpectation(t *testing.T) {
 			toAdd:    0,
 			toDelete: []*v1alpha1.GameServer{gsOwnered3RunningCandidateCost()[0]},
 		},
+		{
+			name:     "gsSet spec replicas, 1 to be candidate, cost, 1 skip update",
+			gsLister: gsOwnered3RunningCandidateCostSkipUpdate(),
+			gsSet:    withReplicas(2, gss()),
+			toAdd:    0,
+			toDelete: []*v1alpha1.GameServer{gsOwnered3RunningCandidateCostSkipUpdate()[1]},
+		},
 		{
 			name:     "gsSet spec replicas, 2 to be candidate, 1 gs limited",
 			gsLister: gsOwnered4Running3Constraint(),
@@ -202,15 +227,15 @@ func TestComputeExpectation(t *testing.T) {
 			gsLister: gsOwnered4Running3Constraint1Out(),
 			gsSet:    withReplicas(1, gss()),
 			toAdd:    0,
-			toDelete: []*v1alpha1.GameServer{gsOwnered4Running3Constraint1Out()[1], gsOwnered4Running3Constraint1Out()[0],
+			toDelete: []*v1alpha1.GameServer{gsOwnered4Running3Constraint1Out()[0], gsOwnered4Running3Constraint1Out()[1],
 				gsOwnered4Running3Constraint1Out()[2]},
 		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			toAdd, toDelete, _ := computeExpectation(testCase.gsSet, testCase.gsLister, &Counter{
+			toAdd, toDelete, _, _ := computeExpectation(testCase.gsSet, testCase.gsLister, &Counter{
 				nodeGameServer: map[string]uint64{},
-			})
+			}, false)
 			if toAdd != testCase.toAdd {
 				t.Errorf("To add :%v\n desired: %v", toAdd, testCase.toAdd)
 			}
@@ -229,6 +254,9 @@ func fakeController(ctx context.Context) (*fake.Clientset, *gsfake.Clientset,
 	carrierFactory := externalversions.NewSharedInformerFactory(fakeGSClient, 0)
 	gsInformer := carrierFactory.Carrier().V1alpha1().GameServers()
 	gssInformer := carrierFactory.Carrier().V1alpha1().GameServerSets()
+	coreFactory := informers.NewSharedInformerFactory(fakeClient, 0)
+	pdbInformer := coreFactory.Policy().V1beta1().PodDisruptionBudgets()
+	npInformer := coreFactory.Networking().V1().NetworkPolicies()
 
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartLogging(klog.Infof)
@@ -237,17 +265,30 @@ func fakeController(ctx context.Context) (*fake.Clientset, *gsfake.Clientset,
 	s := runtime.NewScheme()
 	s.AddKnownTypes(v1alpha1.SchemeGroupVersion, &v1alpha1.GameServerSet{}, &v1alpha1.GameServerSetList{})
 
+	if err := gameservers.AddGameServerIndexers(gsInformer.Informer()); err != nil {
+		panic(err)
+	}
 	c := &Controller{
+		kubeClient:          fakeClient,
 		carrierClient:       fakeGSClient,
 		gameServerSetLister: gssInformer.Lister(),
 		gameServerSetSynced: gssInformer.Informer().HasSynced,
 		gameServerLister:    gsInformer.Lister(),
+		gameServerIndexer:   gsInformer.Informer().GetIndexer(),
 		gameServerSynced:    gsInformer.Informer().HasSynced,
+		pdbLister:           pdbInformer.Lister(),
+		pdbSynced:           pdbInformer.Informer().HasSynced,
+		networkPolicyLister: npInformer.Lister(),
+		networkPolicySynced: npInformer.Informer().HasSynced,
 		recorder:            eventBroadcaster.NewRecorder(s, corev1.EventSource{Component: "gameserverset-controller"}),
+		events:              kube.NewEventDeduper(),
 		counter:             &Counter{nodeGameServer: map[string]uint64{}},
+		batch:               newBatchSizer(),
 	}
 	carrierFactory.Start(ctx.Done())
-	cache.WaitForCacheSync(ctx.Done(), c.gameServerSetSynced, c.gameServerSynced)
+	coreFactory.Start(ctx.Done())
+	cache.WaitForCacheSync(ctx.Done(), c.gameServerSetSynced, c.gameServerSynced, c.pdbSynced,
+		c.networkPolicySynced)
 	return fakeClient, fakeGSClient, gsInformer, gssInformer, c
 }
 
@@ -337,6 +378,13 @@ func gsOwnered1Running1Exit() []*v1alpha1.GameServer {
 	return gamesvrs
 }
 
+func gsOwnered1Running1Completed() []*v1alpha1.GameServer {
+	gamesvrs := gsOwnered1Running1Exit()
+	gamesvrs[1].Spec.CompletionPolicy = v1alpha1.OneShot
+	gamesvrs[1].Status.State = v1alpha1.GameServerCompleted
+	return gamesvrs
+}
+
 func gsOwnered2Running1Exit() []*v1alpha1.GameServer {
 	gamesvrs := gsOwnered2()
 	gamesvrs[0].Status.State = v1alpha1.GameServerRunning
@@ -414,6 +462,12 @@ func gsOwnered3RunningCandidateCost() []*v1alpha1.GameServer {
 	return gamesvrs
 }
 
+func gsOwnered3RunningCandidateCostSkipUpdate() []*v1alpha1.GameServer {
+	gamesvrs := gsOwnered3RunningCandidateCost()
+	gamesvrs[0].Annotations[util.GameServerSkipUpdateAnnotation] = "true"
+	return gamesvrs
+}
+
 func gsOwnered4Running3Constraint() []*v1alpha1.GameServer {
 	gamesvrs := gsOwnered2()
 	gamesvrs[0].Status.State = v1alpha1.GameServerRunning
@@ -459,3 +513,88 @@ func gsOwnered2Unhealthy() []*v1alpha1.GameServer {
 	gamesvrs[1].Status.State = v1alpha1.GameServerRunning
 	return gamesvrs
 }
+
+func TestUpdateGameServerSpecDiff(t *testing.T) {
+	gsSet := gss()
+	gsSet.Labels = map[string]string{util.GameServerHash: "new"}
+	gsSet.Spec.Template.Spec.Template.Spec.Containers = []corev1.Container{
+		{
+			Name:  util.GameServerContainerName,
+			Image: "server:v2",
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			},
+		},
+	}
+	gs := gsOwnered()[0]
+	gs.Spec.Template.Spec.Containers = []corev1.Container{
+		{
+			Name:  util.GameServerContainerName,
+			Image: "server:v1",
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			},
+		},
+	}
+	desired := "image: server:v1 -> server:v2; limits: {cpu=1} -> {cpu=2}"
+	diff := updateGameServerSpec(gsSet, gs)
+	if diff != desired {
+		t.Errorf("desired diff %q, get: %q", desired, diff)
+	}
+	if gs.Annotations[util.GameServerInPlaceUpdateDiffAnnotation] != desired {
+		t.Errorf("desired annotation %q, get: %q", desired, gs.Annotations[util.GameServerInPlaceUpdateDiffAnnotation])
+	}
+	if diff = updateGameServerSpec(gsSet, gs); diff != "" {
+		t.Errorf("desired no diff after update, get: %q", diff)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	list := gsOwnered1Running1Exit()
+	simulation := Simulate(withReplicas(2, gss()), list, nil)
+	desired := &Simulation{
+		ToAdd: 1,
+		ToDelete: []SimulatedDeletion{
+			{Name: list[1].Name, Reason: "GameServer is Exited"},
+		},
+	}
+	if !reflect.DeepEqual(desired, simulation) {
+		t.Errorf("desired simulation %+v, get: %+v", desired, simulation)
+	}
+}
+
+func TestBuildGameServerImagePullSecrets(t *testing.T) {
+	gsSet := gss()
+	gsSet.Spec.Template.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "template"}}
+	gsSet.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "template"}, {Name: "squad"}}
+	gs := BuildGameServer(gsSet)
+	expected := []corev1.LocalObjectReference{{Name: "template"}, {Name: "squad"}}
+	if !reflect.DeepEqual(gs.Spec.Template.Spec.ImagePullSecrets, expected) {
+		t.Errorf("desired secrets %v, get: %v", expected, gs.Spec.Template.Spec.ImagePullSecrets)
+	}
+	if len(gsSet.Spec.Template.Spec.Template.Spec.ImagePullSecrets) != 1 {
+		t.Errorf("desired template unchanged, get: %v", gsSet.Spec.Template.Spec.Template.Spec.ImagePullSecrets)
+	}
+}
+
+func TestCheckDeletionCost(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	c := &Controller{recorder: recorder, events: kube.NewEventDeduper()}
+	gs := &v1alpha1.GameServer{ObjectMeta: v1.ObjectMeta{Name: "gs", UID: "uid",
+		Annotations: map[string]string{util.GameServerDeletionCost: "invalid"}}}
+	list := []*v1alpha1.GameServer{gs}
+	c.checkDeletionCost(list)
+	c.checkDeletionCost(list)
+	if len(recorder.Events) != 1 {
+		t.Fatalf("desired 1 event while the deletion cost stays invalid, get: %v", len(recorder.Events))
+	}
+	<-recorder.Events
+
+	gs.Annotations[util.GameServerDeletionCost] = "10"
+	c.checkDeletionCost(list)
+	gs.Annotations[util.GameServerDeletionCost] = "invalid"
+	c.checkDeletionCost(list)
+	if len(recorder.Events) != 1 {
+		t.Errorf("desired event recorded again once the deletion cost is invalid again, get: %v", len(recorder.Events))
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+)
+
+// IdleReaper describes when GameServers idle longer than their max idle seconds are scaled
+// down before other running GameServers, freeing capacity for busier Squads.
+type IdleReaper struct {
+	// PressureConditions are the node conditions reporting resource pressure, e.g. MemoryPressure,
+	// or a CPU pressure condition reported by node-problem-detector. Idle GameServers are reaped
+	// while any node has one of them True.
+	PressureConditions []corev1.NodeConditionType
+}
+
+// shouldReapIdle returns true if idle GameServers of gsSet should be scaled down first.
+func (c *Controller) shouldReapIdle(gsSet *carrierv1alpha1.GameServerSet) bool {
+	if c.idleReaper == nil || gsSet.Spec.Template.Spec.MaxIdleSeconds == nil {
+		return false
+	}
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list nodes: %v", err)
+		return false
+	}
+	return underPressure(nodes, c.idleReaper.PressureConditions)
+}
+
+// underPressure returns true if any of nodes has one of conditions True.
+func underPressure(nodes []*corev1.Node, conditions []corev1.NodeConditionType) bool {
+	for _, node := range nodes {
+		for _, condition := range node.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			for _, conditionType := range conditions {
+				if condition.Type == conditionType {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// countIdleExpired returns the number of GameServers idle longer than their max idle seconds at now.
+func countIdleExpired(list []*carrierv1alpha1.GameServer, now time.Time) int32 {
+	var count int32
+	for _, gs := range list {
+		if gs.DeletionTimestamp == nil && gameservers.IsIdleExpired(gs, now) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestUnderPressure(t *testing.T) {
+	newNode := func(conditionType corev1.NodeConditionType, status corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: conditionType, Status: status}},
+			},
+		}
+	}
+	conditions := []corev1.NodeConditionType{corev1.NodeMemoryPressure, "CPUPressure"}
+	tests := []struct {
+		nodes    []*corev1.Node
+		pressure bool
+	}{
+		{nodes: []*corev1.Node{newNode(corev1.NodeMemoryPressure, corev1.ConditionFalse)}},
+		{nodes: []*corev1.Node{newNode(corev1.NodeDiskPressure, corev1.ConditionTrue)}},
+		{
+			nodes: []*corev1.Node{
+				newNode(corev1.NodeMemoryPressure, corev1.ConditionFalse),
+				newNode("CPUPressure", corev1.ConditionTrue),
+			},
+			pressure: true,
+		},
+	}
+	for i, tc := range tests {
+		if pressure := underPressure(tc.nodes, conditions); pressure != tc.pressure {
+			t.Errorf("case %v: desired pressure %v, get: %v", i, tc.pressure, pressure)
+		}
+	}
+}
+
+func TestScaleDownIdleFirst(t *testing.T) {
+	now := time.Now()
+	maxIdleSeconds := int64(60)
+	newGS := func(name string, idleSince time.Time) *carrierv1alpha1.GameServer {
+		gs := &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))},
+			Spec:       carrierv1alpha1.GameServerSpec{MaxIdleSeconds: &maxIdleSeconds},
+			Status:     carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning},
+		}
+		if !idleSince.IsZero() {
+			idle := metav1.NewTime(idleSince)
+			gs.Status.IdleSince = &idle
+		}
+		return gs
+	}
+	list := []*carrierv1alpha1.GameServer{
+		newGS("a-busy", time.Time{}),
+		newGS("b-idle-recently", now.Add(-time.Second)),
+		newGS("c-idle", now.Add(-time.Hour)),
+	}
+	ordering := newScaleDownOrdering(&carrierv1alpha1.GameServerSet{}, nil)
+	var names []string
+	for _, gs := range ordering.sort(append([]*carrierv1alpha1.GameServer{}, list...)) {
+		names = append(names, gs.Name)
+	}
+	if desired := []string{"a-busy", "b-idle-recently", "c-idle"}; !reflect.DeepEqual(names, desired) {
+		t.Errorf("desired order %v without reaping, get: %v", desired, names)
+	}
+
+	ordering.reapIdleAt = now
+	names = nil
+	for _, gs := range ordering.sort(append([]*carrierv1alpha1.GameServer{}, list...)) {
+		names = append(names, gs.Name)
+	}
+	if desired := []string{"c-idle", "a-busy", "b-idle-recently"}; !reflect.DeepEqual(names, desired) {
+		t.Errorf("desired order %v when reaping, get: %v", desired, names)
+	}
+	if count := countIdleExpired(list, now); count != 1 {
+		t.Errorf("desired 1 idle replica, get: %v", count)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"strconv"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// freeIndices returns the lowest count indices not used by GameServers in list, which are
+// assigned to new GameServers.
+func freeIndices(list []*carrierv1alpha1.GameServer, count int) []int {
+	used := make(map[int]bool, len(list))
+	for _, gs := range list {
+		if index, ok := getGameServerIndex(gs); ok {
+			used[index] = true
+		}
+	}
+	indices := make([]int, 0, count)
+	for index := 0; len(indices) < count; index++ {
+		if !used[index] {
+			indices = append(indices, index)
+		}
+	}
+	return indices
+}
+
+// getGameServerIndex returns the index of GameServer in its GameServerSet, false if not assigned.
+func getGameServerIndex(gs *carrierv1alpha1.GameServer) (int, bool) {
+	value, ok := gs.Annotations[util.GameServerIndexAnnotation]
+	if !ok {
+		return 0, false
+	}
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 0 {
+		return 0, false
+	}
+	return index, true
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestFreeIndices(t *testing.T) {
+	withIndex := func(index string) *carrierv1alpha1.GameServer {
+		return &carrierv1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{util.GameServerIndexAnnotation: index},
+		}}
+	}
+	list := []*carrierv1alpha1.GameServer{
+		withIndex("0"), withIndex("2"), withIndex("invalid"), {},
+	}
+	for _, testCase := range []struct {
+		count  int
+		expect []int
+	}{
+		{count: 0, expect: []int{}},
+		{count: 1, expect: []int{1}},
+		{count: 3, expect: []int{1, 3, 4}},
+	} {
+		indices := freeIndices(list, testCase.count)
+		if !reflect.DeepEqual(indices, testCase.expect) {
+			t.Errorf("desired indices: %v, get: %v", testCase.expect, indices)
+		}
+	}
+}
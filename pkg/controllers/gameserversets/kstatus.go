@@ -0,0 +1,103 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// setKStatusConditions sets the Ready, Reconciling and Stalled conditions of GameServerSet
+// status following kstatus conventions, so that GitOps tools are able to report the health.
+func setKStatusConditions(gsSet *carrierv1alpha1.GameServerSet, status *carrierv1alpha1.GameServerSetStatus) {
+	stalled := getTrueCondition(status, carrierv1alpha1.GameServerSetTemplateFaulty,
+		carrierv1alpha1.GameServerSetReplicaFailure)
+	if stalled != nil {
+		setGameServerSetCondition(status, carrierv1alpha1.GameServerSetStalled, corev1.ConditionTrue,
+			stalled.Reason, stalled.Message)
+	} else {
+		removeGameServerSetCondition(status, carrierv1alpha1.GameServerSetStalled)
+	}
+
+	ready := stalled == nil && status.ObservedGeneration >= gsSet.Generation &&
+		status.Replicas == gsSet.Spec.Replicas && status.ReadyReplicas == gsSet.Spec.Replicas
+	if ready {
+		removeGameServerSetCondition(status, carrierv1alpha1.GameServerSetReconciling)
+		setGameServerSetCondition(status, carrierv1alpha1.GameServerSetReady, corev1.ConditionTrue,
+			util.GameServersReadyReason, fmt.Sprintf("%v GameServers are ready", status.ReadyReplicas))
+		return
+	}
+	message := fmt.Sprintf("%v of %v GameServers are created, %v are ready",
+		status.Replicas, gsSet.Spec.Replicas, status.ReadyReplicas)
+	if stalled != nil {
+		removeGameServerSetCondition(status, carrierv1alpha1.GameServerSetReconciling)
+	} else {
+		setGameServerSetCondition(status, carrierv1alpha1.GameServerSetReconciling, corev1.ConditionTrue,
+			util.GameServersProgressingReason, message)
+	}
+	if getGameServerSetCondition(status, carrierv1alpha1.GameServerSetReady) != nil {
+		setGameServerSetCondition(status, carrierv1alpha1.GameServerSetReady, corev1.ConditionFalse,
+			util.GameServersProgressingReason, message)
+		return
+	}
+	// setGameServerSetCondition only adds conditions which are True.
+	status.Conditions = append(status.Conditions, carrierv1alpha1.GameServerSetCondition{
+		Type:               carrierv1alpha1.GameServerSetReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             util.GameServersProgressingReason,
+		Message:            message,
+	})
+}
+
+// getGameServerSetCondition returns the condition of GameServerSet status with the provided type.
+func getGameServerSetCondition(status *carrierv1alpha1.GameServerSetStatus,
+	conditionType carrierv1alpha1.GameServerSetConditionType) *carrierv1alpha1.GameServerSetCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// getTrueCondition returns the first condition of the provided types which is True.
+func getTrueCondition(status *carrierv1alpha1.GameServerSetStatus,
+	conditionTypes ...carrierv1alpha1.GameServerSetConditionType) *carrierv1alpha1.GameServerSetCondition {
+	for _, conditionType := range conditionTypes {
+		condition := getGameServerSetCondition(status, conditionType)
+		if condition != nil && condition.Status == corev1.ConditionTrue {
+			return condition
+		}
+	}
+	return nil
+}
+
+// removeGameServerSetCondition removes the condition of GameServerSet status with the provided type.
+func removeGameServerSetCondition(status *carrierv1alpha1.GameServerSetStatus,
+	conditionType carrierv1alpha1.GameServerSetConditionType) {
+	var conditions []carrierv1alpha1.GameServerSetCondition
+	for _, condition := range status.Conditions {
+		if condition.Type != conditionType {
+			conditions = append(conditions, condition)
+		}
+	}
+	status.Conditions = conditions
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestSetKStatusConditions(t *testing.T) {
+	faulty := carrierv1alpha1.GameServerSetCondition{
+		Type:   carrierv1alpha1.GameServerSetTemplateFaulty,
+		Status: corev1.ConditionTrue,
+		Reason: "OOMKilled",
+	}
+	for _, testCase := range []struct {
+		name        string
+		status      carrierv1alpha1.GameServerSetStatus
+		ready       corev1.ConditionStatus
+		reconciling bool
+		stalled     bool
+	}{
+		{
+			name:   "all ready",
+			status: carrierv1alpha1.GameServerSetStatus{Replicas: 1, ReadyReplicas: 1},
+			ready:  corev1.ConditionTrue,
+		},
+		{
+			name:        "scaling",
+			status:      carrierv1alpha1.GameServerSetStatus{Replicas: 2, ReadyReplicas: 1},
+			ready:       corev1.ConditionFalse,
+			reconciling: true,
+		},
+		{
+			name: "template faulty",
+			status: carrierv1alpha1.GameServerSetStatus{Replicas: 1, ReadyReplicas: 1,
+				Conditions: []carrierv1alpha1.GameServerSetCondition{faulty}},
+			ready:   corev1.ConditionFalse,
+			stalled: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			status := testCase.status
+			setKStatusConditions(gss(), &status)
+			ready := getGameServerSetCondition(&status, carrierv1alpha1.GameServerSetReady)
+			if ready == nil || ready.Status != testCase.ready {
+				t.Errorf("desired Ready %v, get: %+v", testCase.ready, ready)
+			}
+			reconciling := getGameServerSetCondition(&status, carrierv1alpha1.GameServerSetReconciling)
+			if (reconciling != nil) != testCase.reconciling {
+				t.Errorf("desired Reconciling %v, get: %+v", testCase.reconciling, reconciling)
+			}
+			stalled := getGameServerSetCondition(&status, carrierv1alpha1.GameServerSetStalled)
+			if (stalled != nil) != testCase.stalled {
+				t.Errorf("desired Stalled %v, get: %+v", testCase.stalled, stalled)
+			}
+			// setting again changes nothing.
+			again := *status.DeepCopy()
+			setKStatusConditions(gss(), &again)
+			if !reflect.DeepEqual(status, again) {
+				t.Errorf("desired status %+v, get: %+v", status, again)
+			}
+		})
+	}
+}
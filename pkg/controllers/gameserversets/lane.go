@@ -0,0 +1,101 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"sync"
+	"time"
+
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+// busyKeyRetryDelay is the delay a key is requeued after if it is being synced by the other lane.
+const busyKeyRetryDelay = 100 * time.Millisecond
+
+// PriorityLane describes the dedicated workers syncing small GameServerSets, so they are
+// not starved behind giant GameServerSets syncing thousands of GameServers.
+type PriorityLane struct {
+	// Workers is the number of workers of the priority lane.
+	Workers int
+	// MaxReplicas is the max replicas of GameServerSets synced by the priority lane.
+	MaxReplicas int32
+}
+
+// keySet is the keys being synced, a GameServerSet changing its replicas could be in both
+// lanes, but it must not be synced concurrently.
+type keySet struct {
+	sync.Mutex
+	keys map[string]bool
+}
+
+// tryAdd adds key, returns false if key is already in the set.
+func (s *keySet) tryAdd(key string) bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.keys == nil {
+		s.keys = make(map[string]bool)
+	}
+	if s.keys[key] {
+		return false
+	}
+	s.keys[key] = true
+	return true
+}
+
+func (s *keySet) remove(key string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.keys, key)
+}
+
+// queueFor returns the queue gsSet is synced by.
+func (c *Controller) queueFor(gsSet *carrierv1alpha1.GameServerSet) workqueue.RateLimitingInterface {
+	if c.priorityQueue != nil && gsSet.Spec.Replicas <= c.priorityLane.MaxReplicas {
+		return c.priorityQueue
+	}
+	return c.workerQueue
+}
+
+func (c *Controller) priorityWorker() {
+	for c.processNextWorkItem(c.priorityQueue) {
+	}
+	klog.Infof("GameServerSet controller priority worker shutting down")
+}
+
+func (c *Controller) processNextWorkItem(queue workqueue.RateLimitingInterface) bool {
+	obj, quit := queue.Get()
+	if quit {
+		return false
+	}
+	defer queue.Done(obj)
+	key := obj.(string)
+	if !c.syncing.tryAdd(key) {
+		queue.AddAfter(key, busyKeyRetryDelay)
+		return true
+	}
+	err := c.syncGameServerSet(key)
+	c.syncing.remove(key)
+	if err != nil {
+		queue.AddRateLimited(key)
+		utilruntime.HandleError(err)
+		return true
+	}
+	queue.Forget(key)
+	return true
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"testing"
+
+	"k8s.io/client-go/util/workqueue"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestQueueFor(t *testing.T) {
+	c := &Controller{
+		workerQueue:   workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		priorityQueue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		priorityLane:  &PriorityLane{Workers: 1, MaxReplicas: 10},
+	}
+	for _, testCase := range []struct {
+		replicas       int32
+		expectPriority bool
+	}{
+		{replicas: 0, expectPriority: true},
+		{replicas: 10, expectPriority: true},
+		{replicas: 11, expectPriority: false},
+		{replicas: 5000, expectPriority: false},
+	} {
+		gsSet := &carrierv1alpha1.GameServerSet{Spec: carrierv1alpha1.GameServerSetSpec{Replicas: testCase.replicas}}
+		if priority := c.queueFor(gsSet) == c.priorityQueue; priority != testCase.expectPriority {
+			t.Errorf("replicas %v: desired priority %v, get: %v", testCase.replicas, testCase.expectPriority, priority)
+		}
+	}
+	c.priorityQueue = nil
+	gsSet := &carrierv1alpha1.GameServerSet{}
+	if c.queueFor(gsSet) != c.workerQueue {
+		t.Errorf("desired worker queue if priority lane disabled")
+	}
+}
+
+func TestKeySet(t *testing.T) {
+	var s keySet
+	if !s.tryAdd("default/a") {
+		t.Errorf("desired default/a added")
+	}
+	if s.tryAdd("default/a") {
+		t.Errorf("desired default/a busy")
+	}
+	s.remove("default/a")
+	if !s.tryAdd("default/a") {
+		t.Errorf("desired default/a added after removed")
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// inheritedKeys is the keys of labels and annotations inherited from gameServerMetadata,
+// recorded in InheritedMetadataAnnotation of GameServers.
+type inheritedKeys struct {
+	Labels      []string `json:"labels,omitempty"`
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+// applyGameServerMetadata sets the gameServerMetadata of gsSet to gs being created, reserved
+// keys of carrier are never overwritten.
+func applyGameServerMetadata(gsSet *carrierv1alpha1.GameServerSet, gs *carrierv1alpha1.GameServer) {
+	metadata := gsSet.Spec.GameServerMetadata
+	if metadata == nil || len(metadata.Labels) == 0 && len(metadata.Annotations) == 0 {
+		return
+	}
+	gs.Labels = util.Merge(gs.Labels, withoutReservedKeys(metadata.Labels))
+	gs.Annotations = util.Merge(gs.Annotations, withoutReservedKeys(metadata.Annotations))
+	gs.Annotations[util.InheritedMetadataAnnotation] = encodeInheritedKeys(metadata)
+}
+
+// syncGameServerMetadata patches the gameServerMetadata of gsSet into its GameServers,
+// so GameServers could be relabeled without being replaced.
+func (c *Controller) syncGameServerMetadata(gsSet *carrierv1alpha1.GameServerSet,
+	list []*carrierv1alpha1.GameServer) error {
+	for _, gs := range list {
+		if gs.DeletionTimestamp != nil {
+			continue
+		}
+		patch := metadataPatch(gsSet.Spec.GameServerMetadata, gs)
+		if patch == nil {
+			continue
+		}
+		klog.V(4).Infof("Patch metadata of GameServer %v/%v: %s", gs.Namespace, gs.Name, patch)
+		_, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Patch(gs.Name, types.MergePatchType, patch)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return errors.Wrapf(err, "error patching metadata of GameServer %v/%v", gs.Namespace, gs.Name)
+		}
+	}
+	return nil
+}
+
+// metadataPatch returns the merge patch setting the inherited labels and annotations of gs
+// to metadata, and removing those no longer in metadata. Nil is returned if nothing changes.
+func metadataPatch(metadata *carrierv1alpha1.GameServerMetadata, gs *carrierv1alpha1.GameServer) []byte {
+	if metadata == nil {
+		metadata = &carrierv1alpha1.GameServerMetadata{}
+	}
+	var previous inheritedKeys
+	if value, ok := gs.Annotations[util.InheritedMetadataAnnotation]; ok {
+		if err := json.Unmarshal([]byte(value), &previous); err != nil {
+			klog.Warningf("Invalid inherited metadata of GameServer %v/%v: %v", gs.Namespace, gs.Name, err)
+		}
+	}
+	labels := mapChanges(gs.Labels, metadata.Labels, previous.Labels)
+	annotations := mapChanges(gs.Annotations, metadata.Annotations, previous.Annotations)
+	current, recorded := gs.Annotations[util.InheritedMetadataAnnotation]
+	if len(metadata.Labels) == 0 && len(metadata.Annotations) == 0 {
+		if recorded {
+			annotations[util.InheritedMetadataAnnotation] = nil
+		}
+	} else if keys := encodeInheritedKeys(metadata); keys != current {
+		annotations[util.InheritedMetadataAnnotation] = &keys
+	}
+	if len(labels) == 0 && len(annotations) == 0 {
+		return nil
+	}
+	patch, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": labels, "annotations": annotations},
+	})
+	return patch
+}
+
+// mapChanges returns the changes setting current to desired, the previous keys not desired
+// any more are removed. Reserved keys of carrier are never changed.
+func mapChanges(current, desired map[string]string, previous []string) map[string]*string {
+	changes := make(map[string]*string)
+	for key, value := range desired {
+		if isReservedKey(key) {
+			continue
+		}
+		if existing, ok := current[key]; !ok || existing != value {
+			value := value
+			changes[key] = &value
+		}
+	}
+	for _, key := range previous {
+		if _, ok := desired[key]; ok || isReservedKey(key) {
+			continue
+		}
+		if _, ok := current[key]; ok {
+			changes[key] = nil
+		}
+	}
+	return changes
+}
+
+// isReservedKey returns true if key is managed by carrier.
+func isReservedKey(key string) bool {
+	switch key {
+	case util.GameServerSetLabelKey, util.SquadNameLabelKey, util.GameServerHash, util.InheritedMetadataAnnotation,
+		util.ControllerVersionAnnotation, util.RoleLabelKey, util.GameServerPodLabelKey, util.PreflightLabelKey,
+		util.GameVersionLabelKey, util.GameServerRegionLabelKey, util.GameServerZoneLabelKey,
+		util.GameServerIndexAnnotation, util.GameServerSDKPortsAnnotation, util.GameServerDynamicPortAllocated:
+		return true
+	}
+	return false
+}
+
+// withoutReservedKeys returns the entries of m whose keys are not reserved.
+func withoutReservedKeys(m map[string]string) map[string]string {
+	filtered := make(map[string]string, len(m))
+	for key, value := range m {
+		if !isReservedKey(key) {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+// encodeInheritedKeys returns the sorted keys of metadata in JSON.
+func encodeInheritedKeys(metadata *carrierv1alpha1.GameServerMetadata) string {
+	keys := inheritedKeys{}
+	for key := range metadata.Labels {
+		keys.Labels = append(keys.Labels, key)
+	}
+	for key := range metadata.Annotations {
+		keys.Annotations = append(keys.Annotations, key)
+	}
+	sort.Strings(keys.Labels)
+	sort.Strings(keys.Annotations)
+	data, _ := json.Marshal(keys)
+	return string(data)
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestBuildGameServerMetadata(t *testing.T) {
+	gsSet := gss()
+	gsSet.Spec.GameServerMetadata = &carrierv1alpha1.GameServerMetadata{
+		Labels:      map[string]string{"team": "ops", util.GameServerSetLabelKey: "other", util.GameServerHash: "other"},
+		Annotations: map[string]string{"owner": "alice"},
+	}
+	if gsSet.Spec.Template.Labels == nil {
+		gsSet.Spec.Template.Labels = map[string]string{}
+	}
+	gsSet.Spec.Template.Labels[util.GameServerHash] = "abc"
+	gs := BuildGameServer(gsSet)
+	if gs.Labels["team"] != "ops" || gs.Annotations["owner"] != "alice" {
+		t.Errorf("desired inherited metadata, get: %v, %v", gs.Labels, gs.Annotations)
+	}
+	if gs.Labels[util.GameServerSetLabelKey] != gsSet.Name {
+		t.Errorf("desired reserved label %v, get: %v", gsSet.Name, gs.Labels[util.GameServerSetLabelKey])
+	}
+	if gs.Labels[util.GameServerHash] != "abc" {
+		t.Errorf("desired template hash kept, get: %v", gs.Labels[util.GameServerHash])
+	}
+	if len(gs.Annotations[util.InheritedMetadataAnnotation]) == 0 {
+		t.Errorf("desired inherited keys recorded, get: %v", gs.Annotations)
+	}
+}
+
+func TestMetadataPatch(t *testing.T) {
+	recorded := `{"labels":["stale","team"]}`
+	tests := []struct {
+		name     string
+		metadata *carrierv1alpha1.GameServerMetadata
+		labels   map[string]string
+		desired  map[string]interface{}
+	}{
+		{
+			name:   "no metadata",
+			labels: map[string]string{"team": "ops"},
+		},
+		{
+			name:     "up to date",
+			metadata: &carrierv1alpha1.GameServerMetadata{Labels: map[string]string{"stale": "1", "team": "ops"}},
+			labels:   map[string]string{"stale": "1", "team": "ops"},
+		},
+		{
+			name:     "changed and removed",
+			metadata: &carrierv1alpha1.GameServerMetadata{Labels: map[string]string{"team": "dev"}},
+			labels:   map[string]string{"stale": "1", "team": "ops", "app": "game"},
+			desired: map[string]interface{}{
+				"labels":      map[string]interface{}{"stale": nil, "team": "dev"},
+				"annotations": map[string]interface{}{util.InheritedMetadataAnnotation: `{"labels":["team"]}`},
+			},
+		},
+	}
+	for _, test := range tests {
+		gs := &carrierv1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Labels: test.labels}}
+		if test.metadata != nil {
+			gs.Annotations = map[string]string{util.InheritedMetadataAnnotation: recorded}
+		}
+		patch := metadataPatch(test.metadata, gs)
+		if test.desired == nil {
+			if patch != nil {
+				t.Errorf("%v: desired no patch, get: %s", test.name, patch)
+			}
+			continue
+		}
+		var get map[string]interface{}
+		if err := json.Unmarshal(patch, &get); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(get["metadata"], test.desired) {
+			t.Errorf("%v: desired patch %v, get: %s", test.name, test.desired, patch)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/metrics"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// recordTimeToReady records the time from creation to ready of GameServer owned by
+// a Squad, GameServers ever in place updated are excluded.
+func (c *Controller) recordTimeToReady(oldGS, gs *carrierv1alpha1.GameServer) {
+	squad := gs.Labels[util.SquadNameLabelKey]
+	if len(squad) == 0 || !becameReady(oldGS, gs) {
+		return
+	}
+	if _, ok := gs.Annotations[util.GameServerInPlaceUpdatingAnnotation]; ok {
+		return
+	}
+	var revision string
+	gsSet, err := c.gameServerSetLister.GameServerSets(gs.Namespace).Get(gs.Labels[util.GameServerSetLabelKey])
+	if err == nil {
+		revision = gsSet.Annotations[util.RevisionAnnotation]
+	}
+	metrics.RecordSquadGameServerReady(gs.Namespace, squad, revision, time.Since(gs.CreationTimestamp.Time))
+}
+
+// recordStartupMilestones records the startup milestones of GameServer owned by a Squad,
+// once all of them are reached.
+func (c *Controller) recordStartupMilestones(oldGS, gs *carrierv1alpha1.GameServer) {
+	squad := gs.Labels[util.SquadNameLabelKey]
+	milestones := gs.Status.Startup
+	if len(squad) == 0 || milestones == nil || milestones.ReadyTime == nil ||
+		oldGS.Status.Startup != nil && oldGS.Status.Startup.ReadyTime != nil {
+		return
+	}
+	created := gs.CreationTimestamp.Time
+	for milestone, t := range map[string]*metav1.Time{
+		"scheduled":  milestones.ScheduledTime,
+		"podRunning": milestones.PodRunningTime,
+		"ready":      milestones.ReadyTime,
+	} {
+		if t != nil {
+			metrics.RecordSquadGameServerStartup(gs.Namespace, squad, milestone, t.Sub(created))
+		}
+	}
+}
+
+// becameReady checks if GameServer turns to be running and ready.
+func becameReady(oldGS, gs *carrierv1alpha1.GameServer) bool {
+	return !isRunningAndReady(oldGS) && isRunningAndReady(gs)
+}
+
+func isRunningAndReady(gs *carrierv1alpha1.GameServer) bool {
+	return gs.Status.State == carrierv1alpha1.GameServerRunning && gameservers.IsReady(gs)
+}
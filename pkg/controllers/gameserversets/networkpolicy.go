@@ -0,0 +1,243 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// defaultAPIServerPorts are the ports the apiserver usually listens on,
+// 443 behind the kubernetes Service and 6443 on the control plane nodes.
+var defaultAPIServerPorts = []int32{443, 6443}
+
+// MaxNetworkPolicyPorts is the max number of ports GameServer ports expand to in the
+// NetworkPolicy of a GameServerSet, as port ranges are expanded to single ports.
+const MaxNetworkPolicyPorts = 256
+
+// networkPolicyInvalid is the reason of events on GameServerSets whose NetworkPolicy could
+// not be synced as desired.
+const networkPolicyInvalid = "NetworkPolicyInvalid"
+
+// syncNetworkPolicy creates, updates or deletes the NetworkPolicy of GameServerSet
+// according to its NetworkPolicy. NetworkPolicies not controlled by GameServerSet are left alone.
+func (c *Controller) syncNetworkPolicy(gsSet *carrierv1alpha1.GameServerSet) error {
+	np, err := c.networkPolicyLister.NetworkPolicies(gsSet.Namespace).Get(gsSet.Name)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "error retrieving NetworkPolicy of GameServerSet %s", gsSet.Name)
+	}
+	if err == nil && !metav1.IsControlledBy(np, gsSet) {
+		if gsSet.Spec.NetworkPolicy != nil {
+			c.recorder.Eventf(gsSet, corev1.EventTypeWarning, "NetworkPolicyConflict",
+				"NetworkPolicy %v exists and is not controlled by GameServerSet", np.Name)
+		}
+		return nil
+	}
+	nps := c.kubeClient.NetworkingV1().NetworkPolicies(gsSet.Namespace)
+	if gsSet.Spec.NetworkPolicy == nil {
+		if np == nil {
+			return nil
+		}
+		klog.Infof("Deleting NetworkPolicy of GameServerSet %v/%v", gsSet.Namespace, gsSet.Name)
+		err = nps.Delete(np.Name, &metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return errors.Wrapf(err, "error deleting NetworkPolicy of GameServerSet %s", gsSet.Name)
+		}
+		return nil
+	}
+	ports, err := gameServerPolicyPorts(gsSet.Spec.Template.Spec.Ports)
+	if err != nil {
+		// an existing NetworkPolicy is kept rather than opening or closing all ports.
+		c.events.Eventf(c.recorder, gsSet, corev1.EventTypeWarning, networkPolicyInvalid,
+			"%v, NetworkPolicy is not synced", err)
+		return nil
+	}
+	if len(gsSet.Spec.NetworkPolicy.APIServerCIDRs) == 0 {
+		c.events.Eventf(c.recorder, gsSet, corev1.EventTypeWarning, networkPolicyInvalid,
+			"apiServerCIDRs of NetworkPolicy is empty, outbound traffic to the apiserver is denied")
+	} else {
+		c.events.Resolve(gsSet.UID, networkPolicyInvalid)
+	}
+	desired := newNetworkPolicy(gsSet, ports)
+	if np == nil {
+		_, err = nps.Create(desired)
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return errors.Wrapf(err, "error creating NetworkPolicy of GameServerSet %s", gsSet.Name)
+		}
+		c.recorder.Eventf(gsSet, corev1.EventTypeNormal, "SuccessfulCreate",
+			"Created NetworkPolicy %v", desired.Name)
+		return nil
+	}
+	if apiequality.Semantic.DeepEqual(np.Spec, desired.Spec) {
+		return nil
+	}
+	npCopy := np.DeepCopy()
+	npCopy.Spec = desired.Spec
+	if _, err = nps.Update(npCopy); err != nil {
+		return errors.Wrapf(err, "error updating NetworkPolicy of GameServerSet %s", gsSet.Name)
+	}
+	klog.V(3).Infof("Updated NetworkPolicy of GameServerSet %v/%v", gsSet.Namespace, gsSet.Name)
+	return nil
+}
+
+// newNetworkPolicy builds the NetworkPolicy isolating pods of GameServerSet. Both directions are
+// restricted, so pods only accept traffic on the ports declared by GameServers, and only reach
+// DNS, the apiserver and the egress of the policy. The apiserver is not reachable if its CIDRs
+// are not set, rather than the apiserver ports of all destinations. ports are the ingress
+// ports of GameServers.
+func newNetworkPolicy(gsSet *carrierv1alpha1.GameServerSet,
+	ports []networkingv1.NetworkPolicyPort) *networkingv1.NetworkPolicy {
+	policy := gsSet.Spec.NetworkPolicy
+	ref := metav1.NewControllerRef(gsSet, carrierv1alpha1.SchemeGroupVersion.WithKind("GameServerSet"))
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            gsSet.Name,
+			Namespace:       gsSet.Namespace,
+			Labels:          map[string]string{util.GameServerSetLabelKey: gsSet.Name},
+			OwnerReferences: []metav1.OwnerReference{*ref},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{util.GameServerSetLabelKey: gsSet.Name},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			Egress:      []networkingv1.NetworkPolicyEgressRule{dnsEgressRule()},
+		},
+	}
+	if len(policy.APIServerCIDRs) != 0 {
+		np.Spec.Egress = append(np.Spec.Egress, apiServerEgressRule(policy))
+	}
+	// no ingress rule denies all inbound traffic, as a rule without ports would allow all ports.
+	if len(ports) != 0 {
+		np.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{Ports: ports, From: policy.From}}
+	}
+	for _, rule := range policy.Egress {
+		np.Spec.Egress = append(np.Spec.Egress, *rule.DeepCopy())
+	}
+	return np
+}
+
+// ValidateNetworkPolicyPorts checks GameServer ports expand to at most MaxNetworkPolicyPorts
+// NetworkPolicy ports.
+func ValidateNetworkPolicyPorts(ports []carrierv1alpha1.GameServerPort) error {
+	_, err := gameServerPolicyPorts(ports)
+	return err
+}
+
+// gameServerPolicyPorts returns the container ports declared by GameServer ports. Ranges are
+// expanded, as NetworkPolicy ports are single ones. TCPUDP opens both protocols and the
+// protocol defaults to UDP, as when the ports are added to the pod. Returns an error if the
+// ports expand to more than MaxNetworkPolicyPorts.
+func gameServerPolicyPorts(ports []carrierv1alpha1.GameServerPort) ([]networkingv1.NetworkPolicyPort, error) {
+	count := 0
+	for _, p := range ports {
+		n := 0
+		if p.ContainerPort != nil {
+			n++
+		}
+		if r := p.ContainerPortRange; r != nil && r.MaxPort >= r.MinPort {
+			n += int(r.MaxPort-r.MinPort) + 1
+		}
+		if p.Protocol == "TCPUDP" {
+			n *= 2
+		}
+		count += n
+	}
+	if count > MaxNetworkPolicyPorts {
+		return nil, errors.Errorf("GameServer ports expand to %d NetworkPolicy ports, more than %d",
+			count, MaxNetworkPolicyPorts)
+	}
+	var policyPorts []networkingv1.NetworkPolicyPort
+	add := func(port int32, protocol corev1.Protocol) {
+		protocols := []corev1.Protocol{protocol}
+		switch protocol {
+		case "":
+			protocols = []corev1.Protocol{corev1.ProtocolUDP}
+		case "TCPUDP":
+			protocols = []corev1.Protocol{corev1.ProtocolTCP, corev1.ProtocolUDP}
+		}
+		for i := range protocols {
+			p := intstr.FromInt(int(port))
+			policyPorts = append(policyPorts, networkingv1.NetworkPolicyPort{Protocol: &protocols[i], Port: &p})
+		}
+	}
+	for _, p := range ports {
+		if p.ContainerPort != nil {
+			add(*p.ContainerPort, p.Protocol)
+		}
+		if p.ContainerPortRange != nil {
+			for port := p.ContainerPortRange.MinPort; port <= p.ContainerPortRange.MaxPort; port++ {
+				add(port, p.Protocol)
+			}
+		}
+	}
+	return policyPorts, nil
+}
+
+// dnsEgressRule allows outbound DNS queries, which resolving the apiserver
+// and backends of the game needs.
+func dnsEgressRule() networkingv1.NetworkPolicyEgressRule {
+	dns := intstr.FromInt(53)
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	return networkingv1.NetworkPolicyEgressRule{
+		Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: &dns}, {Protocol: &tcp, Port: &dns}},
+	}
+}
+
+// apiServerEgressRule allows the SDK server sidecar to reach the apiserver. NetworkPolicies select
+// pods rather than containers, so the rule applies to the GameServer container as well.
+func apiServerEgressRule(policy *carrierv1alpha1.NetworkPolicy) networkingv1.NetworkPolicyEgressRule {
+	ports := policy.APIServerPorts
+	if len(ports) == 0 {
+		ports = defaultAPIServerPorts
+	}
+	rule := networkingv1.NetworkPolicyEgressRule{}
+	for _, port := range ports {
+		p := intstr.FromInt(int(port))
+		tcp := corev1.ProtocolTCP
+		rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{Protocol: &tcp, Port: &p})
+	}
+	for _, cidr := range policy.APIServerCIDRs {
+		rule.To = append(rule.To, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	return rule
+}
+
+// handleNetworkPolicy enqueues the GameServerSet controlling the NetworkPolicy,
+// so that changes made by others are reverted.
+func (c *Controller) handleNetworkPolicy(obj interface{}) {
+	np, ok := obj.(*networkingv1.NetworkPolicy)
+	if !ok {
+		return
+	}
+	ref := metav1.GetControllerOf(np)
+	if ref == nil || ref.Kind != "GameServerSet" {
+		return
+	}
+	gsSet, err := c.gameServerSetLister.GameServerSets(np.Namespace).Get(ref.Name)
+	if err != nil || gsSet.UID != ref.UID {
+		return
+	}
+	c.enqueueGameServerSet(gsSet)
+}
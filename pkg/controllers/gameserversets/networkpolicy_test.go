@@ -0,0 +1,166 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkinglisterv1 "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestGameServerPolicyPorts(t *testing.T) {
+	port := int32(7777)
+	ports, err := gameServerPolicyPorts([]v1alpha1.GameServerPort{
+		{Name: "default", ContainerPort: &port},
+		{Name: "both", ContainerPort: &port, Protocol: "TCPUDP"},
+		{Name: "range", ContainerPortRange: &v1alpha1.PortRange{MinPort: 8000, MaxPort: 8001},
+			Protocol: corev1.ProtocolTCP},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	desired := []struct {
+		port     int
+		protocol corev1.Protocol
+	}{
+		{7777, corev1.ProtocolUDP},
+		{7777, corev1.ProtocolTCP},
+		{7777, corev1.ProtocolUDP},
+		{8000, corev1.ProtocolTCP},
+		{8001, corev1.ProtocolTCP},
+	}
+	if len(ports) != len(desired) {
+		t.Fatalf("desired %v ports, get: %v", len(desired), ports)
+	}
+	for i, d := range desired {
+		if ports[i].Port.IntValue() != d.port || *ports[i].Protocol != d.protocol {
+			t.Errorf("desired port %v/%v, get: %v/%v", d.port, d.protocol, ports[i].Port.IntValue(),
+				*ports[i].Protocol)
+		}
+	}
+}
+
+func TestGameServerPolicyPortsTooMany(t *testing.T) {
+	tests := []struct {
+		name  string
+		ports []v1alpha1.GameServerPort
+		valid bool
+	}{
+		{
+			name: "max",
+			ports: []v1alpha1.GameServerPort{{Name: "range", Protocol: "TCPUDP",
+				ContainerPortRange: &v1alpha1.PortRange{MinPort: 8000, MaxPort: 8000 + MaxNetworkPolicyPorts/2 - 1}}},
+			valid: true,
+		},
+		{
+			name: "too many",
+			ports: []v1alpha1.GameServerPort{{Name: "range", Protocol: "TCPUDP",
+				ContainerPortRange: &v1alpha1.PortRange{MinPort: 8000, MaxPort: 8000 + MaxNetworkPolicyPorts/2}}},
+		},
+		{
+			name: "whole range",
+			ports: []v1alpha1.GameServerPort{{Name: "range",
+				ContainerPortRange: &v1alpha1.PortRange{MinPort: 1, MaxPort: 65535}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := ValidateNetworkPolicyPorts(test.ports); test.valid != (err == nil) {
+				t.Errorf("desired valid: %v, get: %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestSyncNetworkPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kubeClient, _, _, _, c := fakeController(ctx)
+	port := int32(7777)
+	gsSet := gss()
+	gsSet.Spec.Template.Spec.Ports = []v1alpha1.GameServerPort{{Name: "default", ContainerPort: &port}}
+	gsSet.Spec.NetworkPolicy = &v1alpha1.NetworkPolicy{APIServerCIDRs: []string{"10.0.0.1/32"}}
+	if err := c.syncNetworkPolicy(gsSet); err != nil {
+		t.Fatal(err)
+	}
+	nps := kubeClient.NetworkingV1().NetworkPolicies(gsSet.Namespace)
+	np, err := nps.Get(gsSet.Name, v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v1.IsControlledBy(np, gsSet) {
+		t.Errorf("desired NetworkPolicy controlled by GameServerSet, get: %v", np.OwnerReferences)
+	}
+	if len(np.Spec.PolicyTypes) != 2 {
+		t.Errorf("desired both ingress and egress denied by default, get: %v", np.Spec.PolicyTypes)
+	}
+	if len(np.Spec.Ingress) != 1 || len(np.Spec.Ingress[0].Ports) != 1 {
+		t.Fatalf("desired ingress on the game port only, get: %+v", np.Spec.Ingress)
+	}
+	if len(np.Spec.Egress) != 2 || len(np.Spec.Egress[1].To) != 1 || np.Spec.Egress[1].To[0].IPBlock == nil ||
+		np.Spec.Egress[1].To[0].IPBlock.CIDR != "10.0.0.1/32" || len(np.Spec.Egress[1].Ports) != 2 {
+		t.Fatalf("desired egress to DNS and the apiserver, get: %+v", np.Spec.Egress)
+	}
+
+	gsSet.Spec.NetworkPolicy.APIServerCIDRs = nil
+	c.networkPolicyLister = fakeNetworkPolicyLister(t, np)
+	if err = c.syncNetworkPolicy(gsSet); err != nil {
+		t.Fatal(err)
+	}
+	np, _ = nps.Get(gsSet.Name, v1.GetOptions{})
+	if len(np.Spec.Egress) != 1 || len(np.Spec.Egress[0].Ports) != 2 ||
+		np.Spec.Egress[0].Ports[0].Port.IntValue() != 53 {
+		t.Errorf("desired egress to DNS only without apiserver CIDRs, get: %+v", np.Spec.Egress)
+	}
+
+	gsSet.Spec.Template.Spec.Ports = nil
+	c.networkPolicyLister = fakeNetworkPolicyLister(t, np)
+	if err = c.syncNetworkPolicy(gsSet); err != nil {
+		t.Fatal(err)
+	}
+	np, _ = nps.Get(gsSet.Name, v1.GetOptions{})
+	if len(np.Spec.Ingress) != 0 {
+		t.Errorf("desired all ingress denied without ports, get: %+v", np.Spec.Ingress)
+	}
+
+	gsSet.Spec.NetworkPolicy = nil
+	c.networkPolicyLister = fakeNetworkPolicyLister(t, np)
+	if err = c.syncNetworkPolicy(gsSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = nps.Get(gsSet.Name, v1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("desired NetworkPolicy deleted, get: %v", err)
+	}
+}
+
+func fakeNetworkPolicyLister(t *testing.T,
+	nps ...*networkingv1.NetworkPolicy) networkinglisterv1.NetworkPolicyLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, np := range nps {
+		if err := indexer.Add(np); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return networkinglisterv1.NewNetworkPolicyLister(indexer)
+}
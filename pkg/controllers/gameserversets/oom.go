@@ -0,0 +1,290 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/util/hash"
+)
+
+const (
+	// defaultOOMThreshold is the default number of OOMKilled GameServers before remediating.
+	defaultOOMThreshold = 3
+	// defaultMemoryBumpPercent is the default percentage the memory limit is increased by.
+	defaultMemoryBumpPercent = 50
+	// oomKilledReason is the reason of containers killed for running out of memory.
+	oomKilledReason = "OOMKilled"
+)
+
+// remediateOOMKills applies the OOMPolicy of GameServerSet, the memory limit for new GameServers
+// is bumped or the template is marked as faulty once enough GameServers are OOMKilled.
+// The TemplateFaulty condition is set on status according to whether the template is faulty.
+func (c *Controller) remediateOOMKills(gsSet *carrierv1alpha1.GameServerSet,
+	list []*carrierv1alpha1.GameServer, status *statusWriter) (*carrierv1alpha1.GameServerSet, error) {
+	if gsSet.Spec.OOMPolicy == nil {
+		return gsSet, nil
+	}
+	gsSetCopy, message := computeOOMRemediation(gsSet, list)
+	if gsSetCopy != nil {
+		updated, err := c.carrierClient.CarrierV1alpha1().GameServerSets(gsSet.Namespace).Update(gsSetCopy)
+		if err != nil {
+			return gsSet, errors.Wrapf(err, "error applying OOMPolicy of GameServerSet %s", gsSet.Name)
+		}
+		if len(message) != 0 {
+			klog.Infof("GameServerSet %v/%v: %v", gsSet.Namespace, gsSet.Name, message)
+			c.recorder.Event(gsSet, corev1.EventTypeWarning, "OOMKilled", message)
+		}
+		gsSet = updated
+	}
+	if isTemplateFaulty(gsSet) {
+		status.setCondition(carrierv1alpha1.GameServerSetTemplateFaulty, corev1.ConditionTrue,
+			"OOMKilled", fmt.Sprintf("GameServers of template %v are OOMKilled repeatedly",
+				oomTemplateHash(gsSet)))
+	} else {
+		status.setCondition(carrierv1alpha1.GameServerSetTemplateFaulty, corev1.ConditionFalse, "", "")
+	}
+	return gsSet, nil
+}
+
+// recordOOMKills adds OOMKilled GameServers being deleted to the count recorded in GameServerSet,
+// so that they are still counted after gone.
+func (c *Controller) recordOOMKills(gsSet *carrierv1alpha1.GameServerSet,
+	deleted []*carrierv1alpha1.GameServer) (*carrierv1alpha1.GameServerSet, error) {
+	if gsSet.Spec.OOMPolicy == nil {
+		return gsSet, nil
+	}
+	count := countOOMKilled(gsSet, deleted, true)
+	if count == 0 {
+		return gsSet, nil
+	}
+	gsSetCopy := gsSet.DeepCopy()
+	if gsSetCopy.Annotations == nil {
+		gsSetCopy.Annotations = make(map[string]string)
+	}
+	gsSetCopy.Annotations[util.GameServerSetOOMKilledAnnotation] = strconv.Itoa(getOOMKilledCount(gsSet) + count)
+	updated, err := c.carrierClient.CarrierV1alpha1().GameServerSets(gsSet.Namespace).Update(gsSetCopy)
+	if err != nil {
+		return gsSet, errors.Wrapf(err, "error recording OOMKilled GameServers of GameServerSet %s", gsSet.Name)
+	}
+	return updated, nil
+}
+
+// computeOOMRemediation returns a copy of gsSet with OOMPolicy applied and the message describing
+// the remediation. Returns nil if nothing changes.
+func computeOOMRemediation(gsSet *carrierv1alpha1.GameServerSet,
+	list []*carrierv1alpha1.GameServer) (*carrierv1alpha1.GameServerSet, string) {
+	policy := gsSet.Spec.OOMPolicy
+	templateHash := oomTemplateHash(gsSet)
+	faulty, ok := gsSet.Annotations[util.GameServerSetFaultyTemplateAnnotation]
+	if ok && faulty != templateHash {
+		// template changed, start over.
+		gsSetCopy := gsSet.DeepCopy()
+		delete(gsSetCopy.Annotations, util.GameServerSetFaultyTemplateAnnotation)
+		delete(gsSetCopy.Annotations, util.GameServerSetOOMKilledAnnotation)
+		return gsSetCopy, ""
+	}
+	if ok {
+		return nil, ""
+	}
+	threshold := int(policy.Threshold)
+	if threshold <= 0 {
+		threshold = defaultOOMThreshold
+	}
+	count := getOOMKilledCount(gsSet) + countOOMKilled(gsSet, list, false)
+	if count < threshold {
+		return nil, ""
+	}
+	gsSetCopy := gsSet.DeepCopy()
+	if gsSetCopy.Annotations == nil {
+		gsSetCopy.Annotations = make(map[string]string)
+	}
+	delete(gsSetCopy.Annotations, util.GameServerSetOOMKilledAnnotation)
+	if policy.Action == carrierv1alpha1.OOMActionBumpMemory {
+		if limit := bumpMemoryLimit(gsSet); limit != nil {
+			gsSetCopy.Annotations[util.GameServerSetMemoryLimitAnnotation] = limit.String()
+			return gsSetCopy, fmt.Sprintf("%v GameServers OOMKilled, memory limit of new GameServers bumped to %v",
+				count, limit.String())
+		}
+	}
+	gsSetCopy.Annotations[util.GameServerSetFaultyTemplateAnnotation] = templateHash
+	return gsSetCopy, fmt.Sprintf("%v GameServers OOMKilled, template %v marked as faulty", count, templateHash)
+}
+
+// bumpMemoryLimit returns the memory limit increased by OOMPolicy. Returns nil if
+// there is no memory limit or MaxMemoryLimit is reached.
+func bumpMemoryLimit(gsSet *carrierv1alpha1.GameServerSet) *resource.Quantity {
+	policy := gsSet.Spec.OOMPolicy
+	current := effectiveMemoryLimit(gsSet)
+	if current == nil || current.IsZero() {
+		return nil
+	}
+	percent := int64(policy.MemoryBumpPercent)
+	if percent <= 0 {
+		percent = defaultMemoryBumpPercent
+	}
+	bumped := resource.NewQuantity(current.Value()+current.Value()*percent/100, resource.BinarySI)
+	if policy.MaxMemoryLimit != nil && bumped.Cmp(*policy.MaxMemoryLimit) > 0 {
+		if current.Cmp(*policy.MaxMemoryLimit) >= 0 {
+			return nil
+		}
+		limit := policy.MaxMemoryLimit.DeepCopy()
+		return &limit
+	}
+	return bumped
+}
+
+// countOOMKilled counts OOMKilled GameServers which are stopped or not. GameServers with
+// a lower memory limit than the current one are ignored, they are remediated already.
+func countOOMKilled(gsSet *carrierv1alpha1.GameServerSet, list []*carrierv1alpha1.GameServer,
+	stopped bool) int {
+	limit := effectiveMemoryLimit(gsSet)
+	count := 0
+	for _, gs := range list {
+		if gs.Status.PodFailure == nil || !strings.Contains(gs.Status.PodFailure.Reason, oomKilledReason) {
+			continue
+		}
+		if gameservers.IsStopped(gs) != stopped {
+			continue
+		}
+		if !stopped && gameservers.IsBeingDeleted(gs) {
+			continue
+		}
+		if limit != nil {
+			gsLimit := getMemoryLimit(&gs.Spec)
+			if gsLimit == nil || gsLimit.Cmp(*limit) < 0 {
+				continue
+			}
+		}
+		count++
+	}
+	return count
+}
+
+// getOOMKilledCount returns the count of OOMKilled GameServers recorded in GameServerSet.
+func getOOMKilledCount(gsSet *carrierv1alpha1.GameServerSet) int {
+	count, err := strconv.Atoi(gsSet.Annotations[util.GameServerSetOOMKilledAnnotation])
+	if err != nil || count < 0 {
+		return 0
+	}
+	return count
+}
+
+// isTemplateFaulty checks if the current template of GameServerSet is marked as faulty.
+func isTemplateFaulty(gsSet *carrierv1alpha1.GameServerSet) bool {
+	if gsSet.Spec.OOMPolicy == nil {
+		return false
+	}
+	faulty, ok := gsSet.Annotations[util.GameServerSetFaultyTemplateAnnotation]
+	return ok && faulty == oomTemplateHash(gsSet)
+}
+
+// oomTemplateHash returns the hash the faulty template is recorded by. It is computed from the
+// pod template, the same as the template hash label, which is only set with an update strategy,
+// so the faulty mark is cleared once the template changes either way.
+func oomTemplateHash(gsSet *carrierv1alpha1.GameServerSet) string {
+	return hash.PodSpecHash(&gsSet.Spec.Template.Spec.Template.Spec)
+}
+
+// effectiveMemoryLimit returns the memory limit of GameServer container for new GameServers,
+// which is the bumped one if it is larger than the one in template.
+func effectiveMemoryLimit(gsSet *carrierv1alpha1.GameServerSet) *resource.Quantity {
+	limit := getMemoryLimit(&gsSet.Spec.Template.Spec)
+	value, ok := gsSet.Annotations[util.GameServerSetMemoryLimitAnnotation]
+	if !ok || gsSet.Spec.OOMPolicy == nil {
+		return limit
+	}
+	bumped, err := resource.ParseQuantity(value)
+	if err != nil {
+		klog.Warningf("Invalid memory limit %q of GameServerSet %v/%v: %v", value, gsSet.Namespace, gsSet.Name, err)
+		return limit
+	}
+	if limit == nil || bumped.Cmp(*limit) > 0 {
+		return &bumped
+	}
+	return limit
+}
+
+// applyMemoryLimit sets the memory limit of GameServer container to the effective one.
+func applyMemoryLimit(gsSet *carrierv1alpha1.GameServerSet, gs *carrierv1alpha1.GameServer) {
+	limit := effectiveMemoryLimit(gsSet)
+	if limit == nil {
+		return
+	}
+	containers := gs.Spec.Template.Spec.Containers
+	for i := range containers {
+		if containers[i].Name != util.GameServerContainerName {
+			continue
+		}
+		if containers[i].Resources.Limits == nil {
+			containers[i].Resources.Limits = make(corev1.ResourceList)
+		}
+		containers[i].Resources.Limits[corev1.ResourceMemory] = limit.DeepCopy()
+	}
+}
+
+// getMemoryLimit returns the memory limit of GameServer container, nil if not set.
+func getMemoryLimit(spec *carrierv1alpha1.GameServerSpec) *resource.Quantity {
+	for _, container := range spec.Template.Spec.Containers {
+		if container.Name != util.GameServerContainerName {
+			continue
+		}
+		if limit, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+			return &limit
+		}
+	}
+	return nil
+}
+
+// setGameServerSetCondition sets the condition of GameServerSet status, the transition time
+// is updated only if the status changes.
+func setGameServerSetCondition(gsSetStatus *carrierv1alpha1.GameServerSetStatus,
+	conditionType carrierv1alpha1.GameServerSetConditionType, status corev1.ConditionStatus,
+	reason, message string) {
+	for i := range gsSetStatus.Conditions {
+		condition := &gsSetStatus.Conditions[i]
+		if condition.Type != conditionType {
+			continue
+		}
+		if condition.Status != status {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		condition.Status = status
+		condition.Reason = reason
+		condition.Message = message
+		return
+	}
+	if status != corev1.ConditionTrue {
+		return
+	}
+	gsSetStatus.Conditions = append(gsSetStatus.Conditions, carrierv1alpha1.GameServerSetCondition{
+		Type:               conditionType,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
@@ -0,0 +1,213 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func oomGameServerSet(action carrierv1alpha1.OOMAction, annotations map[string]string) *carrierv1alpha1.GameServerSet {
+	maxLimit := resource.MustParse("2Gi")
+	return &carrierv1alpha1.GameServerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Annotations: annotations,
+		},
+		Spec: carrierv1alpha1.GameServerSetSpec{
+			OOMPolicy: &carrierv1alpha1.OOMPolicy{
+				Action:         action,
+				Threshold:      2,
+				MaxMemoryLimit: &maxLimit,
+			},
+			Template: carrierv1alpha1.GameServerTemplateSpec{
+				Spec: oomGameServerSpec("1Gi"),
+			},
+		},
+	}
+}
+
+func oomGameServerSpec(limit string) carrierv1alpha1.GameServerSpec {
+	return carrierv1alpha1.GameServerSpec{
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: util.GameServerContainerName,
+						Resources: corev1.ResourceRequirements{
+							Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(limit)},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// faultyHash is the template hash of oomGameServerSet.
+var faultyHash = oomTemplateHash(oomGameServerSet(carrierv1alpha1.OOMActionMarkFaulty, nil))
+
+func oomKilledGameServer(state carrierv1alpha1.GameServerState, limit string) *carrierv1alpha1.GameServer {
+	return &carrierv1alpha1.GameServer{
+		Spec: oomGameServerSpec(limit),
+		Status: carrierv1alpha1.GameServerStatus{
+			State:      state,
+			PodFailure: &carrierv1alpha1.PodFailure{Reason: "CrashLoopBackOff(OOMKilled)"},
+		},
+	}
+}
+
+func TestComputeOOMRemediation(t *testing.T) {
+	crashing := oomKilledGameServer(carrierv1alpha1.GameServerRunning, "1Gi")
+	tests := []struct {
+		name        string
+		gsSet       *carrierv1alpha1.GameServerSet
+		list        []*carrierv1alpha1.GameServer
+		annotations map[string]string
+	}{
+		{
+			name:  "below threshold",
+			gsSet: oomGameServerSet(carrierv1alpha1.OOMActionBumpMemory, nil),
+			list:  []*carrierv1alpha1.GameServer{crashing},
+		},
+		{
+			name:        "bump memory",
+			gsSet:       oomGameServerSet(carrierv1alpha1.OOMActionBumpMemory, nil),
+			list:        []*carrierv1alpha1.GameServer{crashing, crashing},
+			annotations: map[string]string{util.GameServerSetMemoryLimitAnnotation: "1536Mi"},
+		},
+		{
+			name: "recorded count",
+			gsSet: oomGameServerSet(carrierv1alpha1.OOMActionBumpMemory,
+				map[string]string{util.GameServerSetOOMKilledAnnotation: "1"}),
+			list:        []*carrierv1alpha1.GameServer{crashing},
+			annotations: map[string]string{util.GameServerSetMemoryLimitAnnotation: "1536Mi"},
+		},
+		{
+			name: "bump memory to max",
+			gsSet: oomGameServerSet(carrierv1alpha1.OOMActionBumpMemory,
+				map[string]string{util.GameServerSetMemoryLimitAnnotation: "1536Mi"}),
+			list: []*carrierv1alpha1.GameServer{
+				oomKilledGameServer(carrierv1alpha1.GameServerRunning, "1536Mi"),
+				oomKilledGameServer(carrierv1alpha1.GameServerRunning, "1536Mi"),
+			},
+			annotations: map[string]string{util.GameServerSetMemoryLimitAnnotation: "2Gi"},
+		},
+		{
+			name: "remediated GameServers ignored",
+			gsSet: oomGameServerSet(carrierv1alpha1.OOMActionBumpMemory,
+				map[string]string{util.GameServerSetMemoryLimitAnnotation: "1536Mi"}),
+			list: []*carrierv1alpha1.GameServer{crashing, crashing},
+		},
+		{
+			name: "max reached",
+			gsSet: oomGameServerSet(carrierv1alpha1.OOMActionBumpMemory,
+				map[string]string{util.GameServerSetMemoryLimitAnnotation: "2Gi"}),
+			list: []*carrierv1alpha1.GameServer{
+				oomKilledGameServer(carrierv1alpha1.GameServerRunning, "2Gi"),
+				oomKilledGameServer(carrierv1alpha1.GameServerRunning, "2Gi"),
+			},
+			annotations: map[string]string{
+				util.GameServerSetMemoryLimitAnnotation:    "2Gi",
+				util.GameServerSetFaultyTemplateAnnotation: faultyHash,
+			},
+		},
+		{
+			name:        "mark faulty",
+			gsSet:       oomGameServerSet(carrierv1alpha1.OOMActionMarkFaulty, nil),
+			list:        []*carrierv1alpha1.GameServer{crashing, crashing},
+			annotations: map[string]string{util.GameServerSetFaultyTemplateAnnotation: faultyHash},
+		},
+		{
+			name:  "stopped GameServers counted when deleted",
+			gsSet: oomGameServerSet(carrierv1alpha1.OOMActionMarkFaulty, nil),
+			list: []*carrierv1alpha1.GameServer{
+				oomKilledGameServer(carrierv1alpha1.GameServerFailed, "1Gi"),
+				oomKilledGameServer(carrierv1alpha1.GameServerFailed, "1Gi"),
+			},
+		},
+		{
+			name: "template changed",
+			gsSet: oomGameServerSet(carrierv1alpha1.OOMActionMarkFaulty,
+				map[string]string{
+					util.GameServerSetFaultyTemplateAnnotation: "v0",
+					util.GameServerSetOOMKilledAnnotation:      "1",
+				}),
+			annotations: map[string]string{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gsSet, _ := computeOOMRemediation(tc.gsSet, tc.list)
+			if tc.annotations == nil {
+				if gsSet != nil {
+					t.Fatalf("desired no change, get: %v", gsSet.Annotations)
+				}
+				return
+			}
+			if gsSet == nil {
+				t.Fatalf("desired annotations %v, get no change", tc.annotations)
+			}
+			if len(gsSet.Annotations) != len(tc.annotations) {
+				t.Fatalf("desired annotations %v, get: %v", tc.annotations, gsSet.Annotations)
+			}
+			for key, value := range tc.annotations {
+				if gsSet.Annotations[key] != value {
+					t.Errorf("desired annotation %v: %v, get: %v", key, value, gsSet.Annotations[key])
+				}
+			}
+		})
+	}
+}
+
+func TestApplyMemoryLimit(t *testing.T) {
+	gsSet := oomGameServerSet(carrierv1alpha1.OOMActionBumpMemory,
+		map[string]string{util.GameServerSetMemoryLimitAnnotation: "1536Mi"})
+	gs := BuildGameServer(gsSet)
+	applyMemoryLimit(gsSet, gs)
+	limit := gs.Spec.Template.Spec.Containers[0].Resources.Limits[corev1.ResourceMemory]
+	if limit.Cmp(resource.MustParse("1536Mi")) != 0 {
+		t.Errorf("desired memory limit 1536Mi, get: %v", limit.String())
+	}
+	if !isTemplateFaulty(oomGameServerSet(carrierv1alpha1.OOMActionMarkFaulty,
+		map[string]string{util.GameServerSetFaultyTemplateAnnotation: faultyHash})) {
+		t.Errorf("desired template %v faulty", faultyHash)
+	}
+}
+
+func TestTemplateFaultyWithoutHashLabel(t *testing.T) {
+	gsSet := oomGameServerSet(carrierv1alpha1.OOMActionMarkFaulty, nil)
+	marked, _ := computeOOMRemediation(gsSet, []*carrierv1alpha1.GameServer{
+		oomKilledGameServer(carrierv1alpha1.GameServerRunning, "1Gi"),
+		oomKilledGameServer(carrierv1alpha1.GameServerRunning, "1Gi"),
+	})
+	if marked == nil || !isTemplateFaulty(marked) {
+		t.Fatalf("desired template marked faulty without the template hash label")
+	}
+	marked.Spec.Template.Spec.Template.Spec.Containers[0].Image = "server:v2"
+	if isTemplateFaulty(marked) {
+		t.Errorf("desired new template not faulty")
+	}
+	if cleared, _ := computeOOMRemediation(marked, nil); cleared == nil ||
+		len(cleared.Annotations[util.GameServerSetFaultyTemplateAnnotation]) != 0 {
+		t.Errorf("desired faulty mark cleared once template changed")
+	}
+}
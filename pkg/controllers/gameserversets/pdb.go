@@ -0,0 +1,130 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// syncPodDisruptionBudget creates, resizes or deletes the PodDisruptionBudget of GameServerSet
+// according to its DisruptionBudget. PodDisruptionBudgets not controlled by GameServerSet are left alone.
+func (c *Controller) syncPodDisruptionBudget(gsSet *carrierv1alpha1.GameServerSet) error {
+	pdb, err := c.pdbLister.PodDisruptionBudgets(gsSet.Namespace).Get(gsSet.Name)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "error retrieving PodDisruptionBudget of GameServerSet %s", gsSet.Name)
+	}
+	if err == nil && !metav1.IsControlledBy(pdb, gsSet) {
+		if gsSet.Spec.DisruptionBudget != nil {
+			c.recorder.Eventf(gsSet, corev1.EventTypeWarning, "PodDisruptionBudgetConflict",
+				"PodDisruptionBudget %v exists and is not controlled by GameServerSet", pdb.Name)
+		}
+		return nil
+	}
+	pdbs := c.kubeClient.PolicyV1beta1().PodDisruptionBudgets(gsSet.Namespace)
+	if gsSet.Spec.DisruptionBudget == nil {
+		if pdb == nil {
+			return nil
+		}
+		klog.Infof("Deleting PodDisruptionBudget of GameServerSet %v/%v", gsSet.Namespace, gsSet.Name)
+		err = pdbs.Delete(pdb.Name, &metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return errors.Wrapf(err, "error deleting PodDisruptionBudget of GameServerSet %s", gsSet.Name)
+		}
+		return nil
+	}
+	desired := newPodDisruptionBudget(gsSet)
+	if pdb == nil {
+		_, err = pdbs.Create(desired)
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return errors.Wrapf(err, "error creating PodDisruptionBudget of GameServerSet %s", gsSet.Name)
+		}
+		c.recorder.Eventf(gsSet, corev1.EventTypeNormal, "SuccessfulCreate",
+			"Created PodDisruptionBudget %v, min available: %v", desired.Name, desired.Spec.MinAvailable.String())
+		return nil
+	}
+	if apiequality.Semantic.DeepEqual(pdb.Spec, desired.Spec) {
+		return nil
+	}
+	pdbCopy := pdb.DeepCopy()
+	pdbCopy.Spec = desired.Spec
+	if _, err = pdbs.Update(pdbCopy); err != nil {
+		return errors.Wrapf(err, "error updating PodDisruptionBudget of GameServerSet %s", gsSet.Name)
+	}
+	klog.V(3).Infof("Resized PodDisruptionBudget of GameServerSet %v/%v, min available: %v",
+		gsSet.Namespace, gsSet.Name, desired.Spec.MinAvailable.String())
+	return nil
+}
+
+// newPodDisruptionBudget builds the PodDisruptionBudget covering pods of GameServerSet.
+// MinAvailable is an absolute number, because pods of GameServers are not controlled
+// by a scalable resource which a percentage could be resolved against.
+func newPodDisruptionBudget(gsSet *carrierv1alpha1.GameServerSet) *policyv1beta1.PodDisruptionBudget {
+	minAvailable := intstr.FromInt(computeMinAvailable(gsSet.Spec.Replicas,
+		gsSet.Spec.DisruptionBudget.MinAvailablePercent))
+	ref := metav1.NewControllerRef(gsSet, carrierv1alpha1.SchemeGroupVersion.WithKind("GameServerSet"))
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            gsSet.Name,
+			Namespace:       gsSet.Namespace,
+			Labels:          map[string]string{util.GameServerSetLabelKey: gsSet.Name},
+			OwnerReferences: []metav1.OwnerReference{*ref},
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{util.GameServerSetLabelKey: gsSet.Name},
+			},
+		},
+	}
+}
+
+// computeMinAvailable returns percent of replicas rounded up, percent is limited to [0, 100].
+func computeMinAvailable(replicas, percent int32) int {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	return int((int64(replicas)*int64(percent) + 99) / 100)
+}
+
+// handlePodDisruptionBudget enqueues the GameServerSet controlling the PodDisruptionBudget,
+// so that changes made by others are reverted.
+func (c *Controller) handlePodDisruptionBudget(obj interface{}) {
+	pdb, ok := obj.(*policyv1beta1.PodDisruptionBudget)
+	if !ok {
+		return
+	}
+	ref := metav1.GetControllerOf(pdb)
+	if ref == nil || ref.Kind != "GameServerSet" {
+		return
+	}
+	gsSet, err := c.gameServerSetLister.GameServerSets(pdb.Namespace).Get(ref.Name)
+	if err != nil || gsSet.UID != ref.UID {
+		return
+	}
+	c.enqueueGameServerSet(gsSet)
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"context"
+	"testing"
+
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	policylisterv1beta1 "k8s.io/client-go/listers/policy/v1beta1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestComputeMinAvailable(t *testing.T) {
+	for _, tc := range []struct {
+		replicas int32
+		percent  int32
+		desired  int
+	}{
+		{replicas: 10, percent: 50, desired: 5},
+		{replicas: 3, percent: 50, desired: 2},
+		{replicas: 0, percent: 50, desired: 0},
+		{replicas: 10, percent: 0, desired: 0},
+		{replicas: 10, percent: 150, desired: 10},
+		{replicas: 10, percent: -1, desired: 0},
+	} {
+		if get := computeMinAvailable(tc.replicas, tc.percent); get != tc.desired {
+			t.Errorf("replicas %v, percent %v, desired %v, get: %v", tc.replicas, tc.percent, tc.desired, get)
+		}
+	}
+}
+
+func TestSyncPodDisruptionBudget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kubeClient, _, _, _, c := fakeController(ctx)
+	gsSet := withReplicas(4, gss())
+	gsSet.Spec.DisruptionBudget = &v1alpha1.DisruptionBudget{MinAvailablePercent: 60}
+	if err := c.syncPodDisruptionBudget(gsSet); err != nil {
+		t.Fatal(err)
+	}
+	pdbs := kubeClient.PolicyV1beta1().PodDisruptionBudgets(gsSet.Namespace)
+	pdb, err := pdbs.Get(gsSet.Name, v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pdb.Spec.MinAvailable.IntValue() != 3 {
+		t.Errorf("desired min available 3, get: %v", pdb.Spec.MinAvailable.String())
+	}
+	if !v1.IsControlledBy(pdb, gsSet) {
+		t.Errorf("desired PodDisruptionBudget controlled by GameServerSet, get: %v", pdb.OwnerReferences)
+	}
+
+	gsSet.Spec.Replicas = 10
+	c.pdbLister = fakePodDisruptionBudgetLister(t, pdb)
+	if err = c.syncPodDisruptionBudget(gsSet); err != nil {
+		t.Fatal(err)
+	}
+	pdb, _ = pdbs.Get(gsSet.Name, v1.GetOptions{})
+	if pdb.Spec.MinAvailable.IntValue() != 6 {
+		t.Errorf("desired min available 6, get: %v", pdb.Spec.MinAvailable.String())
+	}
+
+	gsSet.Spec.DisruptionBudget = nil
+	c.pdbLister = fakePodDisruptionBudgetLister(t, pdb)
+	if err = c.syncPodDisruptionBudget(gsSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pdbs.Get(gsSet.Name, v1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("desired PodDisruptionBudget deleted, get: %v", err)
+	}
+}
+
+func fakePodDisruptionBudgetLister(t *testing.T,
+	pdbs ...*policyv1beta1.PodDisruptionBudget) policylisterv1beta1.PodDisruptionBudgetLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pdb := range pdbs {
+		if err := indexer.Add(pdb); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return policylisterv1beta1.NewPodDisruptionBudgetLister(indexer)
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+)
+
+// replicaCounts is the number of GameServers of a GameServerSet counted in its status.
+type replicaCounts struct {
+	replicas      int32
+	ready         int32
+	updateBlocked int32
+	draining      int32
+	// readyOutOfService is the ready GameServers out of service, which are not
+	// ready replicas if the GameServerSet excludes constraints.
+	readyOutOfService int32
+}
+
+// countGameServer returns the counts gs contributes to the status of its GameServerSet.
+func countGameServer(gs *carrierv1alpha1.GameServer) replicaCounts {
+	var counts replicaCounts
+	if gs == nil || gameservers.IsBeingDeleted(gs) {
+		// don't count GS that are being deleted
+		return counts
+	}
+	counts.replicas = 1
+	if gameservers.IsUpdateSkipped(gs) {
+		counts.updateBlocked = 1
+	}
+	if gameservers.IsDraining(gs) {
+		counts.draining = 1
+	}
+	if gs.Status.State != carrierv1alpha1.GameServerRunning || gameservers.IsDeletableWithGates(gs) {
+		return counts
+	}
+	if gameservers.IsReady(gs) {
+		// do not count GS will be deleted, this GS are not online
+		counts.ready = 1
+		if gameservers.IsOutOfService(gs) {
+			counts.readyOutOfService = 1
+		}
+	}
+	return counts
+}
+
+// add adds other to counts, or subtracts it if sign is negative.
+func (counts *replicaCounts) add(other replicaCounts, sign int32) {
+	counts.replicas += sign * other.replicas
+	counts.ready += sign * other.ready
+	counts.updateBlocked += sign * other.updateBlocked
+	counts.draining += sign * other.draining
+	counts.readyOutOfService += sign * other.readyOutOfService
+}
+
+// status returns the replicas of the status of gsSet.
+func (counts replicaCounts) status(gsSet *carrierv1alpha1.GameServerSet) carrierv1alpha1.GameServerSetStatus {
+	status := carrierv1alpha1.GameServerSetStatus{
+		Replicas:              counts.replicas,
+		ReadyReplicas:         counts.ready,
+		UpdateBlockedReplicas: counts.updateBlocked,
+		DrainingReplicas:      counts.draining,
+	}
+	if excludeConstraints(gsSet) {
+		status.ReadyReplicas -= counts.readyOutOfService
+	}
+	return status
+}
+
+// replicaCounter aggregates the replica counts of GameServerSets from GameServer events, so the
+// status of a GameServerSet with thousands of GameServers is not recounted on every sync.
+type replicaCounter struct {
+	sync.RWMutex
+	// counts is the replica counts by the uid of GameServerSet.
+	counts map[types.UID]replicaCounts
+	// contributions is what each GameServer was last counted as, by the uid of GameServer.
+	// Counts depending on time, e.g. expiring constraints, may differ between events of
+	// the same GameServer, so what was added is subtracted instead of recounting the old one.
+	contributions map[types.UID]contribution
+	// warm is true once the counts are rebuilt from the lister, GameServer
+	// events are ignored before that.
+	warm bool
+}
+
+// contribution is the counts a GameServer added to its GameServerSet.
+type contribution struct {
+	owner  types.UID
+	counts replicaCounts
+}
+
+// update applies the change of a GameServer from old to cur, either could be nil.
+func (r *replicaCounter) update(old, cur *carrierv1alpha1.GameServer) {
+	r.Lock()
+	defer r.Unlock()
+	if !r.warm {
+		return
+	}
+	gs := cur
+	if gs == nil {
+		gs = old
+	}
+	if gs == nil {
+		return
+	}
+	r.removeLocked(gs.UID)
+	if cur != nil {
+		r.addLocked(cur)
+	}
+}
+
+// removeLocked subtracts the last contribution of the GameServer of uid.
+func (r *replicaCounter) removeLocked(uid types.UID) {
+	last, ok := r.contributions[uid]
+	if !ok {
+		return
+	}
+	delete(r.contributions, uid)
+	counts := r.counts[last.owner]
+	counts.add(last.counts, -1)
+	if counts == (replicaCounts{}) {
+		delete(r.counts, last.owner)
+		return
+	}
+	r.counts[last.owner] = counts
+}
+
+// addLocked adds the counts of gs to its GameServerSet and records them as its contribution.
+func (r *replicaCounter) addLocked(gs *carrierv1alpha1.GameServer) {
+	ref := metav1.GetControllerOf(gs)
+	if ref == nil {
+		return
+	}
+	added := countGameServer(gs)
+	if added == (replicaCounts{}) {
+		return
+	}
+	counts := r.counts[ref.UID]
+	counts.add(added, 1)
+	r.counts[ref.UID] = counts
+	r.contributions[gs.UID] = contribution{owner: ref.UID, counts: added}
+}
+
+// rebuild recomputes the counts from list and marks the counter warm.
+func (r *replicaCounter) rebuild(list []*carrierv1alpha1.GameServer) {
+	r.Lock()
+	defer r.Unlock()
+	r.counts = make(map[types.UID]replicaCounts)
+	r.contributions = make(map[types.UID]contribution)
+	for _, gs := range list {
+		r.addLocked(gs)
+	}
+	r.warm = true
+}
+
+// status returns the replicas of the status of gsSet, false if the counter is not warm.
+func (r *replicaCounter) status(gsSet *carrierv1alpha1.GameServerSet) (carrierv1alpha1.GameServerSetStatus, bool) {
+	if r == nil {
+		return carrierv1alpha1.GameServerSetStatus{}, false
+	}
+	r.RLock()
+	defer r.RUnlock()
+	if !r.warm {
+		return carrierv1alpha1.GameServerSetStatus{}, false
+	}
+	return r.counts[gsSet.UID].status(gsSet), true
+}
@@ -0,0 +1,88 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestReplicaCounter(t *testing.T) {
+	gsSet := &carrierv1alpha1.GameServerSet{ObjectMeta: metav1.ObjectMeta{Name: "test", UID: "123"}}
+	controller := true
+	newGameServer := func(name string, state carrierv1alpha1.GameServerState, outOfService bool) *carrierv1alpha1.GameServer {
+		gs := &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name), OwnerReferences: []metav1.OwnerReference{
+				{Name: gsSet.Name, UID: gsSet.UID, Controller: &controller}}},
+			Status: carrierv1alpha1.GameServerStatus{State: state},
+		}
+		if outOfService {
+			effective := true
+			gs.Spec.Constraints = []carrierv1alpha1.Constraint{
+				{Type: carrierv1alpha1.NotInService, Effective: &effective}}
+		}
+		return gs
+	}
+	a := newGameServer("a", carrierv1alpha1.GameServerRunning, false)
+	b := newGameServer("b", carrierv1alpha1.GameServerStarting, false)
+	c := newGameServer("c", carrierv1alpha1.GameServerRunning, true)
+	list := []*carrierv1alpha1.GameServer{a, b, c}
+
+	r := &replicaCounter{}
+	r.update(nil, a)
+	if _, ok := r.status(gsSet); ok {
+		t.Errorf("desired not warm before rebuild")
+	}
+	r.rebuild(list)
+	status, _ := r.status(gsSet)
+	if !reflect.DeepEqual(status, computeStatus(list, gsSet)) {
+		t.Errorf("desired %+v, get: %+v", computeStatus(list, gsSet), status)
+	}
+
+	bRunning := newGameServer("b", carrierv1alpha1.GameServerRunning, false)
+	r.update(b, bRunning)
+	r.update(a, nil)
+	list = []*carrierv1alpha1.GameServer{bRunning, c}
+	status, _ = r.status(gsSet)
+	if !reflect.DeepEqual(status, computeStatus(list, gsSet)) {
+		t.Errorf("desired %+v, get: %+v", computeStatus(list, gsSet), status)
+	}
+
+	// old counted differently than when it was added, e.g. a constraint expired in between.
+	cStale := newGameServer("c", carrierv1alpha1.GameServerRunning, false)
+	r.update(cStale, c)
+	status, _ = r.status(gsSet)
+	if !reflect.DeepEqual(status, computeStatus(list, gsSet)) {
+		t.Errorf("desired no drift, desired %+v, get: %+v", computeStatus(list, gsSet), status)
+	}
+
+	exclude := true
+	gsSet.Spec.ExcludeConstraints = &exclude
+	status, _ = r.status(gsSet)
+	if status.Replicas != 2 || status.ReadyReplicas != 1 {
+		t.Errorf("desired 2 replicas, 1 ready excluding constraints, get: %+v", status)
+	}
+
+	r.update(bRunning, nil)
+	r.update(c, nil)
+	if len(r.counts) != 0 || len(r.contributions) != 0 {
+		t.Errorf("desired counts dropped when all GameServers deleted, get: %v, %v", r.counts, r.contributions)
+	}
+}
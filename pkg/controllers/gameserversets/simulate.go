@@ -0,0 +1,82 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"fmt"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+)
+
+// Simulation is what the GameServerSet controller would do in the next sync.
+type Simulation struct {
+	// ToAdd is the number of GameServers would be created
+	ToAdd int `json:"toAdd"`
+	// ToDelete are GameServers would be deleted or marked out of service
+	ToDelete []SimulatedDeletion `json:"toDelete,omitempty"`
+	// ExceedBurst is true if the changes are limited by BurstReplicas,
+	// more would be done in following syncs
+	ExceedBurst bool `json:"exceedBurst"`
+}
+
+// SimulatedDeletion is a GameServer would be deleted and the reason.
+type SimulatedDeletion struct {
+	// Name of the GameServer
+	Name string `json:"name"`
+	// Reason why it is selected
+	Reason string `json:"reason"`
+}
+
+// Simulate runs the reconciliation of gsSet against list without changing
+// anything, nodeGameServers is the number of GameServers on each node which
+// is used by MostAllocated scheduling.
+func Simulate(gsSet *carrierv1alpha1.GameServerSet, list []*carrierv1alpha1.GameServer,
+	nodeGameServers map[string]uint64) *Simulation {
+	counter := &Counter{nodeGameServer: make(map[string]uint64, len(nodeGameServers))}
+	for node, count := range nodeGameServers {
+		counter.nodeGameServer[node] = count
+	}
+	// computeExpectation sorts the list in place.
+	candidates := make([]*carrierv1alpha1.GameServer, len(list))
+	copy(candidates, list)
+	toAdd, toDelete, reasons, exceedBurst := computeExpectation(gsSet, candidates, counter, false)
+	simulation := &Simulation{
+		ToAdd:       toAdd,
+		ExceedBurst: exceedBurst,
+	}
+	for _, gs := range toDelete {
+		reason, ok := reasons[gs.Name]
+		if !ok {
+			reason = deletionReason(gs)
+		}
+		simulation.ToDelete = append(simulation.ToDelete, SimulatedDeletion{
+			Name:   gs.Name,
+			Reason: reason,
+		})
+	}
+	return simulation
+}
+
+// deletionReason explains why gs not selected by scaleDownOrdering is deleted.
+func deletionReason(gs *carrierv1alpha1.GameServer) string {
+	switch {
+	case gameservers.IsStopped(gs):
+		return fmt.Sprintf("GameServer is %v", gs.Status.State)
+	case gs.Status.State == carrierv1alpha1.GameServerRunning && gameservers.IsDeletableWithGates(gs):
+		return "deletable gates of GameServer are ready"
+	}
+	return "GameServer is not running"
+}
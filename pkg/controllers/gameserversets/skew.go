@@ -0,0 +1,99 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/util/hash"
+	"github.com/ocgi/carrier/pkg/version"
+)
+
+// migrationBatchSize is the max number of GameServers whose template hash is rewritten in one sync.
+const migrationBatchSize = 20
+
+// templateSpecHash returns the hash of the spec GameServers built from gsSet have, which tells
+// GameServers of the same template apart once the template hash changes across controller
+// versions. Empty is returned if migration mode is disabled.
+func (c *Controller) templateSpecHash(gsSet *carrierv1alpha1.GameServerSet) string {
+	if !c.migrationMode {
+		return ""
+	}
+	return hash.GameServerSpecHash(&BuildGameServer(gsSet).Spec)
+}
+
+// isOfTemplate returns true if gs runs the template of gsSet. The template hash of gs is
+// compared first. In migration mode, a GameServer created by another controller version with
+// a different hash still runs the template if its spec hashes to specHash, which happens when
+// the hash of the same template changes across controller versions, e.g. fields defaulted by
+// the new version.
+func isOfTemplate(gsSet *carrierv1alpha1.GameServerSet, gs *carrierv1alpha1.GameServer, specHash string) bool {
+	if gs.Labels[util.GameServerHash] == gsSet.Labels[util.GameServerHash] {
+		return true
+	}
+	if len(specHash) == 0 || gs.Annotations[util.ControllerVersionAnnotation] == version.Version {
+		return false
+	}
+	return hash.GameServerSpecHash(&gs.Spec) == specHash
+}
+
+// migrateTemplateHash rewrites the stale template hash of GameServers running the template of
+// gsSet in place, at most migrationBatchSize GameServers each sync, so the hash converges
+// lazily after the controller is upgraded instead of replacing the GameServers. Only GameServers
+// created by other controller versions whose whole spec is of the template are migrated, so a
+// GameServer drifted from the template is still replaced.
+func (c *Controller) migrateTemplateHash(gsSet *carrierv1alpha1.GameServerSet,
+	list []*carrierv1alpha1.GameServer) error {
+	if !c.migrationMode {
+		return nil
+	}
+	templateHash := gsSet.Labels[util.GameServerHash]
+	if len(templateHash) == 0 {
+		return nil
+	}
+	specHash := c.templateSpecHash(gsSet)
+	migrated := 0
+	for _, gs := range list {
+		// The rest are migrated in the syncs triggered by the GameServers patched.
+		if migrated >= migrationBatchSize {
+			break
+		}
+		if gs.DeletionTimestamp != nil || gs.Labels[util.GameServerHash] == templateHash ||
+			!isOfTemplate(gsSet, gs, specHash) {
+			continue
+		}
+		patch, _ := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"labels":      map[string]string{util.GameServerHash: templateHash},
+				"annotations": map[string]string{util.ControllerVersionAnnotation: version.Version},
+			},
+		})
+		klog.V(4).Infof("Migrate template hash of GameServer %v/%v from %v to %v", gs.Namespace, gs.Name,
+			gs.Labels[util.GameServerHash], templateHash)
+		_, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Patch(gs.Name, types.MergePatchType, patch)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return errors.Wrapf(err, "error migrating template hash of GameServer %v/%v", gs.Namespace, gs.Name)
+		}
+		migrated++
+	}
+	return nil
+}
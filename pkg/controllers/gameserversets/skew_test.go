@@ -0,0 +1,80 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/version"
+)
+
+func TestIsOfTemplate(t *testing.T) {
+	gsSet := gss()
+	gsSet.Spec.Template.Spec.Template.Spec.Containers = []corev1.Container{{Name: "server", Image: "server:v1"}}
+	gsSet.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+	gsSet.Labels = map[string]string{util.GameServerHash: "new"}
+	c := &Controller{migrationMode: true}
+	specHash := c.templateSpecHash(gsSet)
+
+	oldVersion := func(gs *carrierv1alpha1.GameServer) *carrierv1alpha1.GameServer {
+		gs.Labels[util.GameServerHash] = "old"
+		gs.Annotations[util.ControllerVersionAnnotation] = "v0.0.1"
+		return gs
+	}
+	current := BuildGameServer(gsSet)
+	stale := oldVersion(BuildGameServer(gsSet))
+	stale.Spec.Constraints = []carrierv1alpha1.Constraint{{Type: carrierv1alpha1.NotInService}}
+	changed := oldVersion(BuildGameServer(gsSet))
+	changed.Spec.Template.Spec.Containers[0].Image = "server:v0"
+	drifted := oldVersion(BuildGameServer(gsSet))
+	drifted.Spec.Ports = []carrierv1alpha1.GameServerPort{{Name: "game"}}
+	sameVersion := BuildGameServer(gsSet)
+	sameVersion.Labels[util.GameServerHash] = "old"
+	if current.Annotations[util.ControllerVersionAnnotation] != version.Version {
+		t.Errorf("desired controller version %v recorded, get: %v", version.Version, current.Annotations)
+	}
+
+	tests := []struct {
+		name     string
+		gs       *carrierv1alpha1.GameServer
+		specHash string
+		expected bool
+	}{
+		{name: "same hash", gs: current, specHash: specHash, expected: true},
+		{name: "stale hash of same spec", gs: stale, specHash: specHash, expected: true},
+		{name: "stale hash out of migration mode", gs: stale, expected: false},
+		{name: "old pod spec", gs: changed, specHash: specHash, expected: false},
+		{name: "drifted spec", gs: drifted, specHash: specHash, expected: false},
+		{name: "other hash of same version", gs: sameVersion, specHash: specHash, expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if get := isOfTemplate(gsSet, test.gs, test.specHash); get != test.expected {
+				t.Errorf("desired %v, get: %v", test.expected, get)
+			}
+		})
+	}
+	toReplace := oldGameServersToReplace(gsSet, []*carrierv1alpha1.GameServer{stale, changed}, 2, specHash)
+	if len(toReplace) != 1 || toReplace[0] != changed {
+		t.Errorf("desired only GameServer of old pod spec replaced, get: %v", toReplace)
+	}
+	if (&Controller{}).templateSpecHash(gsSet) != "" {
+		t.Errorf("desired no spec hash out of migration mode")
+	}
+}
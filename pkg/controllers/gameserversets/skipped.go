@@ -0,0 +1,90 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+)
+
+// reasons why a candidate is skipped by in place update.
+const (
+	skipReasonBeingDeleted = "beingDeleted"
+	skipReasonBeforeReady  = "beforeReady"
+	skipReasonGatesNotTrue = "gatesNotTrue"
+)
+
+// inPlaceUpdateSkipReason returns why the GameServer can not be updated in place,
+// empty if it can. GameServers opting out of updates are never candidates, they are
+// counted by UpdateBlockedReplicas instead.
+func inPlaceUpdateSkipReason(gs *carrierv1alpha1.GameServer) string {
+	if gameservers.CanInPlaceUpdating(gs) || gameservers.IsUpdateSkipped(gs) {
+		return ""
+	}
+	if gameservers.IsBeingDeleted(gs) {
+		return skipReasonBeingDeleted
+	}
+	return skipReasonGatesNotTrue
+}
+
+// countSkipped increases the counter of the reason, it is safe for concurrent use.
+func countSkipped(skipped *carrierv1alpha1.InPlaceUpdateSkipped, reason string) {
+	switch reason {
+	case skipReasonBeingDeleted:
+		atomic.AddInt32(&skipped.BeingDeleted, 1)
+	case skipReasonBeforeReady:
+		atomic.AddInt32(&skipped.BeforeReady, 1)
+	case skipReasonGatesNotTrue:
+		atomic.AddInt32(&skipped.GatesNotTrue, 1)
+	}
+}
+
+// totalSkipped returns the number of GameServers skipped of all reasons.
+func totalSkipped(skipped *carrierv1alpha1.InPlaceUpdateSkipped) int32 {
+	if skipped == nil {
+		return 0
+	}
+	return skipped.BeingDeleted + skipped.BeforeReady + skipped.GatesNotTrue
+}
+
+// describeSkipped returns the message of the aggregated event of skipped GameServers.
+func describeSkipped(skipped *carrierv1alpha1.InPlaceUpdateSkipped) string {
+	var counts []string
+	for _, c := range []struct {
+		reason string
+		count  int32
+	}{
+		{skipReasonBeingDeleted, skipped.BeingDeleted},
+		{skipReasonBeforeReady, skipped.BeforeReady},
+		{skipReasonGatesNotTrue, skipped.GatesNotTrue},
+	} {
+		if c.count > 0 {
+			counts = append(counts, fmt.Sprintf("%v: %v", c.reason, c.count))
+		}
+	}
+	return fmt.Sprintf("Skipped %v GameServers in place updating, %v",
+		totalSkipped(skipped), strings.Join(counts, ", "))
+}
+
+// setInPlaceUpdateSkipped records the GameServers skipped by the last in place update in status.
+func setInPlaceUpdateSkipped(status *statusWriter, skipped *carrierv1alpha1.InPlaceUpdateSkipped) {
+	status.mutate(func(s *carrierv1alpha1.GameServerSetStatus) {
+		s.InPlaceUpdateSkipped = skipped
+	})
+}
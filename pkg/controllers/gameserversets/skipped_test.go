@@ -0,0 +1,99 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestInPlaceUpdateSkipReason(t *testing.T) {
+	now := metav1.Now()
+	updating := map[string]string{util.GameServerInPlaceUpdatingAnnotation: "true"}
+	running := carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning}
+	for _, testCase := range []struct {
+		name   string
+		gs     *carrierv1alpha1.GameServer
+		expect string
+	}{
+		{
+			name:   "before running",
+			gs:     &carrierv1alpha1.GameServer{},
+			expect: "",
+		},
+		{
+			name: "being deleted",
+			gs: &carrierv1alpha1.GameServer{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now},
+			},
+			expect: skipReasonBeingDeleted,
+		},
+		{
+			name: "skip update",
+			gs: &carrierv1alpha1.GameServer{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{util.GameServerSkipUpdateAnnotation: "true"},
+				},
+				Status: running,
+			},
+			expect: "",
+		},
+		{
+			name: "gates not true",
+			gs: &carrierv1alpha1.GameServer{
+				ObjectMeta: metav1.ObjectMeta{Annotations: updating},
+				Spec:       carrierv1alpha1.GameServerSpec{DeletableGates: []string{"carrier.ocgi.dev/no-player"}},
+				Status:     running,
+			},
+			expect: skipReasonGatesNotTrue,
+		},
+		{
+			name: "gates true",
+			gs: &carrierv1alpha1.GameServer{
+				ObjectMeta: metav1.ObjectMeta{Annotations: updating},
+				Spec:       carrierv1alpha1.GameServerSpec{DeletableGates: []string{"carrier.ocgi.dev/no-player"}},
+				Status: carrierv1alpha1.GameServerStatus{
+					State: carrierv1alpha1.GameServerRunning,
+					Conditions: []carrierv1alpha1.GameServerCondition{
+						{Type: "carrier.ocgi.dev/no-player", Status: carrierv1alpha1.ConditionTrue},
+					},
+				},
+			},
+			expect: "",
+		},
+	} {
+		if reason := inPlaceUpdateSkipReason(testCase.gs); reason != testCase.expect {
+			t.Errorf("%v: desired reason: %q, get: %q", testCase.name, testCase.expect, reason)
+		}
+	}
+}
+
+func TestDescribeSkipped(t *testing.T) {
+	skipped := &carrierv1alpha1.InPlaceUpdateSkipped{}
+	countSkipped(skipped, skipReasonBeingDeleted)
+	countSkipped(skipped, skipReasonGatesNotTrue)
+	countSkipped(skipped, skipReasonGatesNotTrue)
+	if total := totalSkipped(skipped); total != 3 {
+		t.Errorf("desired total: 3, get: %v", total)
+	}
+	expect := "Skipped 3 GameServers in place updating, beingDeleted: 1, gatesNotTrue: 2"
+	if message := describeSkipped(skipped); message != expect {
+		t.Errorf("desired message: %q, get: %q", expect, message)
+	}
+}
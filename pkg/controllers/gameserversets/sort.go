@@ -16,52 +16,259 @@
 package gameserversets
 
 import (
+	"fmt"
+	"math"
 	"sort"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
 
 	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
 	"github.com/ocgi/carrier/pkg/util"
 )
 
-// sortGameServersByPodNum sorts the list of GameServers by which GameServers reside on the least full nodes
-func sortGameServersByPodNum(list []*carrierv1alpha1.GameServer, counter *Counter) []*carrierv1alpha1.GameServer {
-	sort.Slice(list, func(i, j int) bool {
-		a := list[i]
-		b := list[j]
-		// not scheduled yet/node deleted, put them first
-		ac, ok := counter.count(a.Status.NodeName)
-		if !ok {
-			return true
-		}
+// State classes of GameServers for scaling down, lower class is scaled down first.
+const (
+	stateClassNotRunning = iota
+	stateClassDeletable
+	stateClassOutOfService
+	stateClassNoNewSessions
+	stateClassIdle
+	stateClassOldTemplate
+	stateClassRunning
+)
+
+// scaleDownCriterion compares two GameServers, returns a negative number if a
+// should be scaled down before b, a positive number if after and 0 if equal.
+type scaleDownCriterion struct {
+	name    string
+	compare func(a, b *carrierv1alpha1.GameServer) int
+}
+
+// scaleDownOrdering is the single comparator chain deciding which GameServers of
+// a GameServerSet are scaled down first:
+//  1. deletion cost, lower first.
+//  2. state class, not running, deletable, out of service, idle longer than max idle
+//     seconds when reaping idle GameServers, running with old template when updating
+//     in place and running.
+//  3. node drain, GameServers on nodes chosen to be emptied by the consolidation controller
+//     first, in the order of the nodes.
+//  4. players, fewer first, GameServers without the players annotation are regarded as full.
+//  5. extended resources, GameServers on nodes with less extended resources like GPU
+//     occupied first for MostAllocated, if the template requests any.
+//  6. node packing, GameServers on nodes with fewer GameServers first for MostAllocated.
+//  7. creation time, older first.
+//
+// GameServers equal in all criteria are ordered by name, so the order is deterministic.
+type scaleDownOrdering struct {
+	gsSet    *carrierv1alpha1.GameServerSet
+	criteria []scaleDownCriterion
+	// reapIdleAt is the time idle GameServers are checked at, zero if they are not
+	// scaled down before other running GameServers.
+	reapIdleAt time.Time
+}
 
-		bc, ok := counter.count(b.Status.NodeName)
-		if !ok {
-			return false
+// newScaleDownOrdering returns the scale down ordering of gsSet, counter is
+// the number of GameServers on each node.
+func newScaleDownOrdering(gsSet *carrierv1alpha1.GameServerSet, counter *Counter) *scaleDownOrdering {
+	o := &scaleDownOrdering{gsSet: gsSet}
+	o.criteria = []scaleDownCriterion{
+		{name: "deletion cost", compare: compareDeletionCost},
+		{name: "state", compare: func(a, b *carrierv1alpha1.GameServer) int {
+			return o.stateClass(a) - o.stateClass(b)
+		}},
+		{name: "node drain", compare: compareNodeDrainRank},
+		{name: "players", compare: comparePlayers},
+	}
+	if gsSet.Spec.Scheduling == carrierv1alpha1.MostAllocated {
+		if names := extendedResourceNames(&gsSet.Spec.Template.Spec.Template.Spec); len(names) != 0 {
+			o.criteria = append(o.criteria, scaleDownCriterion{
+				name: "extended resources",
+				compare: func(a, b *carrierv1alpha1.GameServer) int {
+					return compareExtendedResources(a, b, names, counter)
+				},
+			})
 		}
-		if ac == bc {
-			return a.Name < b.Name
+		o.criteria = append(o.criteria, scaleDownCriterion{
+			name: "node packing",
+			compare: func(a, b *carrierv1alpha1.GameServer) int {
+				return compareNodePacking(a, b, counter)
+			},
+		})
+	}
+	o.criteria = append(o.criteria, scaleDownCriterion{name: "creation time", compare: compareCreationTime})
+	return o
+}
+
+// compare compares a and b by the criteria in order, returns the result
+// and the name of criterion deciding it.
+func (o *scaleDownOrdering) compare(a, b *carrierv1alpha1.GameServer) (int, string) {
+	for _, criterion := range o.criteria {
+		if result := criterion.compare(a, b); result != 0 {
+			return result, criterion.name
 		}
+	}
+	return compareString(a.Name, b.Name), "name"
+}
 
-		return ac < bc
+// sort sorts list in the order of scaling down.
+func (o *scaleDownOrdering) sort(list []*carrierv1alpha1.GameServer) []*carrierv1alpha1.GameServer {
+	sort.Slice(list, func(i, j int) bool {
+		result, _ := o.compare(list[i], list[j])
+		return result < 0
 	})
-
 	return list
 }
 
-// sortGameServersByCost sorts the list of GameServers by which GameServers reside on the game server cost.
-func sortGameServersByCost(list []*carrierv1alpha1.GameServer) []*carrierv1alpha1.GameServer {
-	sort.Slice(list, func(i, j int) bool {
-		costI, err := GetDeletionCostFromGameServerAnnotations(list[i].Annotations)
-		if err != nil {
-			return true
+// reason explains why gs is scaled down before survivor, which is the first GameServer kept.
+func (o *scaleDownOrdering) reason(gs, survivor *carrierv1alpha1.GameServer) string {
+	if survivor == nil {
+		return fmt.Sprintf("scaled down to %v replicas", o.gsSet.Spec.Replicas)
+	}
+	_, criterion := o.compare(gs, survivor)
+	return fmt.Sprintf("scaled down before %v by %v", survivor.Name, criterion)
+}
+
+// stateClass returns the state class of gs, same as the order of classifyGameServers.
+func (o *scaleDownOrdering) stateClass(gs *carrierv1alpha1.GameServer) int {
+	switch {
+	case gameservers.IsStopped(gs), gameservers.IsCompleted(gs):
+		return stateClassDeletable
+	case gameservers.IsBeforeRunning(gs):
+		return stateClassNotRunning
+	case gameservers.IsDeletable(gs):
+		return stateClassDeletable
+	case gameservers.IsOutOfService(gs):
+		return stateClassOutOfService
+	case gameservers.HasEffectiveConstraint(gs, carrierv1alpha1.NoNewSessions):
+		return stateClassNoNewSessions
+	case !o.reapIdleAt.IsZero() && gameservers.IsIdleExpired(gs, o.reapIdleAt):
+		return stateClassIdle
+	}
+	if inPlaceUpdating, _ := IsGameServerSetInPlaceUpdating(o.gsSet); inPlaceUpdating &&
+		gs.Labels[util.GameServerHash] != o.gsSet.Labels[util.GameServerHash] {
+		return stateClassOldTemplate
+	}
+	return stateClassRunning
+}
+
+// isRunning returns true if gs is running and in service.
+func (o *scaleDownOrdering) isRunning(gs *carrierv1alpha1.GameServer) bool {
+	return o.stateClass(gs) >= stateClassIdle
+}
+
+func compareDeletionCost(a, b *carrierv1alpha1.GameServer) int {
+	costA, _ := GetDeletionCostFromGameServerAnnotations(a.Annotations)
+	costB, _ := GetDeletionCostFromGameServerAnnotations(b.Annotations)
+	return compareInt64(costA, costB)
+}
+
+func comparePlayers(a, b *carrierv1alpha1.GameServer) int {
+	return compareInt64(getPlayers(a), getPlayers(b))
+}
+
+func compareNodeDrainRank(a, b *carrierv1alpha1.GameServer) int {
+	return compareInt64(getNodeDrainRank(a), getNodeDrainRank(b))
+}
+
+// getNodeDrainRank returns the drain rank of the node of gs, returns int64 max if not set or the value is invalid.
+func getNodeDrainRank(gs *carrierv1alpha1.GameServer) int64 {
+	rank, err := strconv.ParseInt(gs.Annotations[util.NodeDrainRankAnnotation], 10, 64)
+	if err != nil {
+		return math.MaxInt64
+	}
+	return rank
+}
+
+// getPlayers returns the number of players of gs, returns int64 max if not set or the value is invalid.
+func getPlayers(gs *carrierv1alpha1.GameServer) int64 {
+	players, err := strconv.ParseInt(gs.Annotations[util.GameServerPlayersAnnotation], 10, 64)
+	if err != nil {
+		return math.MaxInt64
+	}
+	return players
+}
+
+// compareNodePacking puts GameServers not scheduled yet or on nodes with fewer GameServers first.
+func compareNodePacking(a, b *carrierv1alpha1.GameServer, counter *Counter) int {
+	countA, okA := counter.count(a.Status.NodeName)
+	countB, okB := counter.count(b.Status.NodeName)
+	switch {
+	case okA == okB:
+		if countA == countB {
+			return 0
+		}
+		if countA < countB {
+			return -1
 		}
-		costJ, err := GetDeletionCostFromGameServerAnnotations(list[j].Annotations)
-		if err != nil {
-			return false
+		return 1
+	case !okA:
+		return -1
+	}
+	return 1
+}
+
+// compareExtendedResources puts GameServers on nodes with less extended resources occupied first,
+// resources are compared in the order of names.
+func compareExtendedResources(a, b *carrierv1alpha1.GameServer, names []corev1.ResourceName,
+	counter *Counter) int {
+	for _, name := range names {
+		quantityA := counter.extendedResource(a.Status.NodeName, name)
+		quantityB := counter.extendedResource(b.Status.NodeName, name)
+		if result := quantityA.Cmp(quantityB); result != 0 {
+			return result
 		}
-		return costI < costJ
+	}
+	return 0
+}
+
+// extendedResourceNames returns the sorted names of extended resources requested by podSpec.
+func extendedResourceNames(podSpec *corev1.PodSpec) []corev1.ResourceName {
+	var names []corev1.ResourceName
+	for name := range util.ExtendedResourceRequests(podSpec) {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
 	})
+	return names
+}
 
-	return list
+// extendedResourceRequests returns the extended resources requested by gs.
+func extendedResourceRequests(gs *carrierv1alpha1.GameServer) corev1.ResourceList {
+	return util.ExtendedResourceRequests(&gs.Spec.Template.Spec)
+}
+
+func compareCreationTime(a, b *carrierv1alpha1.GameServer) int {
+	if a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return 0
+	}
+	if a.CreationTimestamp.Before(&b.CreationTimestamp) {
+		return -1
+	}
+	return 1
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareString(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
 }
 
 // sortGameServersByCreationTime sorts by newest GameServers first, and returns them
@@ -78,19 +285,28 @@ func sortGameServersByCreationTime(list []*carrierv1alpha1.GameServer) []*carrie
 	return list
 }
 
-// sortGameServersByHash sorts by hash. hash same as gss means a newer version.
-func sortGameServersByHash(list []*carrierv1alpha1.GameServer,
-	gameServerSet *carrierv1alpha1.GameServerSet) []*carrierv1alpha1.GameServer {
-	sort.Slice(list, func(i, j int) bool {
-		a := list[i]
-		b := list[j]
-		aMatch := a.Labels[util.GameServerHash] == gameServerSet.Labels[util.GameServerHash]
-		bMatch := b.Labels[util.GameServerHash] == gameServerSet.Labels[util.GameServerHash]
-		if aMatch && bMatch {
-			return a.Name < b.Name
+// spreadGameServersByNode reorders the list by picking GameServers from each node in turn,
+// so GameServers at the head of the list are spread across as many nodes as possible.
+// The order of GameServers on the same node is kept.
+func spreadGameServersByNode(list []*carrierv1alpha1.GameServer) []*carrierv1alpha1.GameServer {
+	var nodes []string
+	nodeGameServers := make(map[string][]*carrierv1alpha1.GameServer)
+	for _, gs := range list {
+		node := gs.Status.NodeName
+		if _, ok := nodeGameServers[node]; !ok {
+			nodes = append(nodes, node)
 		}
-		return !aMatch && bMatch
-	})
-
-	return list
+		nodeGameServers[node] = append(nodeGameServers[node], gs)
+	}
+	spread := make([]*carrierv1alpha1.GameServer, 0, len(list))
+	for len(spread) < len(list) {
+		for _, node := range nodes {
+			if len(nodeGameServers[node]) == 0 {
+				continue
+			}
+			spread = append(spread, nodeGameServers[node][0])
+			nodeGameServers[node] = nodeGameServers[node][1:]
+		}
+	}
+	return spread
 }
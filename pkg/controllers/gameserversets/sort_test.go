@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
@@ -43,7 +45,10 @@ func TestByCount(t *testing.T) {
 	}
 	desiredNames := []string{"test1", "test", "test2"}
 	var actual []string
-	list = sortGameServersByPodNum(list, &counter)
+	gsSet := &carrierv1alpha1.GameServerSet{
+		Spec: carrierv1alpha1.GameServerSetSpec{Scheduling: carrierv1alpha1.MostAllocated},
+	}
+	list = newScaleDownOrdering(gsSet, &counter).sort(list)
 	for _, server := range list {
 		actual = append(actual, server.Name)
 	}
@@ -111,7 +116,7 @@ func TestByCost(t *testing.T) {
 	}
 	desiredNames := []string{"test", "test1"}
 	var actual []string
-	list = sortGameServersByCost(list)
+	list = newScaleDownOrdering(&carrierv1alpha1.GameServerSet{}, nil).sort(list)
 	for _, server := range list {
 		actual = append(actual, server.Name)
 	}
@@ -121,33 +126,188 @@ func TestByCost(t *testing.T) {
 }
 
 func TestByHash(t *testing.T) {
+	running := carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning}
+	spec := carrierv1alpha1.GameServerSpec{DeletableGates: []string{"carrier.ocgi.dev/has-no-player"}}
 	list := []*carrierv1alpha1.GameServer{
 		{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:   "test",
 				Labels: map[string]string{util.GameServerHash: "1"},
 			},
+			Spec:   spec,
+			Status: running,
 		},
 		{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:   "test1",
 				Labels: map[string]string{util.GameServerHash: "2"},
 			},
+			Spec:   spec,
+			Status: running,
 		},
 	}
 	gss := &carrierv1alpha1.GameServerSet{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   "testa",
-			Labels: map[string]string{util.GameServerHash: "1"},
+			Name:        "testa",
+			Labels:      map[string]string{util.GameServerHash: "1"},
+			Annotations: map[string]string{util.GameServerInPlaceUpdateAnnotation: "2"},
 		},
 	}
 	desiredNames := []string{"test1", "test"}
 	var actual []string
-	list = sortGameServersByHash(list, gss)
+	list = newScaleDownOrdering(gss, nil).sort(list)
+	for _, server := range list {
+		actual = append(actual, server.Name)
+	}
+	if !reflect.DeepEqual(desiredNames, actual) {
+		t.Errorf("desired: %v, actual: %v", desiredNames, actual)
+	}
+}
+
+func TestScaleDownOrdering(t *testing.T) {
+	now := metav1.Now()
+	later := metav1.NewTime(now.Add(time.Minute))
+	newGS := func(name, cost, players string, state carrierv1alpha1.GameServerState,
+		created metav1.Time) *carrierv1alpha1.GameServer {
+		gs := &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: created,
+				Annotations:       map[string]string{},
+			},
+			Spec:   carrierv1alpha1.GameServerSpec{DeletableGates: []string{"carrier.ocgi.dev/has-no-player"}},
+			Status: carrierv1alpha1.GameServerStatus{State: state},
+		}
+		if len(cost) != 0 {
+			gs.Annotations[util.GameServerDeletionCost] = cost
+		}
+		if len(players) != 0 {
+			gs.Annotations[util.GameServerPlayersAnnotation] = players
+		}
+		return gs
+	}
+	list := []*carrierv1alpha1.GameServer{
+		newGS("running-later", "", "", carrierv1alpha1.GameServerRunning, later),
+		newGS("running", "", "", carrierv1alpha1.GameServerRunning, now),
+		newGS("running-players", "", "3", carrierv1alpha1.GameServerRunning, later),
+		newGS("starting", "", "", carrierv1alpha1.GameServerStarting, later),
+		newGS("running-cost", "10", "", carrierv1alpha1.GameServerRunning, later),
+	}
+	desiredNames := []string{"running-cost", "starting", "running-players", "running", "running-later"}
+	ordering := newScaleDownOrdering(&carrierv1alpha1.GameServerSet{}, nil)
+	var actual []string
+	for _, server := range ordering.sort(list) {
+		actual = append(actual, server.Name)
+	}
+	if !reflect.DeepEqual(desiredNames, actual) {
+		t.Errorf("desired: %v, actual: %v", desiredNames, actual)
+	}
+	desiredReason := "scaled down before running-later by creation time"
+	if reason := ordering.reason(list[3], list[4]); reason != desiredReason {
+		t.Errorf("desired reason: %v, actual: %v", desiredReason, reason)
+	}
+}
+
+func TestByNodeDrainRank(t *testing.T) {
+	var list []*carrierv1alpha1.GameServer
+	for _, gs := range [][3]string{{"test", "", "0"}, {"test1", "1", "0"}, {"test2", "0", "5"}, {"test3", "invalid", "0"}} {
+		server := &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        gs[0],
+				Annotations: map[string]string{util.GameServerPlayersAnnotation: gs[2]},
+			},
+			Status: carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning},
+		}
+		if len(gs[1]) != 0 {
+			server.Annotations[util.NodeDrainRankAnnotation] = gs[1]
+		}
+		list = append(list, server)
+	}
+	// GameServers on the nodes to empty go first even with more players.
+	desiredNames := []string{"test2", "test1", "test", "test3"}
+	ordering := newScaleDownOrdering(&carrierv1alpha1.GameServerSet{}, nil)
+	var actual []string
+	for _, server := range ordering.sort(list) {
+		actual = append(actual, server.Name)
+	}
+	if !reflect.DeepEqual(desiredNames, actual) {
+		t.Errorf("desired: %v, actual: %v", desiredNames, actual)
+	}
+}
+
+func TestByExtendedResources(t *testing.T) {
+	gpu := corev1.ResourceName("nvidia.com/gpu")
+	podSpec := corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name: util.GameServerContainerName,
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{gpu: resource.MustParse("1")},
+				},
+			},
+		},
+	}
+	gsSet := &carrierv1alpha1.GameServerSet{
+		Spec: carrierv1alpha1.GameServerSetSpec{
+			Scheduling: carrierv1alpha1.MostAllocated,
+			Template: carrierv1alpha1.GameServerTemplateSpec{
+				Spec: carrierv1alpha1.GameServerSpec{
+					Template: corev1.PodTemplateSpec{Spec: podSpec},
+				},
+			},
+		},
+	}
+	var list []*carrierv1alpha1.GameServer
+	for _, gs := range [][2]string{{"test", "node1"}, {"test1", "node2"}, {"test2", "node3"}} {
+		list = append(list, &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: gs[0]},
+			Spec: carrierv1alpha1.GameServerSpec{
+				Template: corev1.PodTemplateSpec{Spec: podSpec},
+			},
+			Status: carrierv1alpha1.GameServerStatus{NodeName: gs[1]},
+		})
+	}
+	// node1 hosts more GameServers, but node2 has more GPUs occupied.
+	counter := &Counter{nodeGameServer: map[string]uint64{"node1": 3, "node2": 2, "node3": 1}, warm: true}
+	counter.addResources("node1", corev1.ResourceList{gpu: resource.MustParse("3")})
+	counter.addResources("node2", corev1.ResourceList{gpu: resource.MustParse("4")})
+	counter.addResources("node3", corev1.ResourceList{gpu: resource.MustParse("1")})
+	counter.subResources("node2", corev1.ResourceList{gpu: resource.MustParse("2")})
+	counter.addResources("node2", corev1.ResourceList{gpu: resource.MustParse("4")})
+	desiredNames := []string{"test2", "test", "test1"}
+	var actual []string
+	for _, server := range newScaleDownOrdering(gsSet, counter).sort(list) {
+		actual = append(actual, server.Name)
+	}
+	if !reflect.DeepEqual(desiredNames, actual) {
+		t.Errorf("desired: %v, actual: %v", desiredNames, actual)
+	}
+}
+
+func TestSpreadByNode(t *testing.T) {
+	var list []*carrierv1alpha1.GameServer
+	for _, gs := range [][2]string{{"a1", "a"}, {"a2", "a"}, {"a3", "a"}, {"b1", "b"}, {"c1", "c"}, {"b2", "b"}} {
+		list = append(list, &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: gs[0]},
+			Status:     carrierv1alpha1.GameServerStatus{NodeName: gs[1]},
+		})
+	}
+	desiredNames := []string{"a1", "b1", "c1", "a2", "b2", "a3"}
+	var actual []string
+	list = spreadGameServersByNode(list)
 	for _, server := range list {
 		actual = append(actual, server.Name)
 	}
 	if !reflect.DeepEqual(desiredNames, actual) {
 		t.Errorf("desired: %v, actual: %v", desiredNames, actual)
 	}
+
+	desiredNames = []string{"a1", "c1"}
+	actual = nil
+	for _, server := range limitGameServersPerNode(list, map[string]int{"b": 1}, 1) {
+		actual = append(actual, server.Name)
+	}
+	if !reflect.DeepEqual(desiredNames, actual) {
+		t.Errorf("desired: %v, actual: %v", desiredNames, actual)
+	}
 }
@@ -0,0 +1,172 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// AnnotationRepair is a control annotation left stale, e.g. by a reconcile failed halfway,
+// and how it is repaired.
+type AnnotationRepair struct {
+	Key string
+	// Value is the value the annotation is repaired to, nil if it is removed.
+	Value  *string
+	Reason string
+}
+
+// String returns the description of repair.
+func (r AnnotationRepair) String() string {
+	if r.Value == nil {
+		return fmt.Sprintf("removed %v (%v)", r.Key, r.Reason)
+	}
+	return fmt.Sprintf("set %v to %q (%v)", r.Key, *r.Value, r.Reason)
+}
+
+// StaleGameServerSetAnnotations returns the repairs of the stale control annotations of gsSet.
+func StaleGameServerSetAnnotations(gsSet *carrierv1alpha1.GameServerSet) []AnnotationRepair {
+	var repairs []AnnotationRepair
+	_, hasThreshold := gsSet.Annotations[util.GameServerInPlaceUpdateAnnotation]
+	inPlaceUpdating, _ := IsGameServerSetInPlaceUpdating(gsSet)
+	if hasThreshold && !inPlaceUpdating {
+		repairs = append(repairs, AnnotationRepair{Key: util.GameServerInPlaceUpdateAnnotation,
+			Reason: "invalid in place update threshold"})
+	}
+	if _, ok := gsSet.Annotations[util.GameServerInPlaceUpdatedReplicasAnnotation]; ok && !inPlaceUpdating {
+		repairs = append(repairs, AnnotationRepair{Key: util.GameServerInPlaceUpdatedReplicasAnnotation,
+			Reason: "no in place update in progress"})
+	}
+	return repairs
+}
+
+// StaleGameServerAnnotations returns the repairs of the stale control annotations of gs owned by gsSet.
+// A GameServer left in place updating is released, as it has been updated to the template, or the
+// in place update of gsSet is over. The annotation is kept, as it tells the GameServer was ever
+// in place updated. The NotInService constraint added for the update is removed by the patch of
+// GameServerRepairPatch as well.
+func StaleGameServerAnnotations(gsSet *carrierv1alpha1.GameServerSet,
+	gs *carrierv1alpha1.GameServer) []AnnotationRepair {
+	if gs.DeletionTimestamp != nil || !gameservers.IsInPlaceUpdating(gs) {
+		return nil
+	}
+	var reason string
+	if inPlaceUpdating, _ := IsGameServerSetInPlaceUpdating(gsSet); !inPlaceUpdating {
+		reason = "no in place update in progress"
+	} else if gs.Labels[util.GameServerHash] == gsSet.Labels[util.GameServerHash] {
+		reason = "already updated to the template"
+	} else {
+		return nil
+	}
+	released := "false"
+	return []AnnotationRepair{{Key: util.GameServerInPlaceUpdatingAnnotation, Value: &released, Reason: reason}}
+}
+
+// AnnotationRepairPatch returns the merge patch applying repairs.
+func AnnotationRepairPatch(repairs []AnnotationRepair) []byte {
+	annotations := make(map[string]*string, len(repairs))
+	for _, repair := range repairs {
+		annotations[repair.Key] = repair.Value
+	}
+	patch, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	return patch
+}
+
+// GameServerRepairPatch returns the merge patch applying repairs to gs. Releasing in place updating
+// also removes the NotInService constraint added for the update, same as a finished in place update,
+// otherwise the GameServer is never in service again. The patch is conditioned on the resourceVersion
+// of gs, as the constraints are replaced as a whole.
+func GameServerRepairPatch(gs *carrierv1alpha1.GameServer, repairs []AnnotationRepair) []byte {
+	annotations := make(map[string]*string, len(repairs))
+	releasing := false
+	for _, repair := range repairs {
+		annotations[repair.Key] = repair.Value
+		if repair.Key == util.GameServerInPlaceUpdatingAnnotation {
+			releasing = true
+		}
+	}
+	metadata := map[string]interface{}{"annotations": annotations}
+	patch := map[string]interface{}{"metadata": metadata}
+	constraints := make([]carrierv1alpha1.Constraint, 0, len(gs.Spec.Constraints))
+	for _, constraint := range gs.Spec.Constraints {
+		if constraint.Type != carrierv1alpha1.NotInService {
+			constraints = append(constraints, constraint)
+		}
+	}
+	if releasing && len(constraints) != len(gs.Spec.Constraints) {
+		patch["spec"] = map[string]interface{}{"constraints": constraints}
+		metadata["resourceVersion"] = gs.ResourceVersion
+	}
+	data, _ := json.Marshal(patch)
+	return data
+}
+
+// describeRepairs returns the description of repairs.
+func describeRepairs(repairs []AnnotationRepair) string {
+	descriptions := make([]string, 0, len(repairs))
+	for _, repair := range repairs {
+		descriptions = append(descriptions, repair.String())
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// repairStaleAnnotations cleans up the stale control annotations of gsSet and its GameServers,
+// the repairs are reported by events.
+func (c *Controller) repairStaleAnnotations(gsSet *carrierv1alpha1.GameServerSet,
+	list []*carrierv1alpha1.GameServer) (*carrierv1alpha1.GameServerSet, error) {
+	for _, gs := range list {
+		repairs := StaleGameServerAnnotations(gsSet, gs)
+		if len(repairs) == 0 {
+			continue
+		}
+		_, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Patch(gs.Name, types.MergePatchType,
+			GameServerRepairPatch(gs, repairs))
+		if k8serrors.IsNotFound(err) || k8serrors.IsConflict(err) {
+			// GameServer changed since listed is repaired in the next sync.
+			continue
+		}
+		if err != nil {
+			return gsSet, errors.Wrapf(err, "error repairing annotations of GameServer %v/%v", gs.Namespace, gs.Name)
+		}
+		klog.Infof("Repaired stale annotations of GameServer %v/%v: %v", gs.Namespace, gs.Name, describeRepairs(repairs))
+		c.recorder.Event(gs, corev1.EventTypeNormal, "StaleAnnotationsRepaired", describeRepairs(repairs))
+	}
+	repairs := StaleGameServerSetAnnotations(gsSet)
+	if len(repairs) == 0 {
+		return gsSet, nil
+	}
+	updated, err := c.carrierClient.CarrierV1alpha1().GameServerSets(gsSet.Namespace).Patch(gsSet.Name,
+		types.MergePatchType, AnnotationRepairPatch(repairs))
+	if err != nil {
+		return gsSet, errors.Wrapf(err, "error repairing annotations of GameServerSet %v", gsSet.Name)
+	}
+	klog.Infof("Repaired stale annotations of GameServerSet %v/%v: %v", gsSet.Namespace, gsSet.Name,
+		describeRepairs(repairs))
+	c.recorder.Event(gsSet, corev1.EventTypeNormal, "StaleAnnotationsRepaired", describeRepairs(repairs))
+	return updated, nil
+}
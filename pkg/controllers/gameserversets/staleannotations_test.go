@@ -0,0 +1,127 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"encoding/json"
+	"testing"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestStaleGameServerSetAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    []string
+	}{
+		{
+			name:        "scaling is not repaired",
+			annotations: map[string]string{util.ScalingReplicasAnnotation: "true"},
+		},
+		{
+			name: "in place updating",
+			annotations: map[string]string{util.GameServerInPlaceUpdateAnnotation: "2",
+				util.GameServerInPlaceUpdatedReplicasAnnotation: "1"},
+		},
+		{
+			name:        "updated replicas without in place update",
+			annotations: map[string]string{util.GameServerInPlaceUpdatedReplicasAnnotation: "1"},
+			expected:    []string{util.GameServerInPlaceUpdatedReplicasAnnotation},
+		},
+		{
+			name: "invalid threshold",
+			annotations: map[string]string{util.GameServerInPlaceUpdateAnnotation: "x",
+				util.GameServerInPlaceUpdatedReplicasAnnotation: "1"},
+			expected: []string{util.GameServerInPlaceUpdateAnnotation, util.GameServerInPlaceUpdatedReplicasAnnotation},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gsSet := gss()
+			gsSet.Annotations = test.annotations
+			repairs := StaleGameServerSetAnnotations(gsSet)
+			if len(repairs) != len(test.expected) {
+				t.Fatalf("desired repairs of %v, get: %v", test.expected, repairs)
+			}
+			for i, repair := range repairs {
+				if repair.Key != test.expected[i] || repair.Value != nil {
+					t.Errorf("desired %v removed, get: %v", test.expected[i], repair)
+				}
+			}
+		})
+	}
+}
+
+func TestStaleGameServerAnnotations(t *testing.T) {
+	updating := map[string]string{util.GameServerInPlaceUpdatingAnnotation: "true"}
+	tests := []struct {
+		name        string
+		gsSetAnns   map[string]string
+		gsHash      string
+		annotations map[string]string
+		stale       bool
+	}{
+		{name: "not updating", gsSetAnns: nil, gsHash: "old",
+			annotations: map[string]string{util.GameServerInPlaceUpdatingAnnotation: "false"}},
+		{name: "in place update over", gsSetAnns: nil, gsHash: "old", annotations: updating, stale: true},
+		{name: "being updated", gsSetAnns: map[string]string{util.GameServerInPlaceUpdateAnnotation: "1"},
+			gsHash: "old", annotations: updating},
+		{name: "already updated", gsSetAnns: map[string]string{util.GameServerInPlaceUpdateAnnotation: "1"},
+			gsHash: "new", annotations: updating, stale: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gsSet := gss()
+			gsSet.Labels = map[string]string{util.GameServerHash: "new"}
+			gsSet.Annotations = test.gsSetAnns
+			gs := BuildGameServer(gsSet)
+			gs.Labels[util.GameServerHash] = test.gsHash
+			gs.Annotations = test.annotations
+			gs.ResourceVersion = "7"
+			gs.Spec.Constraints = []carrierv1alpha1.Constraint{gameservers.NotInServiceConstraint(),
+				{Type: carrierv1alpha1.NoNewSessions}}
+			repairs := StaleGameServerAnnotations(gsSet, gs)
+			if !test.stale {
+				if len(repairs) != 0 {
+					t.Errorf("desired no repair, get: %v", repairs)
+				}
+				return
+			}
+			if len(repairs) != 1 || repairs[0].Value == nil || *repairs[0].Value != "false" {
+				t.Fatalf("desired in place updating released, get: %v", repairs)
+			}
+			var patch struct {
+				Metadata struct {
+					Annotations     map[string]string `json:"annotations"`
+					ResourceVersion string            `json:"resourceVersion"`
+				} `json:"metadata"`
+				Spec struct {
+					Constraints []carrierv1alpha1.Constraint `json:"constraints"`
+				} `json:"spec"`
+			}
+			if err := json.Unmarshal(GameServerRepairPatch(gs, repairs), &patch); err != nil ||
+				patch.Metadata.Annotations[util.GameServerInPlaceUpdatingAnnotation] != "false" {
+				t.Errorf("desired patch releasing in place updating, get: %+v, %v", patch, err)
+			}
+			if len(patch.Spec.Constraints) != 1 || patch.Spec.Constraints[0].Type != carrierv1alpha1.NoNewSessions ||
+				patch.Metadata.ResourceVersion != "7" {
+				t.Errorf("desired NotInService constraint removed at resource version 7, get: %+v", patch)
+			}
+		})
+	}
+}
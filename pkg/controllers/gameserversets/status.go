@@ -0,0 +1,99 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/util/kube"
+)
+
+// statusWriter coalesces the status mutations of a GameServerSet during a sync, and
+// writes them once with a merge patch of the status subresource, which only contains
+// the fields changed. Lists like conditions are replaced as a whole by merge patches, so
+// the patch is conditioned on the resourceVersion observed, and conflicts with concurrent
+// writes fail the sync to be retried instead of overwriting them.
+type statusWriter struct {
+	client versioned.Interface
+	// observed is the GameServerSet whose status was last read or written.
+	observed *carrierv1alpha1.GameServerSet
+	// pending is the status to be written.
+	pending carrierv1alpha1.GameServerSetStatus
+}
+
+// newStatusWriter returns a statusWriter for the GameServerSet.
+func newStatusWriter(client versioned.Interface, gsSet *carrierv1alpha1.GameServerSet) *statusWriter {
+	return &statusWriter{
+		client:   client,
+		observed: gsSet,
+		pending:  *gsSet.Status.DeepCopy(),
+	}
+}
+
+// mutate applies f to the pending status.
+func (w *statusWriter) mutate(f func(status *carrierv1alpha1.GameServerSetStatus)) {
+	f(&w.pending)
+}
+
+// setCondition sets the condition of the pending status.
+func (w *statusWriter) setCondition(conditionType carrierv1alpha1.GameServerSetConditionType,
+	status corev1.ConditionStatus, reason, message string) {
+	w.mutate(func(s *carrierv1alpha1.GameServerSetStatus) {
+		setGameServerSetCondition(s, conditionType, status, reason, message)
+	})
+}
+
+// changed checks if the pending status differs from the observed one.
+func (w *statusWriter) changed() bool {
+	return !reflect.DeepEqual(w.observed.Status, w.pending)
+}
+
+// flush writes the pending status if it is changed, and returns the GameServerSet
+// with the latest status.
+func (w *statusWriter) flush() (*carrierv1alpha1.GameServerSet, error) {
+	if !w.changed() {
+		return w.observed, nil
+	}
+	patch, err := createStatusPatch(w.observed, w.pending)
+	if err != nil {
+		return w.observed, errors.Wrapf(err, "error creating status patch of GameServerSet %s", w.observed.Name)
+	}
+	klog.V(4).Infof("Patch status of GameServerSet %v/%v: %s", w.observed.Namespace, w.observed.Name, patch)
+	updated, err := w.client.CarrierV1alpha1().GameServerSets(w.observed.Namespace).
+		Patch(w.observed.Name, types.MergePatchType, patch, "status")
+	if err != nil {
+		return w.observed, errors.Wrapf(err, "error updating status on GameServerSet %s", w.observed.Name)
+	}
+	w.observed = updated
+	w.pending = *updated.Status.DeepCopy()
+	return updated, nil
+}
+
+// createStatusPatch returns the merge patch changing the status of gsSet to status, which
+// fails with a conflict if gsSet is changed since its resourceVersion.
+func createStatusPatch(gsSet *carrierv1alpha1.GameServerSet,
+	status carrierv1alpha1.GameServerSetStatus) ([]byte, error) {
+	original := &carrierv1alpha1.GameServerSet{Status: gsSet.Status}
+	modified := &carrierv1alpha1.GameServerSet{Status: status}
+	modified.ResourceVersion = gsSet.ResourceVersion
+	return kube.CreateMergePatch(original, modified)
+}
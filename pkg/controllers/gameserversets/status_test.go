@@ -0,0 +1,131 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	gsfake "github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+)
+
+func TestStatusWriterFlush(t *testing.T) {
+	for _, testCase := range []struct {
+		name          string
+		mutate        func(w *statusWriter)
+		expectPatches int
+		expectStatus  carrierv1alpha1.GameServerSetStatus
+	}{
+		{
+			name:          "nothing changed",
+			mutate:        func(w *statusWriter) {},
+			expectPatches: 0,
+			expectStatus:  gss().Status,
+		},
+		{
+			name: "condition unchanged",
+			mutate: func(w *statusWriter) {
+				w.setCondition(carrierv1alpha1.GameServerSetTemplateFaulty, corev1.ConditionFalse, "", "")
+			},
+			expectPatches: 0,
+			expectStatus:  gss().Status,
+		},
+		{
+			name: "several mutations coalesced",
+			mutate: func(w *statusWriter) {
+				w.setCondition(carrierv1alpha1.GameServerSetTemplateFaulty, corev1.ConditionTrue, "OOMKilled", "")
+				w.mutate(func(s *carrierv1alpha1.GameServerSetStatus) {
+					s.Replicas = 2
+				})
+				w.mutate(func(s *carrierv1alpha1.GameServerSetStatus) {
+					s.ObservedGeneration = 3
+				})
+			},
+			expectPatches: 1,
+			expectStatus: carrierv1alpha1.GameServerSetStatus{
+				Replicas:           2,
+				ReadyReplicas:      1,
+				ObservedGeneration: 3,
+				Conditions: []carrierv1alpha1.GameServerSetCondition{
+					{
+						Type:   carrierv1alpha1.GameServerSetTemplateFaulty,
+						Status: corev1.ConditionTrue,
+						Reason: "OOMKilled",
+					},
+				},
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := gsfake.NewSimpleClientset(gss())
+			w := newStatusWriter(client, gss())
+			testCase.mutate(w)
+			updated, err := w.flush()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// flushing again writes nothing.
+			if _, err = w.flush(); err != nil {
+				t.Fatal(err)
+			}
+			patches := 0
+			for _, action := range client.Actions() {
+				if _, ok := action.(k8stesting.PatchAction); ok {
+					patches++
+					continue
+				}
+				t.Errorf("desired only patches, get: %v %v", action.GetVerb(), action.GetSubresource())
+			}
+			if patches != testCase.expectPatches {
+				t.Errorf("desired patches: %v, get: %v", testCase.expectPatches, patches)
+			}
+			for i := range updated.Status.Conditions {
+				updated.Status.Conditions[i].LastTransitionTime = testCase.expectStatus.Conditions[i].LastTransitionTime
+			}
+			if !reflect.DeepEqual(updated.Status, testCase.expectStatus) {
+				t.Errorf("desired status: %+v, get: %+v", testCase.expectStatus, updated.Status)
+			}
+		})
+	}
+}
+
+func TestCreateStatusPatch(t *testing.T) {
+	gsSet := gss()
+	status := *gsSet.Status.DeepCopy()
+	status.ReadyReplicas = 0
+	status.Selector = "a=b"
+	patch, err := createStatusPatch(gsSet, status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"status":{"readyReplicas":0,"selector":"a=b"}}`
+	if string(patch) != expect {
+		t.Errorf("desired patch: %v, get: %v", expect, string(patch))
+	}
+
+	gsSet.ResourceVersion = "42"
+	patch, err = createStatusPatch(gsSet, status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = `{"metadata":{"resourceVersion":"42"},"status":{"readyReplicas":0,"selector":"a=b"}}`
+	if string(patch) != expect {
+		t.Errorf("desired patch conditioned on resourceVersion: %v, get: %v", expect, string(patch))
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/audit"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/util/hash"
+	"github.com/ocgi/carrier/pkg/version"
+)
+
+var (
+	// defaultMaxUnavailable is the max unavailable of rolling update if not specified.
+	defaultMaxUnavailable = intstr.FromString("25%")
+	// defaultInplaceThreshold is the threshold of in place update if not specified.
+	defaultInplaceThreshold = intstr.FromString("100%")
+)
+
+// updateStrategy returns the update strategy honored for GameServerSet, nil if it is
+// controlled by a Squad or has no update strategy.
+func updateStrategy(gsSet *carrierv1alpha1.GameServerSet) *carrierv1alpha1.GameServerSetUpdateStrategy {
+	if ref := metav1.GetControllerOf(gsSet); ref != nil && ref.Kind == "Squad" {
+		return nil
+	}
+	return gsSet.Spec.UpdateStrategy
+}
+
+// syncUpdateStrategy updates the GameServers of GameServerSet managed directly by its update
+// strategy. The template hash of GameServerSet is tracked first, so GameServers of the old
+// template could be told apart.
+func (c *Controller) syncUpdateStrategy(gsSet *carrierv1alpha1.GameServerSet,
+	list []*carrierv1alpha1.GameServer) (*carrierv1alpha1.GameServerSet, error) {
+	strategy := updateStrategy(gsSet)
+	if strategy == nil {
+		return gsSet, nil
+	}
+	if setTemplateHash(gsSet, strategy) {
+		updated, err := c.carrierClient.CarrierV1alpha1().GameServerSets(gsSet.Namespace).Update(gsSet)
+		if err != nil {
+			return gsSet, errors.Wrapf(err, "error updating template hash of GameServerSet %s", gsSet.Name)
+		}
+		gsSet = updated
+	}
+	list, err := c.stampTemplateHash(gsSet, list)
+	if err != nil {
+		return gsSet, err
+	}
+	if err = c.migrateTemplateHash(gsSet, list); err != nil {
+		return gsSet, err
+	}
+	budget := replaceBudget(gsSet, strategy, list)
+	if budget <= 0 {
+		return gsSet, nil
+	}
+	toReplace := oldGameServersToReplace(gsSet, list, budget, c.templateSpecHash(gsSet))
+	if len(toReplace) == 0 {
+		return gsSet, nil
+	}
+	klog.Infof("Replacing %v old GameServers of GameServerSet %v by %v", len(toReplace), gsSet.Name, strategy.Type)
+	deletables, _, runnings := classifyGameServers(toReplace, false)
+	reason := fmt.Sprintf("%v to template %v", strategy.Type, gsSet.Labels[util.GameServerHash])
+	deleted, err := c.deleteGameServers(gsSet, deletables)
+	auditGameServers(audit.OperationDelete, gsSet, len(deletables), deleted, reason, err)
+	if err != nil {
+		return gsSet, err
+	}
+	marked, err := c.markGameServersOutOfService(gsSet, runnings, nil)
+	auditGameServers(audit.OperationScaleDown, gsSet, len(runnings), marked, reason, err)
+	return gsSet, err
+}
+
+// setTemplateHash sets the template hash of GameServerSet, and the in place update annotations
+// for the template if needed. It returns true if GameServerSet is changed.
+func setTemplateHash(gsSet *carrierv1alpha1.GameServerSet, strategy *carrierv1alpha1.GameServerSetUpdateStrategy) bool {
+	podSpecHash := hash.PodSpecHash(&gsSet.Spec.Template.Spec.Template.Spec)
+	if gsSet.Labels[util.GameServerHash] == podSpecHash {
+		return false
+	}
+	if gsSet.Labels == nil {
+		gsSet.Labels = make(map[string]string)
+	}
+	if gsSet.Annotations == nil {
+		gsSet.Annotations = make(map[string]string)
+	}
+	if previous := gsSet.Annotations[util.ControllerVersionAnnotation]; len(gsSet.Labels[util.GameServerHash]) != 0 &&
+		previous != version.Version {
+		klog.Infof("Template hash of GameServerSet %v is recomputed by controller %v, which was computed by %v",
+			gsSet.Name, version.Version, previous)
+	}
+	gsSet.Annotations[util.ControllerVersionAnnotation] = version.Version
+	gsSet.Labels[util.GameServerHash] = podSpecHash
+	if gsSet.Spec.Template.Labels == nil {
+		gsSet.Spec.Template.Labels = make(map[string]string)
+	}
+	gsSet.Spec.Template.Labels[util.GameServerHash] = podSpecHash
+	delete(gsSet.Annotations, util.GameServerInPlaceUpdatedReplicasAnnotation)
+	if strategy.Type != carrierv1alpha1.InplaceUpdateGameServerSetStrategyType {
+		delete(gsSet.Annotations, util.GameServerInPlaceUpdateAnnotation)
+		return true
+	}
+	threshold := defaultInplaceThreshold
+	if strategy.Threshold != nil {
+		threshold = *strategy.Threshold
+	}
+	desired, _ := intstr.GetValueFromIntOrPercent(&threshold, int(gsSet.Spec.Replicas), true)
+	gsSet.Annotations[util.GameServerInPlaceUpdateAnnotation] = strconv.Itoa(desired)
+	gsSet.Annotations[util.GameServerInPlaceUpdatedReplicasAnnotation] = "0"
+	return true
+}
+
+// stampTemplateHash labels GameServers without template hash, which were created before the
+// update strategy of gsSet was enabled, with the template hash of gsSet if their spec is of the
+// current template, so enabling the update strategy does not replace GameServers already up to
+// date. It returns list with the GameServers stamped.
+func (c *Controller) stampTemplateHash(gsSet *carrierv1alpha1.GameServerSet,
+	list []*carrierv1alpha1.GameServer) ([]*carrierv1alpha1.GameServer, error) {
+	templateHash := gsSet.Labels[util.GameServerHash]
+	var specHash string
+	stamped := make([]*carrierv1alpha1.GameServer, 0, len(list))
+	for _, gs := range list {
+		if gs.DeletionTimestamp != nil || len(gs.Labels[util.GameServerHash]) != 0 {
+			stamped = append(stamped, gs)
+			continue
+		}
+		if len(specHash) == 0 {
+			specHash = hash.GameServerSpecHash(&BuildGameServer(gsSet).Spec)
+		}
+		if hash.GameServerSpecHash(&gs.Spec) != specHash {
+			stamped = append(stamped, gs)
+			continue
+		}
+		patch, _ := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"labels": map[string]string{util.GameServerHash: templateHash},
+			},
+		})
+		klog.V(4).Infof("Stamp template hash %v on GameServer %v/%v", templateHash, gs.Namespace, gs.Name)
+		updated, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Patch(gs.Name,
+			types.MergePatchType, patch)
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return list, errors.Wrapf(err, "error stamping template hash of GameServer %v/%v", gs.Namespace, gs.Name)
+		}
+		stamped = append(stamped, updated)
+	}
+	return stamped, nil
+}
+
+// replaceBudget returns the number of old GameServers could be replaced now.
+func replaceBudget(gsSet *carrierv1alpha1.GameServerSet, strategy *carrierv1alpha1.GameServerSetUpdateStrategy,
+	list []*carrierv1alpha1.GameServer) int {
+	switch strategy.Type {
+	case carrierv1alpha1.RecreateGameServerSetStrategyType:
+		return len(list)
+	case carrierv1alpha1.RollingUpdateGameServerSetStrategyType:
+	default:
+		return 0
+	}
+	maxUnavailable := defaultMaxUnavailable
+	if strategy.MaxUnavailable != nil {
+		maxUnavailable = *strategy.MaxUnavailable
+	}
+	budget, _ := intstr.GetValueFromIntOrPercent(&maxUnavailable, int(gsSet.Spec.Replicas), false)
+	if budget < 1 {
+		budget = 1
+	}
+	for _, gs := range list {
+		if gs.DeletionTimestamp != nil || gs.Status.State != carrierv1alpha1.GameServerRunning ||
+			gameservers.IsOutOfService(gs) {
+			budget--
+		}
+	}
+	return budget
+}
+
+// oldGameServersToReplace returns at most budget GameServers of the old templates, which are
+// not being replaced yet. GameServers whose template hash is only stale, told by specHash, are
+// not replaced.
+func oldGameServersToReplace(gsSet *carrierv1alpha1.GameServerSet, list []*carrierv1alpha1.GameServer,
+	budget int, specHash string) []*carrierv1alpha1.GameServer {
+	var old []*carrierv1alpha1.GameServer
+	for _, gs := range list {
+		if gs.DeletionTimestamp != nil || gameservers.IsOutOfService(gs) || isOfTemplate(gsSet, gs, specHash) {
+			continue
+		}
+		old = append(old, gs)
+	}
+	// GameServers not running are replaced first.
+	old = append(filterGameServers(old, gameservers.IsBeforeRunning, true),
+		filterGameServers(old, gameservers.IsBeforeRunning, false)...)
+	if len(old) > budget {
+		old = old[:budget]
+	}
+	return old
+}
+
+// filterGameServers returns GameServers whose predicate is expected.
+func filterGameServers(list []*carrierv1alpha1.GameServer, predicate func(*carrierv1alpha1.GameServer) bool,
+	expected bool) []*carrierv1alpha1.GameServer {
+	var filtered []*carrierv1alpha1.GameServer
+	for _, gs := range list {
+		if predicate(gs) == expected {
+			filtered = append(filtered, gs)
+		}
+	}
+	return filtered
+}
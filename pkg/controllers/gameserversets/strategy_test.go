@@ -0,0 +1,181 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	gsfake "github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestUpdateStrategy(t *testing.T) {
+	strategy := &carrierv1alpha1.GameServerSetUpdateStrategy{Type: carrierv1alpha1.RecreateGameServerSetStrategyType}
+	gsSet := &carrierv1alpha1.GameServerSet{Spec: carrierv1alpha1.GameServerSetSpec{UpdateStrategy: strategy}}
+	if updateStrategy(gsSet) != strategy {
+		t.Errorf("desired strategy honored for GameServerSet managed directly")
+	}
+	squad := &carrierv1alpha1.Squad{ObjectMeta: metav1.ObjectMeta{Name: "squad"}}
+	gsSet.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(squad, carrierv1alpha1.SchemeGroupVersion.WithKind("Squad")),
+	}
+	if updateStrategy(gsSet) != nil {
+		t.Errorf("desired strategy ignored for GameServerSet controlled by Squad")
+	}
+}
+
+func TestSetTemplateHash(t *testing.T) {
+	threshold := intstr.FromString("50%")
+	strategy := &carrierv1alpha1.GameServerSetUpdateStrategy{
+		Type:      carrierv1alpha1.InplaceUpdateGameServerSetStrategyType,
+		Threshold: &threshold,
+	}
+	gsSet := withReplicas(4, gss())
+	if !setTemplateHash(gsSet, strategy) {
+		t.Fatalf("desired template hash set")
+	}
+	templateHash := gsSet.Labels[util.GameServerHash]
+	if len(templateHash) == 0 || gsSet.Spec.Template.Labels[util.GameServerHash] != templateHash {
+		t.Errorf("desired template hash in labels of GameServerSet and template, get: %v, %v",
+			gsSet.Labels, gsSet.Spec.Template.Labels)
+	}
+	if gsSet.Annotations[util.GameServerInPlaceUpdateAnnotation] != "2" {
+		t.Errorf("desired in place update threshold 2, get: %v",
+			gsSet.Annotations[util.GameServerInPlaceUpdateAnnotation])
+	}
+	if setTemplateHash(gsSet, strategy) {
+		t.Errorf("desired unchanged if template is not changed")
+	}
+}
+
+func TestReplaceBudget(t *testing.T) {
+	maxUnavailable := intstr.FromInt(2)
+	gsSet := withReplicas(4, gss())
+	running := func(name string) *carrierv1alpha1.GameServer {
+		return &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning},
+		}
+	}
+	starting := running("starting")
+	starting.Status.State = carrierv1alpha1.GameServerStarting
+	tests := []struct {
+		name     string
+		strategy carrierv1alpha1.GameServerSetUpdateStrategy
+		list     []*carrierv1alpha1.GameServer
+		budget   int
+	}{
+		{
+			name:     "on delete",
+			strategy: carrierv1alpha1.GameServerSetUpdateStrategy{Type: carrierv1alpha1.OnDeleteGameServerSetStrategyType},
+			list:     []*carrierv1alpha1.GameServer{running("a"), running("b")},
+			budget:   0,
+		},
+		{
+			name:     "recreate",
+			strategy: carrierv1alpha1.GameServerSetUpdateStrategy{Type: carrierv1alpha1.RecreateGameServerSetStrategyType},
+			list:     []*carrierv1alpha1.GameServer{running("a"), running("b")},
+			budget:   2,
+		},
+		{
+			name: "rolling update",
+			strategy: carrierv1alpha1.GameServerSetUpdateStrategy{
+				Type:           carrierv1alpha1.RollingUpdateGameServerSetStrategyType,
+				MaxUnavailable: &maxUnavailable,
+			},
+			list:   []*carrierv1alpha1.GameServer{running("a"), running("b")},
+			budget: 2,
+		},
+		{
+			name: "rolling update with unavailable",
+			strategy: carrierv1alpha1.GameServerSetUpdateStrategy{
+				Type:           carrierv1alpha1.RollingUpdateGameServerSetStrategyType,
+				MaxUnavailable: &maxUnavailable,
+			},
+			list:   []*carrierv1alpha1.GameServer{running("a"), starting},
+			budget: 1,
+		},
+		{
+			name:     "rolling update by default",
+			strategy: carrierv1alpha1.GameServerSetUpdateStrategy{Type: carrierv1alpha1.RollingUpdateGameServerSetStrategyType},
+			list:     []*carrierv1alpha1.GameServer{running("a")},
+			budget:   1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if budget := replaceBudget(gsSet, &test.strategy, test.list); budget != test.budget {
+				t.Errorf("desired budget %v, get: %v", test.budget, budget)
+			}
+		})
+	}
+}
+
+func TestOldGameServersToReplace(t *testing.T) {
+	gsSet := gss()
+	gsSet.Labels = map[string]string{util.GameServerHash: "new"}
+	gsSet.Spec.Template.Spec.Template.Spec.Containers = []corev1.Container{{Name: "server", Image: "server:v2"}}
+	newGS := func(name, templateHash string, state carrierv1alpha1.GameServerState) *carrierv1alpha1.GameServer {
+		return &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{util.GameServerHash: templateHash}},
+			Status:     carrierv1alpha1.GameServerStatus{State: state},
+		}
+	}
+	list := []*carrierv1alpha1.GameServer{
+		newGS("old-running", "old", carrierv1alpha1.GameServerRunning),
+		newGS("new-running", "new", carrierv1alpha1.GameServerRunning),
+		newGS("old-starting", "old", carrierv1alpha1.GameServerStarting),
+	}
+	toReplace := oldGameServersToReplace(gsSet, list, 1, "")
+	if len(toReplace) != 1 || toReplace[0].Name != "old-starting" {
+		t.Errorf("desired old starting GameServer replaced first, get: %v", toReplace)
+	}
+	if toReplace = oldGameServersToReplace(gsSet, list, 3, ""); len(toReplace) != 2 {
+		t.Errorf("desired only old GameServers replaced, get: %v", toReplace)
+	}
+}
+
+func TestStampTemplateHash(t *testing.T) {
+	gsSet := gss()
+	gsSet.Spec.Template.Spec.Template.Spec.Containers = []corev1.Container{{Name: "server", Image: "server:v1"}}
+	strategy := &carrierv1alpha1.GameServerSetUpdateStrategy{Type: carrierv1alpha1.RecreateGameServerSetStrategyType}
+	untracked := func(name, image string) *carrierv1alpha1.GameServer {
+		gs := BuildGameServer(gsSet)
+		gs.Name = name
+		gs.Spec.Template.Spec.Containers[0].Image = image
+		return gs
+	}
+	current, old := untracked("current", "server:v1"), untracked("old", "server:v0")
+	client := gsfake.NewSimpleClientset(current, old)
+	c := &Controller{carrierClient: client}
+	// the update strategy is enabled on GameServerSet whose GameServers have no template hash.
+	setTemplateHash(gsSet, strategy)
+	list, err := c.stampTemplateHash(gsSet, []*carrierv1alpha1.GameServer{current, old})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Labels[util.GameServerHash] != gsSet.Labels[util.GameServerHash] {
+		t.Fatalf("desired GameServer of current template stamped, get: %v", list)
+	}
+	toReplace := oldGameServersToReplace(gsSet, list, 2, "")
+	if len(toReplace) != 1 || toReplace[0].Name != "old" {
+		t.Errorf("desired only GameServer of old template replaced, get: %v", toReplace)
+	}
+}
@@ -16,17 +16,20 @@ package gameserversets
 
 import (
 	"fmt"
-	"math"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
 
 	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
-	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
 	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/version"
 )
 
 // BuildGameServer build a GameServerFrom GameServerSet
@@ -41,7 +44,13 @@ func BuildGameServer(gsSet *carrierv1alpha1.GameServerSet) *carrierv1alpha1.Game
 		Spec: *gsSet.Spec.Template.Spec.DeepCopy(),
 	}
 
+	applyGameServerMetadata(gsSet, gs)
 	gs.Spec.Scheduling = gsSet.Spec.Scheduling
+	gs.Spec.Colocation = gsSet.Spec.Colocation
+	if gsSet.Spec.LogShipping != nil {
+		gs.Spec.LogShipping = gsSet.Spec.LogShipping.DeepCopy()
+	}
+	AddImagePullSecrets(&gs.Spec.Template.Spec, gsSet.Spec.ImagePullSecrets)
 	ref := metav1.NewControllerRef(gsSet, carrierv1alpha1.SchemeGroupVersion.WithKind("GameServerSet"))
 	gs.OwnerReferences = []metav1.OwnerReference{*ref}
 
@@ -50,12 +59,30 @@ func BuildGameServer(gsSet *carrierv1alpha1.GameServerSet) *carrierv1alpha1.Game
 	}
 	gs.Labels[util.GameServerSetLabelKey] = gsSet.Name
 	gs.Labels[util.SquadNameLabelKey] = gsSet.Labels[util.SquadNameLabelKey]
+	gameservers.SetGameVersion(gs, gs.Spec.GameVersion)
 	if gs.Annotations == nil {
 		gs.Annotations = make(map[string]string)
 	}
+	gs.Annotations[util.ControllerVersionAnnotation] = version.Version
 	return gs
 }
 
+// AddImagePullSecrets adds secrets not referenced by podSpec yet to its imagePullSecrets.
+func AddImagePullSecrets(podSpec *corev1.PodSpec, secrets []corev1.LocalObjectReference) {
+	for _, secret := range secrets {
+		found := false
+		for _, existing := range podSpec.ImagePullSecrets {
+			if existing.Name == secret.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets, secret)
+		}
+	}
+}
+
 // IsGameServerSetScaling check if the GameServerSet is scaling GameServer.
 func IsGameServerSetScaling(gsSet *carrierv1alpha1.GameServerSet) bool {
 	for _, condition := range gsSet.Status.Conditions {
@@ -82,23 +109,64 @@ func IsGameServerSetInPlaceUpdating(gsSet *carrierv1alpha1.GameServerSet) (bool,
 	return false, 0
 }
 
-// GetDeletionCostFromGameServerAnnotations returns the integer value of gs-deletion-cost. Returns int64 max
-// if not set or the value is invalid.
-func GetDeletionCostFromGameServerAnnotations(annotations map[string]string) (int64, error) {
-	if value, exist := annotations[util.GameServerDeletionCost]; exist {
-		// values that start with plus sign (e.g, "+10") or leading zeros (e.g., "008") are not valid.
-		if !validFirstDigit(value) {
-			return 0, fmt.Errorf("invalid value %q", value)
+// GetInPlaceUpdateMaxPerNode returns the max number of GameServers on one node can be updated
+// in place at the same time. Returns 0 if not set or the value is invalid, which means no limit.
+func GetInPlaceUpdateMaxPerNode(gsSet *carrierv1alpha1.GameServerSet) int {
+	val, ok := gsSet.Annotations[util.GameServerInPlaceUpdateMaxPerNodeAnnotation]
+	if !ok {
+		return 0
+	}
+	number, err := strconv.Atoi(val)
+	if err != nil || number < 0 {
+		return 0
+	}
+	return number
+}
+
+// limitGameServersPerNode keeps at most maxPerNode GameServers on each node, GameServers
+// counted by inFlight are taken into account. No limit if maxPerNode is 0.
+// GameServers not scheduled yet are not limited.
+func limitGameServersPerNode(list []*carrierv1alpha1.GameServer, inFlight map[string]int,
+	maxPerNode int) []*carrierv1alpha1.GameServer {
+	if maxPerNode <= 0 {
+		return list
+	}
+	counts := make(map[string]int, len(inFlight))
+	for node, count := range inFlight {
+		counts[node] = count
+	}
+	var limited []*carrierv1alpha1.GameServer
+	for _, gs := range list {
+		node := gs.Status.NodeName
+		if len(node) != 0 && counts[node] >= maxPerNode {
+			continue
 		}
+		counts[node]++
+		limited = append(limited, gs)
+	}
+	return limited
+}
 
-		i, err := strconv.ParseInt(value, 10, 64)
-		if err != nil {
-			// make sure we default to int64 max on error.
-			return int64(math.MaxInt64), err
+// countInPlaceUpdatingByNode counts GameServers on each node which are updated
+// but not running yet, they are regarded as still being updated.
+func countInPlaceUpdatingByNode(list []*carrierv1alpha1.GameServer) map[string]int {
+	counts := make(map[string]int)
+	for _, gs := range list {
+		if len(gs.Status.NodeName) == 0 || gs.Status.State == carrierv1alpha1.GameServerRunning {
+			continue
 		}
-		return i, nil
+		counts[gs.Status.NodeName]++
+	}
+	return counts
+}
+
+// GetDeletionCostFromGameServerAnnotations returns the integer value of gs-deletion-cost. Returns int64 max
+// if not set or the value is invalid, the error is returned for invalid value so that it could be surfaced.
+func GetDeletionCostFromGameServerAnnotations(annotations map[string]string) (int64, error) {
+	if value, exist := annotations[util.GameServerDeletionCost]; exist {
+		return util.ParseDeletionCost(value)
 	}
-	return int64(math.MaxInt64), nil
+	return util.DefaultDeletionCost, nil
 }
 
 // GetGameServerSetInplaceUpdateStatus get the current number of updated replicas
@@ -117,30 +185,54 @@ func GetGameServerSetInplaceUpdateStatus(gsSet *carrierv1alpha1.GameServerSet) i
 	return int32(replicas)
 }
 
-func validFirstDigit(str string) bool {
-	if len(str) == 0 {
-		return false
-	}
-	return str[0] == '-' || (str[0] == '0' && str == "0") || (str[0] >= '1' && str[0] <= '9')
-}
-
 // ListGameServersByGameServerSetOwner lists the GameServers for a given GameServerSet
-func ListGameServersByGameServerSetOwner(gameServerLister listerv1.GameServerLister,
+// from the owner index of GameServers.
+func ListGameServersByGameServerSetOwner(indexer cache.Indexer,
 	gsSet *carrierv1alpha1.GameServerSet) ([]*carrierv1alpha1.GameServer, error) {
 	labelSelector := labels.Set{util.GameServerSetLabelKey: gsSet.Name}
 	if gsSet.Spec.Selector != nil && len(gsSet.Spec.Selector.MatchLabels) != 0 {
 		labelSelector = gsSet.Spec.Selector.MatchLabels
 	}
-	list, err := gameServerLister.List(labels.SelectorFromSet(labelSelector))
+	list, err := gameservers.ListGameServersByIndex(indexer, gameservers.GameServerOwnerIndex, string(gsSet.UID))
 	if err != nil {
 		return list, errors.Wrapf(err, "error listing GameServers for GameServerSet %s", gsSet.ObjectMeta.Name)
 	}
+	selector := labels.SelectorFromSet(labelSelector)
 	var result []*carrierv1alpha1.GameServer
 	for _, gs := range list {
-		if metav1.IsControlledBy(gs, gsSet) {
+		if selector.Matches(labels.Set(gs.Labels)) {
 			result = append(result, gs)
 		}
 	}
 
 	return result, nil
 }
+
+// containerDiff returns a compact summary of changes from container to the
+// given image and resources, e.g. "image: server:v1 -> server:v2".
+// An empty string is returned if nothing changed.
+func containerDiff(container corev1.Container, image string, resources corev1.ResourceRequirements) string {
+	var diffs []string
+	if container.Image != image {
+		diffs = append(diffs, fmt.Sprintf("image: %v -> %v", container.Image, image))
+	}
+	oldRequests, requests := formatResourceList(container.Resources.Requests), formatResourceList(resources.Requests)
+	if oldRequests != requests {
+		diffs = append(diffs, fmt.Sprintf("requests: {%v} -> {%v}", oldRequests, requests))
+	}
+	oldLimits, limits := formatResourceList(container.Resources.Limits), formatResourceList(resources.Limits)
+	if oldLimits != limits {
+		diffs = append(diffs, fmt.Sprintf("limits: {%v} -> {%v}", oldLimits, limits))
+	}
+	return strings.Join(diffs, "; ")
+}
+
+// formatResourceList formats resources as "cpu=1,memory=1Gi" sorted by name.
+func formatResourceList(resources corev1.ResourceList) string {
+	var items []string
+	for name, quantity := range resources {
+		items = append(items, fmt.Sprintf("%v=%v", name, quantity.String()))
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/metrics"
+)
+
+// warmUp recovers the node packing counts and replica counts from the lister before the queue
+// is processed, otherwise the first scale down after a restart orders GameServers by partial counts.
+func (c *Controller) warmUp() error {
+	start := time.Now()
+	list, err := c.gameServerLister.List(labels.Everything())
+	if err != nil {
+		return errors.Wrap(err, "error listing GameServers")
+	}
+	c.counter.rebuild(list)
+	c.replicas.rebuild(list)
+	klog.Infof("Recovered node packing and replica counts of %v GameServers in %v", len(list), time.Since(start))
+	metrics.RecordControllerReady("gameserverset")
+	return nil
+}
+
+// rebuild recomputes the counts from list and marks the counter warm, the
+// counts before are dropped.
+func (c *Counter) rebuild(list []*carrierv1alpha1.GameServer) {
+	nodeGameServer := make(map[string]uint64)
+	nodeResources := make(map[string]corev1.ResourceList)
+	for _, gs := range list {
+		node := gs.Status.NodeName
+		if gs.DeletionTimestamp != nil || len(node) == 0 {
+			continue
+		}
+		nodeGameServer[node]++
+		requests := extendedResourceRequests(gs)
+		if len(requests) == 0 {
+			continue
+		}
+		resources, ok := nodeResources[node]
+		if !ok {
+			resources = corev1.ResourceList{}
+			nodeResources[node] = resources
+		}
+		for name, quantity := range requests {
+			current := resources[name]
+			current.Add(quantity)
+			resources[name] = current
+		}
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.nodeGameServer = nodeGameServer
+	c.nodeResources = nodeResources
+	c.warm = true
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestCounterRebuild(t *testing.T) {
+	gpu := corev1.ResourceName("nvidia.com/gpu")
+	newGameServer := func(node string, gpus int64) *carrierv1alpha1.GameServer {
+		gs := &carrierv1alpha1.GameServer{Status: carrierv1alpha1.GameServerStatus{NodeName: node}}
+		gs.Spec.Template.Spec.Containers = []corev1.Container{{Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{gpu: *resource.NewQuantity(gpus, resource.DecimalSI)}}}}
+		return gs
+	}
+	deleting := newGameServer("node1", 1)
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+
+	counter := &Counter{nodeGameServer: map[string]uint64{}}
+	counter.inc("node3")
+	if _, ok := counter.count("node3"); ok {
+		t.Errorf("desired events ignored before warm, get counted")
+	}
+	counter.rebuild([]*carrierv1alpha1.GameServer{
+		newGameServer("node1", 1), newGameServer("node1", 2), newGameServer("node2", 1),
+		newGameServer("", 1), deleting,
+	})
+	for node, expect := range map[string]uint64{"node1": 2, "node2": 1} {
+		if count, _ := counter.count(node); count != expect {
+			t.Errorf("%v: desired count %v, get: %v", node, expect, count)
+		}
+	}
+	if quantity := counter.extendedResource("node1", gpu); quantity.Value() != 3 {
+		t.Errorf("desired 3 gpus on node1, get: %v", quantity.String())
+	}
+	counter.inc("node3")
+	if count, _ := counter.count("node3"); count != 1 {
+		t.Errorf("desired events counted after warm, get: %v", count)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gc
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+)
+
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=list;watch;delete
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameserversets,verbs=list;watch
+
+// collectPeriod is the period finished GameServers are checked.
+const collectPeriod = time.Minute
+
+// Controller deletes GameServers which are Exited or Failed after a TTL. The TTL is
+// the TTLSecondsAfterFinished of the owner GameServerSet, or the default TTL for
+// GameServers not owned by a GameServerSet. GameServers owned by a GameServerSet
+// without TTLSecondsAfterFinished are deleted by the GameServerSet immediately.
+type Controller struct {
+	carrierClient       versioned.Interface
+	gameServerLister    listerv1.GameServerLister
+	gameServerSynced    cache.InformerSynced
+	gameServerSetLister listerv1.GameServerSetLister
+	gameServerSetSynced cache.InformerSynced
+	// defaultTTL is the TTL of GameServers not owned by a GameServerSet, disabled if not positive.
+	defaultTTL time.Duration
+	// retain is the number of finished GameServers kept for each owner for debugging.
+	retain int
+}
+
+// NewController returns a new finished GameServer garbage collector.
+func NewController(
+	carrierClient versioned.Interface,
+	carrierInformerFactory externalversions.SharedInformerFactory,
+	defaultTTL time.Duration,
+	retain int) *Controller {
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	gameServerSets := carrierInformerFactory.Carrier().V1alpha1().GameServerSets()
+	return &Controller{
+		carrierClient:       carrierClient,
+		gameServerLister:    gameServers.Lister(),
+		gameServerSynced:    gameServers.Informer().HasSynced,
+		gameServerSetLister: gameServerSets.Lister(),
+		gameServerSetSynced: gameServerSets.Informer().HasSynced,
+		defaultTTL:          defaultTTL,
+		retain:              retain,
+	}
+}
+
+// Run collects finished GameServers periodically. Will block until stop is closed.
+func (c *Controller) Run(workers int, stop <-chan struct{}) error {
+	klog.Info("Wait for cache sync")
+	if !cache.WaitForCacheSync(stop, c.gameServerSynced, c.gameServerSetSynced) {
+		return errors.New("failed to wait for caches to sync")
+	}
+	wait.Until(c.collect, collectPeriod, stop)
+	return nil
+}
+
+// collect deletes the expired finished GameServers.
+func (c *Controller) collect() {
+	list, err := c.gameServerLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing GameServers"))
+		return
+	}
+	now := time.Now()
+	for owner, finished := range groupFinishedGameServers(list) {
+		for _, gs := range c.expired(owner, finished, now) {
+			err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Delete(gs.Name, &metav1.DeleteOptions{})
+			if err != nil && !k8serrors.IsNotFound(err) {
+				utilruntime.HandleError(errors.Wrapf(err, "error deleting GameServer %v/%v", gs.Namespace, gs.Name))
+				continue
+			}
+			klog.Infof("Deleted finished GameServer %v/%v", gs.Namespace, gs.Name)
+		}
+	}
+}
+
+// expired returns the finished GameServers of owner which exceed the TTL,
+// the latest finished ones are retained.
+func (c *Controller) expired(owner ownerKey, finished []*carrierv1alpha1.GameServer,
+	now time.Time) []*carrierv1alpha1.GameServer {
+	ttl := c.defaultTTL
+	if len(owner.name) != 0 {
+		gsSet, err := c.gameServerSetLister.GameServerSets(owner.namespace).Get(owner.name)
+		if err != nil {
+			if !k8serrors.IsNotFound(err) {
+				utilruntime.HandleError(errors.Wrapf(err, "error retrieving GameServerSet %v", owner))
+			}
+			return nil
+		}
+		if gsSet.Spec.TTLSecondsAfterFinished == nil {
+			return nil
+		}
+		ttl = time.Duration(*gsSet.Spec.TTLSecondsAfterFinished) * time.Second
+	} else if ttl <= 0 {
+		return nil
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finishedTime(finished[i]).After(finishedTime(finished[j]))
+	})
+	var expired []*carrierv1alpha1.GameServer
+	for i, gs := range finished {
+		if i < c.retain {
+			continue
+		}
+		if finishedTime(gs).Add(ttl).Before(now) {
+			expired = append(expired, gs)
+		}
+	}
+	return expired
+}
+
+// ownerKey identifies the owner GameServerSet of GameServers, name is empty if not owned.
+type ownerKey struct {
+	namespace string
+	name      string
+}
+
+// groupFinishedGameServers groups the finished GameServers by owner.
+func groupFinishedGameServers(list []*carrierv1alpha1.GameServer) map[ownerKey][]*carrierv1alpha1.GameServer {
+	groups := make(map[ownerKey][]*carrierv1alpha1.GameServer)
+	for _, gs := range list {
+		if gs.DeletionTimestamp != nil || !gameservers.IsStopped(gs) {
+			continue
+		}
+		key := ownerKey{namespace: gs.Namespace}
+		if ref := metav1.GetControllerOf(gs); ref != nil && ref.Kind == "GameServerSet" {
+			key.name = ref.Name
+		}
+		groups[key] = append(groups[key], gs)
+	}
+	return groups
+}
+
+// finishedTime returns the time GameServer finished, the creation time is
+// used for GameServers finished before the finished time is recorded.
+func finishedTime(gs *carrierv1alpha1.GameServer) time.Time {
+	if gs.Status.FinishedTime != nil {
+		return gs.Status.FinishedTime.Time
+	}
+	return gs.CreationTimestamp.Time
+}
@@ -0,0 +1,96 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+)
+
+func finishedGameServer(name, owner string, finished time.Time) *carrierv1alpha1.GameServer {
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: metav1.NamespaceDefault,
+		},
+		Status: carrierv1alpha1.GameServerStatus{
+			State:        carrierv1alpha1.GameServerExited,
+			FinishedTime: &metav1.Time{Time: finished},
+		},
+	}
+	if len(owner) != 0 {
+		gsSet := &carrierv1alpha1.GameServerSet{ObjectMeta: metav1.ObjectMeta{Name: owner}}
+		gs.OwnerReferences = []metav1.OwnerReference{
+			*metav1.NewControllerRef(gsSet, carrierv1alpha1.SchemeGroupVersion.WithKind("GameServerSet")),
+		}
+	}
+	return gs
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Now()
+	ttl := int32(60)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(&carrierv1alpha1.GameServerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ttl", Namespace: metav1.NamespaceDefault},
+		Spec:       carrierv1alpha1.GameServerSetSpec{TTLSecondsAfterFinished: &ttl},
+	})
+	indexer.Add(&carrierv1alpha1.GameServerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "no-ttl", Namespace: metav1.NamespaceDefault},
+	})
+	c := &Controller{
+		gameServerSetLister: listerv1.NewGameServerSetLister(indexer),
+		defaultTTL:          time.Hour,
+		retain:              1,
+	}
+	running := finishedGameServer("running", "ttl", now.Add(-time.Hour))
+	running.Status.State = carrierv1alpha1.GameServerRunning
+	list := []*carrierv1alpha1.GameServer{
+		running,
+		finishedGameServer("ttl-1", "ttl", now.Add(-3*time.Minute)),
+		finishedGameServer("ttl-2", "ttl", now.Add(-2*time.Minute)),
+		finishedGameServer("ttl-3", "ttl", now.Add(-30*time.Second)),
+		finishedGameServer("ttl-4", "ttl", now.Add(-10*time.Second)),
+		finishedGameServer("no-ttl-1", "no-ttl", now.Add(-time.Hour)),
+		finishedGameServer("no-owner-1", "", now.Add(-2*time.Hour)),
+		finishedGameServer("no-owner-2", "", now.Add(-3*time.Hour)),
+		finishedGameServer("no-owner-3", "", now.Add(-time.Minute)),
+	}
+	desired := map[string][]string{
+		"ttl":    {"ttl-2", "ttl-1"},
+		"no-ttl": nil,
+		"":       {"no-owner-1", "no-owner-2"},
+	}
+	groups := groupFinishedGameServers(list)
+	if len(groups) != len(desired) {
+		t.Fatalf("desired %v groups, get: %v", len(desired), len(groups))
+	}
+	for owner, finished := range groups {
+		var names []string
+		for _, gs := range c.expired(owner, finished, now) {
+			names = append(names, gs.Name)
+		}
+		if !reflect.DeepEqual(names, desired[owner.name]) {
+			t.Errorf("owner %v, desired expired: %v, get: %v", owner.name, desired[owner.name], names)
+		}
+	}
+}
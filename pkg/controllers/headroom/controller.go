@@ -0,0 +1,284 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headroom
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/controllers/squad"
+	"github.com/ocgi/carrier/pkg/metrics"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=squads,verbs=list;watch
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=squads/status,verbs=update
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch
+
+// Config describes how headroom is forecast.
+type Config struct {
+	// Interval is the period headroom is forecast.
+	Interval time.Duration
+	// Window is how far back allocations are averaged into the allocation rate.
+	Window time.Duration
+	// Horizon is the projected time to exhaustion under which a Squad has low headroom.
+	Horizon time.Duration
+}
+
+// Validate checks if the config is valid.
+func (c *Config) Validate() error {
+	if c.Interval <= 0 {
+		return errors.Errorf("interval %v must be positive", c.Interval)
+	}
+	if c.Window < c.Interval {
+		return errors.Errorf("window %v must not be shorter than interval %v", c.Window, c.Interval)
+	}
+	if c.Horizon <= 0 {
+		return errors.Errorf("horizon %v must be positive", c.Horizon)
+	}
+	return nil
+}
+
+// squadKey is the namespace and name of a Squad.
+type squadKey struct {
+	namespace string
+	name      string
+}
+
+// allocationSample is the number of GameServers allocated between two observations.
+type allocationSample struct {
+	from        time.Time
+	to          time.Time
+	allocations int32
+}
+
+// history is the allocations observed of a Squad.
+type history struct {
+	// allocated are the names of GameServers allocated at the last observation.
+	allocated map[string]bool
+	// observed is the time of the last observation.
+	observed time.Time
+	// samples are the allocations between observations within the window.
+	samples []allocationSample
+}
+
+// observe records the GameServers allocated at now, a GameServer not allocated at the last
+// observation is counted as a new allocation. Samples ended before the window are dropped.
+func (h *history) observe(allocated map[string]bool, now time.Time, window time.Duration) {
+	if h.allocated != nil {
+		var count int32
+		for name := range allocated {
+			if !h.allocated[name] {
+				count++
+			}
+		}
+		h.samples = append(h.samples, allocationSample{from: h.observed, to: now, allocations: count})
+	}
+	h.allocated = allocated
+	h.observed = now
+	start := now.Add(-window)
+	i := 0
+	for i < len(h.samples) && !h.samples[i].to.After(start) {
+		i++
+	}
+	h.samples = h.samples[i:]
+}
+
+// perMinute returns the allocations per minute over the samples, ok is false if nothing
+// is sampled yet.
+func (h *history) perMinute() (rate float64, ok bool) {
+	if len(h.samples) == 0 {
+		return 0, false
+	}
+	var total int32
+	for _, sample := range h.samples {
+		total += sample.allocations
+	}
+	elapsed := h.samples[len(h.samples)-1].to.Sub(h.samples[0].from)
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return float64(total) / elapsed.Minutes(), true
+}
+
+// Controller forecasts the headroom of Squads periodically. A GameServer is allocated once it
+// reports players, and the ready GameServers accepting new sessions without players are the
+// headroom of its Squad, which lasts for headroom / allocation rate. The LowHeadroom condition
+// of a Squad is True while that is shorter than the horizon.
+type Controller struct {
+	carrierClient    versioned.Interface
+	squadLister      listerv1.SquadLister
+	squadSynced      cache.InformerSynced
+	gameServerLister listerv1.GameServerLister
+	gameServerSynced cache.InformerSynced
+	recorder         record.EventRecorder
+	config           Config
+	// histories are the allocations observed of each Squad.
+	histories map[squadKey]*history
+}
+
+// NewController returns a new headroom controller, config must be validated.
+func NewController(
+	kubeClient kubernetes.Interface,
+	carrierClient versioned.Interface,
+	carrierInformerFactory externalversions.SharedInformerFactory,
+	config Config) *Controller {
+	squads := carrierInformerFactory.Carrier().V1alpha1().Squads()
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	c := &Controller{
+		carrierClient:    carrierClient,
+		squadLister:      squads.Lister(),
+		squadSynced:      squads.Informer().HasSynced,
+		gameServerLister: gameServers.Lister(),
+		gameServerSynced: gameServers.Informer().HasSynced,
+		config:           config,
+		histories:        make(map[squadKey]*history),
+	}
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	c.recorder = eventBroadcaster.NewRecorder(scheme.Scheme,
+		corev1.EventSource{Component: "headroom-controller"})
+	return c
+}
+
+// Run forecasts headroom periodically. Will block until stop is closed.
+func (c *Controller) Run(workers int, stop <-chan struct{}) error {
+	if !cache.WaitForCacheSync(stop, c.squadSynced, c.gameServerSynced) {
+		return errors.New("failed to wait for caches to sync")
+	}
+	wait.Until(c.forecast, c.config.Interval, stop)
+	return nil
+}
+
+// forecast observes the allocations and forecasts the headroom of all Squads once.
+func (c *Controller) forecast() {
+	squads, err := c.squadLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing Squads"))
+		return
+	}
+	list, err := c.gameServerLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing GameServers"))
+		return
+	}
+	ready := make(map[squadKey]int32)
+	allocated := make(map[squadKey]map[string]bool)
+	for _, gs := range list {
+		name, ok := gs.Labels[util.SquadNameLabelKey]
+		if !ok {
+			continue
+		}
+		if _, ok := gs.Labels[util.PreflightLabelKey]; ok {
+			continue
+		}
+		key := squadKey{namespace: gs.Namespace, name: name}
+		switch {
+		case gameservers.IsAllocated(gs):
+			if allocated[key] == nil {
+				allocated[key] = make(map[string]bool)
+			}
+			allocated[key][gs.Name] = true
+		case isAvailable(gs):
+			ready[key]++
+		}
+	}
+	now := time.Now()
+	current := make(map[squadKey]*history, len(squads))
+	for _, sqd := range squads {
+		key := squadKey{namespace: sqd.Namespace, name: sqd.Name}
+		h, ok := c.histories[key]
+		if !ok {
+			h = &history{}
+		}
+		h.observe(allocated[key], now, c.config.Window)
+		current[key] = h
+		rate, ok := h.perMinute()
+		seconds := headroomSeconds(ready[key], rate)
+		metrics.RecordSquadHeadroom(sqd.Namespace, sqd.Name, ready[key], rate, seconds)
+		if !ok {
+			continue
+		}
+		if err := c.setCondition(sqd, ready[key], rate, seconds); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}
+	for key := range c.histories {
+		if _, ok := current[key]; !ok {
+			metrics.DeleteSquadHeadroom(key.namespace, key.name)
+		}
+	}
+	c.histories = current
+}
+
+// setCondition sets the LowHeadroom condition of sqd if its status or reason changes.
+func (c *Controller) setCondition(sqd *carrierv1alpha1.Squad, ready int32, rate, seconds float64) error {
+	condition := squad.NewSquadCondition(carrierv1alpha1.SquadLowHeadroom, corev1.ConditionFalse,
+		util.SufficientHeadroomReason, fmt.Sprintf("%d ready GameServers last beyond %v", ready, c.config.Horizon))
+	if seconds < c.config.Horizon.Seconds() {
+		condition = squad.NewSquadCondition(carrierv1alpha1.SquadLowHeadroom, corev1.ConditionTrue,
+			util.LowHeadroomReason, fmt.Sprintf("%d ready GameServers are projected to be exhausted in %v "+
+				"at %.1f allocations per minute", ready, (time.Duration(seconds)*time.Second).Round(time.Second), rate))
+	}
+	existing := squad.GetSquadCondition(sqd.Status, carrierv1alpha1.SquadLowHeadroom)
+	if existing != nil && existing.Status == condition.Status && existing.Reason == condition.Reason {
+		return nil
+	}
+	sqdCopy := sqd.DeepCopy()
+	squad.SetSquadCondition(&sqdCopy.Status, *condition)
+	if _, err := c.carrierClient.CarrierV1alpha1().Squads(sqd.Namespace).UpdateStatus(sqdCopy); err != nil {
+		return errors.Wrapf(err, "error updating LowHeadroom condition of Squad %v/%v", sqd.Namespace, sqd.Name)
+	}
+	if condition.Status == corev1.ConditionTrue {
+		c.recorder.Event(sqd, corev1.EventTypeWarning, condition.Reason, condition.Message)
+	}
+	return nil
+}
+
+// headroomSeconds returns the seconds till ready GameServers are exhausted at rate allocations
+// per minute, +Inf if nothing is allocated.
+func headroomSeconds(ready int32, rate float64) float64 {
+	if rate <= 0 {
+		return math.Inf(1)
+	}
+	return float64(ready) / rate * 60
+}
+
+// isAvailable returns true if gs is ready and could be allocated to new sessions.
+func isAvailable(gs *carrierv1alpha1.GameServer) bool {
+	return !gameservers.IsBeingDeleted(gs) && gs.Status.State == carrierv1alpha1.GameServerRunning &&
+		!gameservers.IsDeletableWithGates(gs) && gameservers.IsReady(gs) && gameservers.AcceptsNewSessions(gs)
+}
@@ -0,0 +1,120 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headroom
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestHistory(t *testing.T) {
+	start := time.Now()
+	window := 5 * time.Minute
+	h := &history{}
+	h.observe(map[string]bool{"gs1": true}, start, window)
+	if _, ok := h.perMinute(); ok {
+		t.Errorf("desired no rate before the second observation")
+	}
+	// gs2 and gs3 allocated, gs1 finished
+	h.observe(map[string]bool{"gs2": true, "gs3": true}, start.Add(time.Minute), window)
+	if rate, ok := h.perMinute(); !ok || rate != 2 {
+		t.Errorf("desired 2 allocations per minute, get %v, %v", rate, ok)
+	}
+	h.observe(map[string]bool{"gs2": true, "gs3": true}, start.Add(2*time.Minute), window)
+	if rate, _ := h.perMinute(); rate != 1 {
+		t.Errorf("desired 1 allocation per minute, get %v", rate)
+	}
+	// the samples before are ended before the window
+	h.observe(nil, start.Add(7*time.Minute), window)
+	if len(h.samples) != 1 {
+		t.Errorf("desired 1 sample within the window, get %v", len(h.samples))
+	}
+	if rate, _ := h.perMinute(); rate != 0 {
+		t.Errorf("desired no allocations, get %v", rate)
+	}
+}
+
+func TestHeadroomSeconds(t *testing.T) {
+	if seconds := headroomSeconds(10, 0); !math.IsInf(seconds, 1) {
+		t.Errorf("desired +Inf without allocations, get %v", seconds)
+	}
+	if seconds := headroomSeconds(10, 5); seconds != 120 {
+		t.Errorf("desired 120 seconds, get %v", seconds)
+	}
+	if seconds := headroomSeconds(0, 5); seconds != 0 {
+		t.Errorf("desired 0 seconds, get %v", seconds)
+	}
+}
+
+func TestAllocatedAndAvailable(t *testing.T) {
+	effective := true
+	newGameServer := func(players string, constraints ...carrierv1alpha1.Constraint) *carrierv1alpha1.GameServer {
+		gs := &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}},
+			Spec:       carrierv1alpha1.GameServerSpec{Constraints: constraints},
+			Status:     carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning},
+		}
+		if len(players) != 0 {
+			gs.Annotations[util.GameServerPlayersAnnotation] = players
+		}
+		return gs
+	}
+	tests := []struct {
+		name      string
+		gs        *carrierv1alpha1.GameServer
+		allocated bool
+		available bool
+	}{
+		{name: "players not reported", gs: newGameServer(""), available: true},
+		{name: "no players", gs: newGameServer("0"), available: true},
+		{name: "players", gs: newGameServer("3"), allocated: true, available: true},
+		{name: "no new sessions", available: false, gs: newGameServer("0",
+			carrierv1alpha1.Constraint{Type: carrierv1alpha1.NoNewSessions, Effective: &effective})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := gameservers.IsAllocated(test.gs); got != test.allocated {
+				t.Errorf("desired allocated %v, get %v", test.allocated, got)
+			}
+			if got := isAvailable(test.gs); got != test.available {
+				t.Errorf("desired available %v, get %v", test.available, got)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		config Config
+		valid  bool
+	}{
+		{config: Config{Interval: time.Minute, Window: 10 * time.Minute, Horizon: 5 * time.Minute}, valid: true},
+		{config: Config{Window: 10 * time.Minute, Horizon: 5 * time.Minute}},
+		{config: Config{Interval: time.Minute, Window: time.Second, Horizon: 5 * time.Minute}},
+		{config: Config{Interval: time.Minute, Window: 10 * time.Minute}},
+	}
+	for _, test := range tests {
+		if err := test.config.Validate(); (err == nil) != test.valid {
+			t.Errorf("config %+v desired valid %v, get %v", test.config, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/util/kube"
+)
+
+// NewGameServerInformer returns a function building the GameServer informer of carrier
+// informer factory, GameServers are transformed before caching.
+func NewGameServerInformer(
+	transform kube.TransformFunc) func(versioned.Interface, time.Duration) cache.SharedIndexInformer {
+	return func(client versioned.Interface, resync time.Duration) cache.SharedIndexInformer {
+		lw := &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.CarrierV1alpha1().GameServers(metav1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.CarrierV1alpha1().GameServers(metav1.NamespaceAll).Watch(options)
+			},
+		}
+		return cache.NewSharedIndexInformer(kube.NewTransformingListWatch(lw, transform),
+			&carrierv1alpha1.GameServer{}, resync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	}
+}
+
+// NewGameServerSetInformer returns a function building the GameServerSet informer of carrier
+// informer factory, GameServerSets are transformed before caching.
+func NewGameServerSetInformer(
+	transform kube.TransformFunc) func(versioned.Interface, time.Duration) cache.SharedIndexInformer {
+	return func(client versioned.Interface, resync time.Duration) cache.SharedIndexInformer {
+		lw := &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.CarrierV1alpha1().GameServerSets(metav1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.CarrierV1alpha1().GameServerSets(metav1.NamespaceAll).Watch(options)
+			},
+		}
+		return cache.NewSharedIndexInformer(kube.NewTransformingListWatch(lw, transform),
+			&carrierv1alpha1.GameServerSet{}, resync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	}
+}
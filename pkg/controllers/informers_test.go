@@ -0,0 +1,127 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/util/kube"
+)
+
+// benchmarkObjects is the number of objects cached in each benchmark iteration.
+const benchmarkObjects = 10000
+
+// newBenchmarkGameServer returns a GameServer similar to the ones created by GameServerSets,
+// managed by the controller, the SDK and the kubelet status updates.
+func newBenchmarkGameServer(i int) *carrierv1alpha1.GameServer {
+	fields := `{"f:metadata":{"f:annotations":{},"f:labels":{}},"f:spec":{"f:ports":{},"f:template":{}},` +
+		`"f:status":{"f:address":{},"f:conditions":{},"f:nodeName":{},"f:state":{}}}`
+	var managedFields []metav1.ManagedFieldsEntry
+	for _, manager := range []string{"carrier-controller", "carrier-sdk", "kubectl"} {
+		managedFields = append(managedFields, metav1.ManagedFieldsEntry{
+			Manager:    manager,
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			APIVersion: carrierv1alpha1.SchemeGroupVersion.String(),
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+		})
+	}
+	return &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("squad-abcde-%d", i),
+			Namespace: "default",
+			Labels: map[string]string{
+				util.SquadNameLabelKey:     "squad",
+				util.GameServerSetLabelKey: "squad-abcde",
+				util.GameServerHash:        "abcde",
+			},
+			Annotations:   map[string]string{util.GameServerPlayersAnnotation: "10"},
+			ManagedFields: managedFields,
+		},
+		Spec: carrierv1alpha1.GameServerSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:    util.GameServerContainerName,
+							Image:   "registry.example.com/game/server:v1.0.0",
+							Command: []string{"/server", "--config", strings.Repeat("x", 64)},
+						},
+					},
+				},
+			},
+		},
+		Status: carrierv1alpha1.GameServerStatus{
+			State:    carrierv1alpha1.GameServerRunning,
+			Address:  "10.0.0.1",
+			NodeName: "node-1",
+		},
+	}
+}
+
+// benchmarkCacheMemory reports the heap used by caching benchmarkObjects GameServers
+// transformed by transform, run with `go test -bench CacheMemory -run ^$ ./pkg/controllers`.
+func benchmarkCacheMemory(b *testing.B, transform kube.TransformFunc) {
+	var stats runtime.MemStats
+	var total uint64
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		before := stats.HeapAlloc
+		b.StartTimer()
+
+		store := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		for i := 0; i < benchmarkObjects; i++ {
+			// objects are decoded from the apiserver and transformed before they are stored.
+			gs := newBenchmarkGameServer(i)
+			if transform != nil {
+				transform(gs)
+			}
+			if err := store.Add(gs); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc > before {
+			total += stats.HeapAlloc - before
+		}
+		runtime.KeepAlive(store)
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(total)/float64(b.N), "bytes/10k-objects")
+}
+
+func BenchmarkCacheMemory(b *testing.B) {
+	b.Run("GameServer", func(b *testing.B) {
+		benchmarkCacheMemory(b, nil)
+	})
+	b.Run("GameServerStripManagedFields", func(b *testing.B) {
+		benchmarkCacheMemory(b, kube.StripManagedFields)
+	})
+}
@@ -0,0 +1,296 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodecapacity
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// Config describes the nodes annotated and the period.
+type Config struct {
+	// NodeSelector selects the game nodes annotated, including those running no GameServers.
+	// Only the nodes running GameServers are annotated if empty.
+	NodeSelector string
+	// Interval is the period the capacity of nodes is annotated.
+	Interval time.Duration
+}
+
+// Validate checks if the config is valid.
+func (c *Config) Validate() error {
+	if _, err := labels.Parse(c.NodeSelector); err != nil {
+		return errors.Wrapf(err, "invalid node selector %q", c.NodeSelector)
+	}
+	if c.Interval <= 0 {
+		return errors.Errorf("interval %v must be positive", c.Interval)
+	}
+	return nil
+}
+
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list;watch
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=list;watch;patch
+
+// Controller annotates game nodes periodically with the GameServers more fitting in their
+// allocatable, and the free player slots of their ready GameServers. A GameServer slot is sized
+// as the largest GameServer pod on the node, or of the cluster if the node runs none. The
+// allocatable left is what all pods on the node do not request, including pods of DaemonSets
+// and system pods.
+type Controller struct {
+	kubeClient       kubernetes.Interface
+	nodeLister       corelisterv1.NodeLister
+	nodeSynced       cache.InformerSynced
+	podLister        corelisterv1.PodLister
+	podSynced        cache.InformerSynced
+	gameServerLister listerv1.GameServerLister
+	gameServerSynced cache.InformerSynced
+	// nodePodInformer caches all scheduled pods not terminated, indexed by node.
+	nodePodInformer cache.SharedIndexInformer
+	nodePodIndexer  cache.Indexer
+	// selector selects the game nodes, nil if only nodes running GameServers are annotated.
+	selector labels.Selector
+	config   Config
+}
+
+// NewController returns a new node capacity controller, config must be validated.
+func NewController(
+	kubeClient kubernetes.Interface,
+	kubeInformerFactory informers.SharedInformerFactory,
+	carrierInformerFactory externalversions.SharedInformerFactory,
+	config Config) *Controller {
+	nodes := kubeInformerFactory.Core().V1().Nodes()
+	pods := kubeInformerFactory.Core().V1().Pods()
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	c := &Controller{
+		kubeClient:       kubeClient,
+		nodeLister:       nodes.Lister(),
+		nodeSynced:       nodes.Informer().HasSynced,
+		podLister:        pods.Lister(),
+		podSynced:        pods.Informer().HasSynced,
+		gameServerLister: gameServers.Lister(),
+		gameServerSynced: gameServers.Informer().HasSynced,
+		nodePodInformer:  newNodePodInformer(kubeClient, 0),
+		config:           config,
+	}
+	c.nodePodIndexer = c.nodePodInformer.GetIndexer()
+	if len(config.NodeSelector) != 0 {
+		c.selector, _ = labels.Parse(config.NodeSelector)
+	}
+	return c
+}
+
+// Run annotates nodes periodically. Will block until stop is closed.
+func (c *Controller) Run(workers int, stop <-chan struct{}) error {
+	go c.nodePodInformer.Run(stop)
+	if !cache.WaitForCacheSync(stop, c.nodeSynced, c.podSynced, c.nodePodInformer.HasSynced,
+		c.gameServerSynced) {
+		return errors.New("failed to wait for caches to sync")
+	}
+	wait.Until(c.annotate, c.config.Interval, stop)
+	return nil
+}
+
+// annotate updates the capacity annotations of all nodes once.
+func (c *Controller) annotate() {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing nodes"))
+		return
+	}
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing pods"))
+		return
+	}
+	list, err := c.gameServerLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing GameServers"))
+		return
+	}
+	podsByNode := make(map[string][]*corev1.Pod)
+	var allPods []*corev1.Pod
+	for _, pod := range pods {
+		if len(pod.Spec.NodeName) == 0 || isTerminated(pod) {
+			continue
+		}
+		podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], pod)
+		allPods = append(allPods, pod)
+	}
+	playerSlots := make(map[string]int64)
+	for _, gs := range list {
+		if slots := freePlayerSlots(gs); slots > 0 {
+			playerSlots[gs.Status.NodeName] += slots
+		}
+	}
+	clusterSlot := largestRequests(allPods)
+	for _, node := range nodes {
+		var desired map[string]string
+		if len(podsByNode[node.Name]) != 0 || (c.selector != nil && c.selector.Matches(labels.Set(node.Labels))) {
+			slot := largestRequests(podsByNode[node.Name])
+			if len(podsByNode[node.Name]) == 0 {
+				slot = clusterSlot
+			}
+			nodePods, err := c.nodePods(node.Name)
+			if err != nil {
+				utilruntime.HandleError(err)
+				continue
+			}
+			desired = map[string]string{
+				util.NodeFreeGameServerSlotsAnnotation: strconv.FormatInt(
+					freeGameServerSlots(node, nodePods, slot), 10),
+				util.NodeFreePlayerSlotsAnnotation: strconv.FormatInt(playerSlots[node.Name], 10),
+			}
+		}
+		if err := c.patchNode(node, desired); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}
+}
+
+// nodePods returns all pods taking resources of node, not only the pods of GameServers.
+func (c *Controller) nodePods(nodeName string) ([]*corev1.Pod, error) {
+	objs, err := c.nodePodIndexer.ByIndex(nodeNameIndex, nodeName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing pods of node %v", nodeName)
+	}
+	var pods []*corev1.Pod
+	for _, obj := range objs {
+		if pod, ok := obj.(*corev1.Pod); ok && !isTerminated(pod) {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// patchNode patches the capacity annotations of node to desired, removes them if desired is nil.
+func (c *Controller) patchNode(node *corev1.Node, desired map[string]string) error {
+	annotations := make(map[string]interface{})
+	for _, key := range []string{util.NodeFreeGameServerSlotsAnnotation, util.NodeFreePlayerSlotsAnnotation} {
+		current, ok := node.Annotations[key]
+		value, wanted := desired[key]
+		switch {
+		case wanted && (!ok || current != value):
+			annotations[key] = value
+		case !wanted && ok:
+			annotations[key] = nil
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	klog.V(4).Infof("Patch capacity annotations of node %v: %s", node.Name, patch)
+	if _, err = c.kubeClient.CoreV1().Nodes().Patch(node.Name, types.MergePatchType, patch); err != nil {
+		return errors.Wrapf(err, "error patching capacity annotations of node %v", node.Name)
+	}
+	return nil
+}
+
+// largestRequests returns the largest cpu and memory requested by any of pods.
+func largestRequests(pods []*corev1.Pod) corev1.ResourceList {
+	largest := corev1.ResourceList{}
+	for _, pod := range pods {
+		requests := util.ResourceRequests(&pod.Spec)
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			if quantity, ok := requests[name]; ok && quantity.Cmp(largest[name]) > 0 {
+				largest[name] = quantity
+			}
+		}
+	}
+	return largest
+}
+
+// freeGameServerSlots returns the number of pods of slot requests more fitting in the allocatable
+// of node besides pods, also bounded by the allocatable pods of node.
+func freeGameServerSlots(node *corev1.Node, pods []*corev1.Pod, slot corev1.ResourceList) int64 {
+	requested := corev1.ResourceList{}
+	for _, pod := range pods {
+		for name, quantity := range util.ResourceRequests(&pod.Spec) {
+			current := requested[name]
+			current.Add(quantity)
+			requested[name] = current
+		}
+	}
+	allocatable := node.Status.Allocatable
+	free := int64(-1)
+	if maxPods, ok := allocatable[corev1.ResourcePods]; ok {
+		free = maxPods.Value() - int64(len(pods))
+	}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		size := slot[name]
+		if size.MilliValue() <= 0 {
+			continue
+		}
+		remaining := allocatable[name].DeepCopy()
+		remaining.Sub(requested[name])
+		if n := remaining.MilliValue() / size.MilliValue(); free < 0 || n < free {
+			free = n
+		}
+	}
+	if free < 0 {
+		return 0
+	}
+	return free
+}
+
+// freePlayerSlots returns the capacity minus players of gs if it is ready for new sessions,
+// 0 otherwise or if its capacity is not reported.
+func freePlayerSlots(gs *carrierv1alpha1.GameServer) int64 {
+	if len(gs.Status.NodeName) == 0 || gs.Status.State != carrierv1alpha1.GameServerRunning ||
+		gameservers.IsBeingDeleted(gs) || !gameservers.IsReady(gs) || !gameservers.AcceptsNewSessions(gs) {
+		return 0
+	}
+	capacity, err := strconv.ParseInt(gs.Annotations[util.GameServerCapacityAnnotation], 10, 64)
+	if err != nil {
+		return 0
+	}
+	players, _ := strconv.ParseInt(gs.Annotations[util.GameServerPlayersAnnotation], 10, 64)
+	if players < 0 {
+		players = 0
+	}
+	if players >= capacity {
+		return 0
+	}
+	return capacity - players
+}
+
+// isTerminated returns true if pod no longer takes resources of its node.
+func isTerminated(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
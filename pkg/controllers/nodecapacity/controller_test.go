@@ -0,0 +1,144 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodecapacity
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func newPod(cpu, memory string) *corev1.Pod {
+	return &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}},
+	}}}}
+}
+
+func TestFreeGameServerSlots(t *testing.T) {
+	node := &corev1.Node{Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("8"),
+		corev1.ResourceMemory: resource.MustParse("16Gi"),
+		corev1.ResourcePods:   resource.MustParse("110"),
+	}}}
+	pods := []*corev1.Pod{newPod("1", "2Gi"), newPod("2", "2Gi")}
+	slot := largestRequests(pods)
+	if cpu := slot[corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("2")) != 0 {
+		t.Errorf("desired slot of 2 cpu, get %v", cpu.String())
+	}
+	// 5 cpu and 12Gi memory left, 2 slots of 2 cpu
+	if free := freeGameServerSlots(node, pods, slot); free != 2 {
+		t.Errorf("desired 2 free slots, get %v", free)
+	}
+	// bounded by memory
+	if free := freeGameServerSlots(node, pods, largestRequests([]*corev1.Pod{newPod("100m", "5Gi")})); free != 2 {
+		t.Errorf("desired 2 free slots, get %v", free)
+	}
+	// bounded by pods
+	node.Status.Allocatable[corev1.ResourcePods] = resource.MustParse("3")
+	if free := freeGameServerSlots(node, pods, slot); free != 1 {
+		t.Errorf("desired 1 free slot, get %v", free)
+	}
+	// full
+	if free := freeGameServerSlots(node, append(pods, newPod("5", "1Gi")), slot); free != 0 {
+		t.Errorf("desired no free slots, get %v", free)
+	}
+}
+
+func TestFreePlayerSlots(t *testing.T) {
+	newGameServer := func(capacity, players string) *carrierv1alpha1.GameServer {
+		return &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				util.GameServerCapacityAnnotation: capacity,
+				util.GameServerPlayersAnnotation:  players,
+			}},
+			Status: carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning, NodeName: "node"},
+		}
+	}
+	deleting := newGameServer("10", "0")
+	now := metav1.NewTime(time.Now())
+	deleting.DeletionTimestamp = &now
+	tests := []struct {
+		name  string
+		gs    *carrierv1alpha1.GameServer
+		slots int64
+	}{
+		{name: "free", gs: newGameServer("10", "3"), slots: 7},
+		{name: "players not reported", gs: newGameServer("10", ""), slots: 10},
+		{name: "full", gs: newGameServer("10", "12")},
+		{name: "capacity not reported", gs: newGameServer("", "3")},
+		{name: "being deleted", gs: deleting},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if slots := freePlayerSlots(test.gs); slots != test.slots {
+				t.Errorf("desired %v free slots, get %v", test.slots, slots)
+			}
+		})
+	}
+}
+
+func TestAnnotateCountsAllPods(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("8"),
+			corev1.ResourceMemory: resource.MustParse("16Gi"),
+		}},
+	}
+	gsPod := newPod("2", "2Gi")
+	gsPod.Name, gsPod.Namespace, gsPod.Spec.NodeName = "gs", "default", node.Name
+	daemonPod := newPod("3", "1Gi")
+	daemonPod.Name, daemonPod.Namespace, daemonPod.Spec.NodeName = "daemon", "kube-system", node.Name
+
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nodeIndexer.Add(node)
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	podIndexer.Add(gsPod)
+	nodePodIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{nodeNameIndex: indexByNodeName})
+	nodePodIndexer.Add(gsPod)
+	nodePodIndexer.Add(daemonPod)
+	gsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	kubeClient := fake.NewSimpleClientset(node)
+	c := &Controller{
+		kubeClient:       kubeClient,
+		nodeLister:       corelisterv1.NewNodeLister(nodeIndexer),
+		podLister:        corelisterv1.NewPodLister(podIndexer),
+		gameServerLister: listerv1.NewGameServerLister(gsIndexer),
+		nodePodIndexer:   nodePodIndexer,
+	}
+	c.annotate()
+
+	node, err := kubeClient.CoreV1().Nodes().Get(node.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 cpu left besides the GameServer and the DaemonSet pod, 1 slot of 2 cpu
+	if free := node.Annotations[util.NodeFreeGameServerSlotsAnnotation]; free != "1" {
+		t.Errorf("desired 1 free GameServer slot, get %v", free)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodecapacity
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/ocgi/carrier/pkg/util/kube"
+)
+
+// nodeNameIndex indexes pods by the name of their node.
+const nodeNameIndex = "nodeName"
+
+// scheduledPodsSelector selects the pods taking resources of their nodes.
+var scheduledPodsSelector = fmt.Sprintf("spec.nodeName!=,status.phase!=%v,status.phase!=%v",
+	corev1.PodSucceeded, corev1.PodFailed)
+
+// newNodePodInformer returns an informer of all pods scheduled and not terminated, indexed by
+// node, including pods of DaemonSets and system pods. The pod informer of kube informer factory
+// only caches pods of GameServers, so it is not shared. managedFields of pods are dropped
+// before caching, as only the nodes and requests of pods are needed.
+func newNodePodInformer(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = scheduledPodsSelector
+			return client.CoreV1().Pods(metav1.NamespaceAll).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = scheduledPodsSelector
+			return client.CoreV1().Pods(metav1.NamespaceAll).Watch(options)
+		},
+	}
+	return cache.NewSharedIndexInformer(kube.NewTransformingListWatch(lw, kube.StripManagedFields), &corev1.Pod{}, resync,
+		cache.Indexers{nodeNameIndex: indexByNodeName})
+}
+
+// indexByNodeName returns the node name of pod as its index value.
+func indexByNodeName(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || len(pod.Spec.NodeName) == 0 {
+		return nil, nil
+	}
+	return []string{pod.Spec.NodeName}, nil
+}
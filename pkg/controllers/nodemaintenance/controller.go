@@ -0,0 +1,348 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodemaintenance
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util/kube"
+)
+
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=nodemaintenances,verbs=list;watch
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=nodemaintenances/status,verbs=update
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=list;watch;update
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=list;watch;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch
+
+const (
+	// defaultLeadSeconds is how long ahead of the window GameServers are marked if not specified.
+	defaultLeadSeconds = 3600
+	// cordonOwnerPrefix prefixes the name of NodeMaintenance cordoning a node.
+	cordonOwnerPrefix = "NodeMaintenance/"
+	// invalidNodeSelector is the reason of the event recorded on maintenances with an empty node selector.
+	invalidNodeSelector = "InvalidNodeSelector"
+)
+
+// Controller reconciles every NodeMaintenance periodically. From the lead time before the
+// window till its end, GameServers on the nodes selected are marked by the constraint of
+// the policy, which expires at the end of the window, so they are not allocated and return
+// to service once the maintenance is over. The nodes are cordoned meanwhile, so GameServers
+// replacing the ones marked are not scheduled onto them, and uncordoned once no maintenance
+// marks them any more.
+type Controller struct {
+	kubeClient        kubernetes.Interface
+	carrierClient     versioned.Interface
+	maintenanceLister listerv1.NodeMaintenanceLister
+	maintenanceSynced cache.InformerSynced
+	nodeLister        corelisterv1.NodeLister
+	nodeSynced        cache.InformerSynced
+	gameServerLister  listerv1.GameServerLister
+	gameServerSynced  cache.InformerSynced
+	recorder          record.EventRecorder
+	events            *kube.EventDeduper
+	interval          time.Duration
+}
+
+// NewController returns a new node maintenance controller reconciling every interval.
+func NewController(
+	kubeClient kubernetes.Interface,
+	carrierClient versioned.Interface,
+	kubeInformerFactory informers.SharedInformerFactory,
+	carrierInformerFactory externalversions.SharedInformerFactory,
+	interval time.Duration) *Controller {
+	maintenances := carrierInformerFactory.Carrier().V1alpha1().NodeMaintenances()
+	nodes := kubeInformerFactory.Core().V1().Nodes()
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	c := &Controller{
+		kubeClient:        kubeClient,
+		carrierClient:     carrierClient,
+		maintenanceLister: maintenances.Lister(),
+		maintenanceSynced: maintenances.Informer().HasSynced,
+		nodeLister:        nodes.Lister(),
+		nodeSynced:        nodes.Informer().HasSynced,
+		gameServerLister:  gameServers.Lister(),
+		gameServerSynced:  gameServers.Informer().HasSynced,
+		events:            kube.NewEventDeduper(),
+		interval:          interval,
+	}
+	maintenances.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: c.deleteMaintenance,
+	})
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	c.recorder = eventBroadcaster.NewRecorder(scheme.Scheme,
+		corev1.EventSource{Component: "nodemaintenance-controller"})
+	return c
+}
+
+// Run reconciles NodeMaintenances periodically. Will block until stop is closed.
+func (c *Controller) Run(workers int, stop <-chan struct{}) error {
+	if !cache.WaitForCacheSync(stop, c.maintenanceSynced, c.nodeSynced, c.gameServerSynced) {
+		return errors.New("failed to wait for caches to sync")
+	}
+	wait.Until(c.syncAll, c.interval, stop)
+	return nil
+}
+
+// syncAll reconciles all NodeMaintenances once.
+func (c *Controller) syncAll() {
+	maintenances, err := c.maintenanceLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing NodeMaintenances"))
+		return
+	}
+	servers := make(map[string][]*carrierv1alpha1.GameServer)
+	if len(maintenances) != 0 {
+		list, err := c.gameServerLister.List(labels.Everything())
+		if err != nil {
+			utilruntime.HandleError(errors.Wrap(err, "error listing GameServers"))
+			return
+		}
+		for _, gs := range list {
+			if onNode(gs) {
+				servers[gs.Status.NodeName] = append(servers[gs.Status.NodeName], gs)
+			}
+		}
+	}
+	now := time.Now()
+	cordoned := make(map[string]bool)
+	failed := make(map[string]bool)
+	for _, maintenance := range maintenances {
+		if err := c.sync(maintenance, servers, cordoned, now); err != nil {
+			utilruntime.HandleError(err)
+			failed[cordonOwnerPrefix+maintenance.Name] = true
+		}
+	}
+	c.uncordon(cordoned, failed)
+}
+
+// deleteMaintenance forgets the events recorded on the NodeMaintenance deleted.
+func (c *Controller) deleteMaintenance(obj interface{}) {
+	if maintenance, ok := obj.(*carrierv1alpha1.NodeMaintenance); ok {
+		c.events.Forget(maintenance.UID)
+	} else if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		if maintenance, ok := tombstone.Obj.(*carrierv1alpha1.NodeMaintenance); ok {
+			c.events.Forget(maintenance.UID)
+		}
+	}
+}
+
+// uncordon uncordons the nodes cordoned by NodeMaintenances which are not in cordoned,
+// because the maintenances are completed, rescheduled or deleted. The nodes of maintenances
+// failing to sync, whose owners are in failed, are not known, so they are kept cordoned till
+// the next round, without holding back the nodes of the other maintenances.
+func (c *Controller) uncordon(cordoned, failed map[string]bool) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing nodes"))
+		return
+	}
+	for _, node := range nodes {
+		owner := kube.CordonedBy(node)
+		if !strings.HasPrefix(owner, cordonOwnerPrefix) || cordoned[node.Name] || failed[owner] {
+			continue
+		}
+		klog.Infof("Uncordon node %v cordoned by %v", node.Name, owner)
+		if err := kube.UncordonNode(c.kubeClient, node, owner); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}
+}
+
+// sync marks the GameServers on the nodes of maintenance and cordons the nodes if its window is
+// approaching or started, and updates its status. The nodes cordoned are added to cordoned.
+// Maintenances with an empty node selector are skipped, as they would select every node.
+func (c *Controller) sync(maintenance *carrierv1alpha1.NodeMaintenance,
+	servers map[string][]*carrierv1alpha1.GameServer, cordoned map[string]bool, now time.Time) error {
+	if len(maintenance.Spec.NodeSelector) == 0 {
+		c.events.Eventf(c.recorder, maintenance, corev1.EventTypeWarning, invalidNodeSelector,
+			"Skipped, an empty nodeSelector would select every node")
+		return nil
+	}
+	c.events.Resolve(maintenance.UID, invalidNodeSelector)
+	status := &carrierv1alpha1.NodeMaintenanceStatus{Phase: phase(&maintenance.Spec, now)}
+	if status.Phase == carrierv1alpha1.MaintenanceCompleted {
+		status.Nodes = maintenance.Status.Nodes
+		status.ReadyNodes = maintenance.Status.ReadyNodes
+	} else {
+		nodes, err := c.nodeLister.List(labels.SelectorFromSet(maintenance.Spec.NodeSelector))
+		if err != nil {
+			return errors.Wrapf(err, "error listing nodes of NodeMaintenance %v", maintenance.Name)
+		}
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].Name < nodes[j].Name
+		})
+		marking := status.Phase == carrierv1alpha1.MaintenancePreparing ||
+			status.Phase == carrierv1alpha1.MaintenanceInProgress
+		for _, node := range nodes {
+			if marking {
+				cordoned[node.Name] = true
+				if err := kube.CordonNode(c.kubeClient, node, cordonOwnerPrefix+maintenance.Name); err != nil {
+					utilruntime.HandleError(err)
+				}
+				c.mark(maintenance, node.Name, servers[node.Name])
+			}
+			nodeStatus := nodeReadiness(maintenance.Spec.Policy, node.Name, servers[node.Name])
+			if nodeStatus.Ready {
+				status.ReadyNodes++
+			}
+			status.Nodes = append(status.Nodes, nodeStatus)
+		}
+	}
+	previous := maintenance.Status.DeepCopy()
+	previous.LastUpdateTime = nil
+	if apiequality.Semantic.DeepEqual(previous, status) {
+		return nil
+	}
+	updateTime := metav1.NewTime(now)
+	status.LastUpdateTime = &updateTime
+	maintenanceCopy := maintenance.DeepCopy()
+	maintenanceCopy.Status = *status
+	klog.V(4).Infof("NodeMaintenance %v: %+v", maintenance.Name, status)
+	if _, err := c.carrierClient.CarrierV1alpha1().NodeMaintenances().UpdateStatus(maintenanceCopy); err != nil {
+		return errors.Wrapf(err, "error updating status of NodeMaintenance %v", maintenance.Name)
+	}
+	return nil
+}
+
+// mark adds the constraint of the policy of maintenance to the GameServers on node.
+func (c *Controller) mark(maintenance *carrierv1alpha1.NodeMaintenance, node string,
+	list []*carrierv1alpha1.GameServer) {
+	constraint := maintenanceConstraint(maintenance)
+	for _, gs := range list {
+		gsCopy := gs.DeepCopy()
+		if !addConstraint(gsCopy, constraint) {
+			continue
+		}
+		if _, err := c.carrierClient.CarrierV1alpha1().GameServers(gs.Namespace).Update(gsCopy); err != nil {
+			utilruntime.HandleError(errors.Wrapf(err, "error marking GameServer %v/%v for maintenance",
+				gs.Namespace, gs.Name))
+			continue
+		}
+		c.recorder.Eventf(gs, corev1.EventTypeNormal, "NodeMaintenance",
+			"Marked %v till %v for the maintenance %v of node %v", constraint.Type,
+			constraint.ExpiresAt.UTC().Format(time.RFC3339), maintenance.Name, node)
+	}
+}
+
+// phase returns the phase of maintenance at now.
+func phase(spec *carrierv1alpha1.NodeMaintenanceSpec, now time.Time) carrierv1alpha1.NodeMaintenancePhase {
+	start := spec.Window.Start.Time
+	lead := int32(defaultLeadSeconds)
+	if spec.LeadSeconds != nil {
+		lead = *spec.LeadSeconds
+	}
+	switch {
+	case now.Before(start.Add(-time.Duration(lead) * time.Second)):
+		return carrierv1alpha1.MaintenancePending
+	case now.Before(start):
+		return carrierv1alpha1.MaintenancePreparing
+	case now.Before(windowEnd(spec)):
+		return carrierv1alpha1.MaintenanceInProgress
+	}
+	return carrierv1alpha1.MaintenanceCompleted
+}
+
+// windowEnd returns when the maintenance window ends.
+func windowEnd(spec *carrierv1alpha1.NodeMaintenanceSpec) time.Time {
+	return spec.Window.Start.Add(time.Duration(spec.Window.DurationSeconds) * time.Second)
+}
+
+// maintenanceConstraint returns the constraint of the policy of maintenance, which expires
+// at the end of the window.
+func maintenanceConstraint(maintenance *carrierv1alpha1.NodeMaintenance) carrierv1alpha1.Constraint {
+	constraintType := carrierv1alpha1.NotInService
+	if maintenance.Spec.Policy == carrierv1alpha1.MaintenanceNoNewSessions {
+		constraintType = carrierv1alpha1.NoNewSessions
+	}
+	effective := true
+	now := metav1.Now()
+	end := metav1.NewTime(windowEnd(&maintenance.Spec))
+	return carrierv1alpha1.Constraint{
+		Type:      constraintType,
+		Effective: &effective,
+		Message:   fmt.Sprintf("Node maintenance %v", maintenance.Name),
+		TimeAdded: &now,
+		ExpiresAt: &end,
+	}
+}
+
+// addConstraint adds constraint to gs, an effective constraint of the same type lasting
+// longer is kept. Returns true if gs is changed.
+func addConstraint(gs *carrierv1alpha1.GameServer, constraint carrierv1alpha1.Constraint) bool {
+	for i := range gs.Spec.Constraints {
+		existing := &gs.Spec.Constraints[i]
+		if existing.Type != constraint.Type {
+			continue
+		}
+		if existing.Effective != nil && *existing.Effective &&
+			(existing.ExpiresAt == nil || !existing.ExpiresAt.Before(constraint.ExpiresAt)) {
+			return false
+		}
+		*existing = constraint
+		return true
+	}
+	gs.Spec.Constraints = append(gs.Spec.Constraints, constraint)
+	return true
+}
+
+// nodeReadiness returns the readiness for maintenance of node running GameServers of list.
+func nodeReadiness(policy carrierv1alpha1.NodeMaintenancePolicy, node string,
+	list []*carrierv1alpha1.GameServer) carrierv1alpha1.NodeMaintenanceNodeStatus {
+	status := carrierv1alpha1.NodeMaintenanceNodeStatus{NodeName: node, GameServers: int32(len(list))}
+	for _, gs := range list {
+		if players := gameservers.ReportedPlayers(gs); players > 0 {
+			status.Players += players
+		}
+	}
+	if policy == carrierv1alpha1.MaintenanceNoNewSessions {
+		status.Ready = status.Players == 0
+	} else {
+		status.Ready = status.GameServers == 0
+	}
+	return status
+}
+
+// onNode returns true if gs is scheduled and not finished.
+func onNode(gs *carrierv1alpha1.GameServer) bool {
+	return gs.DeletionTimestamp == nil && len(gs.Status.NodeName) != 0 && !gameservers.IsStopped(gs) &&
+		!gameservers.IsCompleted(gs)
+}
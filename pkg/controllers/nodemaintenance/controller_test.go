@@ -0,0 +1,223 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodemaintenance
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/util/kube"
+)
+
+func TestPhase(t *testing.T) {
+	start := time.Now()
+	lead := int32(600)
+	spec := &carrierv1alpha1.NodeMaintenanceSpec{
+		Window:      carrierv1alpha1.MaintenanceWindow{Start: metav1.NewTime(start), DurationSeconds: 300},
+		LeadSeconds: &lead,
+	}
+	tests := []struct {
+		now     time.Time
+		desired carrierv1alpha1.NodeMaintenancePhase
+	}{
+		{now: start.Add(-time.Hour), desired: carrierv1alpha1.MaintenancePending},
+		{now: start.Add(-time.Minute), desired: carrierv1alpha1.MaintenancePreparing},
+		{now: start.Add(time.Minute), desired: carrierv1alpha1.MaintenanceInProgress},
+		{now: start.Add(10 * time.Minute), desired: carrierv1alpha1.MaintenanceCompleted},
+	}
+	for _, test := range tests {
+		if got := phase(spec, test.now); got != test.desired {
+			t.Errorf("at %v desired %v, get %v", test.now.Sub(start), test.desired, got)
+		}
+	}
+}
+
+func TestAddConstraint(t *testing.T) {
+	effective := true
+	soon := metav1.NewTime(time.Now().Add(time.Minute))
+	later := metav1.NewTime(time.Now().Add(time.Hour))
+	constraint := carrierv1alpha1.Constraint{Type: carrierv1alpha1.NotInService, Effective: &effective,
+		ExpiresAt: &soon}
+
+	gs := &carrierv1alpha1.GameServer{}
+	if !addConstraint(gs, constraint) || len(gs.Spec.Constraints) != 1 {
+		t.Fatalf("desired constraint added, get: %v", gs.Spec.Constraints)
+	}
+	if addConstraint(gs, constraint) {
+		t.Errorf("desired the same constraint not added again")
+	}
+
+	gs.Spec.Constraints[0].ExpiresAt = &later
+	if addConstraint(gs, constraint) {
+		t.Errorf("desired the constraint lasting longer kept")
+	}
+
+	gs.Spec.Constraints[0].ExpiresAt = nil
+	if addConstraint(gs, constraint) {
+		t.Errorf("desired the constraint without expiry kept")
+	}
+
+	notEffective := false
+	gs.Spec.Constraints[0].Effective = &notEffective
+	if !addConstraint(gs, constraint) || !*gs.Spec.Constraints[0].Effective {
+		t.Errorf("desired the constraint not effective replaced, get: %v", gs.Spec.Constraints)
+	}
+}
+
+func TestNodeReadiness(t *testing.T) {
+	newGameServer := func(players string) *carrierv1alpha1.GameServer {
+		return &carrierv1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{util.GameServerPlayersAnnotation: players}}}
+	}
+	tests := []struct {
+		name    string
+		policy  carrierv1alpha1.NodeMaintenancePolicy
+		list    []*carrierv1alpha1.GameServer
+		desired carrierv1alpha1.NodeMaintenanceNodeStatus
+	}{
+		{
+			name:    "drained",
+			policy:  carrierv1alpha1.MaintenanceDrain,
+			desired: carrierv1alpha1.NodeMaintenanceNodeStatus{NodeName: "node1", Ready: true},
+		},
+		{
+			name:    "draining",
+			policy:  carrierv1alpha1.MaintenanceDrain,
+			list:    []*carrierv1alpha1.GameServer{newGameServer("0")},
+			desired: carrierv1alpha1.NodeMaintenanceNodeStatus{NodeName: "node1", GameServers: 1},
+		},
+		{
+			name:   "no players left",
+			policy: carrierv1alpha1.MaintenanceNoNewSessions,
+			list:   []*carrierv1alpha1.GameServer{newGameServer("0"), newGameServer("invalid")},
+			desired: carrierv1alpha1.NodeMaintenanceNodeStatus{NodeName: "node1", GameServers: 2,
+				Ready: true},
+		},
+		{
+			name:    "players left",
+			policy:  carrierv1alpha1.MaintenanceNoNewSessions,
+			list:    []*carrierv1alpha1.GameServer{newGameServer("3"), newGameServer("2")},
+			desired: carrierv1alpha1.NodeMaintenanceNodeStatus{NodeName: "node1", GameServers: 2, Players: 5},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := nodeReadiness(test.policy, "node1", test.list); got != test.desired {
+				t.Errorf("desired %+v, get %+v", test.desired, got)
+			}
+		})
+	}
+}
+
+func TestSyncAllCordonsNodes(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: map[string]string{"pool": "game"}}}
+	other := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+	maintenance := &carrierv1alpha1.NodeMaintenance{
+		ObjectMeta: metav1.ObjectMeta{Name: "kernel"},
+		Spec: carrierv1alpha1.NodeMaintenanceSpec{
+			NodeSelector: map[string]string{"pool": "game"},
+			Window:       carrierv1alpha1.MaintenanceWindow{Start: metav1.NewTime(time.Now()), DurationSeconds: 300},
+		},
+	}
+	empty := &carrierv1alpha1.NodeMaintenance{
+		ObjectMeta: metav1.ObjectMeta{Name: "empty"},
+		Spec:       carrierv1alpha1.NodeMaintenanceSpec{Window: maintenance.Spec.Window},
+	}
+	// broken selects no node, and fails to update its status.
+	broken := &carrierv1alpha1.NodeMaintenance{
+		ObjectMeta: metav1.ObjectMeta{Name: "broken"},
+		Spec: carrierv1alpha1.NodeMaintenanceSpec{
+			NodeSelector: map[string]string{"pool": "none"},
+			Window:       maintenance.Spec.Window,
+		},
+	}
+	kubeClient := kubefake.NewSimpleClientset(node, other)
+	carrierClient := fake.NewSimpleClientset(maintenance, empty, broken)
+	carrierClient.PrependReactor("update", "nodemaintenances",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			update := action.(k8stesting.UpdateAction)
+			if update.GetObject().(*carrierv1alpha1.NodeMaintenance).Name != broken.Name {
+				return false, nil, nil
+			}
+			return true, nil, errors.NewInternalError(fmt.Errorf("broken"))
+		})
+	newIndexer := func() cache.Indexer {
+		return cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	}
+	maintenances, nodes := newIndexer(), newIndexer()
+	maintenances.Add(maintenance)
+	maintenances.Add(empty)
+	maintenances.Add(broken)
+	nodes.Add(node)
+	nodes.Add(other)
+	recorder := record.NewFakeRecorder(10)
+	c := &Controller{
+		kubeClient:        kubeClient,
+		carrierClient:     carrierClient,
+		maintenanceLister: listerv1.NewNodeMaintenanceLister(maintenances),
+		nodeLister:        corelisterv1.NewNodeLister(nodes),
+		gameServerLister:  listerv1.NewGameServerLister(newIndexer()),
+		recorder:          recorder,
+		events:            kube.NewEventDeduper(),
+	}
+	resync := func() {
+		c.syncAll()
+		for _, name := range []string{"node", "other"} {
+			n, _ := kubeClient.CoreV1().Nodes().Get(name, metav1.GetOptions{})
+			nodes.Update(n)
+		}
+	}
+
+	resync()
+	cordoned, _ := kubeClient.CoreV1().Nodes().Get("node", metav1.GetOptions{})
+	if kube.CordonedBy(cordoned) != "NodeMaintenance/kernel" {
+		t.Fatalf("desired node cordoned by the maintenance in progress, get: %+v", cordoned)
+	}
+	if n, _ := kubeClient.CoreV1().Nodes().Get("other", metav1.GetOptions{}); n.Spec.Unschedulable {
+		t.Errorf("desired node not selected left schedulable")
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("desired a warning of the empty node selector, get %v events", len(recorder.Events))
+	}
+	if m, _ := carrierClient.CarrierV1alpha1().NodeMaintenances().Get("empty", metav1.GetOptions{}); len(m.Status.Phase) != 0 {
+		t.Errorf("desired maintenance with empty node selector skipped, get: %+v", m.Status)
+	}
+
+	// the maintenance is deleted before the window ends, while broken keeps failing.
+	maintenances.Delete(maintenance)
+	resync()
+	if n, _ := kubeClient.CoreV1().Nodes().Get("node", metav1.GetOptions{}); n.Spec.Unschedulable {
+		t.Errorf("desired node uncordoned once no maintenance marks it, get: %+v", n)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("desired the warning of the empty node selector recorded once, get %v events",
+			len(recorder.Events))
+	}
+}
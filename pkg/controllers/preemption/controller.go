@@ -0,0 +1,347 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preemption
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=prioritypolicies,verbs=list;watch
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=prioritypolicies/status,verbs=update
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=squads,verbs=list;watch;update
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch
+
+const (
+	// defaultPendingSeconds is how long GameServers are unschedulable before preemption if not specified.
+	defaultPendingSeconds = 60
+	// defaultCooldownSeconds is the min interval between preemptions if not specified.
+	defaultCooldownSeconds = 60
+)
+
+// Controller reconciles every PriorityPolicy periodically. While GameServers of its high priority
+// Squads are unschedulable longer than the pending seconds, its low priority Squads are scaled
+// down by as many replicas, the ones with most replicas above the min replicas first. GameServers
+// of low priority Squads still draining are counted as capacity about to be freed, so Squads are
+// not scaled down again before the replicas preempted earlier are gone. Preempted
+// Squads are not scaled back, which is left to their operators. Squads whose replicas are
+// managed by autoscalers are never preempted, or preemption would repeat every cooldown as
+// the autoscalers scale them back up.
+type Controller struct {
+	carrierClient    versioned.Interface
+	policyLister     listerv1.PriorityPolicyLister
+	policySynced     cache.InformerSynced
+	squadLister      listerv1.SquadLister
+	squadSynced      cache.InformerSynced
+	gameServerLister listerv1.GameServerLister
+	gameServerSynced cache.InformerSynced
+	podLister        corelisterv1.PodLister
+	podSynced        cache.InformerSynced
+	recorder         record.EventRecorder
+	interval         time.Duration
+}
+
+// NewController returns a new preemption controller reconciling every interval.
+func NewController(
+	kubeClient kubernetes.Interface,
+	carrierClient versioned.Interface,
+	kubeInformerFactory informers.SharedInformerFactory,
+	carrierInformerFactory externalversions.SharedInformerFactory,
+	interval time.Duration) *Controller {
+	policies := carrierInformerFactory.Carrier().V1alpha1().PriorityPolicies()
+	squads := carrierInformerFactory.Carrier().V1alpha1().Squads()
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	pods := kubeInformerFactory.Core().V1().Pods()
+	c := &Controller{
+		carrierClient:    carrierClient,
+		policyLister:     policies.Lister(),
+		policySynced:     policies.Informer().HasSynced,
+		squadLister:      squads.Lister(),
+		squadSynced:      squads.Informer().HasSynced,
+		gameServerLister: gameServers.Lister(),
+		gameServerSynced: gameServers.Informer().HasSynced,
+		podLister:        pods.Lister(),
+		podSynced:        pods.Informer().HasSynced,
+		interval:         interval,
+	}
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	c.recorder = eventBroadcaster.NewRecorder(scheme.Scheme,
+		corev1.EventSource{Component: "preemption-controller"})
+	return c
+}
+
+// Run reconciles PriorityPolicies periodically. Will block until stop is closed.
+func (c *Controller) Run(workers int, stop <-chan struct{}) error {
+	if !cache.WaitForCacheSync(stop, c.policySynced, c.squadSynced, c.gameServerSynced, c.podSynced) {
+		return errors.New("failed to wait for caches to sync")
+	}
+	wait.Until(c.syncAll, c.interval, stop)
+	return nil
+}
+
+// syncAll reconciles all PriorityPolicies once.
+func (c *Controller) syncAll() {
+	policies, err := c.policyLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "error listing PriorityPolicies"))
+		return
+	}
+	now := time.Now()
+	for _, policy := range policies {
+		if err := c.sync(policy, now); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}
+}
+
+// sync preempts the low priority Squads of policy if GameServers of its high priority Squads
+// are pending, and updates its status.
+func (c *Controller) sync(policy *carrierv1alpha1.PriorityPolicy, now time.Time) error {
+	high, err := metav1.LabelSelectorAsSelector(policy.Spec.HighPriority)
+	if err != nil {
+		return errors.Wrapf(err, "invalid highPriority of PriorityPolicy %v", policy.Name)
+	}
+	low, err := metav1.LabelSelectorAsSelector(policy.Spec.LowPriority)
+	if err != nil {
+		return errors.Wrapf(err, "invalid lowPriority of PriorityPolicy %v", policy.Name)
+	}
+	highSquads, err := c.squadLister.List(high)
+	if err != nil {
+		return errors.Wrap(err, "error listing high priority Squads")
+	}
+	pendingFor := time.Duration(int32Value(policy.Spec.PendingSeconds, defaultPendingSeconds)) * time.Second
+	isHigh := make(map[string]bool, len(highSquads))
+	status := policy.Status.DeepCopy()
+	status.PendingGameServers = 0
+	for _, sqd := range highSquads {
+		isHigh[sqd.Namespace+"/"+sqd.Name] = true
+		pending, err := c.pendingGameServers(sqd, now, pendingFor)
+		if err != nil {
+			return err
+		}
+		status.PendingGameServers += pending
+	}
+	cooldown := time.Duration(int32Value(policy.Spec.CooldownSeconds, defaultCooldownSeconds)) * time.Second
+	if status.PendingGameServers > 0 &&
+		(status.LastPreemptionTime == nil || !now.Before(status.LastPreemptionTime.Add(cooldown))) {
+		lowSquads, err := c.squadLister.List(low)
+		if err != nil {
+			return errors.Wrap(err, "error listing low priority Squads")
+		}
+		var candidates []*carrierv1alpha1.Squad
+		for _, sqd := range lowSquads {
+			if isHigh[sqd.Namespace+"/"+sqd.Name] || sqd.DeletionTimestamp != nil {
+				continue
+			}
+			if isAutoscaled(sqd) {
+				klog.V(4).Infof("PriorityPolicy %v skips Squad %v/%v managed by an autoscaler",
+					policy.Name, sqd.Namespace, sqd.Name)
+				continue
+			}
+			candidates = append(candidates, sqd)
+		}
+		// GameServers preempted before still hold their capacity while they drain.
+		draining, err := c.drainingGameServers(candidates)
+		if err != nil {
+			return err
+		}
+		if needed := status.PendingGameServers - draining; needed <= 0 {
+			klog.V(4).Infof("PriorityPolicy %v skips preemption, %v pending GameServers wait for %v draining",
+				policy.Name, status.PendingGameServers, draining)
+		} else if preempted := c.preempt(policy, candidates, needed); preempted > 0 {
+			status.PreemptedReplicas += preempted
+			preemptionTime := metav1.NewTime(now)
+			status.LastPreemptionTime = &preemptionTime
+		}
+	}
+	if apiequality.Semantic.DeepEqual(&policy.Status, status) {
+		return nil
+	}
+	policyCopy := policy.DeepCopy()
+	policyCopy.Status = *status
+	if _, err := c.carrierClient.CarrierV1alpha1().PriorityPolicies().UpdateStatus(policyCopy); err != nil {
+		return errors.Wrapf(err, "error updating status of PriorityPolicy %v", policy.Name)
+	}
+	return nil
+}
+
+// preempt scales down candidates by pending replicas in total, returns the replicas scaled down.
+func (c *Controller) preempt(policy *carrierv1alpha1.PriorityPolicy,
+	candidates []*carrierv1alpha1.Squad, pending int32) int32 {
+	var preempted int32
+	for _, p := range planPreemptions(candidates, pending, policy.Spec.MinReplicas) {
+		sqdCopy := p.squad.DeepCopy()
+		sqdCopy.Spec.Replicas = p.replicas
+		if _, err := c.carrierClient.CarrierV1alpha1().Squads(sqdCopy.Namespace).Update(sqdCopy); err != nil {
+			utilruntime.HandleError(errors.Wrapf(err, "error preempting Squad %v/%v",
+				sqdCopy.Namespace, sqdCopy.Name))
+			continue
+		}
+		preempted += p.squad.Spec.Replicas - p.replicas
+		klog.Infof("PriorityPolicy %v scaled down Squad %v/%v from %v to %v replicas",
+			policy.Name, sqdCopy.Namespace, sqdCopy.Name, p.squad.Spec.Replicas, p.replicas)
+		c.recorder.Eventf(p.squad, corev1.EventTypeWarning, util.PreemptedReason,
+			"Scaled down from %v to %v replicas by PriorityPolicy %v for %v pending GameServers of high priority Squads",
+			p.squad.Spec.Replicas, p.replicas, policy.Name, pending)
+		c.recorder.Eventf(policy, corev1.EventTypeNormal, util.PreemptedReason,
+			"Scaled down Squad %v/%v from %v to %v replicas for %v pending GameServers of high priority Squads",
+			sqdCopy.Namespace, sqdCopy.Name, p.squad.Spec.Replicas, p.replicas, pending)
+	}
+	return preempted
+}
+
+// drainingGameServers returns the number of GameServers of squads whose capacity is about to be
+// freed: the ones on nodes being deleted or out of service, and the ones above the replicas of
+// their Squads which are not marked yet.
+func (c *Controller) drainingGameServers(squads []*carrierv1alpha1.Squad) (int32, error) {
+	var draining int32
+	for _, sqd := range squads {
+		list, err := c.gameServerLister.GameServers(sqd.Namespace).List(
+			labels.SelectorFromSet(labels.Set{util.SquadNameLabelKey: sqd.Name}))
+		if err != nil {
+			return 0, errors.Wrapf(err, "error listing GameServers of Squad %v/%v", sqd.Namespace, sqd.Name)
+		}
+		var active int32
+		for _, gs := range list {
+			if len(gs.Status.NodeName) == 0 {
+				continue
+			}
+			if gameservers.IsBeingDeleted(gs) || gameservers.IsOutOfService(gs) {
+				draining++
+				continue
+			}
+			active++
+		}
+		if active > sqd.Spec.Replicas {
+			draining += active - sqd.Spec.Replicas
+		}
+	}
+	return draining, nil
+}
+
+// pendingGameServers returns the number of GameServers of sqd unschedulable for longer than pendingFor.
+func (c *Controller) pendingGameServers(sqd *carrierv1alpha1.Squad, now time.Time,
+	pendingFor time.Duration) (int32, error) {
+	list, err := c.gameServerLister.GameServers(sqd.Namespace).List(
+		labels.SelectorFromSet(labels.Set{util.SquadNameLabelKey: sqd.Name}))
+	if err != nil {
+		return 0, errors.Wrapf(err, "error listing GameServers of Squad %v/%v", sqd.Namespace, sqd.Name)
+	}
+	var pending int32
+	for _, gs := range list {
+		if gameservers.IsBeingDeleted(gs) || !gameservers.IsBeforeRunning(gs) {
+			continue
+		}
+		pod, err := c.podLister.Pods(gs.Namespace).Get(gs.Name)
+		if err != nil {
+			continue
+		}
+		if since := unschedulableSince(pod); since != nil && !now.Before(since.Add(pendingFor)) {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// preemption is the replicas a Squad is scaled down to.
+type preemption struct {
+	squad    *carrierv1alpha1.Squad
+	replicas int32
+}
+
+// planPreemptions returns the replicas candidates are scaled down to for replicas needed in total,
+// taken from the Squads with most replicas above minReplicas first.
+func planPreemptions(candidates []*carrierv1alpha1.Squad, needed, minReplicas int32) []preemption {
+	sorted := make([]*carrierv1alpha1.Squad, len(candidates))
+	copy(sorted, candidates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Spec.Replicas != sorted[j].Spec.Replicas {
+			return sorted[i].Spec.Replicas > sorted[j].Spec.Replicas
+		}
+		if sorted[i].Namespace != sorted[j].Namespace {
+			return sorted[i].Namespace < sorted[j].Namespace
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	var result []preemption
+	for _, sqd := range sorted {
+		if needed <= 0 {
+			break
+		}
+		spare := sqd.Spec.Replicas - minReplicas
+		if spare <= 0 {
+			continue
+		}
+		if spare > needed {
+			spare = needed
+		}
+		result = append(result, preemption{squad: sqd, replicas: sqd.Spec.Replicas - spare})
+		needed -= spare
+	}
+	return result
+}
+
+// unschedulableSince returns when pod became unschedulable, nil if it is not.
+func unschedulableSince(pod *corev1.Pod) *metav1.Time {
+	for i := range pod.Status.Conditions {
+		condition := &pod.Status.Conditions[i]
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse &&
+			condition.Reason == corev1.PodReasonUnschedulable {
+			return &condition.LastTransitionTime
+		}
+	}
+	return nil
+}
+
+// isAutoscaled returns true if the replicas of sqd are managed by an autoscaler.
+func isAutoscaled(sqd *carrierv1alpha1.Squad) bool {
+	return sqd.Annotations[util.SquadAutoscaledAnnotation] == "true"
+}
+
+// int32Value returns the value of p, or defaultValue if p is nil.
+func int32Value(p *int32, defaultValue int32) int32 {
+	if p == nil {
+		return defaultValue
+	}
+	return *p
+}
@@ -0,0 +1,177 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preemption
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func newSquad(name string, replicas int32) *carrierv1alpha1.Squad {
+	return &carrierv1alpha1.Squad{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       carrierv1alpha1.SquadSpec{Replicas: replicas},
+	}
+}
+
+func TestPlanPreemptions(t *testing.T) {
+	candidates := []*carrierv1alpha1.Squad{newSquad("a", 3), newSquad("b", 10), newSquad("c", 1)}
+	tests := []struct {
+		name        string
+		needed      int32
+		minReplicas int32
+		desired     map[string]int32
+	}{
+		{name: "largest first", needed: 4, desired: map[string]int32{"b": 6}},
+		{name: "spread", needed: 12, minReplicas: 1, desired: map[string]int32{"b": 1, "a": 1}},
+		{name: "all", needed: 20, desired: map[string]int32{"b": 0, "a": 0, "c": 0}},
+		{name: "none needed", needed: 0, desired: map[string]int32{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan := planPreemptions(candidates, test.needed, test.minReplicas)
+			if len(plan) != len(test.desired) {
+				t.Fatalf("desired %v preemptions, get %v", len(test.desired), plan)
+			}
+			for _, p := range plan {
+				if replicas, ok := test.desired[p.squad.Name]; !ok || replicas != p.replicas {
+					t.Errorf("Squad %v desired scaled to %v, get %v", p.squad.Name, replicas, p.replicas)
+				}
+			}
+		})
+	}
+}
+
+func TestUnschedulableSince(t *testing.T) {
+	since := metav1.NewTime(time.Now())
+	newPod := func(status corev1.ConditionStatus, reason string) *corev1.Pod {
+		return &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{
+			{Type: corev1.PodScheduled, Status: status, Reason: reason, LastTransitionTime: since},
+		}}}
+	}
+	if got := unschedulableSince(newPod(corev1.ConditionFalse, corev1.PodReasonUnschedulable)); got == nil ||
+		!got.Equal(&since) {
+		t.Errorf("desired unschedulable since %v, get %v", since, got)
+	}
+	if got := unschedulableSince(newPod(corev1.ConditionTrue, "")); got != nil {
+		t.Errorf("desired scheduled, get %v", got)
+	}
+	if got := unschedulableSince(&corev1.Pod{}); got != nil {
+		t.Errorf("desired not unschedulable without conditions, get %v", got)
+	}
+}
+
+func TestSyncSkipsWhileVictimsDrain(t *testing.T) {
+	now := time.Now()
+	high := newSquad("high", 2)
+	high.Labels = map[string]string{"tier": "high"}
+	low := newSquad("low", 10)
+	low.Labels = map[string]string{"tier": "low"}
+	// scaled has the most replicas, but they are managed by an autoscaler.
+	scaled := newSquad("scaled", 20)
+	scaled.Labels = map[string]string{"tier": "low"}
+	scaled.Annotations = map[string]string{util.SquadAutoscaledAnnotation: "true"}
+	policy := &carrierv1alpha1.PriorityPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy"},
+		Spec: carrierv1alpha1.PriorityPolicySpec{
+			HighPriority: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "high"}},
+			LowPriority:  &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "low"}},
+		},
+	}
+	newIndexer := func() cache.Indexer {
+		return cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	}
+	squads, gameServers, pods := newIndexer(), newIndexer(), newIndexer()
+	newGameServer := func(sqd *carrierv1alpha1.Squad, i int, state carrierv1alpha1.GameServerState,
+		node string) *carrierv1alpha1.GameServer {
+		return &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%v-%v", sqd.Name, i), Namespace: sqd.Namespace,
+				Labels: map[string]string{util.SquadNameLabelKey: sqd.Name}},
+			Status: carrierv1alpha1.GameServerStatus{State: state, NodeName: node},
+		}
+	}
+	for i := 0; i < 2; i++ {
+		gs := newGameServer(high, i, carrierv1alpha1.GameServerStarting, "")
+		gameServers.Add(gs)
+		pods.Add(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: gs.Name, Namespace: gs.Namespace},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{
+				Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable,
+				LastTransitionTime: metav1.NewTime(now.Add(-2 * time.Minute)),
+			}}},
+		})
+	}
+	for i := 0; i < 10; i++ {
+		gameServers.Add(newGameServer(low, i, carrierv1alpha1.GameServerRunning, "node"))
+	}
+	squads.Add(high)
+	squads.Add(low)
+	squads.Add(scaled)
+	client := fake.NewSimpleClientset(policy, high, low, scaled)
+	c := &Controller{
+		carrierClient:    client,
+		squadLister:      listerv1.NewSquadLister(squads),
+		gameServerLister: listerv1.NewGameServerLister(gameServers),
+		podLister:        corelisterv1.NewPodLister(pods),
+		recorder:         record.NewFakeRecorder(10),
+	}
+
+	if err := c.sync(policy, now); err != nil {
+		t.Fatal(err)
+	}
+	preempted, _ := client.CarrierV1alpha1().Squads(low.Namespace).Get(low.Name, metav1.GetOptions{})
+	if preempted.Spec.Replicas != 8 {
+		t.Fatalf("desired low priority Squad scaled down to 8, get: %v", preempted.Spec.Replicas)
+	}
+	squads.Update(preempted)
+	autoscaled, _ := client.CarrierV1alpha1().Squads(scaled.Namespace).Get(scaled.Name, metav1.GetOptions{})
+	if autoscaled.Spec.Replicas != 20 {
+		t.Errorf("desired autoscaled Squad not preempted, get replicas: %v", autoscaled.Spec.Replicas)
+	}
+
+	// the cooldown expires while GameServers preempted are still running, then draining.
+	for i, drain := range []bool{false, true} {
+		if drain {
+			for j := 0; j < 2; j++ {
+				gameServers.Update(newGameServer(low, j, carrierv1alpha1.GameServerExited, "node"))
+			}
+		}
+		policy, _ = client.CarrierV1alpha1().PriorityPolicies().Get(policy.Name, metav1.GetOptions{})
+		if err := c.sync(policy, now.Add(time.Duration(i+2)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		sqd, _ := client.CarrierV1alpha1().Squads(low.Namespace).Get(low.Name, metav1.GetOptions{})
+		if sqd.Spec.Replicas != 8 {
+			t.Errorf("desired no preemption while victims drain, get replicas: %v", sqd.Spec.Replicas)
+		}
+	}
+	policy, _ = client.CarrierV1alpha1().PriorityPolicies().Get(policy.Name, metav1.GetOptions{})
+	if policy.Status.PreemptedReplicas != 2 {
+		t.Errorf("desired 2 replicas preempted in total, get: %v", policy.Status.PreemptedReplicas)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// budgetReplicas returns the max replicas of squad whose requests stay within its maxResources,
+// and the resource limiting them. The budget left by the GameServers of old GameServerSets still
+// draining is shared by the new replicas and the surge GameServers of a rolling update, so the
+// requests of all GameServers stay within the budget during rollouts too. ok is false if squad
+// has no budget for the resources its template requests.
+func budgetReplicas(squad *carrierv1alpha1.Squad,
+	gsSetList []*carrierv1alpha1.GameServerSet) (replicas int32, limiting corev1.ResourceName, ok bool) {
+	requests := util.ResourceRequests(&squad.Spec.Template.Spec.Template.Spec)
+	_, oldGSSets := FindOldGameServerSets(squad, gsSetList)
+	surge := int64(MaxSurge(*squad))
+	for name, budget := range squad.Spec.MaxResources {
+		request, found := requests[name]
+		if !found || request.MilliValue() <= 0 {
+			continue
+		}
+		available := budget.MilliValue()
+		for _, gsSet := range oldGSSets {
+			oldRequest := util.ResourceRequests(&gsSet.Spec.Template.Spec.Template.Spec)[name]
+			available -= oldRequest.MilliValue() * int64(gameServerSetReplicas(gsSet))
+		}
+		n := available/request.MilliValue() - surge
+		if n < 0 {
+			n = 0
+		}
+		if n > int64(squad.Spec.Replicas) {
+			n = int64(squad.Spec.Replicas)
+		}
+		if !ok || int32(n) < replicas {
+			replicas, limiting, ok = int32(n), name, true
+		}
+	}
+	return replicas, limiting, ok
+}
+
+// gameServerSetReplicas returns the GameServers of gsSet, including the ones scaled down but
+// still draining.
+func gameServerSetReplicas(gsSet *carrierv1alpha1.GameServerSet) int32 {
+	if gsSet.Status.Replicas > gsSet.Spec.Replicas {
+		return gsSet.Status.Replicas
+	}
+	return gsSet.Spec.Replicas
+}
+
+// capReplicasByBudget returns squad with its replicas capped by its maxResources. The replicas
+// are capped in a copy only, so the replicas desired in the spec apply once the budget allows.
+// The BudgetCapped condition is set in memory, and persisted by the status sync following.
+func (c *Controller) capReplicasByBudget(squad *carrierv1alpha1.Squad,
+	gsSetList []*carrierv1alpha1.GameServerSet) *carrierv1alpha1.Squad {
+	replicas, limiting, ok := budgetReplicas(squad, gsSetList)
+	if !ok || replicas >= squad.Spec.Replicas {
+		if GetSquadCondition(squad.Status, carrierv1alpha1.SquadBudgetCapped) == nil {
+			return squad
+		}
+		squad = squad.DeepCopy()
+		RemoveSquadCondition(&squad.Status, carrierv1alpha1.SquadBudgetCapped)
+		return squad
+	}
+	budget := squad.Spec.MaxResources[limiting]
+	message := fmt.Sprintf("Replicas capped from %d to %d by maxResources %s %s, "+
+		"including %d surge and the GameServers of old GameServerSets",
+		squad.Spec.Replicas, replicas, limiting, budget.String(), MaxSurge(*squad))
+	squad = squad.DeepCopy()
+	squad.Spec.Replicas = replicas
+	if UpdateSquadCondition(&squad.Status, *NewSquadCondition(carrierv1alpha1.SquadBudgetCapped,
+		corev1.ConditionTrue, util.BudgetCappedReason, message)) {
+		c.recorder.Event(squad, corev1.EventTypeWarning, util.BudgetCappedReason, message)
+	}
+	return squad
+}
+
+// uncapped returns squad with the replicas desired in its spec instead of those capped by the
+// budget in memory, so updates of the Squad never persist the capped replicas.
+func (c *Controller) uncapped(squad *carrierv1alpha1.Squad) *carrierv1alpha1.Squad {
+	condition := GetSquadCondition(squad.Status, carrierv1alpha1.SquadBudgetCapped)
+	if condition == nil || condition.Status != corev1.ConditionTrue {
+		return squad
+	}
+	current, err := c.squadLister.Squads(squad.Namespace).Get(squad.Name)
+	if err != nil {
+		return squad
+	}
+	squadCopy := squad.DeepCopy()
+	squadCopy.Spec.Replicas = current.Spec.Replicas
+	return squadCopy
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func newBudgetSquad(replicas int) *carrierv1alpha1.Squad {
+	squad := newSquad("foo", replicas, nil, nil, nil, map[string]string{"foo": "bar"})
+	squad.Spec.Template.Spec.Template.Spec.Containers[0].Resources = corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+	}
+	return squad
+}
+
+func TestBudgetReplicas(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxResources corev1.ResourceList
+		replicas     int32
+		limiting     corev1.ResourceName
+		ok           bool
+	}{
+		{name: "no budget"},
+		{name: "not requested", maxResources: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")}},
+		{name: "cpu", maxResources: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			replicas: 4, limiting: corev1.ResourceCPU, ok: true},
+		{name: "memory from limits", maxResources: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("2"),
+			corev1.ResourceMemory: resource.MustParse("3Gi"),
+		}, replicas: 3, limiting: corev1.ResourceMemory, ok: true},
+		{name: "within budget", maxResources: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100")},
+			replicas: 10, limiting: corev1.ResourceCPU, ok: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			squad := newBudgetSquad(10)
+			squad.Spec.MaxResources = test.maxResources
+			replicas, limiting, ok := budgetReplicas(squad, nil)
+			if replicas != test.replicas || limiting != test.limiting || ok != test.ok {
+				t.Errorf("desired %v %v %v, get %v %v %v", test.replicas, test.limiting, test.ok,
+					replicas, limiting, ok)
+			}
+		})
+	}
+}
+
+func TestBudgetReplicasDuringRollout(t *testing.T) {
+	squad := newBudgetSquad(10)
+	squad.Spec.MaxResources = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("5")}
+	surge := intstr.FromInt(2)
+	squad.Spec.Strategy.RollingUpdate.MaxSurge = &surge
+	oldGSSet := newGameServerSet(squad, "old", 2)
+	oldGSSet.Spec.Template = *squad.Spec.Template.DeepCopy()
+	oldGSSet.Spec.Template.Spec.Template.Spec.Containers[0].Image = "foo/bar:old"
+	oldGSSet.Status.Replicas = 4
+	newGSSet := newGameServerSet(squad, "new", 2)
+
+	// 5 cpu, 4 draining GameServers of old template use 2, 2 surge use 1, 4 replicas left.
+	replicas, limiting, ok := budgetReplicas(squad, []*carrierv1alpha1.GameServerSet{oldGSSet, newGSSet})
+	if replicas != 4 || limiting != corev1.ResourceCPU || !ok {
+		t.Errorf("desired 4 cpu true, get %v %v %v", replicas, limiting, ok)
+	}
+	// the old GameServers are gone.
+	replicas, _, _ = budgetReplicas(squad, []*carrierv1alpha1.GameServerSet{newGSSet})
+	if replicas != 8 {
+		t.Errorf("desired 8 replicas besides the surge, get %v", replicas)
+	}
+}
+
+func TestCapReplicasByBudget(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	c := &Controller{recorder: recorder}
+	squad := newBudgetSquad(10)
+	squad.Spec.MaxResources = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}
+
+	capped := c.capReplicasByBudget(squad, nil)
+	if capped == squad || capped.Spec.Replicas != 4 || squad.Spec.Replicas != 10 {
+		t.Fatalf("desired replicas capped to 4 in a copy, get %v, desired %v", capped.Spec.Replicas,
+			squad.Spec.Replicas)
+	}
+	condition := GetSquadCondition(capped.Status, carrierv1alpha1.SquadBudgetCapped)
+	if condition == nil || condition.Status != corev1.ConditionTrue || !strings.Contains(condition.Message, "10 to 4") {
+		t.Fatalf("desired BudgetCapped condition explaining the cap, get: %+v", condition)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("desired 1 warning event, get: %v", len(recorder.Events))
+	}
+
+	// the budget is raised
+	capped.Spec.Replicas = 10
+	capped.Spec.MaxResources = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10")}
+	uncapped := c.capReplicasByBudget(capped, nil)
+	if uncapped.Spec.Replicas != 10 {
+		t.Errorf("desired replicas not capped, get %v", uncapped.Spec.Replicas)
+	}
+	if condition := GetSquadCondition(uncapped.Status, carrierv1alpha1.SquadBudgetCapped); condition != nil {
+		t.Errorf("desired condition removed, get: %+v", condition)
+	}
+}
@@ -33,6 +33,16 @@ func (c *Controller) rolloutCanary(squad *carrierv1alpha1.Squad, gsSetList []*ca
 	if squad.Spec.Strategy.CanaryUpdate == nil {
 		return errors.Errorf("Squad %v CanaryUpdate is null", squad.ObjectMeta)
 	}
+	if newGSSet := FindNewGameServerSet(squad, gsSetList); newGSSet != nil {
+		newGSSet, aborted, err := c.checkMetricGate(squad, newGSSet)
+		if err != nil {
+			return err
+		}
+		if aborted {
+			_, oldGSSets := FindOldGameServerSets(squad, gsSetList)
+			return c.abortCanary(squad, newGSSet, oldGSSets)
+		}
+	}
 	switch squad.Spec.Strategy.CanaryUpdate.Type {
 	case carrierv1alpha1.CreateFirstGameServerStrategyType:
 		return c.createFirst(squad, gsSetList)
@@ -71,6 +81,9 @@ func (c *Controller) createFirst(squad *carrierv1alpha1.Squad, gsSetList []*carr
 		return c.syncRolloutStatus(allGSSets, newGSSet, squad)
 	}
 	if SquadComplete(squad, &squad.Status) {
+		if err := c.shiftTraffic(squad, newGSSet, oldGSSets); err != nil {
+			return err
+		}
 		if err := c.cleanupSquad(oldGSSets, squad); err != nil {
 			return err
 		}
@@ -115,6 +128,9 @@ func (c *Controller) deleteFirst(squad *carrierv1alpha1.Squad, gsSetList []*carr
 		return err
 	}
 	if SquadComplete(squad, &squad.Status) {
+		if err := c.shiftTraffic(squad, newGSSet, oldGSSets); err != nil {
+			return err
+		}
 		if err := c.cleanupSquad(oldGSSets, squad); err != nil {
 			return err
 		}
@@ -177,6 +193,10 @@ func (c *Controller) scaleDownOldGameServerSetsForCanary(
 			newGSSet.Name)
 		return false, nil
 	}
+	// shift new sessions to the ready GameServers before scaling down the old ones.
+	if err := c.shiftTraffic(squad, newGSSet, oldGSSets); err != nil {
+		return false, err
+	}
 
 	allOldGameServersCount := GetReplicaCountForGameServerSets(oldGSSets)
 	klog.V(4).Infof("%d old GameServer for squad: %v", allOldGameServersCount, squad.ObjectMeta)
@@ -29,9 +29,11 @@ import (
 	"k8s.io/apimachinery/pkg/util/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
@@ -42,26 +44,42 @@ import (
 	getterv1alpha1 "github.com/ocgi/carrier/pkg/client/clientset/versioned/typed/carrier/v1alpha1"
 	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
 	listerv1alpha1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/metrics"
 	"github.com/ocgi/carrier/pkg/util"
 )
 
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=fleetprofiles,verbs=get
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameserversets,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=squads,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=squads/status,verbs=update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
 // Controller is a the GameServerSet controller
 type Controller struct {
 	crdGetter           v1beta1.CustomResourceDefinitionInterface
+	gameServerGetter    getterv1alpha1.GameServersGetter
 	gameServerLister    listerv1alpha1.GameServerLister
 	gameServerSetGetter getterv1alpha1.GameServerSetsGetter
 	gameServerSetLister listerv1alpha1.GameServerSetLister
 	gameServerSetSynced cache.InformerSynced
 	squadGetter         getterv1alpha1.SquadsGetter
 	squadLister         listerv1alpha1.SquadLister
+	squadIndexer        cache.Indexer
 	squadSynced         cache.InformerSynced
 	workerQueue         workqueue.RateLimitingInterface
 	recorder            record.EventRecorder
+	secretLister        corelisterv1.SecretLister
+	secretSynced        cache.InformerSynced
+	rollouts            *rolloutTracker
+	metricGates         *gateTracker
 }
 
 // NewController returns a new squads crd controller
 func NewController(
 	kubeClient kubernetes.Interface,
+	kubeInformerFactory informers.SharedInformerFactory,
 	carrierClient versioned.Interface,
 	carrierInformerFactory externalversions.SharedInformerFactory) *Controller {
 
@@ -71,15 +89,25 @@ func NewController(
 
 	squads := carrierInformerFactory.Carrier().V1alpha1().Squads()
 	squadsInformer := squads.Informer()
+	secrets := kubeInformerFactory.Core().V1().Secrets()
 
 	c := &Controller{
+		gameServerGetter:    carrierClient.CarrierV1alpha1(),
 		gameServerLister:    gameServers.Lister(),
 		gameServerSetGetter: carrierClient.CarrierV1alpha1(),
 		gameServerSetLister: gameServerSets.Lister(),
 		gameServerSetSynced: gsSetInformer.HasSynced,
 		squadGetter:         carrierClient.CarrierV1alpha1(),
 		squadLister:         squads.Lister(),
+		squadIndexer:        squadsInformer.GetIndexer(),
 		squadSynced:         squadsInformer.HasSynced,
+		secretLister:        secrets.Lister(),
+		secretSynced:        secrets.Informer().HasSynced,
+		rollouts:            newRolloutTracker(),
+		metricGates:         newGateTracker(),
+	}
+	if err := squadsInformer.AddIndexers(cache.Indexers{dependsOnIndex: dependsOnIndexFunc}); err != nil {
+		klog.Fatalf("Failed to add Squad indexers: %v", err)
 	}
 	c.workerQueue = workqueue.NewRateLimitingQueue(
 		workqueue.NewItemFastSlowRateLimiter(20*time.Millisecond, 500*time.Millisecond, 5))
@@ -106,6 +134,16 @@ func NewController(
 		DeleteFunc: c.deleteGameServerSet,
 	})
 
+	gameServers.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: isPreflightGameServer,
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc: c.preflightGameServerEventHandler,
+			UpdateFunc: func(_, newObj interface{}) {
+				c.preflightGameServerEventHandler(newObj)
+			},
+		},
+	})
+
 	return c
 }
 
@@ -113,7 +151,7 @@ func NewController(
 // Runs threadiness number workers to process the rate limited queue
 func (c *Controller) Run(workers int, stop <-chan struct{}) error {
 	klog.V(4).Info("Wait for cache sync")
-	if !cache.WaitForCacheSync(stop, c.squadSynced, c.gameServerSetSynced) {
+	if !cache.WaitForCacheSync(stop, c.squadSynced, c.gameServerSetSynced, c.secretSynced) {
 		return errors.New("failed to wait for caches to sync")
 	}
 	for i := 0; i < workers; i++ {
@@ -126,6 +164,9 @@ func (c *Controller) Run(workers int, stop <-chan struct{}) error {
 // obj could be a Squad, or a DeletionFinalStateUnknown marker item.
 func (c *Controller) updateGameSquad(old, cur interface{}) {
 	c.enqueueGameSquad(cur)
+	if squad, ok := cur.(*carrierv1alpha1.Squad); ok {
+		c.enqueueDependents(squad)
+	}
 }
 
 // obj could be a GamServerSet, or a DeletionFinalStateUnknown marker item.
@@ -180,6 +221,7 @@ func (c *Controller) processNextWorkItem() bool {
 func (c *Controller) syncSquad(key string) error {
 	startTime := time.Now()
 	klog.V(4).Infof("Started syncing Squad %q (%v)", key, startTime)
+	metrics.RecordControllerSync("squad")
 	defer func() {
 		klog.V(4).Infof("Finished syncing Squad %q (%v)", key, time.Since(startTime))
 	}()
@@ -210,6 +252,7 @@ func (c *Controller) syncSquad(key string) error {
 	if err != nil {
 		return err
 	}
+	squad = c.capReplicasByBudget(squad, gsSetList)
 	// List all GameServers owned by this Squad
 	gsMap, err := c.getGameServerMapForSquad(squad, gsSetList)
 	if err != nil {
@@ -240,6 +283,17 @@ func (c *Controller) syncSquad(key string) error {
 		return c.sync(squad, gsSetList)
 	}
 
+	if !c.checkDependencies(squad, gsSetList) {
+		return c.syncStatusOnly(squad, gsSetList)
+	}
+	passed, err := c.checkPreflight(squad, gsSetList)
+	if err != nil {
+		return err
+	}
+	if !passed {
+		return c.syncStatusOnly(squad, gsSetList)
+	}
+
 	switch squad.Spec.Strategy.Type {
 	case carrierv1alpha1.RecreateSquadStrategyType:
 		return c.rolloutRecreate(squad, gsSetList, gsMap)
@@ -357,6 +411,28 @@ func (c *Controller) resolveControllerRef(namespace string, controllerRef *metav
 	return squad
 }
 
+// isPreflightGameServer checks if obj is a preflight GameServer.
+func isPreflightGameServer(obj interface{}) bool {
+	gs, ok := obj.(*carrierv1alpha1.GameServer)
+	if !ok {
+		return false
+	}
+	_, ok = gs.Labels[util.PreflightLabelKey]
+	return ok
+}
+
+// preflightGameServerEventHandler enqueues the Squad checked by the preflight GameServer.
+func (c *Controller) preflightGameServerEventHandler(obj interface{}) {
+	gs := obj.(*carrierv1alpha1.GameServer)
+	ref := metav1.GetControllerOf(gs)
+	if ref == nil {
+		return
+	}
+	if squad := c.resolveControllerRef(gs.Namespace, ref); squad != nil {
+		c.enqueueGameSquad(squad)
+	}
+}
+
 func (c *Controller) ensureDefaults(squad *carrierv1alpha1.Squad) {
 	// setting revision history limit
 	if squad.Spec.RevisionHistoryLimit == nil {
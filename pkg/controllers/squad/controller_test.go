@@ -164,6 +164,8 @@ func (f *fixture) newController() (*Controller, externalversions.SharedInformerF
 		squadLister:         squads.Lister(),
 		squadSynced:         alwaysReady,
 		recorder:            &record.FakeRecorder{},
+		rollouts:            newRolloutTracker(),
+		metricGates:         newGateTracker(),
 	}
 	for _, squad := range f.squadLister {
 		squadsInformer.GetIndexer().Add(squad)
@@ -280,6 +282,7 @@ func TestSyncSquadCreatesGameServerSet(t *testing.T) {
 	gsSet := newGameServerSet(squad, "gsSet", 1)
 
 	f.expectCreateGameServerSetAction(gsSet)
+	f.expectUpdateSquadAction(squad)
 	f.expectUpdateSquadStatusAction(squad)
 
 	f.run(getKey(squad, t))
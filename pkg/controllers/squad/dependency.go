@@ -0,0 +1,134 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// dependsOnIndex indexes Squads by the namespace/name keys of the Squads they depend on.
+const dependsOnIndex = "dependsOn"
+
+// dependsOnIndexFunc returns the keys of the Squads obj depends on.
+func dependsOnIndexFunc(obj interface{}) ([]string, error) {
+	squad, ok := obj.(*carrierv1alpha1.Squad)
+	if !ok {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(squad.Spec.DependsOn))
+	for _, ref := range squad.Spec.DependsOn {
+		keys = append(keys, squad.Namespace+"/"+ref.Name)
+	}
+	return keys, nil
+}
+
+// rolledOut checks if the controller observed the latest spec of squad, and all its replicas
+// are of the current template. Readiness is not required, so GameServers restarting or
+// exiting with their sessions do not hold the rollouts of dependents back.
+func rolledOut(squad *carrierv1alpha1.Squad) bool {
+	return squad.Status.ObservedGeneration >= squad.Generation &&
+		squad.Status.UpdatedReplicas >= squad.Spec.Replicas
+}
+
+// checkDependencies returns true if the rollout of squad could start, which is once the Squads
+// it depends on have rolled out. The first GameServerSet of squad, and templates already rolled
+// out, are not delayed. The WaitingForDependencies condition of squad is set in memory, and
+// persisted by the status sync following.
+func (c *Controller) checkDependencies(
+	squad *carrierv1alpha1.Squad,
+	gsSetList []*carrierv1alpha1.GameServerSet) bool {
+	if len(squad.Spec.DependsOn) == 0 || len(gsSetList) == 0 || FindNewGameServerSet(squad, gsSetList) != nil {
+		RemoveSquadCondition(&squad.Status, carrierv1alpha1.SquadWaitingForDependencies)
+		return true
+	}
+	var waiting []string
+	for _, ref := range squad.Spec.DependsOn {
+		dependency, err := c.squadLister.Squads(squad.Namespace).Get(ref.Name)
+		switch {
+		case k8serrors.IsNotFound(err):
+			waiting = append(waiting, ref.Name+" (not found)")
+		case err != nil:
+			waiting = append(waiting, fmt.Sprintf("%s (%v)", ref.Name, err))
+		case !rolledOut(dependency):
+			waiting = append(waiting, ref.Name)
+		}
+	}
+	if len(waiting) == 0 {
+		RemoveSquadCondition(&squad.Status, carrierv1alpha1.SquadWaitingForDependencies)
+		return true
+	}
+	message := fmt.Sprintf("Rollout waits for Squads %s to roll out", strings.Join(waiting, ", "))
+	if cycle := c.dependencyCycle(squad); len(cycle) != 0 {
+		message += fmt.Sprintf(", which never happens in dependency cycle %s", strings.Join(cycle, " -> "))
+	}
+	if UpdateSquadCondition(&squad.Status, *NewSquadCondition(carrierv1alpha1.SquadWaitingForDependencies,
+		corev1.ConditionTrue, util.WaitingForDependenciesReason, message)) {
+		c.recorder.Event(squad, corev1.EventTypeNormal, util.WaitingForDependenciesReason, message)
+	}
+	return false
+}
+
+// dependencyCycle returns the names of Squads in the dependency cycle through squad, nil if none.
+func (c *Controller) dependencyCycle(squad *carrierv1alpha1.Squad) []string {
+	visited := make(map[string]bool)
+	var walk func(name string, path []string) []string
+	walk = func(name string, path []string) []string {
+		if name == squad.Name && len(path) != 0 {
+			return append(path, name)
+		}
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+		current := squad
+		if name != squad.Name {
+			var err error
+			if current, err = c.squadLister.Squads(squad.Namespace).Get(name); err != nil {
+				return nil
+			}
+		}
+		for _, ref := range current.Spec.DependsOn {
+			if cycle := walk(ref.Name, append(path, name)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return walk(squad.Name, nil)
+}
+
+// enqueueDependents enqueues the Squads depending on squad once it rolled out, so their
+// rollouts waiting for squad could start.
+func (c *Controller) enqueueDependents(squad *carrierv1alpha1.Squad) {
+	if !rolledOut(squad) {
+		return
+	}
+	dependents, err := c.squadIndexer.ByIndex(dependsOnIndex, squad.Namespace+"/"+squad.Name)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, dependent := range dependents {
+		c.enqueueGameSquad(dependent)
+	}
+}
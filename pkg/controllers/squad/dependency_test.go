@@ -0,0 +1,127 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	carrierfake "github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+)
+
+func TestCheckDependencies(t *testing.T) {
+	newDependency := func(name string, complete bool, dependsOn ...string) *carrierv1alpha1.Squad {
+		squad := newSquad(name, 2, nil, nil, nil, map[string]string{"app": name})
+		if complete {
+			squad.Status.Replicas, squad.Status.UpdatedReplicas, squad.Status.ReadyReplicas = 2, 2, 2
+		}
+		for _, dependency := range dependsOn {
+			squad.Spec.DependsOn = append(squad.Spec.DependsOn, corev1.LocalObjectReference{Name: dependency})
+		}
+		return squad
+	}
+	squads := externalversions.NewSharedInformerFactory(carrierfake.NewSimpleClientset(), 0).
+		Carrier().V1alpha1().Squads()
+	// updated but not all ready
+	voice := newDependency("voice", false)
+	voice.Status.Replicas, voice.Status.UpdatedReplicas, voice.Status.ReadyReplicas = 2, 2, 1
+	// new spec not observed yet
+	store := newDependency("store", true)
+	store.Generation, store.Status.ObservedGeneration = 2, 1
+	for _, squad := range []*carrierv1alpha1.Squad{
+		newDependency("match", true),
+		newDependency("chat", false),
+		newDependency("gateway", false, "lobby"),
+		voice,
+		store,
+	} {
+		squads.Informer().GetIndexer().Add(squad)
+	}
+	c := &Controller{squadLister: squads.Lister(), recorder: &record.FakeRecorder{}}
+
+	tests := []struct {
+		name      string
+		dependsOn []string
+		rolledOut bool
+		passed    bool
+		message   string
+	}{
+		{name: "no dependencies", passed: true},
+		{name: "completed", dependsOn: []string{"match"}, passed: true},
+		{name: "updated", dependsOn: []string{"voice"}, passed: true},
+		{name: "not observed", dependsOn: []string{"store"}, message: "store"},
+		{name: "rolled out", dependsOn: []string{"chat"}, rolledOut: true, passed: true},
+		{name: "waiting", dependsOn: []string{"match", "chat", "missing"}, message: "chat, missing (not found)"},
+		{name: "cycle", dependsOn: []string{"gateway"}, message: "lobby -> gateway -> lobby"},
+	}
+	for _, tc := range tests {
+		squad := newDependency("lobby", false, tc.dependsOn...)
+		oldGSSet := newGameServerSet(squad, "lobby-1", 2)
+		oldGSSet.Spec.Template = *squad.Spec.Template.DeepCopy()
+		if !tc.rolledOut {
+			squad.Spec.Template.Spec.Template.Spec.Containers[0].Image = "game:v2"
+		}
+		passed := c.checkDependencies(squad, []*carrierv1alpha1.GameServerSet{oldGSSet})
+		if passed != tc.passed {
+			t.Errorf("%v: desired passed: %v, get: %v", tc.name, tc.passed, passed)
+		}
+		cond := GetSquadCondition(squad.Status, carrierv1alpha1.SquadWaitingForDependencies)
+		if tc.passed != (cond == nil) {
+			t.Errorf("%v: desired condition set: %v, get: %+v", tc.name, !tc.passed, cond)
+		}
+		if cond != nil && !strings.Contains(cond.Message, tc.message) {
+			t.Errorf("%v: desired message containing %q, get: %q", tc.name, tc.message, cond.Message)
+		}
+	}
+}
+
+func TestEnqueueDependents(t *testing.T) {
+	squads := externalversions.NewSharedInformerFactory(carrierfake.NewSimpleClientset(), 0).
+		Carrier().V1alpha1().Squads()
+	indexer := squads.Informer().GetIndexer()
+	if err := indexer.AddIndexers(cache.Indexers{dependsOnIndex: dependsOnIndexFunc}); err != nil {
+		t.Fatal(err)
+	}
+	match := newSquad("match", 2, nil, nil, nil, map[string]string{"app": "match"})
+	lobby := newSquad("lobby", 2, nil, nil, nil, map[string]string{"app": "lobby"})
+	lobby.Spec.DependsOn = []corev1.LocalObjectReference{{Name: "match"}}
+	chat := newSquad("chat", 2, nil, nil, nil, map[string]string{"app": "chat"})
+	for _, squad := range []*carrierv1alpha1.Squad{match, lobby, chat} {
+		indexer.Add(squad)
+	}
+	c := &Controller{squadIndexer: indexer, workerQueue: workqueue.NewRateLimitingQueue(
+		workqueue.NewItemFastSlowRateLimiter(0, 0, 5))}
+	defer c.workerQueue.ShutDown()
+
+	c.enqueueDependents(match)
+	if c.workerQueue.Len() != 0 {
+		t.Fatalf("desired no dependents enqueued before match rolls out, get %v", c.workerQueue.Len())
+	}
+	match.Status.UpdatedReplicas = 2
+	c.enqueueDependents(match)
+	if c.workerQueue.Len() != 1 {
+		t.Fatalf("desired lobby enqueued, get %v", c.workerQueue.Len())
+	}
+	if key, _ := c.workerQueue.Get(); key != lobby.Namespace+"/"+lobby.Name {
+		t.Errorf("desired lobby enqueued, get %v", key)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+const (
+	defaultMetricGateInterval = 30 * time.Second
+	defaultMetricGateTimeout  = 10 * time.Second
+)
+
+// gateTracker remembers when the metric gates were evaluated last time.
+type gateTracker struct {
+	sync.Mutex
+	checked map[string]time.Time
+}
+
+func newGateTracker() *gateTracker {
+	return &gateTracker{checked: make(map[string]time.Time)}
+}
+
+// due returns true and records now if the gate has not been evaluated within interval.
+func (t *gateTracker) due(key string, now time.Time, interval time.Duration) bool {
+	t.Lock()
+	defer t.Unlock()
+	if checked, ok := t.checked[key]; ok && now.Sub(checked) < interval {
+		return false
+	}
+	t.checked[key] = now
+	return true
+}
+
+// forget stops tracking the gate.
+func (t *gateTracker) forget(key string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.checked, key)
+}
+
+// checkMetricGate evaluates the metric gate of canary update against newGSSet, and marks
+// newGSSet aborted if the gate is breached. Returns the latest newGSSet and true if its
+// rollout is aborted. Failures to evaluate the gate are reported as events and do not
+// abort the rollout.
+func (c *Controller) checkMetricGate(
+	squad *carrierv1alpha1.Squad,
+	newGSSet *carrierv1alpha1.GameServerSet) (*carrierv1alpha1.GameServerSet, bool, error) {
+	if isCanaryAborted(newGSSet) {
+		return newGSSet, true, nil
+	}
+	gate := squad.Spec.Strategy.CanaryUpdate.MetricGate
+	key := rolloutKey(squad, newGSSet)
+	if gate == nil || SquadComplete(squad, &squad.Status) {
+		c.metricGates.forget(key)
+		return newGSSet, false, nil
+	}
+	interval := defaultMetricGateInterval
+	if gate.IntervalSeconds != nil {
+		interval = time.Duration(*gate.IntervalSeconds) * time.Second
+	}
+	if !c.metricGates.due(key, time.Now(), interval) {
+		return newGSSet, false, nil
+	}
+	// keep evaluating the gate even if nothing of the Squad changes.
+	if squadKey, err := cache.MetaNamespaceKeyFunc(squad); err == nil {
+		c.workerQueue.AddAfter(squadKey, interval)
+	}
+	value, err := queryMetricGate(gate)
+	if err != nil {
+		c.recorder.Eventf(squad, corev1.EventTypeWarning, util.FailedMetricGateReason,
+			"Failed to evaluate metric gate of %s: %v", newGSSet.Name, err)
+		return newGSSet, false, nil
+	}
+	threshold := float64(gate.Threshold.MilliValue()) / 1000
+	klog.V(4).Infof("Metric gate of squad %v/%v: %v, threshold: %v", squad.Namespace, squad.Name, value, threshold)
+	if value <= threshold {
+		return newGSSet, false, nil
+	}
+	message := fmt.Sprintf("%v exceeds threshold %v", value, gate.Threshold.String())
+	gsSetCopy := newGSSet.DeepCopy()
+	if gsSetCopy.Annotations == nil {
+		gsSetCopy.Annotations = make(map[string]string)
+	}
+	gsSetCopy.Annotations[util.CanaryAbortedAnnotation] = message
+	newGSSet, err = c.gameServerSetGetter.GameServerSets(gsSetCopy.Namespace).Update(gsSetCopy)
+	if err != nil {
+		return nil, false, err
+	}
+	c.metricGates.forget(key)
+	c.recorder.Eventf(squad, corev1.EventTypeWarning, util.CanaryAbortedReason,
+		"Aborted canary update of %s, metric gate %q: %s", newGSSet.Name, gate.Query, message)
+	return newGSSet, true, nil
+}
+
+// abortCanary scales newGSSet to zero and restores the replicas of the latest old GameServerSet.
+func (c *Controller) abortCanary(
+	squad *carrierv1alpha1.Squad,
+	newGSSet *carrierv1alpha1.GameServerSet,
+	oldGSSets []*carrierv1alpha1.GameServerSet) error {
+	allGSSets := append(oldGSSets, newGSSet)
+	scaled, _, err := c.scaleGameServerSetAndRecordEvent(newGSSet, 0, squad)
+	if err != nil {
+		return err
+	}
+	if scaled || len(oldGSSets) == 0 {
+		return c.syncRolloutStatus(allGSSets, newGSSet, squad)
+	}
+	if err := c.shiftTraffic(squad, newGSSet, oldGSSets); err != nil {
+		return err
+	}
+	sorted := make([]*carrierv1alpha1.GameServerSet, len(oldGSSets))
+	copy(sorted, oldGSSets)
+	sort.Sort(GameServerSetsByCreationTimestamp(sorted))
+	latest := sorted[len(sorted)-1]
+	desired := squad.Spec.Replicas - (GetReplicaCountForGameServerSets(sorted) - latest.Spec.Replicas)
+	if desired > latest.Spec.Replicas {
+		if _, _, err := c.scaleGameServerSetAndRecordEvent(latest, desired, squad); err != nil {
+			return err
+		}
+	}
+	return c.syncRolloutStatus(allGSSets, newGSSet, squad)
+}
+
+// isCanaryAborted checks if the canary update of GameServerSet is aborted by the metric gate.
+func isCanaryAborted(gsSet *carrierv1alpha1.GameServerSet) bool {
+	_, ok := gsSet.Annotations[util.CanaryAbortedAnnotation]
+	return ok
+}
+
+// promQueryResponse is the response of prometheus instant query api.
+type promQueryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// queryMetricGate runs the query of gate against prometheus and returns the first sample.
+func queryMetricGate(gate *carrierv1alpha1.MetricGate) (float64, error) {
+	timeout := defaultMetricGateTimeout
+	if gate.TimeoutSeconds != nil {
+		timeout = time.Duration(*gate.TimeoutSeconds) * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(gate.Address + "/api/v1/query?query=" + url.QueryEscape(gate.Query))
+	if err != nil {
+		return 0, errors.Wrap(err, "error querying prometheus")
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.Errorf("prometheus returned %v: %s", resp.StatusCode, body)
+	}
+	return parsePromQueryResponse(body)
+}
+
+// parsePromQueryResponse returns the value of the first sample of a vector or scalar result.
+func parsePromQueryResponse(body []byte) (float64, error) {
+	response := &promQueryResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		return 0, errors.Wrap(err, "could not decode prometheus response")
+	}
+	if response.Status != "success" {
+		return 0, errors.Errorf("prometheus query failed: %v", response.Error)
+	}
+	var sample []interface{}
+	switch response.Data.ResultType {
+	case "scalar":
+		if err := json.Unmarshal(response.Data.Result, &sample); err != nil {
+			return 0, errors.Wrap(err, "could not decode scalar result")
+		}
+	case "vector":
+		var vector []struct {
+			Value []interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(response.Data.Result, &vector); err != nil {
+			return 0, errors.Wrap(err, "could not decode vector result")
+		}
+		if len(vector) == 0 {
+			return 0, errors.New("query returned no data")
+		}
+		sample = vector[0].Value
+	default:
+		return 0, errors.Errorf("unsupported result type %q", response.Data.ResultType)
+	}
+	if len(sample) != 2 {
+		return 0, errors.Errorf("invalid sample %v", sample)
+	}
+	value, ok := sample[1].(string)
+	if !ok {
+		return 0, errors.Errorf("invalid sample value %v", sample[1])
+	}
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid sample value %v", value)
+	}
+	if math.IsNaN(result) {
+		return 0, errors.New("query returned NaN")
+	}
+	return result, nil
+}
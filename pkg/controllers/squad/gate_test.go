@@ -0,0 +1,114 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestParsePromQueryResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		value   float64
+		wantErr bool
+	}{
+		{
+			name:  "vector",
+			body:  `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1622534400,"0.25"]}]}}`,
+			value: 0.25,
+		},
+		{
+			name:  "scalar",
+			body:  `{"status":"success","data":{"resultType":"scalar","result":[1622534400,"3"]}}`,
+			value: 3,
+		},
+		{
+			name:    "empty vector",
+			body:    `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			wantErr: true,
+		},
+		{
+			name:    "NaN",
+			body:    `{"status":"success","data":{"resultType":"scalar","result":[1622534400,"NaN"]}}`,
+			wantErr: true,
+		},
+		{
+			name:    "error",
+			body:    `{"status":"error","error":"parse error"}`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := parsePromQueryResponse([]byte(tc.body))
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("desired error: %v, get: %v", tc.wantErr, err)
+			}
+			if value != tc.value {
+				t.Errorf("desired value %v, get: %v", tc.value, value)
+			}
+		})
+	}
+}
+
+func TestQueryMetricGate(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1622534400,"0.5"]}}`))
+	}))
+	defer server.Close()
+	gate := &carrierv1alpha1.MetricGate{
+		Address:   server.URL,
+		Query:     `sum(rate(errors_total{version="v2"}[5m]))`,
+		Threshold: resource.MustParse("0.1"),
+	}
+	value, err := queryMetricGate(gate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != 0.5 {
+		t.Errorf("desired value 0.5, get: %v", value)
+	}
+	if query != gate.Query {
+		t.Errorf("desired query %v, get: %v", gate.Query, query)
+	}
+}
+
+func TestGateTracker(t *testing.T) {
+	tracker := newGateTracker()
+	now := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	if !tracker.due("default/squad/abc", now, time.Minute) {
+		t.Errorf("desired gate due at first evaluation")
+	}
+	if tracker.due("default/squad/abc", now.Add(30*time.Second), time.Minute) {
+		t.Errorf("desired gate not due within interval")
+	}
+	if !tracker.due("default/squad/abc", now.Add(time.Minute), time.Minute) {
+		t.Errorf("desired gate due after interval")
+	}
+	tracker.forget("default/squad/abc")
+	if len(tracker.checked) != 0 {
+		t.Errorf("desired no gate tracked, get: %v", tracker.checked)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// setKStatusConditions sets the Ready, Reconciling and Stalled conditions of newStatus following
+// kstatus conventions, so that GitOps tools are able to report the health of Squad.
+func setKStatusConditions(
+	squad *carrierv1alpha1.Squad,
+	newGSSet *carrierv1alpha1.GameServerSet,
+	newStatus *carrierv1alpha1.SquadStatus) {
+	reason, message, stalled := squadStalled(newGSSet, newStatus)
+	if stalled {
+		SetSquadCondition(newStatus, *NewSquadCondition(carrierv1alpha1.SquadStalled,
+			corev1.ConditionTrue, reason, message))
+	} else {
+		RemoveSquadCondition(newStatus, carrierv1alpha1.SquadStalled)
+	}
+
+	if !stalled && SquadComplete(squad, newStatus) {
+		RemoveSquadCondition(newStatus, carrierv1alpha1.SquadReconciling)
+		SetSquadCondition(newStatus, *NewSquadCondition(carrierv1alpha1.SquadReady,
+			corev1.ConditionTrue, util.GameServersReadyReason,
+			fmt.Sprintf("%v GameServers are updated and ready", newStatus.ReadyReplicas)))
+		return
+	}
+
+	if !stalled && canaryHeld(squad, newStatus) {
+		RemoveSquadCondition(newStatus, carrierv1alpha1.SquadReconciling)
+		SetSquadCondition(newStatus, *NewSquadCondition(carrierv1alpha1.SquadReady,
+			corev1.ConditionTrue, util.CanaryHeldReason,
+			fmt.Sprintf("Canary update is held at %v of %v GameServers, all are ready",
+				newStatus.UpdatedReplicas, squad.Spec.Replicas)))
+		return
+	}
+
+	message = fmt.Sprintf("%v of %v GameServers are updated, %v are ready",
+		newStatus.UpdatedReplicas, squad.Spec.Replicas, newStatus.ReadyReplicas)
+	reason = util.GameServersProgressingReason
+	if squad.Spec.Paused {
+		reason = util.PausedDeployReason
+	}
+	if stalled || squad.Spec.Paused {
+		RemoveSquadCondition(newStatus, carrierv1alpha1.SquadReconciling)
+	} else {
+		SetSquadCondition(newStatus, *NewSquadCondition(carrierv1alpha1.SquadReconciling,
+			corev1.ConditionTrue, reason, message))
+	}
+	SetSquadCondition(newStatus, *NewSquadCondition(carrierv1alpha1.SquadReady,
+		corev1.ConditionFalse, reason, message))
+}
+
+// squadStalled checks if the Squad could not make progress without intervention, and
+// returns the reason and message.
+func squadStalled(
+	newGSSet *carrierv1alpha1.GameServerSet,
+	newStatus *carrierv1alpha1.SquadStatus) (string, string, bool) {
+	if newGSSet != nil && isCanaryAborted(newGSSet) {
+		return util.CanaryAbortedReason, fmt.Sprintf("Canary update of GameServerSet %q is aborted: %v",
+			newGSSet.Name, newGSSet.Annotations[util.CanaryAbortedAnnotation]), true
+	}
+	if newGSSet != nil {
+		for _, cond := range newGSSet.Status.Conditions {
+			if cond.Type == carrierv1alpha1.GameServerSetTemplateFaulty && cond.Status == corev1.ConditionTrue {
+				return cond.Reason, fmt.Sprintf("GameServerSet %q: %v", newGSSet.Name, cond.Message), true
+			}
+		}
+	}
+	cond := GetSquadCondition(*newStatus, carrierv1alpha1.SquadReplicaFailure)
+	if cond != nil && cond.Status == corev1.ConditionTrue {
+		return cond.Reason, cond.Message, true
+	}
+	cond = GetSquadCondition(*newStatus, carrierv1alpha1.SquadPreflight)
+	if cond != nil && cond.Status == corev1.ConditionFalse {
+		return cond.Reason, cond.Message, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestSetKStatusConditions(t *testing.T) {
+	aborted := &carrierv1alpha1.GameServerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "squad-abc",
+			Annotations: map[string]string{util.CanaryAbortedAnnotation: "error_rate=0.5"},
+		},
+	}
+	failure := *NewSquadCondition(carrierv1alpha1.SquadReplicaFailure, corev1.ConditionTrue,
+		"FailedCreate", "quota exceeded")
+	for _, testCase := range []struct {
+		name        string
+		paused      bool
+		newGSSet    *carrierv1alpha1.GameServerSet
+		status      carrierv1alpha1.SquadStatus
+		ready       corev1.ConditionStatus
+		reconciling bool
+		stalled     bool
+	}{
+		{
+			name:   "complete",
+			status: carrierv1alpha1.SquadStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 2},
+			ready:  corev1.ConditionTrue,
+		},
+		{
+			name:        "rolling out",
+			status:      carrierv1alpha1.SquadStatus{Replicas: 3, UpdatedReplicas: 1, ReadyReplicas: 2},
+			ready:       corev1.ConditionFalse,
+			reconciling: true,
+		},
+		{
+			name:   "paused",
+			paused: true,
+			status: carrierv1alpha1.SquadStatus{Replicas: 3, UpdatedReplicas: 1, ReadyReplicas: 2},
+			ready:  corev1.ConditionFalse,
+		},
+		{
+			name:     "canary aborted",
+			newGSSet: aborted,
+			status:   carrierv1alpha1.SquadStatus{Replicas: 2, UpdatedReplicas: 0, ReadyReplicas: 2},
+			ready:    corev1.ConditionFalse,
+			stalled:  true,
+		},
+		{
+			name: "replica failure",
+			status: carrierv1alpha1.SquadStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 2,
+				Conditions: []carrierv1alpha1.SquadCondition{failure}},
+			ready:   corev1.ConditionFalse,
+			stalled: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			squad := &carrierv1alpha1.Squad{
+				ObjectMeta: metav1.ObjectMeta{Name: "squad", Generation: 1},
+				Spec:       carrierv1alpha1.SquadSpec{Replicas: 2, Paused: testCase.paused},
+			}
+			status := testCase.status
+			status.ObservedGeneration = 1
+			setKStatusConditions(squad, testCase.newGSSet, &status)
+			ready := GetSquadCondition(status, carrierv1alpha1.SquadReady)
+			if ready == nil || ready.Status != testCase.ready {
+				t.Errorf("desired Ready %v, get: %+v", testCase.ready, ready)
+			}
+			reconciling := GetSquadCondition(status, carrierv1alpha1.SquadReconciling)
+			if (reconciling != nil) != testCase.reconciling {
+				t.Errorf("desired Reconciling %v, get: %+v", testCase.reconciling, reconciling)
+			}
+			stalled := GetSquadCondition(status, carrierv1alpha1.SquadStalled)
+			if (stalled != nil) != testCase.stalled {
+				t.Errorf("desired Stalled %v, get: %+v", testCase.stalled, stalled)
+			}
+		})
+	}
+}
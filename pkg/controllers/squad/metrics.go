@@ -0,0 +1,98 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"sync"
+	"time"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/metrics"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// rolloutTracker remembers when the rollouts in progress started.
+type rolloutTracker struct {
+	sync.Mutex
+	started map[string]time.Time
+}
+
+func newRolloutTracker() *rolloutTracker {
+	return &rolloutTracker{started: make(map[string]time.Time)}
+}
+
+// start records the start time of rollout if it is not tracked yet.
+func (t *rolloutTracker) start(key string, now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	if _, ok := t.started[key]; !ok {
+		t.started[key] = now
+	}
+}
+
+// finish stops tracking the rollout and returns its duration,
+// ok is false if the rollout is not tracked.
+func (t *rolloutTracker) finish(key string, now time.Time) (time.Duration, bool) {
+	t.Lock()
+	defer t.Unlock()
+	started, ok := t.started[key]
+	if !ok {
+		return 0, false
+	}
+	delete(t.started, key)
+	return now.Sub(started), true
+}
+
+// rolloutKey identifies a rollout by Squad and the template hash of its new GameServerSet,
+// in place updates reuse the GameServerSet but change the hash.
+func rolloutKey(squad *carrierv1alpha1.Squad, newGSSet *carrierv1alpha1.GameServerSet) string {
+	return squad.Namespace + "/" + squad.Name + "/" + newGSSet.Labels[util.GameServerHash]
+}
+
+// recordRolloutMetrics records the GameServers updated, failures and duration
+// of the rollout according to the status changes of Squad.
+func (c *Controller) recordRolloutMetrics(
+	squad *carrierv1alpha1.Squad,
+	newGSSet *carrierv1alpha1.GameServerSet,
+	newStatus *carrierv1alpha1.SquadStatus) {
+	completed := rolloutCompleted(*newStatus)
+	if newGSSet == nil || completed && rolloutCompleted(squad.Status) {
+		return
+	}
+	revision := newGSSet.Annotations[util.RevisionAnnotation]
+	strategy := string(squad.Spec.Strategy.Type)
+	if updated := newStatus.UpdatedReplicas - squad.Status.UpdatedReplicas; updated > 0 {
+		metrics.RecordSquadGameServersUpdated(squad.Namespace, squad.Name, revision, strategy, updated)
+	}
+	failure := GetSquadCondition(*newStatus, carrierv1alpha1.SquadReplicaFailure)
+	if failure != nil && GetSquadCondition(squad.Status, carrierv1alpha1.SquadReplicaFailure) == nil {
+		metrics.RecordSquadRolloutFailure(squad.Namespace, squad.Name, revision, failure.Reason)
+	}
+	key := rolloutKey(squad, newGSSet)
+	now := time.Now()
+	if !completed {
+		c.rollouts.start(key, now)
+		return
+	}
+	if duration, ok := c.rollouts.finish(key, now); ok {
+		metrics.RecordSquadRolloutCompleted(squad.Namespace, squad.Name, revision, strategy, duration)
+	}
+}
+
+// rolloutCompleted checks if the latest rollout of Squad has completed.
+func rolloutCompleted(status carrierv1alpha1.SquadStatus) bool {
+	cond := GetSquadCondition(status, carrierv1alpha1.SquadProgressing)
+	return cond != nil && cond.Reason == util.NewGSSetReadyReason
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestRolloutTracker(t *testing.T) {
+	tracker := newRolloutTracker()
+	now := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	if _, ok := tracker.finish("default/squad/abc", now); ok {
+		t.Errorf("desired rollout not tracked")
+	}
+	tracker.start("default/squad/abc", now)
+	// restart of a rollout in progress keeps the first start time.
+	tracker.start("default/squad/abc", now.Add(time.Minute))
+	duration, ok := tracker.finish("default/squad/abc", now.Add(5*time.Minute))
+	if !ok || duration != 5*time.Minute {
+		t.Errorf("desired duration %v, get: %v, %v", 5*time.Minute, duration, ok)
+	}
+	if _, ok := tracker.finish("default/squad/abc", now.Add(6*time.Minute)); ok {
+		t.Errorf("desired rollout not tracked after finished")
+	}
+}
+
+func TestRecordRolloutMetrics(t *testing.T) {
+	squad := &carrierv1alpha1.Squad{
+		ObjectMeta: metav1.ObjectMeta{Name: "squad", Namespace: "default"},
+	}
+	newGSSet := &carrierv1alpha1.GameServerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{util.GameServerHash: "abc"},
+			Annotations: map[string]string{util.RevisionAnnotation: "2"},
+		},
+	}
+	progressing := carrierv1alpha1.SquadStatus{
+		Conditions: []carrierv1alpha1.SquadCondition{
+			*NewSquadCondition(carrierv1alpha1.SquadProgressing, corev1.ConditionTrue,
+				util.GameServerSetUpdatedReason, ""),
+		},
+	}
+	completed := carrierv1alpha1.SquadStatus{
+		Conditions: []carrierv1alpha1.SquadCondition{
+			*NewSquadCondition(carrierv1alpha1.SquadProgressing, corev1.ConditionTrue,
+				util.NewGSSetReadyReason, ""),
+		},
+	}
+	c := &Controller{rollouts: newRolloutTracker()}
+	key := rolloutKey(squad, newGSSet)
+
+	squad.Status = completed
+	c.recordRolloutMetrics(squad, newGSSet, &progressing)
+	if _, ok := c.rollouts.started[key]; !ok {
+		t.Fatalf("desired rollout %v tracked", key)
+	}
+	squad.Status = progressing
+	c.recordRolloutMetrics(squad, newGSSet, &completed)
+	if _, ok := c.rollouts.started[key]; ok {
+		t.Errorf("desired rollout %v finished", key)
+	}
+	squad.Status = completed
+	c.recordRolloutMetrics(squad, newGSSet, &completed)
+	if len(c.rollouts.started) != 0 {
+		t.Errorf("desired no rollout tracked, get: %v", c.rollouts.started)
+	}
+}
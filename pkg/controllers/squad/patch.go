@@ -0,0 +1,77 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+// podSpecPath is the JSON pointer of pod spec in the template of Squad.
+const podSpecPath = "/spec/template/spec/template/spec"
+
+// jsonPatchOperation is an operation of JSON patch.
+type jsonPatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value string `json:"value"`
+}
+
+// ImagePatch returns the JSON patch updating only the images of containers in the template
+// of Squad, keyed by container name, so the rest of template changed by others is preserved.
+// Init containers are matched too. Each replacement is guarded by a test of the container
+// name, so the patch fails instead of updating another container if containers are reordered
+// after squad is read.
+func ImagePatch(squad *carrierv1alpha1.Squad, images map[string]string) ([]byte, error) {
+	names := make([]string, 0, len(images))
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	podSpec := &squad.Spec.Template.Spec.Template.Spec
+	var patch []jsonPatchOperation
+	for _, name := range names {
+		path, ok := containerPath(podSpec, name)
+		if !ok {
+			return nil, errors.Errorf("container %v not found in template of Squad %v", name, squad.Name)
+		}
+		patch = append(patch,
+			jsonPatchOperation{Op: "test", Path: path + "/name", Value: name},
+			jsonPatchOperation{Op: "replace", Path: path + "/image", Value: images[name]},
+		)
+	}
+	return json.Marshal(patch)
+}
+
+// containerPath returns the JSON pointer of the container named name in pod spec.
+func containerPath(podSpec *corev1.PodSpec, name string) (string, bool) {
+	for field, containers := range map[string][]corev1.Container{
+		"containers":     podSpec.Containers,
+		"initContainers": podSpec.InitContainers,
+	} {
+		for i, container := range containers {
+			if container.Name == name {
+				return fmt.Sprintf("%v/%v/%v", podSpecPath, field, i), true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestImagePatch(t *testing.T) {
+	squad := &carrierv1alpha1.Squad{}
+	squad.Spec.Template.Spec.Template.Spec = corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init", Image: "init:v1"}},
+		Containers:     []corev1.Container{{Name: "sidecar", Image: "sidecar:v1"}, {Name: "server", Image: "game:v1"}},
+	}
+	data, err := ImagePatch(squad, map[string]string{"server": "game:v2", "init": "init:v2"})
+	if err != nil {
+		t.Fatalf("desired no error, get: %v", err)
+	}
+	var patch []jsonPatchOperation
+	if err = json.Unmarshal(data, &patch); err != nil {
+		t.Fatal(err)
+	}
+	desired := []jsonPatchOperation{
+		{Op: "test", Path: "/spec/template/spec/template/spec/initContainers/0/name", Value: "init"},
+		{Op: "replace", Path: "/spec/template/spec/template/spec/initContainers/0/image", Value: "init:v2"},
+		{Op: "test", Path: "/spec/template/spec/template/spec/containers/1/name", Value: "server"},
+		{Op: "replace", Path: "/spec/template/spec/template/spec/containers/1/image", Value: "game:v2"},
+	}
+	if !reflect.DeepEqual(patch, desired) {
+		t.Errorf("desired patch %+v, get: %+v", desired, patch)
+	}
+
+	if _, err = ImagePatch(squad, map[string]string{"missing": "game:v2"}); err == nil {
+		t.Errorf("desired error for missing container")
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/controllers/gameserversets"
+	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/util/hash"
+)
+
+// defaultPreflightTimeout is how long the preflight GameServer could take to become ready by default.
+const defaultPreflightTimeout = 300 * time.Second
+
+// checkPreflight runs the preflight GameServer with the template of squad before rolling it out,
+// and returns true once the rollout could start. The first GameServerSet of squad, and templates
+// already rolled out, are not checked. The Preflight condition of squad is set in memory, and
+// persisted by the status sync following.
+func (c *Controller) checkPreflight(
+	squad *carrierv1alpha1.Squad,
+	gsSetList []*carrierv1alpha1.GameServerSet) (bool, error) {
+	if squad.Spec.Preflight == nil || len(gsSetList) == 0 || FindNewGameServerSet(squad, gsSetList) != nil {
+		// the failure of a template reverted no longer stalls the Squad.
+		if cond := GetSquadCondition(squad.Status, carrierv1alpha1.SquadPreflight); cond != nil &&
+			cond.Status != corev1.ConditionTrue {
+			RemoveSquadCondition(&squad.Status, carrierv1alpha1.SquadPreflight)
+		}
+		return true, c.cleanupPreflight(squad, "")
+	}
+	name := preflightName(squad)
+	if err := c.cleanupPreflight(squad, name); err != nil {
+		return false, err
+	}
+	gs, err := c.gameServerLister.GameServers(squad.Namespace).Get(name)
+	if k8serrors.IsNotFound(err) {
+		_, err = c.gameServerGetter.GameServers(squad.Namespace).Create(newPreflightGameServer(squad, name))
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return false, err
+		}
+		c.recorder.Eventf(squad, corev1.EventTypeNormal, util.PreflightRunningReason,
+			"Created preflight GameServer %s", name)
+		setPreflightCondition(squad, corev1.ConditionUnknown, util.PreflightRunningReason,
+			fmt.Sprintf("Waiting for preflight GameServer %s to become ready", name))
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	passed, failure, remaining := preflightResult(squad, gs, time.Now())
+	switch {
+	case passed:
+		if setPreflightCondition(squad, corev1.ConditionTrue, util.PreflightPassedReason,
+			fmt.Sprintf("Preflight GameServer %s is ready", name)) {
+			c.recorder.Eventf(squad, corev1.EventTypeNormal, util.PreflightPassedReason,
+				"Preflight GameServer %s is ready, start rolling out", name)
+		}
+		return true, nil
+	case len(failure) != 0:
+		if setPreflightCondition(squad, corev1.ConditionFalse, util.PreflightFailedReason, failure) {
+			c.recorder.Event(squad, corev1.EventTypeWarning, util.PreflightFailedReason, failure)
+		}
+		return false, nil
+	}
+	setPreflightCondition(squad, corev1.ConditionUnknown, util.PreflightRunningReason,
+		fmt.Sprintf("Waiting for preflight GameServer %s to become ready", name))
+	if key, err := cache.MetaNamespaceKeyFunc(squad); err == nil {
+		c.workerQueue.AddAfter(key, remaining)
+	}
+	return false, nil
+}
+
+// cleanupPreflight deletes the preflight GameServers of squad except the one named keep.
+func (c *Controller) cleanupPreflight(squad *carrierv1alpha1.Squad, keep string) error {
+	list, err := c.gameServerLister.GameServers(squad.Namespace).List(
+		labels.SelectorFromSet(labels.Set{util.PreflightLabelKey: squad.Name}))
+	if err != nil {
+		return err
+	}
+	for _, gs := range list {
+		if gs.Name == keep || gs.DeletionTimestamp != nil || !metav1.IsControlledBy(gs, squad) {
+			continue
+		}
+		klog.V(4).Infof("Delete preflight GameServer %v/%v", gs.Namespace, gs.Name)
+		err = c.gameServerGetter.GameServers(gs.Namespace).Delete(gs.Name, &metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// preflightName returns the name of the preflight GameServer of the current template of squad.
+func preflightName(squad *carrierv1alpha1.Squad) string {
+	return squad.Name + "-preflight-" + hash.GameServerTemplateHash(&squad.Spec.Template)
+}
+
+// newPreflightGameServer builds the preflight GameServer of squad. It is labeled apart from the
+// selector of squad, so it is neither counted nor allocated as a GameServer of squad.
+func newPreflightGameServer(squad *carrierv1alpha1.Squad, name string) *carrierv1alpha1.GameServer {
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       squad.Namespace,
+			Labels:          map[string]string{util.PreflightLabelKey: squad.Name},
+			Annotations:     util.Merge(nil, squad.Spec.Template.Annotations),
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(squad, controllerKind)},
+		},
+		Spec: *squad.Spec.Template.Spec.DeepCopy(),
+	}
+	gs.Spec.Scheduling = squad.Spec.Scheduling
+	gs.Spec.Colocation = squad.Spec.Colocation
+	gameserversets.AddImagePullSecrets(&gs.Spec.Template.Spec, squad.Spec.ImagePullSecrets)
+	gameservers.SetGameVersion(gs, gs.Spec.GameVersion)
+	return gs
+}
+
+// preflightResult checks the preflight GameServer gs of squad at now. It returns true if gs
+// passes, the message if gs fails, or the time left before gs times out otherwise.
+func preflightResult(
+	squad *carrierv1alpha1.Squad,
+	gs *carrierv1alpha1.GameServer,
+	now time.Time) (bool, string, time.Duration) {
+	preflight := squad.Spec.Preflight
+	switch gs.Status.State {
+	case carrierv1alpha1.GameServerFailed, carrierv1alpha1.GameServerExited, carrierv1alpha1.GameServerCompleted:
+		return false, fmt.Sprintf("Preflight GameServer %s is %s", gs.Name, gs.Status.State), 0
+	}
+	selfTestPassed := true
+	if len(preflight.SelfTestCondition) != 0 {
+		selfTestPassed = false
+		for _, condition := range gs.Status.Conditions {
+			if string(condition.Type) != preflight.SelfTestCondition {
+				continue
+			}
+			switch condition.Status {
+			case carrierv1alpha1.ConditionTrue:
+				selfTestPassed = true
+			case carrierv1alpha1.ConditionFalse:
+				return false, fmt.Sprintf("Self-test of preflight GameServer %s failed: %s",
+					gs.Name, condition.Message), 0
+			}
+		}
+	}
+	if gs.Status.State == carrierv1alpha1.GameServerRunning && gameservers.IsReady(gs) && selfTestPassed {
+		return true, "", 0
+	}
+	timeout := defaultPreflightTimeout
+	if preflight.TimeoutSeconds != nil {
+		timeout = time.Duration(*preflight.TimeoutSeconds) * time.Second
+	}
+	remaining := gs.CreationTimestamp.Add(timeout).Sub(now)
+	if remaining <= 0 {
+		return false, fmt.Sprintf("Preflight GameServer %s is not ready within %v", gs.Name, timeout), 0
+	}
+	return false, "", remaining
+}
+
+// setPreflightCondition sets the Preflight condition of squad, and returns true if it changes.
+func setPreflightCondition(squad *carrierv1alpha1.Squad, status corev1.ConditionStatus, reason, message string) bool {
+	return UpdateSquadCondition(&squad.Status,
+		*NewSquadCondition(carrierv1alpha1.SquadPreflight, status, reason, message))
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	carrierfake "github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestPreflightResult(t *testing.T) {
+	now := time.Now()
+	squad := newSquad("foo", 1, nil, nil, nil, map[string]string{"foo": "bar"})
+	squad.Spec.Preflight = &carrierv1alpha1.SquadPreflight{SelfTestCondition: "SelfTest"}
+	newGameServer := func(state carrierv1alpha1.GameServerState, age time.Duration,
+		selfTest carrierv1alpha1.ConditionStatus) *carrierv1alpha1.GameServer {
+		gs := &carrierv1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo-preflight", CreationTimestamp: metav1.NewTime(now.Add(-age))},
+			Status:     carrierv1alpha1.GameServerStatus{State: state},
+		}
+		if len(selfTest) != 0 {
+			gs.Status.Conditions = []carrierv1alpha1.GameServerCondition{
+				{Type: "SelfTest", Status: selfTest, Message: "broken"},
+			}
+		}
+		return gs
+	}
+	tests := []struct {
+		name      string
+		gs        *carrierv1alpha1.GameServer
+		passed    bool
+		failure   string
+		remaining time.Duration
+	}{
+		{
+			name:      "starting",
+			gs:        newGameServer(carrierv1alpha1.GameServerStarting, time.Minute, ""),
+			remaining: 4 * time.Minute,
+		},
+		{
+			name:      "running without self-test",
+			gs:        newGameServer(carrierv1alpha1.GameServerRunning, time.Minute, ""),
+			remaining: 4 * time.Minute,
+		},
+		{
+			name:   "self-test passed",
+			gs:     newGameServer(carrierv1alpha1.GameServerRunning, time.Minute, carrierv1alpha1.ConditionTrue),
+			passed: true,
+		},
+		{
+			name:    "self-test failed",
+			gs:      newGameServer(carrierv1alpha1.GameServerRunning, time.Minute, carrierv1alpha1.ConditionFalse),
+			failure: "broken",
+		},
+		{
+			name:    "exited",
+			gs:      newGameServer(carrierv1alpha1.GameServerExited, time.Minute, ""),
+			failure: "is Exited",
+		},
+		{
+			name:    "timeout",
+			gs:      newGameServer(carrierv1alpha1.GameServerStarting, 10*time.Minute, ""),
+			failure: "not ready within",
+		},
+	}
+	for _, tc := range tests {
+		passed, failure, remaining := preflightResult(squad, tc.gs, now)
+		if passed != tc.passed {
+			t.Errorf("%v: desired passed: %v, get: %v", tc.name, tc.passed, passed)
+		}
+		if (len(tc.failure) == 0) != (len(failure) == 0) || !strings.Contains(failure, tc.failure) {
+			t.Errorf("%v: desired failure %q, get: %q", tc.name, tc.failure, failure)
+		}
+		if remaining != tc.remaining {
+			t.Errorf("%v: desired remaining %v, get: %v", tc.name, tc.remaining, remaining)
+		}
+	}
+}
+
+func TestCheckPreflight(t *testing.T) {
+	squad := newSquad("foo", 1, nil, nil, nil, map[string]string{"foo": "bar"})
+	oldGSSet := newGameServerSet(squad, "foo-1", 1)
+	oldGSSet.Spec.Template = *squad.Spec.Template.DeepCopy()
+	squad.Spec.Template.Spec.Template.Spec.Containers[0].Image = "game:v2"
+	squad.Spec.Preflight = &carrierv1alpha1.SquadPreflight{}
+	gsSetList := []*carrierv1alpha1.GameServerSet{oldGSSet}
+
+	client := carrierfake.NewSimpleClientset()
+	gameServers := externalversions.NewSharedInformerFactory(client, 0).Carrier().V1alpha1().GameServers()
+	c := &Controller{
+		gameServerGetter: client.CarrierV1alpha1(),
+		gameServerLister: gameServers.Lister(),
+		recorder:         &record.FakeRecorder{},
+		workerQueue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+	defer c.workerQueue.ShutDown()
+
+	passed, err := c.checkPreflight(squad, gsSetList)
+	if err != nil || passed {
+		t.Fatalf("desired preflight started, get passed: %v, err: %v", passed, err)
+	}
+	name := preflightName(squad)
+	gs, err := client.CarrierV1alpha1().GameServers(squad.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("desired preflight GameServer created, get: %v", err)
+	}
+	if gs.Labels[util.PreflightLabelKey] != squad.Name || gs.Labels["foo"] == "bar" {
+		t.Errorf("desired preflight GameServer out of the selector of Squad, get: %v", gs.Labels)
+	}
+	cond := GetSquadCondition(squad.Status, carrierv1alpha1.SquadPreflight)
+	if cond == nil || cond.Status != corev1.ConditionUnknown {
+		t.Errorf("desired Preflight condition Unknown, get: %+v", cond)
+	}
+
+	gs.CreationTimestamp = metav1.Now()
+	gs.Status.State = carrierv1alpha1.GameServerRunning
+	gameServers.Informer().GetIndexer().Add(gs)
+	passed, err = c.checkPreflight(squad, gsSetList)
+	if err != nil || !passed {
+		t.Fatalf("desired preflight passed, get passed: %v, err: %v", passed, err)
+	}
+	cond = GetSquadCondition(squad.Status, carrierv1alpha1.SquadPreflight)
+	if cond == nil || cond.Status != corev1.ConditionTrue {
+		t.Errorf("desired Preflight condition True, get: %+v", cond)
+	}
+
+	// the GameServerSet of the new template is created, preflight GameServer is cleaned up.
+	gsSetList = append(gsSetList, newGameServerSet(squad, "foo-2", 0))
+	passed, err = c.checkPreflight(squad, gsSetList)
+	if err != nil || !passed {
+		t.Fatalf("desired rolled out template passed, get passed: %v, err: %v", passed, err)
+	}
+	if _, err = client.CarrierV1alpha1().GameServers(squad.Namespace).Get(name, metav1.GetOptions{}); err == nil {
+		t.Errorf("desired preflight GameServer deleted")
+	}
+}
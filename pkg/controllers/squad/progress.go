@@ -88,6 +88,10 @@ func (c *Controller) syncRolloutStatus(
 	} else {
 		RemoveSquadCondition(&newStatus, carrierv1alpha1.SquadReplicaFailure)
 	}
+	c.setImagePullSecretCondition(squad, &newStatus)
+	setKStatusConditions(squad, newGSSet, &newStatus)
+
+	c.recordRolloutMetrics(squad, newGSSet, &newStatus)
 
 	// Do not update if there is nothing new to add.
 	if reflect.DeepEqual(squad.Status, newStatus) {
@@ -0,0 +1,78 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// missingImagePullSecrets returns the names of imagePullSecrets of squad which do not exist.
+func (c *Controller) missingImagePullSecrets(squad *carrierv1alpha1.Squad) ([]string, error) {
+	var missing []string
+	for _, ref := range squad.Spec.ImagePullSecrets {
+		_, err := c.secretLister.Secrets(squad.Namespace).Get(ref.Name)
+		if k8serrors.IsNotFound(err) {
+			missing = append(missing, ref.Name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// setImagePullSecretCondition sets the ImagePullSecretMissing condition of newStatus if some of
+// the imagePullSecrets of squad do not exist, and removes it otherwise. The condition is kept
+// as is if secrets fail to be checked.
+func (c *Controller) setImagePullSecretCondition(squad *carrierv1alpha1.Squad, newStatus *carrierv1alpha1.SquadStatus) {
+	missing, err := c.missingImagePullSecrets(squad)
+	if err != nil {
+		klog.Warningf("Failed to check imagePullSecrets of Squad %v/%v: %v", squad.Namespace, squad.Name, err)
+		return
+	}
+	if len(missing) == 0 {
+		RemoveSquadCondition(newStatus, carrierv1alpha1.SquadImagePullSecretMissing)
+		return
+	}
+	message := fmt.Sprintf("ImagePullSecrets %v do not exist, GameServers may fail to pull images",
+		strings.Join(missing, ", "))
+	if UpdateSquadCondition(newStatus, *NewSquadCondition(carrierv1alpha1.SquadImagePullSecretMissing,
+		corev1.ConditionTrue, util.ImagePullSecretMissingReason, message)) {
+		c.recorder.Event(squad, corev1.EventTypeWarning, util.ImagePullSecretMissingReason, message)
+	}
+}
+
+// syncImagePullSecrets updates the imagePullSecrets of gsSet to those of squad, so new GameServers
+// pull images with the secrets without rolling out the template.
+func (c *Controller) syncImagePullSecrets(
+	squad *carrierv1alpha1.Squad,
+	gsSet *carrierv1alpha1.GameServerSet) (*carrierv1alpha1.GameServerSet, error) {
+	if apiequality.Semantic.DeepEqual(squad.Spec.ImagePullSecrets, gsSet.Spec.ImagePullSecrets) {
+		return gsSet, nil
+	}
+	gsSetCopy := gsSet.DeepCopy()
+	gsSetCopy.Spec.ImagePullSecrets = append([]corev1.LocalObjectReference(nil), squad.Spec.ImagePullSecrets...)
+	return c.gameServerSetGetter.GameServerSets(gsSetCopy.Namespace).Update(gsSetCopy)
+}
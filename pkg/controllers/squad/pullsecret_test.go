@@ -0,0 +1,91 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	carrierfake "github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+)
+
+func TestSetImagePullSecretCondition(t *testing.T) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: metav1.NamespaceDefault}}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(secret)
+	recorder := record.NewFakeRecorder(10)
+	c := &Controller{
+		secretLister: corelisterv1.NewSecretLister(indexer),
+		recorder:     recorder,
+	}
+	squad := newSquad("foo", 1, nil, nil, nil, map[string]string{"foo": "bar"})
+	status := &carrierv1alpha1.SquadStatus{}
+
+	squad.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}, {Name: "renamed"}}
+	c.setImagePullSecretCondition(squad, status)
+	condition := GetSquadCondition(*status, carrierv1alpha1.SquadImagePullSecretMissing)
+	if condition == nil || condition.Status != corev1.ConditionTrue || !strings.Contains(condition.Message, "renamed") {
+		t.Fatalf("desired condition of missing secret renamed, get: %+v", condition)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("desired 1 warning event, get: %v", len(recorder.Events))
+	}
+
+	c.setImagePullSecretCondition(squad, status)
+	if len(recorder.Events) != 1 {
+		t.Errorf("desired no event if missing secrets are unchanged, get: %v", len(recorder.Events))
+	}
+
+	squad.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+	c.setImagePullSecretCondition(squad, status)
+	if condition := GetSquadCondition(*status, carrierv1alpha1.SquadImagePullSecretMissing); condition != nil {
+		t.Errorf("desired condition removed, get: %+v", condition)
+	}
+}
+
+func TestSyncImagePullSecrets(t *testing.T) {
+	squad := newSquad("foo", 1, nil, nil, nil, map[string]string{"foo": "bar"})
+	gsSet := newGameServerSet(squad, "foo-1", 1)
+	client := carrierfake.NewSimpleClientset(gsSet)
+	c := &Controller{gameServerSetGetter: client.CarrierV1alpha1()}
+
+	synced, err := c.syncImagePullSecrets(squad, gsSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if synced != gsSet || len(client.Actions()) != 0 {
+		t.Errorf("desired no update without secrets, get: %v", client.Actions())
+	}
+
+	squad.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+	synced, err = c.syncImagePullSecrets(squad, gsSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(synced.Spec.ImagePullSecrets, squad.Spec.ImagePullSecrets) {
+		t.Errorf("desired secrets %v, get: %v", squad.Spec.ImagePullSecrets, synced.Spec.ImagePullSecrets)
+	}
+	if len(gsSet.Spec.ImagePullSecrets) != 0 {
+		t.Errorf("desired GameServerSet in cache unchanged, get: %v", gsSet.Spec.ImagePullSecrets)
+	}
+}
@@ -109,7 +109,7 @@ func oldGameServersRunning(
 		}
 		for _, gs := range gsList {
 			switch gs.Status.State {
-			case carrierv1alpha1.GameServerFailed, carrierv1alpha1.GameServerExited:
+			case carrierv1alpha1.GameServerFailed, carrierv1alpha1.GameServerExited, carrierv1alpha1.GameServerCompleted:
 				// Don't count GameServers in terminal state.
 				continue
 			case carrierv1alpha1.GameServerUnknown:
@@ -100,7 +100,7 @@ func (c *Controller) updateSquadAndClearRollbackTo(squad *carrierv1alpha1.Squad)
 		threshold := intstrutil.FromString("100%")
 		squad.Spec.Strategy.CanaryUpdate.Threshold = &threshold
 	}
-	_, err := c.squadGetter.Squads(squad.Namespace).Update(squad)
+	_, err := c.squadGetter.Squads(squad.Namespace).Update(c.uncapped(squad))
 	return err
 }
 
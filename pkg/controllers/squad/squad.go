@@ -31,6 +31,7 @@ import (
 
 	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
 	"github.com/ocgi/carrier/pkg/util"
+	"github.com/ocgi/carrier/pkg/util/hash"
 )
 
 const (
@@ -82,6 +83,8 @@ func (c *Controller) syncSquadStatus(
 	newGSSet *carrierv1alpha1.GameServerSet,
 	squad *carrierv1alpha1.Squad) error {
 	newStatus := calculateStatus(allGSSets, newGSSet, squad)
+	c.setImagePullSecretCondition(squad, &newStatus)
+	setKStatusConditions(squad, newGSSet, &newStatus)
 	klog.V(4).Infof("sync squad status: name: %v, spec: %v, status: %v",
 		squad.ObjectMeta, squad.Spec, newStatus)
 	if reflect.DeepEqual(squad.Status, newStatus) {
@@ -104,6 +107,7 @@ func calculateStatus(
 		Replicas:           GetActualReplicaCountForGameServerSets(allGSSets),
 		UpdatedReplicas:    GetUpdateReplicaCountForGameServerSets([]*carrierv1alpha1.GameServerSet{newGSSet}),
 		ReadyReplicas:      GetReadyReplicaCountForGameServerSets(allGSSets),
+		GameVersions:       calculateGameVersions(allGSSets),
 	}
 	conditions := squad.Status.Conditions
 	for i := range conditions {
@@ -163,6 +167,17 @@ func (c *Controller) getAllGameServerSetsAndSyncRevision(
 	if err != nil {
 		return nil, nil, err
 	}
+	// old GameServerSets may still replace GameServers, so they need the secrets too.
+	for i, gsSet := range allOldGSSets {
+		if allOldGSSets[i], err = c.syncImagePullSecrets(squad, gsSet); err != nil {
+			return nil, nil, err
+		}
+	}
+	if newGSSet != nil {
+		if newGSSet, err = c.syncImagePullSecrets(squad, newGSSet); err != nil {
+			return nil, nil, err
+		}
+	}
 	return newGSSet, allOldGSSets, nil
 }
 
@@ -220,19 +235,18 @@ func (c *Controller) getNewGameServerSet(
 		}
 
 		// Should use the revision in existingNewGSSet's annotation, since it set by before
-		needsUpdate := SetSquadRevision(squad, gsSetCopy.Annotations[util.RevisionAnnotation])
+		if SetSquadRevision(squad, gsSetCopy.Annotations[util.RevisionAnnotation]) {
+			if err := c.updateSquadRevision(squad); err != nil {
+				return nil, err
+			}
+		}
 		cond := GetSquadCondition(squad.Status, carrierv1alpha1.SquadProgressing)
 		if cond == nil {
 			msg := fmt.Sprintf("Found new GameServerSet %q", gsSetCopy.Name)
 			condition := NewSquadCondition(carrierv1alpha1.SquadProgressing,
 				corev1.ConditionTrue, util.FoundNewGSSetReason, msg)
 			SetSquadCondition(&squad.Status, *condition)
-			needsUpdate = true
-		}
-
-		if needsUpdate {
-			var err error
-			if _, err = c.squadGetter.Squads(squad.Namespace).UpdateStatus(squad); err != nil {
+			if _, err := c.squadGetter.Squads(squad.Namespace).UpdateStatus(squad); err != nil {
 				return nil, err
 			}
 		}
@@ -245,7 +259,7 @@ func (c *Controller) getNewGameServerSet(
 
 	// new GameServerSet does not exist, create one.
 	newGSSetTemplate := *squad.Spec.Template.DeepCopy()
-	gsTemplateSpecHash := ComputeHash(&newGSSetTemplate)
+	gsTemplateSpecHash := hash.GameServerTemplateHash(&newGSSetTemplate)
 	newGSSSetelector := metav1.CloneSelectorAndAddLabel(squad.Spec.Selector, util.SquadNameLabelKey, squad.Name)
 	// Create new GameServerSet
 	newGSSet := carrierv1alpha1.GameServerSet{
@@ -258,9 +272,11 @@ func (c *Controller) getNewGameServerSet(
 		},
 		Spec: carrierv1alpha1.GameServerSetSpec{
 			Scheduling:         squad.Spec.Scheduling,
+			Colocation:         squad.Spec.Colocation,
 			Selector:           newGSSSetelector,
 			Template:           newGSSetTemplate,
 			ExcludeConstraints: squad.Spec.ExcludeConstraints,
+			ImagePullSecrets:   squad.Spec.ImagePullSecrets,
 		},
 	}
 	// Setting GameServerSet labels
@@ -330,7 +346,11 @@ func (c *Controller) getNewGameServerSet(
 			"Scaled up GameServerSet %s to %d", createdGSSet.Name, newReplicasCount)
 	}
 
-	needsUpdate := SetSquadRevision(squad, newRevision)
+	if SetSquadRevision(squad, newRevision) {
+		if err = c.updateSquadRevision(squad); err != nil {
+			return nil, err
+		}
+	}
 	if !alreadyExists {
 		msg := fmt.Sprintf("Created new GameServerSet %q", createdGSSet.Name)
 		condition := NewSquadCondition(
@@ -339,14 +359,23 @@ func (c *Controller) getNewGameServerSet(
 			util.NewGameServerSetReason,
 			msg)
 		SetSquadCondition(&squad.Status, *condition)
-		needsUpdate = true
-	}
-	if needsUpdate {
 		_, err = c.squadGetter.Squads(squad.Namespace).UpdateStatus(squad)
 	}
 	return createdGSSet, err
 }
 
+// updateSquadRevision writes the revision annotation of Squad back to apiserver.
+// Changes of metadata through the status subresource are ignored, so the annotation
+// should be updated before the status.
+func (c *Controller) updateSquadRevision(squad *carrierv1alpha1.Squad) error {
+	newSquad, err := c.squadGetter.Squads(squad.Namespace).Update(c.uncapped(squad))
+	if err != nil {
+		return err
+	}
+	squad.ResourceVersion = newSquad.ResourceVersion
+	return nil
+}
+
 // scale scales proportionally in order to mitigate risk. Otherwise, scaling up can increase the size
 // of the new GameServerSet and scaling down can decrease the sizes of the old ones, both of which would
 // have the effect of hastening the rollout progress, which could produce a higher proportion of unavailable
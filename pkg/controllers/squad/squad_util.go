@@ -17,18 +17,15 @@ package squad
 
 import (
 	"fmt"
-	"hash/fnv"
 	"math"
 	"sort"
 	"strconv"
 
 	corev1 "k8s.io/api/core/v1"
-	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
-	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/klog"
 	"k8s.io/utils/integer"
 
@@ -207,16 +204,9 @@ func FindActiveOrLatest(
 }
 
 // EqualGameServerTemplate returns true if two given GameServerTemplateSpec are equal,
-// ignoring some diff in value of Labels
+// ignoring labels managed by carrier and fields equal to their defaults.
 func EqualGameServerTemplate(template1, template2 *carrierv1alpha1.GameServerTemplateSpec) bool {
-	t1Copy := template1.DeepCopy()
-	t2Copy := template2.DeepCopy()
-	// Remove specific labels from template.Labels before comparing
-	delete(t1Copy.Labels, util.SquadNameLabelKey)
-	delete(t2Copy.Labels, util.SquadNameLabelKey)
-	delete(t1Copy.Labels, util.GameServerHash)
-	delete(t2Copy.Labels, util.GameServerHash)
-	return apiequality.Semantic.DeepEqual(t1Copy, t2Copy)
+	return hash.EqualGameServerTemplate(template1, template2)
 }
 
 // GetDesiredReplicasAnnotation returns the number of desired replicas
@@ -647,6 +637,22 @@ func SetSquadCondition(status *carrierv1alpha1.SquadStatus, condition carrierv1a
 	status.Conditions = append(newConditions, condition)
 }
 
+// UpdateSquadCondition sets condition in status like SetSquadCondition, but also updates the
+// message if the status and reason are unchanged. Returns true if the condition changes.
+func UpdateSquadCondition(status *carrierv1alpha1.SquadStatus, condition carrierv1alpha1.SquadCondition) bool {
+	currentCond := GetSquadCondition(*status, condition.Type)
+	if currentCond != nil && currentCond.Status == condition.Status && currentCond.Reason == condition.Reason &&
+		currentCond.Message == condition.Message {
+		return false
+	}
+	if currentCond != nil && currentCond.Status == condition.Status {
+		condition.LastTransitionTime = currentCond.LastTransitionTime
+	}
+	newConditions := filterOutCondition(status.Conditions, condition.Type)
+	status.Conditions = append(newConditions, condition)
+	return true
+}
+
 // filterOutCondition returns a new slice of squad conditions without conditions with the provided type.
 func filterOutCondition(
 	conditions []carrierv1alpha1.SquadCondition,
@@ -772,17 +778,9 @@ func HasRevisionHistoryLimit(squad *carrierv1alpha1.Squad) bool {
 	return squad.Spec.RevisionHistoryLimit != nil && *squad.Spec.RevisionHistoryLimit != math.MaxInt32
 }
 
-// ComputeHash returns a hash value calculated from GameServerTemplateSpec
-func ComputeHash(template *carrierv1alpha1.GameServerTemplateSpec) string {
-	gsTemplateSpecHasher := fnv.New32a()
-	hash.DeepHashObject(gsTemplateSpecHasher, *template)
-
-	return rand.SafeEncodeString(fmt.Sprint(gsTemplateSpecHasher.Sum32()))
-}
-
 // SetGameServerTemplateHashLabels setting pod spec hash to GameServerSet labels
 func SetGameServerTemplateHashLabels(gsSet *carrierv1alpha1.GameServerSet) {
-	podSpecHash := ComputePodSpecHash(&gsSet.Spec.Template.Spec.Template.Spec)
+	podSpecHash := hash.PodSpecHash(&gsSet.Spec.Template.Spec.Template.Spec)
 	if gsSet.Labels == nil {
 		gsSet.Labels = make(map[string]string)
 	}
@@ -801,14 +799,6 @@ func SetGameServerSetInplaceUpdateAnnotations(gsSet *carrierv1alpha1.GameServerS
 	gsSet.Annotations[util.GameServerInPlaceUpdateAnnotation] = strconv.Itoa(int(InplaceThreshold(*squad)))
 }
 
-// ComputePodSpecHash return the hash value of the podspec
-func ComputePodSpecHash(spec *corev1.PodSpec) string {
-	gsTemplateHasher := fnv.New32a()
-	hash.DeepHashObject(gsTemplateHasher, *spec)
-
-	return rand.SafeEncodeString(fmt.Sprint(gsTemplateHasher.Sum32()))
-}
-
 // GameServerSetsByCreationTimestamp sorts a list of GameServerSet by creation timestamp,
 // using their names as a tie breaker.
 type GameServerSetsByCreationTimestamp []*carrierv1alpha1.GameServerSet
@@ -0,0 +1,178 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	admregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+const defaultTrafficHookTimeout = 10 * time.Second
+
+// TrafficShift is posted to the traffic hook of a Squad.
+type TrafficShift struct {
+	// Namespace of the Squad
+	Namespace string `json:"namespace"`
+	// Squad is the name of the Squad
+	Squad string `json:"squad"`
+	// NewGameServerSet is the name of the GameServerSet rolled out
+	NewGameServerSet string `json:"newGameServerSet"`
+	// Weights is the percentage of new sessions routed to each GameServerSet
+	Weights map[string]int32 `json:"weights"`
+}
+
+// shiftTraffic calls the traffic hook of squad if the weight of newGSSet changed,
+// the weight is the percentage of ready GameServers of newGSSet.
+func (c *Controller) shiftTraffic(
+	squad *carrierv1alpha1.Squad,
+	newGSSet *carrierv1alpha1.GameServerSet,
+	oldGSSets []*carrierv1alpha1.GameServerSet) error {
+	if newGSSet == nil || squad.Spec.Strategy.CanaryUpdate == nil ||
+		squad.Spec.Strategy.CanaryUpdate.TrafficHook == nil {
+		return nil
+	}
+	weight := int32(100)
+	if squad.Spec.Replicas > 0 && newGSSet.Status.ReadyReplicas < squad.Spec.Replicas {
+		weight = newGSSet.Status.ReadyReplicas * 100 / squad.Spec.Replicas
+	}
+	weightStr := strconv.Itoa(int(weight))
+	if newGSSet.Annotations[util.TrafficWeightAnnotation] == weightStr {
+		return nil
+	}
+	shift := &TrafficShift{
+		Namespace:        squad.Namespace,
+		Squad:            squad.Name,
+		NewGameServerSet: newGSSet.Name,
+		Weights:          computeTrafficWeights(newGSSet, FilterActiveGameServerSets(oldGSSets), weight),
+	}
+	if err := callTrafficHook(squad.Spec.Strategy.CanaryUpdate.TrafficHook, shift); err != nil {
+		c.recorder.Eventf(squad, corev1.EventTypeWarning, util.FailedTrafficShiftReason,
+			"Failed to shift %d%% of new sessions to %s: %v", weight, newGSSet.Name, err)
+		return err
+	}
+	gsSetCopy := newGSSet.DeepCopy()
+	if gsSetCopy.Annotations == nil {
+		gsSetCopy.Annotations = make(map[string]string)
+	}
+	gsSetCopy.Annotations[util.TrafficWeightAnnotation] = weightStr
+	if _, err := c.gameServerSetGetter.GameServerSets(gsSetCopy.Namespace).Update(gsSetCopy); err != nil {
+		return err
+	}
+	c.recorder.Eventf(squad, corev1.EventTypeNormal, util.TrafficShiftedReason,
+		"Shifted %d%% of new sessions to %s", weight, newGSSet.Name)
+	return nil
+}
+
+// computeTrafficWeights gives weight to newGSSet, the rest is divided among
+// oldGSSets in proportion to their replicas.
+func computeTrafficWeights(
+	newGSSet *carrierv1alpha1.GameServerSet,
+	oldGSSets []*carrierv1alpha1.GameServerSet,
+	weight int32) map[string]int32 {
+	weights := map[string]int32{newGSSet.Name: weight}
+	oldReplicas := GetReplicaCountForGameServerSets(oldGSSets)
+	if oldReplicas == 0 {
+		weights[newGSSet.Name] = 100
+		return weights
+	}
+	rest := 100 - weight
+	for _, gsSet := range oldGSSets {
+		w := (100 - weight) * gsSet.Spec.Replicas / oldReplicas
+		weights[gsSet.Name] = w
+		rest -= w
+	}
+	// give the remainder of rounding to the newest old GameServerSet.
+	weights[oldGSSets[len(oldGSSets)-1].Name] += rest
+	return weights
+}
+
+// callTrafficHook posts shift to the traffic hook.
+func callTrafficHook(hook *carrierv1alpha1.TrafficHook, shift *TrafficShift) error {
+	url, err := trafficHookURL(hook.ClientConfig)
+	if err != nil {
+		return err
+	}
+	client, err := trafficHookClient(hook)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(shift)
+	if err != nil {
+		return err
+	}
+	klog.V(4).Infof("Call traffic hook %v of squad %v/%v: %s", url, shift.Namespace, shift.Squad, body)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "error calling traffic hook")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("traffic hook returned %v: %s", resp.StatusCode, msg)
+	}
+	return nil
+}
+
+// trafficHookURL returns the url of the webhook client config.
+func trafficHookURL(config admregv1.WebhookClientConfig) (string, error) {
+	if config.URL != nil {
+		return *config.URL, nil
+	}
+	if config.Service == nil {
+		return "", errors.New("neither url nor service of traffic hook is specified")
+	}
+	port := int32(443)
+	if config.Service.Port != nil {
+		port = *config.Service.Port
+	}
+	var path string
+	if config.Service.Path != nil {
+		path = *config.Service.Path
+	}
+	return fmt.Sprintf("https://%s.%s.svc:%d%s", config.Service.Name, config.Service.Namespace, port, path), nil
+}
+
+// trafficHookClient returns a http client trusting the CABundle of hook.
+func trafficHookClient(hook *carrierv1alpha1.TrafficHook) (*http.Client, error) {
+	timeout := defaultTrafficHookTimeout
+	if hook.TimeoutSeconds != nil {
+		timeout = time.Duration(*hook.TimeoutSeconds) * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+	if len(hook.ClientConfig.CABundle) == 0 {
+		return client, nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(hook.ClientConfig.CABundle) {
+		return nil, errors.New("invalid caBundle of traffic hook")
+	}
+	client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	return client, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	admregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestComputeTrafficWeights(t *testing.T) {
+	newGSSet := &carrierv1alpha1.GameServerSet{ObjectMeta: metav1.ObjectMeta{Name: "new"}}
+	oldGSSets := []*carrierv1alpha1.GameServerSet{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "old1"},
+			Spec:       carrierv1alpha1.GameServerSetSpec{Replicas: 1},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "old2"},
+			Spec:       carrierv1alpha1.GameServerSetSpec{Replicas: 2},
+		},
+	}
+	desired := map[string]int32{"new": 20, "old1": 26, "old2": 54}
+	weights := computeTrafficWeights(newGSSet, oldGSSets, 20)
+	if !reflect.DeepEqual(desired, weights) {
+		t.Errorf("desired weights %v, get: %v", desired, weights)
+	}
+	desired = map[string]int32{"new": 100}
+	weights = computeTrafficWeights(newGSSet, nil, 20)
+	if !reflect.DeepEqual(desired, weights) {
+		t.Errorf("desired weights %v, get: %v", desired, weights)
+	}
+}
+
+func TestCallTrafficHook(t *testing.T) {
+	var received TrafficShift
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	url := server.URL
+	hook := &carrierv1alpha1.TrafficHook{
+		ClientConfig: admregv1.WebhookClientConfig{URL: &url},
+	}
+	shift := &TrafficShift{
+		Namespace:        "default",
+		Squad:            "test",
+		NewGameServerSet: "new",
+		Weights:          map[string]int32{"new": 50, "old": 50},
+	}
+	if err := callTrafficHook(hook, shift); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*shift, received) {
+		t.Errorf("desired traffic shift %+v, get: %+v", *shift, received)
+	}
+}
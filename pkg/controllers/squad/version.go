@@ -0,0 +1,64 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"sort"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+// calculateGameVersions returns the replicas of each game version of GameServerSets, nil if
+// game versions are not specified.
+func calculateGameVersions(allGSSets []*carrierv1alpha1.GameServerSet) []carrierv1alpha1.GameVersionStatus {
+	versions := make(map[string]*carrierv1alpha1.GameVersionStatus)
+	specified := false
+	for _, gsSet := range allGSSets {
+		if gsSet == nil || gsSet.Status.Replicas == 0 {
+			continue
+		}
+		version := gsSet.Spec.Template.Spec.GameVersion
+		specified = specified || len(version) != 0
+		status, ok := versions[version]
+		if !ok {
+			status = &carrierv1alpha1.GameVersionStatus{GameVersion: version}
+			versions[version] = status
+		}
+		status.Replicas += gsSet.Status.Replicas
+		status.ReadyReplicas += gsSet.Status.ReadyReplicas
+	}
+	if !specified {
+		return nil
+	}
+	result := make([]carrierv1alpha1.GameVersionStatus, 0, len(versions))
+	for _, status := range versions {
+		result = append(result, *status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GameVersion < result[j].GameVersion
+	})
+	return result
+}
+
+// canaryHeld checks if the canary update of Squad is held at its threshold with all GameServers
+// ready, the Squad intentionally runs two game versions weighted by the threshold.
+func canaryHeld(squad *carrierv1alpha1.Squad, newStatus *carrierv1alpha1.SquadStatus) bool {
+	threshold := CanaryThreshold(*squad)
+	return threshold > 0 && threshold < squad.Spec.Replicas &&
+		newStatus.UpdatedReplicas == threshold &&
+		newStatus.Replicas == squad.Spec.Replicas &&
+		newStatus.ReadyReplicas == squad.Spec.Replicas &&
+		newStatus.ObservedGeneration >= squad.Generation
+}
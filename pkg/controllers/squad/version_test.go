@@ -0,0 +1,106 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package squad
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestCalculateGameVersions(t *testing.T) {
+	gsSet := func(version string, replicas, ready int32) *carrierv1alpha1.GameServerSet {
+		gsSet := &carrierv1alpha1.GameServerSet{}
+		gsSet.Spec.Template.Spec.GameVersion = version
+		gsSet.Status.Replicas = replicas
+		gsSet.Status.ReadyReplicas = ready
+		return gsSet
+	}
+	tests := []struct {
+		name      string
+		allGSSets []*carrierv1alpha1.GameServerSet
+		desired   []carrierv1alpha1.GameVersionStatus
+	}{
+		{
+			name:      "not specified",
+			allGSSets: []*carrierv1alpha1.GameServerSet{gsSet("", 2, 2), nil},
+		},
+		{
+			name:      "mixed versions",
+			allGSSets: []*carrierv1alpha1.GameServerSet{gsSet("v2", 3, 1), gsSet("v1", 5, 5), gsSet("v1", 2, 2)},
+			desired: []carrierv1alpha1.GameVersionStatus{
+				{GameVersion: "v1", Replicas: 7, ReadyReplicas: 7},
+				{GameVersion: "v2", Replicas: 3, ReadyReplicas: 1},
+			},
+		},
+		{
+			name:      "scaled down",
+			allGSSets: []*carrierv1alpha1.GameServerSet{gsSet("v2", 3, 3), gsSet("v1", 0, 0)},
+			desired: []carrierv1alpha1.GameVersionStatus{
+				{GameVersion: "v2", Replicas: 3, ReadyReplicas: 3},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			versions := calculateGameVersions(tc.allGSSets)
+			if !reflect.DeepEqual(tc.desired, versions) {
+				t.Errorf("desired %+v, get: %+v", tc.desired, versions)
+			}
+		})
+	}
+}
+
+func TestCanaryHeld(t *testing.T) {
+	threshold := intstr.FromString("30%")
+	squad := &carrierv1alpha1.Squad{
+		Spec: carrierv1alpha1.SquadSpec{
+			Replicas: 10,
+			Strategy: carrierv1alpha1.SquadStrategy{
+				Type:         carrierv1alpha1.CanaryUpdateSquadStrategyType,
+				CanaryUpdate: &carrierv1alpha1.CanaryUpdateSquad{Threshold: &threshold},
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		status  carrierv1alpha1.SquadStatus
+		desired bool
+	}{
+		{
+			name:    "held",
+			status:  carrierv1alpha1.SquadStatus{Replicas: 10, UpdatedReplicas: 3, ReadyReplicas: 10},
+			desired: true,
+		},
+		{
+			name:   "updating",
+			status: carrierv1alpha1.SquadStatus{Replicas: 10, UpdatedReplicas: 1, ReadyReplicas: 10},
+		},
+		{
+			name:   "not ready",
+			status: carrierv1alpha1.SquadStatus{Replicas: 10, UpdatedReplicas: 3, ReadyReplicas: 8},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if held := canaryHeld(squad, &tc.status); held != tc.desired {
+				t.Errorf("desired held %v, get: %v", tc.desired, held)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package demand
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/metrics"
+	"github.com/ocgi/carrier/pkg/query"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=squads,verbs=get;list;watch;update
+
+// shareInterval is the period misses are pruned and shared with the other replicas.
+const shareInterval = 10 * time.Second
+
+// Source reports the unfulfilled allocations of Squads to autoscalers.
+type Source interface {
+	// PerMinute returns the unfulfilled allocations per minute of Squad over the recent window.
+	PerMinute(namespace, squad string) float64
+}
+
+// Buffer returns buffer raised by the GameServers needed for the unfulfilled allocations at
+// perMinute during lead, the time new GameServers take to be ready. Autoscalers add it to the
+// buffer computed from the status of Squads, so scale up starts on the first misses.
+func Buffer(buffer int32, perMinute float64, lead time.Duration) int32 {
+	if perMinute <= 0 {
+		return buffer
+	}
+	return buffer + int32(math.Ceil(perMinute*lead.Minutes()))
+}
+
+// squadKey is the namespace and name of a Squad.
+type squadKey struct {
+	namespace string
+	name      string
+}
+
+// sharedDemand is the unfulfilled allocations per minute of a Squad tracked by a replica, as
+// shared in util.SquadUnfulfilledAllocationsAnnotation.
+type sharedDemand struct {
+	PerMinute float64     `json:"perMinute"`
+	Time      metav1.Time `json:"time"`
+}
+
+// bucket is the misses within a second.
+type bucket struct {
+	second int64
+	misses int32
+}
+
+// Tracker records the queries matching no GameServers as unfulfilled allocations of their
+// Squads, within a sliding window. Only existing Squads are tracked, so queries naming
+// arbitrary Squads could not grow the tracker and the metrics without bound.
+// Queries are served by every replica, so each replica shares its misses in an annotation of
+// the Squads, and reports the misses of all replicas.
+type Tracker struct {
+	sync.Mutex
+	window time.Duration
+	// identity names the replica in the misses shared.
+	identity      string
+	carrierClient versioned.Interface
+	// buckets are the misses of each Squad by second, oldest first.
+	buckets map[squadKey][]bucket
+	// recorded are the Squads whose misses are recorded in metrics.
+	recorded map[squadKey]struct{}
+	// squadLister tells the Squads exist.
+	squadLister listerv1.SquadLister
+	now         func() time.Time
+}
+
+var _ query.ScaleUpHinter = &Tracker{}
+var _ Source = &Tracker{}
+
+// NewTracker returns a new Tracker averaging misses over window, of the Squads in squadLister.
+// The misses are shared with the other replicas under identity.
+func NewTracker(window time.Duration, identity string, carrierClient versioned.Interface,
+	squadLister listerv1.SquadLister) *Tracker {
+	return &Tracker{
+		window:        window,
+		identity:      identity,
+		carrierClient: carrierClient,
+		buckets:       make(map[squadKey][]bucket),
+		recorded:      make(map[squadKey]struct{}),
+		squadLister:   squadLister,
+		now:           time.Now,
+	}
+}
+
+// Run prunes the misses out of the window of all Squads, forgets the Squads deleted and
+// shares the misses with the other replicas periodically. Will block until stop is closed.
+func (t *Tracker) Run(stop <-chan struct{}) {
+	wait.Until(func() {
+		t.prune()
+		t.share()
+	}, shareInterval, stop)
+}
+
+// HintScaleUp records q as an unfulfilled allocation of its Squad. Queries not naming both the
+// namespace and an existing Squad could not be attributed, and are ignored.
+func (t *Tracker) HintScaleUp(q *query.Query) {
+	if len(q.Namespace) == 0 || len(q.Squad) == 0 {
+		return
+	}
+	if _, err := t.squadLister.Squads(q.Namespace).Get(q.Squad); err != nil {
+		return
+	}
+	key := squadKey{namespace: q.Namespace, name: q.Squad}
+	metrics.RecordSquadUnfulfilledAllocation(q.Namespace, q.Squad)
+	second := t.now().Unix()
+	t.Lock()
+	defer t.Unlock()
+	t.recorded[key] = struct{}{}
+	buckets := t.pruneLocked(key, second)
+	if n := len(buckets); n != 0 && buckets[n-1].second == second {
+		buckets[n-1].misses++
+	} else {
+		buckets = append(buckets, bucket{second: second, misses: 1})
+	}
+	t.buckets[key] = buckets
+}
+
+// PerMinute returns the unfulfilled allocations per minute of Squad over the window, tracked by
+// this replica and shared by the others.
+func (t *Tracker) PerMinute(namespace, squad string) float64 {
+	perMinute := t.localPerMinute(squadKey{namespace: namespace, name: squad})
+	sqd, err := t.squadLister.Squads(namespace).Get(squad)
+	if err != nil {
+		return perMinute
+	}
+	now := t.now()
+	for identity, shared := range getSharedDemand(sqd) {
+		if identity != t.identity && now.Sub(shared.Time.Time) < t.window {
+			perMinute += shared.PerMinute
+		}
+	}
+	return perMinute
+}
+
+// localPerMinute returns the unfulfilled allocations per minute of key tracked by this replica.
+func (t *Tracker) localPerMinute(key squadKey) float64 {
+	t.Lock()
+	defer t.Unlock()
+	var total int32
+	for _, b := range t.pruneLocked(key, t.now().Unix()) {
+		total += b.misses
+	}
+	return float64(total) / t.window.Minutes()
+}
+
+// share records the misses of this replica in the Squads it tracks, and drops the misses
+// shared by replicas gone. Misses not changed are shared again only when half of the
+// window passed, so the Squads are not updated on every period.
+func (t *Tracker) share() {
+	t.Lock()
+	keys := make([]squadKey, 0, len(t.recorded))
+	for key := range t.recorded {
+		keys = append(keys, key)
+	}
+	t.Unlock()
+	for _, key := range keys {
+		perMinute := t.localPerMinute(key)
+		sqd, err := t.squadLister.Squads(key.namespace).Get(key.name)
+		if err != nil {
+			continue
+		}
+		now := t.now()
+		shared := getSharedDemand(sqd)
+		own, ok := shared[t.identity]
+		if (!ok && perMinute == 0) ||
+			(ok && own.PerMinute == perMinute && now.Sub(own.Time.Time) < t.window/2) {
+			continue
+		}
+		for identity, other := range shared {
+			if now.Sub(other.Time.Time) >= t.window {
+				delete(shared, identity)
+			}
+		}
+		if perMinute == 0 {
+			delete(shared, t.identity)
+		} else {
+			if shared == nil {
+				shared = make(map[string]sharedDemand)
+			}
+			shared[t.identity] = sharedDemand{PerMinute: perMinute, Time: metav1.NewTime(now)}
+		}
+		if err := t.updateSharedDemand(sqd, shared); err != nil {
+			// shared again on next period.
+			klog.V(4).Infof("Failed to share unfulfilled allocations of Squad %v/%v: %v",
+				sqd.Namespace, sqd.Name, err)
+		}
+	}
+}
+
+// updateSharedDemand updates the annotation of squad to the misses shared by replicas.
+func (t *Tracker) updateSharedDemand(squad *carrierv1alpha1.Squad, shared map[string]sharedDemand) error {
+	squadCopy := squad.DeepCopy()
+	if len(shared) == 0 {
+		delete(squadCopy.Annotations, util.SquadUnfulfilledAllocationsAnnotation)
+	} else {
+		data, err := json.Marshal(shared)
+		if err != nil {
+			return err
+		}
+		if squadCopy.Annotations == nil {
+			squadCopy.Annotations = make(map[string]string)
+		}
+		squadCopy.Annotations[util.SquadUnfulfilledAllocationsAnnotation] = string(data)
+	}
+	_, err := t.carrierClient.CarrierV1alpha1().Squads(squad.Namespace).Update(squadCopy)
+	return err
+}
+
+// getSharedDemand returns the misses shared by replicas in the annotation of squad, nil if not
+// set or invalid.
+func getSharedDemand(squad *carrierv1alpha1.Squad) map[string]sharedDemand {
+	value, ok := squad.Annotations[util.SquadUnfulfilledAllocationsAnnotation]
+	if !ok {
+		return nil
+	}
+	var shared map[string]sharedDemand
+	if err := json.Unmarshal([]byte(value), &shared); err != nil {
+		return nil
+	}
+	return shared
+}
+
+// prune drops the misses out of the window of all Squads, and the metrics of Squads deleted.
+func (t *Tracker) prune() {
+	t.Lock()
+	defer t.Unlock()
+	second := t.now().Unix()
+	for key := range t.buckets {
+		t.pruneLocked(key, second)
+	}
+	for key := range t.recorded {
+		if _, err := t.squadLister.Squads(key.namespace).Get(key.name); k8serrors.IsNotFound(err) {
+			metrics.DeleteSquadUnfulfilledAllocations(key.namespace, key.name)
+			delete(t.recorded, key)
+			delete(t.buckets, key)
+		}
+	}
+}
+
+// pruneLocked drops the buckets of key out of the window ending at second.
+func (t *Tracker) pruneLocked(key squadKey, second int64) []bucket {
+	buckets := t.buckets[key]
+	start := second - int64(t.window.Seconds())
+	i := 0
+	for i < len(buckets) && buckets[i].second <= start {
+		i++
+	}
+	buckets = buckets[i:]
+	if len(buckets) == 0 {
+		delete(t.buckets, key)
+	} else {
+		t.buckets[key] = buckets
+	}
+	return buckets
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package demand
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/query"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// newSquadIndexer returns an indexer holding Squads of names in namespace default.
+func newSquadIndexer(names ...string) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, name := range names {
+		indexer.Add(&carrierv1alpha1.Squad{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})
+	}
+	return indexer
+}
+
+func TestTracker(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tracker := NewTracker(2*time.Minute, "a", fake.NewSimpleClientset(),
+		listerv1.NewSquadLister(newSquadIndexer("squad")))
+	tracker.now = func() time.Time { return now }
+	q := &query.Query{Namespace: "default", Squad: "squad"}
+	for i := 0; i < 3; i++ {
+		tracker.HintScaleUp(q)
+	}
+	tracker.HintScaleUp(&query.Query{Squad: "squad"})
+	tracker.HintScaleUp(&query.Query{Namespace: "default", Squad: "missing"})
+	if len(tracker.buckets) != 1 || len(tracker.recorded) != 1 {
+		t.Errorf("desired only existing Squad tracked, get %v", tracker.buckets)
+	}
+	now = now.Add(time.Minute)
+	tracker.HintScaleUp(q)
+	if rate := tracker.PerMinute("default", "squad"); rate != 2 {
+		t.Errorf("desired 2 per minute, get %v", rate)
+	}
+	if rate := tracker.PerMinute("other", "squad"); rate != 0 {
+		t.Errorf("desired no misses of other namespace, get %v", rate)
+	}
+	// the first misses are out of the window
+	now = now.Add(90 * time.Second)
+	if rate := tracker.PerMinute("default", "squad"); rate != 0.5 {
+		t.Errorf("desired 0.5 per minute, get %v", rate)
+	}
+	now = now.Add(time.Minute)
+	if rate := tracker.PerMinute("default", "squad"); rate != 0 {
+		t.Errorf("desired 0 per minute, get %v", rate)
+	}
+	if len(tracker.buckets) != 0 {
+		t.Errorf("desired buckets released, get %v", tracker.buckets)
+	}
+}
+
+func TestTrackerPrune(t *testing.T) {
+	now := time.Unix(1000, 0)
+	indexer := newSquadIndexer("a", "b")
+	tracker := NewTracker(time.Minute, "a", fake.NewSimpleClientset(), listerv1.NewSquadLister(indexer))
+	tracker.now = func() time.Time { return now }
+	tracker.HintScaleUp(&query.Query{Namespace: "default", Squad: "a"})
+	tracker.HintScaleUp(&query.Query{Namespace: "default", Squad: "b"})
+	obj, _, _ := indexer.GetByKey("default/b")
+	indexer.Delete(obj)
+	tracker.prune()
+	if _, ok := tracker.recorded[squadKey{namespace: "default", name: "b"}]; ok || len(tracker.buckets) != 1 {
+		t.Errorf("desired deleted Squad forgotten, get %v", tracker.buckets)
+	}
+	now = now.Add(2 * time.Minute)
+	tracker.prune()
+	if len(tracker.buckets) != 0 {
+		t.Errorf("desired misses out of window pruned, get %v", tracker.buckets)
+	}
+}
+
+func TestTrackerShared(t *testing.T) {
+	now := time.Unix(1000, 0)
+	squad := &carrierv1alpha1.Squad{ObjectMeta: metav1.ObjectMeta{Name: "squad", Namespace: "default",
+		Annotations: map[string]string{util.SquadUnfulfilledAllocationsAnnotation: fmt.Sprintf(
+			`{"a":{"perMinute":9,"time":%q},"b":{"perMinute":3,"time":%q},"c":{"perMinute":5,"time":%q}}`,
+			now.Format(time.RFC3339), now.Add(-30*time.Second).Format(time.RFC3339),
+			now.Add(-5*time.Minute).Format(time.RFC3339))}}}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	indexer.Add(squad)
+	client := fake.NewSimpleClientset(squad)
+	tracker := NewTracker(2*time.Minute, "a", client, listerv1.NewSquadLister(indexer))
+	tracker.now = func() time.Time { return now }
+	tracker.HintScaleUp(&query.Query{Namespace: "default", Squad: "squad"})
+	// misses of b, not the stale ones of c or the ones shared by this replica before
+	if rate := tracker.PerMinute("default", "squad"); rate != 3.5 {
+		t.Errorf("desired 3.5 per minute, get %v", rate)
+	}
+
+	tracker.share()
+	updated, err := client.CarrierV1alpha1().Squads("default").Get("squad", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := getSharedDemand(updated)
+	if len(shared) != 2 || shared["a"].PerMinute != 0.5 || shared["b"].PerMinute != 3 {
+		t.Errorf("desired misses of a and b shared, get %v", shared)
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	tests := []struct {
+		buffer    int32
+		perMinute float64
+		lead      time.Duration
+		desired   int32
+	}{
+		{buffer: 5, perMinute: 0, lead: time.Minute, desired: 5},
+		{buffer: 5, perMinute: 3, lead: 2 * time.Minute, desired: 11},
+		{buffer: 0, perMinute: 0.5, lead: 30 * time.Second, desired: 1},
+	}
+	for _, test := range tests {
+		if got := Buffer(test.buffer, test.perMinute, test.lead); got != test.desired {
+			t.Errorf("buffer %v at %v per minute for %v desired %v, get %v",
+				test.buffer, test.perMinute, test.lead, test.desired, got)
+		}
+	}
+}
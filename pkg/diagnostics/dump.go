@@ -0,0 +1,52 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"syscall"
+
+	"k8s.io/klog"
+)
+
+// DumpGoroutinesOnSignal writes the stacks of all goroutines to stderr whenever the process
+// receives SIGQUIT, instead of the default of dumping and exiting. Will stop handling SIGQUIT
+// once stop is closed.
+func DumpGoroutinesOnSignal(stop <-chan struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGQUIT)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+				klog.Info("Received SIGQUIT, dumping goroutines")
+				if err := dumpGoroutines(os.Stderr); err != nil {
+					klog.Errorf("Failed to dump goroutines: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// dumpGoroutines writes the stacks of all goroutines to w, in the format of unrecovered panics.
+func dumpGoroutines(w io.Writer) error {
+	return pprof.Lookup("goroutine").WriteTo(w, 2)
+}
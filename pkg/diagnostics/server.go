@@ -0,0 +1,73 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+
+	"k8s.io/klog"
+)
+
+var publishOnce sync.Once
+
+// Server serves the pprof endpoints under /debug/pprof/ and expvar under /debug/vars. It should
+// be bound to a loopback or otherwise private address, as profiles reveal the internals of the
+// process and CPU profiles slow it down.
+type Server struct {
+	addr string
+	mux  *http.ServeMux
+}
+
+// NewServer returns a new diagnostics server listening on addr, e.g. "127.0.0.1:6060".
+func NewServer(addr string) *Server {
+	publishOnce.Do(func() {
+		expvar.Publish("goroutines", expvar.Func(func() interface{} {
+			return runtime.NumGoroutine()
+		}))
+	})
+	s := &Server{
+		addr: addr,
+		mux:  http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	s.mux.Handle("/debug/vars", expvar.Handler())
+	return s
+}
+
+// Run starts the diagnostics server. Will block until stop is closed.
+func (s *Server) Run(stop <-chan struct{}) error {
+	server := &http.Server{
+		Addr:    s.addr,
+		Handler: s.mux,
+	}
+	go func() {
+		<-stop
+		server.Close()
+	}()
+	klog.Infof("Starting diagnostics server on %v", s.addr)
+	err := server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	s := NewServer("127.0.0.1:0")
+	// NewServer could be called more than once.
+	NewServer("127.0.0.1:0")
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/vars"} {
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("desired %v served, get %v", path, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest("GET", "/debug/vars", nil))
+	if !strings.Contains(w.Body.String(), `"goroutines"`) {
+		t.Errorf("desired goroutines published, get %v", w.Body.String())
+	}
+}
+
+func TestDumpGoroutines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := dumpGoroutines(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "TestDumpGoroutines") {
+		t.Errorf("desired stack of the test goroutine, get %v", buf.String())
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ocgi/carrier/pkg/apis/carrier"
+)
+
+const (
+	// cloudEventsSpecVersion is the version of CloudEvents spec implemented.
+	cloudEventsSpecVersion = "1.0"
+	// cloudEventsContentType is the content type of CloudEvents in structured mode.
+	cloudEventsContentType = "application/cloudevents+json"
+	// cloudEventsTypePrefix is the prefix of CloudEvents type, in reverse-DNS form.
+	cloudEventsTypePrefix = "dev.ocgi.carrier.gameserver."
+)
+
+// CloudEvent is an event in CloudEvents JSON format.
+type CloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject,omitempty"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            *Event    `json:"data"`
+}
+
+// NewCloudEvent wraps event as a CloudEvent, e.g. the type of a Ready event is
+// "dev.ocgi.carrier.gameserver.ready", the source is the path of GameServers in
+// the namespace and the subject is the name of the GameServer.
+func NewCloudEvent(event *Event) *CloudEvent {
+	return &CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              event.ID,
+		Source:          fmt.Sprintf("/apis/%s/v1alpha1/namespaces/%s/gameservers", carrier.GroupName, event.Namespace),
+		Type:            cloudEventsTypePrefix + strings.ToLower(string(event.Type)),
+		Subject:         event.Name,
+		Time:            event.Time,
+		DataContentType: "application/json",
+		Data:            event,
+	}
+}
+
+// encodeCloudEvent encodes event in CloudEvents structured mode.
+func encodeCloudEvent(event *Event) (string, []byte, error) {
+	body, err := json.Marshal(NewCloudEvent(event))
+	return cloudEventsContentType, body, err
+}
@@ -0,0 +1,227 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=carrier.ocgi.dev,resources=gameservers,verbs=get;list;watch;update
+
+// finalizer holds GameServers being deleted until their Deleted event is published.
+const finalizer = "carrier.ocgi.dev/eventbus"
+
+// Controller publishes GameServer lifecycle events at least once. The events published for
+// each GameServer are recorded in its published events annotation only after the targets
+// accept them, and the events of the current state of a GameServer not recorded yet are
+// published on every sync, so events failed to publish, pending when the leader stops, or of
+// transitions happening while no leader runs, are published by the next leader. The same
+// event may be delivered more than once with the same ID, receivers should deduplicate by ID.
+// GameServers carry a finalizer until their Deleted event is published, only the Deleted
+// events of GameServers deleted before the controller adds the finalizer are best effort.
+// States entered and left while no leader runs are not published. Events are only collected
+// after Run, so only the leader publishes them.
+type Controller struct {
+	carrierClient    versioned.Interface
+	gameServerLister listerv1.GameServerLister
+	gameServerSynced cache.InformerSynced
+	publisher        Publisher
+	// queue holds the keys of GameServers to sync, and the best effort Deleted events.
+	queue workqueue.RateLimitingInterface
+	// started is 1 once Run is called.
+	started int32
+}
+
+// NewController returns a new lifecycle event controller.
+func NewController(carrierClient versioned.Interface,
+	carrierInformerFactory externalversions.SharedInformerFactory, publisher Publisher) *Controller {
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	c := &Controller{
+		carrierClient:    carrierClient,
+		gameServerLister: gameServers.Lister(),
+		gameServerSynced: gameServers.Informer().HasSynced,
+		publisher:        publisher,
+		queue:            workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "eventbus"),
+	}
+	gameServers.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueGameServer,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueGameServer(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			gs, ok := obj.(*carrierv1alpha1.GameServer)
+			if !ok || hasFinalizer(gs) {
+				return
+			}
+			// the Deleted event of GameServers with the finalizer is published before it is
+			// removed, the ones deleted before the finalizer is added are published here.
+			if published := getPublished(gs); !published[EventDeleted].Active {
+				c.enqueue(NewEvent(EventDeleted, gs, published[EventDeleted].Count+1))
+			}
+		},
+	})
+	return c
+}
+
+// Run publishes events. Will block until stop is closed.
+func (c *Controller) Run(workers int, stop <-chan struct{}) error {
+	defer c.queue.ShutDown()
+	atomic.StoreInt32(&c.started, 1)
+	klog.Info("Wait for cache sync")
+	if !cache.WaitForCacheSync(stop, c.gameServerSynced) {
+		return errors.New("failed to wait for caches to sync")
+	}
+	// GameServers listed before Run are not enqueued by the handlers.
+	list, err := c.gameServerLister.List(labels.Everything())
+	if err != nil {
+		return errors.Wrap(err, "error listing GameServers")
+	}
+	for _, gs := range list {
+		c.enqueueGameServer(gs)
+	}
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stop)
+	}
+	<-stop
+	return nil
+}
+
+func (c *Controller) enqueueGameServer(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.enqueue(key)
+}
+
+func (c *Controller) enqueue(item interface{}) {
+	if atomic.LoadInt32(&c.started) == 0 {
+		return
+	}
+	c.queue.Add(item)
+}
+
+func (c *Controller) worker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *Controller) processNextWorkItem() bool {
+	item, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(item)
+	var err error
+	switch item := item.(type) {
+	case string:
+		err = c.syncGameServer(item)
+	case *Event:
+		err = errors.Wrapf(c.publisher.Publish(item), "error publishing event %v", item.ID)
+	}
+	if err != nil {
+		utilruntime.HandleError(err)
+		c.queue.AddRateLimited(item)
+		return true
+	}
+	c.queue.Forget(item)
+	return true
+}
+
+// syncGameServer publishes the events of the state of the GameServer not published yet in
+// order, records them, and adds the finalizer, or removes it once the Deleted event is published.
+// If the record fails, the next sync publishes the events again with the same IDs.
+func (c *Controller) syncGameServer(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	gs, err := c.gameServerLister.GameServers(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	published := getPublished(gs)
+	pending, changed := pendingEvents(gs, published)
+	var publishErr error
+	for _, event := range pending {
+		if publishErr = c.publisher.Publish(event); publishErr != nil {
+			publishErr = errors.Wrapf(publishErr, "error publishing event %v", event.ID)
+			break
+		}
+		markPublished(published, event)
+		changed = true
+	}
+	gsCopy := gs.DeepCopy()
+	if changed {
+		setPublished(gsCopy, published)
+	}
+	switch {
+	case gs.DeletionTimestamp == nil && !hasFinalizer(gs):
+		gsCopy.Finalizers = append(gsCopy.Finalizers, finalizer)
+		changed = true
+	case gs.DeletionTimestamp != nil && hasFinalizer(gs) && published[EventDeleted].Active:
+		gsCopy.Finalizers = removeFinalizer(gsCopy.Finalizers)
+		changed = true
+	}
+	if changed {
+		if _, err := c.carrierClient.CarrierV1alpha1().GameServers(namespace).Update(gsCopy); err != nil {
+			return errors.Wrapf(err, "error recording events published for GameServer %v", key)
+		}
+	}
+	return publishErr
+}
+
+// hasFinalizer returns true if gs has the event bus finalizer.
+func hasFinalizer(gs *carrierv1alpha1.GameServer) bool {
+	for _, f := range gs.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// removeFinalizer returns finalizers without the event bus finalizer.
+func removeFinalizer(finalizers []string) []string {
+	var fin []string
+	for _, f := range finalizers {
+		if f != finalizer {
+			fin = append(fin, f)
+		}
+	}
+	return fin
+}
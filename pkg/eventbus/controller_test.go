@@ -0,0 +1,102 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	gsfake "github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+)
+
+// fakePublisher records the IDs of events published, and fails while failing is set.
+type fakePublisher struct {
+	ids     []string
+	failing bool
+}
+
+func (p *fakePublisher) Publish(event *Event) error {
+	if p.failing {
+		return errors.New("target unavailable")
+	}
+	p.ids = append(p.ids, event.ID)
+	return nil
+}
+
+func newTestController(gs *carrierv1alpha1.GameServer) (*Controller, *gsfake.Clientset, *fakePublisher) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(gs)
+	carrierClient := gsfake.NewSimpleClientset(gs)
+	publisher := &fakePublisher{}
+	return &Controller{
+		carrierClient:    carrierClient,
+		gameServerLister: listerv1.NewGameServerLister(indexer),
+		publisher:        publisher,
+	}, carrierClient, publisher
+}
+
+func TestSyncGameServer(t *testing.T) {
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default", UID: "123"},
+		Status:     carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning},
+	}
+	c, carrierClient, publisher := newTestController(gs)
+	publisher.failing = true
+	if err := c.syncGameServer("default/gs"); err == nil {
+		t.Fatalf("desired error while the target is unavailable")
+	}
+	// the next leader publishes the events not recorded.
+	c, carrierClient, publisher = newTestController(gs)
+	if err := c.syncGameServer("default/gs"); err != nil {
+		t.Fatal(err)
+	}
+	if desired := []string{"123-Created-1", "123-Ready-1"}; !reflect.DeepEqual(desired, publisher.ids) {
+		t.Errorf("desired events %v, get: %v", desired, publisher.ids)
+	}
+	updated, err := carrierClient.CarrierV1alpha1().GameServers("default").Get("gs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasFinalizer(updated) {
+		t.Errorf("desired finalizer added")
+	}
+	published := getPublished(updated)
+	if !published[EventCreated].Active || !published[EventReady].Active {
+		t.Errorf("desired created and ready recorded, get: %v", published)
+	}
+
+	now := metav1.Now()
+	updated.DeletionTimestamp = &now
+	c, carrierClient, publisher = newTestController(updated)
+	if err := c.syncGameServer("default/gs"); err != nil {
+		t.Fatal(err)
+	}
+	if desired := []string{"123-Deleted-1"}; !reflect.DeepEqual(desired, publisher.ids) {
+		t.Errorf("desired events %v, get: %v", desired, publisher.ids)
+	}
+	deleted, err := carrierClient.CarrierV1alpha1().GameServers("default").Get("gs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasFinalizer(deleted) {
+		t.Errorf("desired finalizer removed once deleted event published")
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eventbus publishes GameServer lifecycle events to external targets,
+// so backend services can react without watching the Kubernetes API.
+//
+// Events are posted to webhooks, produced to Kafka by Kafka REST Proxy, or published
+// to NATS JetStream, and delivered at least once. GameServers carry the
+// carrier.ocgi.dev/eventbus finalizer until their Deleted event is published, which
+// must be removed by hand from GameServers left over once the event bus is disabled.
+package eventbus
@@ -0,0 +1,184 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// EventType is the lifecycle transition of a GameServer.
+type EventType string
+
+const (
+	// EventCreated is published when a GameServer is created.
+	EventCreated EventType = "Created"
+	// EventReady is published when a GameServer becomes Running and ready.
+	EventReady EventType = "Ready"
+	// EventAllocated is published when a GameServer starts hosting players.
+	EventAllocated EventType = "Allocated"
+	// EventOutOfService is published when a GameServer is marked out of service.
+	EventOutOfService EventType = "OutOfService"
+	// EventExited is published when a GameServer exits.
+	EventExited EventType = "Exited"
+	// EventFailed is published when a GameServer fails.
+	EventFailed EventType = "Failed"
+	// EventCompleted is published when a OneShot GameServer exits successfully.
+	EventCompleted EventType = "Completed"
+	// EventDeleted is published when a GameServer is deleted.
+	EventDeleted EventType = "Deleted"
+)
+
+// eventTypes are the event types in the order they are published.
+var eventTypes = []EventType{
+	EventCreated, EventReady, EventAllocated, EventOutOfService,
+	EventExited, EventFailed, EventCompleted, EventDeleted,
+}
+
+// Event is a lifecycle event of a GameServer.
+type Event struct {
+	// ID identifies the event, the same event delivered more than once has the same ID.
+	// It is made of the UID of the GameServer, the event type and the number of times the
+	// transition happened, so transitions happening again, e.g. ready after not ready,
+	// have different IDs.
+	ID string `json:"id"`
+	// Type of the event
+	Type EventType `json:"type"`
+	// Time when the event happened
+	Time time.Time `json:"time"`
+	// Namespace of the GameServer
+	Namespace string `json:"namespace"`
+	// Name of the GameServer
+	Name string `json:"name"`
+	// UID of the GameServer
+	UID types.UID `json:"uid"`
+	// GameServerSet owning the GameServer
+	GameServerSet string `json:"gameServerSet,omitempty"`
+	// Squad owning the GameServer
+	Squad string `json:"squad,omitempty"`
+	// State of the GameServer
+	State carrierv1alpha1.GameServerState `json:"state,omitempty"`
+	// NodeName of the GameServer
+	NodeName string `json:"nodeName,omitempty"`
+}
+
+// NewEvent returns the count-th lifecycle event of eventType of gs.
+func NewEvent(eventType EventType, gs *carrierv1alpha1.GameServer, count int) *Event {
+	return &Event{
+		ID:            fmt.Sprintf("%v-%v-%v", gs.UID, eventType, count),
+		Type:          eventType,
+		Time:          time.Now(),
+		Namespace:     gs.Namespace,
+		Name:          gs.Name,
+		UID:           gs.UID,
+		GameServerSet: gs.Labels[util.GameServerSetLabelKey],
+		Squad:         gs.Labels[util.SquadNameLabelKey],
+		State:         gs.Status.State,
+		NodeName:      gs.Status.NodeName,
+	}
+}
+
+// isActive returns true if gs is in the state eventType is published on entering.
+func isActive(eventType EventType, gs *carrierv1alpha1.GameServer) bool {
+	switch eventType {
+	case EventCreated:
+		return true
+	case EventReady:
+		return gs.Status.State == carrierv1alpha1.GameServerRunning && gameservers.IsReady(gs)
+	case EventAllocated:
+		return gameservers.IsAllocated(gs)
+	case EventOutOfService:
+		return gameservers.IsOutOfService(gs)
+	case EventExited:
+		return gs.Status.State == carrierv1alpha1.GameServerExited
+	case EventFailed:
+		return gs.Status.State == carrierv1alpha1.GameServerFailed
+	case EventCompleted:
+		return gs.Status.State == carrierv1alpha1.GameServerCompleted
+	case EventDeleted:
+		return gs.DeletionTimestamp != nil
+	}
+	return false
+}
+
+// publishedEvent records the events of a type published for a GameServer.
+type publishedEvent struct {
+	// Count is the number of events of the type published.
+	Count int `json:"count"`
+	// Active is true if the GameServer has not left the state since the last event.
+	Active bool `json:"active,omitempty"`
+}
+
+// getPublished returns the events published for gs, empty if the annotation is missing or
+// corrupted, in which case all events of the current state are published again.
+func getPublished(gs *carrierv1alpha1.GameServer) map[EventType]publishedEvent {
+	published := make(map[EventType]publishedEvent)
+	value, ok := gs.Annotations[util.PublishedEventsAnnotation]
+	if !ok {
+		return published
+	}
+	if err := json.Unmarshal([]byte(value), &published); err != nil {
+		klog.Warningf("Ignore corrupted %v of GameServer %v/%v: %v",
+			util.PublishedEventsAnnotation, gs.Namespace, gs.Name, err)
+		return make(map[EventType]publishedEvent)
+	}
+	return published
+}
+
+// setPublished records the events published for gs.
+func setPublished(gs *carrierv1alpha1.GameServer, published map[EventType]publishedEvent) {
+	value, _ := json.Marshal(published)
+	if gs.Annotations == nil {
+		gs.Annotations = make(map[string]string)
+	}
+	gs.Annotations[util.PublishedEventsAnnotation] = string(value)
+}
+
+// pendingEvents returns the events of the state of gs not published yet in order, and the
+// published record with the states gs left marked inactive, so they are published again
+// when gs enters them again.
+func pendingEvents(gs *carrierv1alpha1.GameServer,
+	published map[EventType]publishedEvent) ([]*Event, bool) {
+	var pending []*Event
+	changed := false
+	for _, eventType := range eventTypes {
+		record := published[eventType]
+		if !isActive(eventType, gs) {
+			if record.Active {
+				record.Active = false
+				published[eventType] = record
+				changed = true
+			}
+			continue
+		}
+		if !record.Active {
+			pending = append(pending, NewEvent(eventType, gs, record.Count+1))
+		}
+	}
+	return pending, changed
+}
+
+// markPublished records event as published.
+func markPublished(published map[EventType]publishedEvent, event *Event) {
+	published[event.Type] = publishedEvent{Count: published[event.Type].Count + 1, Active: true}
+}
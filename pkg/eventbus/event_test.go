@@ -0,0 +1,134 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"reflect"
+	"testing"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestPendingEvents(t *testing.T) {
+	effective := true
+	running := &carrierv1alpha1.GameServer{
+		Status: carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning},
+	}
+	allocated := running.DeepCopy()
+	allocated.Annotations = map[string]string{util.GameServerPlayersAnnotation: "3"}
+	outOfService := allocated.DeepCopy()
+	outOfService.Spec.Constraints = []carrierv1alpha1.Constraint{
+		{Type: carrierv1alpha1.NotInService, Effective: &effective},
+	}
+	exited := outOfService.DeepCopy()
+	exited.Status.State = carrierv1alpha1.GameServerExited
+	tests := []struct {
+		name      string
+		gs        *carrierv1alpha1.GameServer
+		published map[EventType]publishedEvent
+		desired   []string
+		changed   bool
+	}{
+		{
+			name:      "created and ready",
+			gs:        running,
+			published: map[EventType]publishedEvent{},
+			desired:   []string{"-Created-1", "-Ready-1"},
+		},
+		{
+			name: "allocated",
+			gs:   allocated,
+			published: map[EventType]publishedEvent{
+				EventCreated: {Count: 1, Active: true},
+				EventReady:   {Count: 1, Active: true},
+			},
+			desired: []string{"-Allocated-1"},
+		},
+		{
+			name: "allocated again",
+			gs:   allocated,
+			published: map[EventType]publishedEvent{
+				EventCreated:   {Count: 1, Active: true},
+				EventReady:     {Count: 1, Active: true},
+				EventAllocated: {Count: 1},
+			},
+			desired: []string{"-Allocated-2"},
+		},
+		{
+			name: "out of service",
+			gs:   outOfService,
+			published: map[EventType]publishedEvent{
+				EventCreated:   {Count: 1, Active: true},
+				EventReady:     {Count: 1, Active: true},
+				EventAllocated: {Count: 1, Active: true},
+			},
+			desired: []string{"-OutOfService-1"},
+		},
+		{
+			name: "exited",
+			gs:   exited,
+			published: map[EventType]publishedEvent{
+				EventCreated:      {Count: 1, Active: true},
+				EventReady:        {Count: 1, Active: true},
+				EventAllocated:    {Count: 1, Active: true},
+				EventOutOfService: {Count: 1, Active: true},
+			},
+			desired: []string{"-Exited-1"},
+			changed: true,
+		},
+		{
+			name: "no change",
+			gs:   running,
+			published: map[EventType]publishedEvent{
+				EventCreated: {Count: 1, Active: true},
+				EventReady:   {Count: 1, Active: true},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pending, changed := pendingEvents(tc.gs, tc.published)
+			var ids []string
+			for _, event := range pending {
+				ids = append(ids, event.ID)
+			}
+			if !reflect.DeepEqual(tc.desired, ids) {
+				t.Errorf("desired events %v, get: %v", tc.desired, ids)
+			}
+			if tc.changed != changed {
+				t.Errorf("desired changed %v, get: %v", tc.changed, changed)
+			}
+		})
+	}
+}
+
+func TestNewCloudEvent(t *testing.T) {
+	gs := &carrierv1alpha1.GameServer{}
+	gs.Name = "test"
+	gs.Namespace = "default"
+	gs.UID = "123"
+	gs.ResourceVersion = "5"
+	event := NewCloudEvent(NewEvent(EventReady, gs, 2))
+	if event.Type != "dev.ocgi.carrier.gameserver.ready" {
+		t.Errorf("desired type dev.ocgi.carrier.gameserver.ready, get: %v", event.Type)
+	}
+	if event.Source != "/apis/carrier.ocgi.dev/v1alpha1/namespaces/default/gameservers" {
+		t.Errorf("desired source of GameServers in default, get: %v", event.Source)
+	}
+	if event.ID != "123-Ready-2" || event.Subject != "test" || event.SpecVersion != "1.0" {
+		t.Errorf("desired id 123-Ready-2, subject test and spec version 1.0, get: %+v", event)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// kafkaRESTContentType is the content type of JSON records of Kafka REST Proxy API v2.
+	kafkaRESTContentType = "application/vnd.kafka.json.v2+json"
+	// kafkaRESTAccept is the content type of responses of Kafka REST Proxy API v2.
+	kafkaRESTAccept = "application/vnd.kafka.v2+json"
+)
+
+// kafkaRecord is a record produced to Kafka REST Proxy.
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// kafkaProduceRequest is the request producing records to a topic.
+type kafkaProduceRequest struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+// kafkaProduceResponse is the response of producing records, with an offset per record.
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+// kafkaPublisher produces events to a Kafka topic through Kafka REST Proxy.
+type kafkaPublisher struct {
+	url    string
+	client *http.Client
+	encode encodeFunc
+}
+
+// NewKafkaPublisher returns a publisher producing events in encoding to topic by the Kafka
+// REST Proxy at restURL, keyed by the UID of GameServer so the events of a GameServer are
+// kept in order in a partition. Events are only accepted once the proxy acknowledges the
+// record, so the topic should be written with acks=all to survive broker failures.
+func NewKafkaPublisher(restURL, topic, encoding string) (Publisher, error) {
+	if len(topic) == 0 {
+		return nil, errors.New("kafka topic is required")
+	}
+	if _, err := url.Parse(restURL); err != nil {
+		return nil, errors.Wrap(err, "invalid kafka rest url")
+	}
+	encode, err := newEncodeFunc(encoding)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaPublisher{
+		url:    fmt.Sprintf("%s/topics/%s", strings.TrimSuffix(restURL, "/"), url.PathEscape(topic)),
+		client: &http.Client{Timeout: webhookTimeout},
+		encode: encode,
+	}, nil
+}
+
+// Publish produces the encoded event, any non 2xx response or record error is an error.
+func (p *kafkaPublisher) Publish(event *Event) error {
+	_, value, err := p.encode(event)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(&kafkaProduceRequest{
+		Records: []kafkaRecord{{Key: string(event.UID), Value: value}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaRESTContentType)
+	req.Header.Set("Accept", kafkaRESTAccept)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf("kafka rest proxy returned %v", resp.StatusCode)
+	}
+	produced := &kafkaProduceResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(produced); err != nil {
+		return errors.Wrap(err, "error decoding kafka rest proxy response")
+	}
+	if len(produced.Offsets) != 1 {
+		return errors.Errorf("kafka rest proxy returned %v offsets for 1 record", len(produced.Offsets))
+	}
+	if offset := produced.Offsets[0]; offset.ErrorCode != nil || offset.Error != nil {
+		message := ""
+		if offset.Error != nil {
+			message = *offset.Error
+		}
+		return errors.Errorf("kafka rest proxy failed to produce record: %v", message)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ocgi/carrier/pkg/tenancy"
+)
+
+const webhookTimeout = 10 * time.Second
+
+const (
+	// EncodingJSON encodes an event as JSON.
+	EncodingJSON = "json"
+	// EncodingCloudEvents encodes an event in CloudEvents structured mode.
+	EncodingCloudEvents = "cloudevents"
+)
+
+// encodeFunc encodes an event, returns the content type and body.
+type encodeFunc func(event *Event) (string, []byte, error)
+
+// encodeJSON encodes an event as JSON.
+func encodeJSON(event *Event) (string, []byte, error) {
+	body, err := json.Marshal(event)
+	return "application/json", body, err
+}
+
+// Publisher publishes events to a target.
+type Publisher interface {
+	// Publish returns nil only if the event is accepted by the target.
+	Publish(event *Event) error
+}
+
+// multiPublisher publishes events to all its publishers.
+type multiPublisher []Publisher
+
+// NewMultiPublisher returns a publisher publishing events to all publishers, the event is
+// published again to all of them if any fails.
+func NewMultiPublisher(publishers ...Publisher) Publisher {
+	if len(publishers) == 1 {
+		return publishers[0]
+	}
+	return multiPublisher(publishers)
+}
+
+// Publish publishes the event to all publishers, stops at the first failing.
+func (p multiPublisher) Publish(event *Event) error {
+	for _, publisher := range p {
+		if err := publisher.Publish(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newEncodeFunc returns the encodeFunc of encoding, which can be json or cloudevents.
+func newEncodeFunc(encoding string) (encodeFunc, error) {
+	switch encoding {
+	case EncodingJSON:
+		return encodeJSON, nil
+	case EncodingCloudEvents:
+		return encodeCloudEvent, nil
+	}
+	return nil, errors.Errorf("unknown event encoding %q", encoding)
+}
+
+// webhookPublisher posts events to a webhook.
+type webhookPublisher struct {
+	url    string
+	client *http.Client
+	encode encodeFunc
+}
+
+// NewWebhookPublisher returns a publisher posting events to url in encoding,
+// which can be json or cloudevents.
+func NewWebhookPublisher(url, encoding string) (Publisher, error) {
+	encode, err := newEncodeFunc(encoding)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookPublisher{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+		encode: encode,
+	}, nil
+}
+
+// Publish posts the encoded event, any non 2xx response is an error.
+func (p *webhookPublisher) Publish(event *Event) error {
+	contentType, body, err := p.encode(event)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Post(p.url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf("event webhook returned %v", resp.StatusCode)
+	}
+	return nil
+}
+
+// tenantPublisher publishes events of the namespaces of a tenant to the webhooks of the
+// tenant only, and events of other namespaces to the fallback publisher.
+type tenantPublisher struct {
+	tenants    *tenancy.Config
+	publishers map[string][]Publisher
+	fallback   Publisher
+}
+
+// NewTenantPublisher returns a publisher isolating the events of tenants, each tenant only
+// receives the events of its own namespaces on its webhook urls in encoding. Events of
+// namespaces not owned by any tenant are published by fallback, dropped if it is nil.
+func NewTenantPublisher(tenants *tenancy.Config, fallback Publisher, encoding string) (Publisher, error) {
+	p := &tenantPublisher{
+		tenants:    tenants,
+		publishers: make(map[string][]Publisher),
+		fallback:   fallback,
+	}
+	for _, tenant := range tenants.Tenants {
+		for _, url := range tenant.WebhookURLs {
+			publisher, err := NewWebhookPublisher(url, encoding)
+			if err != nil {
+				return nil, err
+			}
+			p.publishers[tenant.Name] = append(p.publishers[tenant.Name], publisher)
+		}
+	}
+	return p, nil
+}
+
+// Publish publishes the event to all webhooks of the tenant owning its namespace, the
+// event is published again to all of them if any fails.
+func (p *tenantPublisher) Publish(event *Event) error {
+	tenant := p.tenants.TenantOf(event.Namespace)
+	if tenant == nil {
+		if p.fallback == nil {
+			return nil
+		}
+		return p.fallback.Publish(event)
+	}
+	for _, publisher := range p.publishers[tenant.Name] {
+		if err := publisher.Publish(event); err != nil {
+			return errors.Wrapf(err, "error publishing to tenant %v", tenant.Name)
+		}
+	}
+	return nil
+}
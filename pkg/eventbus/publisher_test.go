@@ -0,0 +1,206 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ocgi/carrier/pkg/tenancy"
+)
+
+// recorder records the namespaces of events posted to it.
+type recorder struct {
+	lock       sync.Mutex
+	namespaces []string
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	event := &Event{}
+	if err := json.NewDecoder(req.Body).Decode(event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.namespaces = append(r.namespaces, event.Namespace)
+}
+
+func TestTenantPublisher(t *testing.T) {
+	tenantA, fallback := &recorder{}, &recorder{}
+	serverA, serverFallback := httptest.NewServer(tenantA), httptest.NewServer(fallback)
+	defer serverA.Close()
+	defer serverFallback.Close()
+	tenants := &tenancy.Config{Tenants: []tenancy.Tenant{
+		{Name: "a", Namespaces: []string{"a-prod"}, WebhookURLs: []string{serverA.URL}},
+		{Name: "b", Namespaces: []string{"b-prod"}},
+	}}
+	if err := tenants.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	fallbackPublisher, err := NewWebhookPublisher(serverFallback.URL, EncodingJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher, err := NewTenantPublisher(tenants, fallbackPublisher, EncodingJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, namespace := range []string{"a-prod", "b-prod", "default"} {
+		if err := publisher.Publish(&Event{Namespace: namespace}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if desired := []string{"a-prod"}; !reflect.DeepEqual(desired, tenantA.namespaces) {
+		t.Errorf("desired tenant events %v, get: %v", desired, tenantA.namespaces)
+	}
+	if desired := []string{"default"}; !reflect.DeepEqual(desired, fallback.namespaces) {
+		t.Errorf("desired fallback events %v, get: %v", desired, fallback.namespaces)
+	}
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	var records []kafkaRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/topics/events" || req.Header.Get("Content-Type") != kafkaRESTContentType {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		produce := &kafkaProduceRequest{}
+		if err := json.NewDecoder(req.Body).Decode(produce); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		records = append(records, produce.Records...)
+		if strings.Contains(string(produce.Records[0].Value), "fail") {
+			w.Write([]byte(`{"offsets":[{"error_code":50002,"error":"broker unavailable"}]}`))
+			return
+		}
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":1}]}`))
+	}))
+	defer server.Close()
+	publisher, err := NewKafkaPublisher(server.URL+"/", "events", EncodingJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := publisher.Publish(&Event{ID: "1", UID: "123", Namespace: "default"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Key != "123" {
+		t.Errorf("desired a record keyed by 123, get: %v", records)
+	}
+	if err := publisher.Publish(&Event{ID: "2", UID: "123", Namespace: "fail"}); err == nil {
+		t.Errorf("desired error on record error")
+	}
+}
+
+// fakeJetStream acknowledges messages published to subject, and records their ids.
+type fakeJetStream struct {
+	listener net.Listener
+	subject  string
+	lock     sync.Mutex
+	ids      []string
+}
+
+func (s *fakeJetStream) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	io.WriteString(conn, "INFO {\"headers\":true}\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "PING":
+			io.WriteString(conn, "PONG\r\n")
+		case "HPUB":
+			headerSize, _ := strconv.Atoi(fields[3])
+			size, _ := strconv.Atoi(fields[4])
+			message := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, message); err != nil {
+				return
+			}
+			if fields[1] != s.subject {
+				fmt.Fprintf(conn, "HMSG %s 1 16 16\r\nNATS/1.0 503\r\n\r\n\r\n", fields[2])
+				continue
+			}
+			for _, header := range strings.Split(string(message[:headerSize]), "\r\n") {
+				if strings.HasPrefix(header, natsMsgIDHeader+": ") {
+					s.lock.Lock()
+					s.ids = append(s.ids, strings.TrimPrefix(header, natsMsgIDHeader+": "))
+					s.lock.Unlock()
+				}
+			}
+			ack := `{"stream":"events","seq":1}`
+			fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", fields[2], len(ack), ack)
+		}
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	server := &fakeJetStream{listener: listener, subject: "events"}
+	go server.serve()
+	publisher, err := NewNATSPublisher("nats://"+listener.Addr().String(), "events", EncodingJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if err := publisher.Publish(&Event{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if desired := []string{"1", "2"}; !reflect.DeepEqual(desired, server.ids) {
+		t.Errorf("desired ids %v, get: %v", desired, server.ids)
+	}
+}
+
+func TestNATSPublisherNoStream(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go (&fakeJetStream{listener: listener, subject: "other"}).serve()
+	publisher, err := NewNATSPublisher("nats://"+listener.Addr().String(), "events", EncodingJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := publisher.Publish(&Event{ID: "1"}); err == nil {
+		t.Errorf("desired error if no stream captures the subject")
+	}
+}
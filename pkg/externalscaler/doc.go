@@ -0,0 +1,22 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package externalscaler implements the gRPC contract of KEDA external scalers backed by
+// the GameServers of Squads, so teams running KEDA autoscale Squads through ScaledObjects.
+// The contract is defined by externalscaler.proto, copied from KEDA, and externalscaler.pb.go
+// is generated from it by hack/update-protos.sh.
+// The server requires mutual TLS, as it answers for the namespace KEDA sets in the requests:
+// triggers set caCert, tlsClientCert and tlsClientKey with a client certificate verified by
+// the client CA of the controller.
+package externalscaler
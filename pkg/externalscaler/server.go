@@ -0,0 +1,289 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package externalscaler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	listerv1alpha1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/demand"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+const (
+	// MetricName is the metric Squads are scaled by, the GameServers desired, one per replica.
+	MetricName = "gameservers"
+
+	// SquadMetadata is the trigger metadata naming the Squad, the ScaledObject name if not set.
+	SquadMetadata = "squad"
+	// BufferMetadata is the trigger metadata of the GameServers kept beyond the allocated ones
+	// for new sessions, 0 if not set.
+	BufferMetadata = "buffer"
+	// LeadSecondsMetadata is the trigger metadata of the time new GameServers take to be ready.
+	// The buffer is raised by the GameServers needed for the unfulfilled allocations during the
+	// lead, which are ignored if not set.
+	LeadSecondsMetadata = "leadSeconds"
+)
+
+// streamInterval is the period activeness is evaluated for StreamIsActive.
+const streamInterval = 5 * time.Second
+
+// Server serves the KEDA external scaler contract from the informer cache. The GameServers
+// desired by a Squad are its allocated GameServers plus the buffer, so the replicas of the
+// ScaledObject follow the sessions hosted.
+// Only clients presenting certificates verified by the client CA are served, as the namespace
+// of requests is trusted to be the one of the ScaledObject, set by KEDA.
+type Server struct {
+	addr         string
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	// allowedNames are the common names of client certificates allowed, any if empty.
+	allowedNames     []string
+	squadLister      listerv1alpha1.SquadLister
+	squadSynced      cache.InformerSynced
+	gameServerLister listerv1alpha1.GameServerLister
+	gameServerSynced cache.InformerSynced
+	// demand reports the unfulfilled allocations of Squads, nil if not tracked.
+	demand demand.Source
+}
+
+var _ ExternalScalerServer = &Server{}
+
+// NewServer returns a new external scaler server listening on port, certFile and keyFile are
+// used for serving TLS, and clientCAFile verifies the client certificates of KEDA, whose
+// common names must be in allowedNames if not empty. The buffer of Squads is raised by the
+// unfulfilled allocations reported by source if it is not nil.
+func NewServer(port int, certFile, keyFile, clientCAFile string, allowedNames []string,
+	carrierInformerFactory externalversions.SharedInformerFactory, source demand.Source) *Server {
+	squads := carrierInformerFactory.Carrier().V1alpha1().Squads()
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	return &Server{
+		addr:             fmt.Sprintf(":%d", port),
+		certFile:         certFile,
+		keyFile:          keyFile,
+		clientCAFile:     clientCAFile,
+		allowedNames:     allowedNames,
+		squadLister:      squads.Lister(),
+		squadSynced:      squads.Informer().HasSynced,
+		gameServerLister: gameServers.Lister(),
+		gameServerSynced: gameServers.Informer().HasSynced,
+		demand:           source,
+	}
+}
+
+// Run starts the external scaler server after the caches synced. Will block until stop is closed.
+func (s *Server) Run(stop <-chan struct{}) error {
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "error loading serving cert")
+	}
+	caData, err := ioutil.ReadFile(s.clientCAFile)
+	if err != nil {
+		return errors.Wrap(err, "error reading client CA")
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caData) {
+		return errors.Errorf("no certificates found in %v", s.clientCAFile)
+	}
+	if !cache.WaitForCacheSync(stop, s.squadSynced, s.gameServerSynced) {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    clientCAs,
+		})),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler) (interface{}, error) {
+			if err := s.authorize(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo,
+			handler grpc.StreamHandler) error {
+			if err := s.authorize(stream.Context()); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	RegisterExternalScalerServer(server, s)
+	go func() {
+		<-stop
+		server.Stop()
+	}()
+	klog.Infof("Starting external scaler server on %v", s.addr)
+	return server.Serve(listener)
+}
+
+// authorize checks the client certificate of ctx has a common name allowed.
+func (s *Server) authorize(ctx context.Context) error {
+	if len(s.allowedNames) == 0 {
+		return nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "no peer found")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return status.Error(codes.Unauthenticated, "no verified client certificate")
+	}
+	name := info.State.VerifiedChains[0][0].Subject.CommonName
+	for _, allowed := range s.allowedNames {
+		if name == allowed {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "client %q is not allowed", name)
+}
+
+// IsActive returns true if the Squad desires any GameServer, so it is scaled from zero.
+func (s *Server) IsActive(ctx context.Context, ref *ScaledObjectRef) (*IsActiveResponse, error) {
+	desired, err := s.desiredGameServers(ref)
+	if err != nil {
+		return nil, err
+	}
+	return &IsActiveResponse{Result: desired > 0}, nil
+}
+
+// StreamIsActive pushes the activeness of the Squad whenever it changes.
+func (s *Server) StreamIsActive(ref *ScaledObjectRef, stream ExternalScaler_StreamIsActiveServer) error {
+	ticker := time.NewTicker(streamInterval)
+	defer ticker.Stop()
+	var sent, last bool
+	for {
+		desired, err := s.desiredGameServers(ref)
+		if err != nil {
+			return err
+		}
+		if active := desired > 0; !sent || active != last {
+			if err := stream.Send(&IsActiveResponse{Result: active}); err != nil {
+				return err
+			}
+			sent, last = true, active
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetMetricSpec returns the metric of GameServers desired, one per replica.
+func (s *Server) GetMetricSpec(ctx context.Context, ref *ScaledObjectRef) (*GetMetricSpecResponse, error) {
+	if _, _, _, err := parseMetadata(ref); err != nil {
+		return nil, err
+	}
+	return &GetMetricSpecResponse{MetricSpecs: []*MetricSpec{{MetricName: MetricName, TargetSize: 1}}}, nil
+}
+
+// GetMetrics returns the GameServers desired by the Squad.
+func (s *Server) GetMetrics(ctx context.Context, req *GetMetricsRequest) (*GetMetricsResponse, error) {
+	if req.ScaledObjectRef == nil {
+		return nil, status.Error(codes.InvalidArgument, "scaledObjectRef is required")
+	}
+	desired, err := s.desiredGameServers(req.ScaledObjectRef)
+	if err != nil {
+		return nil, err
+	}
+	return &GetMetricsResponse{MetricValues: []*MetricValue{
+		{MetricName: req.MetricName, MetricValue: int64(desired)},
+	}}, nil
+}
+
+// desiredGameServers returns the allocated GameServers of the Squad of ref plus its buffer.
+func (s *Server) desiredGameServers(ref *ScaledObjectRef) (int32, error) {
+	name, buffer, lead, err := parseMetadata(ref)
+	if err != nil {
+		return 0, err
+	}
+	sqd, err := s.squadLister.Squads(ref.Namespace).Get(name)
+	if k8serrors.IsNotFound(err) {
+		return 0, status.Errorf(codes.NotFound, "Squad %v/%v not found", ref.Namespace, name)
+	}
+	if err != nil {
+		return 0, status.Error(codes.Internal, err.Error())
+	}
+	list, err := s.gameServerLister.GameServers(sqd.Namespace).List(
+		labels.SelectorFromSet(labels.Set{util.SquadNameLabelKey: sqd.Name}))
+	if err != nil {
+		return 0, status.Error(codes.Internal, err.Error())
+	}
+	var allocated int32
+	for _, gs := range list {
+		if gameservers.IsAllocated(gs) {
+			allocated++
+		}
+	}
+	if s.demand != nil && lead > 0 {
+		buffer = demand.Buffer(buffer, s.demand.PerMinute(sqd.Namespace, sqd.Name), lead)
+	}
+	return allocated + buffer, nil
+}
+
+// parseMetadata returns the Squad name, buffer and lead from the trigger metadata of ref.
+func parseMetadata(ref *ScaledObjectRef) (name string, buffer int32, lead time.Duration, err error) {
+	name = ref.ScalerMetadata[SquadMetadata]
+	if len(name) == 0 {
+		name = ref.Name
+	}
+	if len(name) == 0 || len(ref.Namespace) == 0 {
+		return "", 0, 0, status.Error(codes.InvalidArgument, "namespace and Squad name are required")
+	}
+	if value, ok := ref.ScalerMetadata[BufferMetadata]; ok {
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || n < 0 {
+			return "", 0, 0, status.Errorf(codes.InvalidArgument, "invalid %v %q", BufferMetadata, value)
+		}
+		buffer = int32(n)
+	}
+	if value, ok := ref.ScalerMetadata[LeadSecondsMetadata]; ok {
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || n < 0 {
+			return "", 0, 0, status.Errorf(codes.InvalidArgument, "invalid %v %q", LeadSecondsMetadata, value)
+		}
+		lead = time.Duration(n) * time.Second
+	}
+	return name, buffer, lead, nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package externalscaler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	listerv1alpha1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+type fakeSource map[string]float64
+
+func (f fakeSource) PerMinute(namespace, squad string) float64 {
+	return f[namespace+"/"+squad]
+}
+
+func newGameServer(name, players string) *carrierv1alpha1.GameServer {
+	return &carrierv1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{
+		Name:        name,
+		Namespace:   "default",
+		Labels:      map[string]string{util.SquadNameLabelKey: "squad"},
+		Annotations: map[string]string{util.GameServerPlayersAnnotation: players},
+	}}
+}
+
+func newTestServer(source fakeSource) *Server {
+	squads := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	squads.Add(&carrierv1alpha1.Squad{ObjectMeta: metav1.ObjectMeta{Name: "squad", Namespace: "default"}})
+	gameServers := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	gameServers.Add(newGameServer("gs1", "3"))
+	gameServers.Add(newGameServer("gs2", "1"))
+	gameServers.Add(newGameServer("gs3", "0"))
+	return &Server{
+		squadLister:      listerv1alpha1.NewSquadLister(squads),
+		gameServerLister: listerv1alpha1.NewGameServerLister(gameServers),
+		demand:           source,
+	}
+}
+
+func TestDesiredGameServers(t *testing.T) {
+	s := newTestServer(fakeSource{"default/squad": 6})
+	tests := []struct {
+		name     string
+		ref      *ScaledObjectRef
+		desired  int32
+		code     codes.Code
+		hasError bool
+	}{
+		{name: "allocated", ref: &ScaledObjectRef{Name: "squad", Namespace: "default"}, desired: 2},
+		{name: "buffer", desired: 5, ref: &ScaledObjectRef{Name: "so", Namespace: "default",
+			ScalerMetadata: map[string]string{SquadMetadata: "squad", BufferMetadata: "3"}}},
+		{name: "demand", desired: 6, ref: &ScaledObjectRef{Name: "squad", Namespace: "default",
+			ScalerMetadata: map[string]string{BufferMetadata: "1", LeadSecondsMetadata: "30"}}},
+		{name: "not found", code: codes.NotFound, hasError: true,
+			ref: &ScaledObjectRef{Name: "other", Namespace: "default"}},
+		{name: "invalid buffer", code: codes.InvalidArgument, hasError: true, ref: &ScaledObjectRef{
+			Name: "squad", Namespace: "default", ScalerMetadata: map[string]string{BufferMetadata: "-1"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			desired, err := s.desiredGameServers(test.ref)
+			if test.hasError {
+				if status.Code(err) != test.code {
+					t.Errorf("desired error code %v, get %v", test.code, err)
+				}
+				return
+			}
+			if err != nil || desired != test.desired {
+				t.Errorf("desired %v GameServers, get %v, %v", test.desired, desired, err)
+			}
+		})
+	}
+}
+
+func TestServeGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	RegisterExternalScalerServer(server, newTestServer(nil))
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := NewExternalScalerClient(conn)
+	ref := &ScaledObjectRef{Name: "squad", Namespace: "default", ScalerMetadata: map[string]string{BufferMetadata: "2"}}
+
+	spec, err := client.GetMetricSpec(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.MetricSpecs) != 1 || spec.MetricSpecs[0].MetricName != MetricName || spec.MetricSpecs[0].TargetSize != 1 {
+		t.Errorf("desired metric %v with target 1, get %v", MetricName, spec)
+	}
+	req := &GetMetricsRequest{ScaledObjectRef: ref, MetricName: MetricName}
+	metrics, err := client.GetMetrics(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics.MetricValues) != 1 || metrics.MetricValues[0].MetricValue != 4 {
+		t.Errorf("desired 4 GameServers, get %v", metrics)
+	}
+	active, err := client.IsActive(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !active.Result {
+		t.Errorf("desired active")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	withClient := func(name string) context.Context {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: name}}
+		return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}}})
+	}
+	tests := []struct {
+		name         string
+		allowedNames []string
+		ctx          context.Context
+		code         codes.Code
+	}{
+		{name: "any client", ctx: withClient("other"), code: codes.OK},
+		{name: "allowed", allowedNames: []string{"keda"}, ctx: withClient("keda"), code: codes.OK},
+		{name: "not allowed", allowedNames: []string{"keda"}, ctx: withClient("other"), code: codes.PermissionDenied},
+		{name: "no peer", allowedNames: []string{"keda"}, ctx: context.Background(), code: codes.Unauthenticated},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &Server{allowedNames: test.allowedNames}
+			if code := status.Code(s.authorize(test.ctx)); code != test.code {
+				t.Errorf("desired %v, get %v", test.code, code)
+			}
+		})
+	}
+}
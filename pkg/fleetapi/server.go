@@ -0,0 +1,315 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleetapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+)
+
+const (
+	// remoteUserHeader is the header the aggregator passes the authenticated user in.
+	remoteUserHeader = "X-Remote-User"
+	// remoteGroupHeader is the header the aggregator passes the groups of user in.
+	remoteGroupHeader = "X-Remote-Group"
+)
+
+const (
+	apisPath    = "/apis"
+	groupPath   = apisPath + "/" + GroupName
+	versionPath = groupPath + "/" + Version
+)
+
+// Server serves the aggregated API from the informer cache. Requests are only accepted from
+// the aggregator, authenticated by client certificates signed by the request header CA with
+// an allowed common name, and the users passed by the aggregator are authorized by
+// SubjectAccessReviews.
+type Server struct {
+	addr         string
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	// allowedNames are the common names of client certificates allowed to pass users, any if empty.
+	allowedNames []string
+	viewer       *viewer
+	synced       []cache.InformerSynced
+	authorizer   authorizationclient.SubjectAccessReviewInterface
+	mux          *http.ServeMux
+}
+
+// NewServer returns a new aggregated API server listening on port, certFile and keyFile
+// are used for serving TLS, and clientCAFile verifies the client certificate of aggregator,
+// whose common name must be in allowedNames if not empty.
+func NewServer(port int, certFile, keyFile, clientCAFile string, allowedNames []string,
+	kubeClient kubernetes.Interface,
+	kubeInformerFactory informers.SharedInformerFactory,
+	carrierInformerFactory externalversions.SharedInformerFactory) *Server {
+	squads := carrierInformerFactory.Carrier().V1alpha1().Squads()
+	gsSets := carrierInformerFactory.Carrier().V1alpha1().GameServerSets()
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	pods := kubeInformerFactory.Core().V1().Pods()
+	s := &Server{
+		addr:         fmt.Sprintf(":%d", port),
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		allowedNames: allowedNames,
+		viewer: &viewer{
+			squadLister:         squads.Lister(),
+			gameServerSetLister: gsSets.Lister(),
+			gameServerLister:    gameServers.Lister(),
+			podLister:           pods.Lister(),
+		},
+		synced: []cache.InformerSynced{squads.Informer().HasSynced, gsSets.Informer().HasSynced,
+			gameServers.Informer().HasSynced, pods.Informer().HasSynced},
+		authorizer: kubeClient.AuthorizationV1().SubjectAccessReviews(),
+		mux:        http.NewServeMux(),
+	}
+	s.mux.HandleFunc(apisPath, s.serveGroupList)
+	s.mux.HandleFunc(groupPath, s.serveGroup)
+	s.mux.HandleFunc(versionPath, s.serveResourceList)
+	s.mux.HandleFunc(versionPath+"/", s.serveFleetStatuses)
+	return s
+}
+
+// Run starts the server after the cache synced. Will block until stop is closed.
+func (s *Server) Run(stop <-chan struct{}) error {
+	caData, err := ioutil.ReadFile(s.clientCAFile)
+	if err != nil {
+		return errors.Wrap(err, "error reading client CA")
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caData) {
+		return errors.Errorf("no certificates found in %v", s.clientCAFile)
+	}
+	if !cache.WaitForCacheSync(stop, s.synced...) {
+		return errors.New("failed to wait for caches to sync")
+	}
+	server := &http.Server{
+		Addr:    s.addr,
+		Handler: s.mux,
+		TLSConfig: &tls.Config{
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  clientCAs,
+		},
+	}
+	go func() {
+		<-stop
+		server.Close()
+	}()
+	klog.Infof("Starting fleet API server on %v", s.addr)
+	err = server.ListenAndServeTLS(s.certFile, s.keyFile)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) serveGroupList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, &metav1.APIGroupList{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "APIGroupList"},
+		Groups:   []metav1.APIGroup{apiGroup()},
+	})
+}
+
+func (s *Server) serveGroup(w http.ResponseWriter, r *http.Request) {
+	group := apiGroup()
+	group.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "APIGroup"}
+	writeJSON(w, http.StatusOK, &group)
+}
+
+func (s *Server) serveResourceList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{APIVersion: "v1", Kind: "APIResourceList"},
+		GroupVersion: SchemeGroupVersion.String(),
+		APIResources: []metav1.APIResource{
+			{
+				Name:       FleetStatusResource,
+				Namespaced: true,
+				Kind:       "FleetStatus",
+				Verbs:      metav1.Verbs{"get", "list"},
+				ShortNames: []string{"fs"},
+			},
+		},
+	})
+}
+
+// serveFleetStatuses serves get and list of fleetstatuses, watch is not supported.
+func (s *Server) serveFleetStatuses(w http.ResponseWriter, r *http.Request) {
+	namespace, name, ok := parsePath(r.URL.Path)
+	if !ok {
+		writeStatus(w, k8serrors.NewNotFound(SchemeGroupVersion.WithResource(FleetStatusResource).GroupResource(),
+			r.URL.Path))
+		return
+	}
+	verb := "list"
+	if len(name) != 0 {
+		verb = "get"
+	}
+	if r.Method != http.MethodGet {
+		writeStatus(w, k8serrors.NewMethodNotSupported(
+			SchemeGroupVersion.WithResource(FleetStatusResource).GroupResource(), strings.ToLower(r.Method)))
+		return
+	}
+	if r.URL.Query().Get("watch") == "true" {
+		writeStatus(w, k8serrors.NewMethodNotSupported(
+			SchemeGroupVersion.WithResource(FleetStatusResource).GroupResource(), "watch"))
+		return
+	}
+	if err := s.authorize(r, verb, namespace, name); err != nil {
+		writeStatus(w, err)
+		return
+	}
+	if len(name) != 0 {
+		status, err := s.viewer.get(namespace, name)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				err = k8serrors.NewNotFound(SchemeGroupVersion.WithResource(FleetStatusResource).GroupResource(), name)
+			}
+			writeStatus(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+		return
+	}
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		writeStatus(w, k8serrors.NewBadRequest(err.Error()))
+		return
+	}
+	list, err := s.viewer.list(namespace, selector)
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// authorize checks the user passed by the aggregator is allowed to verb fleetstatuses.
+func (s *Server) authorize(r *http.Request, verb, namespace, name string) error {
+	if err := s.authenticate(r); err != nil {
+		return err
+	}
+	user := r.Header.Get(remoteUserHeader)
+	if len(user) == 0 {
+		return k8serrors.NewUnauthorized("no user passed by the aggregator")
+	}
+	review, err := s.authorizer.Create(&authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user,
+			Groups: r.Header[remoteGroupHeader],
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     GroupName,
+				Version:   Version,
+				Resource:  FleetStatusResource,
+				Name:      name,
+			},
+		},
+	})
+	if err != nil {
+		return k8serrors.NewInternalError(errors.Wrap(err, "error reviewing access"))
+	}
+	if !review.Status.Allowed {
+		return k8serrors.NewForbidden(SchemeGroupVersion.WithResource(FleetStatusResource).GroupResource(), name,
+			errors.Errorf("user %v is not allowed to %v: %v", user, verb, review.Status.Reason))
+	}
+	return nil
+}
+
+// authenticate checks the client certificate of r has a common name allowed, so users are
+// only taken from the headers passed by the aggregator.
+func (s *Server) authenticate(r *http.Request) error {
+	if len(s.allowedNames) == 0 {
+		return nil
+	}
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return k8serrors.NewUnauthorized("no verified client certificate")
+	}
+	name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	for _, allowed := range s.allowedNames {
+		if name == allowed {
+			return nil
+		}
+	}
+	return k8serrors.NewUnauthorized(fmt.Sprintf("client %q is not allowed to pass users", name))
+}
+
+// parsePath returns the namespace and name of fleetstatuses in path, which can be
+// fleetstatuses, namespaces/{namespace}/fleetstatuses or namespaces/{namespace}/fleetstatuses/{name}
+// under the version path.
+func parsePath(path string) (string, string, bool) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, versionPath), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == FleetStatusResource:
+		return "", "", true
+	case len(parts) == 3 && parts[0] == "namespaces" && parts[2] == FleetStatusResource:
+		return parts[1], "", true
+	case len(parts) == 4 && parts[0] == "namespaces" && parts[2] == FleetStatusResource:
+		return parts[1], parts[3], true
+	}
+	return "", "", false
+}
+
+func apiGroup() metav1.APIGroup {
+	version := metav1.GroupVersionForDiscovery{GroupVersion: SchemeGroupVersion.String(), Version: Version}
+	return metav1.APIGroup{
+		Name:             GroupName,
+		Versions:         []metav1.GroupVersionForDiscovery{version},
+		PreferredVersion: version,
+	}
+}
+
+// writeStatus writes err as a Status.
+func writeStatus(w http.ResponseWriter, err error) {
+	status := k8serrors.NewInternalError(err).ErrStatus
+	if statusErr, ok := err.(k8serrors.APIStatus); ok {
+		status = statusErr.Status()
+	}
+	status.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Status"}
+	writeJSON(w, int(status.Code), &status)
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err = w.Write(data); err != nil {
+		klog.Errorf("Failed to write fleet API response: %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleetapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path      string
+		namespace string
+		name      string
+		ok        bool
+	}{
+		{path: versionPath + "/fleetstatuses", ok: true},
+		{path: versionPath + "/namespaces/default/fleetstatuses", namespace: "default", ok: true},
+		{path: versionPath + "/namespaces/default/fleetstatuses/squad", namespace: "default", name: "squad", ok: true},
+		{path: versionPath + "/namespaces/default/squads"},
+		{path: versionPath + "/namespaces/default/fleetstatuses/squad/status"},
+	}
+	for _, test := range tests {
+		namespace, name, ok := parsePath(test.path)
+		if namespace != test.namespace || name != test.name || ok != test.ok {
+			t.Errorf("path %v desired %q %q %v, get: %q %q %v", test.path, test.namespace, test.name, test.ok,
+				namespace, name, ok)
+		}
+	}
+}
+
+func newTestServer(allowed bool) *Server {
+	squadIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	squadIndexer.Add(&carrierv1alpha1.Squad{
+		ObjectMeta: metav1.ObjectMeta{Name: "squad", Namespace: "default"},
+		Spec:       carrierv1alpha1.SquadSpec{Replicas: 1},
+	})
+	gsSetIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	gsSetIndexer.Add(&carrierv1alpha1.GameServerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "squad-abc", Namespace: "default",
+			Labels: map[string]string{util.SquadNameLabelKey: "squad"}},
+		Spec: carrierv1alpha1.GameServerSetSpec{Replicas: 1},
+	})
+	gsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	gsIndexer.Add(&carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "squad-abc-x", Namespace: "default",
+			Labels: map[string]string{util.GameServerSetLabelKey: "squad-abc"}},
+		Status: carrierv1alpha1.GameServerStatus{State: carrierv1alpha1.GameServerRunning, NodeName: "node1"},
+	})
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	podIndexer.Add(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "squad-abc-x", Namespace: "default"},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{RestartCount: 2}},
+		},
+	})
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "subjectaccessreviews",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+			review.Status.Allowed = allowed
+			return true, review, nil
+		})
+	return &Server{
+		viewer: &viewer{
+			squadLister:         listerv1.NewSquadLister(squadIndexer),
+			gameServerSetLister: listerv1.NewGameServerSetLister(gsSetIndexer),
+			gameServerLister:    listerv1.NewGameServerLister(gsIndexer),
+			podLister:           corelisterv1.NewPodLister(podIndexer),
+		},
+		authorizer: kubeClient.AuthorizationV1().SubjectAccessReviews(),
+	}
+}
+
+func TestServeFleetStatuses(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		user    string
+		allowed bool
+		// proxy is the common name of the client certificate, no certificate if empty.
+		proxy        string
+		allowedNames []string
+		code         int
+	}{
+		{
+			name:    "get",
+			path:    versionPath + "/namespaces/default/fleetstatuses/squad",
+			user:    "alice",
+			allowed: true,
+			code:    http.StatusOK,
+		},
+		{
+			name:    "not found",
+			path:    versionPath + "/namespaces/default/fleetstatuses/missing",
+			user:    "alice",
+			allowed: true,
+			code:    http.StatusNotFound,
+		},
+		{
+			name: "forbidden",
+			path: versionPath + "/namespaces/default/fleetstatuses/squad",
+			user: "alice",
+			code: http.StatusForbidden,
+		},
+		{
+			name:    "no user",
+			path:    versionPath + "/namespaces/default/fleetstatuses/squad",
+			allowed: true,
+			code:    http.StatusUnauthorized,
+		},
+		{
+			name:         "proxy allowed",
+			path:         versionPath + "/namespaces/default/fleetstatuses/squad",
+			user:         "alice",
+			allowed:      true,
+			proxy:        "front-proxy-client",
+			allowedNames: []string{"front-proxy-client"},
+			code:         http.StatusOK,
+		},
+		{
+			name:         "proxy not allowed",
+			path:         versionPath + "/namespaces/default/fleetstatuses/squad",
+			user:         "alice",
+			allowed:      true,
+			proxy:        "mallory",
+			allowedNames: []string{"front-proxy-client"},
+			code:         http.StatusUnauthorized,
+		},
+		{
+			name:         "no client certificate",
+			path:         versionPath + "/namespaces/default/fleetstatuses/squad",
+			user:         "alice",
+			allowed:      true,
+			allowedNames: []string{"front-proxy-client"},
+			code:         http.StatusUnauthorized,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(test.allowed)
+			s.allowedNames = test.allowedNames
+			r := httptest.NewRequest("GET", test.path, nil)
+			if len(test.proxy) != 0 {
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{
+					{{Subject: pkix.Name{CommonName: test.proxy}}},
+				}}
+			}
+			if len(test.user) != 0 {
+				r.Header.Set(remoteUserHeader, test.user)
+			}
+			w := httptest.NewRecorder()
+			s.serveFleetStatuses(w, r)
+			if w.Code != test.code {
+				t.Fatalf("desired code %v, get: %v %v", test.code, w.Code, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			status := &FleetStatus{}
+			if err := json.Unmarshal(w.Body.Bytes(), status); err != nil {
+				t.Fatal(err)
+			}
+			if len(status.GameServerSets) != 1 || len(status.GameServerSets[0].GameServers) != 1 {
+				t.Fatalf("desired 1 GameServerSet with 1 GameServer, get: %+v", status)
+			}
+			pod := status.GameServerSets[0].GameServers[0].Pod
+			if pod == nil || pod.Phase != corev1.PodRunning || pod.Restarts != 2 {
+				t.Errorf("desired running pod restarted twice, get: %+v", pod)
+			}
+		})
+	}
+}
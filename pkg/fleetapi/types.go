@@ -0,0 +1,99 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleetapi
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+const (
+	// GroupName is the group of the aggregated API.
+	GroupName = "fleet.carrier.ocgi.dev"
+	// Version is the version of the aggregated API.
+	Version = "v1"
+	// FleetStatusResource is the resource of FleetStatus.
+	FleetStatusResource = "fleetstatuses"
+)
+
+// SchemeGroupVersion is the group version of the aggregated API.
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: Version}
+
+// FleetStatus is the status of a Squad joined with its GameServerSets, GameServers and pods.
+// It has the same name and namespace as the Squad.
+type FleetStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Replicas is the desired replicas of Squad.
+	Replicas int32 `json:"replicas"`
+	// Squad is the status of Squad.
+	Squad carrierv1alpha1.SquadStatus `json:"squad"`
+	// GameServerSets are the GameServerSets of Squad, newest first.
+	GameServerSets []GameServerSetStatus `json:"gameServerSets"`
+}
+
+// FleetStatusList is a list of FleetStatus.
+type FleetStatusList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []FleetStatus `json:"items"`
+}
+
+// GameServerSetStatus is the status of a GameServerSet joined with its GameServers.
+type GameServerSetStatus struct {
+	// Name of GameServerSet.
+	Name string `json:"name"`
+	// Replicas is the desired replicas of GameServerSet.
+	Replicas int32 `json:"replicas"`
+	// Status of GameServerSet.
+	Status carrierv1alpha1.GameServerSetStatus `json:"status"`
+	// GameServers of GameServerSet, ordered by name.
+	GameServers []GameServerStatus `json:"gameServers"`
+}
+
+// GameServerStatus is the status of a GameServer joined with its pod.
+type GameServerStatus struct {
+	// Name of GameServer.
+	Name string `json:"name"`
+	// State of GameServer.
+	State carrierv1alpha1.GameServerState `json:"state,omitempty"`
+	// Ready is true if GameServer passes all readiness gates.
+	Ready bool `json:"ready"`
+	// OutOfService is true if GameServer is marked out of service.
+	OutOfService bool `json:"outOfService"`
+	// NodeName is the node GameServer runs on.
+	NodeName string `json:"nodeName,omitempty"`
+	// Address of GameServer.
+	Address string `json:"address,omitempty"`
+	// Pod is the status of the pod, nil if the pod does not exist.
+	Pod *PodStatus `json:"pod,omitempty"`
+}
+
+// PodStatus is the status of the pod of a GameServer.
+type PodStatus struct {
+	// Phase of pod.
+	Phase corev1.PodPhase `json:"phase"`
+	// PodIP of pod.
+	PodIP string `json:"podIP,omitempty"`
+	// Ready is true if the pod is ready.
+	Ready bool `json:"ready"`
+	// Restarts is the total restarts of containers.
+	Restarts int32 `json:"restarts"`
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleetapi
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	listerv1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// viewer joins FleetStatuses from listers.
+type viewer struct {
+	squadLister         listerv1.SquadLister
+	gameServerSetLister listerv1.GameServerSetLister
+	gameServerLister    listerv1.GameServerLister
+	podLister           corelisterv1.PodLister
+}
+
+// get returns the FleetStatus of Squad namespace/name.
+func (v *viewer) get(namespace, name string) (*FleetStatus, error) {
+	squad, err := v.squadLister.Squads(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return v.fleetStatus(squad)
+}
+
+// list returns the FleetStatuses of Squads in namespace matching selector, all namespaces if empty.
+func (v *viewer) list(namespace string, selector labels.Selector) (*FleetStatusList, error) {
+	var squads []*carrierv1alpha1.Squad
+	var err error
+	if len(namespace) == 0 {
+		squads, err = v.squadLister.List(selector)
+	} else {
+		squads, err = v.squadLister.Squads(namespace).List(selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(squads, func(i, j int) bool {
+		if squads[i].Namespace != squads[j].Namespace {
+			return squads[i].Namespace < squads[j].Namespace
+		}
+		return squads[i].Name < squads[j].Name
+	})
+	list := &FleetStatusList{
+		TypeMeta: metav1.TypeMeta{APIVersion: SchemeGroupVersion.String(), Kind: "FleetStatusList"},
+		Items:    make([]FleetStatus, 0, len(squads)),
+	}
+	for _, squad := range squads {
+		status, err := v.fleetStatus(squad)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *status)
+	}
+	return list, nil
+}
+
+func (v *viewer) fleetStatus(squad *carrierv1alpha1.Squad) (*FleetStatus, error) {
+	status := &FleetStatus{
+		TypeMeta: metav1.TypeMeta{APIVersion: SchemeGroupVersion.String(), Kind: "FleetStatus"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              squad.Name,
+			Namespace:         squad.Namespace,
+			UID:               squad.UID,
+			Labels:            squad.Labels,
+			CreationTimestamp: squad.CreationTimestamp,
+		},
+		Replicas:       squad.Spec.Replicas,
+		Squad:          squad.Status,
+		GameServerSets: []GameServerSetStatus{},
+	}
+	gsSets, err := v.gameServerSetLister.GameServerSets(squad.Namespace).List(
+		labels.SelectorFromSet(labels.Set{util.SquadNameLabelKey: squad.Name}))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(gsSets, func(i, j int) bool {
+		return gsSets[j].CreationTimestamp.Before(&gsSets[i].CreationTimestamp)
+	})
+	for _, gsSet := range gsSets {
+		gsSetStatus, err := v.gameServerSetStatus(gsSet)
+		if err != nil {
+			return nil, err
+		}
+		status.GameServerSets = append(status.GameServerSets, *gsSetStatus)
+	}
+	return status, nil
+}
+
+func (v *viewer) gameServerSetStatus(gsSet *carrierv1alpha1.GameServerSet) (*GameServerSetStatus, error) {
+	list, err := v.gameServerLister.GameServers(gsSet.Namespace).List(
+		labels.SelectorFromSet(labels.Set{util.GameServerSetLabelKey: gsSet.Name}))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	status := &GameServerSetStatus{
+		Name:        gsSet.Name,
+		Replicas:    gsSet.Spec.Replicas,
+		Status:      gsSet.Status,
+		GameServers: make([]GameServerStatus, 0, len(list)),
+	}
+	for _, gs := range list {
+		gsStatus := GameServerStatus{
+			Name:         gs.Name,
+			State:        gs.Status.State,
+			Ready:        gameservers.IsReady(gs),
+			OutOfService: gameservers.IsOutOfService(gs),
+			NodeName:     gs.Status.NodeName,
+			Address:      gs.Status.Address,
+		}
+		if pod, err := v.podLister.Pods(gs.Namespace).Get(gs.Name); err == nil {
+			gsStatus.Pod = podStatus(pod)
+		}
+		status.GameServers = append(status.GameServers, gsStatus)
+	}
+	return status, nil
+}
+
+func podStatus(pod *corev1.Pod) *PodStatus {
+	status := &PodStatus{Phase: pod.Status.Phase, PodIP: pod.Status.PodIP}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			status.Ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+	for _, container := range pod.Status.ContainerStatuses {
+		status.Restarts += container.RestartCount
+	}
+	return status
+}
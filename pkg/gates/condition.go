@@ -0,0 +1,103 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gates
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+)
+
+// HasGate returns true if gs declares gate as a readiness gate or a deletable gate.
+func HasGate(gs *carrierv1alpha1.GameServer, gate string) bool {
+	for _, g := range gs.Spec.ReadinessGates {
+		if g == gate {
+			return true
+		}
+	}
+	for _, g := range gs.Spec.DeletableGates {
+		if g == gate {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCondition returns the condition of gs for gate, nil if not found.
+func GetCondition(gs *carrierv1alpha1.GameServer, gate string) *carrierv1alpha1.GameServerCondition {
+	for i := range gs.Status.Conditions {
+		if string(gs.Status.Conditions[i].Type) == gate {
+			return &gs.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsConditionTrue returns true if the condition of gs for gate is True.
+func IsConditionTrue(gs *carrierv1alpha1.GameServer, gate string) bool {
+	condition := GetCondition(gs, gate)
+	return condition != nil && condition.Status == carrierv1alpha1.ConditionTrue
+}
+
+// SetCondition sets the condition of gs for gate, returns false if the condition is not changed.
+func SetCondition(gs *carrierv1alpha1.GameServer, gate string, status carrierv1alpha1.ConditionStatus,
+	message string) bool {
+	now := metav1.Now()
+	condition := GetCondition(gs, gate)
+	if condition == nil {
+		gs.Status.Conditions = append(gs.Status.Conditions, carrierv1alpha1.GameServerCondition{
+			Type:               carrierv1alpha1.GameServerConditionType(gate),
+			Status:             status,
+			LastProbeTime:      now,
+			LastTransitionTime: now,
+			Message:            message,
+		})
+		return true
+	}
+	if condition.Status == status && condition.Message == message {
+		return false
+	}
+	if condition.Status != status {
+		condition.Status = status
+		condition.LastTransitionTime = now
+	}
+	condition.Message = message
+	condition.LastProbeTime = now
+	return true
+}
+
+// UpdateCondition sets the condition of the GameServer namespace/name for gate. The status
+// is updated at the resourceVersion got, so the conditions set by others concurrently are
+// never overwritten, and is retried on conflicts. The GameServer is not updated if the
+// condition is not changed.
+func UpdateCondition(client versioned.Interface, namespace, name, gate string,
+	status carrierv1alpha1.ConditionStatus, message string) (*carrierv1alpha1.GameServer, error) {
+	var result *carrierv1alpha1.GameServer
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		gs, err := client.CarrierV1alpha1().GameServers(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if !SetCondition(gs, gate, status, message) {
+			result = gs
+			return nil
+		}
+		result, err = client.CarrierV1alpha1().GameServers(namespace).UpdateStatus(gs)
+		return err
+	})
+	return result, err
+}
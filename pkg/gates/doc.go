@@ -0,0 +1,19 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gates is the library of external gate controllers, which evaluate the readiness
+// gates or deletable gates declared by GameServers and report them as conditions. It provides
+// helpers setting conditions with optimistic concurrency, informers only caching GameServers
+// declaring a gate, and running a controller with leader election.
+package gates
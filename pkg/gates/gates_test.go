@@ -0,0 +1,126 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gates
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+)
+
+func newGameServer(name string, readinessGates ...string) *carrierv1alpha1.GameServer {
+	gs := &carrierv1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+	gs.Spec.ReadinessGates = readinessGates
+	return gs
+}
+
+func TestSetCondition(t *testing.T) {
+	gs := newGameServer("gs", "matchmaker")
+	tests := []struct {
+		status  carrierv1alpha1.ConditionStatus
+		message string
+		changed bool
+	}{
+		{status: carrierv1alpha1.ConditionFalse, message: "registering", changed: true},
+		{status: carrierv1alpha1.ConditionFalse, message: "registering", changed: false},
+		{status: carrierv1alpha1.ConditionTrue, message: "registered", changed: true},
+	}
+	for i, test := range tests {
+		if changed := SetCondition(gs, "matchmaker", test.status, test.message); changed != test.changed {
+			t.Errorf("%v: desired changed %v, get: %v", i, test.changed, changed)
+		}
+		condition := GetCondition(gs, "matchmaker")
+		if condition == nil || condition.Status != test.status || condition.Message != test.message {
+			t.Errorf("%v: desired condition %v %v, get: %+v", i, test.status, test.message, condition)
+		}
+	}
+	if len(gs.Status.Conditions) != 1 || !IsConditionTrue(gs, "matchmaker") {
+		t.Errorf("desired one True condition, get: %+v", gs.Status.Conditions)
+	}
+}
+
+func TestUpdateCondition(t *testing.T) {
+	client := fake.NewSimpleClientset(newGameServer("gs", "matchmaker"))
+	gs, err := UpdateCondition(client, "default", "gs", "matchmaker", carrierv1alpha1.ConditionTrue, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsConditionTrue(gs, "matchmaker") {
+		t.Errorf("desired condition True, get: %+v", gs.Status.Conditions)
+	}
+	client.ClearActions()
+	if _, err := UpdateCondition(client, "default", "gs", "matchmaker", carrierv1alpha1.ConditionTrue, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			t.Errorf("desired no update if condition not changed, get: %v", action)
+		}
+	}
+}
+
+func TestGateListWatch(t *testing.T) {
+	fakeWatch := watch.NewFake()
+	lw := newGateListWatch(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &carrierv1alpha1.GameServerList{Items: []carrierv1alpha1.GameServer{
+				*newGameServer("gated", "matchmaker"), *newGameServer("other", "other"),
+			}}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return fakeWatch, nil
+		},
+	}, "matchmaker")
+
+	list, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := list.(*carrierv1alpha1.GameServerList).Items
+	if len(items) != 1 || items[0].Name != "gated" {
+		t.Errorf("desired only gated listed, get: %+v", items)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	go func() {
+		fakeWatch.Add(newGameServer("other", "other"))
+		fakeWatch.Add(newGameServer("gated", "matchmaker"))
+		fakeWatch.Modify(newGameServer("gated", "other"))
+	}()
+	tests := []struct {
+		eventType watch.EventType
+		name      string
+	}{
+		{eventType: watch.Added, name: "gated"},
+		{eventType: watch.Deleted, name: "gated"},
+	}
+	for _, test := range tests {
+		event := <-w.ResultChan()
+		gs := event.Object.(*carrierv1alpha1.GameServer)
+		if event.Type != test.eventType || gs.Name != test.name {
+			t.Errorf("desired %v %v, get: %v %v", test.eventType, test.name, event.Type, gs.Name)
+		}
+	}
+}
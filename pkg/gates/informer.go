@@ -0,0 +1,96 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gates
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned"
+)
+
+// NewGateInformer returns an informer of the GameServers in namespace declaring gate.
+// GameServers are filtered before caching, so gate controllers do not cache all GameServers
+// of the cluster.
+func NewGateInformer(client versioned.Interface, namespace, gate string,
+	resync time.Duration) cache.SharedIndexInformer {
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.CarrierV1alpha1().GameServers(namespace).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CarrierV1alpha1().GameServers(namespace).Watch(options)
+		},
+	}
+	return cache.NewSharedIndexInformer(newGateListWatch(lw, gate),
+		&carrierv1alpha1.GameServer{}, resync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+// gateListWatch drops the GameServers not declaring gate from lists and watches.
+type gateListWatch struct {
+	lw   cache.ListerWatcher
+	gate string
+}
+
+func newGateListWatch(lw cache.ListerWatcher, gate string) cache.ListerWatcher {
+	return &gateListWatch{lw: lw, gate: gate}
+}
+
+// List lists GameServers declaring gate.
+func (g *gateListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	list, err := g.lw.List(options)
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	var filtered []runtime.Object
+	for _, item := range items {
+		if gs, ok := item.(*carrierv1alpha1.GameServer); ok && HasGate(gs, g.gate) {
+			filtered = append(filtered, item)
+		}
+	}
+	if err := meta.SetList(list, filtered); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Watch watches GameServers declaring gate. A GameServer modified to not declare gate is
+// seen as deleted.
+func (g *gateListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w, err := g.lw.Watch(options)
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+		gs, ok := in.Object.(*carrierv1alpha1.GameServer)
+		if !ok || HasGate(gs, g.gate) {
+			return in, true
+		}
+		if in.Type == watch.Modified {
+			return watch.Event{Type: watch.Deleted, Object: in.Object}, true
+		}
+		return in, in.Type == watch.Deleted
+	}), nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gates
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog"
+)
+
+// LeaderElectionConfig describes the lease gate controller replicas elect their leader with.
+type LeaderElectionConfig struct {
+	// Namespace and Name of the lease.
+	Namespace string
+	Name      string
+	// Identity of the replica, defaults to the hostname.
+	Identity string
+	// LeaseDuration, RenewDeadline and RetryPeriod default to 15s, 10s and 2s.
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// RunWithLeaderElection runs run once the replica is elected as leader, until ctx is done.
+// The process exits if the leadership is lost before ctx is done, since run may still be running.
+func RunWithLeaderElection(ctx context.Context, client kubernetes.Interface, config LeaderElectionConfig,
+	run func(ctx context.Context)) error {
+	if len(config.Namespace) == 0 || len(config.Name) == 0 {
+		return errors.New("namespace and name of lease are required")
+	}
+	if len(config.Identity) == 0 {
+		id, err := os.Hostname()
+		if err != nil {
+			return errors.Wrap(err, "unable to get hostname")
+		}
+		config.Identity = id
+	}
+	if config.LeaseDuration == 0 {
+		config.LeaseDuration = 15 * time.Second
+	}
+	if config.RenewDeadline == 0 {
+		config.RenewDeadline = 10 * time.Second
+	}
+	if config.RetryPeriod == 0 {
+		config.RetryPeriod = 2 * time.Second
+	}
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, config.Namespace, config.Name,
+		client.CoreV1(), client.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: config.Identity})
+	if err != nil {
+		return errors.Wrap(err, "unable to create leader election lock")
+	}
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: config.LeaseDuration,
+		RenewDeadline: config.RenewDeadline,
+		RetryPeriod:   config.RetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: run,
+			OnStoppedLeading: func() {
+				if ctx.Err() != nil {
+					return
+				}
+				klog.Fatalf("%v lost leadership of %v/%v", config.Identity, config.Namespace, config.Name)
+			},
+		},
+	})
+	return nil
+}
@@ -0,0 +1,375 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	carrierNamespace    = "carrier"
+	squadSubsystem      = "squad"
+	apiserverSubsystem  = "apiserver"
+	gameServerSubsystem = "gameserver"
+	controllerSubsystem = "controller"
+	defragSubsystem     = "defrag"
+	costSubsystem       = "cost"
+	headroomSubsystem   = "headroom"
+)
+
+var (
+	// SquadRolloutDuration is the duration from a rollout of Squad started to all GameServers updated and ready.
+	SquadRolloutDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      squadSubsystem,
+			Name:           "rollout_duration_seconds",
+			Help:           "Duration of Squad rollouts from started to completed.",
+			Buckets:        metrics.ExponentialBuckets(10, 2, 12),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "squad", "revision", "strategy"},
+	)
+	// SquadUpdatedGameServers is the number of GameServers updated during rollouts of Squad.
+	SquadUpdatedGameServers = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      squadSubsystem,
+			Name:           "updated_gameservers_total",
+			Help:           "Number of GameServers updated during Squad rollouts.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "squad", "revision", "strategy"},
+	)
+	// SquadRolloutFailures is the number of replica failures observed during rollouts of Squad.
+	SquadRolloutFailures = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      squadSubsystem,
+			Name:           "rollout_failures_total",
+			Help:           "Number of replica failures observed during Squad rollouts.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "squad", "revision", "reason"},
+	)
+	// SquadGameServerTimeToReady is the duration from a GameServer of Squad created to ready.
+	SquadGameServerTimeToReady = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      squadSubsystem,
+			Name:           "gameserver_time_to_ready_seconds",
+			Help:           "Duration of GameServers of Squad from created to ready.",
+			Buckets:        metrics.ExponentialBuckets(1, 2, 12),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "squad", "revision"},
+	)
+	// SquadGameServerStartup is the duration from a GameServer of Squad created to each startup milestone.
+	SquadGameServerStartup = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      squadSubsystem,
+			Name:           "gameserver_startup_seconds",
+			Help:           "Duration of GameServers of Squad from created to scheduled, pod running and ready.",
+			Buckets:        metrics.ExponentialBuckets(1, 2, 12),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "squad", "milestone"},
+	)
+	// APIServerThrottled is the number of requests throttled by the apiserver with 429.
+	APIServerThrottled = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      apiserverSubsystem,
+			Name:           "throttled_total",
+			Help:           "Number of requests throttled by the apiserver priority and fairness.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation"},
+	)
+	// GameServerDiscrepancies is the number of discrepancies between GameServers and pods found by
+	// the anti-entropy audit.
+	GameServerDiscrepancies = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      gameServerSubsystem,
+			Name:           "discrepancies_total",
+			Help:           "Number of discrepancies between GameServers and pods found by the anti-entropy audit.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"kind", "repaired"},
+	)
+	// ControllerReady is 1 once a controller recovered its in-memory state and started processing the queue.
+	ControllerReady = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      controllerSubsystem,
+			Name:           "ready",
+			Help:           "Whether the controller recovered its in-memory state and started processing the queue.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"controller"},
+	)
+	// ControllerSyncs is the number of objects synced by a controller.
+	ControllerSyncs = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      controllerSubsystem,
+			Name:           "syncs_total",
+			Help:           "Number of objects synced by the controller.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"controller"},
+	)
+	// DefragFragmentation is the percentage of capacity unused on the nodes running GameServers of a pool.
+	DefragFragmentation = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      defragSubsystem,
+			Name:           "fragmentation_percent",
+			Help:           "Percentage of capacity unused on the nodes running GameServers of the pool.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"report"},
+	)
+	// DefragReclaimableNodes is the number of nodes of a pool could be emptied by moving GameServers.
+	DefragReclaimableNodes = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      defragSubsystem,
+			Name:           "reclaimable_nodes",
+			Help:           "Number of nodes of the pool could be emptied by moving their GameServers to other nodes.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"report"},
+	)
+	// SquadResourceRequests is the total resources requested by GameServers in service of a Squad.
+	SquadResourceRequests = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      costSubsystem,
+			Name:           "squad_resource_requests",
+			Help:           "Total resources requested by GameServers in service of the Squad, cpu in cores and memory in bytes.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "squad", "resource"},
+	)
+	// SquadNodeSeconds is the node time attributed to a Squad, each GameServer is attributed the share
+	// of its node it requests.
+	SquadNodeSeconds = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      costSubsystem,
+			Name:           "squad_node_seconds_total",
+			Help:           "Node seconds attributed to the Squad by the share of nodes requested by its GameServers.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "squad"},
+	)
+	// SquadCostLabels is 1 for every cost label of a Squad, to be joined with the other cost metrics.
+	SquadCostLabels = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      costSubsystem,
+			Name:           "squad_labels",
+			Help:           "Cost labels of the Squad, the value is always 1.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "squad", "key", "value"},
+	)
+	// SquadUnfulfilledAllocations is the number of queries of a Squad matching no GameServers.
+	SquadUnfulfilledAllocations = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      squadSubsystem,
+			Name:           "unfulfilled_allocations_total",
+			Help:           "Number of GameServer queries of the Squad matching no GameServers.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "squad"},
+	)
+	// SquadHeadroomGameServers is the number of ready GameServers of a Squad not allocated yet.
+	SquadHeadroomGameServers = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      headroomSubsystem,
+			Name:           "squad_ready_gameservers",
+			Help:           "Number of ready GameServers of the Squad accepting new sessions without players.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "squad"},
+	)
+	// SquadAllocationRate is the recent number of GameServers of a Squad allocated per minute.
+	SquadAllocationRate = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      headroomSubsystem,
+			Name:           "squad_allocations_per_minute",
+			Help:           "Number of GameServers of the Squad getting their first players per minute, over the recent window.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "squad"},
+	)
+	// SquadHeadroomSeconds is the projected time till the ready GameServers of a Squad are exhausted.
+	SquadHeadroomSeconds = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      carrierNamespace,
+			Subsystem:      headroomSubsystem,
+			Name:           "squad_seconds",
+			Help:           "Projected seconds till the ready GameServers of the Squad are exhausted at the recent allocation rate, +Inf if nothing is allocated.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"namespace", "squad"},
+	)
+)
+
+var registerOnce sync.Once
+
+// Register registers all carrier metrics to the legacy registry.
+func Register() {
+	registerOnce.Do(func() {
+		legacyregistry.MustRegister(SquadRolloutDuration)
+		legacyregistry.MustRegister(SquadUpdatedGameServers)
+		legacyregistry.MustRegister(SquadRolloutFailures)
+		legacyregistry.MustRegister(SquadGameServerTimeToReady)
+		legacyregistry.MustRegister(SquadGameServerStartup)
+		legacyregistry.MustRegister(APIServerThrottled)
+		legacyregistry.MustRegister(GameServerDiscrepancies)
+		legacyregistry.MustRegister(ControllerReady)
+		legacyregistry.MustRegister(ControllerSyncs)
+		legacyregistry.MustRegister(DefragFragmentation)
+		legacyregistry.MustRegister(DefragReclaimableNodes)
+		legacyregistry.MustRegister(SquadResourceRequests)
+		legacyregistry.MustRegister(SquadNodeSeconds)
+		legacyregistry.MustRegister(SquadCostLabels)
+		legacyregistry.MustRegister(SquadHeadroomGameServers)
+		legacyregistry.MustRegister(SquadAllocationRate)
+		legacyregistry.MustRegister(SquadHeadroomSeconds)
+		legacyregistry.MustRegister(SquadUnfulfilledAllocations)
+	})
+}
+
+// RecordSquadRolloutCompleted records the duration of a completed rollout of Squad.
+func RecordSquadRolloutCompleted(namespace, squad, revision, strategy string, duration time.Duration) {
+	SquadRolloutDuration.WithLabelValues(namespace, squad, revision, strategy).Observe(duration.Seconds())
+}
+
+// RecordSquadGameServersUpdated records the GameServers updated during a rollout of Squad.
+func RecordSquadGameServersUpdated(namespace, squad, revision, strategy string, count int32) {
+	SquadUpdatedGameServers.WithLabelValues(namespace, squad, revision, strategy).Add(float64(count))
+}
+
+// RecordSquadRolloutFailure records a replica failure observed during a rollout of Squad.
+func RecordSquadRolloutFailure(namespace, squad, revision, reason string) {
+	SquadRolloutFailures.WithLabelValues(namespace, squad, revision, reason).Inc()
+}
+
+// RecordSquadGameServerReady records the time a GameServer of Squad takes to be ready.
+func RecordSquadGameServerReady(namespace, squad, revision string, timeToReady time.Duration) {
+	SquadGameServerTimeToReady.WithLabelValues(namespace, squad, revision).Observe(timeToReady.Seconds())
+}
+
+// RecordSquadGameServerStartup records the time a GameServer of Squad takes to reach the startup milestone.
+func RecordSquadGameServerStartup(namespace, squad, milestone string, duration time.Duration) {
+	SquadGameServerStartup.WithLabelValues(namespace, squad, milestone).Observe(duration.Seconds())
+}
+
+// RecordAPIServerThrottled records a request of operation throttled by the apiserver.
+func RecordAPIServerThrottled(operation string) {
+	APIServerThrottled.WithLabelValues(operation).Inc()
+}
+
+// RecordGameServerDiscrepancy records a discrepancy of kind found by the anti-entropy audit.
+func RecordGameServerDiscrepancy(kind string, repaired bool) {
+	GameServerDiscrepancies.WithLabelValues(kind, strconv.FormatBool(repaired)).Inc()
+}
+
+// RecordControllerReady records controller recovered its in-memory state and is ready.
+func RecordControllerReady(controller string) {
+	ControllerReady.WithLabelValues(controller).Set(1)
+}
+
+// RecordControllerSync records an object synced by controller.
+func RecordControllerSync(controller string) {
+	ControllerSyncs.WithLabelValues(controller).Inc()
+}
+
+// RecordDefragReport records the fragmentation and reclaimable nodes of the pool analyzed by report.
+func RecordDefragReport(report string, fragmentationPercent, reclaimableNodes int32) {
+	DefragFragmentation.WithLabelValues(report).Set(float64(fragmentationPercent))
+	DefragReclaimableNodes.WithLabelValues(report).Set(float64(reclaimableNodes))
+}
+
+// DeleteDefragReport deletes the metrics of report.
+func DeleteDefragReport(report string) {
+	DefragFragmentation.Delete(map[string]string{"report": report})
+	DefragReclaimableNodes.Delete(map[string]string{"report": report})
+}
+
+// ResetSquadCosts deletes the resource requests and cost labels of all Squads before they are recorded again.
+func ResetSquadCosts() {
+	SquadResourceRequests.Reset()
+	SquadCostLabels.Reset()
+}
+
+// RecordSquadResourceRequest records the total quantity of resource requested by Squad.
+func RecordSquadResourceRequest(namespace, squad, resource string, quantity float64) {
+	SquadResourceRequests.WithLabelValues(namespace, squad, resource).Set(quantity)
+}
+
+// RecordSquadNodeSeconds records the node seconds attributed to Squad.
+func RecordSquadNodeSeconds(namespace, squad string, seconds float64) {
+	SquadNodeSeconds.WithLabelValues(namespace, squad).Add(seconds)
+}
+
+// RecordSquadCostLabel records a cost label of Squad.
+func RecordSquadCostLabel(namespace, squad, key, value string) {
+	SquadCostLabels.WithLabelValues(namespace, squad, key, value).Set(1)
+}
+
+// DeleteSquadNodeSeconds deletes the node seconds of a deleted Squad.
+func DeleteSquadNodeSeconds(namespace, squad string) {
+	SquadNodeSeconds.Delete(map[string]string{"namespace": namespace, "squad": squad})
+}
+
+// RecordSquadHeadroom records the ready GameServers, allocation rate and projected headroom of Squad.
+func RecordSquadHeadroom(namespace, squad string, ready int32, perMinute, seconds float64) {
+	SquadHeadroomGameServers.WithLabelValues(namespace, squad).Set(float64(ready))
+	SquadAllocationRate.WithLabelValues(namespace, squad).Set(perMinute)
+	SquadHeadroomSeconds.WithLabelValues(namespace, squad).Set(seconds)
+}
+
+// DeleteSquadHeadroom deletes the headroom metrics of a deleted Squad.
+func DeleteSquadHeadroom(namespace, squad string) {
+	labels := map[string]string{"namespace": namespace, "squad": squad}
+	SquadHeadroomGameServers.Delete(labels)
+	SquadAllocationRate.Delete(labels)
+	SquadHeadroomSeconds.Delete(labels)
+}
+
+// RecordSquadUnfulfilledAllocation records a query of Squad matching no GameServers.
+func RecordSquadUnfulfilledAllocation(namespace, squad string) {
+	SquadUnfulfilledAllocations.WithLabelValues(namespace, squad).Inc()
+}
+
+// DeleteSquadUnfulfilledAllocations deletes the unfulfilled allocations of a deleted Squad.
+func DeleteSquadUnfulfilledAllocations(namespace, squad string) {
+	SquadUnfulfilledAllocations.Delete(map[string]string{"namespace": namespace, "squad": squad})
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog"
+)
+
+// MetricsPath is the path serving prometheus metrics
+const MetricsPath = "/metrics"
+
+// Server serves the prometheus metrics of carrier.
+type Server struct {
+	addr string
+	mux  *http.ServeMux
+}
+
+// NewServer returns a new metrics server listening on port.
+func NewServer(port int) *Server {
+	s := &Server{
+		addr: fmt.Sprintf(":%d", port),
+		mux:  http.NewServeMux(),
+	}
+	s.mux.Handle(MetricsPath, legacyregistry.Handler())
+	return s
+}
+
+// Run starts the metrics server. Will block until stop is closed.
+func (s *Server) Run(stop <-chan struct{}) error {
+	server := &http.Server{
+		Addr:    s.addr,
+		Handler: s.mux,
+	}
+	go func() {
+		<-stop
+		server.Close()
+	}()
+	klog.Infof("Starting metrics server on %v", s.addr)
+	err := server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
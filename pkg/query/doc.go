@@ -0,0 +1,19 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package query serves queries of GameServers by capacity from informer caches,
+// for matchmakers that need richer queries than label selectors. Queries are served as
+// HTTP/JSON on GameServersPath, and as the GameServerQuery gRPC service defined by
+// querypb/query.proto.
+package query
@@ -0,0 +1,164 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/ocgi/carrier/pkg/query/querypb"
+	"github.com/ocgi/carrier/pkg/tenancy"
+)
+
+// grpcService serves the GameServerQuery service from the cache of Server, the same
+// way as GameServersPath.
+type grpcService struct {
+	server *Server
+}
+
+var _ querypb.GameServerQueryServer = &grpcService{}
+
+// Query returns the ready GameServers matching req, the ones with most free slots first.
+func (g *grpcService) Query(ctx context.Context, req *querypb.QueryRequest) (*querypb.QueryResponse, error) {
+	s := g.server
+	q, err := queryFromRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	var tenant *tenancy.Tenant
+	if s.tenancy != nil {
+		tenant = s.tenancy.TenantForToken(grpcBearerToken(ctx))
+		if tenant == nil {
+			return nil, status.Error(codes.Unauthenticated, "unknown bearer token")
+		}
+		if len(q.Namespace) != 0 && !tenant.Allows(q.Namespace) {
+			return nil, status.Errorf(codes.PermissionDenied, "namespace %v is not allowed for tenant %v",
+				q.Namespace, tenant.Name)
+		}
+	}
+	result, err := s.match(q, tenant)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if len(result) == 0 && s.hinter != nil {
+		s.hinter.HintScaleUp(q)
+	}
+	if len(result) == 0 && q.Wait > 0 && s.waiters != nil {
+		result, err = s.wait(ctx, q, tenant)
+		if err == errTooManyWaiting {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+	}
+	resp := &querypb.QueryResponse{}
+	for i := range result {
+		resp.GameServers = append(resp.GameServers, gameServerToProto(&result[i]))
+	}
+	return resp, nil
+}
+
+// grpcBearerToken returns the bearer token in the authorization metadata of ctx.
+func grpcBearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	const prefix = "Bearer "
+	for _, value := range md.Get("authorization") {
+		if strings.HasPrefix(value, prefix) {
+			return strings.TrimSpace(value[len(prefix):])
+		}
+	}
+	return ""
+}
+
+// queryFromRequest converts req to Query, validating it like parseQuery does.
+func queryFromRequest(req *querypb.QueryRequest) (*Query, error) {
+	q := &Query{
+		Namespace:    req.Namespace,
+		Squad:        req.Squad,
+		Region:       req.Region,
+		Zone:         req.Zone,
+		GameVersion:  req.GameVersion,
+		MinFreeSlots: req.MinFreeSlots,
+		Limit:        int(req.Limit),
+		Wait:         time.Duration(req.WaitSeconds) * time.Second,
+	}
+	if len(req.LabelSelector) != 0 {
+		selector, err := labels.Parse(req.LabelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid labelSelector: %v", err)
+		}
+		q.Selector = selector
+	}
+	if req.Limit < 0 {
+		return nil, fmt.Errorf("invalid limit: %v", req.Limit)
+	}
+	if req.WaitSeconds < 0 {
+		return nil, fmt.Errorf("invalid waitSeconds: %v", req.WaitSeconds)
+	}
+	return q, nil
+}
+
+// gameServerToProto converts gs to its message of the gRPC service.
+func gameServerToProto(gs *GameServer) *querypb.GameServer {
+	result := &querypb.GameServer{
+		Name:        gs.Name,
+		Namespace:   gs.Namespace,
+		Squad:       gs.Squad,
+		Region:      gs.Region,
+		Zone:        gs.Zone,
+		GameVersion: gs.GameVersion,
+		Address:     gs.Address,
+		NodeName:    gs.NodeName,
+		Players:     gs.Players,
+		FreeSlots:   gs.FreeSlots,
+	}
+	for _, port := range gs.Ports {
+		p := &querypb.GameServerPort{
+			Name:     port.Name,
+			Protocol: string(port.Protocol),
+		}
+		if port.ContainerPort != nil {
+			p.ContainerPort = *port.ContainerPort
+		}
+		if port.ContainerPortRange != nil {
+			p.ContainerPortRange = &querypb.PortRange{
+				MinPort: port.ContainerPortRange.MinPort,
+				MaxPort: port.ContainerPortRange.MaxPort,
+			}
+		}
+		if port.HostPort != nil {
+			p.HostPort = *port.HostPort
+		}
+		if port.HostPortRange != nil {
+			p.HostPortRange = &querypb.PortRange{
+				MinPort: port.HostPortRange.MinPort,
+				MaxPort: port.HostPortRange.MaxPort,
+			}
+		}
+		result.Ports = append(result.Ports, p)
+	}
+	return result
+}
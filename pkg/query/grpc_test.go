@@ -0,0 +1,92 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	listerv1alpha1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/query/querypb"
+	"github.com/ocgi/carrier/pkg/tenancy"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func TestServeGRPC(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, namespace := range []string{"a-prod", "b-prod"} {
+		port := int32(7777)
+		gs := newGameServer("gs", carrierv1alpha1.GameServerRunning,
+			map[string]string{util.GameServerCapacityAnnotation: "10"})
+		gs.Namespace = namespace
+		gs.Spec.Ports = []carrierv1alpha1.GameServerPort{{Name: "default", HostPort: &port}}
+		indexer.Add(gs)
+	}
+	tenants := &tenancy.Config{Tenants: []tenancy.Tenant{
+		{Name: "a", Namespaces: []string{"a-prod"}, Tokens: []string{"token-a"}},
+	}}
+	if err := tenants.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{gameServerLister: listerv1alpha1.NewGameServerLister(indexer), tenancy: tenants}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	querypb.RegisterGameServerQueryServer(server, &grpcService{server: s})
+	go server.Serve(listener)
+	defer server.Stop()
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := querypb.NewGameServerQueryClient(conn)
+
+	_, err = client.Query(context.Background(), &querypb.QueryRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("desired unauthenticated without token, get: %v", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer token-a")
+	_, err = client.Query(ctx, &querypb.QueryRequest{Namespace: "b-prod"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("desired permission denied for namespace of other tenant, get: %v", err)
+	}
+	_, err = client.Query(ctx, &querypb.QueryRequest{LabelSelector: "a in (b"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("desired invalid argument for invalid selector, get: %v", err)
+	}
+	resp, err := client.Query(ctx, &querypb.QueryRequest{MinFreeSlots: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GameServers) != 1 || resp.GameServers[0].Namespace != "a-prod" ||
+		resp.GameServers[0].FreeSlots != 10 {
+		t.Fatalf("desired GameServer of a-prod with 10 free slots, get: %v", resp.GameServers)
+	}
+	if ports := resp.GameServers[0].Ports; len(ports) != 1 || ports[0].HostPort != 7777 {
+		t.Errorf("desired host port 7777, get: %v", ports)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameservers"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// DefaultLimit is the max number of GameServers returned if limit is not specified.
+const DefaultLimit = 10
+
+// Query describes the GameServers wanted.
+type Query struct {
+	// Namespace of GameServers, all namespaces if empty.
+	Namespace string
+	// Squad is the name of Squad GameServers belong to.
+	Squad string
+	// Region of the node GameServers run on.
+	Region string
+	// Zone of the node GameServers run on.
+	Zone string
+	// GameVersion is the game version GameServers are running.
+	GameVersion string
+	// Selector selects GameServers by labels.
+	Selector labels.Selector
+	// MinFreeSlots is the min number of free slots, GameServers not reporting
+	// capacity are excluded if it is positive.
+	MinFreeSlots int64
+	// Limit is the max number of GameServers returned.
+	Limit int
+	// Wait is how long the query waits for GameServers if none matches, not waiting if 0.
+	Wait time.Duration
+}
+
+// GameServer is a GameServer matching the query.
+type GameServer struct {
+	Name        string                           `json:"name"`
+	Namespace   string                           `json:"namespace"`
+	Squad       string                           `json:"squad,omitempty"`
+	Region      string                           `json:"region,omitempty"`
+	Zone        string                           `json:"zone,omitempty"`
+	GameVersion string                           `json:"gameVersion,omitempty"`
+	Address     string                           `json:"address"`
+	NodeName    string                           `json:"nodeName,omitempty"`
+	Ports       []carrierv1alpha1.GameServerPort `json:"ports,omitempty"`
+	// Players is the number of players, -1 if not reported.
+	Players int64 `json:"players"`
+	// FreeSlots is the capacity minus players, -1 if capacity is not reported.
+	FreeSlots int64 `json:"freeSlots"`
+}
+
+// labelSelector returns the selector combining the label conditions of query.
+func (q *Query) labelSelector() labels.Selector {
+	selector := labels.Everything()
+	if q.Selector != nil {
+		selector = q.Selector
+	}
+	for key, value := range map[string]string{
+		util.SquadNameLabelKey:        q.Squad,
+		util.GameServerRegionLabelKey: q.Region,
+		util.GameServerZoneLabelKey:   q.Zone,
+	} {
+		if len(value) == 0 {
+			continue
+		}
+		requirement, err := labels.NewRequirement(key, selection.Equals, []string{value})
+		if err != nil {
+			// invalid values match nothing.
+			return labels.Nothing()
+		}
+		selector = selector.Add(*requirement)
+	}
+	return selector
+}
+
+// Filter returns the ready GameServers matching query, the ones with most free slots first.
+func Filter(gsList []*carrierv1alpha1.GameServer, q *Query) []GameServer {
+	var result []GameServer
+	for _, gs := range gsList {
+		if !isAvailable(gs) {
+			continue
+		}
+		if len(q.GameVersion) != 0 && gs.Status.GameVersion != q.GameVersion {
+			continue
+		}
+		freeSlots := getFreeSlots(gs)
+		if q.MinFreeSlots > 0 && freeSlots < q.MinFreeSlots {
+			continue
+		}
+		result = append(result, GameServer{
+			Name:        gs.Name,
+			Namespace:   gs.Namespace,
+			Squad:       gs.Labels[util.SquadNameLabelKey],
+			Region:      gs.Labels[util.GameServerRegionLabelKey],
+			Zone:        gs.Labels[util.GameServerZoneLabelKey],
+			GameVersion: gs.Status.GameVersion,
+			Address:     gs.Status.Address,
+			NodeName:    gs.Status.NodeName,
+			Ports:       gs.Spec.Ports,
+			Players:     getIntAnnotation(gs, util.GameServerPlayersAnnotation),
+			FreeSlots:   freeSlots,
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].FreeSlots != result[j].FreeSlots {
+			return result[i].FreeSlots > result[j].FreeSlots
+		}
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// isAvailable checks if GameServer is running, ready and accepts new sessions.
+func isAvailable(gs *carrierv1alpha1.GameServer) bool {
+	return gs.Status.State == carrierv1alpha1.GameServerRunning && !gameservers.IsBeingDeleted(gs) &&
+		gameservers.IsReady(gs) && gameservers.AcceptsNewSessions(gs)
+}
+
+// getFreeSlots returns capacity minus players of GameServer, -1 if capacity is not reported.
+func getFreeSlots(gs *carrierv1alpha1.GameServer) int64 {
+	capacity := getIntAnnotation(gs, util.GameServerCapacityAnnotation)
+	if capacity < 0 {
+		return -1
+	}
+	players := getIntAnnotation(gs, util.GameServerPlayersAnnotation)
+	if players < 0 {
+		players = 0
+	}
+	if players > capacity {
+		return 0
+	}
+	return capacity - players
+}
+
+// getIntAnnotation returns the non negative int value of annotation, -1 if not set or invalid.
+func getIntAnnotation(gs *carrierv1alpha1.GameServer, key string) int64 {
+	value, ok := gs.Annotations[key]
+	if !ok {
+		return -1
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || i < 0 {
+		return -1
+	}
+	return i
+}
@@ -0,0 +1,200 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	listerv1alpha1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/tenancy"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func newGameServer(name string, state carrierv1alpha1.GameServerState,
+	annotations map[string]string) *carrierv1alpha1.GameServer {
+	return &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: annotations,
+		},
+		Status: carrierv1alpha1.GameServerStatus{State: state},
+	}
+}
+
+func TestFilter(t *testing.T) {
+	effective := true
+	outOfService := newGameServer("out-of-service", carrierv1alpha1.GameServerRunning,
+		map[string]string{util.GameServerCapacityAnnotation: "10"})
+	outOfService.Spec.Constraints = []carrierv1alpha1.Constraint{
+		{Type: carrierv1alpha1.NotInService, Effective: &effective},
+	}
+	half := newGameServer("half", carrierv1alpha1.GameServerRunning,
+		map[string]string{util.GameServerCapacityAnnotation: "10", util.GameServerPlayersAnnotation: "5"})
+	half.Status.GameVersion = "v2"
+	gsList := []*carrierv1alpha1.GameServer{
+		newGameServer("full", carrierv1alpha1.GameServerRunning,
+			map[string]string{util.GameServerCapacityAnnotation: "10", util.GameServerPlayersAnnotation: "12"}),
+		half,
+		newGameServer("empty", carrierv1alpha1.GameServerRunning,
+			map[string]string{util.GameServerCapacityAnnotation: "10"}),
+		newGameServer("unknown", carrierv1alpha1.GameServerRunning, nil),
+		newGameServer("starting", carrierv1alpha1.GameServerStarting,
+			map[string]string{util.GameServerCapacityAnnotation: "10"}),
+		outOfService,
+	}
+	tests := []struct {
+		name    string
+		query   *Query
+		desired []string
+	}{
+		{
+			name:    "all available",
+			query:   &Query{},
+			desired: []string{"empty", "half", "full", "unknown"},
+		},
+		{
+			name:    "min free slots",
+			query:   &Query{MinFreeSlots: 5},
+			desired: []string{"empty", "half"},
+		},
+		{
+			name:    "game version",
+			query:   &Query{GameVersion: "v2"},
+			desired: []string{"half"},
+		},
+		{
+			name:    "limit",
+			query:   &Query{Limit: 1},
+			desired: []string{"empty"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var names []string
+			for _, gs := range Filter(gsList, tc.query) {
+				names = append(names, gs.Name)
+			}
+			if !reflect.DeepEqual(tc.desired, names) {
+				t.Errorf("desired %v, get: %v", tc.desired, names)
+			}
+		})
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	r := httptest.NewRequest("GET",
+		"/gameservers?namespace=default&zone=a&minFreeSlots=4&limit=10&labelSelector=app%3Dfps", nil)
+	q, err := parseQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Namespace != "default" || q.Zone != "a" || q.MinFreeSlots != 4 || q.Limit != 10 {
+		t.Errorf("unexpected query: %+v", q)
+	}
+	desired := "app=fps," + util.GameServerZoneLabelKey + "=a"
+	if selector := q.labelSelector().String(); selector != desired {
+		t.Errorf("desired selector %v, get: %v", desired, selector)
+	}
+
+	for _, invalid := range []string{
+		"/gameservers?limit=0",
+		"/gameservers?minFreeSlots=a",
+		"/gameservers?labelSelector=a%20in%20(b",
+		"/gameservers?waitSeconds=-1",
+	} {
+		if _, err := parseQuery(httptest.NewRequest("GET", invalid, nil)); err == nil {
+			t.Errorf("desired error for %v", invalid)
+		}
+	}
+}
+
+func TestServeGameServersTenancy(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, namespace := range []string{"a-prod", "b-prod"} {
+		gs := newGameServer("gs", carrierv1alpha1.GameServerRunning, nil)
+		gs.Namespace = namespace
+		indexer.Add(gs)
+	}
+	tenants := &tenancy.Config{Tenants: []tenancy.Tenant{
+		{Name: "a", Namespaces: []string{"a-prod"}, Tokens: []string{"token-a"}},
+		{Name: "b", Namespaces: []string{"b-prod"}, Tokens: []string{"token-b"}},
+	}}
+	if err := tenants.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{gameServerLister: listerv1alpha1.NewGameServerLister(indexer), tenancy: tenants}
+	tests := []struct {
+		name       string
+		url        string
+		token      string
+		code       int
+		namespaces []string
+	}{
+		{
+			name: "no token",
+			url:  "/gameservers",
+			code: http.StatusUnauthorized,
+		},
+		{
+			name:       "all namespaces of tenant",
+			url:        "/gameservers",
+			token:      "token-a",
+			code:       http.StatusOK,
+			namespaces: []string{"a-prod"},
+		},
+		{
+			name:  "namespace of other tenant",
+			url:   "/gameservers?namespace=a-prod",
+			token: "token-b",
+			code:  http.StatusForbidden,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", test.url, nil)
+			if len(test.token) != 0 {
+				r.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			w := httptest.NewRecorder()
+			s.serveGameServers(w, r)
+			if w.Code != test.code {
+				t.Fatalf("desired code %v, get: %v", test.code, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var gsList []GameServer
+			if err := json.Unmarshal(w.Body.Bytes(), &gsList); err != nil {
+				t.Fatal(err)
+			}
+			var namespaces []string
+			for _, gs := range gsList {
+				namespaces = append(namespaces, gs.Namespace)
+			}
+			if !reflect.DeepEqual(test.namespaces, namespaces) {
+				t.Errorf("desired namespaces %v, get: %v", test.namespaces, namespaces)
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/informers/externalversions"
+	listerv1alpha1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/query/querypb"
+	"github.com/ocgi/carrier/pkg/tenancy"
+)
+
+// GameServersPath is the path serving GameServer queries, parameters are namespace,
+// squad, region, zone, gameVersion, labelSelector, minFreeSlots, limit and waitSeconds, e.g.
+// /gameservers?zone=a&minFreeSlots=5&limit=10&waitSeconds=30
+const GameServersPath = "/gameservers"
+
+// Config describes how queries matching no GameServers wait for them, and where
+// the gRPC service is served.
+type Config struct {
+	// GRPCPort is the port serving the GameServerQuery gRPC service of querypb,
+	// not served if 0.
+	GRPCPort int
+	// CertFile and KeyFile serve queries over TLS if set, which tenancy needs as tenants
+	// send their tokens in queries.
+	CertFile string
+	KeyFile  string
+	// MaxWaiting is the max number of queries waiting at the same time, queries beyond are
+	// rejected with 429. Queries do not wait if 0.
+	MaxWaiting int
+	// MaxWait is the longest a query waits, whatever its waitSeconds.
+	MaxWait time.Duration
+	// Hinter is told of the queries matching no GameServers, nil if not needed.
+	Hinter ScaleUpHinter
+}
+
+// ScaleUpHinter is told of the queries matching no GameServers, so more could be scaled up
+// before the queries waiting time out, and before matchmakers retry.
+type ScaleUpHinter interface {
+	// HintScaleUp hints the GameServers wanted by q are not enough.
+	HintScaleUp(q *Query)
+}
+
+// Server serves GameServer queries from the informer cache, without hitting the apiserver.
+type Server struct {
+	addr             string
+	grpcAddr         string
+	certFile         string
+	keyFile          string
+	gameServerLister listerv1alpha1.GameServerLister
+	gameServerSynced cache.InformerSynced
+	mux              *http.ServeMux
+	// tenancy authenticates queries by bearer tokens, queries are restricted to the
+	// namespaces of the tenant. nil if tenancy is not configured.
+	tenancy *tenancy.Config
+	// waiters are the queries waiting for GameServers, nil if queries do not wait.
+	waiters *waitQueue
+	maxWait time.Duration
+	hinter  ScaleUpHinter
+}
+
+// NewServer returns a new query server listening on port. If tenants is not nil,
+// every query must carry the bearer token of a tenant, and the TLS files of config
+// should be set so tokens are not sent in plaintext.
+func NewServer(port int, carrierInformerFactory externalversions.SharedInformerFactory,
+	tenants *tenancy.Config, config Config) *Server {
+	gameServers := carrierInformerFactory.Carrier().V1alpha1().GameServers()
+	s := &Server{
+		addr:             fmt.Sprintf(":%d", port),
+		gameServerLister: gameServers.Lister(),
+		gameServerSynced: gameServers.Informer().HasSynced,
+		mux:              http.NewServeMux(),
+		tenancy:          tenants,
+		maxWait:          config.MaxWait,
+		hinter:           config.Hinter,
+		certFile:         config.CertFile,
+		keyFile:          config.KeyFile,
+	}
+	if config.GRPCPort != 0 {
+		s.grpcAddr = fmt.Sprintf(":%d", config.GRPCPort)
+	}
+	if config.MaxWaiting > 0 && config.MaxWait > 0 {
+		s.waiters = newWaitQueue(config.MaxWaiting)
+		gameServers.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				s.notifyWaiters(nil, obj)
+			},
+			UpdateFunc: s.notifyWaiters,
+		})
+	}
+	s.mux.HandleFunc(GameServersPath, s.serveGameServers)
+	return s
+}
+
+// Run starts the query server, and the gRPC service if its port is set, after the cache
+// synced. Will block until stop is closed.
+func (s *Server) Run(stop <-chan struct{}) error {
+	if !cache.WaitForCacheSync(stop, s.gameServerSynced) {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+	if len(s.grpcAddr) != 0 {
+		var options []grpc.ServerOption
+		if len(s.certFile) != 0 {
+			creds, err := credentials.NewServerTLSFromFile(s.certFile, s.keyFile)
+			if err != nil {
+				return err
+			}
+			options = append(options, grpc.Creds(creds))
+		}
+		listener, err := net.Listen("tcp", s.grpcAddr)
+		if err != nil {
+			return err
+		}
+		grpcServer := grpc.NewServer(options...)
+		querypb.RegisterGameServerQueryServer(grpcServer, &grpcService{server: s})
+		go func() {
+			<-stop
+			grpcServer.Stop()
+		}()
+		go func() {
+			klog.Infof("Starting gRPC query server on %v", s.grpcAddr)
+			if err := grpcServer.Serve(listener); err != nil {
+				klog.Errorf("gRPC query server stopped: %v", err)
+			}
+		}()
+	}
+	server := &http.Server{
+		Addr:    s.addr,
+		Handler: s.mux,
+	}
+	go func() {
+		<-stop
+		server.Close()
+	}()
+	klog.Infof("Starting query server on %v", s.addr)
+	var err error
+	if len(s.certFile) != 0 {
+		err = server.ListenAndServeTLS(s.certFile, s.keyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) serveGameServers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := parseQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var tenant *tenancy.Tenant
+	if s.tenancy != nil {
+		tenant = s.tenancy.TenantForToken(bearerToken(r))
+		if tenant == nil {
+			http.Error(w, "unknown bearer token", http.StatusUnauthorized)
+			return
+		}
+		if len(q.Namespace) != 0 && !tenant.Allows(q.Namespace) {
+			http.Error(w, fmt.Sprintf("namespace %v is not allowed for tenant %v", q.Namespace, tenant.Name),
+				http.StatusForbidden)
+			return
+		}
+	}
+	result, err := s.match(q, tenant)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(result) == 0 && s.hinter != nil {
+		s.hinter.HintScaleUp(q)
+	}
+	if len(result) == 0 && q.Wait > 0 && s.waiters != nil {
+		result, err = s.wait(r.Context(), q, tenant)
+		if err == errTooManyWaiting {
+			w.Header().Set("Retry-After", strconv.Itoa(int(s.maxWait.Seconds())))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	resp, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(resp); err != nil {
+		klog.Errorf("Failed to write query response: %v", err)
+	}
+}
+
+// match returns the GameServers matching q in the namespaces of tenant, tenant is nil if
+// tenancy is not configured.
+func (s *Server) match(q *Query, tenant *tenancy.Tenant) ([]GameServer, error) {
+	var gsList []*carrierv1alpha1.GameServer
+	var err error
+	if len(q.Namespace) == 0 {
+		gsList, err = s.gameServerLister.List(q.labelSelector())
+	} else {
+		gsList, err = s.gameServerLister.GameServers(q.Namespace).List(q.labelSelector())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tenant != nil {
+		gsList = filterTenant(gsList, tenant)
+	}
+	return Filter(gsList, q), nil
+}
+
+// wait holds q until a GameServer matching it becomes available, at most for its wait time
+// capped by the max wait of server, the result is empty on timeout. Queries waiting are
+// handed GameServers in FIFO order, one GameServer each. Returns errTooManyWaiting if the
+// wait queue is full.
+func (s *Server) wait(ctx context.Context, q *Query, tenant *tenancy.Tenant) ([]GameServer, error) {
+	// queue before matching again, so GameServers available in between are not missed.
+	w := s.waiters.enter(q, tenant)
+	if w == nil {
+		return nil, errTooManyWaiting
+	}
+	defer s.waiters.leave(w)
+	result, err := s.match(q, tenant)
+	if err != nil || len(result) != 0 {
+		return result, err
+	}
+	timeout := q.Wait
+	if timeout > s.maxWait {
+		timeout = s.maxWait
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case gs := <-w.ready:
+		return Filter([]*carrierv1alpha1.GameServer{gs}, q), nil
+	case <-timer.C:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// notifyWaiters hands the GameServer newObj to the first waiting query it starts to match,
+// oldObj is nil if newObj is just added.
+func (s *Server) notifyWaiters(oldObj, newObj interface{}) {
+	newGS, ok := newObj.(*carrierv1alpha1.GameServer)
+	if !ok {
+		return
+	}
+	oldGS, _ := oldObj.(*carrierv1alpha1.GameServer)
+	s.waiters.notify(oldGS, newGS)
+}
+
+// bearerToken returns the bearer token in the Authorization header of r.
+func bearerToken(r *http.Request) string {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
+
+// filterTenant returns the GameServers in the namespaces of tenant.
+func filterTenant(gsList []*carrierv1alpha1.GameServer, tenant *tenancy.Tenant) []*carrierv1alpha1.GameServer {
+	var result []*carrierv1alpha1.GameServer
+	for _, gs := range gsList {
+		if tenant.Allows(gs.Namespace) {
+			result = append(result, gs)
+		}
+	}
+	return result
+}
+
+// parseQuery parses Query from the url parameters of request.
+func parseQuery(r *http.Request) (*Query, error) {
+	values := r.URL.Query()
+	q := &Query{
+		Namespace:   values.Get("namespace"),
+		Squad:       values.Get("squad"),
+		Region:      values.Get("region"),
+		Zone:        values.Get("zone"),
+		GameVersion: values.Get("gameVersion"),
+	}
+	if value := values.Get("labelSelector"); len(value) != 0 {
+		selector, err := labels.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid labelSelector: %v", err)
+		}
+		q.Selector = selector
+	}
+	if value := values.Get("minFreeSlots"); len(value) != 0 {
+		minFreeSlots, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid minFreeSlots: %v", err)
+		}
+		q.MinFreeSlots = minFreeSlots
+	}
+	if value := values.Get("limit"); len(value) != 0 {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid limit: %v", value)
+		}
+		q.Limit = limit
+	}
+	if value := values.Get("waitSeconds"); len(value) != 0 {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("invalid waitSeconds: %v", value)
+		}
+		q.Wait = time.Duration(seconds) * time.Second
+	}
+	return q, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"errors"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/tenancy"
+)
+
+// errTooManyWaiting is returned when a query could not wait as the wait queue is full.
+var errTooManyWaiting = errors.New("too many queries waiting for GameServers")
+
+// waitQueue bounds the queries waiting for GameServers. Queries wait in FIFO order, and a
+// GameServer starting to match is handed to the first query waiting for it only, so waiting
+// matchmakers are not all woken up for the same GameServer.
+type waitQueue struct {
+	sync.Mutex
+	// max is the max number of queries waiting.
+	max     int
+	waiters []*waiter
+}
+
+// waiter is a query waiting in the queue.
+type waiter struct {
+	query *Query
+	// tenant of the query, nil if tenancy is not configured.
+	tenant *tenancy.Tenant
+	// ready receives the GameServer handed to the waiter.
+	ready chan *carrierv1alpha1.GameServer
+}
+
+func newWaitQueue(max int) *waitQueue {
+	return &waitQueue{max: max}
+}
+
+// enter queues query at the end of the queue, returns nil if the queue is full.
+func (q *waitQueue) enter(query *Query, tenant *tenancy.Tenant) *waiter {
+	q.Lock()
+	defer q.Unlock()
+	if len(q.waiters) >= q.max {
+		return nil
+	}
+	w := &waiter{query: query, tenant: tenant, ready: make(chan *carrierv1alpha1.GameServer, 1)}
+	q.waiters = append(q.waiters, w)
+	return w
+}
+
+// leave removes w from the queue. If w was handed a GameServer it did not take, the
+// GameServer is handed to the next waiter matching it.
+func (q *waitQueue) leave(w *waiter) {
+	q.Lock()
+	defer q.Unlock()
+	if q.remove(w) {
+		return
+	}
+	select {
+	case gs := <-w.ready:
+		q.hand(nil, gs)
+	default:
+	}
+}
+
+// notify hands the GameServer newGS to the first waiter it starts to match, oldGS is nil
+// if newGS is just added.
+func (q *waitQueue) notify(oldGS, newGS *carrierv1alpha1.GameServer) {
+	q.Lock()
+	defer q.Unlock()
+	q.hand(oldGS, newGS)
+}
+
+// hand sends newGS to the first waiter it matches but oldGS does not, and removes the
+// waiter from the queue. Must be called with the lock held.
+func (q *waitQueue) hand(oldGS, newGS *carrierv1alpha1.GameServer) {
+	for _, w := range q.waiters {
+		if !w.matches(newGS) || (oldGS != nil && w.matches(oldGS)) {
+			continue
+		}
+		q.remove(w)
+		w.ready <- newGS
+		return
+	}
+}
+
+// remove removes w from the queue, returns false if w is not queued.
+func (q *waitQueue) remove(w *waiter) bool {
+	for i := range q.waiters {
+		if q.waiters[i] == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// matches checks if gs matches the query of w.
+func (w *waiter) matches(gs *carrierv1alpha1.GameServer) bool {
+	if len(w.query.Namespace) != 0 && gs.Namespace != w.query.Namespace {
+		return false
+	}
+	if w.tenant != nil && !w.tenant.Allows(gs.Namespace) {
+		return false
+	}
+	if !w.query.labelSelector().Matches(labels.Set(gs.Labels)) {
+		return false
+	}
+	return len(Filter([]*carrierv1alpha1.GameServer{gs}, w.query)) != 0
+}
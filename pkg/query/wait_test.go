@@ -0,0 +1,148 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	listerv1alpha1 "github.com/ocgi/carrier/pkg/client/listers/carrier/v1alpha1"
+)
+
+type fakeHinter struct {
+	queries []*Query
+}
+
+func (h *fakeHinter) HintScaleUp(q *Query) {
+	h.queries = append(h.queries, q)
+}
+
+func newWaitingServer(indexer cache.Indexer, maxWaiting int) (*Server, *fakeHinter) {
+	hinter := &fakeHinter{}
+	return &Server{
+		gameServerLister: listerv1alpha1.NewGameServerLister(indexer),
+		waiters:          newWaitQueue(maxWaiting),
+		maxWait:          time.Minute,
+		hinter:           hinter,
+	}, hinter
+}
+
+func TestServeGameServersWait(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	s, hinter := newWaitingServer(indexer, 1)
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		s.serveGameServers(w, httptest.NewRequest("GET", "/gameservers?waitSeconds=30", nil))
+		done <- w
+	}()
+	for !waiting(s) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the queue is full
+	w := httptest.NewRecorder()
+	s.serveGameServers(w, httptest.NewRequest("GET", "/gameservers?waitSeconds=30", nil))
+	if w.Code != http.StatusTooManyRequests || len(w.Header().Get("Retry-After")) == 0 {
+		t.Errorf("desired 429 with Retry-After, get: %v %v", w.Code, w.Header())
+	}
+
+	// not available yet
+	starting := newGameServer("gs", carrierv1alpha1.GameServerStarting, nil)
+	indexer.Add(starting)
+	s.notifyWaiters(nil, starting)
+	gs := newGameServer("gs", carrierv1alpha1.GameServerRunning, nil)
+	indexer.Update(gs)
+	s.notifyWaiters(starting, gs)
+
+	select {
+	case w := <-done:
+		var gsList []GameServer
+		if err := json.Unmarshal(w.Body.Bytes(), &gsList); err != nil {
+			t.Fatal(err)
+		}
+		if len(gsList) != 1 || gsList[0].Name != "gs" {
+			t.Errorf("desired gs returned, get: %v", gsList)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("query is not woken up")
+	}
+	// the query rejected missed as well
+	if len(hinter.queries) != 2 {
+		t.Errorf("desired 2 scale up hints, get: %v", len(hinter.queries))
+	}
+	if waiting(s) {
+		t.Errorf("desired queue released")
+	}
+}
+
+func TestServeGameServersWaitTimeout(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	s, _ := newWaitingServer(indexer, 1)
+	s.maxWait = 10 * time.Millisecond
+	w := httptest.NewRecorder()
+	s.serveGameServers(w, httptest.NewRequest("GET", "/gameservers?waitSeconds=30", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("desired 200 on timeout, get: %v", w.Code)
+	}
+	var gsList []GameServer
+	if err := json.Unmarshal(w.Body.Bytes(), &gsList); err != nil || len(gsList) != 0 {
+		t.Errorf("desired no GameServers, get: %v, %v", gsList, err)
+	}
+}
+
+func TestWaitQueueFIFO(t *testing.T) {
+	q := newWaitQueue(2)
+	first := q.enter(&Query{}, nil)
+	second := q.enter(&Query{}, nil)
+	if q.enter(&Query{}, nil) != nil {
+		t.Fatal("desired queue full")
+	}
+	starting := newGameServer("gs", carrierv1alpha1.GameServerStarting, nil)
+	gs := newGameServer("gs", carrierv1alpha1.GameServerRunning, nil)
+	q.notify(nil, starting)
+	q.notify(starting, gs)
+	// still matching, not handed again
+	q.notify(gs, gs)
+	if len(first.ready) != 1 || len(second.ready) != 0 {
+		t.Fatalf("desired gs handed to the first waiter only, get: %v, %v", len(first.ready), len(second.ready))
+	}
+	// not taken by the first waiter, handed to the next one
+	q.leave(first)
+	select {
+	case handed := <-second.ready:
+		if handed.Name != "gs" {
+			t.Errorf("desired gs handed, get: %v", handed.Name)
+		}
+	default:
+		t.Fatal("desired gs handed to the second waiter")
+	}
+	q.leave(second)
+	if len(q.waiters) != 0 {
+		t.Errorf("desired queue empty, get: %v", len(q.waiters))
+	}
+}
+
+func waiting(s *Server) bool {
+	s.waiters.Lock()
+	defer s.waiters.Unlock()
+	return len(s.waiters.waiters) != 0
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tenancy
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Tenant is a set of namespaces isolated from other tenants.
+type Tenant struct {
+	// Name of the tenant.
+	Name string `json:"name"`
+	// Namespaces owned by the tenant, a namespace belongs to one tenant at most.
+	Namespaces []string `json:"namespaces"`
+	// Tokens are the bearer tokens the tenant queries GameServers with.
+	Tokens []string `json:"tokens,omitempty"`
+	// WebhookURLs receive the lifecycle events of GameServers in the namespaces of the tenant.
+	WebhookURLs []string `json:"webhookURLs,omitempty"`
+}
+
+// Config is the tenancy configuration, loaded from a YAML or JSON file.
+type Config struct {
+	Tenants []Tenant `json:"tenants"`
+
+	byToken     map[string]*Tenant
+	byNamespace map[string]*Tenant
+}
+
+// Load reads and validates the tenancy configuration from path.
+func Load(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening tenancy config")
+	}
+	defer file.Close()
+	config := &Config{}
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(config); err != nil {
+		return nil, errors.Wrap(err, "error decoding tenancy config")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate checks tenants are named uniquely, and namespaces and tokens are not shared
+// by tenants. It also indexes the tenants by their namespaces and tokens.
+func (c *Config) Validate() error {
+	names := sets.NewString()
+	c.byToken = make(map[string]*Tenant)
+	c.byNamespace = make(map[string]*Tenant)
+	for i := range c.Tenants {
+		tenant := &c.Tenants[i]
+		if len(tenant.Name) == 0 {
+			return errors.New("tenant name must not be empty")
+		}
+		if names.Has(tenant.Name) {
+			return errors.Errorf("tenant %v is duplicated", tenant.Name)
+		}
+		names.Insert(tenant.Name)
+		if len(tenant.Namespaces) == 0 {
+			return errors.Errorf("tenant %v has no namespaces", tenant.Name)
+		}
+		for _, namespace := range tenant.Namespaces {
+			if owner, ok := c.byNamespace[namespace]; ok {
+				return errors.Errorf("namespace %v is shared by tenant %v and %v", namespace, owner.Name, tenant.Name)
+			}
+			c.byNamespace[namespace] = tenant
+		}
+		for _, token := range tenant.Tokens {
+			if len(token) == 0 {
+				return errors.Errorf("tenant %v has an empty token", tenant.Name)
+			}
+			if owner, ok := c.byToken[token]; ok {
+				return errors.Errorf("token of tenant %v is shared by tenant %v", owner.Name, tenant.Name)
+			}
+			c.byToken[token] = tenant
+		}
+	}
+	return nil
+}
+
+// TenantForToken returns the tenant authenticated by token, nil if no tenant has it.
+func (c *Config) TenantForToken(token string) *Tenant {
+	return c.byToken[token]
+}
+
+// TenantOf returns the tenant owning namespace, nil if it belongs to no tenant.
+func (c *Config) TenantOf(namespace string) *Tenant {
+	return c.byNamespace[namespace]
+}
+
+// Allows returns true if namespace is owned by tenant.
+func (t *Tenant) Allows(namespace string) bool {
+	for _, ns := range t.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tenancy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tenants []Tenant
+		valid   bool
+	}{
+		{
+			name: "valid",
+			tenants: []Tenant{
+				{Name: "a", Namespaces: []string{"a-prod", "a-test"}, Tokens: []string{"token-a"}},
+				{Name: "b", Namespaces: []string{"b-prod"}, Tokens: []string{"token-b"}},
+			},
+			valid: true,
+		},
+		{
+			name:    "no namespaces",
+			tenants: []Tenant{{Name: "a"}},
+		},
+		{
+			name: "shared namespace",
+			tenants: []Tenant{
+				{Name: "a", Namespaces: []string{"prod"}},
+				{Name: "b", Namespaces: []string{"prod"}},
+			},
+		},
+		{
+			name: "shared token",
+			tenants: []Tenant{
+				{Name: "a", Namespaces: []string{"a-prod"}, Tokens: []string{"token"}},
+				{Name: "b", Namespaces: []string{"b-prod"}, Tokens: []string{"token"}},
+			},
+		},
+		{
+			name: "duplicated name",
+			tenants: []Tenant{
+				{Name: "a", Namespaces: []string{"a-prod"}},
+				{Name: "a", Namespaces: []string{"a-test"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{Tenants: test.tenants}
+			if err := config.Validate(); (err == nil) != test.valid {
+				t.Errorf("desired valid %v, get: %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tenancy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tenancy.yaml")
+	data := `
+tenants:
+- name: a
+  namespaces: [a-prod]
+  tokens: [token-a]
+  webhookURLs: [http://a.example.com/events]
+`
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenant := config.TenantForToken("token-a")
+	if tenant == nil || tenant.Name != "a" {
+		t.Fatalf("desired tenant a, get: %+v", tenant)
+	}
+	if !tenant.Allows("a-prod") || tenant.Allows("b-prod") {
+		t.Errorf("desired tenant a allows only a-prod")
+	}
+	if config.TenantOf("b-prod") != nil {
+		t.Errorf("desired b-prod belongs to no tenant")
+	}
+}
@@ -25,10 +25,23 @@ const (
 	GameServerLabelRoleValue = "gameserver"
 	// GameServerPodLabelKey default if group + gameserver
 	GameServerPodLabelKey = carrier.GroupName + "/gameserver"
+	// GameServerUIDLabelKey is the UID of the GameServer a pod is created for, so pods losing
+	// their owner are only adopted by the same GameServer, not one recreated with the same name.
+	GameServerUIDLabelKey = carrier.GroupName + "/gameserver-uid"
 	// GameServerSetLabelKey default if group + gameserverset
 	GameServerSetLabelKey = carrier.GroupName + "/gameserverset"
 	// SquadNameLabelKey default if group + squad
 	SquadNameLabelKey = carrier.GroupName + "/squad"
+	// PreflightLabelKey is the name of Squad the preflight GameServer checks the new template for
+	PreflightLabelKey = carrier.GroupName + "/preflight"
+	// GameServerRegionLabelKey is the region of node GameServer runs on, copied from node topology labels.
+	// Matchmakers can select GameServers close to players by it.
+	GameServerRegionLabelKey = carrier.GroupName + "/region"
+	// GameServerZoneLabelKey is the zone of node GameServer runs on, copied from node topology labels.
+	GameServerZoneLabelKey = carrier.GroupName + "/zone"
+	// GameVersionLabelKey is the game version of GameServer, copied from its spec, so that
+	// GameServers and pods of a version can be selected.
+	GameVersionLabelKey = carrier.GroupName + "/game-version"
 	// GameServerContainerName default is server
 	GameServerContainerName = "server"
 
@@ -61,6 +74,11 @@ const (
 	// ResumedDeployReason is added in a squad when it is resumed. Useful for not failing accidentally
 	// Squad that paused amidst a rollout and are bounded by a deadline.
 	ResumedDeployReason = "SquadResumed"
+	// GameServersReadyReason is added in a squad or gameserverset when all desired gameservers are ready.
+	GameServersReadyReason = "GameServersReady"
+	// GameServersProgressingReason is added in a squad or gameserverset when desired gameservers are
+	// being updated, created or deleted.
+	GameServersProgressingReason = "GameServersProgressing"
 
 	// RollbackRevisionNotFound is not found rollback event reason
 	RollbackRevisionNotFound = "SquadRollbackRevisionNotFound"
@@ -70,6 +88,46 @@ const (
 	RollbackDone = "SquadRollback"
 	// ScalingReplicasAnnotation marks squad is scaling
 	ScalingReplicasAnnotation = carrier.GroupName + "/scaling"
+	// TrafficWeightAnnotation is the percentage of new sessions shifted to a new gameserverset
+	// by the traffic hook of squad during canary update.
+	TrafficWeightAnnotation = carrier.GroupName + "/traffic-weight"
+	// TrafficShiftedReason is added in a squad when new sessions are shifted by its traffic hook.
+	TrafficShiftedReason = "TrafficShifted"
+	// FailedTrafficShiftReason is added in a squad when its traffic hook fails.
+	FailedTrafficShiftReason = "TrafficShiftError"
+	// CanaryAbortedAnnotation marks the new gameserverset of squad whose canary update is aborted
+	// by the metric gate, the value is the metric breaching the threshold.
+	CanaryAbortedAnnotation = carrier.GroupName + "/canary-aborted"
+	// CanaryAbortedReason is added in a squad when its canary update is aborted by the metric gate.
+	CanaryAbortedReason = "CanaryAborted"
+	// CanaryHeldReason is added in a squad whose canary update is held at its threshold, running
+	// two versions weighted by the threshold.
+	CanaryHeldReason = "CanaryHeld"
+	// FailedMetricGateReason is added in a squad when its metric gate fails to be evaluated.
+	FailedMetricGateReason = "MetricGateError"
+	// ImagePullSecretMissingReason is added in a squad when some of its imagePullSecrets do not exist.
+	ImagePullSecretMissingReason = "ImagePullSecretMissing"
+	// PreflightRunningReason is added in a squad while its preflight GameServer is running.
+	PreflightRunningReason = "PreflightRunning"
+	// PreflightPassedReason is added in a squad once its preflight GameServer becomes ready.
+	PreflightPassedReason = "PreflightPassed"
+	// PreflightFailedReason is added in a squad when its preflight GameServer fails, the rollout
+	// is aborted until the template changes.
+	PreflightFailedReason = "PreflightFailed"
+	// WaitingForDependenciesReason is added in a squad whose rollout waits for the squads it depends on.
+	WaitingForDependenciesReason = "WaitingForDependencies"
+	// LowHeadroomReason is added in a squad whose ready GameServers are projected to be exhausted
+	// within the horizon at the recent allocation rate.
+	LowHeadroomReason = "LowHeadroom"
+	// SufficientHeadroomReason is added in a squad whose ready GameServers last beyond the horizon.
+	SufficientHeadroomReason = "SufficientHeadroom"
+	// PreemptedReason is added in a squad scaled down by a PriorityPolicy for its high priority squads.
+	PreemptedReason = "Preempted"
+	// SquadAutoscaledAnnotation marks a squad whose replicas are managed by an autoscaler when "true".
+	// PriorityPolicies do not preempt such squads, as the autoscaler would scale them back up.
+	SquadAutoscaledAnnotation = carrier.GroupName + "/autoscaled"
+	// BudgetCappedReason is added in a squad whose replicas are capped by its maxResources.
+	BudgetCappedReason = "BudgetCapped"
 	// GracefulUpdateAnnotation describes wait for the game server to exit before updating
 	GracefulUpdateAnnotation = carrier.GroupName + "/graceful-update"
 	// GameServerDeletionCost can be used to set to an int64 that represent the cost of deleting
@@ -80,6 +138,27 @@ const (
 	// The implicit deletion cost for game servers that don't set the annotation is int64 max
 	// negative values are permitted.
 	GameServerDeletionCost = "carrier.ocgi.dev/gs-deletion-cost"
+	// GameServerPlayersAnnotation is the number of players on the game server, reported by the game server.
+	// GameServers with fewer players are preferred to be scaled down.
+	GameServerPlayersAnnotation = "carrier.ocgi.dev/players"
+	// GameServerCapacityAnnotation is the max number of players of the game server, reported by the game server.
+	// Free slots of the game server are the capacity minus players.
+	GameServerCapacityAnnotation = "carrier.ocgi.dev/capacity"
+	// GameServerExternalEndpointAnnotation is the endpoint reachable by clients, e.g. "1.2.3.4:7777",
+	// reported by the game server through SDK SetExternalEndpoint after STUN or metadata lookup.
+	GameServerExternalEndpointAnnotation = "carrier.ocgi.dev/external-endpoint"
+	// IngressBandwidthAnnotation is the pod annotation of ingress bandwidth limit read by the CNI bandwidth plugin.
+	IngressBandwidthAnnotation = "kubernetes.io/ingress-bandwidth"
+	// EgressBandwidthAnnotation is the pod annotation of egress bandwidth limit read by the CNI bandwidth plugin.
+	EgressBandwidthAnnotation = "kubernetes.io/egress-bandwidth"
+	// GameServerGPUModelsAnnotation is the comma separated GPU models GameServer pods can run on,
+	// matched against the GPUModelNodeLabel of nodes.
+	GameServerGPUModelsAnnotation = "carrier.ocgi.dev/gpu-models"
+	// WindowsTaintKey is the key of taint commonly set on windows nodes to keep linux pods away,
+	// which is tolerated by windows GameServer pods.
+	WindowsTaintKey = "os"
+	// GPUModelNodeLabel is the node label of GPU model, which is set by GPU feature discovery.
+	GPUModelNodeLabel = "nvidia.com/gpu.product"
 	// GameServerDeletionMetrics is the metric name used by cost-server when sorting the candidate game servers
 	GameServerDeletionMetrics = "carrier.ocgi.dev/gs-cost-metrics-name"
 	// GameServerHash describes the pod spec hash of game server,
@@ -87,10 +166,117 @@ const (
 	GameServerHash = "carrier.ocgi.dev/gameserver-template-hash"
 	// GameServerInPlaceUpdateAnnotation describes gameserver in place update info
 	GameServerInPlaceUpdateAnnotation = "carrier.ocgi.dev/inplace-update-threshold"
+	// GameServerInPlaceUpdateMaxPerNodeAnnotation limits the number of GameServers on one node
+	// updated in place at the same time, no limit if not set.
+	GameServerInPlaceUpdateMaxPerNodeAnnotation = "carrier.ocgi.dev/inplace-update-max-per-node"
 	// GameServerInPlaceUpdatedReplicasAnnotation describes in place updated game server number
 	GameServerInPlaceUpdatedReplicasAnnotation = "carrier.ocgi.dev/inplace-updated-replicas"
+	// GameServerInPlaceUpdateDiffAnnotation records the changes of the last in place update,
+	// e.g. "image: server:v1 -> server:v2"
+	GameServerInPlaceUpdateDiffAnnotation = "carrier.ocgi.dev/inplace-update-diff"
 	// GameServerInPlaceUpdatingAnnotation describes in place updateing is doning("true", false)
 	GameServerInPlaceUpdatingAnnotation = "carrier.ocgi.dev/inplace-updating"
+	// GameServerSkipUpdateAnnotation excludes a GameServer from in place updates and scale down
+	// if the value is "true", e.g. the GameServer is hosting an important match.
+	GameServerSkipUpdateAnnotation = "carrier.ocgi.dev/skip-update"
 	// GameServerDynamicPortAllocated port allocated for dynamic policy.
 	GameServerDynamicPortAllocated = "carrier.ocgi.dev/dynamic-port-allocated"
+	// GameServerSetOOMKilledAnnotation is the number of OOMKilled GameServers of a GameServerSet
+	// observed since the last OOM remediation.
+	GameServerSetOOMKilledAnnotation = "carrier.ocgi.dev/oom-killed"
+	// GameServerSetMemoryLimitAnnotation is the memory limit of GameServer container bumped by
+	// OOMPolicy, which overrides the limit in template for new GameServers.
+	GameServerSetMemoryLimitAnnotation = "carrier.ocgi.dev/memory-limit"
+	// GameServerSetFaultyTemplateAnnotation is the template hash of GameServerSet marked as faulty by OOMPolicy.
+	GameServerSetFaultyTemplateAnnotation = "carrier.ocgi.dev/faulty-template"
+	// GameServerSDKPortsAnnotation records the SDK gRPC and HTTP ports allocated for
+	// GameServer in hostNetwork mode, e.g. "9200,9201".
+	GameServerSDKPortsAnnotation = "carrier.ocgi.dev/sdk-ports"
+	// SDKGRPCPortEnv is the env exporting the gRPC port of SDK server to containers.
+	SDKGRPCPortEnv = "CARRIER_SDK_GRPC_PORT"
+	// SDKHTTPPortEnv is the env exporting the HTTP port of SDK server to containers.
+	SDKHTTPPortEnv = "CARRIER_SDK_HTTP_PORT"
+	// GameServerIndexAnnotation is the index of GameServer in its GameServerSet, the lowest index
+	// not used by other GameServers of the GameServerSet is assigned on creation.
+	GameServerIndexAnnotation = "carrier.ocgi.dev/index"
+	// AssetContainerName is the name of the init container downloading assets of GameServer.
+	AssetContainerName = "carrier-asset"
+	// AssetLockContainerName is the name of the init container acquiring the node-level lease
+	// before the asset container runs.
+	AssetLockContainerName = "carrier-asset-lock"
+	// AssetCacheVolumeName is the name of the volume caching assets of GameServer.
+	AssetCacheVolumeName = "carrier-asset-cache"
+	// TLSVolumeName is the name of the volume of GameServer certificate.
+	TLSVolumeName = "carrier-tls"
+	// AssetDirEnv is the env exporting the directory of assets to containers.
+	AssetDirEnv = "CARRIER_ASSET_DIR"
+	// AssetLeaseEnv is the env exporting the name of the node-level lease deduping
+	// downloads of assets to the asset lock and asset containers.
+	AssetLeaseEnv = "CARRIER_ASSET_LEASE"
+	// NodeNameEnv is the env exporting the node name to the asset container.
+	NodeNameEnv = "CARRIER_NODE_NAME"
+	// PodNameEnv is the env exporting the pod name to the asset container.
+	PodNameEnv = "CARRIER_POD_NAME"
+	// PodNamespaceEnv is the env exporting the pod namespace to the asset lock container.
+	PodNamespaceEnv = "CARRIER_POD_NAMESPACE"
+	// MatchIDAnnotation is the id of match GameServer serves, set by matchmakers, it is
+	// copied to the pod, so that logs of GameServer can be labeled by it.
+	MatchIDAnnotation = "carrier.ocgi.dev/match-id"
+	// LogShipperContainerName is the name of the sidecar shipping logs of GameServer.
+	LogShipperContainerName = "carrier-log-shipper"
+	// LogVolumeName is the name of the volume of GameServer logs.
+	LogVolumeName = "carrier-logs"
+	// LogLabelsVolumeName is the name of the volume of GameServer log labels.
+	LogLabelsVolumeName = "carrier-log-labels"
+	// LogShipperConfigVolumeName is the name of the volume of log shipper config.
+	LogShipperConfigVolumeName = "carrier-log-shipper-config"
+	// LogDirEnv is the env exporting the directory of logs to containers.
+	LogDirEnv = "CARRIER_LOG_DIR"
+	// DumpVolumeName is the name of the volume of GameServer crash dumps.
+	DumpVolumeName = "carrier-dumps"
+	// DumpDirEnv is the env exporting the directory of crash dumps to containers.
+	DumpDirEnv = "CARRIER_DUMP_DIR"
+	// ArtifactURLEnv is the env exporting the URL crash dumps are uploaded to the post-mortem Job.
+	ArtifactURLEnv = "CARRIER_ARTIFACT_URL"
+	// ChaosReadinessGate is the readiness gate added by chaos to delay the readiness of GameServer.
+	ChaosReadinessGate = "carrier.ocgi.dev/chaos"
+	// ChaosReadinessReleaseAnnotation is the time the chaos readiness gate of GameServer is passed.
+	ChaosReadinessReleaseAnnotation = "carrier.ocgi.dev/chaos-readiness-release"
+	// TemplateProvenanceAnnotation is the JSON map from the fields of Squad template to the
+	// FleetProfile they are merged from. Fields not in the map are set by the Squad.
+	TemplateProvenanceAnnotation = "carrier.ocgi.dev/template-provenance"
+	// NodeDrainRankAnnotation is the rank of the node of GameServer among the nodes chosen to be
+	// emptied by the consolidation controller, GameServers of lower rank are scaled down first.
+	NodeDrainRankAnnotation = "carrier.ocgi.dev/node-drain-rank"
+	// NodeCordonedByAnnotation is the carrier controller and object which cordoned the node, so the
+	// node is only uncordoned by the one cordoning it and nodes cordoned by operators are left alone.
+	NodeCordonedByAnnotation = "carrier.ocgi.dev/cordoned-by"
+	// NodeCordonedAtAnnotation is the time in RFC3339 the node is cordoned by the carrier controller
+	// in the cordoned-by annotation, so nodes cordoned too long could be uncordoned.
+	NodeCordonedAtAnnotation = "carrier.ocgi.dev/cordoned-at"
+	// NodeFreeGameServerSlotsAnnotation is the number of GameServers more fitting in the allocatable
+	// of the node, read by cluster autoscaler expanders to fill existing game nodes before adding new.
+	NodeFreeGameServerSlotsAnnotation = "carrier.ocgi.dev/free-gameserver-slots"
+	// NodeFreePlayerSlotsAnnotation is the free player slots of the ready GameServers on the node
+	// accepting new sessions.
+	NodeFreePlayerSlotsAnnotation = "carrier.ocgi.dev/free-player-slots"
+	// ConnectionSecretAnnotation is the Secret the connection info of GameServer is published into,
+	// set by the matchmaker allocating the GameServer for backends which can not watch GameServers.
+	ConnectionSecretAnnotation = "carrier.ocgi.dev/connection-secret"
+	// InheritedMetadataAnnotation records the keys of labels and annotations GameServer inherits
+	// from the gameServerMetadata of its GameServerSet, so keys removed there are removed too.
+	InheritedMetadataAnnotation = "carrier.ocgi.dev/inherited-metadata"
+	// PinnedImagesAnnotation is the JSON map from the containers of Squad template to the
+	// images referenced by tag their digests are resolved from at admission.
+	PinnedImagesAnnotation = "carrier.ocgi.dev/pinned-images"
+	// ControllerVersionAnnotation is the version of the controller which created the GameServer,
+	// or computed the template hash of the GameServerSet.
+	ControllerVersionAnnotation = "carrier.ocgi.dev/controller-version"
+	// PublishedEventsAnnotation records the lifecycle events published for the GameServer by the
+	// event bus, so events not accepted by the targets yet are published again by the next leader.
+	PublishedEventsAnnotation = "carrier.ocgi.dev/published-events"
+	// SquadUnfulfilledAllocationsAnnotation is the JSON map from controller replicas to the
+	// unfulfilled allocations per minute of the Squad they tracked, so every replica reports
+	// the misses of the queries served by all replicas.
+	SquadUnfulfilledAllocationsAnnotation = "carrier.ocgi.dev/unfulfilled-allocations"
 )
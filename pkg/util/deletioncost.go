@@ -0,0 +1,63 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultDeletionCost is the implicit deletion cost of game servers without
+// the deletion cost annotation. It is reserved and can not be set explicitly,
+// otherwise a game server could not be told apart from one without the annotation.
+const DefaultDeletionCost = int64(math.MaxInt64)
+
+// ParseDeletionCost parses the value of deletion cost annotation. Only the
+// canonical form of an int64 is accepted, values start with plus sign
+// (e.g, "+10") or leading zeros (e.g., "008") are not valid.
+func ParseDeletionCost(value string) (int64, error) {
+	cost, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return DefaultDeletionCost, fmt.Errorf("invalid deletion cost %q, must be an int64", value)
+	}
+	if strconv.FormatInt(cost, 10) != value {
+		return DefaultDeletionCost, fmt.Errorf("invalid deletion cost %q, must be in canonical form %q",
+			value, strconv.FormatInt(cost, 10))
+	}
+	return cost, nil
+}
+
+// ValidateDeletionCost checks value is a valid deletion cost which can be set explicitly.
+func ValidateDeletionCost(value string) error {
+	cost, err := ParseDeletionCost(value)
+	if err != nil {
+		return err
+	}
+	if cost == DefaultDeletionCost {
+		return fmt.Errorf("deletion cost %v is reserved for game servers without %v", cost, GameServerDeletionCost)
+	}
+	return nil
+}
+
+// NormalizeDeletionCost returns the canonical form of value, e.g, " +008" is normalized to "8".
+func NormalizeDeletionCost(value string) (string, error) {
+	cost, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return value, fmt.Errorf("invalid deletion cost %q, must be an int64", value)
+	}
+	return strconv.FormatInt(cost, 10), nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hash
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+const (
+	defaultTerminationGracePeriodSeconds = int64(corev1.DefaultTerminationGracePeriodSeconds)
+	defaultSchedulerName                 = corev1.DefaultSchedulerName
+	defaultTerminationMessagePath        = corev1.TerminationMessagePathDefault
+)
+
+// ComputeHash returns the hash of obj, which is safe to be used in names and labels.
+func ComputeHash(obj interface{}) string {
+	hasher := fnv.New32a()
+	DeepHashObject(hasher, obj)
+	return rand.SafeEncodeString(fmt.Sprint(hasher.Sum32()))
+}
+
+// GameServerTemplateHash returns the semantic hash of a GameServer template.
+// Labels managed by carrier, metadata other than labels and annotations, and
+// fields equal to their defaults are ignored, so no-op defaulting differences
+// between client versions do not change the hash.
+func GameServerTemplateHash(template *carrierv1alpha1.GameServerTemplateSpec) string {
+	return ComputeHash(*NormalizeGameServerTemplate(template))
+}
+
+// PodSpecHash returns the semantic hash of a pod spec, fields equal to their defaults are ignored.
+func PodSpecHash(spec *corev1.PodSpec) string {
+	return ComputeHash(*NormalizePodSpec(spec))
+}
+
+// GameServerSpecHash returns the semantic hash of a GameServer spec. Constraints and the chaos
+// readiness gate, which are changed on GameServers at runtime, are ignored as well.
+func GameServerSpecHash(spec *carrierv1alpha1.GameServerSpec) string {
+	normalized := NormalizeGameServerTemplate(&carrierv1alpha1.GameServerTemplateSpec{Spec: *spec}).Spec
+	normalized.Constraints = nil
+	var gates []string
+	for _, gate := range normalized.ReadinessGates {
+		if gate != util.ChaosReadinessGate {
+			gates = append(gates, gate)
+		}
+	}
+	normalized.ReadinessGates = gates
+	return ComputeHash(normalized)
+}
+
+// EqualGameServerTemplate returns true if two GameServer templates have the same semantic hash input.
+func EqualGameServerTemplate(template1, template2 *carrierv1alpha1.GameServerTemplateSpec) bool {
+	return apiequality.Semantic.DeepEqual(NormalizeGameServerTemplate(template1),
+		NormalizeGameServerTemplate(template2))
+}
+
+// NormalizeGameServerTemplate returns a copy of template with ignored fields cleared.
+func NormalizeGameServerTemplate(
+	template *carrierv1alpha1.GameServerTemplateSpec) *carrierv1alpha1.GameServerTemplateSpec {
+	normalized := &carrierv1alpha1.GameServerTemplateSpec{
+		ObjectMeta: normalizeObjectMeta(&template.ObjectMeta),
+		Spec:       *template.Spec.DeepCopy(),
+	}
+	spec := &normalized.Spec
+	for i := range spec.Ports {
+		if spec.Ports[i].PortPolicy == carrierv1alpha1.LoadBalancer {
+			spec.Ports[i].PortPolicy = ""
+		}
+		if spec.Ports[i].Protocol == corev1.ProtocolUDP {
+			spec.Ports[i].Protocol = ""
+		}
+	}
+	if spec.Scheduling == carrierv1alpha1.MostAllocated {
+		spec.Scheduling = ""
+	}
+	if spec.OS == carrierv1alpha1.Linux {
+		spec.OS = ""
+	}
+	if len(spec.Ports) == 0 {
+		spec.Ports = nil
+	}
+	spec.Template = corev1.PodTemplateSpec{
+		ObjectMeta: normalizeObjectMeta(&spec.Template.ObjectMeta),
+		Spec:       *NormalizePodSpec(&spec.Template.Spec),
+	}
+	return normalized
+}
+
+// NormalizePodSpec returns a copy of spec with fields equal to their defaults cleared.
+func NormalizePodSpec(spec *corev1.PodSpec) *corev1.PodSpec {
+	normalized := spec.DeepCopy()
+	if normalized.RestartPolicy == corev1.RestartPolicyAlways {
+		normalized.RestartPolicy = ""
+	}
+	if normalized.DNSPolicy == corev1.DNSClusterFirst {
+		normalized.DNSPolicy = ""
+	}
+	if normalized.SchedulerName == defaultSchedulerName {
+		normalized.SchedulerName = ""
+	}
+	if normalized.TerminationGracePeriodSeconds != nil &&
+		*normalized.TerminationGracePeriodSeconds == defaultTerminationGracePeriodSeconds {
+		normalized.TerminationGracePeriodSeconds = nil
+	}
+	if normalized.SecurityContext != nil &&
+		apiequality.Semantic.DeepEqual(*normalized.SecurityContext, corev1.PodSecurityContext{}) {
+		normalized.SecurityContext = nil
+	}
+	for i := range normalized.InitContainers {
+		normalizeContainer(&normalized.InitContainers[i])
+	}
+	for i := range normalized.Containers {
+		normalizeContainer(&normalized.Containers[i])
+	}
+	return normalized
+}
+
+// normalizeObjectMeta keeps labels and annotations only, labels managed by carrier are removed.
+func normalizeObjectMeta(meta *metav1.ObjectMeta) metav1.ObjectMeta {
+	normalized := metav1.ObjectMeta{}
+	for k, v := range meta.Labels {
+		if k == util.SquadNameLabelKey || k == util.GameServerHash {
+			continue
+		}
+		if normalized.Labels == nil {
+			normalized.Labels = make(map[string]string)
+		}
+		normalized.Labels[k] = v
+	}
+	if len(meta.Annotations) != 0 {
+		normalized.Annotations = util.Merge(meta.Annotations, nil)
+	}
+	return normalized
+}
+
+// normalizeContainer clears the fields of container equal to their defaults.
+func normalizeContainer(container *corev1.Container) {
+	if container.TerminationMessagePath == defaultTerminationMessagePath {
+		container.TerminationMessagePath = ""
+	}
+	if container.TerminationMessagePolicy == corev1.TerminationMessageReadFile {
+		container.TerminationMessagePolicy = ""
+	}
+	if container.ImagePullPolicy == defaultImagePullPolicy(container.Image) {
+		container.ImagePullPolicy = ""
+	}
+	for i := range container.Ports {
+		if container.Ports[i].Protocol == corev1.ProtocolTCP {
+			container.Ports[i].Protocol = ""
+		}
+	}
+	if len(container.Ports) == 0 {
+		container.Ports = nil
+	}
+	if len(container.Env) == 0 {
+		container.Env = nil
+	}
+	if len(container.Resources.Limits) == 0 {
+		container.Resources.Limits = nil
+	}
+	if len(container.Resources.Requests) == 0 {
+		container.Resources.Requests = nil
+	}
+}
+
+// defaultImagePullPolicy returns the pull policy defaulted by apiserver for image.
+func defaultImagePullPolicy(image string) corev1.PullPolicy {
+	if hasLatestTag(image) {
+		return corev1.PullAlways
+	}
+	return corev1.PullIfNotPresent
+}
+
+// hasLatestTag returns true if image has no tag nor digest, or the tag is latest.
+func hasLatestTag(image string) bool {
+	for i := len(image) - 1; i >= 0; i-- {
+		switch image[i] {
+		case '@':
+			return false
+		case ':':
+			return image[i+1:] == "latest"
+		case '/':
+			return true
+		}
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hash
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func newTemplate() *carrierv1alpha1.GameServerTemplateSpec {
+	return &carrierv1alpha1.GameServerTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"app": "game"},
+		},
+		Spec: carrierv1alpha1.GameServerSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  util.GameServerContainerName,
+							Image: "server:v1",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestGameServerTemplateHash(t *testing.T) {
+	gracePeriod := int64(30)
+	tests := []struct {
+		name    string
+		mutate  func(template *carrierv1alpha1.GameServerTemplateSpec)
+		changed bool
+	}{
+		{
+			name: "defaults set",
+			mutate: func(template *carrierv1alpha1.GameServerTemplateSpec) {
+				template.Spec.Scheduling = carrierv1alpha1.MostAllocated
+				spec := &template.Spec.Template.Spec
+				spec.RestartPolicy = corev1.RestartPolicyAlways
+				spec.DNSPolicy = corev1.DNSClusterFirst
+				spec.SchedulerName = corev1.DefaultSchedulerName
+				spec.TerminationGracePeriodSeconds = &gracePeriod
+				spec.SecurityContext = &corev1.PodSecurityContext{}
+				spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+				spec.Containers[0].TerminationMessagePath = corev1.TerminationMessagePathDefault
+				spec.Containers[0].TerminationMessagePolicy = corev1.TerminationMessageReadFile
+				spec.Containers[0].Env = []corev1.EnvVar{}
+			},
+		},
+		{
+			name: "labels managed by carrier",
+			mutate: func(template *carrierv1alpha1.GameServerTemplateSpec) {
+				template.Labels[util.GameServerHash] = "abc"
+				template.Labels[util.SquadNameLabelKey] = "squad"
+				template.CreationTimestamp = metav1.Now()
+			},
+		},
+		{
+			name: "image changed",
+			mutate: func(template *carrierv1alpha1.GameServerTemplateSpec) {
+				template.Spec.Template.Spec.Containers[0].Image = "server:v2"
+			},
+			changed: true,
+		},
+		{
+			name: "pull policy not default",
+			mutate: func(template *carrierv1alpha1.GameServerTemplateSpec) {
+				template.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullAlways
+			},
+			changed: true,
+		},
+		{
+			name: "label changed",
+			mutate: func(template *carrierv1alpha1.GameServerTemplateSpec) {
+				template.Labels["app"] = "other"
+			},
+			changed: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			template := newTemplate()
+			mutated := newTemplate()
+			tc.mutate(mutated)
+			changed := GameServerTemplateHash(template) != GameServerTemplateHash(mutated)
+			if changed != tc.changed {
+				t.Errorf("desired hash changed: %v, get: %v", tc.changed, changed)
+			}
+			if equal := EqualGameServerTemplate(template, mutated); equal == tc.changed {
+				t.Errorf("desired templates equal: %v, get: %v", !tc.changed, equal)
+			}
+		})
+	}
+}
+
+func TestHasLatestTag(t *testing.T) {
+	tests := map[string]bool{
+		"server":                   true,
+		"server:latest":            true,
+		"server:v1":                false,
+		"registry:5000/server":     true,
+		"registry:5000/server:v1":  false,
+		"server@sha256:0123456789": false,
+	}
+	for image, desired := range tests {
+		if get := hasLatestTag(image); get != desired {
+			t.Errorf("image %v, desired latest: %v, get: %v", image, desired, get)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// CordonNode marks node unschedulable on behalf of owner, which is recorded in the cordoned-by
+// annotation. Nodes already unschedulable are left alone, as someone else cordoned them.
+func CordonNode(client kubernetes.Interface, node *corev1.Node, owner string) error {
+	if node.Spec.Unschedulable {
+		return nil
+	}
+	return patchSchedulable(client, node, true, owner)
+}
+
+// UncordonNode marks node schedulable again if it is cordoned by owner.
+func UncordonNode(client kubernetes.Interface, node *corev1.Node, owner string) error {
+	if CordonedBy(node) != owner {
+		return nil
+	}
+	return patchSchedulable(client, node, false, "")
+}
+
+// CordonedBy returns the owner which cordoned node, empty if it is not cordoned by carrier.
+func CordonedBy(node *corev1.Node) string {
+	if !node.Spec.Unschedulable {
+		return ""
+	}
+	return node.Annotations[util.NodeCordonedByAnnotation]
+}
+
+// CordonedAt returns when node is cordoned by the owner it is cordoned by, false if it is not
+// cordoned by carrier or the time is not recorded.
+func CordonedAt(node *corev1.Node) (time.Time, bool) {
+	if len(CordonedBy(node)) == 0 {
+		return time.Time{}, false
+	}
+	cordonedAt, err := time.Parse(time.RFC3339, node.Annotations[util.NodeCordonedAtAnnotation])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return cordonedAt, true
+}
+
+// patchSchedulable sets unschedulable of node, and the cordoned-by annotation to owner along
+// with the cordoned-at annotation to now, the annotations are removed if owner is empty.
+func patchSchedulable(client kubernetes.Interface, node *corev1.Node, unschedulable bool, owner string) error {
+	var annotation, cordonedAt interface{}
+	if len(owner) != 0 {
+		annotation = owner
+		cordonedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				util.NodeCordonedByAnnotation: annotation,
+				util.NodeCordonedAtAnnotation: cordonedAt,
+			},
+		},
+		"spec": map[string]interface{}{"unschedulable": unschedulable},
+	})
+	if err != nil {
+		return err
+	}
+	klog.V(4).Infof("Patch node %v: %s", node.Name, patch)
+	if _, err = client.CoreV1().Nodes().Patch(node.Name, types.MergePatchType, patch); err != nil {
+		return errors.Wrapf(err, "error patching schedulable of node %v", node.Name)
+	}
+	return nil
+}
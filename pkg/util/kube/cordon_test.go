@@ -0,0 +1,62 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCordonNode(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+	client := fake.NewSimpleClientset(node)
+	if err := CordonNode(client, node, "NodeMaintenance/foo"); err != nil {
+		t.Fatal(err)
+	}
+	node, _ = client.CoreV1().Nodes().Get("node", metav1.GetOptions{})
+	if CordonedBy(node) != "NodeMaintenance/foo" {
+		t.Fatalf("desired node cordoned by NodeMaintenance/foo, get: %+v", node)
+	}
+	if cordonedAt, ok := CordonedAt(node); !ok || time.Since(cordonedAt) > time.Minute {
+		t.Errorf("desired node cordoned just now, get: %v %v", cordonedAt, ok)
+	}
+	if err := UncordonNode(client, node, "NodeMaintenance/bar"); err != nil {
+		t.Fatal(err)
+	}
+	node, _ = client.CoreV1().Nodes().Get("node", metav1.GetOptions{})
+	if !node.Spec.Unschedulable {
+		t.Fatalf("desired node kept cordoned by others")
+	}
+	if err := UncordonNode(client, node, "NodeMaintenance/foo"); err != nil {
+		t.Fatal(err)
+	}
+	node, _ = client.CoreV1().Nodes().Get("node", metav1.GetOptions{})
+	if node.Spec.Unschedulable || len(node.Annotations) != 0 {
+		t.Errorf("desired node uncordoned without annotation, get: %+v", node)
+	}
+
+	// nodes cordoned by operators are never taken over.
+	node.Spec.Unschedulable = true
+	if err := CordonNode(client, node, "NodeMaintenance/foo"); err != nil {
+		t.Fatal(err)
+	}
+	if CordonedBy(node) != "" {
+		t.Errorf("desired node cordoned by operator left alone")
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+)
+
+// EventDeduper records events of a reason on an object only when the message changes, so a
+// warning persisting across syncs is recorded once instead of on every sync.
+type EventDeduper struct {
+	sync.Mutex
+	// last is the last message recorded by the uid of object and the reason.
+	last map[types.UID]map[string]string
+}
+
+// NewEventDeduper returns an empty EventDeduper.
+func NewEventDeduper() *EventDeduper {
+	return &EventDeduper{last: make(map[types.UID]map[string]string)}
+}
+
+// Eventf records the event on object by recorder, unless the same message of reason was the
+// last one recorded on object.
+func (d *EventDeduper) Eventf(recorder record.EventRecorder, object runtime.Object, eventtype, reason,
+	messageFmt string, args ...interface{}) {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		klog.Errorf("Failed to dedupe event %v: %v", reason, err)
+		recorder.Eventf(object, eventtype, reason, messageFmt, args...)
+		return
+	}
+	message := fmt.Sprintf(messageFmt, args...)
+	d.Lock()
+	reasons, ok := d.last[accessor.GetUID()]
+	if !ok {
+		reasons = make(map[string]string)
+		d.last[accessor.GetUID()] = reasons
+	}
+	if last, ok := reasons[reason]; ok && last == message {
+		d.Unlock()
+		return
+	}
+	reasons[reason] = message
+	d.Unlock()
+	recorder.Event(object, eventtype, reason, message)
+}
+
+// Resolve forgets the event of reason on the object of uid, so it is recorded again if it recurs.
+func (d *EventDeduper) Resolve(uid types.UID, reason string) {
+	d.Lock()
+	defer d.Unlock()
+	reasons, ok := d.last[uid]
+	if !ok {
+		return
+	}
+	delete(reasons, reason)
+	if len(reasons) == 0 {
+		delete(d.last, uid)
+	}
+}
+
+// Forget forgets all events on the object of uid, it should be called once the object is deleted.
+func (d *EventDeduper) Forget(uid types.UID) {
+	d.Lock()
+	defer d.Unlock()
+	delete(d.last, uid)
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEventDeduper(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	d := NewEventDeduper()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", UID: "uid"}}
+	d.Eventf(recorder, pod, corev1.EventTypeWarning, "Conflict", "secret %v exists", "a")
+	d.Eventf(recorder, pod, corev1.EventTypeWarning, "Conflict", "secret %v exists", "a")
+	d.Eventf(recorder, pod, corev1.EventTypeWarning, "Invalid", "invalid")
+	d.Eventf(recorder, pod, corev1.EventTypeWarning, "Conflict", "secret %v exists", "b")
+	d.Resolve(pod.UID, "Conflict")
+	d.Eventf(recorder, pod, corev1.EventTypeWarning, "Conflict", "secret %v exists", "b")
+	d.Forget(pod.UID)
+	d.Eventf(recorder, pod, corev1.EventTypeWarning, "Invalid", "invalid")
+	close(recorder.Events)
+	var events []string
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+	expected := []string{
+		"Warning Conflict secret a exists",
+		"Warning Invalid invalid",
+		"Warning Conflict secret b exists",
+		"Warning Conflict secret b exists",
+		"Warning Invalid invalid",
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("desired events %v, get: %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("desired event %v, get: %v", expected[i], events[i])
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// TransformFunc mutates objects before they are stored in the informer cache.
+type TransformFunc func(obj runtime.Object)
+
+// StripManagedFields drops the managedFields of obj, which are never read by controllers
+// but take a large part of the memory of cached objects. Updates sent from stripped objects
+// keep the managedFields on the server, since unset managedFields are ignored by the apiserver.
+func StripManagedFields(obj runtime.Object) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	accessor.SetManagedFields(nil)
+}
+
+// transformingListWatch applies transform to the objects listed and watched.
+type transformingListWatch struct {
+	lw        cache.ListerWatcher
+	transform TransformFunc
+}
+
+// NewTransformingListWatch returns a ListerWatcher applying transform to all objects
+// returned by lw, so informers using it only cache the transformed objects.
+func NewTransformingListWatch(lw cache.ListerWatcher, transform TransformFunc) cache.ListerWatcher {
+	return &transformingListWatch{lw: lw, transform: transform}
+}
+
+// List lists objects from lw and transforms them.
+func (t *transformingListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	list, err := t.lw.List(options)
+	if err != nil {
+		return nil, err
+	}
+	err = meta.EachListItem(list, func(obj runtime.Object) error {
+		t.transform(obj)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Watch watches objects from lw and transforms the objects of events.
+func (t *transformingListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w, err := t.lw.Watch(options)
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+		if in.Type != watch.Error && in.Object != nil {
+			t.transform(in.Object)
+		}
+		return in, true
+	}), nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newManagedPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:          name,
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubelet", Operation: metav1.ManagedFieldsOperationUpdate}},
+		},
+	}
+}
+
+func TestTransformingListWatch(t *testing.T) {
+	fakeWatch := watch.NewFake()
+	lw := NewTransformingListWatch(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &corev1.PodList{Items: []corev1.Pod{*newManagedPod("a"), *newManagedPod("b")}}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return fakeWatch, nil
+		},
+	}, StripManagedFields)
+
+	list, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pod := range list.(*corev1.PodList).Items {
+		if pod.ManagedFields != nil {
+			t.Errorf("desired managedFields of listed pod %v stripped, get: %v", pod.Name, pod.ManagedFields)
+		}
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	go fakeWatch.Add(newManagedPod("c"))
+	event := <-w.ResultChan()
+	pod := event.Object.(*corev1.Pod)
+	if pod.Name != "c" || pod.ManagedFields != nil {
+		t.Errorf("desired managedFields of watched pod stripped, get: %+v", pod.ObjectMeta)
+	}
+}
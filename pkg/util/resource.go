@@ -0,0 +1,85 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// IsExtendedResourceName returns true for resources which are not native cpu, memory or
+// storage, e.g. nvidia.com/gpu and hugepages-2Mi. Hugepages are regarded as extended
+// resources, because nodes hosting them are as scarce as GPU nodes.
+func IsExtendedResourceName(name corev1.ResourceName) bool {
+	if strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix) {
+		return true
+	}
+	if strings.HasPrefix(string(name), corev1.DefaultResourceRequestsPrefix) {
+		return false
+	}
+	if !strings.Contains(string(name), "/") {
+		return false
+	}
+	return !strings.Contains(string(name), corev1.ResourceDefaultNamespacePrefix)
+}
+
+// ExtendedResourceRequests returns the extended resources requested by containers of podSpec.
+// Limits are used if requests are not set, which are the same for extended resources.
+func ExtendedResourceRequests(podSpec *corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range podSpec.Containers {
+		list := container.Resources.Requests
+		for name, quantity := range container.Resources.Limits {
+			if _, ok := list[name]; !ok && IsExtendedResourceName(name) {
+				addResource(requests, name, quantity)
+			}
+		}
+		for name, quantity := range list {
+			if IsExtendedResourceName(name) {
+				addResource(requests, name, quantity)
+			}
+		}
+	}
+	return requests
+}
+
+// ResourceRequests returns all resources requested by containers of podSpec. Limits are used if
+// requests are not set, as requests default to limits.
+func ResourceRequests(podSpec *corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range podSpec.Containers {
+		list := container.Resources.Requests
+		for name, quantity := range container.Resources.Limits {
+			if _, ok := list[name]; !ok {
+				addResource(requests, name, quantity)
+			}
+		}
+		for name, quantity := range list {
+			addResource(requests, name, quantity)
+		}
+	}
+	return requests
+}
+
+func addResource(list corev1.ResourceList, name corev1.ResourceName, quantity resource.Quantity) {
+	if current, ok := list[name]; ok {
+		current.Add(quantity)
+		list[name] = current
+		return
+	}
+	list[name] = quantity.DeepCopy()
+}
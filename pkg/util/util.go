@@ -14,6 +14,11 @@
 
 package util
 
+import (
+	"path"
+	"strings"
+)
+
 // Merge helps merge labels or annotations
 func Merge(one, two map[string]string) map[string]string {
 	three := make(map[string]string)
@@ -25,3 +30,26 @@ func Merge(one, two map[string]string) map[string]string {
 	}
 	return three
 }
+
+// HasDNSSuffix checks if name is one of the domains or a subdomain of them.
+func HasDNSSuffix(name string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(domain, ".")
+		if len(domain) == 0 {
+			continue
+		}
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSubPath checks if the absolute path p is root or under root, after cleaned.
+func IsSubPath(root, p string) bool {
+	if len(root) == 0 || !path.IsAbs(p) {
+		return false
+	}
+	root, p = path.Clean(root), path.Clean(p)
+	return p == root || strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/")
+}
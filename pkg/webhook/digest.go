@@ -0,0 +1,390 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// defaultRegistry is the registry of images without registry host.
+	defaultRegistry = "docker.io"
+	// defaultRegistryHost is the API endpoint of defaultRegistry.
+	defaultRegistryHost = "registry-1.docker.io"
+	// defaultRegistryRealm is the token server of defaultRegistry.
+	defaultRegistryRealm = "auth.docker.io"
+	// defaultTag is the tag of images without tag or digest.
+	defaultTag = "latest"
+)
+
+// manifestMediaTypes are the manifest types accepted from registries. Lists and indexes are
+// preferred, so the digest of multi-arch images stays valid on nodes of any architecture.
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+// ImageResolver resolves the tag of images into digest.
+type ImageResolver interface {
+	// Resolve returns image referenced by digest, images already referenced by digest
+	// are returned as is. Private registries are accessed with the credentials in keyring.
+	Resolve(ctx context.Context, image string, keyring Keyring) (string, error)
+}
+
+// registryAuth is the credential of a registry in docker config.
+type registryAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+// basic returns the username and password of a, decoded from the auth field if they are not set.
+func (a registryAuth) basic() (string, string, bool) {
+	if len(a.Username) != 0 || len(a.Password) != 0 {
+		return a.Username, a.Password, true
+	}
+	data, err := base64.StdEncoding.DecodeString(a.Auth)
+	if err != nil {
+		return "", "", false
+	}
+	parts := strings.SplitN(string(data), ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// Keyring is the credentials of registries by registry host, e.g. docker.io.
+type Keyring map[string]registryAuth
+
+// KeyringFromSecrets returns the credentials in image pull secrets, malformed secrets are skipped.
+func KeyringFromSecrets(secrets []*corev1.Secret) Keyring {
+	keyring := make(Keyring)
+	for _, secret := range secrets {
+		var auths map[string]registryAuth
+		switch secret.Type {
+		case corev1.SecretTypeDockerConfigJson:
+			var config struct {
+				Auths map[string]registryAuth `json:"auths"`
+			}
+			if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config); err != nil {
+				continue
+			}
+			auths = config.Auths
+		case corev1.SecretTypeDockercfg:
+			if err := json.Unmarshal(secret.Data[corev1.DockerConfigKey], &auths); err != nil {
+				continue
+			}
+		}
+		for server, auth := range auths {
+			host := registryHostOf(server)
+			if _, ok := keyring[host]; !ok {
+				keyring[host] = auth
+			}
+		}
+	}
+	return keyring
+}
+
+// registryHostOf returns the registry host of a server in docker config, which may be an URL.
+func registryHostOf(server string) string {
+	host := server
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	switch host {
+	case "index.docker.io", defaultRegistryHost:
+		return defaultRegistry
+	}
+	return host
+}
+
+// RegistryResolver resolves image tags by looking up the manifests in the registry through
+// the Docker Registry HTTP API V2. Bearer tokens are only requested from the registry itself
+// or the allowed token servers, so images can not make the webhook send requests elsewhere.
+type RegistryResolver struct {
+	client *http.Client
+	// realmHosts are the token servers allowed besides the registries.
+	realmHosts []string
+}
+
+// NewRegistryResolver returns a RegistryResolver sending requests by client, tokens are
+// requested from registries themselves, docker hub's token server or realmHosts.
+func NewRegistryResolver(client *http.Client, realmHosts []string) *RegistryResolver {
+	return &RegistryResolver{client: client, realmHosts: realmHosts}
+}
+
+// Resolve resolves the tag of image into digest.
+func (r *RegistryResolver) Resolve(ctx context.Context, image string, keyring Keyring) (string, error) {
+	ref, err := parseImageReference(image)
+	if err != nil {
+		return "", err
+	}
+	if len(ref.digest) != 0 {
+		return image, nil
+	}
+	host := ref.registry
+	if host == defaultRegistry {
+		host = defaultRegistryHost
+	}
+	auth, hasAuth := keyring[ref.registry]
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, ref.repository, ref.tag)
+	resp, err := r.headManifest(ctx, manifestURL, "")
+	if err != nil {
+		return "", errors.Wrapf(err, "look up image %s", image)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		var authorization string
+		switch {
+		case strings.HasPrefix(challenge, "Basic ") && hasAuth:
+			username, password, ok := auth.basic()
+			if !ok {
+				return "", errors.Errorf("look up image %s: invalid credential of %s", image, ref.registry)
+			}
+			authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+		default:
+			token, err := r.token(ctx, host, challenge, auth, hasAuth)
+			if err != nil {
+				return "", errors.Wrapf(err, "get token of image %s", image)
+			}
+			authorization = "Bearer " + token
+		}
+		if resp, err = r.headManifest(ctx, manifestURL, authorization); err != nil {
+			return "", errors.Wrapf(err, "look up image %s", image)
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("look up image %s: registry returns %s", image, resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if len(digest) == 0 {
+		return "", errors.Errorf("look up image %s: registry returns no digest", image)
+	}
+	return ref.name + "@" + digest, nil
+}
+
+// headManifest requests the head of manifest, with authorization if it is not empty.
+func (r *RegistryResolver) headManifest(ctx context.Context, manifestURL,
+	authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if len(authorization) != 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// allowedRealm returns if tokens of registry host could be requested from realm.
+func (r *RegistryResolver) allowedRealm(host string, realm *url.URL) bool {
+	if realm.Scheme != "https" {
+		return false
+	}
+	if realm.Host == host || (host == defaultRegistryHost && realm.Host == defaultRegistryRealm) {
+		return true
+	}
+	for _, allowed := range r.realmHosts {
+		if realm.Host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// token requests a token of registry host from the realm in the bearer challenge, with the
+// credential auth if hasAuth, or anonymously.
+func (r *RegistryResolver) token(ctx context.Context, host, challenge string, auth registryAuth,
+	hasAuth bool) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", errors.Errorf("unsupported challenge %q", challenge)
+	}
+	params := parseChallengeParams(strings.TrimPrefix(challenge, "Bearer "))
+	realm, err := url.Parse(params["realm"])
+	if err != nil || len(realm.Host) == 0 {
+		return "", errors.Errorf("invalid realm in challenge %q", challenge)
+	}
+	if !r.allowedRealm(host, realm) {
+		return "", errors.Errorf("realm %s of registry %s is not allowed", realm.Host, host)
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if value, ok := params[key]; ok {
+			query.Set(key, value)
+		}
+	}
+	realm.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasAuth {
+		username, password, ok := auth.basic()
+		if !ok {
+			return "", errors.Errorf("invalid credential of registry %s", host)
+		}
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("token server returns %s", resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if len(body.Token) != 0 {
+		return body.Token, nil
+	}
+	if len(body.AccessToken) != 0 {
+		return body.AccessToken, nil
+	}
+	return "", errors.New("token server returns no token")
+}
+
+// parseChallengeParams parses the comma separated key="value" params of a challenge,
+// values may contain commas if quoted.
+func parseChallengeParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) != 0 {
+		s = strings.TrimLeft(s, ", ")
+		i := strings.Index(s, "=")
+		if i < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:i])
+		s = s[i+1:]
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.Index(s, ",")
+			if end < 0 {
+				value, s = s, ""
+			} else {
+				value, s = s[:end], s[end:]
+			}
+		}
+		params[key] = value
+	}
+	return params
+}
+
+// imageReference is a parsed image reference.
+type imageReference struct {
+	// name is the image without tag or digest, as written in the reference.
+	name       string
+	registry   string
+	repository string
+	tag        string
+	digest     string
+}
+
+// parseImageReference parses image in the form of [registry/]repository[:tag][@digest].
+func parseImageReference(image string) (*imageReference, error) {
+	ref := &imageReference{name: image, tag: defaultTag}
+	if i := strings.Index(ref.name, "@"); i >= 0 {
+		ref.name, ref.digest = ref.name[:i], ref.name[i+1:]
+	}
+	if i := strings.LastIndex(ref.name, ":"); i > strings.LastIndex(ref.name, "/") {
+		ref.name, ref.tag = ref.name[:i], ref.name[i+1:]
+	}
+	if len(ref.name) == 0 || len(ref.tag) == 0 {
+		return nil, errors.Errorf("invalid image %q", image)
+	}
+	ref.registry, ref.repository = defaultRegistry, ref.name
+	if parts := strings.SplitN(ref.name, "/", 2); len(parts) == 2 &&
+		(strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.registry, ref.repository = parts[0], parts[1]
+	}
+	if ref.registry == defaultRegistry && !strings.Contains(ref.repository, "/") {
+		ref.repository = "library/" + ref.repository
+	}
+	return ref, nil
+}
+
+// PinImageDigests replaces the images of containers in spec referenced by tag with the
+// digest resolved by resolver within ctx, so all GameServers of a Squad run the same build
+// even if the tag is pushed again. Private registries are accessed with the credentials in
+// keyring. It returns the map from the containers to the images they were pinned from,
+// containers already pinned keep their entries in previous if the repository is unchanged.
+func PinImageDigests(ctx context.Context, resolver ImageResolver, spec *corev1.PodSpec,
+	previous map[string]string, keyring Keyring) (map[string]string, error) {
+	pinned := make(map[string]string)
+	// images shared by containers are looked up once.
+	resolved := make(map[string]string)
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			container := &containers[i]
+			ref, err := parseImageReference(container.Image)
+			if err != nil {
+				return nil, err
+			}
+			if len(ref.digest) != 0 {
+				// keep the entry if the container is still pinned from the same repository.
+				image, ok := previous[container.Name]
+				if ok {
+					if from, err := parseImageReference(image); err == nil && from.name == ref.name {
+						pinned[container.Name] = image
+					}
+				}
+				continue
+			}
+			image, ok := resolved[container.Image]
+			if !ok {
+				if image, err = resolver.Resolve(ctx, container.Image, keyring); err != nil {
+					return nil, err
+				}
+				resolved[container.Image] = image
+			}
+			pinned[container.Name] = container.Image
+			container.Image = image
+		}
+	}
+	return pinned, nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseImageReference(t *testing.T) {
+	for _, tc := range []struct {
+		image    string
+		expected imageReference
+	}{
+		{
+			image:    "nginx",
+			expected: imageReference{name: "nginx", registry: "docker.io", repository: "library/nginx", tag: "latest"},
+		},
+		{
+			image:    "ocgi/game:v1",
+			expected: imageReference{name: "ocgi/game", registry: "docker.io", repository: "ocgi/game", tag: "v1"},
+		},
+		{
+			image: "localhost:5000/game:v1",
+			expected: imageReference{name: "localhost:5000/game", registry: "localhost:5000",
+				repository: "game", tag: "v1"},
+		},
+		{
+			image: "ccr.ccs.tencentyun.com/ocgi/game@sha256:abc",
+			expected: imageReference{name: "ccr.ccs.tencentyun.com/ocgi/game", registry: "ccr.ccs.tencentyun.com",
+				repository: "ocgi/game", tag: "latest", digest: "sha256:abc"},
+		},
+	} {
+		ref, err := parseImageReference(tc.image)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.image, err)
+			continue
+		}
+		if !reflect.DeepEqual(*ref, tc.expected) {
+			t.Errorf("%v: desired %+v, get: %+v", tc.image, tc.expected, *ref)
+		}
+	}
+}
+
+func TestRegistryResolver(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.URL.Query().Get("scope") != "repository:game:pull" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"token":"secret"}`)
+		case "/private-token":
+			if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token":"private"}`)
+		case "/v2/private/manifests/v1":
+			if r.Header.Get("Authorization") != "Bearer private" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+					`Bearer realm="%s/private-token",service="registry",scope="repository:private:pull"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", "sha256:def")
+		case "/v2/elsewhere/manifests/v1":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="https://169.254.169.254/token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/v2/game/manifests/v1":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+					`Bearer realm="%s/token",service="registry",scope="repository:game:pull"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if !strings.Contains(r.Header.Get("Accept"), manifestMediaTypes[0]) {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", "sha256:abc")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+	resolver := NewRegistryResolver(server.Client(), nil)
+	keyring := Keyring{host: registryAuth{Username: "user", Password: "pass"}}
+
+	for _, tc := range []struct {
+		image    string
+		keyring  Keyring
+		expected string
+		err      bool
+	}{
+		{image: host + "/game:v1", expected: host + "/game@sha256:abc"},
+		{image: host + "/game@sha256:def", expected: host + "/game@sha256:def"},
+		{image: host + "/game:v2", err: true},
+		{image: host + "/private:v1", keyring: keyring, expected: host + "/private@sha256:def"},
+		{image: host + "/private:v1", err: true},
+		{image: host + "/elsewhere:v1", err: true},
+	} {
+		image, err := resolver.Resolve(context.Background(), tc.image, tc.keyring)
+		if (err != nil) != tc.err {
+			t.Errorf("%v: desired error: %v, get: %v", tc.image, tc.err, err)
+			continue
+		}
+		if image != tc.expected {
+			t.Errorf("%v: desired %v, get: %v", tc.image, tc.expected, image)
+		}
+	}
+}
+
+func TestKeyringFromSecrets(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	secrets := []*corev1.Secret{
+		{
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{` +
+				`"https://index.docker.io/v1/":{"auth":"` + auth + `"},` +
+				`"registry.example.com":{"username":"u","password":"p"}}}`)},
+		},
+		{
+			Type: corev1.SecretTypeDockercfg,
+			Data: map[string][]byte{corev1.DockerConfigKey: []byte(`{"other.example.com":{"auth":"` + auth + `"}}`)},
+		},
+		{
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(`malformed`)},
+		},
+	}
+	keyring := KeyringFromSecrets(secrets)
+	for host, expected := range map[string][2]string{
+		"docker.io":            {"user", "pass"},
+		"registry.example.com": {"u", "p"},
+		"other.example.com":    {"user", "pass"},
+	} {
+		username, password, ok := keyring[host].basic()
+		if !ok || username != expected[0] || password != expected[1] {
+			t.Errorf("%v: desired %v, get: %v, %v, %v", host, expected, username, password, ok)
+		}
+	}
+	if len(keyring) != 3 {
+		t.Errorf("desired 3 registries, get: %v", keyring)
+	}
+}
+
+type fakeResolver map[string]string
+
+func (r fakeResolver) Resolve(ctx context.Context, image string, keyring Keyring) (string, error) {
+	if digest, ok := r[image]; ok {
+		return digest, nil
+	}
+	return "", errors.Errorf("image %v not found", image)
+}
+
+func TestPinImageDigests(t *testing.T) {
+	resolver := fakeResolver{"game:v1": "game@sha256:1", "agent:v1": "agent@sha256:2"}
+	for _, tc := range []struct {
+		name       string
+		images     []string
+		previous   map[string]string
+		expected   []string
+		expectPins map[string]string
+		err        bool
+	}{
+		{
+			name:       "tags",
+			images:     []string{"game:v1", "agent:v1"},
+			expected:   []string{"game@sha256:1", "agent@sha256:2"},
+			expectPins: map[string]string{"server": "game:v1", "agent": "agent:v1"},
+		},
+		{
+			name:       "pinned before",
+			images:     []string{"game@sha256:1", "agent:v1"},
+			previous:   map[string]string{"server": "game:v1", "agent": "agent:v0"},
+			expected:   []string{"game@sha256:1", "agent@sha256:2"},
+			expectPins: map[string]string{"server": "game:v1", "agent": "agent:v1"},
+		},
+		{
+			name:       "pinned from other repository",
+			images:     []string{"other@sha256:3", "agent@sha256:2"},
+			previous:   map[string]string{"server": "game:v1"},
+			expected:   []string{"other@sha256:3", "agent@sha256:2"},
+			expectPins: map[string]string{},
+		},
+		{
+			name:   "not found",
+			images: []string{"game:v2", "agent:v1"},
+			err:    true,
+		},
+	} {
+		spec := &corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "server", Image: tc.images[0]},
+			{Name: "agent", Image: tc.images[1]},
+		}}
+		pins, err := PinImageDigests(context.Background(), resolver, spec, tc.previous, nil)
+		if (err != nil) != tc.err {
+			t.Errorf("%v: desired error: %v, get: %v", tc.name, tc.err, err)
+			continue
+		}
+		if tc.err {
+			continue
+		}
+		images := []string{spec.Containers[0].Image, spec.Containers[1].Image}
+		if !reflect.DeepEqual(images, tc.expected) {
+			t.Errorf("%v: desired images %v, get: %v", tc.name, tc.expected, images)
+		}
+		if !reflect.DeepEqual(pins, tc.expectPins) {
+			t.Errorf("%v: desired pins %v, get: %v", tc.name, tc.expectPins, pins)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	carrierv1alpha1client "github.com/ocgi/carrier/pkg/client/clientset/versioned/typed/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// pinImagesTimeout bounds the time all images of a Squad are looked up in, within the
+// timeout of admission webhooks.
+const pinImagesTimeout = 5 * time.Second
+
+// mutateSquad returns the admitFunc merging the FleetProfile referenced by a Squad into its template,
+// and recording the fields merged in the provenance annotation. Squads referencing a missing
+// FleetProfile are rejected on creation. If resolver is not nil, images referenced by tag are
+// pinned to digest with the image pull secrets of the template got from secrets, and Squads
+// whose images can not be resolved are rejected.
+// Updates changing neither the template nor the profile reference, e.g. the writes of controllers,
+// are left alone, so changes of a FleetProfile never start rollouts of the Squads referencing it.
+// The template is kept as is on updates if the FleetProfile has been deleted.
+func mutateSquad(profiles carrierv1alpha1client.FleetProfileInterface, secrets corev1client.SecretsGetter,
+	resolver ImageResolver) admitFunc {
+	return func(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+			return allowed()
+		}
+		squad := &carrierv1alpha1.Squad{}
+		if err := json.Unmarshal(req.Object.Raw, squad); err != nil {
+			return errorResponse(err)
+		}
+		if req.Operation == admissionv1.Update {
+			oldSquad := &carrierv1alpha1.Squad{}
+			if err := json.Unmarshal(req.OldObject.Raw, oldSquad); err != nil {
+				return errorResponse(err)
+			}
+			if !templateOrProfileChanged(oldSquad, squad) {
+				return allowed()
+			}
+		}
+		var profile *carrierv1alpha1.FleetProfile
+		profileDeleted := false
+		if squad.Spec.ProfileRef != nil {
+			var err error
+			profile, err = profiles.Get(squad.Spec.ProfileRef.Name, metav1.GetOptions{})
+			switch {
+			case err == nil:
+			case k8serrors.IsNotFound(err) && req.Operation == admissionv1.Update:
+				klog.V(4).Infof("FleetProfile %v of Squad %v/%v not found, keep the template as is",
+					squad.Spec.ProfileRef.Name, squad.Namespace, squad.Name)
+				profile, profileDeleted = nil, true
+			default:
+				return errorResponse(err)
+			}
+		}
+		template, provenance := ResolveTemplate(squad, profile)
+		if profileDeleted {
+			// fields merged before still come from the FleetProfile.
+			provenance = TemplateProvenance(squad)
+		}
+		var pinned map[string]string
+		if resolver != nil {
+			keyring, err := pullSecretsKeyring(secrets, squad.Namespace, &template.Spec.Template.Spec)
+			if err != nil {
+				return errorResponse(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), pinImagesTimeout)
+			defer cancel()
+			pinned, err = PinImageDigests(ctx, resolver, &template.Spec.Template.Spec, PinnedImages(squad), keyring)
+			if err != nil {
+				return errorResponse(err)
+			}
+		}
+		var patch []jsonPatchOperation
+		if !apiequality.Semantic.DeepEqual(template, &squad.Spec.Template) {
+			patch = append(patch, jsonPatchOperation{Op: "replace", Path: "/spec/template", Value: template})
+		}
+		annotations := make(map[string]string, len(squad.Annotations)+1)
+		for key, value := range squad.Annotations {
+			annotations[key] = value
+		}
+		changed := false
+		for key, value := range map[string]map[string]string{
+			util.TemplateProvenanceAnnotation: provenance,
+			util.PinnedImagesAnnotation:       pinned,
+		} {
+			delete(annotations, key)
+			if len(value) != 0 {
+				data, err := json.Marshal(value)
+				if err != nil {
+					return errorResponse(err)
+				}
+				annotations[key] = string(data)
+			}
+			if squad.Annotations[key] != annotations[key] {
+				changed = true
+			}
+		}
+		if changed {
+			patch = append(patch, jsonPatchOperation{Op: "add", Path: "/metadata/annotations", Value: annotations})
+		}
+		if len(patch) == 0 {
+			return allowed()
+		}
+		data, err := json.Marshal(patch)
+		if err != nil {
+			return errorResponse(err)
+		}
+		klog.V(4).Infof("Mutate Squad %v/%v, provenance: %v, pinned images: %v",
+			squad.Namespace, squad.Name, provenance, pinned)
+		patchType := admissionv1.PatchTypeJSONPatch
+		return &admissionv1.AdmissionResponse{
+			Allowed:   true,
+			Patch:     data,
+			PatchType: &patchType,
+		}
+	}
+}
+
+// templateOrProfileChanged returns true if the update from oldSquad to squad changes
+// the template or the FleetProfile referenced.
+func templateOrProfileChanged(oldSquad, squad *carrierv1alpha1.Squad) bool {
+	return !apiequality.Semantic.DeepEqual(&oldSquad.Spec.Template, &squad.Spec.Template) ||
+		!apiequality.Semantic.DeepEqual(oldSquad.Spec.ProfileRef, squad.Spec.ProfileRef)
+}
+
+// ResolveTemplate returns the template of squad with profile merged, and the provenance map from
+// the fields merged to the FleetProfile they come from. Fields merged by earlier admissions are
+// kept in the map as long as squad still references the same FleetProfile. profile is nil if
+// squad references no FleetProfile.
+func ResolveTemplate(squad *carrierv1alpha1.Squad,
+	profile *carrierv1alpha1.FleetProfile) (*carrierv1alpha1.GameServerTemplateSpec, map[string]string) {
+	template := squad.Spec.Template.DeepCopy()
+	if profile == nil {
+		return template, nil
+	}
+	source := "FleetProfile/" + profile.Name
+	provenance := make(map[string]string)
+	for path, from := range TemplateProvenance(squad) {
+		if from == source {
+			provenance[path] = from
+		}
+	}
+	for _, path := range ApplyFleetProfile(&template.Spec, &profile.Spec) {
+		provenance[path] = source
+	}
+	return template, provenance
+}
+
+// TemplateProvenance returns the provenance map recorded in the annotation of squad,
+// an invalid annotation is treated as empty.
+func TemplateProvenance(squad *carrierv1alpha1.Squad) map[string]string {
+	return mapAnnotation(squad, util.TemplateProvenanceAnnotation)
+}
+
+// PinnedImages returns the map from containers to the images they were pinned from recorded
+// in the annotation of squad, an invalid annotation is treated as empty.
+func PinnedImages(squad *carrierv1alpha1.Squad) map[string]string {
+	return mapAnnotation(squad, util.PinnedImagesAnnotation)
+}
+
+// mapAnnotation decodes the JSON map in annotation key of squad.
+func mapAnnotation(squad *carrierv1alpha1.Squad, key string) map[string]string {
+	m := make(map[string]string)
+	value, ok := squad.Annotations[key]
+	if !ok {
+		return m
+	}
+	if err := json.Unmarshal([]byte(value), &m); err != nil {
+		klog.V(4).Infof("Invalid annotation %v of Squad %v/%v: %v", key, squad.Namespace, squad.Name, err)
+		return make(map[string]string)
+	}
+	return m
+}
+
+// ApplyFleetProfile merges the defaults of profile into spec, values set in spec take precedence.
+// It is idempotent, so the profile could be merged again on every template change of the Squad. The paths
+// of fields set from profile are returned, relative to the Squad.
+func ApplyFleetProfile(spec *carrierv1alpha1.GameServerSpec, profile *carrierv1alpha1.FleetProfileSpec) []string {
+	var fields []string
+	fldPath := field.NewPath("spec", "template", "spec")
+	podPath := fldPath.Child("template", "spec")
+	podSpec := &spec.Template.Spec
+	for _, sidecar := range profile.Sidecars {
+		if !hasContainer(podSpec, sidecar.Name) {
+			podSpec.Containers = append(podSpec.Containers, *sidecar.DeepCopy())
+			fields = append(fields, podPath.Child("containers").Key(sidecar.Name).String())
+		}
+	}
+	for _, volume := range profile.Volumes {
+		if !hasVolume(podSpec, volume.Name) {
+			podSpec.Volumes = append(podSpec.Volumes, *volume.DeepCopy())
+			fields = append(fields, podPath.Child("volumes").Key(volume.Name).String())
+		}
+	}
+	for key, value := range profile.NodeSelector {
+		if podSpec.NodeSelector == nil {
+			podSpec.NodeSelector = make(map[string]string)
+		}
+		if _, ok := podSpec.NodeSelector[key]; !ok {
+			podSpec.NodeSelector[key] = value
+			fields = append(fields, podPath.Child("nodeSelector").Key(key).String())
+		}
+	}
+	for _, toleration := range profile.Tolerations {
+		if !hasToleration(podSpec, &toleration) {
+			podSpec.Tolerations = append(podSpec.Tolerations, toleration)
+			fields = append(fields, podPath.Child("tolerations").Key(toleration.Key).String())
+		}
+	}
+	if podSpec.Affinity == nil && profile.Affinity != nil {
+		podSpec.Affinity = profile.Affinity.DeepCopy()
+		fields = append(fields, podPath.Child("affinity").String())
+	}
+	if len(podSpec.PriorityClassName) == 0 && len(profile.PriorityClassName) != 0 {
+		podSpec.PriorityClassName = profile.PriorityClassName
+		fields = append(fields, podPath.Child("priorityClassName").String())
+	}
+	if len(spec.Scheduling) == 0 && len(profile.Scheduling) != 0 {
+		spec.Scheduling = profile.Scheduling
+		fields = append(fields, fldPath.Child("scheduling").String())
+	}
+	var merged []string
+	spec.ReadinessGates, merged = mergeGates(spec.ReadinessGates, profile.ReadinessGates)
+	for _, gate := range merged {
+		fields = append(fields, fldPath.Child("readinessGates").Key(gate).String())
+	}
+	spec.DeletableGates, merged = mergeGates(spec.DeletableGates, profile.DeletableGates)
+	for _, gate := range merged {
+		fields = append(fields, fldPath.Child("deletableGates").Key(gate).String())
+	}
+	if spec.MaxDrainSeconds == nil && profile.MaxDrainSeconds != nil {
+		maxDrainSeconds := *profile.MaxDrainSeconds
+		spec.MaxDrainSeconds = &maxDrainSeconds
+		fields = append(fields, fldPath.Child("maxDrainSeconds").String())
+	}
+	return fields
+}
+
+func hasContainer(podSpec *corev1.PodSpec, name string) bool {
+	for _, container := range podSpec.Containers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasVolume(podSpec *corev1.PodSpec, name string) bool {
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasToleration(podSpec *corev1.PodSpec, toleration *corev1.Toleration) bool {
+	for i := range podSpec.Tolerations {
+		if apiequality.Semantic.DeepEqual(&podSpec.Tolerations[i], toleration) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeGates appends the gates of profile not in gates, and returns the gates appended.
+func mergeGates(gates, profileGates []string) ([]string, []string) {
+	var merged []string
+	for _, gate := range profileGates {
+		found := false
+		for _, existing := range gates {
+			if existing == gate {
+				found = true
+				break
+			}
+		}
+		if !found {
+			gates = append(gates, gate)
+			merged = append(merged, gate)
+		}
+	}
+	return gates, merged
+}
+
+// pullSecretsKeyring returns the credentials in the image pull secrets of spec in namespace,
+// missing secrets are skipped as kubelet does.
+func pullSecretsKeyring(secrets corev1client.SecretsGetter, namespace string, spec *corev1.PodSpec) (Keyring, error) {
+	var list []*corev1.Secret
+	for _, ref := range spec.ImagePullSecrets {
+		secret, err := secrets.Secrets(namespace).Get(ref.Name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			klog.V(4).Infof("Image pull secret %v/%v not found", namespace, ref.Name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, secret)
+	}
+	return KeyringFromSecrets(list), nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/client/clientset/versioned/fake"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func newFleetProfile() *carrierv1alpha1.FleetProfile {
+	maxDrainSeconds := int64(60)
+	return &carrierv1alpha1.FleetProfile{
+		ObjectMeta: metav1.ObjectMeta{Name: "golden"},
+		Spec: carrierv1alpha1.FleetProfileSpec{
+			Sidecars:          []corev1.Container{{Name: "agent", Image: "agent:v1"}, {Name: "server", Image: "other"}},
+			Volumes:           []corev1.Volume{{Name: "agent-config"}},
+			NodeSelector:      map[string]string{"pool": "game", "zone": "a"},
+			Tolerations:       []corev1.Toleration{{Key: "dedicated", Value: "game"}},
+			PriorityClassName: "game",
+			Scheduling:        carrierv1alpha1.MostAllocated,
+			ReadinessGates:    []string{"agent-ready"},
+			MaxDrainSeconds:   &maxDrainSeconds,
+		},
+	}
+}
+
+func TestApplyFleetProfile(t *testing.T) {
+	profile := newFleetProfile()
+	spec := &carrierv1alpha1.GameServerSpec{
+		Scheduling:     carrierv1alpha1.LeastAllocated,
+		ReadinessGates: []string{"agent-ready", "warm"},
+	}
+	spec.Template.Spec = corev1.PodSpec{
+		Containers:   []corev1.Container{{Name: "server", Image: "game:v1"}},
+		NodeSelector: map[string]string{"zone": "b"},
+	}
+	fields := ApplyFleetProfile(spec, &profile.Spec)
+	sort.Strings(fields)
+	desiredFields := []string{
+		"spec.template.spec.maxDrainSeconds",
+		"spec.template.spec.template.spec.containers[agent]",
+		"spec.template.spec.template.spec.nodeSelector[pool]",
+		"spec.template.spec.template.spec.priorityClassName",
+		"spec.template.spec.template.spec.tolerations[dedicated]",
+		"spec.template.spec.template.spec.volumes[agent-config]",
+	}
+	if !reflect.DeepEqual(fields, desiredFields) {
+		t.Errorf("desired fields %v, get: %v", desiredFields, fields)
+	}
+	podSpec := &spec.Template.Spec
+	if len(podSpec.Containers) != 2 || podSpec.Containers[0].Image != "game:v1" ||
+		podSpec.Containers[1].Name != "agent" {
+		t.Errorf("desired sidecar agent appended and container server kept, get: %+v", podSpec.Containers)
+	}
+	if desired := map[string]string{"pool": "game", "zone": "b"}; !reflect.DeepEqual(podSpec.NodeSelector, desired) {
+		t.Errorf("desired node selector %v, get: %v", desired, podSpec.NodeSelector)
+	}
+	if len(podSpec.Volumes) != 1 || len(podSpec.Tolerations) != 1 || podSpec.PriorityClassName != "game" {
+		t.Errorf("desired volumes, tolerations and priority class of profile, get: %+v", podSpec)
+	}
+	if spec.Scheduling != carrierv1alpha1.LeastAllocated {
+		t.Errorf("desired scheduling %v, get: %v", carrierv1alpha1.LeastAllocated, spec.Scheduling)
+	}
+	if desired := []string{"agent-ready", "warm"}; !reflect.DeepEqual(spec.ReadinessGates, desired) {
+		t.Errorf("desired readiness gates %v, get: %v", desired, spec.ReadinessGates)
+	}
+	if spec.MaxDrainSeconds == nil || *spec.MaxDrainSeconds != 60 {
+		t.Errorf("desired max drain seconds 60, get: %v", spec.MaxDrainSeconds)
+	}
+
+	merged := spec.DeepCopy()
+	if fields = ApplyFleetProfile(merged, &profile.Spec); len(fields) != 0 || !reflect.DeepEqual(merged, spec) {
+		t.Errorf("desired merging twice makes no change, get: %v, %+v", fields, merged)
+	}
+}
+
+func TestResolveTemplate(t *testing.T) {
+	profile := newFleetProfile()
+	squad := newSquad(carrierv1alpha1.RollingUpdateSquadStrategyType)
+	squad.Annotations = map[string]string{util.TemplateProvenanceAnnotation: `{"spec.template.spec.template.spec.containers[agent]":"FleetProfile/golden","spec.template.spec.scheduling":"FleetProfile/old"}`}
+	squad.Spec.Template.Spec.Template.Spec.Containers = append(squad.Spec.Template.Spec.Template.Spec.Containers,
+		corev1.Container{Name: "agent", Image: "agent:v1"})
+	_, provenance := ResolveTemplate(squad, profile)
+	if provenance["spec.template.spec.template.spec.containers[agent]"] != "FleetProfile/golden" {
+		t.Errorf("desired provenance of sidecar merged before kept, get: %v", provenance)
+	}
+	if _, ok := provenance["spec.template.spec.scheduling"]; ok {
+		t.Errorf("desired provenance of other profile dropped, get: %v", provenance)
+	}
+	if provenance["spec.template.spec.template.spec.priorityClassName"] != "FleetProfile/golden" {
+		t.Errorf("desired provenance of priority class, get: %v", provenance)
+	}
+}
+
+func TestMutateSquad(t *testing.T) {
+	admit := mutateSquad(fake.NewSimpleClientset(newFleetProfile()).CarrierV1alpha1().FleetProfiles(), nil, nil)
+	tests := []struct {
+		name    string
+		profile string
+		allowed bool
+		patched bool
+	}{
+		{name: "without profile", allowed: true},
+		{name: "with profile", profile: "golden", allowed: true, patched: true},
+		{name: "missing profile", profile: "missing"},
+	}
+	for _, tc := range tests {
+		squad := newSquad(carrierv1alpha1.RollingUpdateSquadStrategyType)
+		if len(tc.profile) != 0 {
+			squad.Spec.ProfileRef = &corev1.LocalObjectReference{Name: tc.profile}
+		}
+		raw, _ := json.Marshal(squad)
+		resp := admit(&admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		})
+		if resp.Allowed != tc.allowed {
+			t.Errorf("%v: desired allowed: %v, get: %v", tc.name, tc.allowed, resp.Allowed)
+		}
+		if patched := len(resp.Patch) != 0; patched != tc.patched {
+			t.Errorf("%v: desired patched: %v, get: %v", tc.name, tc.patched, patched)
+		}
+		if tc.patched && !strings.Contains(string(resp.Patch), `"path":"/metadata/annotations"`) {
+			t.Errorf("%v: desired provenance annotation patched, get: %v", tc.name, string(resp.Patch))
+		}
+	}
+}
+
+func TestMutateSquadUpdate(t *testing.T) {
+	profile := newFleetProfile()
+	client := fake.NewSimpleClientset(profile)
+	admit := mutateSquad(client.CarrierV1alpha1().FleetProfiles(), nil, nil)
+	oldSquad := newSquad(carrierv1alpha1.InplaceUpdateSquadStrategyType)
+	oldSquad.Spec.ProfileRef = &corev1.LocalObjectReference{Name: profile.Name}
+	ApplyFleetProfile(&oldSquad.Spec.Template.Spec, &profile.Spec)
+	update := func(squad *carrierv1alpha1.Squad) *admissionv1.AdmissionResponse {
+		oldRaw, _ := json.Marshal(oldSquad)
+		raw, _ := json.Marshal(squad)
+		return admit(&admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			Object:    runtime.RawExtension{Raw: raw},
+			OldObject: runtime.RawExtension{Raw: oldRaw},
+		})
+	}
+
+	// the profile gets a new sidecar, which must not leak into writes of the Squad controller.
+	profile.Spec.Sidecars = append(profile.Spec.Sidecars, corev1.Container{Name: "exporter", Image: "exporter"})
+	if _, err := client.CarrierV1alpha1().FleetProfiles().Update(profile); err != nil {
+		t.Fatal(err)
+	}
+	squad := oldSquad.DeepCopy()
+	squad.Spec.Replicas = 5
+	resp := update(squad)
+	if !resp.Allowed || len(resp.Patch) != 0 {
+		t.Fatalf("desired update without template change allowed unpatched, get: %+v", resp)
+	}
+	if errs := ValidateSquadInplaceUpdate(oldSquad, squad); len(errs) != 0 {
+		t.Errorf("desired update of InplaceUpdate Squad valid, get: %v", errs)
+	}
+
+	// the profile is deleted, image updates still go through with the template as is.
+	if err := client.CarrierV1alpha1().FleetProfiles().Delete(profile.Name, &metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	squad = oldSquad.DeepCopy()
+	squad.Spec.Template.Spec.Template.Spec.Containers[0].Image = "game:v2"
+	resp = update(squad)
+	if !resp.Allowed || len(resp.Patch) != 0 {
+		t.Errorf("desired update allowed unpatched once the profile is deleted, get: %+v, patch: %v",
+			resp, string(resp.Patch))
+	}
+}
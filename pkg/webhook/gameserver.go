@@ -0,0 +1,646 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// jsonPatchOperation is an operation of JSON patch.
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// validateGameServer returns the admitFunc validating GameServer creations and updates against policy.
+func validateGameServer(policy Policy) admitFunc {
+	return func(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+			return allowed()
+		}
+		gs := &carrierv1alpha1.GameServer{}
+		if err := json.Unmarshal(req.Object.Raw, gs); err != nil {
+			return errorResponse(err)
+		}
+		errs := ValidateGameServerDeletionCost(gs)
+		errs = append(errs, ValidateGameServerSDKPorts(gs)...)
+		errs = append(errs, ValidateGameServerOS(gs)...)
+		errs = append(errs, ValidateGameServerDNS(gs)...)
+		errs = append(errs, ValidateGameServerBandwidth(gs)...)
+		errs = append(errs, ValidateGameServerMaxDrainSeconds(gs)...)
+		errs = append(errs, ValidateGameServerMaxIdleSeconds(gs)...)
+		errs = append(errs, ValidateGameServerMaxSessionSeconds(gs)...)
+		errs = append(errs, ValidateGameServerAssetCache(gs, policy.AssetCacheRoot)...)
+		errs = append(errs, ValidateGameServerTLS(gs, policy.TLSDNSSuffixes)...)
+		errs = append(errs, ValidateGameServerPostMortem(gs, policy.PostMortemRoot)...)
+		errs = append(errs, ValidateGameServerConnectionSecret(gs)...)
+		errs = append(errs, ValidateGameServerConstraints(gs)...)
+		errs = append(errs, ValidateGameServerSecurityProfile(gs)...)
+		errs = append(errs, ValidateGameVersion(gs.Spec.GameVersion, field.NewPath("spec", "gameVersion"))...)
+		if len(errs) == 0 {
+			return allowed()
+		}
+		klog.V(4).Infof("Reject GameServer %v/%v: %v", gs.Namespace, gs.Name, errs)
+		status := k8serrors.NewInvalid(carrierv1alpha1.Kind("GameServer"), gs.Name, errs).Status()
+		return &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &status,
+		}
+	}
+}
+
+// ValidateGameServerDeletionCost checks the deletion cost annotation of GameServer
+// is an int64 in canonical form and not the reserved default cost.
+func ValidateGameServerDeletionCost(gs *carrierv1alpha1.GameServer) field.ErrorList {
+	var allErrs field.ErrorList
+	value, ok := gs.Annotations[util.GameServerDeletionCost]
+	if !ok {
+		return allErrs
+	}
+	if err := util.ValidateDeletionCost(value); err != nil {
+		fldPath := field.NewPath("metadata", "annotations").Key(util.GameServerDeletionCost)
+		allErrs = append(allErrs, field.Invalid(fldPath, value, err.Error()))
+	}
+	return allErrs
+}
+
+// ValidateGameServerSDKPorts checks the SDK ports set by env explicitly are valid
+// ports and not conflict with ports of the GameServer container. SDK ports not set
+// explicitly are selected by the controller to avoid the conflict.
+func ValidateGameServerSDKPorts(gs *carrierv1alpha1.GameServer) field.ErrorList {
+	var allErrs field.ErrorList
+	used := make(map[int32]bool)
+	for _, container := range gs.Spec.Template.Spec.Containers {
+		if container.Name != util.GameServerContainerName {
+			continue
+		}
+		for _, port := range container.Ports {
+			used[port.ContainerPort] = true
+			if port.HostPort != 0 {
+				used[port.HostPort] = true
+			}
+		}
+	}
+	for _, port := range gs.Spec.Ports {
+		if port.ContainerPort != nil {
+			used[*port.ContainerPort] = true
+		}
+	}
+	fldPath := field.NewPath("spec", "template", "spec", "containers")
+	for i, container := range gs.Spec.Template.Spec.Containers {
+		for j, env := range container.Env {
+			if env.Name != util.SDKGRPCPortEnv && env.Name != util.SDKHTTPPortEnv {
+				continue
+			}
+			envPath := fldPath.Index(i).Child("env").Index(j).Child("value")
+			port, err := strconv.ParseInt(env.Value, 10, 32)
+			if err != nil || port <= 0 || port > 65535 {
+				allErrs = append(allErrs, field.Invalid(envPath, env.Value, "must be a valid port number"))
+				continue
+			}
+			if used[int32(port)] {
+				allErrs = append(allErrs, field.Invalid(envPath, env.Value,
+					fmt.Sprintf("conflicts with ports of container %q", util.GameServerContainerName)))
+			}
+		}
+	}
+	return allErrs
+}
+
+// ValidateGameServerOS checks the OS of GameServer is supported and consistent with the
+// OS node selector of pod template. Host network is not supported by windows pods.
+func ValidateGameServerOS(gs *carrierv1alpha1.GameServer) field.ErrorList {
+	var allErrs field.ErrorList
+	fldPath := field.NewPath("spec", "os")
+	os := gs.Spec.OS
+	switch os {
+	case "":
+		os = carrierv1alpha1.Linux
+	case carrierv1alpha1.Linux, carrierv1alpha1.Windows:
+	default:
+		return append(allErrs, field.NotSupported(fldPath, os,
+			[]string{string(carrierv1alpha1.Linux), string(carrierv1alpha1.Windows)}))
+	}
+	podPath := field.NewPath("spec", "template", "spec")
+	podSpec := &gs.Spec.Template.Spec
+	if value, ok := podSpec.NodeSelector[corev1.LabelOSStable]; ok && value != string(os) {
+		allErrs = append(allErrs, field.Invalid(podPath.Child("nodeSelector").Key(corev1.LabelOSStable), value,
+			fmt.Sprintf("conflicts with os %q of GameServer", os)))
+	}
+	if os == carrierv1alpha1.Windows && podSpec.HostNetwork {
+		allErrs = append(allErrs, field.Forbidden(podPath.Child("hostNetwork"),
+			"host network is not supported by windows GameServers"))
+	}
+	return allErrs
+}
+
+// Limits of pod DNS config, same as the validation of kube-apiserver.
+const (
+	maxDNSNameservers     = 3
+	maxDNSSearchPaths     = 6
+	maxDNSSearchListChars = 256
+)
+
+// supportedDNSPolicies are the DNS policies of pod.
+var supportedDNSPolicies = []string{
+	string(corev1.DNSClusterFirst),
+	string(corev1.DNSClusterFirstWithHostNet),
+	string(corev1.DNSDefault),
+	string(corev1.DNSNone),
+}
+
+// ValidateGameServerDNS checks DNS policy and DNS config of pod template, which are passed
+// through to pods. Pods in host network without DNS policy use ClusterFirstWithHostNet.
+func ValidateGameServerDNS(gs *carrierv1alpha1.GameServer) field.ErrorList {
+	var allErrs field.ErrorList
+	fldPath := field.NewPath("spec", "template", "spec")
+	podSpec := &gs.Spec.Template.Spec
+	policy := podSpec.DNSPolicy
+	switch policy {
+	case "", corev1.DNSClusterFirst, corev1.DNSClusterFirstWithHostNet, corev1.DNSDefault, corev1.DNSNone:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("dnsPolicy"), policy, supportedDNSPolicies))
+	}
+	config := podSpec.DNSConfig
+	configPath := fldPath.Child("dnsConfig")
+	if config == nil {
+		if policy == corev1.DNSNone {
+			allErrs = append(allErrs, field.Required(configPath,
+				fmt.Sprintf("must provide `dnsConfig` when `dnsPolicy` is %s", corev1.DNSNone)))
+		}
+		return allErrs
+	}
+	if len(config.Nameservers) > maxDNSNameservers {
+		allErrs = append(allErrs, field.Invalid(configPath.Child("nameservers"), config.Nameservers,
+			fmt.Sprintf("must not have more than %v nameservers", maxDNSNameservers)))
+	}
+	for i, nameserver := range config.Nameservers {
+		if net.ParseIP(nameserver) == nil {
+			allErrs = append(allErrs, field.Invalid(configPath.Child("nameservers").Index(i), nameserver,
+				"must be valid IP address"))
+		}
+	}
+	if policy == corev1.DNSNone && len(config.Nameservers) == 0 {
+		allErrs = append(allErrs, field.Required(configPath.Child("nameservers"),
+			fmt.Sprintf("must provide at least one DNS nameserver when `dnsPolicy` is %s", corev1.DNSNone)))
+	}
+	if len(config.Searches) > maxDNSSearchPaths {
+		allErrs = append(allErrs, field.Invalid(configPath.Child("searches"), config.Searches,
+			fmt.Sprintf("must not have more than %v search paths", maxDNSSearchPaths)))
+	}
+	if chars := len(strings.Join(config.Searches, " ")); chars > maxDNSSearchListChars {
+		allErrs = append(allErrs, field.Invalid(configPath.Child("searches"), config.Searches,
+			fmt.Sprintf("must not have more than %v characters (including spaces) in the search list",
+				maxDNSSearchListChars)))
+	}
+	for i, option := range config.Options {
+		if len(option.Name) == 0 {
+			allErrs = append(allErrs, field.Required(configPath.Child("options").Index(i).Child("name"),
+				"must not be empty"))
+		}
+	}
+	return allErrs
+}
+
+// Limits of bandwidth, same as the ones accepted by kubelet.
+var (
+	minBandwidth = resource.MustParse("1k")
+	maxBandwidth = resource.MustParse("1P")
+)
+
+// ValidateGameServerBandwidth checks the bandwidth limits of GameServer are in range [1k, 1P].
+func ValidateGameServerBandwidth(gs *carrierv1alpha1.GameServer) field.ErrorList {
+	var allErrs field.ErrorList
+	bandwidth := gs.Spec.Bandwidth
+	if bandwidth == nil {
+		return allErrs
+	}
+	fldPath := field.NewPath("spec", "bandwidth")
+	allErrs = append(allErrs, validateBandwidth(bandwidth.Ingress, fldPath.Child("ingress"))...)
+	allErrs = append(allErrs, validateBandwidth(bandwidth.Egress, fldPath.Child("egress"))...)
+	return allErrs
+}
+
+func validateBandwidth(quantity *resource.Quantity, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if quantity == nil {
+		return allErrs
+	}
+	if quantity.Cmp(minBandwidth) < 0 || quantity.Cmp(maxBandwidth) > 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath, quantity.String(),
+			fmt.Sprintf("must be between %v and %v", minBandwidth.String(), maxBandwidth.String())))
+	}
+	return allErrs
+}
+
+// ValidateGameServerMaxDrainSeconds checks the drain deadline of GameServer is positive.
+func ValidateGameServerMaxDrainSeconds(gs *carrierv1alpha1.GameServer) field.ErrorList {
+	var allErrs field.ErrorList
+	if gs.Spec.MaxDrainSeconds != nil && *gs.Spec.MaxDrainSeconds <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "maxDrainSeconds"),
+			*gs.Spec.MaxDrainSeconds, "must be greater than 0"))
+	}
+	return allErrs
+}
+
+// ValidateGameServerMaxIdleSeconds checks the max idle seconds of GameServer is positive.
+func ValidateGameServerMaxIdleSeconds(gs *carrierv1alpha1.GameServer) field.ErrorList {
+	var allErrs field.ErrorList
+	if gs.Spec.MaxIdleSeconds != nil && *gs.Spec.MaxIdleSeconds <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "maxIdleSeconds"),
+			*gs.Spec.MaxIdleSeconds, "must be greater than 0"))
+	}
+	return allErrs
+}
+
+// ValidateGameServerMaxSessionSeconds checks the max session seconds of GameServer is positive.
+func ValidateGameServerMaxSessionSeconds(gs *carrierv1alpha1.GameServer) field.ErrorList {
+	var allErrs field.ErrorList
+	if gs.Spec.MaxSessionSeconds != nil && *gs.Spec.MaxSessionSeconds <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "maxSessionSeconds"),
+			*gs.Spec.MaxSessionSeconds, "must be greater than 0"))
+	}
+	return allErrs
+}
+
+// ValidateGameServerAssetCache checks the key of asset cache could name a directory and a lease,
+// the mount path is absolute and the host path is under hostRoot.
+func ValidateGameServerAssetCache(gs *carrierv1alpha1.GameServer, hostRoot string) field.ErrorList {
+	var allErrs field.ErrorList
+	cache := gs.Spec.AssetCache
+	if cache == nil {
+		return allErrs
+	}
+	fldPath := field.NewPath("spec", "assetCache")
+	for _, msg := range validation.IsDNS1123Subdomain(cache.Key) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("key"), cache.Key, msg))
+	}
+	if len(cache.Container.Image) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("container", "image"), "must not be empty"))
+	}
+	if !path.IsAbs(cache.MountPath) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("mountPath"), cache.MountPath,
+			"must be an absolute path"))
+	}
+	switch {
+	case len(cache.HostPath) == 0:
+	case len(hostRoot) == 0:
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("hostPath"),
+			"asset caches on host path are not allowed"))
+	case !util.IsSubPath(hostRoot, cache.HostPath):
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("hostPath"), cache.HostPath,
+			fmt.Sprintf("must be an absolute path under %v", hostRoot)))
+	}
+	return allErrs
+}
+
+// ValidateGameServerPostMortem checks the dump directory is under hostRoot, the mount path is
+// absolute and the upload container has an image.
+func ValidateGameServerPostMortem(gs *carrierv1alpha1.GameServer, hostRoot string) field.ErrorList {
+	var allErrs field.ErrorList
+	postMortem := gs.Spec.PostMortem
+	if postMortem == nil {
+		return allErrs
+	}
+	fldPath := field.NewPath("spec", "postMortem")
+	switch {
+	case len(hostRoot) == 0:
+		allErrs = append(allErrs, field.Forbidden(fldPath, "post mortem is not allowed"))
+	case !util.IsSubPath(hostRoot, postMortem.HostPath):
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("hostPath"), postMortem.HostPath,
+			fmt.Sprintf("must be an absolute path under %v", hostRoot)))
+	}
+	if !path.IsAbs(postMortem.MountPath) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("mountPath"), postMortem.MountPath,
+			"must be an absolute path"))
+	}
+	if len(postMortem.Container.Image) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("container", "image"), "must not be empty"))
+	}
+	return allErrs
+}
+
+// ValidateGameServerTemplate checks the GameServer template of Squads and GameServerSets
+// against policy, with the validators of GameServers whose violations would otherwise only be
+// found once GameServers are created from the template. Paths of errors are under fldPath.
+func ValidateGameServerTemplate(template *carrierv1alpha1.GameServerTemplateSpec, policy Policy,
+	fldPath *field.Path) field.ErrorList {
+	gs := &carrierv1alpha1.GameServer{ObjectMeta: template.ObjectMeta, Spec: template.Spec}
+	errs := ValidateGameServerAssetCache(gs, policy.AssetCacheRoot)
+	errs = append(errs, ValidateGameServerTLS(gs, policy.TLSDNSSuffixes)...)
+	errs = append(errs, ValidateGameServerConstraints(gs)...)
+	errs = append(errs, ValidateGameServerSecurityProfile(gs)...)
+	errs = append(errs, ValidateGameVersion(gs.Spec.GameVersion, field.NewPath("spec", "gameVersion"))...)
+	for _, err := range errs {
+		err.Field = fldPath.String() + "." + err.Field
+	}
+	return errs
+}
+
+// ValidateGameVersion checks the game version is a valid label value, as GameServers are
+// labeled with their game version.
+func ValidateGameVersion(version string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, msg := range validation.IsValidLabelValue(version) {
+		allErrs = append(allErrs, field.Invalid(fldPath, version, msg))
+	}
+	return allErrs
+}
+
+// ValidateGameServerConnectionSecret checks the connection secret annotation names a Secret.
+func ValidateGameServerConnectionSecret(gs *carrierv1alpha1.GameServer) field.ErrorList {
+	var allErrs field.ErrorList
+	name, ok := gs.Annotations[util.ConnectionSecretAnnotation]
+	if !ok {
+		return allErrs
+	}
+	fldPath := field.NewPath("metadata", "annotations").Key(util.ConnectionSecretAnnotation)
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		allErrs = append(allErrs, field.Invalid(fldPath, name, msg))
+	}
+	return allErrs
+}
+
+// ValidateGameServerTLS checks the mount path of certificate is absolute, the DNS names
+// are valid and under one of dnsSuffixes, and the duration is positive.
+func ValidateGameServerTLS(gs *carrierv1alpha1.GameServer, dnsSuffixes []string) field.ErrorList {
+	var allErrs field.ErrorList
+	tls := gs.Spec.TLS
+	if tls == nil {
+		return allErrs
+	}
+	fldPath := field.NewPath("spec", "tls")
+	if !path.IsAbs(tls.MountPath) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("mountPath"), tls.MountPath,
+			"must be an absolute path"))
+	}
+	for i, name := range tls.DNSNames {
+		idxPath := fldPath.Child("dnsNames").Index(i)
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			allErrs = append(allErrs, field.Invalid(idxPath, name, msg))
+		}
+		if !util.HasDNSSuffix(name, dnsSuffixes) {
+			allErrs = append(allErrs, field.Invalid(idxPath, name,
+				fmt.Sprintf("must be under one of the allowed domains %v", dnsSuffixes)))
+		}
+	}
+	if tls.Duration != nil && tls.Duration.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("duration"), tls.Duration.Duration.String(),
+			"must be greater than 0"))
+	}
+	return allErrs
+}
+
+// builtinConstraintTypes are the constraint types defined by carrier.
+var builtinConstraintTypes = []string{
+	string(carrierv1alpha1.NotInService),
+	string(carrierv1alpha1.NoNewSessions),
+	string(carrierv1alpha1.Cordoned),
+}
+
+// ValidateGameServerConstraints checks the constraints of GameServer are of the types defined by
+// carrier, or user defined types prefixed by a domain, each type appears at most once, effective
+// is set, and the constraint does not expire before it is added.
+func ValidateGameServerConstraints(gs *carrierv1alpha1.GameServer) field.ErrorList {
+	var allErrs field.ErrorList
+	fldPath := field.NewPath("spec", "constraints")
+	seen := make(map[carrierv1alpha1.ConstraintType]bool)
+	for i, constraint := range gs.Spec.Constraints {
+		idxPath := fldPath.Index(i)
+		typePath := idxPath.Child("type")
+		switch constraint.Type {
+		case "":
+			allErrs = append(allErrs, field.Required(typePath, ""))
+		case carrierv1alpha1.NotInService, carrierv1alpha1.NoNewSessions, carrierv1alpha1.Cordoned:
+		default:
+			name := string(constraint.Type)
+			if !strings.Contains(name, "/") {
+				allErrs = append(allErrs, field.NotSupported(typePath, name,
+					append(builtinConstraintTypes, "<domain>/<name>")))
+				break
+			}
+			for _, msg := range validation.IsQualifiedName(name) {
+				allErrs = append(allErrs, field.Invalid(typePath, name, msg))
+			}
+		}
+		if seen[constraint.Type] {
+			allErrs = append(allErrs, field.Duplicate(typePath, constraint.Type))
+		}
+		seen[constraint.Type] = true
+		if constraint.Effective == nil {
+			allErrs = append(allErrs, field.Required(idxPath.Child("effective"), ""))
+		}
+		if constraint.ExpiresAt != nil && constraint.TimeAdded != nil &&
+			!constraint.ExpiresAt.After(constraint.TimeAdded.Time) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("expiresAt"), constraint.ExpiresAt.String(),
+				"must be after timeAdded"))
+		}
+	}
+	return allErrs
+}
+
+// supportedSecurityProfiles are the security profiles of GameServer.
+var supportedSecurityProfiles = []string{
+	string(carrierv1alpha1.BaselineSecurityProfile),
+	string(carrierv1alpha1.RestrictedSecurityProfile),
+}
+
+// baselineCapabilities are the capabilities containers may add under the baseline profile,
+// same as the Pod Security Standards. Restricted containers may only add NET_BIND_SERVICE.
+var baselineCapabilities = map[corev1.Capability]bool{
+	"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true,
+	"KILL": true, "MKNOD": true, "NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true,
+	"SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+}
+
+// ValidateGameServerSecurityProfile checks the security profile of GameServer is supported, and
+// the GameServer does not violate it. Host ports are forbidden by both profiles, so ports of
+// GameServer must use the LoadBalancer port policy, which is the default.
+func ValidateGameServerSecurityProfile(gs *carrierv1alpha1.GameServer) field.ErrorList {
+	var allErrs field.ErrorList
+	fldPath := field.NewPath("spec", "securityProfile")
+	profile := gs.Spec.SecurityProfile
+	switch profile {
+	case "":
+		return allErrs
+	case carrierv1alpha1.BaselineSecurityProfile, carrierv1alpha1.RestrictedSecurityProfile:
+	default:
+		return append(allErrs, field.NotSupported(fldPath, profile, supportedSecurityProfiles))
+	}
+	if gs.Spec.OS == carrierv1alpha1.Windows {
+		return append(allErrs, field.Forbidden(fldPath, "not supported by windows GameServers"))
+	}
+	forbidden := fmt.Sprintf("forbidden by the %s security profile", profile)
+	portsPath := field.NewPath("spec", "ports")
+	for i, port := range gs.Spec.Ports {
+		idxPath := portsPath.Index(i)
+		if port.PortPolicy == carrierv1alpha1.Static || port.PortPolicy == carrierv1alpha1.Dynamic {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("portPolicy"), port.PortPolicy,
+				fmt.Sprintf("host ports are %s, use %s", forbidden, carrierv1alpha1.LoadBalancer)))
+		}
+		if port.HostPort != nil {
+			allErrs = append(allErrs, field.Forbidden(idxPath.Child("hostPort"), forbidden))
+		}
+		if port.HostPortRange != nil {
+			allErrs = append(allErrs, field.Forbidden(idxPath.Child("hostPortRange"), forbidden))
+		}
+	}
+	if gs.Spec.AssetCache != nil && len(gs.Spec.AssetCache.HostPath) != 0 {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "assetCache", "hostPath"), forbidden))
+	}
+	if gs.Spec.PostMortem != nil {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "postMortem"),
+			"crash dumps on host path are "+forbidden))
+	}
+	podPath := field.NewPath("spec", "template", "spec")
+	podSpec := &gs.Spec.Template.Spec
+	if podSpec.HostNetwork {
+		allErrs = append(allErrs, field.Forbidden(podPath.Child("hostNetwork"), forbidden))
+	}
+	if podSpec.HostPID {
+		allErrs = append(allErrs, field.Forbidden(podPath.Child("hostPID"), forbidden))
+	}
+	if podSpec.HostIPC {
+		allErrs = append(allErrs, field.Forbidden(podPath.Child("hostIPC"), forbidden))
+	}
+	for i, volume := range podSpec.Volumes {
+		if volume.HostPath != nil {
+			allErrs = append(allErrs, field.Forbidden(podPath.Child("volumes").Index(i).Child("hostPath"),
+				forbidden))
+		}
+	}
+	if profile == carrierv1alpha1.RestrictedSecurityProfile && podSpec.SecurityContext != nil {
+		sc := podSpec.SecurityContext
+		scPath := podPath.Child("securityContext")
+		if sc.RunAsNonRoot != nil && !*sc.RunAsNonRoot {
+			allErrs = append(allErrs, field.Forbidden(scPath.Child("runAsNonRoot"), forbidden))
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			allErrs = append(allErrs, field.Forbidden(scPath.Child("runAsUser"), forbidden))
+		}
+	}
+	allErrs = append(allErrs, validateContainersSecurityProfile(podSpec.InitContainers, profile,
+		podPath.Child("initContainers"))...)
+	allErrs = append(allErrs, validateContainersSecurityProfile(podSpec.Containers, profile,
+		podPath.Child("containers"))...)
+	return allErrs
+}
+
+// validateContainersSecurityProfile checks containers do not violate profile.
+func validateContainersSecurityProfile(containers []corev1.Container, profile carrierv1alpha1.SecurityProfile,
+	fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	forbidden := fmt.Sprintf("forbidden by the %s security profile", profile)
+	restricted := profile == carrierv1alpha1.RestrictedSecurityProfile
+	for i, container := range containers {
+		idxPath := fldPath.Index(i)
+		for j, port := range container.Ports {
+			if port.HostPort != 0 {
+				allErrs = append(allErrs, field.Forbidden(idxPath.Child("ports").Index(j).Child("hostPort"),
+					forbidden))
+			}
+		}
+		sc := container.SecurityContext
+		if sc == nil {
+			continue
+		}
+		scPath := idxPath.Child("securityContext")
+		if sc.Privileged != nil && *sc.Privileged {
+			allErrs = append(allErrs, field.Forbidden(scPath.Child("privileged"), forbidden))
+		}
+		if sc.Capabilities != nil {
+			for j, capability := range sc.Capabilities.Add {
+				if !baselineCapabilities[capability] || (restricted && capability != "NET_BIND_SERVICE") {
+					allErrs = append(allErrs, field.Forbidden(scPath.Child("capabilities", "add").Index(j),
+						fmt.Sprintf("capability %s is %s", capability, forbidden)))
+				}
+			}
+		}
+		if !restricted {
+			continue
+		}
+		if sc.AllowPrivilegeEscalation != nil && *sc.AllowPrivilegeEscalation {
+			allErrs = append(allErrs, field.Forbidden(scPath.Child("allowPrivilegeEscalation"), forbidden))
+		}
+		if sc.RunAsNonRoot != nil && !*sc.RunAsNonRoot {
+			allErrs = append(allErrs, field.Forbidden(scPath.Child("runAsNonRoot"), forbidden))
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			allErrs = append(allErrs, field.Forbidden(scPath.Child("runAsUser"), forbidden))
+		}
+	}
+	return allErrs
+}
+
+// mutateGameServer normalizes the deletion cost annotation of GameServer, so
+// that values like "+10" or "008" are accepted as "10" and "8".
+func mutateGameServer(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return allowed()
+	}
+	gs := &carrierv1alpha1.GameServer{}
+	if err := json.Unmarshal(req.Object.Raw, gs); err != nil {
+		return errorResponse(err)
+	}
+	value, ok := gs.Annotations[util.GameServerDeletionCost]
+	if !ok {
+		return allowed()
+	}
+	normalized, err := util.NormalizeDeletionCost(value)
+	if err != nil || normalized == value {
+		// invalid values are rejected by validation.
+		return allowed()
+	}
+	patch, err := json.Marshal([]jsonPatchOperation{
+		{
+			Op:    "replace",
+			Path:  "/metadata/annotations/" + escapeJSONPointer(util.GameServerDeletionCost),
+			Value: normalized,
+		},
+	})
+	if err != nil {
+		return errorResponse(err)
+	}
+	klog.V(4).Infof("Normalize deletion cost of GameServer %v/%v from %q to %q",
+		gs.Namespace, gs.Name, value, normalized)
+	patchType := admissionv1.PatchTypeJSONPatch
+	return &admissionv1.AdmissionResponse{
+		Allowed:   true,
+		Patch:     patch,
+		PatchType: &patchType,
+	}
+}
+
+// escapeJSONPointer escapes s as a reference token of JSON pointer.
+func escapeJSONPointer(s string) string {
+	return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
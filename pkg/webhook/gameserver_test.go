@@ -0,0 +1,789 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func newGameServerRequest(cost string) *admissionv1.AdmissionRequest {
+	gs := &carrierv1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Namespace:   metav1.NamespaceDefault,
+			Annotations: map[string]string{util.GameServerDeletionCost: cost},
+		},
+	}
+	raw, _ := json.Marshal(gs)
+	return &admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}
+}
+
+func TestValidateGameServer(t *testing.T) {
+	tests := []struct {
+		cost    string
+		allowed bool
+	}{
+		{cost: "10", allowed: true},
+		{cost: "-10", allowed: true},
+		{cost: "0", allowed: true},
+		{cost: "+10", allowed: false},
+		{cost: "008", allowed: false},
+		{cost: "abc", allowed: false},
+		{cost: "9223372036854775808", allowed: false},
+		{cost: "9223372036854775807", allowed: false},
+	}
+	for _, tc := range tests {
+		resp := validateGameServer(Policy{})(newGameServerRequest(tc.cost))
+		if resp.Allowed != tc.allowed {
+			t.Errorf("cost %q, desired allowed: %v, get: %v", tc.cost, tc.allowed, resp.Allowed)
+		}
+	}
+}
+
+func TestMutateGameServer(t *testing.T) {
+	tests := []struct {
+		cost  string
+		patch string
+	}{
+		{cost: "10"},
+		{cost: "abc"},
+		{
+			cost:  " +008",
+			patch: `[{"op":"replace","path":"/metadata/annotations/carrier.ocgi.dev~1gs-deletion-cost","value":"8"}]`,
+		},
+	}
+	for _, tc := range tests {
+		resp := mutateGameServer(newGameServerRequest(tc.cost))
+		if !resp.Allowed {
+			t.Errorf("cost %q, desired allowed", tc.cost)
+		}
+		if string(resp.Patch) != tc.patch {
+			t.Errorf("cost %q, desired patch: %v, get: %v", tc.cost, tc.patch, string(resp.Patch))
+		}
+	}
+}
+
+func TestValidateGameServerSDKPorts(t *testing.T) {
+	port := int32(9020)
+	tests := []struct {
+		name     string
+		env      []corev1.EnvVar
+		errPaths []string
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name: "no conflict",
+			env:  []corev1.EnvVar{{Name: util.SDKGRPCPortEnv, Value: "9030"}},
+		},
+		{
+			name:     "conflict",
+			env:      []corev1.EnvVar{{Name: util.SDKGRPCPortEnv, Value: "9020"}},
+			errPaths: []string{"spec.template.spec.containers[0].env[0].value"},
+		},
+		{
+			name:     "invalid",
+			env:      []corev1.EnvVar{{Name: "foo", Value: "bar"}, {Name: util.SDKHTTPPortEnv, Value: "abc"}},
+			errPaths: []string{"spec.template.spec.containers[0].env[1].value"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				Spec: carrierv1alpha1.GameServerSpec{
+					Ports: []carrierv1alpha1.GameServerPort{{Name: "default", ContainerPort: &port}},
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: util.GameServerContainerName, Env: tc.env}},
+						},
+					},
+				},
+			}
+			errs := ValidateGameServerSDKPorts(gs)
+			if len(errs) != len(tc.errPaths) {
+				t.Fatalf("desired %v errors, get: %v", len(tc.errPaths), errs)
+			}
+			for i, err := range errs {
+				if err.Field != tc.errPaths[i] {
+					t.Errorf("desired error on %v, get: %v", tc.errPaths[i], err.Field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateGameServerOS(t *testing.T) {
+	tests := []struct {
+		name         string
+		os           carrierv1alpha1.OperatingSystem
+		nodeSelector map[string]string
+		hostNetwork  bool
+		errPaths     []string
+	}{
+		{
+			name:         "default linux",
+			nodeSelector: map[string]string{corev1.LabelOSStable: "linux"},
+			hostNetwork:  true,
+		},
+		{
+			name:         "windows",
+			os:           carrierv1alpha1.Windows,
+			nodeSelector: map[string]string{corev1.LabelOSStable: "windows"},
+		},
+		{
+			name:     "not supported",
+			os:       "darwin",
+			errPaths: []string{"spec.os"},
+		},
+		{
+			name:         "node selector conflict",
+			os:           carrierv1alpha1.Windows,
+			nodeSelector: map[string]string{corev1.LabelOSStable: "linux"},
+			hostNetwork:  true,
+			errPaths:     []string{"spec.template.spec.nodeSelector[kubernetes.io/os]", "spec.template.spec.hostNetwork"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				Spec: carrierv1alpha1.GameServerSpec{
+					OS: tc.os,
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							NodeSelector: tc.nodeSelector,
+							HostNetwork:  tc.hostNetwork,
+						},
+					},
+				},
+			}
+			errs := ValidateGameServerOS(gs)
+			if len(errs) != len(tc.errPaths) {
+				t.Fatalf("desired %v errors, get: %v", len(tc.errPaths), errs)
+			}
+			for i, err := range errs {
+				if err.Field != tc.errPaths[i] {
+					t.Errorf("desired error on %v, get: %v", tc.errPaths[i], err.Field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateGameServerDNS(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   corev1.DNSPolicy
+		config   *corev1.PodDNSConfig
+		errPaths []string
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:   "host network",
+			policy: corev1.DNSClusterFirstWithHostNet,
+			config: &corev1.PodDNSConfig{Searches: []string{"game.svc.cluster.local"}},
+		},
+		{
+			name:     "not supported",
+			policy:   "Cluster",
+			errPaths: []string{"spec.template.spec.dnsPolicy"},
+		},
+		{
+			name:     "none without config",
+			policy:   corev1.DNSNone,
+			errPaths: []string{"spec.template.spec.dnsConfig"},
+		},
+		{
+			name:   "none without nameservers",
+			policy: corev1.DNSNone,
+			config: &corev1.PodDNSConfig{
+				Options: []corev1.PodDNSConfigOption{{}},
+			},
+			errPaths: []string{"spec.template.spec.dnsConfig.nameservers",
+				"spec.template.spec.dnsConfig.options[0].name"},
+		},
+		{
+			name:   "invalid nameservers",
+			policy: corev1.DNSNone,
+			config: &corev1.PodDNSConfig{
+				Nameservers: []string{"8.8.8.8", "1.1.1.1", "10.0.0.10", "dns"},
+			},
+			errPaths: []string{"spec.template.spec.dnsConfig.nameservers",
+				"spec.template.spec.dnsConfig.nameservers[3]"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				Spec: carrierv1alpha1.GameServerSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							DNSPolicy: tc.policy,
+							DNSConfig: tc.config,
+						},
+					},
+				},
+			}
+			errs := ValidateGameServerDNS(gs)
+			if len(errs) != len(tc.errPaths) {
+				t.Fatalf("desired %v errors, get: %v", len(tc.errPaths), errs)
+			}
+			for i, err := range errs {
+				if err.Field != tc.errPaths[i] {
+					t.Errorf("desired error on %v, get: %v", tc.errPaths[i], err.Field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateGameServerBandwidth(t *testing.T) {
+	quantity := func(value string) *resource.Quantity {
+		q := resource.MustParse(value)
+		return &q
+	}
+	tests := []struct {
+		name      string
+		bandwidth *carrierv1alpha1.Bandwidth
+		errPaths  []string
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:      "valid",
+			bandwidth: &carrierv1alpha1.Bandwidth{Ingress: quantity("10M"), Egress: quantity("100M")},
+		},
+		{
+			name:      "out of range",
+			bandwidth: &carrierv1alpha1.Bandwidth{Ingress: quantity("100"), Egress: quantity("2P")},
+			errPaths:  []string{"spec.bandwidth.ingress", "spec.bandwidth.egress"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				Spec: carrierv1alpha1.GameServerSpec{Bandwidth: tc.bandwidth},
+			}
+			errs := ValidateGameServerBandwidth(gs)
+			if len(errs) != len(tc.errPaths) {
+				t.Fatalf("desired %v errors, get: %v", len(tc.errPaths), errs)
+			}
+			for i, err := range errs {
+				if err.Field != tc.errPaths[i] {
+					t.Errorf("desired error on %v, get: %v", tc.errPaths[i], err.Field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateGameServerMaxDrainSeconds(t *testing.T) {
+	seconds := func(value int64) *int64 {
+		return &value
+	}
+	tests := []struct {
+		name            string
+		maxDrainSeconds *int64
+		valid           bool
+	}{
+		{
+			name:  "not set",
+			valid: true,
+		},
+		{
+			name:            "positive",
+			maxDrainSeconds: seconds(600),
+			valid:           true,
+		},
+		{
+			name:            "zero",
+			maxDrainSeconds: seconds(0),
+		},
+		{
+			name:            "negative",
+			maxDrainSeconds: seconds(-1),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				Spec: carrierv1alpha1.GameServerSpec{MaxDrainSeconds: tc.maxDrainSeconds},
+			}
+			errs := ValidateGameServerMaxDrainSeconds(gs)
+			if tc.valid != (len(errs) == 0) {
+				t.Errorf("desired valid: %v, get: %v", tc.valid, errs)
+			}
+		})
+	}
+}
+
+func TestValidateGameServerMaxIdleSeconds(t *testing.T) {
+	seconds := func(value int64) *int64 {
+		return &value
+	}
+	tests := []struct {
+		maxIdleSeconds *int64
+		valid          bool
+	}{
+		{valid: true},
+		{maxIdleSeconds: seconds(300), valid: true},
+		{maxIdleSeconds: seconds(0)},
+	}
+	for i, tc := range tests {
+		gs := &carrierv1alpha1.GameServer{
+			Spec: carrierv1alpha1.GameServerSpec{MaxIdleSeconds: tc.maxIdleSeconds},
+		}
+		if errs := ValidateGameServerMaxIdleSeconds(gs); tc.valid != (len(errs) == 0) {
+			t.Errorf("case %v, desired valid: %v, get: %v", i, tc.valid, errs)
+		}
+	}
+}
+
+func TestValidateGameServerMaxSessionSeconds(t *testing.T) {
+	seconds := func(value int64) *int64 {
+		return &value
+	}
+	tests := []struct {
+		maxSessionSeconds *int64
+		valid             bool
+	}{
+		{valid: true},
+		{maxSessionSeconds: seconds(3600), valid: true},
+		{maxSessionSeconds: seconds(-1)},
+	}
+	for i, tc := range tests {
+		gs := &carrierv1alpha1.GameServer{
+			Spec: carrierv1alpha1.GameServerSpec{MaxSessionSeconds: tc.maxSessionSeconds},
+		}
+		if errs := ValidateGameServerMaxSessionSeconds(gs); tc.valid != (len(errs) == 0) {
+			t.Errorf("case %v, desired valid: %v, get: %v", i, tc.valid, errs)
+		}
+	}
+}
+
+func TestValidateGameServerAssetCache(t *testing.T) {
+	tests := []struct {
+		name  string
+		cache *carrierv1alpha1.AssetCache
+		valid bool
+	}{
+		{
+			name:  "not set",
+			valid: true,
+		},
+		{
+			name: "host path",
+			cache: &carrierv1alpha1.AssetCache{Key: "v1.2.0", MountPath: "/assets", HostPath: "/var/cache/game",
+				Container: corev1.Container{Image: "downloader"}},
+			valid: true,
+		},
+		{
+			name: "host path not under root",
+			cache: &carrierv1alpha1.AssetCache{Key: "v1.2.0", MountPath: "/assets", HostPath: "/etc",
+				Container: corev1.Container{Image: "downloader"}},
+		},
+		{
+			name: "host path escaping root",
+			cache: &carrierv1alpha1.AssetCache{Key: "v1.2.0", MountPath: "/assets",
+				HostPath: "/var/cache/../../etc", Container: corev1.Container{Image: "downloader"}},
+		},
+		{
+			name: "invalid key",
+			cache: &carrierv1alpha1.AssetCache{Key: "v1/2", MountPath: "/assets",
+				Container: corev1.Container{Image: "downloader"}},
+		},
+		{
+			name:  "no image",
+			cache: &carrierv1alpha1.AssetCache{Key: "v1", MountPath: "/assets"},
+		},
+		{
+			name: "relative path",
+			cache: &carrierv1alpha1.AssetCache{Key: "v1", MountPath: "assets",
+				Container: corev1.Container{Image: "downloader"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				Spec: carrierv1alpha1.GameServerSpec{AssetCache: tc.cache},
+			}
+			errs := ValidateGameServerAssetCache(gs, "/var/cache")
+			if tc.valid != (len(errs) == 0) {
+				t.Errorf("desired valid: %v, get: %v", tc.valid, errs)
+			}
+		})
+	}
+}
+
+func TestValidateGameServerPostMortem(t *testing.T) {
+	tests := []struct {
+		name       string
+		postMortem *carrierv1alpha1.PostMortem
+		valid      bool
+	}{
+		{
+			name:  "not set",
+			valid: true,
+		},
+		{
+			name: "host path",
+			postMortem: &carrierv1alpha1.PostMortem{HostPath: "/var/dumps/game", MountPath: "/dumps",
+				Container: corev1.Container{Image: "uploader"}},
+			valid: true,
+		},
+		{
+			name: "host path not under root",
+			postMortem: &carrierv1alpha1.PostMortem{HostPath: "/var/lib/kubelet", MountPath: "/dumps",
+				Container: corev1.Container{Image: "uploader"}},
+		},
+		{
+			name: "host path escaping root",
+			postMortem: &carrierv1alpha1.PostMortem{HostPath: "/var/dumps/../../etc", MountPath: "/dumps",
+				Container: corev1.Container{Image: "uploader"}},
+		},
+		{
+			name: "relative path",
+			postMortem: &carrierv1alpha1.PostMortem{HostPath: "/var/dumps", MountPath: "dumps",
+				Container: corev1.Container{Image: "uploader"}},
+		},
+		{
+			name:       "no image",
+			postMortem: &carrierv1alpha1.PostMortem{HostPath: "/var/dumps", MountPath: "/dumps"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				Spec: carrierv1alpha1.GameServerSpec{PostMortem: tc.postMortem},
+			}
+			errs := ValidateGameServerPostMortem(gs, "/var/dumps")
+			if tc.valid != (len(errs) == 0) {
+				t.Errorf("desired valid: %v, get: %v", tc.valid, errs)
+			}
+		})
+	}
+	gs := &carrierv1alpha1.GameServer{
+		Spec: carrierv1alpha1.GameServerSpec{PostMortem: &carrierv1alpha1.PostMortem{HostPath: "/var/dumps",
+			MountPath: "/dumps", Container: corev1.Container{Image: "uploader"}}},
+	}
+	if errs := ValidateGameServerPostMortem(gs, ""); len(errs) == 0 {
+		t.Errorf("desired post mortem forbidden without host root")
+	}
+}
+
+func TestValidateGameServerConnectionSecret(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		valid       bool
+	}{
+		{name: "not set", valid: true},
+		{name: "valid", annotations: map[string]string{util.ConnectionSecretAnnotation: "match-1"}, valid: true},
+		{name: "empty", annotations: map[string]string{util.ConnectionSecretAnnotation: ""}},
+		{name: "invalid", annotations: map[string]string{util.ConnectionSecretAnnotation: "Match/1"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+			errs := ValidateGameServerConnectionSecret(gs)
+			if tc.valid != (len(errs) == 0) {
+				t.Errorf("desired valid: %v, get: %v", tc.valid, errs)
+			}
+		})
+	}
+}
+
+func TestValidateGameVersion(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		version string
+		valid   bool
+	}{
+		{name: "not set", valid: true},
+		{name: "valid", version: "1.2.0-hotfix_1", valid: true},
+		{name: "slash", version: "release/1.2"},
+		{name: "too long", version: strings.Repeat("1", 64)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := ValidateGameVersion(tc.version, field.NewPath("spec", "gameVersion"))
+			if tc.valid != (len(errs) == 0) {
+				t.Errorf("desired valid: %v, get: %v", tc.valid, errs)
+			}
+		})
+	}
+}
+
+func TestValidateGameServerTemplate(t *testing.T) {
+	effective := true
+	tests := []struct {
+		name     string
+		spec     carrierv1alpha1.GameServerSpec
+		errPaths []string
+	}{
+		{
+			name: "valid",
+			spec: carrierv1alpha1.GameServerSpec{GameVersion: "1.2.0"},
+		},
+		{
+			name: "invalid",
+			spec: carrierv1alpha1.GameServerSpec{
+				GameVersion:     "release/1.2",
+				TLS:             &carrierv1alpha1.GameServerTLS{MountPath: "/etc/tls", DNSNames: []string{"bank.example.org"}},
+				Constraints:     []carrierv1alpha1.Constraint{{Type: "Maintenance", Effective: &effective}},
+				SecurityProfile: carrierv1alpha1.RestrictedSecurityProfile,
+				Template:        corev1.PodTemplateSpec{Spec: corev1.PodSpec{HostNetwork: true}},
+			},
+			errPaths: []string{
+				"spec.template.spec.tls.dnsNames[0]",
+				"spec.template.spec.constraints[0].type",
+				"spec.template.spec.template.spec.hostNetwork",
+				"spec.template.spec.gameVersion",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			template := &carrierv1alpha1.GameServerTemplateSpec{Spec: tc.spec}
+			errs := ValidateGameServerTemplate(template, Policy{TLSDNSSuffixes: []string{"example.com"}},
+				field.NewPath("spec", "template"))
+			if len(errs) != len(tc.errPaths) {
+				t.Fatalf("desired %v errors, get: %v", len(tc.errPaths), errs)
+			}
+			for i, err := range errs {
+				if err.Field != tc.errPaths[i] {
+					t.Errorf("desired error on %v, get: %v", tc.errPaths[i], err.Field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateGameServerTLS(t *testing.T) {
+	tests := []struct {
+		name  string
+		tls   *carrierv1alpha1.GameServerTLS
+		valid bool
+	}{
+		{
+			name:  "not set",
+			valid: true,
+		},
+		{
+			name: "valid",
+			tls: &carrierv1alpha1.GameServerTLS{MountPath: "/etc/tls", DNSNames: []string{"game.example.com"},
+				Duration: &metav1.Duration{Duration: time.Hour}},
+			valid: true,
+		},
+		{
+			name: "relative path",
+			tls:  &carrierv1alpha1.GameServerTLS{MountPath: "tls"},
+		},
+		{
+			name: "dns name not allowed",
+			tls:  &carrierv1alpha1.GameServerTLS{MountPath: "/etc/tls", DNSNames: []string{"bank.example.org"}},
+		},
+		{
+			name:  "dns name of suffix",
+			tls:   &carrierv1alpha1.GameServerTLS{MountPath: "/etc/tls", DNSNames: []string{"example.com"}},
+			valid: true,
+		},
+		{
+			name: "invalid dns name",
+			tls:  &carrierv1alpha1.GameServerTLS{MountPath: "/etc/tls", DNSNames: []string{"Game_Server"}},
+		},
+		{
+			name: "negative duration",
+			tls:  &carrierv1alpha1.GameServerTLS{MountPath: "/etc/tls", Duration: &metav1.Duration{Duration: -time.Hour}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				Spec: carrierv1alpha1.GameServerSpec{TLS: tc.tls},
+			}
+			errs := ValidateGameServerTLS(gs, []string{"example.com"})
+			if tc.valid != (len(errs) == 0) {
+				t.Errorf("desired valid: %v, get: %v", tc.valid, errs)
+			}
+		})
+	}
+}
+
+func TestValidateGameServerConstraints(t *testing.T) {
+	effective := true
+	added := metav1.NewTime(time.Now())
+	expires := metav1.NewTime(added.Add(time.Hour))
+	tests := []struct {
+		constraints []carrierv1alpha1.Constraint
+		valid       bool
+	}{
+		{valid: true},
+		{
+			constraints: []carrierv1alpha1.Constraint{
+				{Type: carrierv1alpha1.NotInService, Effective: &effective},
+				{Type: carrierv1alpha1.NoNewSessions, Effective: &effective, TimeAdded: &added, ExpiresAt: &expires},
+				{Type: "example.com/Maintenance", Effective: &effective},
+			},
+			valid: true,
+		},
+		{constraints: []carrierv1alpha1.Constraint{{Type: "Maintenance", Effective: &effective}}},
+		{constraints: []carrierv1alpha1.Constraint{{Type: "example.com/-bad", Effective: &effective}}},
+		{constraints: []carrierv1alpha1.Constraint{{Effective: &effective}}},
+		{constraints: []carrierv1alpha1.Constraint{{Type: carrierv1alpha1.Cordoned}}},
+		{
+			constraints: []carrierv1alpha1.Constraint{
+				{Type: carrierv1alpha1.Cordoned, Effective: &effective},
+				{Type: carrierv1alpha1.Cordoned, Effective: &effective},
+			},
+		},
+		{
+			constraints: []carrierv1alpha1.Constraint{
+				{Type: carrierv1alpha1.Cordoned, Effective: &effective, TimeAdded: &expires, ExpiresAt: &added},
+			},
+		},
+	}
+	for i, tc := range tests {
+		gs := &carrierv1alpha1.GameServer{
+			Spec: carrierv1alpha1.GameServerSpec{Constraints: tc.constraints},
+		}
+		if errs := ValidateGameServerConstraints(gs); tc.valid != (len(errs) == 0) {
+			t.Errorf("case %v, desired valid: %v, get: %v", i, tc.valid, errs)
+		}
+	}
+}
+
+func TestValidateGameServerSecurityProfile(t *testing.T) {
+	port := int32(7777)
+	privileged, escalation, nonRoot := true, true, false
+	tests := []struct {
+		name     string
+		profile  carrierv1alpha1.SecurityProfile
+		os       carrierv1alpha1.OperatingSystem
+		ports    []carrierv1alpha1.GameServerPort
+		podSpec  corev1.PodSpec
+		errPaths []string
+	}{
+		{
+			name:    "no profile",
+			ports:   []carrierv1alpha1.GameServerPort{{PortPolicy: carrierv1alpha1.Static, HostPort: &port}},
+			podSpec: corev1.PodSpec{HostNetwork: true},
+		},
+		{
+			name:     "not supported",
+			profile:  "Privileged",
+			errPaths: []string{"spec.securityProfile"},
+		},
+		{
+			name:     "windows",
+			profile:  carrierv1alpha1.BaselineSecurityProfile,
+			os:       carrierv1alpha1.Windows,
+			errPaths: []string{"spec.securityProfile"},
+		},
+		{
+			name:    "baseline load balancer",
+			profile: carrierv1alpha1.BaselineSecurityProfile,
+			ports:   []carrierv1alpha1.GameServerPort{{ContainerPort: &port}},
+			podSpec: corev1.PodSpec{Containers: []corev1.Container{{SecurityContext: &corev1.SecurityContext{
+				AllowPrivilegeEscalation: &escalation,
+				Capabilities:             &corev1.Capabilities{Add: []corev1.Capability{"CHOWN"}},
+			}}}},
+		},
+		{
+			name:    "baseline host network and ports",
+			profile: carrierv1alpha1.BaselineSecurityProfile,
+			ports: []carrierv1alpha1.GameServerPort{
+				{ContainerPort: &port, PortPolicy: carrierv1alpha1.Static, HostPort: &port},
+			},
+			podSpec: corev1.PodSpec{
+				HostNetwork: true,
+				Containers: []corev1.Container{{
+					Ports: []corev1.ContainerPort{{ContainerPort: port, HostPort: port}},
+					SecurityContext: &corev1.SecurityContext{
+						Privileged:   &privileged,
+						Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"SYS_ADMIN"}},
+					},
+				}},
+			},
+			errPaths: []string{
+				"spec.ports[0].portPolicy",
+				"spec.ports[0].hostPort",
+				"spec.template.spec.hostNetwork",
+				"spec.template.spec.containers[0].ports[0].hostPort",
+				"spec.template.spec.containers[0].securityContext.privileged",
+				"spec.template.spec.containers[0].securityContext.capabilities.add[0]",
+			},
+		},
+		{
+			name:    "restricted",
+			profile: carrierv1alpha1.RestrictedSecurityProfile,
+			podSpec: corev1.PodSpec{
+				Volumes: []corev1.Volume{{VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{Path: "/data"}}}},
+				InitContainers: []corev1.Container{{SecurityContext: &corev1.SecurityContext{
+					RunAsNonRoot: &nonRoot,
+				}}},
+				Containers: []corev1.Container{{SecurityContext: &corev1.SecurityContext{
+					AllowPrivilegeEscalation: &escalation,
+					Capabilities:             &corev1.Capabilities{Add: []corev1.Capability{"CHOWN", "NET_BIND_SERVICE"}},
+				}}},
+			},
+			errPaths: []string{
+				"spec.template.spec.volumes[0].hostPath",
+				"spec.template.spec.initContainers[0].securityContext.runAsNonRoot",
+				"spec.template.spec.containers[0].securityContext.capabilities.add[0]",
+				"spec.template.spec.containers[0].securityContext.allowPrivilegeEscalation",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := &carrierv1alpha1.GameServer{
+				Spec: carrierv1alpha1.GameServerSpec{
+					SecurityProfile: tc.profile,
+					OS:              tc.os,
+					Ports:           tc.ports,
+					Template:        corev1.PodTemplateSpec{Spec: tc.podSpec},
+				},
+			}
+			errs := ValidateGameServerSecurityProfile(gs)
+			if len(errs) != len(tc.errPaths) {
+				t.Fatalf("desired %v errors, get: %v", len(tc.errPaths), errs)
+			}
+			for i, err := range errs {
+				if err.Field != tc.errPaths[i] {
+					t.Errorf("desired error on %v, get: %v", tc.errPaths[i], err.Field)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/controllers/gameserversets"
+)
+
+// supportedGameServerSetStrategyTypes are the update strategy types of GameServerSets.
+var supportedGameServerSetStrategyTypes = []string{
+	string(carrierv1alpha1.OnDeleteGameServerSetStrategyType),
+	string(carrierv1alpha1.RecreateGameServerSetStrategyType),
+	string(carrierv1alpha1.RollingUpdateGameServerSetStrategyType),
+	string(carrierv1alpha1.InplaceUpdateGameServerSetStrategyType),
+}
+
+// validateGameServerSet returns the admitFunc validating GameServerSet creations and updates
+// against policy.
+func validateGameServerSet(policy Policy) admitFunc {
+	return func(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+			return allowed()
+		}
+		gsSet := &carrierv1alpha1.GameServerSet{}
+		if err := json.Unmarshal(req.Object.Raw, gsSet); err != nil {
+			return errorResponse(err)
+		}
+		errs := ValidateGameServerSetUpdateStrategy(gsSet)
+		errs = append(errs, ValidateGameServerSetNetworkPolicy(gsSet)...)
+		errs = append(errs, ValidateGameServerTemplate(&gsSet.Spec.Template, policy,
+			field.NewPath("spec", "template"))...)
+		if len(errs) == 0 {
+			return allowed()
+		}
+		klog.V(4).Infof("Reject GameServerSet %v/%v: %v", gsSet.Namespace, gsSet.Name, errs)
+		status := k8serrors.NewInvalid(carrierv1alpha1.Kind("GameServerSet"), gsSet.Name, errs).Status()
+		return &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &status,
+		}
+	}
+}
+
+// ValidateGameServerSetUpdateStrategy checks the type of update strategy is supported, and
+// maxUnavailable and threshold are only set for the types using them, as a non-negative
+// number or a percentage no more than 100%.
+func ValidateGameServerSetUpdateStrategy(gsSet *carrierv1alpha1.GameServerSet) field.ErrorList {
+	var allErrs field.ErrorList
+	strategy := gsSet.Spec.UpdateStrategy
+	if strategy == nil {
+		return allErrs
+	}
+	fldPath := field.NewPath("spec", "updateStrategy")
+	if len(strategy.Type) != 0 {
+		supported := false
+		for _, t := range supportedGameServerSetStrategyTypes {
+			if string(strategy.Type) == t {
+				supported = true
+			}
+		}
+		if !supported {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("type"), strategy.Type,
+				supportedGameServerSetStrategyTypes))
+		}
+	}
+	if strategy.MaxUnavailable != nil {
+		if strategy.Type != carrierv1alpha1.RollingUpdateGameServerSetStrategyType {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("maxUnavailable"),
+				"may only be set when type is RollingUpdate"))
+		}
+		allErrs = append(allErrs, validateIntOrPercent(strategy.MaxUnavailable, fldPath.Child("maxUnavailable"))...)
+	}
+	if strategy.Threshold != nil {
+		if strategy.Type != carrierv1alpha1.InplaceUpdateGameServerSetStrategyType {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("threshold"),
+				"may only be set when type is InplaceUpdate"))
+		}
+		allErrs = append(allErrs, validateIntOrPercent(strategy.Threshold, fldPath.Child("threshold"))...)
+	}
+	return allErrs
+}
+
+// validateIntOrPercent checks value is a non-negative number, or a percentage from 0% to 100%.
+func validateIntOrPercent(value *intstr.IntOrString, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch value.Type {
+	case intstr.Int:
+		if value.IntVal < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath, value.IntVal, "must be non-negative"))
+		}
+	case intstr.String:
+		percent, err := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%"))
+		if !strings.HasSuffix(value.StrVal, "%") || err != nil || percent < 0 || percent > 100 {
+			allErrs = append(allErrs, field.Invalid(fldPath, value.StrVal,
+				"must be a percentage from 0% to 100%, e.g. 25%"))
+		}
+	}
+	return allErrs
+}
+
+// ValidateGameServerSetNetworkPolicy checks the ports of GameServers do not expand to too many
+// NetworkPolicy ports, as port ranges are expanded to single ports in the NetworkPolicy.
+func ValidateGameServerSetNetworkPolicy(gsSet *carrierv1alpha1.GameServerSet) field.ErrorList {
+	var allErrs field.ErrorList
+	if gsSet.Spec.NetworkPolicy == nil {
+		return allErrs
+	}
+	if err := gameserversets.ValidateNetworkPolicyPorts(gsSet.Spec.Template.Spec.Ports); err != nil {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "networkPolicy"), "", err.Error()))
+	}
+	return allErrs
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+)
+
+func TestValidateGameServerSetUpdateStrategy(t *testing.T) {
+	percent := func(s string) *intstr.IntOrString {
+		v := intstr.FromString(s)
+		return &v
+	}
+	number := func(i int) *intstr.IntOrString {
+		v := intstr.FromInt(i)
+		return &v
+	}
+	tests := []struct {
+		name     string
+		strategy *carrierv1alpha1.GameServerSetUpdateStrategy
+		valid    bool
+	}{
+		{
+			name:  "not set",
+			valid: true,
+		},
+		{
+			name: "rolling update",
+			strategy: &carrierv1alpha1.GameServerSetUpdateStrategy{
+				Type: carrierv1alpha1.RollingUpdateGameServerSetStrategyType, MaxUnavailable: percent("30%")},
+			valid: true,
+		},
+		{
+			name: "in place update",
+			strategy: &carrierv1alpha1.GameServerSetUpdateStrategy{
+				Type: carrierv1alpha1.InplaceUpdateGameServerSetStrategyType, Threshold: number(3)},
+			valid: true,
+		},
+		{
+			name:     "unknown type",
+			strategy: &carrierv1alpha1.GameServerSetUpdateStrategy{Type: "Rolling"},
+		},
+		{
+			name: "max unavailable of other type",
+			strategy: &carrierv1alpha1.GameServerSetUpdateStrategy{
+				Type: carrierv1alpha1.RecreateGameServerSetStrategyType, MaxUnavailable: number(1)},
+		},
+		{
+			name: "threshold of other type",
+			strategy: &carrierv1alpha1.GameServerSetUpdateStrategy{
+				Type: carrierv1alpha1.RollingUpdateGameServerSetStrategyType, Threshold: number(1)},
+		},
+		{
+			name: "negative max unavailable",
+			strategy: &carrierv1alpha1.GameServerSetUpdateStrategy{
+				Type: carrierv1alpha1.RollingUpdateGameServerSetStrategyType, MaxUnavailable: number(-1)},
+		},
+		{
+			name: "invalid percentage",
+			strategy: &carrierv1alpha1.GameServerSetUpdateStrategy{
+				Type: carrierv1alpha1.InplaceUpdateGameServerSetStrategyType, Threshold: percent("120%")},
+		},
+		{
+			name: "not a percentage",
+			strategy: &carrierv1alpha1.GameServerSetUpdateStrategy{
+				Type: carrierv1alpha1.RollingUpdateGameServerSetStrategyType, MaxUnavailable: percent("half")},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gsSet := &carrierv1alpha1.GameServerSet{
+				Spec: carrierv1alpha1.GameServerSetSpec{UpdateStrategy: tc.strategy},
+			}
+			errs := ValidateGameServerSetUpdateStrategy(gsSet)
+			if tc.valid != (len(errs) == 0) {
+				t.Errorf("desired valid: %v, get: %v", tc.valid, errs)
+			}
+		})
+	}
+}
+
+func TestValidateGameServerSetNetworkPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		networkPolicy *carrierv1alpha1.NetworkPolicy
+		portRange     *carrierv1alpha1.PortRange
+		valid         bool
+	}{
+		{name: "no NetworkPolicy", portRange: &carrierv1alpha1.PortRange{MinPort: 1, MaxPort: 65535}, valid: true},
+		{
+			name:          "small range",
+			networkPolicy: &carrierv1alpha1.NetworkPolicy{},
+			portRange:     &carrierv1alpha1.PortRange{MinPort: 7000, MaxPort: 7009},
+			valid:         true,
+		},
+		{
+			name:          "large range",
+			networkPolicy: &carrierv1alpha1.NetworkPolicy{},
+			portRange:     &carrierv1alpha1.PortRange{MinPort: 1, MaxPort: 65535},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gsSet := &carrierv1alpha1.GameServerSet{}
+			gsSet.Spec.NetworkPolicy = tc.networkPolicy
+			gsSet.Spec.Template.Spec.Ports = []carrierv1alpha1.GameServerPort{
+				{Name: "range", ContainerPortRange: tc.portRange},
+			}
+			errs := ValidateGameServerSetNetworkPolicy(gsSet)
+			if tc.valid != (len(errs) == 0) {
+				t.Errorf("desired valid: %v, get: %v", tc.valid, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/klog"
+
+	carrierv1alpha1client "github.com/ocgi/carrier/pkg/client/clientset/versioned/typed/carrier/v1alpha1"
+)
+
+const (
+	// ValidateSquadPath is the path serving Squad validation
+	ValidateSquadPath = "/validate-squad"
+	// ValidateGameServerPath is the path serving GameServer validation
+	ValidateGameServerPath = "/validate-gameserver"
+	// ValidateGameServerSetPath is the path serving GameServerSet validation
+	ValidateGameServerSetPath = "/validate-gameserverset"
+	// MutateGameServerPath is the path serving GameServer mutation
+	MutateGameServerPath = "/mutate-gameserver"
+	// MutateSquadPath is the path serving Squad mutation
+	MutateSquadPath = "/mutate-squad"
+)
+
+// admitFunc handles an AdmissionRequest and returns the response.
+type admitFunc func(*admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+// Policy is the operator configured policy GameServers are validated against.
+type Policy struct {
+	// TLSDNSSuffixes are the domains extra DNS names of GameServer certificates must be under,
+	// no extra DNS names are allowed if empty.
+	TLSDNSSuffixes []string
+	// AssetCacheRoot is the host directory asset caches on host path must be under, asset
+	// caches on host path are not allowed if empty.
+	AssetCacheRoot string
+	// PostMortemRoot is the host directory crash dumps of GameServers must be under, post
+	// mortem is not allowed if empty.
+	PostMortemRoot string
+}
+
+// Server serves the admission webhooks of carrier.
+type Server struct {
+	addr     string
+	certFile string
+	keyFile  string
+	mux      *http.ServeMux
+}
+
+// NewServer returns a new admission webhook server listening on port,
+// certFile and keyFile are used for serving TLS. profiles are merged into
+// Squads referencing them. Images of Squads are pinned to digest by resolver
+// if it is not nil, with the image pull secrets got from secrets. GameServers
+// are validated against policy.
+func NewServer(port int, certFile, keyFile string, profiles carrierv1alpha1client.FleetProfileInterface,
+	secrets corev1client.SecretsGetter, resolver ImageResolver, policy Policy) *Server {
+	s := &Server{
+		addr:     fmt.Sprintf(":%d", port),
+		certFile: certFile,
+		keyFile:  keyFile,
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc(ValidateSquadPath, serve(validateSquad(policy)))
+	s.mux.HandleFunc(ValidateGameServerPath, serve(validateGameServer(policy)))
+	s.mux.HandleFunc(ValidateGameServerSetPath, serve(validateGameServerSet(policy)))
+	s.mux.HandleFunc(MutateGameServerPath, serve(mutateGameServer))
+	s.mux.HandleFunc(MutateSquadPath, serve(mutateSquad(profiles, secrets, resolver)))
+	return s
+}
+
+// Run starts the webhook server. Will block until stop is closed.
+func (s *Server) Run(stop <-chan struct{}) error {
+	server := &http.Server{
+		Addr:    s.addr,
+		Handler: s.mux,
+	}
+	go func() {
+		<-stop
+		server.Close()
+	}()
+	klog.Infof("Starting webhook server on %v", s.addr)
+	err := server.ListenAndServeTLS(s.certFile, s.keyFile)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// serve decodes the AdmissionReview, calls admit and writes back the response.
+func serve(admit admitFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		review := &admissionv1.AdmissionReview{}
+		if err = json.Unmarshal(body, review); err != nil {
+			http.Error(w, fmt.Sprintf("could not decode admission review: %v", err), http.StatusBadRequest)
+			return
+		}
+		if review.Request == nil {
+			http.Error(w, "admission review without request", http.StatusBadRequest)
+			return
+		}
+		response := admit(review.Request)
+		response.UID = review.Request.UID
+		review.Response = response
+		review.Request = nil
+		resp, err := json.Marshal(review)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err = w.Write(resp); err != nil {
+			klog.Errorf("Failed to write admission response: %v", err)
+		}
+	}
+}
+
+// allowed returns a response allowing the request.
+func allowed() *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+// errorResponse returns a response denying the request because of err.
+func errorResponse(err error) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		},
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+// inplaceForbiddenMessage explains which changes can be applied in place.
+var inplaceForbiddenMessage = fmt.Sprintf("only the image of container %q can be updated when strategy is %s, "+
+	"use %s, %s or %s to roll out this change", util.GameServerContainerName,
+	carrierv1alpha1.InplaceUpdateSquadStrategyType, carrierv1alpha1.RollingUpdateSquadStrategyType,
+	carrierv1alpha1.CanaryUpdateSquadStrategyType, carrierv1alpha1.RecreateSquadStrategyType)
+
+// validateSquad returns the admitFunc validating Squad creations and updates against policy.
+func validateSquad(policy Policy) admitFunc {
+	return func(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+			return allowed()
+		}
+		squad := &carrierv1alpha1.Squad{}
+		if err := json.Unmarshal(req.Object.Raw, squad); err != nil {
+			return errorResponse(err)
+		}
+		errs := ValidateGameServerTemplate(&squad.Spec.Template, policy, field.NewPath("spec", "template"))
+		if req.Operation == admissionv1.Update {
+			oldSquad := &carrierv1alpha1.Squad{}
+			if err := json.Unmarshal(req.OldObject.Raw, oldSquad); err != nil {
+				return errorResponse(err)
+			}
+			errs = append(errs, ValidateSquadInplaceUpdate(oldSquad, squad)...)
+		}
+		if len(errs) == 0 {
+			return allowed()
+		}
+		klog.V(4).Infof("Reject Squad %v/%v: %v", squad.Namespace, squad.Name, errs)
+		status := k8serrors.NewInvalid(carrierv1alpha1.Kind("Squad"), squad.Name, errs).Status()
+		return &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &status,
+		}
+	}
+}
+
+// ValidateSquadInplaceUpdate checks if the template change of a Squad can be
+// applied by InplaceUpdate strategy. Only the image of the GameServer container
+// is updated in place, so other changes of the template would leave GameServers
+// half updated.
+func ValidateSquadInplaceUpdate(oldSquad, squad *carrierv1alpha1.Squad) field.ErrorList {
+	var allErrs field.ErrorList
+	if squad.Spec.Strategy.Type != carrierv1alpha1.InplaceUpdateSquadStrategyType {
+		return allErrs
+	}
+	fldPath := field.NewPath("spec", "template", "spec")
+	oldSpec := &oldSquad.Spec.Template.Spec
+	newSpec := &squad.Spec.Template.Spec
+	if !apiequality.Semantic.DeepEqual(oldSpec.Ports, newSpec.Ports) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("ports"), inplaceForbiddenMessage))
+	}
+	podPath := fldPath.Child("template", "spec")
+	oldPodSpec := oldSpec.Template.Spec.DeepCopy()
+	newPodSpec := newSpec.Template.Spec.DeepCopy()
+	if !apiequality.Semantic.DeepEqual(oldPodSpec.Volumes, newPodSpec.Volumes) {
+		allErrs = append(allErrs, field.Forbidden(podPath.Child("volumes"), inplaceForbiddenMessage))
+	}
+	allErrs = append(allErrs, validateContainersInplaceUpdate(oldPodSpec.InitContainers,
+		newPodSpec.InitContainers, podPath.Child("initContainers"))...)
+	allErrs = append(allErrs, validateContainersInplaceUpdate(oldPodSpec.Containers,
+		newPodSpec.Containers, podPath.Child("containers"))...)
+	if len(allErrs) != 0 {
+		return allErrs
+	}
+	// fields checked above are equal now, compare the others.
+	oldPodSpec.Volumes, newPodSpec.Volumes = nil, nil
+	oldPodSpec.InitContainers, newPodSpec.InitContainers = nil, nil
+	oldPodSpec.Containers, newPodSpec.Containers = nil, nil
+	if !apiequality.Semantic.DeepEqual(oldPodSpec, newPodSpec) {
+		allErrs = append(allErrs, field.Forbidden(podPath, inplaceForbiddenMessage))
+	}
+	return allErrs
+}
+
+// validateContainersInplaceUpdate checks containers only differ in the image of GameServer container.
+func validateContainersInplaceUpdate(oldContainers, containers []corev1.Container,
+	fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(oldContainers) != len(containers) {
+		return append(allErrs, field.Forbidden(fldPath, inplaceForbiddenMessage))
+	}
+	for i := range containers {
+		oldContainer := oldContainers[i].DeepCopy()
+		container := containers[i].DeepCopy()
+		if container.Name == util.GameServerContainerName && oldContainer.Name == container.Name {
+			oldContainer.Image, container.Image = "", ""
+		}
+		if !apiequality.Semantic.DeepEqual(oldContainer, container) {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Index(i), inplaceForbiddenMessage))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 The OCGI Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	carrierv1alpha1 "github.com/ocgi/carrier/pkg/apis/carrier/v1alpha1"
+	"github.com/ocgi/carrier/pkg/util"
+)
+
+func newSquad(strategy carrierv1alpha1.SquadStrategyType) *carrierv1alpha1.Squad {
+	port := int32(7777)
+	return &carrierv1alpha1.Squad{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: metav1.NamespaceDefault,
+		},
+		Spec: carrierv1alpha1.SquadSpec{
+			Replicas: 2,
+			Strategy: carrierv1alpha1.SquadStrategy{Type: strategy},
+			Template: carrierv1alpha1.GameServerTemplateSpec{
+				Spec: carrierv1alpha1.GameServerSpec{
+					Ports: []carrierv1alpha1.GameServerPort{
+						{
+							Name:          "default",
+							ContainerPort: &port,
+						},
+					},
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{
+								{
+									Name:  util.GameServerContainerName,
+									Image: "server:v1",
+								},
+								{
+									Name:  "sidecar",
+									Image: "sidecar:v1",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestValidateSquadInplaceUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy carrierv1alpha1.SquadStrategyType
+		mutate   func(squad *carrierv1alpha1.Squad)
+		errPaths []string
+	}{
+		{
+			name:     "server image changed",
+			strategy: carrierv1alpha1.InplaceUpdateSquadStrategyType,
+			mutate: func(squad *carrierv1alpha1.Squad) {
+				squad.Spec.Template.Spec.Template.Spec.Containers[0].Image = "server:v2"
+			},
+		},
+		{
+			name:     "replicas changed",
+			strategy: carrierv1alpha1.InplaceUpdateSquadStrategyType,
+			mutate: func(squad *carrierv1alpha1.Squad) {
+				squad.Spec.Replicas = 5
+			},
+		},
+		{
+			name:     "sidecar image changed",
+			strategy: carrierv1alpha1.InplaceUpdateSquadStrategyType,
+			mutate: func(squad *carrierv1alpha1.Squad) {
+				squad.Spec.Template.Spec.Template.Spec.Containers[1].Image = "sidecar:v2"
+			},
+			errPaths: []string{"spec.template.spec.template.spec.containers[1]"},
+		},
+		{
+			name:     "ports and volumes changed",
+			strategy: carrierv1alpha1.InplaceUpdateSquadStrategyType,
+			mutate: func(squad *carrierv1alpha1.Squad) {
+				squad.Spec.Template.Spec.Ports[0].Name = "changed"
+				squad.Spec.Template.Spec.Template.Spec.Volumes = []corev1.Volume{{Name: "data"}}
+			},
+			errPaths: []string{"spec.template.spec.ports", "spec.template.spec.template.spec.volumes"},
+		},
+		{
+			name:     "node selector changed",
+			strategy: carrierv1alpha1.InplaceUpdateSquadStrategyType,
+			mutate: func(squad *carrierv1alpha1.Squad) {
+				squad.Spec.Template.Spec.Template.Spec.NodeSelector = map[string]string{"zone": "a"}
+			},
+			errPaths: []string{"spec.template.spec.template.spec"},
+		},
+		{
+			name:     "volumes changed with rolling update",
+			strategy: carrierv1alpha1.RollingUpdateSquadStrategyType,
+			mutate: func(squad *carrierv1alpha1.Squad) {
+				squad.Spec.Template.Spec.Template.Spec.Volumes = []corev1.Volume{{Name: "data"}}
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			oldSquad := newSquad(tc.strategy)
+			squad := oldSquad.DeepCopy()
+			tc.mutate(squad)
+			errs := ValidateSquadInplaceUpdate(oldSquad, squad)
+			if len(errs) != len(tc.errPaths) {
+				t.Fatalf("desired %v errors, get: %v", len(tc.errPaths), errs)
+			}
+			for i, err := range errs {
+				if err.Field != tc.errPaths[i] {
+					t.Errorf("desired error on %v, get: %v", tc.errPaths[i], err.Field)
+				}
+			}
+		})
+	}
+}
+
+func TestServeValidateSquad(t *testing.T) {
+	oldSquad := newSquad(carrierv1alpha1.InplaceUpdateSquadStrategyType)
+	squad := oldSquad.DeepCopy()
+	squad.Spec.Template.Spec.Template.Spec.Volumes = []corev1.Volume{{Name: "data"}}
+	oldRaw, _ := json.Marshal(oldSquad)
+	raw, _ := json.Marshal(squad)
+	review := admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "test-uid",
+			Operation: admissionv1.Update,
+			Object:    runtime.RawExtension{Raw: raw},
+			OldObject: runtime.RawExtension{Raw: oldRaw},
+		},
+	}
+	body, _ := json.Marshal(review)
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, ValidateSquadPath, bytes.NewReader(body))
+	serve(validateSquad(Policy{}))(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("desired status code %v, get: %v", http.StatusOK, recorder.Code)
+	}
+	result := admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Response == nil || result.Response.UID != "test-uid" {
+		t.Fatalf("desired response of request test-uid, get: %+v", result.Response)
+	}
+	if result.Response.Allowed {
+		t.Errorf("desired update of volumes rejected")
+	}
+}